@@ -0,0 +1,120 @@
+package abd
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Operation 是 history 中的一次读或写
+// Call 和 Return 是调用和返回的逻辑时间，只用来比较先后
+type Operation struct {
+	Client int
+	Write  bool
+	Value  interface{} // 写入的值，或者读到的值
+	Call   int64
+	Return int64
+}
+
+func (o Operation) String() string {
+	kind := "read"
+	if o.Write {
+		kind = "write"
+	}
+	return fmt.Sprintf("C%d %s(%v) [%d, %d]", o.Client, kind, o.Value, o.Call, o.Return)
+}
+
+// History 记录并发的 client 的操作，可以在多个 goroutine 中同时使用
+type History struct {
+	mutex sync.Mutex
+	clock int64
+	ops   []Operation
+}
+
+// Begin 记录一次操作的调用，返回这次操作的编号
+// 读操作的 value 会被 End 覆盖
+func (h *History) Begin(client int, write bool, value interface{}) int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.clock++
+	h.ops = append(h.ops, Operation{Client: client, Write: write, Value: value, Call: h.clock, Return: -1})
+	return len(h.ops) - 1
+}
+
+// End 记录第 i 次操作的返回，value 是读到的值，写操作会忽略它
+func (h *History) End(i int, value interface{}) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.clock++
+	if !h.ops[i].Write {
+		h.ops[i].Value = value
+	}
+	h.ops[i].Return = h.clock
+}
+
+// Operations 返回所有已经返回的操作
+func (h *History) Operations() []Operation {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	res := make([]Operation, 0, len(h.ops))
+	for _, o := range h.ops {
+		if o.Return >= 0 {
+			res = append(res, o)
+		}
+	}
+	return res
+}
+
+// Linearizable 返回 true，如果 ops 对于初始值为 init 的寄存器是可线性化的：
+// 存在一个所有操作的全序，它与实时顺序一致，即 a 返回后 b 才调用时，a 排在 b 前面，
+// 并且每次读取都返回全序中前一次写入的值。
+// 采用 Wing 和 Gong 的回溯搜索，并用已经线性化的操作集合和寄存器的值做备忘，
+// 所以 ops 最多有 64 个
+func Linearizable(ops []Operation, init interface{}) bool {
+	if len(ops) > 64 {
+		panic("abd: too many operations to check linearizability")
+	}
+	ops = append([]Operation(nil), ops...)
+	sort.Slice(ops, func(i, j int) bool { return ops[i].Call < ops[j].Call })
+	type state struct {
+		done  uint64
+		value interface{}
+	}
+	all := uint64(1)<<uint(len(ops)) - 1
+	if len(ops) == 64 {
+		all = ^uint64(0)
+	}
+	failed := make(map[state]bool)
+	var search func(done uint64, value interface{}) bool
+	search = func(done uint64, value interface{}) bool {
+		if done == all {
+			return true
+		}
+		s := state{done, value}
+		if failed[s] {
+			return false
+		}
+		// 下一个线性化的操作，必须在所有还没有线性化的操作返回之前调用
+		var deadline int64 = -1
+		for i, o := range ops {
+			if done&(1<<uint(i)) == 0 && (deadline < 0 || o.Return < deadline) {
+				deadline = o.Return
+			}
+		}
+		for i, o := range ops {
+			if done&(1<<uint(i)) != 0 || o.Call > deadline {
+				continue
+			}
+			if o.Write {
+				if search(done|1<<uint(i), o.Value) {
+					return true
+				}
+			} else if o.Value == value && search(done|1<<uint(i), value) {
+				return true
+			}
+		}
+		failed[s] = true
+		return false
+	}
+	return search(0, init)
+}
@@ -0,0 +1,62 @@
+package abd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeOp(client int, value interface{}, call, ret int64) Operation {
+	return Operation{Client: client, Write: true, Value: value, Call: call, Return: ret}
+}
+
+func readOp(client int, value interface{}, call, ret int64) Operation {
+	return Operation{Client: client, Value: value, Call: call, Return: ret}
+}
+
+func Test_Linearizable_sequential(t *testing.T) {
+	ast := assert.New(t)
+	//
+	ast.True(Linearizable(nil, nil))
+	ast.True(Linearizable([]Operation{readOp(0, 0, 1, 2), writeOp(0, 1, 3, 4), readOp(1, 1, 5, 6)}, 0))
+	ast.False(Linearizable([]Operation{writeOp(0, 1, 1, 2), readOp(1, 0, 3, 4)}, 0))
+	ast.False(Linearizable([]Operation{readOp(0, 1, 1, 2)}, 0))
+}
+
+func Test_Linearizable_concurrent(t *testing.T) {
+	ast := assert.New(t)
+	//
+	// 与写入并发的读取，读到新值和旧值都可以
+	ast.True(Linearizable([]Operation{writeOp(0, 1, 1, 10), readOp(1, 1, 2, 3)}, 0))
+	ast.True(Linearizable([]Operation{writeOp(0, 1, 1, 10), readOp(1, 0, 2, 3)}, 0))
+	// 但是先读到新值以后，之后的读取不能再读到旧值
+	ast.True(Linearizable([]Operation{writeOp(0, 1, 1, 10), readOp(1, 0, 2, 3), readOp(2, 1, 4, 5)}, 0))
+	ast.False(Linearizable([]Operation{writeOp(0, 1, 1, 10), readOp(1, 1, 2, 3), readOp(2, 0, 4, 5)}, 0))
+}
+
+func Test_Linearizable_multiWriter(t *testing.T) {
+	ast := assert.New(t)
+	//
+	// 两个并发的写入可以按照任意顺序线性化
+	ops := []Operation{writeOp(0, 1, 1, 4), writeOp(1, 2, 2, 5), readOp(2, 1, 6, 7)}
+	ast.True(Linearizable(ops, 0))
+	ops = []Operation{writeOp(0, 1, 1, 4), writeOp(1, 2, 2, 5), readOp(2, 2, 6, 7)}
+	ast.True(Linearizable(ops, 0))
+	// 但是两个读者必须看到同一个顺序
+	ops = []Operation{writeOp(0, 1, 1, 4), writeOp(1, 2, 2, 5), readOp(2, 1, 6, 7), readOp(2, 2, 8, 9), readOp(3, 1, 10, 11)}
+	ast.False(Linearizable(ops, 0))
+}
+
+func Test_History(t *testing.T) {
+	ast := assert.New(t)
+	//
+	h := &History{}
+	w := h.Begin(0, true, 1)
+	r := h.Begin(1, false, nil)
+	h.End(r, 0)
+	h.Begin(2, false, nil) // 还没有返回的操作不会出现在 history 中
+	h.End(w, "ignored")
+	ops := h.Operations()
+	ast.Equal([]Operation{writeOp(0, 1, 1, 5), readOp(1, 0, 2, 3)}, ops)
+	ast.Equal("C0 write(1) [1, 5]", ops[0].String())
+}
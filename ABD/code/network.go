@@ -0,0 +1,117 @@
+package abd
+
+import (
+	"sync"
+	"time"
+
+	reliablechannel "github.com/aQuaYi/Distributed-Algorithms/Reliable-Channel/code"
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+)
+
+// Network 模拟了 client 和 replica 之间的异步网络
+// message 不会丢失，但是会按照链路的延迟分布被任意延迟，所以可能乱序送达。
+// 崩溃的 replica 不再处理 message。崩溃的 replica 必须是少数，否则读写永远不会返回。
+type Network struct {
+	mutex    sync.Mutex
+	env      sim.Env
+	latency  reliablechannel.Latency
+	links    map[[2]int]reliablechannel.Latency // 按照 [client, replica] 单独设置的延迟
+	replicas []*replica
+	crashed  map[int]bool
+	sent     int
+}
+
+// NewNetwork 返回有 n 个 replica 的网络，链路延迟默认服从 latency，延迟的随机抽样由 seed 决定
+// 寄存器的初始值是 nil
+func NewNetwork(n int, latency reliablechannel.Latency, seed int64) *Network {
+	return NewNetworkWithEnv(n, latency, sim.Env{Rand: sim.NewRand(seed)})
+}
+
+// NewNetworkWithEnv 与 NewNetwork 一样，但是延迟的抽样和 message 的投递都由 env 决定
+func NewNetworkWithEnv(n int, latency reliablechannel.Latency, env sim.Env) *Network {
+	net := &Network{
+		env:      env,
+		latency:  latency,
+		links:    make(map[[2]int]reliablechannel.Latency, 16),
+		replicas: make([]*replica, n),
+		crashed:  make(map[int]bool, n),
+	}
+	for i := range net.replicas {
+		net.replicas[i] = &replica{}
+	}
+	return net
+}
+
+// Size 返回 replica 的数量
+func (n *Network) Size() int {
+	return len(n.replicas)
+}
+
+// majority 返回多数派的大小
+func (n *Network) majority() int {
+	return len(n.replicas)/2 + 1
+}
+
+// SetLatency 单独设置 client 与 replica 之间双向链路的延迟分布
+func (n *Network) SetLatency(client, replica int, latency reliablechannel.Latency) {
+	n.mutex.Lock()
+	n.links[[2]int{client, replica}] = latency
+	n.mutex.Unlock()
+}
+
+// Crash 让 replica id 崩溃
+func (n *Network) Crash(id int) {
+	n.mutex.Lock()
+	n.crashed[id] = true
+	n.mutex.Unlock()
+}
+
+// Sent 返回网络中发送过的 message 数量，包括请求和回复
+func (n *Network) Sent() int {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return n.sent
+}
+
+// delay 抽样一条 client 与 replica 之间的 message 的延迟，并计数
+func (n *Network) delay(client, replica int) time.Duration {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.sent++
+	latency, ok := n.links[[2]int{client, replica}]
+	if !ok {
+		latency = n.latency
+	}
+	if latency == nil {
+		return 0
+	}
+	return latency.Sample(n.env)
+}
+
+// call 把 client 的 req 发送给所有的 replica，返回最先到达的多数派的回复
+func (n *Network) call(client int, req request) []reply {
+	replies := make(chan reply, len(n.replicas))
+	for i := range n.replicas {
+		n.send(client, i, req, replies)
+	}
+	res := make([]reply, 0, n.majority())
+	for len(res) < n.majority() {
+		res = append(res, <-replies)
+	}
+	return res
+}
+
+func (n *Network) send(client, to int, req request, replies chan<- reply) {
+	n.env.AfterFunc(n.delay(client, to), func() {
+		n.mutex.Lock()
+		crashed := n.crashed[to]
+		n.mutex.Unlock()
+		if crashed {
+			return
+		}
+		rep := n.replicas[to].handle(req)
+		rep.from = to
+		// replies 的容量足够容纳所有的回复，不会阻塞
+		n.env.AfterFunc(n.delay(client, to), func() { replies <- rep })
+	})
+}
@@ -0,0 +1,137 @@
+package abd
+
+import "sync"
+
+// Tag 是写入的版本号
+// 单写者时 Writer 总是同一个，只比较 Seq。多写者时用 Writer 打破 Seq 相同的平局。
+type Tag struct {
+	Seq    int
+	Writer int
+}
+
+// Less 返回 true，如果 t 比 u 旧
+func (t Tag) Less(u Tag) bool {
+	if t.Seq != u.Seq {
+		return t.Seq < u.Seq
+	}
+	return t.Writer < u.Writer
+}
+
+// request 是 client 发送给 replica 的 message
+type request struct {
+	update bool // 为 false 时查询 replica 保存的值
+	tag    Tag
+	value  interface{}
+}
+
+// reply 是 replica 的回复
+type reply struct {
+	from  int
+	tag   Tag
+	value interface{}
+}
+
+// replica 保存寄存器的一个副本
+type replica struct {
+	mutex sync.Mutex
+	tag   Tag
+	value interface{}
+}
+
+// handle 处理 req，只有 req 的 tag 更新时，才会覆盖保存的值
+func (r *replica) handle(req request) reply {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if req.update && r.tag.Less(req.tag) {
+		r.tag, r.value = req.tag, req.value
+	}
+	return reply{tag: r.tag, value: r.value}
+}
+
+// latest 返回 replies 中 tag 最新的回复
+func latest(replies []reply) reply {
+	res := replies[0]
+	for _, r := range replies[1:] {
+		if res.tag.Less(r.tag) {
+			res = r
+		}
+	}
+	return res
+}
+
+// Writer 是单写者寄存器唯一的写者
+// 写者自己知道最新的 Seq，所以一次写入只需要一轮：把新的值发给多数派
+type Writer struct {
+	net *Network
+	id  int
+	seq int
+}
+
+// NewWriter 返回 net 上的写者，id 用于区分链路，不能与其他 client 相同
+func NewWriter(net *Network, id int) *Writer {
+	return &Writer{net: net, id: id}
+}
+
+// Write 写入 value，多数派确认以后才返回
+// 同一个 Writer 的 Write 不能并发调用
+func (w *Writer) Write(value interface{}) {
+	w.seq++
+	w.net.call(w.id, request{update: true, tag: Tag{Seq: w.seq, Writer: w.id}, value: value})
+}
+
+// Reader 读取寄存器
+type Reader struct {
+	net       *Network
+	id        int
+	writeBack bool
+}
+
+// NewReader 返回 net 上的读者
+// 读取分为两轮：先从多数派查询最新的值，再把这个值写回多数派，
+// 写回保证了之后的读取不会读到更旧的值，这正是原子寄存器和正则寄存器的区别
+func NewReader(net *Network, id int) *Reader {
+	return &Reader{net: net, id: id, writeBack: true}
+}
+
+// NewRegularReader 返回不写回的读者，只用于演示
+// 它只能实现正则寄存器：与写入并发的两次读取可能先读到新值，再读到旧值
+func NewRegularReader(net *Network, id int) *Reader {
+	return &Reader{net: net, id: id}
+}
+
+// Read 返回寄存器的值
+func (r *Reader) Read() interface{} {
+	return read(r.net, r.id, r.writeBack)
+}
+
+func read(net *Network, id int, writeBack bool) interface{} {
+	max := latest(net.call(id, request{}))
+	if writeBack {
+		net.call(id, request{update: true, tag: max.tag, value: max.value})
+	}
+	return max.value
+}
+
+// Client 是多写者多读者寄存器的 client，可以读也可以写
+type Client struct {
+	net *Network
+	id  int
+}
+
+// NewClient 返回 net 上的 client，id 也是 Tag 中的 Writer，不能与其他 client 相同
+func NewClient(net *Network, id int) *Client {
+	return &Client{net: net, id: id}
+}
+
+// Write 写入 value
+// 多个写者不知道彼此的 Seq，所以先从多数派查询最大的 Seq，再用更大的 Seq 写入多数派
+func (c *Client) Write(value interface{}) {
+	max := latest(c.net.call(c.id, request{}))
+	tag := Tag{Seq: max.tag.Seq + 1, Writer: c.id}
+	c.net.call(c.id, request{update: true, tag: tag, value: value})
+}
+
+// Read 返回寄存器的值，与 Reader.Read 相同
+func (c *Client) Read() interface{} {
+	return read(c.net, c.id, true)
+}
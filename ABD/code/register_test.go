@@ -0,0 +1,171 @@
+package abd
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	reliablechannel "github.com/aQuaYi/Distributed-Algorithms/Reliable-Channel/code"
+	"github.com/stretchr/testify/assert"
+)
+
+var jitter = reliablechannel.Uniform{Max: 2 * time.Millisecond}
+
+func Test_Tag_Less(t *testing.T) {
+	ast := assert.New(t)
+	//
+	ast.True(Tag{1, 5}.Less(Tag{2, 0}))
+	ast.True(Tag{2, 0}.Less(Tag{2, 1}))
+	ast.False(Tag{2, 1}.Less(Tag{2, 1}))
+	ast.False(Tag{3, 0}.Less(Tag{2, 9}))
+}
+
+func Test_replica_handle(t *testing.T) {
+	ast := assert.New(t)
+	//
+	r := &replica{}
+	r.handle(request{update: true, tag: Tag{2, 0}, value: "new"})
+	// 旧的写入不会覆盖新的值
+	rep := r.handle(request{update: true, tag: Tag{1, 0}, value: "old"})
+	ast.Equal(Tag{2, 0}, rep.tag)
+	ast.Equal("new", rep.value)
+	ast.Equal("new", r.handle(request{}).value)
+}
+
+func Test_Writer_Reader(t *testing.T) {
+	ast := assert.New(t)
+	//
+	net := NewNetwork(5, jitter, 1)
+	w, r := NewWriter(net, 0), NewReader(net, 1)
+	ast.Nil(r.Read())
+	w.Write(1)
+	ast.Equal(1, r.Read())
+	w.Write(2)
+	ast.Equal(2, r.Read())
+	// 写入一轮，读取两轮，每轮 5 个请求和至少 3 个回复
+	ast.True(net.Sent() >= 3*(3+3))
+}
+
+// record 让 clients 个 client 并发地执行 ops 次 op，返回记录下的 history
+func record(clients, ops int, op func(h *History, client, i int)) []Operation {
+	h := &History{}
+	var wg sync.WaitGroup
+	for c := 0; c < clients; c++ {
+		wg.Add(1)
+		go func(c int) {
+			defer wg.Done()
+			for i := 0; i < ops; i++ {
+				op(h, c, i)
+			}
+		}(c)
+	}
+	wg.Wait()
+	return h.Operations()
+}
+
+func Test_SWMR_linearizable(t *testing.T) {
+	ast := assert.New(t)
+	//
+	for seed := int64(0); seed < 5; seed++ {
+		net := NewNetwork(5, jitter, seed)
+		w := NewWriter(net, 0)
+		readers := []*Reader{nil, NewReader(net, 1), NewReader(net, 2), NewReader(net, 3)}
+		ops := record(4, 5, func(h *History, c, i int) {
+			if c == 0 {
+				v := i + 1
+				op := h.Begin(c, true, v)
+				w.Write(v)
+				h.End(op, nil)
+				return
+			}
+			op := h.Begin(c, false, nil)
+			h.End(op, readers[c].Read())
+		})
+		ast.Len(ops, 20)
+		ast.True(Linearizable(ops, nil), "seed %d: %v", seed, ops)
+	}
+}
+
+func Test_MWMR_linearizable(t *testing.T) {
+	ast := assert.New(t)
+	//
+	for seed := int64(0); seed < 5; seed++ {
+		net := NewNetwork(3, jitter, seed)
+		clients := []*Client{NewClient(net, 0), NewClient(net, 1), NewClient(net, 2), NewClient(net, 3)}
+		ops := record(4, 6, func(h *History, c, i int) {
+			if i%2 == 0 {
+				v := c*100 + i
+				op := h.Begin(c, true, v)
+				clients[c].Write(v)
+				h.End(op, nil)
+				return
+			}
+			op := h.Begin(c, false, nil)
+			h.End(op, clients[c].Read())
+		})
+		ast.Len(ops, 24)
+		ast.True(Linearizable(ops, nil), "seed %d: %v", seed, ops)
+	}
+}
+
+func Test_Crash_minority(t *testing.T) {
+	ast := assert.New(t)
+	//
+	net := NewNetwork(5, jitter, 1)
+	net.Crash(0)
+	net.Crash(3)
+	a, b := NewClient(net, 0), NewClient(net, 1)
+	a.Write("a")
+	ast.Equal("a", b.Read())
+	b.Write("b")
+	ast.Equal("b", a.Read())
+}
+
+// inversion 构造正则寄存器会出现的新旧倒置：
+// 写者到 replica 1 和 2 的链路很慢，新的值只很快到达了 replica 0，写入迟迟不能返回。
+// 读者 A 到 replica 2 的链路很慢，所以从 replica 0 和 1 读到了新的值；
+// A 返回以后，读者 B 开始读取，它到 replica 0 的链路很慢，所以从 replica 1 和 2 读取。
+// 没有写回时，B 会读到旧的值。
+func inversion(newReader func(net *Network, id int) *Reader) []Operation {
+	slow := reliablechannel.Fixed(200 * time.Millisecond)
+	net := NewNetwork(3, reliablechannel.Fixed(time.Millisecond), 1)
+	net.SetLatency(0, 1, slow)
+	net.SetLatency(0, 2, slow)
+	net.SetLatency(1, 2, slow)
+	net.SetLatency(2, 0, slow)
+	w, a, b := NewWriter(net, 0), newReader(net, 1), newReader(net, 2)
+
+	h := &History{}
+	done := make(chan struct{})
+	go func() {
+		op := h.Begin(0, true, "new")
+		w.Write("new")
+		h.End(op, nil)
+		close(done)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	op := h.Begin(1, false, nil)
+	h.End(op, a.Read())
+	op = h.Begin(2, false, nil)
+	h.End(op, b.Read())
+	<-done
+	return h.Operations()
+}
+
+func Test_RegularReader_inversion(t *testing.T) {
+	ast := assert.New(t)
+	//
+	ops := inversion(NewRegularReader)
+	ast.Equal("new", ops[1].Value)
+	ast.Nil(ops[2].Value)
+	ast.False(Linearizable(ops, nil))
+}
+
+func Test_Reader_noInversion(t *testing.T) {
+	ast := assert.New(t)
+	//
+	ops := inversion(NewReader)
+	ast.Equal("new", ops[1].Value)
+	ast.Equal("new", ops[2].Value)
+	ast.True(Linearizable(ops, nil))
+}
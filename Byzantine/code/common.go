@@ -0,0 +1,81 @@
+package byzantine
+
+// Order 是 commander 下达的命令
+type Order int
+
+// 枚举了所有的命令
+const (
+	// Retreat 也是无法决定时的默认命令
+	Retreat Order = iota
+	Attack
+)
+
+func (o Order) String() string {
+	if o == Attack {
+		return "进攻"
+	}
+	return "撤退"
+}
+
+// Traitor 决定了叛徒发给 to 的命令，v 是忠诚的 general 在这里会发送的命令
+type Traitor func(to int, v Order) Order
+
+// Alternate 是最常见的叛徒：给 ID 为奇数的 general 发送进攻，给偶数的发送撤退
+func Alternate(to int, v Order) Order {
+	if to%2 == 1 {
+		return Attack
+	}
+	return Retreat
+}
+
+// Tamper 是 fault injector，在消息从 from 发往 to 的途中修改命令
+// 返回 ok 为 false 时，不修改
+type Tamper func(from, to int, v Order) (res Order, ok bool)
+
+// Config 描述了一次 Byzantine agreement
+// general 0 是 commander，1 到 N-1 是 lieutenant
+type Config struct {
+	N int // general 的数量
+	M int // 算法可以容忍的叛徒的数量
+	// Order 是 commander 下达的命令，commander 是叛徒时，由 Traitors[0] 决定每个 lieutenant 收到的命令
+	Order    Order
+	Traitors map[int]Traitor
+	// Tamper 不为 nil 时，会在传输途中修改消息
+	Tamper Tamper
+}
+
+func (c Config) loyal(id int) bool {
+	_, ok := c.Traitors[id]
+	return !ok
+}
+
+// Result 是一次 Byzantine agreement 的结果
+type Result struct {
+	// Decisions 是每个忠诚的 lieutenant 最终执行的命令
+	Decisions map[int]Order
+	Messages  int // 发送的消息数量
+	Tampered  int // 在传输途中被修改的消息数量
+	Rejected  int // 因为签名错误而被丢弃的消息数量
+}
+
+// Agreed 返回 true，如果所有忠诚的 lieutenant 执行了同样的命令，即 IC1
+func (r Result) Agreed() bool {
+	first, ok := Order(0), false
+	for _, d := range r.Decisions {
+		if ok && d != first {
+			return false
+		}
+		first, ok = d, true
+	}
+	return true
+}
+
+// Obeyed 返回 true，如果所有忠诚的 lieutenant 都执行了 order，即 commander 忠诚时的 IC2
+func (r Result) Obeyed(order Order) bool {
+	for _, d := range r.Decisions {
+		if d != order {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,97 @@
+package byzantine
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"math/rand"
+)
+
+// Scheme 是签名的算法
+type Scheme int
+
+// 枚举了支持的签名算法
+const (
+	// HMAC 使用每个 process 自己的密钥计算 HMAC-SHA256
+	// 只有知道密钥的人才能验证，所以需要一个可信的 Registry 代为验证
+	HMAC Scheme = iota
+	// Ed25519 是公钥签名，任何人都可以用公钥验证，但是只有私钥的主人才能签名
+	Ed25519
+)
+
+func (s Scheme) String() string {
+	if s == Ed25519 {
+		return "Ed25519"
+	}
+	return "HMAC"
+}
+
+// Registry 保存了每个 process 的密钥
+// process 只能通过 Signer 拿到自己的签名能力，所以叛徒无法冒充别人
+type Registry struct {
+	scheme  Scheme
+	secrets [][]byte             // HMAC 的密钥
+	private []ed25519.PrivateKey // Ed25519 的私钥
+	public  []ed25519.PublicKey  // Ed25519 的公钥
+}
+
+// NewRegistry 用 seed 为 n 个 process 生成 scheme 的密钥
+func NewRegistry(n int, scheme Scheme, seed int64) *Registry {
+	rnd := rand.New(rand.NewSource(seed))
+	r := &Registry{scheme: scheme}
+	for i := 0; i < n; i++ {
+		key := make([]byte, 32)
+		rnd.Read(key)
+		switch scheme {
+		case HMAC:
+			r.secrets = append(r.secrets, key)
+		case Ed25519:
+			priv := ed25519.NewKeyFromSeed(key)
+			r.private = append(r.private, priv)
+			r.public = append(r.public, priv.Public().(ed25519.PublicKey))
+		}
+	}
+	return r
+}
+
+// Scheme 返回 r 使用的签名算法
+func (r *Registry) Scheme() Scheme {
+	return r.scheme
+}
+
+// Signer 用 process id 的密钥签名
+type Signer func(msg []byte) []byte
+
+// Signer 返回 process id 的 Signer
+func (r *Registry) Signer(id int) Signer {
+	if r.scheme == Ed25519 {
+		priv := r.private[id]
+		return func(msg []byte) []byte {
+			return ed25519.Sign(priv, msg)
+		}
+	}
+	secret := r.secrets[id]
+	return func(msg []byte) []byte {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(msg)
+		return mac.Sum(nil)
+	}
+}
+
+// Verify 返回 true，如果 sig 是 process id 对 msg 的签名
+func (r *Registry) Verify(id int, msg, sig []byte) bool {
+	if id < 0 || id >= r.size() {
+		return false
+	}
+	if r.scheme == Ed25519 {
+		return ed25519.Verify(r.public[id], msg, sig)
+	}
+	return hmac.Equal(r.Signer(id)(msg), sig)
+}
+
+func (r *Registry) size() int {
+	if r.scheme == Ed25519 {
+		return len(r.public)
+	}
+	return len(r.secrets)
+}
@@ -0,0 +1,30 @@
+package byzantine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Registry_signAndVerify(t *testing.T) {
+	ast := assert.New(t)
+	//
+	for _, scheme := range []Scheme{HMAC, Ed25519} {
+		r := NewRegistry(3, scheme, 1)
+		ast.Equal(scheme, r.Scheme())
+		msg := []byte("attack at dawn")
+		sig := r.Signer(1)(msg)
+		ast.True(r.Verify(1, msg, sig), "%s", scheme)
+		// 内容被修改，或者冒充别人的签名，都无法通过验证
+		ast.False(r.Verify(1, []byte("retreat at dawn"), sig), "%s", scheme)
+		ast.False(r.Verify(2, msg, sig), "%s", scheme)
+		ast.False(r.Verify(2, msg, r.Signer(1)(msg)), "%s", scheme)
+		ast.False(r.Verify(3, msg, sig), "%s", scheme)
+		ast.False(r.Verify(-1, msg, sig), "%s", scheme)
+		// 同样的 seed 生成同样的密钥
+		ast.True(NewRegistry(3, scheme, 1).Verify(1, msg, sig), "%s", scheme)
+		ast.False(NewRegistry(3, scheme, 2).Verify(1, msg, sig), "%s", scheme)
+	}
+	ast.Equal("HMAC", HMAC.String())
+	ast.Equal("Ed25519", Ed25519.String())
+}
@@ -0,0 +1,88 @@
+package byzantine
+
+// Oral 运行 Lamport 的 oral messages 算法 OM(M)
+//
+// OM(0)：commander 把命令发给每个 lieutenant，lieutenant 执行收到的命令。
+// OM(m)：commander 把命令发给每个 lieutenant；然后每个 lieutenant i 作为 commander，
+// 用 OM(m-1) 把收到的命令转告其他的 lieutenant；最后每个 lieutenant 对
+// 自己直接收到的命令和 OM(m-1) 中收到的命令取多数。
+//
+// oral message 的接收方只知道谁发送了消息，无法知道消息最初的内容，
+// 所以被修改的消息与说谎的发送方无法区分。N > 3M 时才能保证 IC1 和 IC2。
+func Oral(c Config) Result {
+	o := &oral{config: c, res: Result{Decisions: make(map[int]Order, c.N)}}
+	lieutenants := make([]int, 0, c.N-1)
+	for i := 1; i < c.N; i++ {
+		lieutenants = append(lieutenants, i)
+	}
+	for id, v := range o.run(c.M, 0, c.Order, lieutenants) {
+		if c.loyal(id) {
+			o.res.Decisions[id] = v
+		}
+	}
+	return o.res
+}
+
+type oral struct {
+	config Config
+	res    Result
+}
+
+// run 执行 OM(m)，返回每个 lieutenant 决定的命令
+func (o *oral) run(m, commander int, v Order, lieutenants []int) map[int]Order {
+	received := make(map[int]Order, len(lieutenants))
+	for _, l := range lieutenants {
+		received[l] = o.send(commander, l, v)
+	}
+	if m == 0 {
+		return received
+	}
+
+	values := make(map[int][]Order, len(lieutenants))
+	for _, l := range lieutenants {
+		values[l] = append(values[l], received[l])
+	}
+	for i, j := range lieutenants {
+		others := make([]int, 0, len(lieutenants)-1)
+		others = append(others, lieutenants[:i]...)
+		others = append(others, lieutenants[i+1:]...)
+		for l, u := range o.run(m-1, j, received[j], others) {
+			values[l] = append(values[l], u)
+		}
+	}
+
+	res := make(map[int]Order, len(lieutenants))
+	for _, l := range lieutenants {
+		res[l] = majority(values[l])
+	}
+	return res
+}
+
+// send 返回 to 收到的命令
+func (o *oral) send(from, to int, v Order) Order {
+	o.res.Messages++
+	if t, ok := o.config.Traitors[from]; ok {
+		v = t(to, v)
+	}
+	if o.config.Tamper != nil {
+		if u, ok := o.config.Tamper(from, to, v); ok {
+			o.res.Tampered++
+			v = u
+		}
+	}
+	return v
+}
+
+// majority 返回 vs 中的多数，没有多数时返回 Retreat
+func majority(vs []Order) Order {
+	attack := 0
+	for _, v := range vs {
+		if v == Attack {
+			attack++
+		}
+	}
+	if 2*attack > len(vs) {
+		return Attack
+	}
+	return Retreat
+}
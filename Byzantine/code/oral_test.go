@@ -0,0 +1,122 @@
+package byzantine
+
+import (
+	"fmt"
+	"testing"
+
+	spec "github.com/aQuaYi/Distributed-Algorithms/Spec/code"
+	"github.com/stretchr/testify/assert"
+)
+
+// always 是总是发送 v 的叛徒
+func always(v Order) Traitor {
+	return func(int, Order) Order { return v }
+}
+
+// flip 修改从 from 发往 to 的消息
+func flip(from, to int) Tamper {
+	return func(f, t int, v Order) (Order, bool) {
+		if f != from || t != to {
+			return v, false
+		}
+		return 1 - v, true
+	}
+}
+
+// quickConfig 从 choices 中取出一次 Byzantine agreement：general 的数量在 [minN, maxN] 中，
+// M 不超过 maxM(N)，至多 M 个叛徒，叛徒发给每个 general 的命令也从 choices 中取出
+func quickConfig(choices *spec.Choices, minN, maxN int, maxM func(n int) int) Config {
+	n := choices.Range(minN, maxN)
+	c := Config{N: n, M: choices.Range(0, maxM(n)), Order: Order(choices.Intn(2)), Traitors: map[int]Traitor{}}
+	for i := 0; i < c.M; i++ {
+		c.Traitors[choices.Intn(n)] = func(int, Order) Order { return Order(choices.Intn(2)) }
+	}
+	return c
+}
+
+// checkAgreement 检查 res 是否满足 IC1 和 IC2
+func checkAgreement(c Config, res Result) error {
+	if !res.Agreed() {
+		return &spec.Violation{Property: "IC1", Reason: fmt.Sprintf("忠诚的 lieutenant 执行了不同的命令 %v", res.Decisions)}
+	}
+	if c.loyal(0) && !res.Obeyed(c.Order) {
+		return &spec.Violation{Property: "IC2", Reason: fmt.Sprintf("commander 下达了%s，lieutenant 执行了 %v", c.Order, res.Decisions)}
+	}
+	return nil
+}
+
+func Test_Oral_quick(t *testing.T) {
+	ast := assert.New(t)
+	//
+	res := spec.Quick(spec.QuickConfig{Seed: 1, Runs: 300}, func(choices *spec.Choices) error {
+		c := quickConfig(choices, 4, 7, func(n int) int { return (n - 1) / 3 })
+		return checkAgreement(c, Oral(c))
+	})
+	ast.True(res.OK(), res.String())
+}
+
+func Test_Oral_quick_tooManyTraitors(t *testing.T) {
+	ast := assert.New(t)
+	//
+	res := spec.Quick(spec.QuickConfig{Seed: 1}, func(choices *spec.Choices) error {
+		c := quickConfig(choices, 3, 7, func(n int) int { return n - 2 })
+		return checkAgreement(c, Oral(c))
+	})
+	ast.False(res.OK())
+	// 反例中叛徒的数量一定不少于 N/3
+	c := quickConfig(spec.Replay(res.Choices), 3, 7, func(n int) int { return n - 2 })
+	ast.True(c.N <= 3*len(c.Traitors), res.String())
+	ast.True(len(res.Choices) < res.Original)
+}
+
+func Test_Oral_fourGeneralsOneTraitor(t *testing.T) {
+	ast := assert.New(t)
+	//
+	for traitor := 0; traitor < 4; traitor++ {
+		for _, order := range []Order{Attack, Retreat} {
+			res := Oral(Config{N: 4, M: 1, Order: order, Traitors: map[int]Traitor{traitor: Alternate}})
+			ast.True(res.Agreed(), "叛徒是 %d", traitor)
+			if traitor != 0 {
+				ast.True(res.Obeyed(order), "叛徒是 %d", traitor)
+			}
+			ast.Equal(3+3*2, res.Messages)
+		}
+	}
+}
+
+func Test_Oral_sevenGeneralsTwoTraitors(t *testing.T) {
+	ast := assert.New(t)
+	//
+	for a := 0; a < 7; a++ {
+		for b := a + 1; b < 7; b++ {
+			traitors := map[int]Traitor{a: Alternate, b: always(Retreat)}
+			res := Oral(Config{N: 7, M: 2, Order: Attack, Traitors: traitors})
+			ast.True(res.Agreed(), "叛徒是 %d 和 %d", a, b)
+			if a != 0 {
+				ast.True(res.Obeyed(Attack), "叛徒是 %d 和 %d", a, b)
+			}
+		}
+	}
+}
+
+func Test_Oral_threeGeneralsOneTraitor(t *testing.T) {
+	ast := assert.New(t)
+	//
+	// N <= 3M 时，lieutenant 1 无法区分说谎的 lieutenant 2 和说谎的 commander
+	res := Oral(Config{N: 3, M: 1, Order: Attack, Traitors: map[int]Traitor{2: always(Retreat)}})
+	ast.False(res.Obeyed(Attack))
+}
+
+func Test_Oral_tamperingLooksLikeATraitor(t *testing.T) {
+	ast := assert.New(t)
+	//
+	// 被修改的消息与说谎的 lieutenant 1 无法区分，加上真正的叛徒 3，超出了 OM(1) 的能力
+	res := Oral(Config{
+		N: 4, M: 1, Order: Attack,
+		Traitors: map[int]Traitor{3: always(Retreat)},
+		Tamper:   flip(1, 2),
+	})
+	ast.Equal(1, res.Tampered)
+	ast.False(res.Agreed())
+	ast.Equal(0, res.Rejected, "oral message 无法发现篡改")
+}
@@ -0,0 +1,167 @@
+package byzantine
+
+import "encoding/binary"
+
+// Signature 是一个 general 的签名
+type Signature struct {
+	Signer int
+	Sig    []byte
+}
+
+// SignedOrder 是带有签名链的命令
+// Chain[0] 是 commander 的签名，之后的每个签名都签署了命令和之前所有的签名
+type SignedOrder struct {
+	Order Order
+	Chain []Signature
+}
+
+// digest 返回在 chain 之后签名时，需要签署的内容
+func digest(v Order, chain []Signature) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	res := append([]byte(nil), buf[:binary.PutUvarint(buf[:], uint64(v))]...)
+	for _, s := range chain {
+		res = append(res, buf[:binary.PutUvarint(buf[:], uint64(s.Signer))]...)
+		res = append(res, s.Sig...)
+	}
+	return res
+}
+
+// envelope 是发往 to 的消息
+type envelope struct {
+	to  int
+	msg SignedOrder
+}
+
+// Signed 运行 Lamport 的 signed messages 算法 SM(M)，r 保存了所有 general 的密钥
+//
+// commander 签名以后把命令发给每个 lieutenant。lieutenant i 收到签名正确的命令 v 时：
+// 如果 v 不在已知命令的集合 V_i 中，就把 v 加入 V_i，并且在签名少于 M+1 个时，
+// 签上自己的名字，转发给还没有签过名的 lieutenant。
+// 没有新的消息以后，V_i 中只有一个命令时执行它，否则撤退。
+//
+// 叛徒无法伪造别人的签名，所以只要忠诚的 general 能够互相通信，任意数量的叛徒都可以容忍。
+// 在传输途中被修改的消息无法通过验证，会被丢弃并记录在 Result.Rejected 中。
+func Signed(c Config, r *Registry) Result {
+	s := &signed{
+		config:  c,
+		reg:     r,
+		signers: make([]Signer, c.N),
+		known:   make(map[int]map[Order]bool, c.N),
+		res:     Result{Decisions: make(map[int]Order, c.N)},
+	}
+	for i := range s.signers {
+		s.signers[i] = r.Signer(i)
+		s.known[i] = make(map[Order]bool, 2)
+	}
+
+	for l := 1; l < c.N; l++ {
+		v := c.Order
+		if t, ok := c.Traitors[0]; ok {
+			// 叛徒 commander 可以给不同的 lieutenant 签署不同的命令
+			v = t(l, v)
+		}
+		s.send(0, l, SignedOrder{Order: v}.sign(0, s.signers[0]))
+	}
+
+	for len(s.queue) > 0 {
+		e := s.queue[0]
+		s.queue = s.queue[1:]
+		s.receive(e)
+	}
+
+	for l := 1; l < c.N; l++ {
+		if !c.loyal(l) {
+			continue
+		}
+		s.res.Decisions[l] = choice(s.known[l])
+	}
+	return s.res
+}
+
+type signed struct {
+	config  Config
+	reg     *Registry
+	signers []Signer
+	known   map[int]map[Order]bool // 每个 lieutenant 的 V_i
+	queue   []envelope
+	res     Result
+}
+
+// sign 返回签上 id 的名字以后的 o
+func (o SignedOrder) sign(id int, signer Signer) SignedOrder {
+	chain := make([]Signature, len(o.Chain), len(o.Chain)+1)
+	copy(chain, o.Chain)
+	sig := signer(digest(o.Order, o.Chain))
+	return SignedOrder{Order: o.Order, Chain: append(chain, Signature{Signer: id, Sig: sig})}
+}
+
+func (s *signed) send(from, to int, msg SignedOrder) {
+	s.res.Messages++
+	if s.config.Tamper != nil {
+		if v, ok := s.config.Tamper(from, to, msg.Order); ok {
+			s.res.Tampered++
+			msg.Order = v
+		}
+	}
+	s.queue = append(s.queue, envelope{to: to, msg: msg})
+}
+
+func (s *signed) receive(e envelope) {
+	if !s.verify(e.to, e.msg) {
+		s.res.Rejected++
+		return
+	}
+	v := e.msg.Order
+	if s.known[e.to][v] {
+		return
+	}
+	s.known[e.to][v] = true
+	// Chain 中除了 commander 以外的签名数量，不少于 M 时不再转发
+	if len(e.msg.Chain)-1 >= s.config.M {
+		return
+	}
+	signedBy := make(map[int]bool, len(e.msg.Chain))
+	for _, sig := range e.msg.Chain {
+		signedBy[sig.Signer] = true
+	}
+	for l := 1; l < s.config.N; l++ {
+		if l == e.to || signedBy[l] {
+			continue
+		}
+		msg := e.msg
+		if t, ok := s.config.Traitors[e.to]; ok {
+			// 叛徒可以修改命令，但是无法伪造之前的签名
+			msg.Order = t(l, msg.Order)
+		}
+		s.send(e.to, l, msg.sign(e.to, s.signers[e.to]))
+	}
+}
+
+// verify 返回 true，如果 msg 的签名链以 commander 开始，没有重复的签名者，
+// 不包含接收者 to，并且每个签名都是正确的
+func (s *signed) verify(to int, msg SignedOrder) bool {
+	if len(msg.Chain) == 0 || msg.Chain[0].Signer != 0 {
+		return false
+	}
+	seen := make(map[int]bool, len(msg.Chain))
+	for i, sig := range msg.Chain {
+		if seen[sig.Signer] || sig.Signer == to {
+			return false
+		}
+		seen[sig.Signer] = true
+		if !s.reg.Verify(sig.Signer, digest(msg.Order, msg.Chain[:i]), sig.Sig) {
+			return false
+		}
+	}
+	return true
+}
+
+// choice 在只有一个命令时返回它，否则返回 Retreat
+func choice(vs map[Order]bool) Order {
+	if len(vs) == 1 {
+		for v := range vs {
+			return v
+		}
+	}
+	return Retreat
+}
@@ -0,0 +1,93 @@
+package byzantine
+
+import (
+	"testing"
+
+	spec "github.com/aQuaYi/Distributed-Algorithms/Spec/code"
+	"github.com/stretchr/testify/assert"
+)
+
+var schemes = []Scheme{HMAC, Ed25519}
+
+func Test_Signed_threeGeneralsOneTraitor(t *testing.T) {
+	ast := assert.New(t)
+	//
+	for _, scheme := range schemes {
+		r := NewRegistry(3, scheme, 1)
+		// Oral 在这里失败了，但是叛徒无法伪造 commander 的签名
+		res := Signed(Config{N: 3, M: 1, Order: Attack, Traitors: map[int]Traitor{2: always(Retreat)}}, r)
+		ast.True(res.Obeyed(Attack), "%s", scheme)
+		ast.Equal(1, res.Rejected, "%s", scheme)
+		// 叛徒 commander 给两个 lieutenant 签署了不同的命令，它们转发以后都会发现
+		res = Signed(Config{N: 3, M: 1, Order: Attack, Traitors: map[int]Traitor{0: Alternate}}, r)
+		ast.True(res.Agreed(), "%s", scheme)
+		ast.True(res.Obeyed(Retreat), "%s", scheme)
+		ast.Equal(0, res.Rejected, "%s", scheme)
+	}
+}
+
+func Test_Signed_manyTraitors(t *testing.T) {
+	ast := assert.New(t)
+	//
+	r := NewRegistry(6, Ed25519, 1)
+	for _, commander := range []Traitor{nil, Alternate} {
+		traitors := map[int]Traitor{2: Alternate, 3: always(Retreat), 5: Alternate}
+		if commander != nil {
+			traitors[0] = commander
+		}
+		// 6 个 general 中有 3 或 4 个叛徒，远远超过了 Oral 的 N > 3M
+		res := Signed(Config{N: 6, M: len(traitors), Order: Attack, Traitors: traitors}, r)
+		ast.True(res.Agreed())
+		if commander == nil {
+			ast.True(res.Obeyed(Attack))
+		}
+	}
+}
+
+func Test_Signed_tamperingIsDetected(t *testing.T) {
+	ast := assert.New(t)
+	//
+	for _, scheme := range schemes {
+		r := NewRegistry(4, scheme, 1)
+		// 与 Test_Oral_tamperingLooksLikeATraitor 相同的场景
+		res := Signed(Config{
+			N: 4, M: 1, Order: Attack,
+			Traitors: map[int]Traitor{3: always(Retreat)},
+			Tamper:   flip(1, 2),
+		}, r)
+		ast.Equal(1, res.Tampered, "%s", scheme)
+		ast.True(res.Rejected >= 1, "%s", scheme)
+		ast.True(res.Obeyed(Attack), "%s", scheme)
+		// commander 发给 lieutenant 1 的命令被修改了，lieutenant 1 从其他 lieutenant 的转发中得到了命令
+		res = Signed(Config{N: 4, M: 1, Order: Attack, Tamper: flip(0, 1)}, r)
+		ast.Equal(1, res.Rejected, "%s", scheme)
+		ast.True(res.Obeyed(Attack), "%s", scheme)
+	}
+}
+
+func Test_Signed_rejectsMalformedChains(t *testing.T) {
+	ast := assert.New(t)
+	//
+	r := NewRegistry(3, HMAC, 1)
+	s := &signed{config: Config{N: 3, M: 1}, reg: r}
+	good := SignedOrder{Order: Attack}.sign(0, r.Signer(0))
+	ast.True(s.verify(1, good))
+	ast.True(s.verify(2, good.sign(1, r.Signer(1))))
+	ast.False(s.verify(1, SignedOrder{Order: Attack}), "没有签名")
+	ast.False(s.verify(1, SignedOrder{Order: Attack}.sign(2, r.Signer(2))), "不是从 commander 开始")
+	ast.False(s.verify(1, good.sign(1, r.Signer(1))), "包含了接收者")
+	ast.False(s.verify(2, good.sign(1, r.Signer(2))), "冒充 lieutenant 1")
+	ast.False(s.verify(2, good.sign(0, r.Signer(0))), "重复的签名者")
+}
+
+func Test_Signed_quick(t *testing.T) {
+	ast := assert.New(t)
+	//
+	r := NewRegistry(7, HMAC, 1)
+	// 只要还有 2 个忠诚的 general，任意数量的叛徒都可以容忍
+	res := spec.Quick(spec.QuickConfig{Seed: 1, Runs: 300}, func(choices *spec.Choices) error {
+		c := quickConfig(choices, 3, 7, func(n int) int { return n - 2 })
+		return checkAgreement(c, Signed(c, r))
+	})
+	ast.True(res.OK(), res.String())
+}
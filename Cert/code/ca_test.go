@@ -0,0 +1,95 @@
+package cert
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// echo 在 config 上启动 TLS echo server，每个连接先回复对方证书中的节点名
+func echo(t *testing.T, config *tls.Config) string {
+	l, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn *tls.Conn) {
+				defer conn.Close()
+				if conn.Handshake() != nil {
+					return
+				}
+				io.WriteString(conn, Peer(conn)+"\n")
+			}(conn.(*tls.Conn))
+		}
+	}()
+	return l.Addr().String()
+}
+
+func Test_CA_mutualTLS(t *testing.T) {
+	ast := assert.New(t)
+	//
+	ca, err := NewCA("test-ca")
+	ast.Nil(err)
+	server, err := ca.Config("server", "127.0.0.1")
+	ast.Nil(err)
+	client, err := ca.Config("client", "127.0.0.1")
+	ast.Nil(err)
+	addr := echo(t, server)
+	//
+	conn, err := tls.Dial("tcp", addr, client)
+	ast.Nil(err)
+	defer conn.Close()
+	ast.Equal("server", Peer(conn))
+	buf := make([]byte, 7)
+	_, err = io.ReadFull(conn, buf)
+	ast.Nil(err)
+	ast.Equal("client\n", string(buf))
+}
+
+func Test_CA_rejectsUntrustedPeers(t *testing.T) {
+	ast := assert.New(t)
+	//
+	ca, _ := NewCA("test-ca")
+	other, _ := NewCA("other-ca")
+	server, _ := ca.Config("server", "127.0.0.1")
+	addr := echo(t, server)
+	// 其他 CA 签发的证书
+	stranger, _ := other.Config("stranger", "127.0.0.1")
+	stranger.RootCAs = ca.Pool()
+	ast.NotNil(handshake(addr, stranger))
+	// 没有证书
+	ast.NotNil(handshake(addr, &tls.Config{RootCAs: ca.Pool()}))
+	// server 的证书中没有对方连接的地址
+	wrongHost, _ := ca.Config("server", "10.0.0.1")
+	addr = echo(t, wrongHost)
+	client, _ := ca.Config("client")
+	ast.NotNil(handshake(addr, client))
+	// 不使用 TLS
+	conn, err := net.Dial("tcp", addr)
+	ast.Nil(err)
+	conn.Write([]byte("hello\n"))
+	_, err = conn.Read(make([]byte, 1))
+	ast.NotNil(err)
+	conn.Close()
+}
+
+// handshake 返回握手以及读取第一个字节时的错误
+// TLS 1.3 中 server 拒绝 client 的证书时，client 在读取时才会发现
+func handshake(addr string, config *tls.Config) error {
+	conn, err := tls.Dial("tcp", addr, config)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Read(make([]byte, 1))
+	return err
+}
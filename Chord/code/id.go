@@ -0,0 +1,49 @@
+package chord
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+)
+
+// m 是 identifier 的位数，identifier 的取值范围是 [0, 2^m)
+// 使用 uint64 的自然溢出来完成 mod 2^m 的运算
+const m = 64
+
+// hash 把 key 映射到 identifier 环上
+func hash(key string) uint64 {
+	sum := sha1.Sum([]byte(key))
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+// between 判断 x 是否在环上的开区间 (a, b) 中
+// 当 a == b 时，(a, b) 表示除了 a 以外的整个环
+func between(x, a, b uint64) bool {
+	if a < b {
+		return a < x && x < b
+	}
+	return a < x || x < b
+}
+
+// betweenRightIncl 判断 x 是否在环上的左开右闭区间 (a, b] 中
+func betweenRightIncl(x, a, b uint64) bool {
+	return between(x, a, b) || x == b
+}
+
+// NodeRef 是对 Chord 环上某个 node 的引用
+type NodeRef struct {
+	ID   uint64
+	Addr string
+}
+
+// IsNil 返回 true，如果 r 没有指向任何 node
+func (r NodeRef) IsNil() bool {
+	return r.Addr == ""
+}
+
+func (r NodeRef) String() string {
+	if r.IsNil() {
+		return "<nil>"
+	}
+	return fmt.Sprintf("<%016x:%s>", r.ID, r.Addr)
+}
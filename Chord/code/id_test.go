@@ -0,0 +1,42 @@
+package chord
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_between(t *testing.T) {
+	ast := assert.New(t)
+	//
+	ast.True(between(5, 1, 10))
+	ast.False(between(1, 1, 10))
+	ast.False(between(10, 1, 10))
+	// 跨过了 0 点
+	ast.True(between(1, 10, 5))
+	ast.True(between(11, 10, 5))
+	ast.False(between(7, 10, 5))
+	// a == b 表示除了 a 以外的整个环
+	ast.True(between(3, 7, 7))
+	ast.False(between(7, 7, 7))
+}
+
+func Test_betweenRightIncl(t *testing.T) {
+	ast := assert.New(t)
+	//
+	ast.True(betweenRightIncl(10, 1, 10))
+	ast.False(betweenRightIncl(1, 1, 10))
+	ast.True(betweenRightIncl(7, 7, 7))
+}
+
+func Test_hash_isStable(t *testing.T) {
+	ast := assert.New(t)
+	ast.Equal(hash("chord"), hash("chord"))
+	ast.NotEqual(hash("chord"), hash("kademlia"))
+}
+
+func Test_NodeRef_String(t *testing.T) {
+	ast := assert.New(t)
+	ast.Equal("<nil>", NodeRef{}.String())
+	ast.Equal("<00000000000000ff:a>", NodeRef{ID: 255, Addr: "a"}.String())
+}
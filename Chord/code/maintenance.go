@@ -0,0 +1,141 @@
+package chord
+
+import (
+	"time"
+
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+)
+
+// Stabilize 检查 successor 的 predecessor 是否应该成为 n 的 successor，
+// 更新 successor list，并通知 successor，n 可能是它的 predecessor
+func (n *Node) Stabilize() {
+	for _, succ := range n.Successors() {
+		var x NodeRef
+		if err := n.tp.Call(succ.Addr, "GetPredecessor", &Empty{}, &x); err != nil {
+			// succ 失效了，尝试 successor list 中的下一个
+			continue
+		}
+
+		if !x.IsNil() && between(x.ID, n.ref.ID, succ.ID) {
+			var ignore Empty
+			if n.tp.Call(x.Addr, "Ping", &Empty{}, &ignore) == nil {
+				succ = x
+			}
+		}
+
+		var list []NodeRef
+		if err := n.tp.Call(succ.Addr, "GetSuccessors", &Empty{}, &list); err != nil {
+			continue
+		}
+
+		n.setSuccessors(succ, list)
+		n.tp.Call(succ.Addr, "Notify", &n.ref, &Empty{})
+		return
+	}
+
+	// successor list 中的 node 全都失效了，只能退回到只有自己的状态
+	n.setSuccessors(n.ref, nil)
+}
+
+func (n *Node) setSuccessors(succ NodeRef, list []NodeRef) {
+	res := make([]NodeRef, 0, successorListSize)
+	res = append(res, succ)
+	for _, r := range list {
+		if len(res) == successorListSize {
+			break
+		}
+		if r.Addr == n.ref.Addr {
+			// 绕了一圈回到了自己
+			break
+		}
+		res = append(res, r)
+	}
+
+	n.mutex.Lock()
+	n.successors = res
+	n.finger[0] = succ
+	n.mutex.Unlock()
+}
+
+// FixFingers 修复下一个 finger
+func (n *Node) FixFingers() {
+	n.mutex.Lock()
+	n.next = (n.next + 1) % m
+	i := n.next
+	n.mutex.Unlock()
+
+	n.fixFinger(i)
+}
+
+// FixAllFingers 一次性修复所有的 finger
+func (n *Node) FixAllFingers() {
+	for i := 0; i < m; i++ {
+		n.fixFinger(i)
+	}
+}
+
+func (n *Node) fixFinger(i int) {
+	start := n.ref.ID + 1<<uint(i)
+	succ, _ := n.findSuccessor(start)
+
+	n.mutex.Lock()
+	n.finger[i] = succ
+	n.mutex.Unlock()
+}
+
+// CheckPredecessor 清除已经失效的 predecessor
+func (n *Node) CheckPredecessor() {
+	pred := n.Predecessor()
+	if pred.IsNil() {
+		return
+	}
+	if err := n.tp.Call(pred.Addr, "Ping", &Empty{}, &Empty{}); err != nil {
+		n.mutex.Lock()
+		if n.predecessor == pred {
+			n.predecessor = NodeRef{}
+		}
+		n.mutex.Unlock()
+	}
+}
+
+// Start 启动后台的维护循环，每隔 interval 运行一次 Stabilize、FixFingers 和 CheckPredecessor
+func (n *Node) Start(interval time.Duration) {
+	n.StartWithEnv(interval, sim.Env{})
+}
+
+// StartWithEnv 与 Start 一样，但是按照 env 的时间运行维护循环
+func (n *Node) StartWithEnv(interval time.Duration, env sim.Env) {
+	n.mutex.Lock()
+	if n.stop != nil {
+		n.mutex.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	n.stop = stop
+	n.mutex.Unlock()
+
+	go func() {
+		ticker := env.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C():
+				n.Stabilize()
+				n.FixFingers()
+				n.CheckPredecessor()
+			}
+		}
+	}()
+}
+
+// Stop 停止后台的维护循环
+func (n *Node) Stop() {
+	n.mutex.Lock()
+	if n.stop != nil {
+		close(n.stop)
+		n.stop = nil
+	}
+	n.mutex.Unlock()
+}
@@ -0,0 +1,216 @@
+package chord
+
+import (
+	"fmt"
+	"sync"
+
+	transport "github.com/aQuaYi/Distributed-Algorithms/Transport/code"
+)
+
+// successorListSize 是 successor list 的长度
+// 只要 list 中的 node 不同时失效，环就不会断开
+const successorListSize = 4
+
+// Node 是 Chord 环上的一个 node
+type Node struct {
+	ref NodeRef
+	tp  transport.Transport
+
+	mutex       sync.Mutex
+	predecessor NodeRef
+	successors  []NodeRef  // successors[0] 就是 successor，也就是 finger[0]
+	finger      [m]NodeRef // finger[i] 是 successor(ref.ID + 2^i)
+	next        int        // fixFingers 下一次要修复的 finger
+	data        map[string]string
+
+	stop chan struct{}
+}
+
+// NewNode 在 tp 上创建一个地址为 addr 的 node
+// node 的 ID 是其真实地址的 hash
+// 创建好的 node 需要通过 Create 或者 Join 加入环
+func NewNode(addr string, tp transport.Transport) (*Node, error) {
+	n := &Node{
+		tp:   tp,
+		data: make(map[string]string, 64),
+	}
+	actual, err := tp.Register(addr, &Service{n: n})
+	if err != nil {
+		return nil, err
+	}
+	n.ref = NodeRef{ID: hash(actual), Addr: actual}
+	n.successors = []NodeRef{n.ref}
+	return n, nil
+}
+
+func (n *Node) String() string {
+	return fmt.Sprintf("Node%s", n.ref)
+}
+
+// Ref 返回 n 的引用
+func (n *Node) Ref() NodeRef {
+	return n.ref
+}
+
+// Create 创建一个只有 n 的新环
+func (n *Node) Create() {
+	n.mutex.Lock()
+	n.predecessor = NodeRef{}
+	n.successors = []NodeRef{n.ref}
+	n.mutex.Unlock()
+}
+
+// Join 通过 addr 上的 node 加入已有的环
+func (n *Node) Join(addr string) error {
+	var reply FindSuccessorReply
+	err := n.tp.Call(addr, "FindSuccessor", &FindSuccessorArgs{ID: n.ref.ID}, &reply)
+	if err != nil {
+		return err
+	}
+	n.mutex.Lock()
+	n.predecessor = NodeRef{}
+	n.successors = []NodeRef{reply.Node}
+	n.mutex.Unlock()
+	return nil
+}
+
+// Leave 让 n 离开网络，相当于 n 失效了
+func (n *Node) Leave() {
+	n.Stop()
+	n.tp.Unregister(n.ref.Addr)
+}
+
+// Predecessor 返回 n 的 predecessor
+func (n *Node) Predecessor() NodeRef {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return n.predecessor
+}
+
+// Successor 返回 n 的 successor
+func (n *Node) Successor() NodeRef {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return n.successors[0]
+}
+
+// Successors 返回 n 的 successor list 的副本
+func (n *Node) Successors() []NodeRef {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	res := make([]NodeRef, len(n.successors))
+	copy(res, n.successors)
+	return res
+}
+
+// Lookup 返回负责 key 的 node，以及查找经过的跳数
+func (n *Node) Lookup(key string) (NodeRef, int) {
+	return n.findSuccessor(hash(key))
+}
+
+// Put 把 key-value 保存到负责 key 的 node 上
+func (n *Node) Put(key, value string) error {
+	owner, _ := n.Lookup(key)
+	return n.tp.Call(owner.Addr, "Store", &StoreArgs{Key: key, Value: value}, &Empty{})
+}
+
+// Get 从负责 key 的 node 上读取 value
+func (n *Node) Get(key string) (string, bool, error) {
+	owner, _ := n.Lookup(key)
+	var reply FetchReply
+	err := n.tp.Call(owner.Addr, "Fetch", &key, &reply)
+	return reply.Value, reply.OK, err
+}
+
+// findSuccessor 查找 id 的 successor
+// 不能在持有锁的时候调用 RPC，因为 RPC 有可能调用到 n 自己
+func (n *Node) findSuccessor(id uint64) (NodeRef, int) {
+	n.mutex.Lock()
+	succ := n.successors[0]
+	if betweenRightIncl(id, n.ref.ID, succ.ID) {
+		n.mutex.Unlock()
+		return succ, 0
+	}
+	candidates := n.closestPrecedingNodes(id)
+	n.mutex.Unlock()
+
+	// 从最接近 id 的 node 开始尝试，失效的 node 会被跳过
+	for _, c := range candidates {
+		var reply FindSuccessorReply
+		err := n.tp.Call(c.Addr, "FindSuccessor", &FindSuccessorArgs{ID: id}, &reply)
+		if err == nil {
+			return reply.Node, reply.Hops + 1
+		}
+	}
+
+	return succ, 0
+}
+
+// closestPrecedingNodes 按照与 id 的距离，由近到远地返回 finger 和 successor list 中位于 (n, id) 的 node
+// 利用调用方的锁进行锁定
+func (n *Node) closestPrecedingNodes(id uint64) []NodeRef {
+	res := make([]NodeRef, 0, 8)
+	seen := make(map[string]bool, 8)
+	add := func(r NodeRef) {
+		if r.IsNil() || seen[r.Addr] || !between(r.ID, n.ref.ID, id) {
+			return
+		}
+		seen[r.Addr] = true
+		res = append(res, r)
+	}
+	for i := m - 1; i >= 0; i-- {
+		add(n.finger[i])
+	}
+	for i := len(n.successors) - 1; i >= 0; i-- {
+		add(n.successors[i])
+	}
+	// 按照到 id 的距离排序，距离越近越靠前
+	for i := 1; i < len(res); i++ {
+		for j := i; j > 0 && id-res[j].ID < id-res[j-1].ID; j-- {
+			res[j], res[j-1] = res[j-1], res[j]
+		}
+	}
+	return res
+}
+
+func (n *Node) notify(p NodeRef) {
+	n.mutex.Lock()
+	if !n.predecessor.IsNil() && !between(p.ID, n.predecessor.ID, n.ref.ID) {
+		n.mutex.Unlock()
+		return
+	}
+	n.predecessor = p
+	// 不再由 n 负责的 key，交给新的 predecessor
+	moved := make(map[string]string, 16)
+	for k, v := range n.data {
+		if !betweenRightIncl(hash(k), p.ID, n.ref.ID) {
+			moved[k] = v
+			delete(n.data, k)
+		}
+	}
+	n.mutex.Unlock()
+
+	for k, v := range moved {
+		n.tp.Call(p.Addr, "Store", &StoreArgs{Key: k, Value: v}, &Empty{})
+	}
+}
+
+func (n *Node) store(key, value string) {
+	n.mutex.Lock()
+	n.data[key] = value
+	n.mutex.Unlock()
+}
+
+func (n *Node) fetch(key string) (string, bool) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	v, ok := n.data[key]
+	return v, ok
+}
+
+// Keys 返回保存在 n 上的 key 的数量
+func (n *Node) Keys() int {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return len(n.data)
+}
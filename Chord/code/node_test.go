@@ -0,0 +1,171 @@
+package chord
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"testing"
+
+	transport "github.com/aQuaYi/Distributed-Algorithms/Transport/code"
+	"github.com/stretchr/testify/assert"
+)
+
+// makeRing 在 sn 上创建由 size 个 node 组成的环，并让环稳定下来
+func makeRing(sn *transport.SimNetwork, size int) []*Node {
+	nodes := make([]*Node, 0, size)
+	for i := 0; i < size; i++ {
+		n, err := NewNode(fmt.Sprintf("node-%d", i), sn)
+		if err != nil {
+			panic(err)
+		}
+		if i == 0 {
+			n.Create()
+		} else if err := n.Join(nodes[0].ref.Addr); err != nil {
+			panic(err)
+		}
+		nodes = append(nodes, n)
+		stabilizeRounds(nodes, 2)
+	}
+	stabilizeRounds(nodes, 3)
+	for _, n := range nodes {
+		n.FixAllFingers()
+	}
+	return nodes
+}
+
+func stabilizeRounds(nodes []*Node, rounds int) {
+	for r := 0; r < rounds; r++ {
+		for _, n := range nodes {
+			n.Stabilize()
+			n.CheckPredecessor()
+		}
+	}
+}
+
+// expectedSuccessor 通过遍历所有 node，求出 id 真正的 successor
+func expectedSuccessor(nodes []*Node, id uint64) NodeRef {
+	refs := make([]NodeRef, len(nodes))
+	for i, n := range nodes {
+		refs[i] = n.ref
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i].ID < refs[j].ID })
+	for _, r := range refs {
+		if r.ID >= id {
+			return r
+		}
+	}
+	return refs[0]
+}
+
+func Test_Node_singleNodeRing(t *testing.T) {
+	ast := assert.New(t)
+	//
+	sn := transport.NewSimNetwork()
+	n, _ := NewNode("solo", sn)
+	n.Create()
+	n.Stabilize()
+	//
+	owner, hops := n.Lookup("any key")
+	ast.Equal(n.ref, owner)
+	ast.Equal(0, hops)
+}
+
+func Test_Node_lookupIsCorrect(t *testing.T) {
+	ast := assert.New(t)
+	//
+	sn := transport.NewSimNetwork()
+	nodes := makeRing(sn, 32)
+	//
+	for i, n := range nodes {
+		ast.Equal(expectedSuccessor(nodes, n.ref.ID+1), n.Successor(), "%s 的 successor 不正确", n)
+		ast.False(nodes[i].Predecessor().IsNil())
+	}
+	//
+	for i := 0; i < 200; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		from := nodes[i%len(nodes)]
+		owner, _ := from.Lookup(key)
+		ast.Equal(expectedSuccessor(nodes, hash(key)), owner, key)
+	}
+}
+
+func Test_Node_lookupHopsIsLogarithmic(t *testing.T) {
+	ast := assert.New(t)
+	//
+	size := 64
+	sn := transport.NewSimNetwork()
+	nodes := makeRing(sn, size)
+	//
+	total, maxHops := 0, 0
+	lookups := 500
+	for i := 0; i < lookups; i++ {
+		_, hops := nodes[i%size].Lookup(fmt.Sprintf("key-%d", i))
+		total += hops
+		if hops > maxHops {
+			maxHops = hops
+		}
+	}
+	//
+	logN := math.Log2(float64(size))
+	mean := float64(total) / float64(lookups)
+	ast.True(mean <= logN, "平均跳数 %.2f 超过了 log2(N) = %.2f", mean, logN)
+	ast.True(float64(maxHops) <= 2*logN, "最大跳数 %d 超过了 2*log2(N)", maxHops)
+}
+
+func Test_Node_survivesFailures(t *testing.T) {
+	ast := assert.New(t)
+	//
+	sn := transport.NewSimNetwork()
+	nodes := makeRing(sn, 24)
+	// 让少于 successorListSize 个相邻的 node 失效
+	live := make([]*Node, 0, len(nodes))
+	for i, n := range nodes {
+		if i%4 == 1 {
+			n.Leave()
+			continue
+		}
+		live = append(live, n)
+	}
+	stabilizeRounds(live, 4)
+	for _, n := range live {
+		n.FixAllFingers()
+	}
+	//
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		owner, _ := live[i%len(live)].Lookup(key)
+		ast.Equal(expectedSuccessor(live, hash(key)), owner, key)
+	}
+}
+
+func Test_Node_PutAndGet(t *testing.T) {
+	ast := assert.New(t)
+	//
+	sn := transport.NewSimNetwork()
+	nodes := makeRing(sn, 8)
+	//
+	size := 100
+	for i := 0; i < size; i++ {
+		ast.Nil(nodes[i%8].Put(fmt.Sprintf("k%d", i), fmt.Sprint(i)))
+	}
+	// 新加入的 node 会从 successor 那里接手自己负责的 key
+	late, _ := NewNode("late", sn)
+	ast.Nil(late.Join(nodes[0].ref.Addr))
+	nodes = append(nodes, late)
+	stabilizeRounds(nodes, 3)
+	for _, n := range nodes {
+		n.FixAllFingers()
+	}
+	//
+	total := 0
+	for _, n := range nodes {
+		total += n.Keys()
+	}
+	ast.Equal(size, total)
+	for i := 0; i < size; i++ {
+		v, ok, err := nodes[(i+3)%len(nodes)].Get(fmt.Sprintf("k%d", i))
+		ast.Nil(err)
+		ast.True(ok)
+		ast.Equal(fmt.Sprint(i), v)
+	}
+}
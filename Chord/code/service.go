@@ -0,0 +1,74 @@
+package chord
+
+// Empty 是不需要参数或者返回值的 RPC 的占位符
+type Empty struct{}
+
+// FindSuccessorArgs 是 FindSuccessor 的参数
+type FindSuccessorArgs struct {
+	ID uint64
+}
+
+// FindSuccessorReply 是 FindSuccessor 的返回值
+type FindSuccessorReply struct {
+	Node NodeRef
+	Hops int // 查找经过的 node 数量，不包括发起查找的 node
+}
+
+// StoreArgs 是 Store 的参数
+type StoreArgs struct {
+	Key, Value string
+}
+
+// FetchReply 是 Fetch 的返回值
+type FetchReply struct {
+	Value string
+	OK    bool
+}
+
+// Service 把 Node 的方法以 RPC 的形式暴露出去
+// 方法的签名满足 net/rpc 的要求
+type Service struct {
+	n *Node
+}
+
+// FindSuccessor 查找 args.ID 的 successor
+func (s *Service) FindSuccessor(args *FindSuccessorArgs, reply *FindSuccessorReply) error {
+	node, hops := s.n.findSuccessor(args.ID)
+	reply.Node, reply.Hops = node, hops
+	return nil
+}
+
+// GetPredecessor 返回 node 的 predecessor
+func (s *Service) GetPredecessor(args *Empty, reply *NodeRef) error {
+	*reply = s.n.Predecessor()
+	return nil
+}
+
+// GetSuccessors 返回 node 的 successor list
+func (s *Service) GetSuccessors(args *Empty, reply *[]NodeRef) error {
+	*reply = s.n.Successors()
+	return nil
+}
+
+// Notify 告诉 node，args 可能是它的 predecessor
+func (s *Service) Notify(args *NodeRef, reply *Empty) error {
+	s.n.notify(*args)
+	return nil
+}
+
+// Ping 用于检查 node 是否存活
+func (s *Service) Ping(args *Empty, reply *Empty) error {
+	return nil
+}
+
+// Store 把 key-value 保存在 node 上
+func (s *Service) Store(args *StoreArgs, reply *Empty) error {
+	s.n.store(args.Key, args.Value)
+	return nil
+}
+
+// Fetch 读取保存在 node 上的 key
+func (s *Service) Fetch(args *string, reply *FetchReply) error {
+	reply.Value, reply.OK = s.n.fetch(*args)
+	return nil
+}
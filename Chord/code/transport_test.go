@@ -0,0 +1,116 @@
+package chord
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	cert "github.com/aQuaYi/Distributed-Algorithms/Cert/code"
+	codec "github.com/aQuaYi/Distributed-Algorithms/Codec/code"
+	transport "github.com/aQuaYi/Distributed-Algorithms/Transport/code"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SimNetwork_duplicateAddress(t *testing.T) {
+	ast := assert.New(t)
+	sn := transport.NewSimNetwork()
+	_, err := NewNode("a", sn)
+	ast.Nil(err)
+	_, err = NewNode("a", sn)
+	ast.NotNil(err)
+}
+
+func Test_TCPTransport_ring(t *testing.T) {
+	tp := transport.NewTCPTransport("Chord")
+	defer tp.Close()
+	testRing(t, func(int) transport.Transport { return tp })
+}
+
+func Test_TCPTransport_compressedRing(t *testing.T) {
+	ast := assert.New(t)
+	//
+	var stats codec.Stats
+	tp := transport.NewTCPTransportWithOptions("Chord", transport.TCPOptions{
+		Codec: &codec.Policy{Default: codec.Snappy, MinSize: 64, Stats: &stats},
+	})
+	defer tp.Close()
+	testRing(t, func(int) transport.Transport { return tp })
+	st := stats.Snapshot()
+	ast.True(st.Messages > 0)
+	ast.True(st.Wire <= st.Raw, "%v", st)
+}
+
+func Test_TCPTransport_mutualTLS(t *testing.T) {
+	ast := assert.New(t)
+	//
+	ca, err := cert.NewCA("chord-ca")
+	ast.Nil(err)
+	// 每个 node 都有自己的证书
+	tcp := func(i int) transport.Transport {
+		config, err := ca.Config(fmt.Sprintf("node-%d", i), "127.0.0.1")
+		ast.Nil(err)
+		tp := transport.NewTCPTransportWithOptions("Chord", transport.TCPOptions{TLS: config})
+		t.Cleanup(tp.Close)
+		return tp
+	}
+	nodes := testRing(t, tcp)
+	// 其他 CA 签发的证书无法加入环
+	other, _ := cert.NewCA("other-ca")
+	config, _ := other.Config("intruder", "127.0.0.1")
+	config.RootCAs = ca.Pool()
+	tp := transport.NewTCPTransportWithOptions("Chord", transport.TCPOptions{TLS: config})
+	defer tp.Close()
+	intruder, err := NewNode("127.0.0.1:0", tp)
+	ast.Nil(err)
+	ast.NotNil(intruder.Join(nodes[0].ref.Addr))
+	// 没有使用 TLS 的 node 也不行
+	plain := transport.NewTCPTransport("Chord")
+	defer plain.Close()
+	intruder, err = NewNode("127.0.0.1:0", plain)
+	ast.Nil(err)
+	ast.NotNil(intruder.Join(nodes[0].ref.Addr))
+}
+
+// testRing 建立 5 个 node 的环，检查 Lookup、Put 和 Get，node i 使用 tp(i)
+func testRing(t *testing.T, tp func(i int) transport.Transport) []*Node {
+	ast := assert.New(t)
+	//
+	size := 5
+	nodes := make([]*Node, 0, size)
+	for i := 0; i < size; i++ {
+		n, err := NewNode("127.0.0.1:0", tp(i))
+		ast.Nil(err)
+		if i == 0 {
+			n.Create()
+		} else {
+			ast.Nil(n.Join(nodes[0].ref.Addr))
+		}
+		n.Start(5 * time.Millisecond)
+		defer n.Stop()
+		nodes = append(nodes, n)
+	}
+	// 等待环稳定下来
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		ok := true
+		for _, n := range nodes {
+			ok = ok && n.Successor() == expectedSuccessor(nodes, n.ref.ID+1)
+		}
+		if ok {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	//
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		ast.Nil(nodes[i%size].Put(key, key))
+		owner, _ := nodes[(i+1)%size].Lookup(key)
+		ast.Equal(expectedSuccessor(nodes, hash(key)), owner)
+		v, ok, err := nodes[(i+2)%size].Get(key)
+		ast.Nil(err)
+		ast.True(ok)
+		ast.Equal(key, v)
+	}
+	return nodes
+}
@@ -0,0 +1,70 @@
+package clocksync
+
+import (
+	"sort"
+	"time"
+)
+
+// Berkeley 是 Berkeley 算法
+// master 轮询所有 slave 的时钟，用 round-trip time 的一半修正网络延迟，估计出每个时钟与自己的偏差。
+// 然后取偏差的容错平均值，把每个时钟需要调整的量发给它们，master 也会调整自己。
+// 与 Cristian 算法不同，没有哪个时钟被当作标准，大家向平均值靠拢。
+type Berkeley struct {
+	// Master 是 master 的编号
+	Master int
+	// Tolerance 是容错的范围，偏差与中位数相差超过 Tolerance 的时钟，不参与平均
+	// 为 0 时，所有的时钟都参与平均
+	Tolerance time.Duration
+}
+
+// Sync 进行一轮 Berkeley 同步
+func (b Berkeley) Sync(s *Simulation) {
+	master := s.clocks[b.Master]
+	start := s.now
+	m0 := master.Read(start)
+	end := start
+	offsets := make([]time.Duration, len(s.clocks))
+	for i, slave := range s.clocks {
+		if i == b.Master {
+			continue
+		}
+		t := start + s.delay()
+		slaveTime := slave.Read(t)
+		t += s.delay()
+		m1 := master.Read(t)
+		// slave 在 master 读数为 m1 时的估计读数，与 m1 的差就是偏差
+		offsets[i] = slaveTime + (m1-m0)/2 - m1
+		end = maxDuration(end, t)
+	}
+
+	avg := b.average(offsets)
+
+	// 调整量是相对值，所以送达时的延迟不影响结果
+	maxDelay := time.Duration(0)
+	for i, c := range s.clocks {
+		c.Adjust(avg - offsets[i])
+		if i != b.Master {
+			maxDelay = maxDuration(maxDelay, s.delay())
+		}
+	}
+	s.now = end + maxDelay
+}
+
+// average 返回 offsets 的容错平均值
+func (b Berkeley) average(offsets []time.Duration) time.Duration {
+	sorted := make([]time.Duration, len(offsets))
+	copy(sorted, offsets)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	median := sorted[len(sorted)/2]
+
+	var sum time.Duration
+	count := 0
+	for _, o := range sorted {
+		if b.Tolerance > 0 && abs(o-median) > b.Tolerance {
+			continue
+		}
+		sum += o
+		count++
+	}
+	return sum / time.Duration(count)
+}
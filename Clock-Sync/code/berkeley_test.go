@@ -0,0 +1,44 @@
+package clocksync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Berkeley(t *testing.T) {
+	ast := assert.New(t)
+	//
+	s := NewSimulation(16, testConfig, 1)
+	r := Run(s, Berkeley{Master: 0}, 20, time.Minute)
+	bound := testConfig.MaxDelay - testConfig.MinDelay + 12*time.Millisecond
+	ast.True(r.MaxResidual() < bound, r.String())
+}
+
+func Test_Berkeley_averages(t *testing.T) {
+	ast := assert.New(t)
+	//
+	s := NewSimulation(3, Config{MinDelay: time.Millisecond, MaxDelay: time.Millisecond}, 0)
+	s.clocks[0] = NewClock(0, 0)
+	s.clocks[1] = NewClock(30*time.Millisecond, 0)
+	s.clocks[2] = NewClock(-60*time.Millisecond, 0)
+	Berkeley{Master: 0}.Sync(s)
+	// 所有的时钟都向平均值 -10ms 靠拢，而不是向 master 靠拢
+	ast.Equal(time.Duration(0), s.Skew())
+	ast.Equal(10*time.Millisecond, s.MaxError())
+}
+
+func Test_Berkeley_tolerance(t *testing.T) {
+	ast := assert.New(t)
+	//
+	s := NewSimulation(4, Config{MinDelay: time.Millisecond, MaxDelay: time.Millisecond}, 0)
+	s.clocks[0] = NewClock(0, 0)
+	s.clocks[1] = NewClock(2*time.Millisecond, 0)
+	s.clocks[2] = NewClock(4*time.Millisecond, 0)
+	s.clocks[3] = NewClock(time.Hour, 0)
+	Berkeley{Master: 0, Tolerance: time.Second}.Sync(s)
+	// 出了故障的时钟不参与平均，但也会被调整到平均值
+	ast.Equal(time.Duration(0), s.Skew())
+	ast.Equal(2*time.Millisecond, s.MaxError())
+}
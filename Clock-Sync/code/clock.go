@@ -0,0 +1,34 @@
+package clocksync
+
+import "time"
+
+// Clock 是会漂移的物理时钟
+// 在真实时间 t，时钟的读数为 offset + t*(1+drift)
+// 所有的时间都用 time.Duration 表示，是从模拟开始时计算的时长
+type Clock struct {
+	offset time.Duration
+	drift  float64 // 漂移率，1e-4 表示每秒会快 100us
+}
+
+// NewClock 返回初始偏差为 offset，漂移率为 drift 的时钟
+func NewClock(offset time.Duration, drift float64) *Clock {
+	return &Clock{
+		offset: offset,
+		drift:  drift,
+	}
+}
+
+// Read 返回真实时间为 t 时，时钟的读数
+func (c *Clock) Read(t time.Duration) time.Duration {
+	return c.offset + t + time.Duration(float64(t)*c.drift)
+}
+
+// Adjust 把时钟的读数调整 delta
+func (c *Clock) Adjust(delta time.Duration) {
+	c.offset += delta
+}
+
+// Set 调整时钟，使得真实时间为 t 时，读数为 value
+func (c *Clock) Set(t, value time.Duration) {
+	c.Adjust(value - c.Read(t))
+}
@@ -0,0 +1,24 @@
+package clocksync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Clock(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := NewClock(time.Millisecond, 1e-3)
+	ast.Equal(time.Millisecond, c.Read(0))
+	// 每秒快 1ms
+	ast.Equal(time.Second+2*time.Millisecond, c.Read(time.Second))
+	//
+	c.Adjust(-2 * time.Millisecond)
+	ast.Equal(time.Second, c.Read(time.Second))
+	//
+	c.Set(time.Second, 5*time.Second)
+	ast.Equal(5*time.Second, c.Read(time.Second))
+	ast.Equal(5*time.Second+1001*time.Millisecond, c.Read(2*time.Second))
+}
@@ -0,0 +1,63 @@
+package clocksync
+
+import "time"
+
+// Cristian 是 Cristian 算法
+// 每个 client 向时间 server 询问时间，用 server 的读数加上 round-trip time 的一半，作为自己的新读数。
+// 如果往返的延迟是对称的，结果就是准确的；误差最多是 round-trip time 的一半减去最小单程延迟。
+type Cristian struct {
+	// Server 是时间 server 的编号
+	Server int
+	// Samples 是每个 client 每轮询问的次数，只采用 round-trip time 最小的那次
+	// 为 0 时，只询问一次
+	Samples int
+}
+
+// exchange 是一次 Cristian 询问的结果
+type exchange struct {
+	serverTime time.Duration // server 回复的读数
+	rtt        time.Duration // client 时钟测量的 round-trip time
+	end        time.Duration // 收到回复时的真实时间
+}
+
+// Sync 让所有的 client 同时向 server 询问时间
+func (c Cristian) Sync(s *Simulation) {
+	samples := c.Samples
+	if samples < 1 {
+		samples = 1
+	}
+	server := s.clocks[c.Server]
+	start, end := s.now, s.now
+	for i, client := range s.clocks {
+		if i == c.Server {
+			continue
+		}
+		t := start
+		var best exchange
+		for k := 0; k < samples; k++ {
+			e := c.ask(s, client, server, t)
+			if k == 0 || e.rtt < best.rtt {
+				best = e
+			}
+			t = e.end
+		}
+		// 采用以前的样本时，要把之后流逝的时间算上
+		elapsed := client.Read(t) - client.Read(best.end)
+		client.Set(t, best.serverTime+best.rtt/2+elapsed)
+		end = maxDuration(end, t)
+	}
+	s.now = end
+}
+
+// ask 在真实时间 t 由 client 向 server 询问一次时间
+func (c Cristian) ask(s *Simulation, client, server *Clock, t time.Duration) exchange {
+	t0 := client.Read(t)
+	t += s.delay()
+	serverTime := server.Read(t)
+	t += s.delay()
+	return exchange{
+		serverTime: serverTime,
+		rtt:        client.Read(t) - t0,
+		end:        t,
+	}
+}
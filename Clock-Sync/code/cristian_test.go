@@ -0,0 +1,40 @@
+package clocksync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Cristian(t *testing.T) {
+	ast := assert.New(t)
+	//
+	s := NewSimulation(16, testConfig, 1)
+	ast.True(s.Skew() > 50*time.Millisecond)
+	//
+	r := Run(s, Cristian{Server: 0}, 20, time.Minute)
+	// 每个 client 的误差最多是 (MaxDelay-MinDelay)/2，再加上一分钟的漂移
+	bound := testConfig.MaxDelay - testConfig.MinDelay + 12*time.Millisecond
+	ast.True(r.MaxResidual() < bound, r.String())
+}
+
+func Test_Cristian_samples(t *testing.T) {
+	ast := assert.New(t)
+	//
+	one := Run(NewSimulation(16, testConfig, 2), Cristian{Server: 0}, 50, time.Minute)
+	many := Run(NewSimulation(16, testConfig, 2), Cristian{Server: 0, Samples: 8}, 50, time.Minute)
+	// 只采用 round-trip time 最小的样本，可以降低延迟不对称带来的误差
+	ast.True(many.MeanResidual() < one.MeanResidual(), "%s\n%s", one, many)
+}
+
+func Test_Cristian_followsServer(t *testing.T) {
+	ast := assert.New(t)
+	//
+	s := NewSimulation(4, Config{MinDelay: time.Millisecond, MaxDelay: time.Millisecond}, 3)
+	s.clocks[0] = NewClock(time.Hour, 0)
+	Cristian{Server: 0}.Sync(s)
+	// 延迟对称时，Cristian 算法是准确的，所有的 client 都与 server 一致
+	ast.Equal(time.Duration(0), s.Skew())
+	ast.Equal(time.Hour, s.MaxError())
+}
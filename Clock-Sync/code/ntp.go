@@ -0,0 +1,122 @@
+package clocksync
+
+import (
+	"fmt"
+	"time"
+)
+
+// Sample 是一次 NTP 交换得到的估计
+type Sample struct {
+	// Offset 是 server 时钟减去 client 时钟的估计值
+	Offset time.Duration
+	// Delay 是去掉 server 处理时间以后的 round-trip delay
+	Delay time.Duration
+}
+
+// NewSample 根据一次 NTP 交换的四个时间戳计算 Sample
+// t1: client 发送请求时 client 的读数
+// t2: server 收到请求时 server 的读数
+// t3: server 发送回复时 server 的读数
+// t4: client 收到回复时 client 的读数
+func NewSample(t1, t2, t3, t4 time.Duration) Sample {
+	return Sample{
+		Offset: ((t2 - t1) + (t3 - t4)) / 2,
+		Delay:  (t4 - t1) - (t3 - t2),
+	}
+}
+
+func (s Sample) String() string {
+	return fmt.Sprintf("{offset:%s delay:%s}", s.Offset, s.Delay)
+}
+
+// NTP 是简化的 NTP 同步
+// 与 Cristian 算法相比，server 会分别记录收到请求和发送回复的时间，所以 server 的处理时间不会带来误差。
+// 每个 client 保存最近 Window 个 Sample，只采用其中 Delay 最小的那个，这就是 NTP 的 clock filter。
+// Delay 越小，往返延迟的不对称程度就越小，Offset 越准确。
+type NTP struct {
+	server   int
+	window   int
+	maxDelay time.Duration
+	filters  map[int][]Sample // 每个 client 最近的 Sample
+	rejected int
+}
+
+// NewNTP 返回以 server 为时间 server 的 NTP 同步
+// 每个 client 保存最近 window 个 Sample，Delay 超过 maxDelay 的 Sample 会被当作离群值直接丢弃，
+// maxDelay 为 0 时不丢弃任何 Sample
+func NewNTP(server, window int, maxDelay time.Duration) *NTP {
+	if window < 1 {
+		window = 1
+	}
+	return &NTP{
+		server:   server,
+		window:   window,
+		maxDelay: maxDelay,
+		filters:  make(map[int][]Sample),
+	}
+}
+
+// Sync 让所有的 client 同时与 server 进行一次交换，然后根据 clock filter 调整时钟
+func (n *NTP) Sync(s *Simulation) {
+	server := s.clocks[n.server]
+	end := s.now
+	for i, client := range s.clocks {
+		if i == n.server {
+			continue
+		}
+		t := s.now
+		t1 := client.Read(t)
+		t += s.delay()
+		t2 := server.Read(t)
+		// server 处理请求也需要时间
+		t += s.delay()
+		t3 := server.Read(t)
+		t += s.delay()
+		t4 := client.Read(t)
+		end = maxDuration(end, t)
+
+		sample := NewSample(t1, t2, t3, t4)
+		if n.maxDelay > 0 && sample.Delay > n.maxDelay {
+			n.rejected++
+			continue
+		}
+		n.add(i, sample)
+
+		best, _ := n.Estimate(i)
+		client.Adjust(best.Offset)
+		// 调整以后，保存的 Sample 都是相对于调整前的时钟，需要一起修正
+		for k := range n.filters[i] {
+			n.filters[i][k].Offset -= best.Offset
+		}
+	}
+	s.now = end
+}
+
+func (n *NTP) add(client int, sample Sample) {
+	f := append(n.filters[client], sample)
+	if len(f) > n.window {
+		f = f[len(f)-n.window:]
+	}
+	n.filters[client] = f
+}
+
+// Estimate 返回 client 的 clock filter 中 Delay 最小的 Sample
+// client 还没有任何 Sample 时，返回 false
+func (n *NTP) Estimate(client int) (Sample, bool) {
+	f := n.filters[client]
+	if len(f) == 0 {
+		return Sample{}, false
+	}
+	best := f[0]
+	for _, sample := range f[1:] {
+		if sample.Delay < best.Delay {
+			best = sample
+		}
+	}
+	return best, true
+}
+
+// Rejected 返回由于 Delay 过大而被丢弃的 Sample 数量
+func (n *NTP) Rejected() int {
+	return n.rejected
+}
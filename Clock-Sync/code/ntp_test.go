@@ -0,0 +1,73 @@
+package clocksync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_NewSample(t *testing.T) {
+	ast := assert.New(t)
+	//
+	ms := time.Millisecond
+	// server 快 10ms，去程 2ms，server 处理 5ms，回程 4ms
+	s := NewSample(100*ms, 112*ms, 117*ms, 111*ms)
+	ast.Equal(Sample{Offset: 9 * ms, Delay: 6 * ms}, s)
+	ast.Equal("{offset:9ms delay:6ms}", s.String())
+}
+
+func Test_NTP_serverProcessing(t *testing.T) {
+	ast := assert.New(t)
+	//
+	s := NewSimulation(4, Config{MinDelay: time.Millisecond, MaxDelay: time.Millisecond}, 0)
+	s.clocks[0] = NewClock(time.Hour, 0)
+	ntp := NewNTP(0, 1, 0)
+	ntp.Sync(s)
+	// 延迟对称时，server 的处理时间不会带来误差
+	ast.Equal(time.Duration(0), s.Skew())
+	best, ok := ntp.Estimate(1)
+	ast.True(ok)
+	ast.Equal(2*time.Millisecond, best.Delay)
+	_, ok = ntp.Estimate(0)
+	ast.False(ok)
+}
+
+var spikyConfig = Config{
+	MaxOffset:  100 * time.Millisecond,
+	MaxDrift:   1e-5,
+	MinDelay:   time.Millisecond,
+	MaxDelay:   2 * time.Millisecond,
+	SpikeRate:  0.3,
+	SpikeDelay: 50 * time.Millisecond,
+}
+
+func Test_NTP_clockFilter(t *testing.T) {
+	ast := assert.New(t)
+	//
+	single := Run(NewSimulation(16, spikyConfig, 1), NewNTP(0, 1, 0), 50, time.Minute)
+	filtered := Run(NewSimulation(16, spikyConfig, 1), NewNTP(0, 8, 0), 50, time.Minute)
+	// 延迟的尖峰让单个 Sample 的误差很大，clock filter 可以把它们过滤掉
+	ast.True(single.MeanResidual() > 20*time.Millisecond, single.String())
+	ast.True(filtered.MeanResidual() < 10*time.Millisecond, filtered.String())
+}
+
+func Test_NTP_maxDelay(t *testing.T) {
+	ast := assert.New(t)
+	//
+	ntp := NewNTP(0, 1, 10*time.Millisecond)
+	r := Run(NewSimulation(16, spikyConfig, 1), ntp, 50, time.Minute)
+	ast.True(ntp.Rejected() > 0)
+	ast.True(r.MeanResidual() < 10*time.Millisecond, r.String())
+}
+
+func Test_Simulation_Source(t *testing.T) {
+	ast := assert.New(t)
+	//
+	s := NewSimulation(2, Config{}, 0)
+	s.clocks[1] = NewClock(time.Second, 0)
+	now := s.Source(1)
+	ast.Equal(time.Second, now())
+	s.Advance(time.Second)
+	ast.Equal(2*time.Second, now())
+}
@@ -0,0 +1,185 @@
+package clocksync
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// Config 是模拟的参数
+type Config struct {
+	// MaxOffset 是时钟初始读数与真实时间的最大偏差
+	MaxOffset time.Duration
+	// MaxDrift 是时钟的最大漂移率
+	MaxDrift float64
+	// MinDelay 和 MaxDelay 是单程网络延迟的范围
+	MinDelay, MaxDelay time.Duration
+	// SpikeRate 是单程延迟出现尖峰的概率，出现尖峰时，延迟会额外增加 SpikeDelay
+	// 尖峰模拟了网络中偶尔出现的排队，它让往返的延迟严重不对称
+	SpikeRate  float64
+	SpikeDelay time.Duration
+}
+
+// Simulation 模拟了拥有各自物理时钟的一组 process
+// 真实时间只有 Simulation 知道，process 只能读取自己的时钟，
+// 并通过延迟随机的网络交换时钟读数
+type Simulation struct {
+	now    time.Duration // 真实时间
+	clocks []*Clock
+	config Config
+	rand   *rand.Rand
+}
+
+// NewSimulation 返回有 n 个 process 的模拟，seed 决定了时钟的初始状态和网络延迟
+func NewSimulation(n int, config Config, seed int64) *Simulation {
+	s := &Simulation{
+		clocks: make([]*Clock, n),
+		config: config,
+		rand:   rand.New(rand.NewSource(seed)),
+	}
+	for i := range s.clocks {
+		offset := time.Duration((2*s.rand.Float64() - 1) * float64(config.MaxOffset))
+		drift := (2*s.rand.Float64() - 1) * config.MaxDrift
+		s.clocks[i] = NewClock(offset, drift)
+	}
+	return s
+}
+
+// Now 返回真实时间
+func (s *Simulation) Now() time.Duration {
+	return s.now
+}
+
+// Clock 返回 process i 的时钟
+func (s *Simulation) Clock(i int) *Clock {
+	return s.clocks[i]
+}
+
+// Size 返回 process 的数量
+func (s *Simulation) Size() int {
+	return len(s.clocks)
+}
+
+// Advance 让真实时间流逝 d
+func (s *Simulation) Advance(d time.Duration) {
+	s.now += d
+}
+
+// Source 返回读取 process i 时钟的函数
+// 它可以作为 process 中其他模块的物理时钟来源，比如 hybrid logical clock
+func (s *Simulation) Source(i int) func() time.Duration {
+	return func() time.Duration {
+		return s.clocks[i].Read(s.now)
+	}
+}
+
+// delay 返回一次单程网络延迟
+func (s *Simulation) delay() time.Duration {
+	res := s.config.MinDelay
+	if spread := s.config.MaxDelay - s.config.MinDelay; spread > 0 {
+		res += time.Duration(s.rand.Int63n(int64(spread)))
+	}
+	if s.config.SpikeRate > 0 && s.rand.Float64() < s.config.SpikeRate {
+		res += s.config.SpikeDelay
+	}
+	return res
+}
+
+// Skew 返回此刻所有时钟读数中，最大值与最小值的差
+func (s *Simulation) Skew() time.Duration {
+	min, max := s.clocks[0].Read(s.now), s.clocks[0].Read(s.now)
+	for _, c := range s.clocks[1:] {
+		r := c.Read(s.now)
+		if r < min {
+			min = r
+		}
+		if r > max {
+			max = r
+		}
+	}
+	return max - min
+}
+
+// MaxError 返回此刻所有时钟读数与真实时间之差的最大绝对值
+func (s *Simulation) MaxError() time.Duration {
+	var res time.Duration
+	for _, c := range s.clocks {
+		res = maxDuration(res, abs(c.Read(s.now)-s.now))
+	}
+	return res
+}
+
+// Algorithm 是时钟同步算法
+type Algorithm interface {
+	// Sync 在 s 上进行一轮同步，同步所花费的真实时间也会在 s 上流逝
+	Sync(s *Simulation)
+}
+
+// Report 记录了每一轮同步前后的时钟偏差
+type Report struct {
+	Before []time.Duration // 每一轮同步之前的 skew
+	After  []time.Duration // 每一轮同步之后的 skew，即残余的 skew
+}
+
+// Run 每隔 interval 用 alg 同步一次 s 中的时钟，一共 rounds 轮
+func Run(s *Simulation, alg Algorithm, rounds int, interval time.Duration) *Report {
+	r := &Report{
+		Before: make([]time.Duration, 0, rounds),
+		After:  make([]time.Duration, 0, rounds),
+	}
+	for i := 0; i < rounds; i++ {
+		s.Advance(interval)
+		r.Before = append(r.Before, s.Skew())
+		alg.Sync(s)
+		r.After = append(r.After, s.Skew())
+	}
+	return r
+}
+
+// MaxResidual 返回同步后 skew 的最大值
+func (r *Report) MaxResidual() time.Duration {
+	var res time.Duration
+	for _, d := range r.After {
+		res = maxDuration(res, d)
+	}
+	return res
+}
+
+// MeanResidual 返回同步后 skew 的平均值
+func (r *Report) MeanResidual() time.Duration {
+	if len(r.After) == 0 {
+		return 0
+	}
+	var sum time.Duration
+	for _, d := range r.After {
+		sum += d
+	}
+	return sum / time.Duration(len(r.After))
+}
+
+func (r *Report) String() string {
+	var b strings.Builder
+	var maxBefore time.Duration
+	for _, d := range r.Before {
+		maxBefore = maxDuration(maxBefore, d)
+	}
+	fmt.Fprintf(&b, "%d 轮同步，", len(r.After))
+	fmt.Fprintf(&b, "同步前 skew 最大 %s，", maxBefore)
+	fmt.Fprintf(&b, "残余 skew 最大 %s，平均 %s", r.MaxResidual(), r.MeanResidual())
+	return b.String()
+}
+
+func abs(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,52 @@
+package clocksync
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testConfig = Config{
+	MaxOffset: 100 * time.Millisecond,
+	MaxDrift:  1e-4,
+	MinDelay:  time.Millisecond,
+	MaxDelay:  10 * time.Millisecond,
+}
+
+func Test_Simulation_skewAndError(t *testing.T) {
+	ast := assert.New(t)
+	//
+	s := NewSimulation(2, Config{}, 0)
+	s.clocks[0] = NewClock(-3*time.Millisecond, 0)
+	s.clocks[1] = NewClock(5*time.Millisecond, 0)
+	ast.Equal(8*time.Millisecond, s.Skew())
+	ast.Equal(5*time.Millisecond, s.MaxError())
+	// 只有 MinDelay 时，延迟是固定的
+	s.config.MinDelay = time.Millisecond
+	ast.Equal(time.Millisecond, s.delay())
+}
+
+func Test_Simulation_sameSeed(t *testing.T) {
+	ast := assert.New(t)
+	//
+	s1 := NewSimulation(8, testConfig, 7)
+	s2 := NewSimulation(8, testConfig, 7)
+	r1 := Run(s1, Berkeley{}, 10, time.Minute)
+	r2 := Run(s2, Berkeley{}, 10, time.Minute)
+	ast.Equal(r1, r2)
+}
+
+func Test_Report(t *testing.T) {
+	ast := assert.New(t)
+	//
+	r := &Report{
+		Before: []time.Duration{10 * time.Millisecond, 20 * time.Millisecond},
+		After:  []time.Duration{time.Millisecond, 3 * time.Millisecond},
+	}
+	ast.Equal(3*time.Millisecond, r.MaxResidual())
+	ast.Equal(2*time.Millisecond, r.MeanResidual())
+	ast.True(strings.Contains(r.String(), "20ms"))
+	ast.Equal(time.Duration(0), (&Report{}).MeanResidual())
+}
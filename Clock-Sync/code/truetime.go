@@ -0,0 +1,121 @@
+package clocksync
+
+import "time"
+
+// TrueTime 是 Spanner 中 TrueTime 风格的时钟 API
+// 它不返回一个时间点，而是返回一个区间 [earliest, latest]，保证真实时间一定在区间之中。
+// 区间的宽度 2*epsilon 就是时钟的不确定性，epsilon 必须是本地时钟误差的上界。
+type TrueTime struct {
+	now     func() time.Duration // 本地时钟
+	epsilon time.Duration
+}
+
+// NewTrueTime 返回以 now 为本地时钟，不确定性为 epsilon 的 TrueTime
+// now 的误差不能超过 epsilon，比如 Simulation.Source 返回的函数，且模拟的 MaxOffset 不超过 epsilon，MaxDrift 为 0
+func NewTrueTime(now func() time.Duration, epsilon time.Duration) *TrueTime {
+	return &TrueTime{
+		now:     now,
+		epsilon: epsilon,
+	}
+}
+
+// Now 返回包含真实时间的区间
+func (tt *TrueTime) Now() (earliest, latest time.Duration) {
+	t := tt.now()
+	return t - tt.epsilon, t + tt.epsilon
+}
+
+// After 返回 true，如果 t 一定已经过去了
+func (tt *TrueTime) After(t time.Duration) bool {
+	earliest, _ := tt.Now()
+	return earliest > t
+}
+
+// Before 返回 true，如果 t 一定还没有到来
+func (tt *TrueTime) Before(t time.Duration) bool {
+	_, latest := tt.Now()
+	return latest < t
+}
+
+// Txn 是一个被提交的事务
+type Txn struct {
+	Process   int           // 提交事务的 process
+	Timestamp time.Duration // commit 时间戳
+	Start     time.Duration // 开始提交时的真实时间
+	End       time.Duration // 提交完成，事务对外可见时的真实时间
+}
+
+// Committer 用 TrueTime 给不同 process 上的事务分配 commit 时间戳
+// 时间戳取 TT.now().latest，开启 commit wait 后，要等到 TT.after(时间戳) 成立，事务才对外可见。
+// 这保证了 external consistency：如果在真实时间中，T1 提交完成以后 T2 才开始提交，
+// 那么 T1 的时间戳一定小于 T2 的时间戳。
+type Committer struct {
+	sim        *Simulation
+	tts        []*TrueTime
+	commitWait bool
+	txns       []Txn
+}
+
+// NewCommitter 返回在 s 上提交事务的 Committer
+// 每个 process 都用自己的时钟和 epsilon 构成 TrueTime
+func NewCommitter(s *Simulation, epsilon time.Duration, commitWait bool) *Committer {
+	tts := make([]*TrueTime, s.Size())
+	for i := range tts {
+		tts[i] = NewTrueTime(s.Source(i), epsilon)
+	}
+	return &Committer{
+		sim:        s,
+		tts:        tts,
+		commitWait: commitWait,
+	}
+}
+
+// Commit 在 process 上提交一个事务
+func (c *Committer) Commit(process int) Txn {
+	tt := c.tts[process]
+	_, ts := tt.Now()
+	txn := Txn{Process: process, Timestamp: ts, Start: c.sim.Now()}
+	if c.commitWait {
+		// process 只能通过 TrueTime 判断时间，所以只能一点一点地等待
+		tick := tt.epsilon / 10
+		if tick <= 0 {
+			tick = time.Microsecond
+		}
+		for !tt.After(ts) {
+			c.sim.Advance(tick)
+		}
+	}
+	txn.End = c.sim.Now()
+	c.txns = append(c.txns, txn)
+	return txn
+}
+
+// Txns 返回所有提交过的事务
+func (c *Committer) Txns() []Txn {
+	return c.txns
+}
+
+// Violations 返回违反 external consistency 的事务对的数量
+func (c *Committer) Violations() int {
+	res := 0
+	for _, t1 := range c.txns {
+		for _, t2 := range c.txns {
+			if t1.End < t2.Start && t1.Timestamp >= t2.Timestamp {
+				res++
+			}
+		}
+	}
+	return res
+}
+
+// CommitWait 返回所有事务在 commit wait 中花费的真实时间的平均值
+func (c *Committer) CommitWait() time.Duration {
+	if len(c.txns) == 0 {
+		return 0
+	}
+	var sum time.Duration
+	for _, t := range c.txns {
+		sum += t.End - t.Start
+	}
+	return sum / time.Duration(len(c.txns))
+}
@@ -0,0 +1,58 @@
+package clocksync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_TrueTime(t *testing.T) {
+	ast := assert.New(t)
+	//
+	s := NewSimulation(1, Config{}, 0)
+	s.clocks[0] = NewClock(3*time.Millisecond, 0)
+	tt := NewTrueTime(s.Source(0), 5*time.Millisecond)
+	s.Advance(time.Second)
+	//
+	earliest, latest := tt.Now()
+	ast.True(earliest <= s.Now() && s.Now() <= latest)
+	ast.Equal(10*time.Millisecond, latest-earliest)
+	ast.True(tt.After(s.Now() - 10*time.Millisecond))
+	ast.False(tt.After(s.Now()))
+	ast.True(tt.Before(s.Now() + 10*time.Millisecond))
+	ast.False(tt.Before(s.Now()))
+}
+
+// runCommits 在时钟误差不超过 epsilon 的 process 上轮流提交事务
+// 每个事务都在上一个事务提交完成以后才开始
+func runCommits(commitWait bool) *Committer {
+	epsilon := 5 * time.Millisecond
+	s := NewSimulation(4, Config{MaxOffset: epsilon}, 1)
+	c := NewCommitter(s, epsilon, commitWait)
+	for i := 0; i < 100; i++ {
+		c.Commit(i % s.Size())
+		s.Advance(time.Millisecond)
+	}
+	return c
+}
+
+func Test_Committer_withoutCommitWait(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := runCommits(false)
+	// 时钟快的 process 给出的时间戳，会比之后时钟慢的 process 给出的还大
+	ast.True(c.Violations() > 0)
+	ast.Equal(time.Duration(0), c.CommitWait())
+}
+
+func Test_Committer_withCommitWait(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := runCommits(true)
+	ast.Equal(0, c.Violations())
+	ast.Equal(100, len(c.Txns()))
+	// commit wait 的代价是 2*epsilon 左右
+	wait := c.CommitWait()
+	ast.True(wait > 5*time.Millisecond && wait <= 11*time.Millisecond, wait)
+}
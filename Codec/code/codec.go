@@ -0,0 +1,95 @@
+package codec
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sync"
+)
+
+// ErrUnknownCodec 表示收到的消息使用了没有注册过的 codec
+var ErrUnknownCodec = errors.New("codec: unknown codec")
+
+// Codec 是消息的压缩算法
+type Codec interface {
+	// ID 写在每条消息中，接收方据此选择解压的 Codec，不同的 Codec 的 ID 必须不同
+	ID() byte
+	// Name 是 Codec 的名字
+	Name() string
+	// Encode 返回压缩后的 src
+	Encode(src []byte) []byte
+	// Decode 返回解压后的 src
+	Decode(src []byte) ([]byte, error)
+}
+
+// 内置的 Codec
+var (
+	// None 不压缩
+	None Codec = none{}
+	// Gzip 压缩率高，但是比较慢，适合很大、很少发送的消息
+	Gzip Codec = gzipCodec{}
+	// Snappy 压缩率不如 Gzip，但是快得多，适合频繁发送的消息
+	Snappy Codec = snappy{}
+)
+
+var (
+	mutex    sync.RWMutex
+	registry = map[byte]Codec{}
+)
+
+func init() {
+	Register(None)
+	Register(Gzip)
+	Register(Snappy)
+}
+
+// Register 注册 c，之后收到的使用 c 压缩的消息，都会用 c 解压
+// 通信双方都需要注册 c。ID 与已有的 Codec 重复时会 panic
+func Register(c Codec) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if old, ok := registry[c.ID()]; ok {
+		panic(fmt.Sprintf("codec: ID %d of %s is already used by %s", c.ID(), c.Name(), old.Name()))
+	}
+	registry[c.ID()] = c
+}
+
+// Lookup 返回 ID 为 id 的 Codec
+func Lookup(id byte) (Codec, bool) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	c, ok := registry[id]
+	return c, ok
+}
+
+type none struct{}
+
+func (none) ID() byte                          { return 0 }
+func (none) Name() string                      { return "none" }
+func (none) Encode(src []byte) []byte          { return src }
+func (none) Decode(src []byte) ([]byte, error) { return src, nil }
+
+type gzipCodec struct{}
+
+func (gzipCodec) ID() byte     { return 1 }
+func (gzipCodec) Name() string { return "gzip" }
+
+func (gzipCodec) Encode(src []byte) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	// 写入 bytes.Buffer 不会出错
+	w.Write(src)
+	w.Close()
+	return buf.Bytes()
+}
+
+func (gzipCodec) Decode(src []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
@@ -0,0 +1,53 @@
+package codec
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// samples 返回各种各样的输入：空的、随机的、高度重复的
+func samples() [][]byte {
+	rnd := rand.New(rand.NewSource(1))
+	random := make([]byte, 10000)
+	rnd.Read(random)
+	text := bytes.Repeat([]byte("the quick brown fox jumps over the lazy dog. "), 500)
+	zeros := make([]byte, 100000)
+	mixed := append(append([]byte(nil), random[:3000]...), text...)
+	return [][]byte{nil, []byte("a"), []byte("abcd"), random, text, zeros, mixed}
+}
+
+func Test_Codecs_roundTrip(t *testing.T) {
+	ast := assert.New(t)
+	//
+	for _, c := range []Codec{None, Gzip, Snappy} {
+		for _, src := range samples() {
+			res, err := c.Decode(c.Encode(src))
+			ast.Nil(err, c.Name())
+			ast.True(bytes.Equal(src, res), "%s %d", c.Name(), len(src))
+		}
+	}
+}
+
+func Test_Codecs_compressRepetitiveInput(t *testing.T) {
+	ast := assert.New(t)
+	//
+	text := samples()[4]
+	ast.True(len(Snappy.Encode(text)) < len(text)/10)
+	ast.True(len(Gzip.Encode(text)) < len(Snappy.Encode(text)), "gzip 的压缩率更高")
+}
+
+func Test_Register(t *testing.T) {
+	ast := assert.New(t)
+	//
+	for _, c := range []Codec{None, Gzip, Snappy} {
+		res, ok := Lookup(c.ID())
+		ast.True(ok)
+		ast.Equal(c, res)
+	}
+	_, ok := Lookup(200)
+	ast.False(ok)
+	ast.Panics(func() { Register(gzipCodec{}) })
+}
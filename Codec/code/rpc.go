@@ -0,0 +1,180 @@
+package codec
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"io"
+	"net/rpc"
+	"sync"
+	"sync/atomic"
+)
+
+// Policy 决定每种消息使用哪个 Codec
+type Policy struct {
+	// Default 是没有单独设置的消息使用的 Codec，nil 表示 None
+	Default Codec
+	// Methods 按照 RPC 的方法名，例如 "MapReduce.Fetch"，单独设置 Codec
+	// 请求和回复使用同一个 Codec
+	Methods map[string]Codec
+	// MinSize 以下的消息不值得压缩，总是使用 None
+	MinSize int
+	// Stats 不为 nil 时，记录压缩的效果
+	Stats *Stats
+}
+
+// For 返回 method 的大小为 size 的消息应该使用的 Codec，p 为 nil 时不压缩
+func (p *Policy) For(method string, size int) Codec {
+	if p == nil || size < p.MinSize {
+		return None
+	}
+	if c, ok := p.Methods[method]; ok {
+		return c
+	}
+	if p.Default != nil {
+		return p.Default
+	}
+	return None
+}
+
+// Stats 统计了发送的消息在压缩前后的大小
+type Stats struct {
+	Messages int64 // 发送的消息的数量
+	Raw      int64 // 压缩前的字节数
+	Wire     int64 // 压缩后的字节数
+}
+
+func (s *Stats) record(raw, wire int) {
+	if s == nil {
+		return
+	}
+	atomic.AddInt64(&s.Messages, 1)
+	atomic.AddInt64(&s.Raw, int64(raw))
+	atomic.AddInt64(&s.Wire, int64(wire))
+}
+
+// Snapshot 返回 s 当前的副本
+func (s *Stats) Snapshot() Stats {
+	return Stats{
+		Messages: atomic.LoadInt64(&s.Messages),
+		Raw:      atomic.LoadInt64(&s.Raw),
+		Wire:     atomic.LoadInt64(&s.Wire),
+	}
+}
+
+// envelope 是 RPC 的 header 之后，装着压缩后的 body 的消息
+type envelope struct {
+	Codec byte
+	Body  []byte
+}
+
+// conn 在 net/rpc 默认的 gob 编码上，按照 policy 压缩每个 body
+// header 很小，不压缩；body 单独用 gob 编码，再压缩，装进 envelope 中发送
+type conn struct {
+	rwc    io.ReadWriteCloser
+	policy *Policy
+	dec    *gob.Decoder
+	enc    *gob.Encoder
+	buf    *bufio.Writer
+	wmutex sync.Mutex // 保护写入，net/rpc 的 server 会并发地写入回复
+}
+
+func newConn(rwc io.ReadWriteCloser, policy *Policy) *conn {
+	buf := bufio.NewWriter(rwc)
+	return &conn{
+		rwc:    rwc,
+		policy: policy,
+		dec:    gob.NewDecoder(bufio.NewReader(rwc)),
+		enc:    gob.NewEncoder(buf),
+		buf:    buf,
+	}
+}
+
+func (c *conn) write(method string, header, body interface{}) error {
+	var raw bytes.Buffer
+	if err := gob.NewEncoder(&raw).Encode(body); err != nil {
+		return err
+	}
+	codec := c.policy.For(method, raw.Len())
+	env := envelope{Codec: codec.ID(), Body: codec.Encode(raw.Bytes())}
+	if c.policy != nil {
+		c.policy.Stats.record(raw.Len(), len(env.Body))
+	}
+
+	c.wmutex.Lock()
+	defer c.wmutex.Unlock()
+	if err := c.enc.Encode(header); err != nil {
+		return err
+	}
+	if err := c.enc.Encode(env); err != nil {
+		return err
+	}
+	return c.buf.Flush()
+}
+
+func (c *conn) readBody(body interface{}) error {
+	var env envelope
+	if err := c.dec.Decode(&env); err != nil {
+		return err
+	}
+	if body == nil {
+		return nil
+	}
+	codec, ok := Lookup(env.Codec)
+	if !ok {
+		return ErrUnknownCodec
+	}
+	raw, err := codec.Decode(env.Body)
+	if err != nil {
+		return err
+	}
+	return gob.NewDecoder(bytes.NewReader(raw)).Decode(body)
+}
+
+type clientCodec struct{ *conn }
+
+// NewClientCodec 返回按照 policy 压缩消息的 rpc.ClientCodec
+// 用法：rpc.NewClientWithCodec(codec.NewClientCodec(conn, policy))
+func NewClientCodec(rwc io.ReadWriteCloser, policy *Policy) rpc.ClientCodec {
+	return clientCodec{newConn(rwc, policy)}
+}
+
+func (c clientCodec) WriteRequest(r *rpc.Request, body interface{}) error {
+	return c.write(r.ServiceMethod, r, body)
+}
+
+func (c clientCodec) ReadResponseHeader(r *rpc.Response) error {
+	return c.dec.Decode(r)
+}
+
+func (c clientCodec) ReadResponseBody(body interface{}) error {
+	return c.readBody(body)
+}
+
+func (c clientCodec) Close() error {
+	return c.rwc.Close()
+}
+
+type serverCodec struct{ *conn }
+
+// NewServerCodec 返回按照 policy 压缩消息的 rpc.ServerCodec
+// 用法：server.ServeCodec(codec.NewServerCodec(conn, policy))
+func NewServerCodec(rwc io.ReadWriteCloser, policy *Policy) rpc.ServerCodec {
+	return serverCodec{newConn(rwc, policy)}
+}
+
+func (c serverCodec) ReadRequestHeader(r *rpc.Request) error {
+	return c.dec.Decode(r)
+}
+
+func (c serverCodec) ReadRequestBody(body interface{}) error {
+	return c.readBody(body)
+}
+
+func (c serverCodec) WriteResponse(r *rpc.Response, body interface{}) error {
+	return c.write(r.ServiceMethod, r, body)
+}
+
+func (c serverCodec) Close() error {
+	return c.rwc.Close()
+}
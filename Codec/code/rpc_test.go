@@ -0,0 +1,115 @@
+package codec
+
+import (
+	"bytes"
+	"net"
+	"net/rpc"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type Store struct{}
+
+type GetArgs struct{ Size int }
+type GetReply struct{ Data []byte }
+
+func (Store) Get(args GetArgs, reply *GetReply) error {
+	reply.Data = bytes.Repeat([]byte("snapshot "), args.Size)
+	return nil
+}
+
+func (Store) Echo(args string, reply *string) error {
+	*reply = args
+	return nil
+}
+
+// serve 启动按照 policy 压缩消息的 RPC server，返回它的地址
+func serve(t *testing.T, policy *Policy) string {
+	server := rpc.NewServer()
+	server.Register(Store{})
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go server.ServeCodec(NewServerCodec(conn, policy))
+		}
+	}()
+	return l.Addr().String()
+}
+
+func dial(t *testing.T, addr string, policy *Policy) *rpc.Client {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := rpc.NewClientWithCodec(NewClientCodec(conn, policy))
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func Test_rpc_perMethodPolicy(t *testing.T) {
+	ast := assert.New(t)
+	//
+	var stats Stats
+	policy := &Policy{
+		Methods: map[string]Codec{"Store.Get": Snappy},
+		MinSize: 64,
+		Stats:   &stats,
+	}
+	c := dial(t, serve(t, policy), nil)
+	//
+	var reply GetReply
+	ast.Nil(c.Call("Store.Get", GetArgs{Size: 10000}, &reply))
+	ast.Equal(90000, len(reply.Data))
+	st := stats.Snapshot()
+	ast.Equal(int64(1), st.Messages)
+	ast.True(st.Wire*10 < st.Raw, "%v", st)
+	// 没有单独设置的方法不压缩
+	long := strings.Repeat("x", 1000)
+	var echo string
+	ast.Nil(c.Call("Store.Echo", long, &echo))
+	ast.Equal(long, echo)
+	after := stats.Snapshot()
+	ast.Equal(int64(2), after.Messages)
+	ast.Equal(after.Raw-st.Raw, after.Wire-st.Wire)
+}
+
+func Test_rpc_mixedPolicies(t *testing.T) {
+	ast := assert.New(t)
+	//
+	// 双方的 policy 不同也可以通信，接收方按照消息中的 Codec 解压
+	addr := serve(t, &Policy{Default: Snappy})
+	for _, policy := range []*Policy{nil, {Default: Gzip}, {Default: Snappy, MinSize: 1 << 20}} {
+		c := dial(t, addr, policy)
+		var reply GetReply
+		ast.Nil(c.Call("Store.Get", GetArgs{Size: 100}, &reply))
+		ast.Equal(900, len(reply.Data))
+		var echo string
+		ast.Nil(c.Call("Store.Echo", "hi", &echo))
+		ast.Equal("hi", echo)
+		// 错误也能正常地返回
+		ast.NotNil(c.Call("Store.Missing", "hi", &echo))
+	}
+}
+
+func Benchmark_Codecs(b *testing.B) {
+	data := samples()[6]
+	for _, c := range []Codec{Gzip, Snappy} {
+		b.Run(c.Name(), func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				c.Decode(c.Encode(data))
+			}
+			b.ReportMetric(float64(len(c.Encode(data)))/float64(len(data)), "ratio")
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package codec
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// ErrCorrupt 表示 snappy 的输入不完整或者被篡改了
+var ErrCorrupt = errors.New("codec: corrupt snappy input")
+
+// snappy 实现了 snappy 的 block 格式
+// https://github.com/google/snappy/blob/main/format_description.txt
+//
+// 开头是 varint 编码的原文长度，之后是一串元素，每个元素的第一个字节的低 2 位是它的类型：
+//
+//	00 literal：直接复制接下来的若干字节
+//	01 copy：长度 4~11，offset 小于 2048，共 2 个字节
+//	10 copy：长度 1~64，offset 用 2 个字节表示
+//	11 copy：长度 1~64，offset 用 4 个字节表示，只解码，不编码
+//
+// copy 表示从已经解压的内容中，往回 offset 个字节的位置，复制长度个字节
+type snappy struct{}
+
+func (snappy) ID() byte     { return 2 }
+func (snappy) Name() string { return "snappy" }
+
+const (
+	tagLiteral = 0x00
+	tagCopy1   = 0x01
+	tagCopy2   = 0x02
+	tagCopy4   = 0x03
+
+	snappyTableBits = 14
+	snappyMaxOffset = 1<<16 - 1
+)
+
+// Encode 用 hash 表记住每个 4 字节序列最后出现的位置，贪心地寻找最长的重复
+func (snappy) Encode(src []byte) []byte {
+	var head [binary.MaxVarintLen64]byte
+	dst := append([]byte(nil), head[:binary.PutUvarint(head[:], uint64(len(src)))]...)
+
+	var table [1 << snappyTableBits]int // 保存的是位置 + 1，0 表示没有
+	lit := 0                            // 还没有输出的 literal 的开始位置
+	for i := 0; i+4 <= len(src); {
+		h := snappyHash(load32(src, i))
+		cand := table[h] - 1
+		table[h] = i + 1
+		if cand < 0 || i-cand > snappyMaxOffset || load32(src, cand) != load32(src, i) {
+			i++
+			continue
+		}
+		n := 4
+		for i+n < len(src) && src[cand+n] == src[i+n] {
+			n++
+		}
+		dst = emitLiteral(dst, src[lit:i])
+		dst = emitCopy(dst, i-cand, n)
+		i += n
+		lit = i
+	}
+	return emitLiteral(dst, src[lit:])
+}
+
+func load32(b []byte, i int) uint32 {
+	return binary.LittleEndian.Uint32(b[i : i+4])
+}
+
+func snappyHash(u uint32) uint32 {
+	return (u * 0x1e35a7bd) >> (32 - snappyTableBits)
+}
+
+func emitLiteral(dst, lit []byte) []byte {
+	if len(lit) == 0 {
+		return dst
+	}
+	n := uint32(len(lit) - 1)
+	switch {
+	case n < 60:
+		dst = append(dst, byte(n)<<2|tagLiteral)
+	case n < 1<<8:
+		dst = append(dst, 60<<2|tagLiteral, byte(n))
+	case n < 1<<16:
+		dst = append(dst, 61<<2|tagLiteral, byte(n), byte(n>>8))
+	case n < 1<<24:
+		dst = append(dst, 62<<2|tagLiteral, byte(n), byte(n>>8), byte(n>>16))
+	default:
+		dst = append(dst, 63<<2|tagLiteral, byte(n), byte(n>>8), byte(n>>16), byte(n>>24))
+	}
+	return append(dst, lit...)
+}
+
+// emitCopy 的 length 至少是 4
+func emitCopy(dst []byte, offset, length int) []byte {
+	// 每个 copy 最长 64，剩下的部分不能少于 4，否则无法用 tagCopy1 编码
+	for length >= 68 {
+		dst = append(dst, 63<<2|tagCopy2, byte(offset), byte(offset>>8))
+		length -= 64
+	}
+	if length > 64 {
+		dst = append(dst, 59<<2|tagCopy2, byte(offset), byte(offset>>8))
+		length -= 60
+	}
+	if length < 12 && offset < 2048 {
+		return append(dst, byte(offset>>8)<<5|byte(length-4)<<2|tagCopy1, byte(offset))
+	}
+	return append(dst, byte(length-1)<<2|tagCopy2, byte(offset), byte(offset>>8))
+}
+
+// Decode 实现了 Codec 接口
+func (snappy) Decode(src []byte) ([]byte, error) {
+	size, n := binary.Uvarint(src)
+	if n <= 0 || size > uint64(len(src))*256 {
+		// snappy 的压缩率不可能超过 256 倍，太大的长度一定是错误的
+		return nil, ErrCorrupt
+	}
+	dst := make([]byte, 0, size)
+	for s := n; s < len(src); {
+		tag := src[s]
+		var length, offset int
+		switch tag & 0x03 {
+		case tagLiteral:
+			length = int(tag >> 2)
+			s++
+			if length >= 60 {
+				extra := length - 59
+				if s+extra > len(src) {
+					return nil, ErrCorrupt
+				}
+				length = 0
+				for i := extra - 1; i >= 0; i-- {
+					length = length<<8 | int(src[s+i])
+				}
+				s += extra
+			}
+			length++
+			if length > len(src)-s || uint64(len(dst)+length) > size {
+				return nil, ErrCorrupt
+			}
+			dst = append(dst, src[s:s+length]...)
+			s += length
+			continue
+		case tagCopy1:
+			if s+2 > len(src) {
+				return nil, ErrCorrupt
+			}
+			length = 4 + int(tag>>2&0x07)
+			offset = int(tag>>5)<<8 | int(src[s+1])
+			s += 2
+		case tagCopy2:
+			if s+3 > len(src) {
+				return nil, ErrCorrupt
+			}
+			length = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint16(src[s+1:]))
+			s += 3
+		case tagCopy4:
+			if s+5 > len(src) {
+				return nil, ErrCorrupt
+			}
+			length = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint32(src[s+1:]))
+			s += 5
+		}
+		if offset <= 0 || offset > len(dst) || uint64(len(dst)+length) > size {
+			return nil, ErrCorrupt
+		}
+		// offset 可以小于 length，此时复制的内容会与正在写入的内容重叠，需要逐个字节地复制
+		start := len(dst) - offset
+		for i := 0; i < length; i++ {
+			dst = append(dst, dst[start+i])
+		}
+	}
+	if uint64(len(dst)) != size {
+		return nil, ErrCorrupt
+	}
+	return dst, nil
+}
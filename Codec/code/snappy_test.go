@@ -0,0 +1,52 @@
+package codec
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_snappy_longMatchesAndLiterals(t *testing.T) {
+	ast := assert.New(t)
+	//
+	rnd := rand.New(rand.NewSource(2))
+	// 覆盖各种长度的 literal 和 copy，以及超过 64KB 的 offset
+	for _, size := range []int{59, 60, 61, 255, 256, 257, 65535, 65536, 70000, 1 << 20} {
+		src := make([]byte, size)
+		rnd.Read(src[:size/2])
+		copy(src[size/2:], src)
+		res, err := Snappy.Decode(Snappy.Encode(src))
+		ast.Nil(err)
+		ast.Equal(src, res, "%d", size)
+	}
+}
+
+func Test_snappy_overlappingCopy(t *testing.T) {
+	ast := assert.New(t)
+	//
+	// "a" 之后 offset 为 1，长度为 9 的 copy
+	src := []byte{10, 0, 'a', 8<<2 | tagCopy2, 1, 0}
+	res, err := Snappy.Decode(src)
+	ast.Nil(err)
+	ast.Equal("aaaaaaaaaa", string(res))
+}
+
+func Test_snappy_corrupt(t *testing.T) {
+	ast := assert.New(t)
+	//
+	good := Snappy.Encode([]byte("hello, hello, hello, hello"))
+	corrupt := [][]byte{
+		nil,
+		good[:len(good)-1],
+		{5, 0, 'a'},                        // 长度不够
+		{5, 4<<2 | tagLiteral, 'a'},        // literal 越界
+		{5, 0, 'a', 3<<2 | tagCopy2, 2, 0}, // offset 越界
+		{5, 0, 'a', tagCopy1},              // copy 不完整
+		{0xff, 0xff, 0xff, 0xff, 0x0f},     // 长度太大
+	}
+	for _, src := range corrupt {
+		_, err := Snappy.Decode(src)
+		ast.Equal(ErrCorrupt, err, "%v", src)
+	}
+}
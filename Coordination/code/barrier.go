@@ -0,0 +1,43 @@
+package coordination
+
+// Barrier 是 ZooKeeper recipes 中的 barrier
+// barrier node 存在时，所有调用 Wait 的 client 都会阻塞，直到 barrier node 被删除
+type Barrier struct {
+	c    *Client
+	path string
+}
+
+// NewBarrier 返回以 path 作为 barrier node 的 Barrier
+func NewBarrier(c *Client, path string) *Barrier {
+	return &Barrier{c: c, path: path}
+}
+
+// Set 创建 barrier node，barrier 已经存在时也不会出错
+func (b *Barrier) Set() error {
+	_, err := b.c.Create(b.path, "", 0)
+	if err == ErrNodeExists {
+		return nil
+	}
+	return err
+}
+
+// Remove 删除 barrier node，放行所有在 Wait 的 client
+func (b *Barrier) Remove() error {
+	return b.c.Delete(b.path)
+}
+
+// Wait 阻塞到 barrier node 不存在为止
+func (b *Barrier) Wait() error {
+	for {
+		exists, events, err := b.c.ExistsW(b.path)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return nil
+		}
+		if _, ok := <-events; !ok {
+			return ErrClosed
+		}
+	}
+}
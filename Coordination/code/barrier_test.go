@@ -0,0 +1,37 @@
+package coordination
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Barrier(t *testing.T) {
+	ast := assert.New(t)
+	//
+	env := newTestEnv(4)
+	defer env.close()
+	//
+	ast.Nil(NewBarrier(env.clients[0], "/barrier").Set())
+	ast.Nil(NewBarrier(env.clients[0], "/barrier").Set())
+	//
+	var passed int32
+	var wg sync.WaitGroup
+	for _, c := range env.clients[1:] {
+		wg.Add(1)
+		go func(c *Client) {
+			defer wg.Done()
+			ast.Nil(NewBarrier(c, "/barrier").Wait())
+			atomic.AddInt32(&passed, 1)
+		}(c)
+	}
+	time.Sleep(100 * time.Millisecond)
+	ast.Equal(int32(0), atomic.LoadInt32(&passed))
+	//
+	ast.Nil(NewBarrier(env.clients[0], "/barrier").Remove())
+	wg.Wait()
+	ast.Equal(int32(3), passed)
+}
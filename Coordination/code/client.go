@@ -0,0 +1,196 @@
+package coordination
+
+import (
+	"sync"
+
+	reliablechannel "github.com/aQuaYi/Distributed-Algorithms/Reliable-Channel/code"
+)
+
+type watchTarget struct {
+	path string
+	kind watchKind
+}
+
+// Client 通过 reliablechannel.Endpoint 访问 Server
+// 一个 Client 就是一个 session，Close 以后，它创建的 Ephemeral node 都会被删除
+type Client struct {
+	ep     *reliablechannel.Endpoint
+	server int
+
+	mu       sync.Mutex
+	nextID   int
+	pending  map[int]chan *response
+	watchers map[watchTarget][]chan Event
+	closed   bool
+}
+
+// NewClient 返回通过 ep 访问 ID 为 server 的 Server 的 client
+func NewClient(ep *reliablechannel.Endpoint, server int) *Client {
+	c := &Client{
+		ep:       ep,
+		server:   server,
+		pending:  make(map[int]chan *response),
+		watchers: make(map[watchTarget][]chan Event),
+	}
+	go c.receiveLoop()
+	return c
+}
+
+func (c *Client) receiveLoop() {
+	for {
+		d, ok := c.ep.Receive()
+		if !ok {
+			break
+		}
+		switch m := d.Payload.(type) {
+		case *response:
+			c.mu.Lock()
+			ch := c.pending[m.id]
+			delete(c.pending, m.id)
+			c.mu.Unlock()
+			ch <- m
+		case *Event:
+			c.mu.Lock()
+			target := watchTarget{path: m.Path, kind: m.kind}
+			chs := c.watchers[target]
+			delete(c.watchers, target)
+			c.mu.Unlock()
+			for _, ch := range chs {
+				ch <- *m
+			}
+		}
+	}
+	// ep 被关闭了，唤醒所有还在等待的调用
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	for id, ch := range c.pending {
+		close(ch)
+		delete(c.pending, id)
+	}
+	for target, chs := range c.watchers {
+		for _, ch := range chs {
+			close(ch)
+		}
+		delete(c.watchers, target)
+	}
+}
+
+// call 发送 req 并等待 server 的回复
+// watch 为 true 时，会在发送 req 之前注册 watch，并返回接收 Event 的通道
+func (c *Client) call(req *request) (*response, <-chan Event, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, nil, ErrClosed
+	}
+	req.id = c.nextID
+	c.nextID++
+	ch := make(chan *response, 1)
+	c.pending[req.id] = ch
+	var events chan Event
+	if req.watch {
+		events = make(chan Event, 1)
+		kind := watchNode
+		if req.op == opChildren {
+			kind = watchChildren
+		}
+		target := watchTarget{path: req.path, kind: kind}
+		c.watchers[target] = append(c.watchers[target], events)
+	}
+	c.mu.Unlock()
+
+	c.ep.Send(c.server, req)
+
+	resp, ok := <-ch
+	if !ok {
+		return nil, nil, ErrClosed
+	}
+	return resp, events, resp.err
+}
+
+// Create 创建 path，返回实际创建的 path
+// flags 含有 Sequential 时，实际创建的 path 是 path 加上序号
+func (c *Client) Create(path, data string, flags Flag) (string, error) {
+	resp, _, err := c.call(&request{op: opCreate, path: path, data: data, flags: flags})
+	if err != nil {
+		return "", err
+	}
+	return resp.path, nil
+}
+
+// Delete 删除 path，path 不能有 children
+func (c *Client) Delete(path string) error {
+	_, _, err := c.call(&request{op: opDelete, path: path})
+	return err
+}
+
+// Exists 返回 path 是否存在
+func (c *Client) Exists(path string) (bool, error) {
+	resp, _, err := c.call(&request{op: opExists, path: path})
+	if err != nil {
+		return false, err
+	}
+	return resp.exists, nil
+}
+
+// ExistsW 与 Exists 一样，同时在 path 上设置 watch
+// path 被创建、删除时，返回的通道会收到 Event
+func (c *Client) ExistsW(path string) (bool, <-chan Event, error) {
+	resp, events, err := c.call(&request{op: opExists, path: path, watch: true})
+	if err != nil {
+		return false, nil, err
+	}
+	return resp.exists, events, nil
+}
+
+// Get 返回 path 的数据
+func (c *Client) Get(path string) (string, error) {
+	resp, _, err := c.call(&request{op: opGet, path: path})
+	if err != nil {
+		return "", err
+	}
+	return resp.data, nil
+}
+
+// Children 返回 path 所有 children 的名字，按字典序排列
+func (c *Client) Children(path string) ([]string, error) {
+	resp, _, err := c.call(&request{op: opChildren, path: path})
+	if err != nil {
+		return nil, err
+	}
+	return resp.children, nil
+}
+
+// ChildrenW 与 Children 一样，同时在 path 上设置 watch
+// path 的 children 发生变化，或者 path 被删除时，返回的通道会收到 Event
+func (c *Client) ChildrenW(path string) ([]string, <-chan Event, error) {
+	resp, events, err := c.call(&request{op: opChildren, path: path, watch: true})
+	if err != nil {
+		c.removeWatcher(watchTarget{path: path, kind: watchChildren}, events)
+		return nil, nil, err
+	}
+	return resp.children, events, nil
+}
+
+// removeWatcher 删除 server 没有设置成功的 watch
+func (c *Client) removeWatcher(target watchTarget, events <-chan Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	chs := c.watchers[target]
+	for i, ch := range chs {
+		if ch == events {
+			c.watchers[target] = append(chs[:i], chs[i+1:]...)
+			break
+		}
+	}
+	if len(c.watchers[target]) == 0 {
+		delete(c.watchers, target)
+	}
+}
+
+// Close 结束 session，并关闭 client 的 endpoint
+func (c *Client) Close() {
+	c.call(&request{op: opCloseSession})
+	c.ep.Close()
+}
@@ -0,0 +1,135 @@
+package coordination
+
+import (
+	"testing"
+	"time"
+
+	reliablechannel "github.com/aQuaYi/Distributed-Algorithms/Reliable-Channel/code"
+	"github.com/stretchr/testify/assert"
+)
+
+// serverID 是测试中 server 的 endpoint ID，client 的 ID 从 1 开始
+const serverID = 0
+
+type testEnv struct {
+	net     *reliablechannel.LossyNetwork
+	server  *reliablechannel.Endpoint
+	clients []*Client
+}
+
+// newTestEnv 在会丢包、重复和乱序的网络上，启动一个 server 和 n 个 client
+func newTestEnv(n int) *testEnv {
+	env := &testEnv{
+		net: reliablechannel.NewLossyNetwork(0.2, 0.1, 2*time.Millisecond),
+	}
+	env.server = reliablechannel.NewEndpoint(serverID, env.net, 10*time.Millisecond)
+	go NewServer(env.server).Serve()
+	for i := 1; i <= n; i++ {
+		ep := reliablechannel.NewEndpoint(i, env.net, 10*time.Millisecond)
+		env.clients = append(env.clients, NewClient(ep, serverID))
+	}
+	return env
+}
+
+func (env *testEnv) close() {
+	for _, c := range env.clients {
+		c.Close()
+	}
+	env.server.Close()
+}
+
+func Test_Client_basicOperations(t *testing.T) {
+	ast := assert.New(t)
+	//
+	env := newTestEnv(1)
+	defer env.close()
+	c := env.clients[0]
+	//
+	path, err := c.Create("/app", "data", 0)
+	ast.Nil(err)
+	ast.Equal("/app", path)
+	_, err = c.Create("/app", "", 0)
+	ast.Equal(ErrNodeExists, err)
+	_, err = c.Create("/missing/child", "", 0)
+	ast.Equal(ErrNoNode, err)
+	_, err = c.Create("app", "", 0)
+	ast.Equal(ErrBadPath, err)
+	//
+	data, err := c.Get("/app")
+	ast.Nil(err)
+	ast.Equal("data", data)
+	exists, err := c.Exists("/app")
+	ast.Nil(err)
+	ast.True(exists)
+	//
+	p0, _ := c.Create("/app/job-", "", Sequential)
+	p1, _ := c.Create("/app/job-", "", Sequential)
+	ast.Equal("/app/job-0000000000", p0)
+	ast.Equal("/app/job-0000000001", p1)
+	children, err := c.Children("/app")
+	ast.Nil(err)
+	ast.Equal([]string{"job-0000000000", "job-0000000001"}, children)
+	//
+	ast.Equal(ErrNotEmpty, c.Delete("/app"))
+	ast.Nil(c.Delete(p0))
+	ast.Nil(c.Delete(p1))
+	ast.Nil(c.Delete("/app"))
+	ast.Equal(ErrNoNode, c.Delete("/app"))
+	_, err = c.Children("/app")
+	ast.Equal(ErrNoNode, err)
+}
+
+func Test_Client_ephemeralNodes(t *testing.T) {
+	ast := assert.New(t)
+	//
+	env := newTestEnv(2)
+	defer env.close()
+	owner, other := env.clients[0], env.clients[1]
+	//
+	owner.Create("/lock", "", Ephemeral)
+	_, err := owner.Create("/lock/child", "", 0)
+	ast.Equal(ErrEphemeralParent, err)
+	//
+	exists, events, err := other.ExistsW("/lock")
+	ast.Nil(err)
+	ast.True(exists)
+	// owner 的 session 结束后，Ephemeral node 会被删除，watch 也会被触发
+	owner.Close()
+	e := <-events
+	ast.Equal(EventDeleted, e.Type)
+	ast.Equal("/lock", e.Path)
+	exists, _ = other.Exists("/lock")
+	ast.False(exists)
+	//
+	_, err = owner.Exists("/lock")
+	ast.Equal(ErrClosed, err)
+}
+
+func Test_Client_watches(t *testing.T) {
+	ast := assert.New(t)
+	//
+	env := newTestEnv(2)
+	defer env.close()
+	watcher, writer := env.clients[0], env.clients[1]
+	//
+	exists, nodeEvents, _ := watcher.ExistsW("/config")
+	ast.False(exists)
+	writer.Create("/config", "", 0)
+	ast.Equal(Event{Type: EventCreated, Path: "/config", kind: watchNode}, <-nodeEvents)
+	//
+	_, childEvents, _ := watcher.ChildrenW("/config")
+	writer.Create("/config/a", "", 0)
+	e := <-childEvents
+	ast.Equal(EventChildrenChanged, e.Type)
+	ast.Equal("{ChildrenChanged /config}", e.String())
+	// watch 只会被触发一次
+	writer.Create("/config/b", "", 0)
+	select {
+	case <-childEvents:
+		ast.Fail("watch 被触发了两次")
+	case <-time.After(50 * time.Millisecond):
+	}
+	//
+	_, _, err := watcher.ChildrenW("/missing")
+	ast.Equal(ErrNoNode, err)
+}
@@ -0,0 +1,95 @@
+package coordination
+
+// readyNode 是 DoubleBarrier 中，所有参与者都到齐以后才会被创建的 node
+const readyNode = "ready"
+
+// DoubleBarrier 是 ZooKeeper recipes 中的 double barrier
+// 所有 size 个参与者都调用了 Enter 以后，大家才能开始计算；
+// 所有参与者都调用了 Leave 以后，大家才能离开。
+// 每个参与者在 path 下以自己的名字创建一个 Ephemeral node，表示自己在 barrier 中
+type DoubleBarrier struct {
+	c    *Client
+	path string
+	name string
+	size int
+	node string
+}
+
+// NewDoubleBarrier 返回名为 name 的参与者在 path 上使用的 DoubleBarrier
+// 同一个 barrier 中，每个参与者的 name 必须不同
+func NewDoubleBarrier(c *Client, path, name string, size int) *DoubleBarrier {
+	return &DoubleBarrier{
+		c:    c,
+		path: path,
+		name: name,
+		size: size,
+	}
+}
+
+// Enter 阻塞到所有的参与者都进入了 barrier
+func (b *DoubleBarrier) Enter() error {
+	if _, err := b.c.Create(b.path, "", 0); err != nil && err != ErrNodeExists {
+		return err
+	}
+	ready := join(b.path, readyNode)
+	// 先设置 watch 再加入，才不会错过 ready node 被创建的通知
+	exists, events, err := b.c.ExistsW(ready)
+	if err != nil {
+		return err
+	}
+	b.node, err = b.c.Create(join(b.path, b.name), "", Ephemeral)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+	children, err := b.c.Children(b.path)
+	if err != nil {
+		return err
+	}
+	if len(participants(children)) >= b.size {
+		// 最后一个到达的参与者通知大家
+		if _, err := b.c.Create(ready, "", 0); err != nil && err != ErrNodeExists {
+			return err
+		}
+		return nil
+	}
+	if _, ok := <-events; !ok {
+		return ErrClosed
+	}
+	return nil
+}
+
+// Leave 阻塞到所有的参与者都离开了 barrier
+func (b *DoubleBarrier) Leave() error {
+	if err := b.c.Delete(b.node); err != nil {
+		return err
+	}
+	for {
+		children, events, err := b.c.ChildrenW(b.path)
+		if err != nil {
+			return err
+		}
+		if len(participants(children)) == 0 {
+			if err := b.c.Delete(join(b.path, readyNode)); err != nil && err != ErrNoNode {
+				return err
+			}
+			return nil
+		}
+		if _, ok := <-events; !ok {
+			return ErrClosed
+		}
+	}
+}
+
+// participants 返回 children 中参与者的名字
+func participants(children []string) []string {
+	res := make([]string, 0, len(children))
+	for _, name := range children {
+		if name != readyNode {
+			res = append(res, name)
+		}
+	}
+	return res
+}
@@ -0,0 +1,60 @@
+package coordination
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_DoubleBarrier(t *testing.T) {
+	ast := assert.New(t)
+	//
+	size := 4
+	env := newTestEnv(size)
+	defer env.close()
+	//
+	var mu sync.Mutex
+	var events []string
+	record := func(e string) {
+		mu.Lock()
+		events = append(events, e)
+		mu.Unlock()
+	}
+	//
+	var wg sync.WaitGroup
+	for i, c := range env.clients {
+		wg.Add(1)
+		go func(i int, c *Client) {
+			defer wg.Done()
+			// 参与者陆续到达
+			time.Sleep(time.Duration(i*20) * time.Millisecond)
+			b := NewDoubleBarrier(c, "/double", fmt.Sprint("p", i), size)
+			record("arrive")
+			ast.Nil(b.Enter())
+			record("enter")
+			time.Sleep(time.Duration(i*20) * time.Millisecond)
+			record("finish")
+			ast.Nil(b.Leave())
+			record("leave")
+		}(i, c)
+	}
+	wg.Wait()
+	// 所有人都到达以后，才有人进入；所有人都完成以后，才有人离开
+	ast.Equal(4*size, len(events))
+	first := make(map[string]int)
+	last := make(map[string]int)
+	for i := len(events) - 1; i >= 0; i-- {
+		first[events[i]] = i
+	}
+	for i, e := range events {
+		last[e] = i
+	}
+	ast.True(last["arrive"] < first["enter"])
+	ast.True(last["finish"] < first["leave"])
+	// barrier 结束后，没有留下任何 child
+	children, _ := env.clients[0].Children("/double")
+	ast.Equal(0, len(children))
+}
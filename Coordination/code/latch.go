@@ -0,0 +1,66 @@
+package coordination
+
+import "strconv"
+
+// countDownPrefix 是 CountDown 时创建的 node 的名字前缀
+const countDownPrefix = "down-"
+
+// CountDownLatch 与 java.util.concurrent.CountDownLatch 类似，但是可以在多个 client 之间使用
+// latch node 的数据是初始的 count，每次 CountDown 都会在 latch node 下创建一个 Sequential child，
+// child 的数量达到 count 以后，所有调用 Await 的 client 都会被放行
+type CountDownLatch struct {
+	c     *Client
+	path  string
+	count int
+}
+
+// NewCountDownLatch 在 path 上创建初始值为 count 的 latch
+// path 已经存在时，加入已有的 latch，此时 count 以已有的 latch 为准
+func NewCountDownLatch(c *Client, path string, count int) (*CountDownLatch, error) {
+	_, err := c.Create(path, strconv.Itoa(count), 0)
+	if err == ErrNodeExists {
+		var data string
+		data, err = c.Get(path)
+		if err == nil {
+			count, err = strconv.Atoi(data)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &CountDownLatch{c: c, path: path, count: count}, nil
+}
+
+// CountDown 把 latch 的值减一
+func (l *CountDownLatch) CountDown() error {
+	_, err := l.c.Create(join(l.path, countDownPrefix), "", Sequential)
+	return err
+}
+
+// Count 返回 latch 当前的值
+func (l *CountDownLatch) Count() (int, error) {
+	children, err := l.c.Children(l.path)
+	if err != nil {
+		return 0, err
+	}
+	if len(children) >= l.count {
+		return 0, nil
+	}
+	return l.count - len(children), nil
+}
+
+// Await 阻塞到 latch 的值变为 0
+func (l *CountDownLatch) Await() error {
+	for {
+		children, events, err := l.c.ChildrenW(l.path)
+		if err != nil {
+			return err
+		}
+		if len(children) >= l.count {
+			return nil
+		}
+		if _, ok := <-events; !ok {
+			return ErrClosed
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package coordination
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CountDownLatch(t *testing.T) {
+	ast := assert.New(t)
+	//
+	workers := 3
+	env := newTestEnv(workers + 2)
+	defer env.close()
+	//
+	latch, err := NewCountDownLatch(env.clients[0], "/latch", workers)
+	ast.Nil(err)
+	// 加入已有的 latch 时，count 以已有的为准
+	joined, err := NewCountDownLatch(env.clients[1], "/latch", 100)
+	ast.Nil(err)
+	//
+	var wg sync.WaitGroup
+	wg.Add(1)
+	awaited := make(chan struct{})
+	go func() {
+		defer wg.Done()
+		ast.Nil(joined.Await())
+		close(awaited)
+	}()
+	//
+	for _, c := range env.clients[2:] {
+		select {
+		case <-awaited:
+			ast.Fail("latch 在归零之前就放行了")
+		default:
+		}
+		l, _ := NewCountDownLatch(c, "/latch", workers)
+		ast.Nil(l.CountDown())
+	}
+	wg.Wait()
+	count, err := latch.Count()
+	ast.Nil(err)
+	ast.Equal(0, count)
+	// 已经归零的 latch 不会阻塞
+	ast.Nil(latch.Await())
+}
@@ -0,0 +1,127 @@
+package coordination
+
+import (
+	"errors"
+	"fmt"
+)
+
+// 服务端返回的错误
+var (
+	ErrNoNode     = errors.New("coordination: node does not exist")
+	ErrNodeExists = errors.New("coordination: node already exists")
+	ErrNotEmpty   = errors.New("coordination: node has children")
+	ErrBadPath    = errors.New("coordination: invalid path")
+	// ErrEphemeralParent 表示试图在 Ephemeral node 下创建 child
+	ErrEphemeralParent = errors.New("coordination: ephemeral nodes may not have children")
+)
+
+// ErrClosed 表示 client 已经被关闭了
+var ErrClosed = errors.New("coordination: client is closed")
+
+// Flag 是创建 node 时的选项
+type Flag int
+
+// 枚举了创建 node 时的所有选项，可以组合使用
+const (
+	// Ephemeral 的 node 会在创建它的 client 关闭后被自动删除
+	Ephemeral Flag = 1 << iota
+	// Sequential 的 node 的名字后面会被加上 parent 中单调递增的序号
+	Sequential
+)
+
+type opType int
+
+const (
+	opCreate opType = iota
+	opDelete
+	opExists
+	opGet
+	opChildren
+	opCloseSession
+)
+
+func (o opType) String() string {
+	switch o {
+	case opCreate:
+		return "Create"
+	case opDelete:
+		return "Delete"
+	case opExists:
+		return "Exists"
+	case opGet:
+		return "Get"
+	case opChildren:
+		return "Children"
+	case opCloseSession:
+		return "CloseSession"
+	default:
+		panic("出现了未知的 opType")
+	}
+}
+
+// request 是 client 发给 server 的请求
+type request struct {
+	id    int
+	op    opType
+	path  string
+	data  string
+	flags Flag
+	watch bool
+}
+
+func (r *request) String() string {
+	return fmt.Sprintf("{%d:%s %s}", r.id, r.op, r.path)
+}
+
+// response 是 server 对 request 的回复
+type response struct {
+	id       int
+	err      error
+	path     string // Create 时实际创建的 path
+	data     string
+	exists   bool
+	children []string
+}
+
+// EventType 是 watch 被触发的原因
+type EventType int
+
+// 枚举了 Event 的所有类型
+const (
+	EventCreated EventType = iota
+	EventDeleted
+	EventChildrenChanged
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventCreated:
+		return "Created"
+	case EventDeleted:
+		return "Deleted"
+	case EventChildrenChanged:
+		return "ChildrenChanged"
+	default:
+		panic("出现了未知的 EventType")
+	}
+}
+
+// watchKind 区分 watch 关注的是 node 本身，还是 node 的 children
+type watchKind int
+
+const (
+	watchNode watchKind = iota
+	watchChildren
+)
+
+// Event 是 watch 被触发时，server 发给 client 的通知
+// 和 ZooKeeper 一样，watch 只会被触发一次
+type Event struct {
+	Type EventType
+	Path string
+	kind watchKind
+}
+
+func (e Event) String() string {
+	return fmt.Sprintf("{%s %s}", e.Type, e.Path)
+}
@@ -0,0 +1,197 @@
+package coordination
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	reliablechannel "github.com/aQuaYi/Distributed-Algorithms/Reliable-Channel/code"
+)
+
+// znode 是 server 中保存的一个 node
+type znode struct {
+	data     string
+	owner    int // Ephemeral node 所属的 client，持久的 node 为 -1
+	children map[string]bool
+	nextSeq  int // 下一个 Sequential child 的序号
+}
+
+type watchKey struct {
+	client int
+	path   string
+	kind   watchKind
+}
+
+// Server 是一个类似于 ZooKeeper 的协调服务
+// 它维护一棵 node 树，按照收到的顺序逐个处理 client 的请求。
+// server 和 client 之间通过 reliablechannel.Endpoint 通信，
+// 所以每个 client 看到的回复和 watch 通知的顺序，与 server 处理它们的顺序一致。
+type Server struct {
+	ep      *reliablechannel.Endpoint
+	nodes   map[string]*znode
+	watches map[watchKey]bool
+}
+
+// NewServer 返回通过 ep 提供服务的 server，调用 Serve 后开始处理请求
+func NewServer(ep *reliablechannel.Endpoint) *Server {
+	return &Server{
+		ep: ep,
+		nodes: map[string]*znode{
+			"/": {owner: -1, children: make(map[string]bool)},
+		},
+		watches: make(map[watchKey]bool),
+	}
+}
+
+// Serve 处理请求，直到 ep 被关闭
+func (s *Server) Serve() {
+	for {
+		d, ok := s.ep.Receive()
+		if !ok {
+			return
+		}
+		req := d.Payload.(*request)
+		resp := s.handle(d.From, req)
+		s.ep.Send(d.From, resp)
+	}
+}
+
+func (s *Server) handle(client int, req *request) *response {
+	resp := &response{id: req.id}
+	switch req.op {
+	case opCreate:
+		resp.path, resp.err = s.create(client, req.path, req.data, req.flags)
+	case opDelete:
+		resp.err = s.delete(req.path)
+	case opExists:
+		_, resp.exists = s.nodes[req.path]
+		if req.watch {
+			s.watches[watchKey{client: client, path: req.path, kind: watchNode}] = true
+		}
+	case opGet:
+		n, ok := s.nodes[req.path]
+		if !ok {
+			resp.err = ErrNoNode
+			break
+		}
+		resp.data = n.data
+	case opChildren:
+		n, ok := s.nodes[req.path]
+		if !ok {
+			resp.err = ErrNoNode
+			break
+		}
+		for name := range n.children {
+			resp.children = append(resp.children, name)
+		}
+		sort.Strings(resp.children)
+		if req.watch {
+			s.watches[watchKey{client: client, path: req.path, kind: watchChildren}] = true
+		}
+	case opCloseSession:
+		s.closeSession(client)
+	}
+	return resp
+}
+
+func (s *Server) create(client int, path, data string, flags Flag) (string, error) {
+	if !strings.HasPrefix(path, "/") || strings.HasSuffix(path, "/") {
+		return "", ErrBadPath
+	}
+	parentPath, _ := split(path)
+	parent, ok := s.nodes[parentPath]
+	if !ok {
+		return "", ErrNoNode
+	}
+	if parent.owner != -1 {
+		return "", ErrEphemeralParent
+	}
+	if flags&Sequential != 0 {
+		path = fmt.Sprintf("%s%010d", path, parent.nextSeq)
+		parent.nextSeq++
+	}
+	if _, ok := s.nodes[path]; ok {
+		return "", ErrNodeExists
+	}
+	n := &znode{data: data, owner: -1, children: make(map[string]bool)}
+	if flags&Ephemeral != 0 {
+		n.owner = client
+	}
+	s.nodes[path] = n
+	_, name := split(path)
+	parent.children[name] = true
+
+	s.trigger(path, watchNode, EventCreated)
+	s.trigger(parentPath, watchChildren, EventChildrenChanged)
+	return path, nil
+}
+
+func (s *Server) delete(path string) error {
+	n, ok := s.nodes[path]
+	if !ok || path == "/" {
+		return ErrNoNode
+	}
+	if len(n.children) > 0 {
+		return ErrNotEmpty
+	}
+	delete(s.nodes, path)
+	parentPath, name := split(path)
+	delete(s.nodes[parentPath].children, name)
+
+	s.trigger(path, watchNode, EventDeleted)
+	s.trigger(path, watchChildren, EventDeleted)
+	s.trigger(parentPath, watchChildren, EventChildrenChanged)
+	return nil
+}
+
+// closeSession 删除 client 所有的 Ephemeral node
+func (s *Server) closeSession(client int) {
+	var paths []string
+	for path, n := range s.nodes {
+		if n.owner == client {
+			paths = append(paths, path)
+		}
+	}
+	// Ephemeral node 不能有 children，所以删除的顺序无关紧要
+	sort.Strings(paths)
+	for _, path := range paths {
+		s.delete(path)
+	}
+	for key := range s.watches {
+		if key.client == client {
+			delete(s.watches, key)
+		}
+	}
+}
+
+// trigger 通知所有在 path 上设置了 kind 类型 watch 的 client
+func (s *Server) trigger(path string, kind watchKind, t EventType) {
+	var clients []int
+	for key := range s.watches {
+		if key.path == path && key.kind == kind {
+			clients = append(clients, key.client)
+			delete(s.watches, key)
+		}
+	}
+	sort.Ints(clients)
+	for _, c := range clients {
+		s.ep.Send(c, &Event{Type: t, Path: path, kind: kind})
+	}
+}
+
+// split 把 path 分为 parent 的 path 和 node 的名字
+func split(path string) (string, string) {
+	i := strings.LastIndex(path, "/")
+	if i <= 0 {
+		return "/", path[i+1:]
+	}
+	return path[:i], path[i+1:]
+}
+
+// join 返回 parent 中名为 name 的 node 的 path
+func join(parent, name string) string {
+	if parent == "/" {
+		return "/" + name
+	}
+	return parent + "/" + name
+}
@@ -0,0 +1,55 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Metrics 是按照名称累加的计数器
+// 模拟器的 Transport 会自动统计 messages 和 messages.<Type>，算法可以通过 Context.Count 添加自己的计数器。
+type Metrics struct {
+	mutex  sync.Mutex
+	counts map[string]int
+}
+
+// NewMetrics 返回空的 Metrics
+func NewMetrics() *Metrics {
+	return &Metrics{counts: make(map[string]int, 16)}
+}
+
+// Add 把 name 增加 delta
+func (m *Metrics) Add(name string, delta int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.counts[name] += delta
+}
+
+// Get 返回 name 的值，没有记录过时返回 0
+func (m *Metrics) Get(name string) int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.counts[name]
+}
+
+// Names 按照字母顺序返回所有记录过的名称
+func (m *Metrics) Names() []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	res := make([]string, 0, len(m.counts))
+	for name := range m.counts {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func (m *Metrics) String() string {
+	names := m.Names()
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s=%d", name, m.Get(name))
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,20 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Metrics(t *testing.T) {
+	ast := assert.New(t)
+	//
+	m := NewMetrics()
+	ast.Equal(0, m.Get("a"))
+	m.Add("b", 2)
+	m.Add("a", 1)
+	m.Add("b", 3)
+	ast.Equal(5, m.Get("b"))
+	ast.Equal([]string{"a", "b"}, m.Names())
+	ast.Equal("a=1 b=5", m.String())
+}
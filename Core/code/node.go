@@ -0,0 +1,122 @@
+package core
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+)
+
+// Message 是 node 之间传递的 message
+type Message struct {
+	From, To int
+	Type     string // 用于 trace 和按类型统计 message
+	Payload  interface{}
+}
+
+func (m Message) String() string {
+	return fmt.Sprintf("P%d→P%d %s %v", m.From, m.To, m.Type, m.Payload)
+}
+
+// Clock 是 node 读取时间和设置定时器的接口，模拟时由 sim.Virtual 实现
+type Clock = sim.Clock
+
+// Transport 负责把 message 送到 m.To
+// 模拟器的 Transport 按照延迟分布送达，真实网络上的 Transport 可以基于 TCP 实现
+type Transport interface {
+	Send(m Message)
+}
+
+// Tracer 记录 node 运行中的事件
+type Tracer interface {
+	Trace(node int, event string)
+}
+
+// Node 是算法中的一个 process
+// 运行时保证 Start、Handle 和 AfterFunc 的回调不会被同时调用，所以 Node 不需要加锁。
+type Node interface {
+	// Start 在开始运行时调用一次，node 可以在这里发送 message 或者设置定时器
+	Start(ctx *Context)
+	// Handle 处理收到的 message
+	Handle(ctx *Context, m Message)
+}
+
+// Context 是 node 运行时可以使用的服务：发送 message、时间和随机数、trace 和 metrics
+// node 只通过 Context 与外界交互，所以同一个 Node 可以运行在模拟器上，也可以运行在真实的网络上。
+type Context struct {
+	id, n     int
+	transport Transport
+	env       sim.Env
+	tracer    Tracer
+	metrics   *Metrics
+}
+
+// NewContext 返回 n 个 node 中 id 的 Context，tracer 和 metrics 可以为 nil
+func NewContext(id, n int, t Transport, env sim.Env, tracer Tracer, metrics *Metrics) *Context {
+	return &Context{
+		id:        id,
+		n:         n,
+		transport: t,
+		env:       env,
+		tracer:    tracer,
+		metrics:   metrics,
+	}
+}
+
+// ID 返回 node 的 id，从 0 开始
+func (c *Context) ID() int {
+	return c.id
+}
+
+// Size 返回 node 的总数
+func (c *Context) Size() int {
+	return c.n
+}
+
+// Send 把 payload 发送给 to
+func (c *Context) Send(to int, typ string, payload interface{}) {
+	c.transport.Send(Message{From: c.id, To: to, Type: typ, Payload: payload})
+}
+
+// Broadcast 把 payload 发送给其他所有的 node
+func (c *Context) Broadcast(typ string, payload interface{}) {
+	for to := 0; to < c.n; to++ {
+		if to != c.id {
+			c.Send(to, typ, payload)
+		}
+	}
+}
+
+// Clock 返回 node 的时钟
+func (c *Context) Clock() Clock {
+	return c.env
+}
+
+// Now 返回当前的时间
+func (c *Context) Now() time.Time {
+	return c.env.Now()
+}
+
+// AfterFunc 在 d 以后调用 f
+func (c *Context) AfterFunc(d time.Duration, f func()) sim.Timer {
+	return c.env.AfterFunc(d, f)
+}
+
+// Rand 返回 node 可以使用的随机数
+func (c *Context) Rand() sim.Rand {
+	return c.env
+}
+
+// Tracef 记录一个事件
+func (c *Context) Tracef(format string, args ...interface{}) {
+	if c.tracer != nil {
+		c.tracer.Trace(c.id, fmt.Sprintf(format, args...))
+	}
+}
+
+// Count 把计数器 name 增加 delta
+func (c *Context) Count(name string, delta int) {
+	if c.metrics != nil {
+		c.metrics.Add(name, delta)
+	}
+}
@@ -0,0 +1,33 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+	"github.com/stretchr/testify/assert"
+)
+
+type recorder []Message
+
+func (r *recorder) Send(m Message) {
+	*r = append(*r, m)
+}
+
+func Test_Context(t *testing.T) {
+	ast := assert.New(t)
+	//
+	var sent recorder
+	ctx := NewContext(1, 3, &sent, sim.Env{}, nil, nil)
+	ast.Equal(1, ctx.ID())
+	ast.Equal(3, ctx.Size())
+	ctx.Broadcast("ping", 7)
+	ast.Equal(recorder{
+		{From: 1, To: 0, Type: "ping", Payload: 7},
+		{From: 1, To: 2, Type: "ping", Payload: 7},
+	}, sent)
+	// 没有 tracer 和 metrics 时，什么也不做
+	ctx.Tracef("event")
+	ctx.Count("c", 1)
+	//
+	ast.Equal("P1→P0 ping 7", sent[0].String())
+}
@@ -0,0 +1,84 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Params 是算法的参数，例如命令行中的 --param requests=5
+type Params map[string]string
+
+// Int 返回整数参数 key，没有设置或者无法解析时返回 def
+func (p Params) Int(key string, def int) int {
+	if v, err := strconv.Atoi(p[key]); err == nil {
+		return v
+	}
+	return def
+}
+
+// Duration 返回时长参数 key，没有设置或者无法解析时返回 def
+func (p Params) Duration(key string, def time.Duration) time.Duration {
+	if v, err := time.ParseDuration(p[key]); err == nil {
+		return v
+	}
+	return def
+}
+
+// Algorithm 描述了一个可以在 core 上运行的算法
+type Algorithm struct {
+	Name    string
+	Kind    string            // 算法的类别，例如 mutex、consensus、gossip、dht
+	Summary string            // 一句话的说明
+	Params  map[string]string // 支持的参数和它们的说明
+	// New 返回 n 个 node 中的第 id 个
+	New func(id, n int, params Params) Node
+	// Invariant 在每一步之后检查所有的 node，返回违反的安全性质，为 nil 时不检查
+	Invariant func(nodes []Node) error
+	// Check 在运行结束以后检查，例如所有的申请都被满足了，为 nil 时不检查
+	Check func(nodes []Node, m *Metrics) error
+}
+
+var registry = struct {
+	sync.Mutex
+	algorithms map[string]Algorithm
+}{algorithms: make(map[string]Algorithm, 16)}
+
+// Register 注册算法 a，通常在算法所在 package 的 init 中调用
+// 与 database/sql 的驱动一样，使用者只需要 import 算法的 package。
+// a.Name 重复或者 a.New 为 nil 时 panic。
+func Register(a Algorithm) {
+	registry.Lock()
+	defer registry.Unlock()
+	if a.New == nil {
+		panic("core: Register with nil New")
+	}
+	if _, dup := registry.algorithms[a.Name]; dup {
+		panic(fmt.Sprintf("core: Register called twice for %q", a.Name))
+	}
+	registry.algorithms[a.Name] = a
+}
+
+// Lookup 返回名为 name 的算法
+func Lookup(name string) (Algorithm, bool) {
+	registry.Lock()
+	defer registry.Unlock()
+	a, ok := registry.algorithms[name]
+	return a, ok
+}
+
+// Algorithms 按照名称的顺序返回所有注册过的算法
+func Algorithms() []Algorithm {
+	registry.Lock()
+	defer registry.Unlock()
+	res := make([]Algorithm, 0, len(registry.algorithms))
+	for _, a := range registry.algorithms {
+		res = append(res, a)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+	return res
+}
@@ -0,0 +1,46 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Register(t *testing.T) {
+	ast := assert.New(t)
+	//
+	a := ringAlgorithm
+	a.Name = "test-ring"
+	Register(a)
+	got, ok := Lookup("test-ring")
+	ast.True(ok)
+	ast.Equal("test", got.Kind)
+	_, ok = Lookup("test-missing")
+	ast.False(ok)
+	//
+	ast.Panics(func() { Register(a) }, "名称重复")
+	a.Name, a.New = "test-nil", nil
+	ast.Panics(func() { Register(a) })
+	//
+	names := []string{}
+	for _, a := range Algorithms() {
+		names = append(names, a.Name)
+	}
+	ast.Contains(names, "test-ring")
+	for i := 1; i < len(names); i++ {
+		ast.True(names[i-1] < names[i])
+	}
+}
+
+func Test_Params(t *testing.T) {
+	ast := assert.New(t)
+	//
+	p := Params{"n": "5", "d": "20ms", "bad": "x"}
+	ast.Equal(5, p.Int("n", 1))
+	ast.Equal(1, p.Int("missing", 1))
+	ast.Equal(1, p.Int("bad", 1))
+	ast.Equal(20*time.Millisecond, p.Duration("d", time.Second))
+	ast.Equal(time.Second, p.Duration("bad", time.Second))
+	ast.Equal(3, Params(nil).Int("n", 3))
+}
@@ -0,0 +1,161 @@
+package core
+
+import (
+	"fmt"
+	"time"
+
+	reliablechannel "github.com/aQuaYi/Distributed-Algorithms/Reliable-Channel/code"
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+)
+
+// Config 描述了一次模拟运行
+type Config struct {
+	Nodes  int
+	Params Params
+	Seed   int64
+	// Latency 是 message 的延迟分布，为 nil 时没有延迟
+	// 同一对 node 之间的 message 总是按照发送的顺序送达
+	Latency reliablechannel.Latency
+	// LossRate 是每条 message 丢失的概率，丢失的 message 计入 messages.lost
+	// 仓库中的算法大多假设通道是可靠的，丢失 message 以后，Check 通常会报告活性被破坏
+	LossRate float64
+	// MaxTime 是模拟时间的上限，为 0 时一直运行到没有事件为止
+	// 带有周期性定时器的算法需要设置它
+	MaxTime time.Duration
+}
+
+// Result 是一次模拟运行的结果
+type Result struct {
+	Algorithm string
+	Nodes     int
+	Steps     int           // 处理的事件数量，包括 message 和定时器
+	Elapsed   time.Duration // 模拟时间
+	Metrics   *Metrics
+	Trace     []string
+	Violation string // 为空表示没有发现错误
+}
+
+// OK 返回 true，如果没有发现错误
+func (r *Result) OK() bool {
+	return r.Violation == ""
+}
+
+func (r *Result) String() string {
+	res := fmt.Sprintf("%s：%d 个 node，运行了 %d 步，模拟时间 %s\n%s",
+		r.Algorithm, r.Nodes, r.Steps, r.Elapsed, r.Metrics)
+	if r.OK() {
+		return res + "\n没有发现错误"
+	}
+	return res + "\n违反了 " + r.Violation
+}
+
+// Run 在模拟器上运行注册过的算法 name
+func Run(name string, c Config) (*Result, error) {
+	a, ok := Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("core: unknown algorithm %q", name)
+	}
+	return Simulate(a, c), nil
+}
+
+// Simulate 在虚拟时钟上运行 a 的 c.Nodes 个 node
+// 所有的事件都在同一个 goroutine 中按照时间顺序处理，同样的 c 总会得到同样的结果。
+// 每一步之后检查 a.Invariant，结束以后检查 a.Check。
+func Simulate(a Algorithm, c Config) *Result {
+	start := time.Unix(0, 0)
+	clock := sim.NewVirtual(start)
+	env := sim.Deterministic(clock, c.Seed)
+	res := &Result{
+		Algorithm: a.Name,
+		Nodes:     c.Nodes,
+		Metrics:   NewMetrics(),
+	}
+	tracer := NewTraceLog(clock)
+	defer func() {
+		res.Trace = tracer.Lines()
+	}()
+	t := &simTransport{
+		env:     env,
+		latency: c.Latency,
+		loss:    c.LossRate,
+		arrival: make(map[[2]int]time.Time, c.Nodes*c.Nodes),
+		tracer:  tracer,
+		metrics: res.Metrics,
+	}
+	nodes := make([]Node, c.Nodes)
+	t.nodes = nodes
+	t.contexts = make([]*Context, c.Nodes)
+	for i := range nodes {
+		nodes[i] = a.New(i, c.Nodes, c.Params)
+		t.contexts[i] = NewContext(i, c.Nodes, t, env, tracer, res.Metrics)
+	}
+	for i, n := range nodes {
+		n.Start(t.contexts[i])
+	}
+
+	check := func() bool {
+		if a.Invariant == nil {
+			return true
+		}
+		if err := a.Invariant(nodes); err != nil {
+			res.Violation = fmt.Sprintf("第 %d 步：%s", res.Steps, err)
+			return false
+		}
+		return true
+	}
+	if !check() {
+		return res
+	}
+	for c.MaxTime == 0 || clock.Since(start) < c.MaxTime {
+		if !clock.Step() {
+			break
+		}
+		res.Steps++
+		if !check() {
+			return res
+		}
+	}
+	res.Elapsed = clock.Since(start)
+	if a.Check != nil {
+		if err := a.Check(nodes, res.Metrics); err != nil {
+			res.Violation = err.Error()
+		}
+	}
+	return res
+}
+
+// simTransport 按照延迟分布，在虚拟时钟上送达 message
+type simTransport struct {
+	env      sim.Env
+	latency  reliablechannel.Latency
+	loss     float64
+	arrival  map[[2]int]time.Time // 每一对 node 之间最后一条 message 的送达时间，用来保证 FIFO
+	nodes    []Node
+	contexts []*Context
+	tracer   Tracer
+	metrics  *Metrics
+}
+
+func (t *simTransport) Send(m Message) {
+	t.metrics.Add("messages", 1)
+	t.metrics.Add("messages."+m.Type, 1)
+	if t.loss > 0 && t.env.Float64() < t.loss {
+		t.metrics.Add("messages.lost", 1)
+		t.tracer.Trace(m.From, "丢失 "+m.String())
+		return
+	}
+	var delay time.Duration
+	if t.latency != nil {
+		delay = t.latency.Sample(t.env)
+	}
+	link := [2]int{m.From, m.To}
+	at := t.env.Now().Add(delay)
+	if last := t.arrival[link]; at.Before(last) {
+		at = last
+	}
+	t.arrival[link] = at
+	t.env.AfterFunc(at.Sub(t.env.Now()), func() {
+		t.tracer.Trace(m.To, "收到 "+m.String())
+		t.nodes[m.To].Handle(t.contexts[m.To], m)
+	})
+}
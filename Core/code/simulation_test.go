@@ -0,0 +1,180 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	reliablechannel "github.com/aQuaYi/Distributed-Algorithms/Reliable-Channel/code"
+	"github.com/stretchr/testify/assert"
+)
+
+// ring 把 token 沿着环传递 rounds 圈，token 中是当前的圈数
+type ring struct {
+	rounds  int
+	holding bool
+}
+
+func (r *ring) Start(ctx *Context) {
+	if ctx.ID() == 0 {
+		r.pass(ctx, 1)
+	}
+}
+
+func (r *ring) Handle(ctx *Context, m Message) {
+	r.holding = true
+	round := m.Payload.(int)
+	if ctx.ID() == 0 {
+		ctx.Count("rounds", 1)
+		if round == r.rounds {
+			ctx.Tracef("结束")
+			return
+		}
+		round++
+	}
+	r.pass(ctx, round)
+}
+
+func (r *ring) pass(ctx *Context, round int) {
+	r.holding = false
+	ctx.Send((ctx.ID()+1)%ctx.Size(), "token", round)
+}
+
+var ringAlgorithm = Algorithm{
+	Name: "ring",
+	Kind: "test",
+	New: func(id, n int, params Params) Node {
+		return &ring{rounds: params.Int("rounds", 2)}
+	},
+	Invariant: func(nodes []Node) error {
+		count := 0
+		for _, n := range nodes {
+			if n.(*ring).holding {
+				count++
+			}
+		}
+		if count > 1 {
+			return fmt.Errorf("%d 个 node 同时持有 token", count)
+		}
+		return nil
+	},
+	Check: func(nodes []Node, m *Metrics) error {
+		if m.Get("rounds") == 0 {
+			return errors.New("token 没有回到 node 0")
+		}
+		return nil
+	},
+}
+
+func Test_Simulate(t *testing.T) {
+	ast := assert.New(t)
+	//
+	res := Simulate(ringAlgorithm, Config{
+		Nodes:   3,
+		Params:  Params{"rounds": "4"},
+		Latency: reliablechannel.Fixed(10 * time.Millisecond),
+	})
+	ast.True(res.OK(), res.Violation)
+	ast.Equal(12, res.Steps)
+	ast.Equal(120*time.Millisecond, res.Elapsed)
+	ast.Equal(4, res.Metrics.Get("rounds"))
+	ast.Equal(12, res.Metrics.Get("messages.token"))
+	ast.Equal("[10ms] P1 收到 P0→P1 token 1", res.Trace[0])
+	ast.Equal("[120ms] P0 结束", res.Trace[len(res.Trace)-1])
+}
+
+func Test_Simulate_maxTime(t *testing.T) {
+	ast := assert.New(t)
+	//
+	res := Simulate(ringAlgorithm, Config{
+		Nodes:   3,
+		Params:  Params{"rounds": "100"},
+		Latency: reliablechannel.Fixed(10 * time.Millisecond),
+		MaxTime: 55 * time.Millisecond,
+	})
+	ast.True(res.OK())
+	ast.Equal(6, res.Steps)
+	ast.Equal(60*time.Millisecond, res.Elapsed)
+}
+
+func Test_Simulate_violation(t *testing.T) {
+	ast := assert.New(t)
+	//
+	a := ringAlgorithm
+	a.Check = nil
+	a.New = func(id, n int, params Params) Node {
+		// 每个 node 都以为自己持有 token
+		return &ring{rounds: 1, holding: true}
+	}
+	res := Simulate(a, Config{Nodes: 3})
+	ast.False(res.OK())
+	ast.Equal("第 0 步：2 个 node 同时持有 token", res.Violation, "node 0 已经把 token 发了出去")
+	//
+	a = ringAlgorithm
+	a.New = func(id, n int, params Params) Node {
+		return nodeFunc{start: func(*Context) {}}
+	}
+	a.Invariant = nil
+	ast.Equal("token 没有回到 node 0", Simulate(a, Config{Nodes: 3}).Violation)
+}
+
+func Test_Simulate_lossRate(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := Config{
+		Nodes:    3,
+		Params:   Params{"rounds": "100"},
+		Latency:  reliablechannel.Fixed(10 * time.Millisecond),
+		LossRate: 0.1,
+		Seed:     1,
+	}
+	res := Simulate(ringAlgorithm, c)
+	ast.Equal(1, res.Metrics.Get("messages.lost"), "token 丢失以后，环上就没有 message 了")
+	ast.True(res.Metrics.Get("rounds") < 100)
+	ast.Contains(res.Trace[len(res.Trace)-1], "丢失 P")
+	ast.Equal(res.Trace, Simulate(ringAlgorithm, c).Trace)
+}
+
+func Test_simTransport_fifo(t *testing.T) {
+	ast := assert.New(t)
+	//
+	var got []int
+	a := Algorithm{
+		Name: "fifo",
+		New: func(id, n int, params Params) Node {
+			return nodeFunc{
+				start: func(ctx *Context) {
+					if ctx.ID() == 0 {
+						for i := 0; i < 20; i++ {
+							ctx.Send(1, "seq", i)
+						}
+					}
+				},
+				handle: func(ctx *Context, m Message) {
+					got = append(got, m.Payload.(int))
+				},
+			}
+		},
+	}
+	Simulate(a, Config{Nodes: 2, Latency: reliablechannel.Uniform{Max: 100 * time.Millisecond}, Seed: 3})
+	ast.Equal(20, len(got))
+	for i, v := range got {
+		ast.Equal(i, v, "同一对 node 之间的 message 按照发送的顺序送达")
+	}
+}
+
+type nodeFunc struct {
+	start  func(ctx *Context)
+	handle func(ctx *Context, m Message)
+}
+
+func (n nodeFunc) Start(ctx *Context)             { n.start(ctx) }
+func (n nodeFunc) Handle(ctx *Context, m Message) { n.handle(ctx, m) }
+
+func Test_Run(t *testing.T) {
+	ast := assert.New(t)
+	//
+	_, err := Run("no-such-algorithm", Config{Nodes: 3})
+	ast.Equal(`core: unknown algorithm "no-such-algorithm"`, err.Error())
+}
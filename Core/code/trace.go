@@ -0,0 +1,52 @@
+package core
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceLog 是可以在多个 goroutine 中使用的 Tracer
+// 每个事件记录成一行 "[经过的时间] P<id> 事件"。模拟器使用虚拟时钟，
+// 运行在真实时间上的程序使用 sim.Env{}，两者的 trace 格式相同，都可以用 dalg diff 比较。
+type TraceLog struct {
+	mutex sync.Mutex
+	clock Clock
+	start time.Time
+	lines []string
+}
+
+// NewTraceLog 返回从 clock 的当前时间开始计时的 TraceLog
+func NewTraceLog(clock Clock) *TraceLog {
+	return &TraceLog{clock: clock, start: clock.Now()}
+}
+
+// Trace 实现了 Tracer 接口
+func (t *TraceLog) Trace(node int, event string) {
+	line := fmt.Sprintf("[%s] P%d %s", t.clock.Since(t.start), node, strings.TrimSpace(event))
+	t.mutex.Lock()
+	t.lines = append(t.lines, line)
+	t.mutex.Unlock()
+}
+
+// Tracef 与 Trace 一样，只是事件由 format 和 args 生成
+func (t *TraceLog) Tracef(node int, format string, args ...interface{}) {
+	t.Trace(node, fmt.Sprintf(format, args...))
+}
+
+// Lines 返回目前为止记录的所有事件
+func (t *TraceLog) Lines() []string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return append([]string(nil), t.lines...)
+}
+
+// WriteFile 把所有的事件写入 path，每行一个，path 为空时什么也不做
+func (t *TraceLog) WriteFile(path string) error {
+	if path == "" {
+		return nil
+	}
+	return ioutil.WriteFile(path, []byte(strings.Join(append(t.Lines(), ""), "\n")), 0644)
+}
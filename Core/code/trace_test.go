@@ -0,0 +1,32 @@
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_TraceLog(t *testing.T) {
+	ast := assert.New(t)
+	//
+	clock := sim.NewVirtual(time.Unix(100, 0))
+	log := NewTraceLog(clock)
+	log.Trace(1, " 开始 ")
+	clock.Advance(15 * time.Millisecond)
+	log.Tracef(2, "收到 %d 条", 3)
+	ast.Equal([]string{"[0s] P1 开始", "[15ms] P2 收到 3 条"}, log.Lines())
+	//
+	dir, err := ioutil.TempDir("", "trace")
+	ast.Nil(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "a.trace")
+	ast.Nil(log.WriteFile(path))
+	data, _ := ioutil.ReadFile(path)
+	ast.Equal("[0s] P1 开始\n[15ms] P2 收到 3 条\n", string(data))
+	ast.Nil(log.WriteFile(""))
+}
@@ -0,0 +1,94 @@
+package epaxos
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Command 是 client 提交的命令
+type Command struct {
+	ID    int // 由 client 分配，在一次模拟中唯一
+	Key   string
+	Write bool
+}
+
+func (c Command) String() string {
+	op := "R"
+	if c.Write {
+		op = "W"
+	}
+	return fmt.Sprintf("%s%d(%s)", op, c.ID, c.Key)
+}
+
+// Conflict 判断两个命令是否冲突，即它们的执行顺序是否会影响结果
+// Conflict 需要是对称的
+type Conflict func(a, b Command) bool
+
+// KeyConflict 认为访问同一个 key，且至少有一个是写的命令互相冲突
+func KeyConflict(a, b Command) bool {
+	return a.Key == b.Key && (a.Write || b.Write)
+}
+
+// AlwaysConflict 认为所有的命令都互相冲突，此时 EPaxos 需要对所有的命令排序
+func AlwaysConflict(a, b Command) bool {
+	return true
+}
+
+// InstanceID 是 instance 的 id，每个 replica 拥有自己的一行 instance
+type InstanceID struct {
+	Replica int
+	Slot    int
+}
+
+func (id InstanceID) String() string {
+	return fmt.Sprintf("R%d.%d", id.Replica, id.Slot)
+}
+
+func (id InstanceID) less(other InstanceID) bool {
+	if id.Replica != other.Replica {
+		return id.Replica < other.Replica
+	}
+	return id.Slot < other.Slot
+}
+
+// deps 是按照 InstanceID 排序的依赖集合
+type deps []InstanceID
+
+// union 返回 d 与 other 的并集
+func (d deps) union(other deps) deps {
+	res := make(deps, 0, len(d)+len(other))
+	i, j := 0, 0
+	for i < len(d) || j < len(other) {
+		switch {
+		case j == len(other) || (i < len(d) && d[i].less(other[j])):
+			res = append(res, d[i])
+			i++
+		case i == len(d) || other[j].less(d[i]):
+			res = append(res, other[j])
+			j++
+		default:
+			res = append(res, d[i])
+			i++
+			j++
+		}
+	}
+	return res
+}
+
+func (d deps) equal(other deps) bool {
+	if len(d) != len(other) {
+		return false
+	}
+	for i := range d {
+		if d[i] != other[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (d deps) sort() {
+	sort.Slice(d, func(i, j int) bool {
+		return d[i].less(d[j])
+	})
+}
@@ -0,0 +1,33 @@
+package epaxos
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_KeyConflict(t *testing.T) {
+	ast := assert.New(t)
+	//
+	ast.True(KeyConflict(Command{Key: "a", Write: true}, Command{Key: "a"}))
+	ast.False(KeyConflict(Command{Key: "a"}, Command{Key: "a"}))
+	ast.False(KeyConflict(Command{Key: "a", Write: true}, Command{Key: "b", Write: true}))
+	ast.True(AlwaysConflict(Command{Key: "a"}, Command{Key: "b"}))
+	ast.Equal("W1(a)", Command{ID: 1, Key: "a", Write: true}.String())
+}
+
+func Test_deps(t *testing.T) {
+	ast := assert.New(t)
+	//
+	a := deps{{Replica: 0, Slot: 1}, {Replica: 1, Slot: 0}}
+	b := deps{{Replica: 0, Slot: 0}, {Replica: 1, Slot: 0}, {Replica: 2, Slot: 3}}
+	ast.Equal(deps{{0, 0}, {0, 1}, {1, 0}, {2, 3}}, a.union(b))
+	ast.Equal(a, a.union(nil))
+	ast.True(a.equal(deps{{0, 1}, {1, 0}}))
+	ast.False(a.equal(b))
+	//
+	c := deps{{2, 0}, {0, 5}, {0, 1}}
+	c.sort()
+	ast.Equal(deps{{0, 1}, {0, 5}, {2, 0}}, c)
+	ast.Equal("R2.0", c[2].String())
+}
@@ -0,0 +1,162 @@
+package epaxos
+
+import (
+	"fmt"
+	"time"
+
+	core "github.com/aQuaYi/Distributed-Algorithms/Core/code"
+)
+
+func init() {
+	params := map[string]string{
+		"commands": "每个 replica 提交的命令数量，默认为 3",
+		"interval": "同一个 replica 提交命令的间隔，默认为 100ms",
+		"keys":     "命令访问的 key 的数量，越少越容易冲突，默认为 3",
+	}
+	core.Register(core.Algorithm{
+		Name:    "epaxos",
+		Kind:    "consensus",
+		Summary: "EPaxos，每个 replica 都是自己收到的命令的 command leader",
+		Params:  params,
+		New: newCoreReplica(func(id, n int) node {
+			return newReplica(id, n, KeyConflict)
+		}),
+		Invariant: consistentOrder,
+		Check:     allExecuted,
+	})
+	core.Register(core.Algorithm{
+		Name:    "multipaxos",
+		Kind:    "consensus",
+		Summary: "leader 固定为 replica 0 的 Multi-Paxos，作为 EPaxos 的对照",
+		Params:  params,
+		New: newCoreReplica(func(id, n int) node {
+			return newPaxosNode(id, n, 0)
+		}),
+		Invariant: consistentOrder,
+		Check:     allExecuted,
+	})
+}
+
+// coreReplica 把 Replica 和 paxosNode 接到 core 上
+// 每个 replica 每隔 interval 提交一个写命令，共提交 commands 个，key 从 keys 个中随机选择。
+type coreReplica struct {
+	node     node
+	ctx      *core.Context
+	commands int
+	interval time.Duration
+	keys     int
+	proposed map[int]time.Time // 在本地提交的命令的提交时间
+	commits  int
+}
+
+func newCoreReplica(newNode func(id, n int) node) func(id, n int, params core.Params) core.Node {
+	return func(id, n int, params core.Params) core.Node {
+		return &coreReplica{
+			node:     newNode(id, n),
+			commands: params.Int("commands", 3),
+			interval: params.Duration("interval", 100*time.Millisecond),
+			keys:     params.Int("keys", 3),
+			proposed: make(map[int]time.Time, 16),
+		}
+	}
+}
+
+func (r *coreReplica) Start(ctx *core.Context) {
+	r.ctx = ctx
+	r.propose(0)
+}
+
+func (r *coreReplica) Handle(ctx *core.Context, msg core.Message) {
+	r.node.handle(msg.Payload.(message), r)
+}
+
+// propose 提交第 round 个命令，然后在 interval 以后提交下一个
+func (r *coreReplica) propose(round int) {
+	if round == r.commands {
+		return
+	}
+	cmd := Command{
+		ID:    round*r.ctx.Size() + r.ctx.ID(),
+		Key:   fmt.Sprintf("k%d", r.ctx.Rand().Intn(r.keys)),
+		Write: true,
+	}
+	r.proposed[cmd.ID] = r.ctx.Now()
+	r.ctx.Tracef("提交 %s", cmd)
+	r.node.propose(cmd, r)
+	r.ctx.AfterFunc(r.interval, func() {
+		r.propose(round + 1)
+	})
+}
+
+// 以下实现了 network 接口
+
+func (r *coreReplica) send(m message) {
+	r.ctx.Send(m.to, m.kind.String(), m)
+}
+
+func (r *coreReplica) broadcast(m message) {
+	for to := 0; to < r.ctx.Size(); to++ {
+		if to != m.from {
+			m.to = to
+			r.send(m)
+		}
+	}
+}
+
+func (r *coreReplica) committed(replica int, cmd Command, fast bool) {
+	r.commits++
+	r.ctx.Count("commits", 1)
+	if fast {
+		r.ctx.Count("commits.fast", 1)
+	}
+	r.ctx.Count("commit.ms", int(r.ctx.Clock().Since(r.proposed[cmd.ID])/time.Millisecond))
+	r.ctx.Tracef("%s 被 commit", cmd)
+}
+
+// consistentOrder 检查所有的 replica 以同样的顺序执行了冲突的命令
+// 所有的命令都是写命令，访问同一个 key 就会冲突，
+// 所以每个 replica 在每个 key 上执行的命令，都应该是同一个序列的前缀。
+func consistentOrder(nodes []core.Node) error {
+	longest := make(map[string][]Command, 8)
+	owner := make(map[string]int, 8)
+	for id, n := range nodes {
+		perKey := make(map[string][]Command, 8)
+		for _, cmd := range n.(*coreReplica).node.executed() {
+			perKey[cmd.Key] = append(perKey[cmd.Key], cmd)
+		}
+		for key, order := range perKey {
+			other := longest[key]
+			short, long := order, other
+			if len(short) > len(long) {
+				short, long = long, short
+			}
+			for i := range short {
+				if short[i].ID != long[i].ID {
+					return fmt.Errorf("ExecutionConsistency: R%d 和 R%d 在 %s 上执行的第 %d 个命令分别是 %s 和 %s",
+						id, owner[key], key, i, order[i], other[i])
+				}
+			}
+			if len(order) > len(other) {
+				longest[key], owner[key] = order, id
+			}
+		}
+	}
+	return nil
+}
+
+func allExecuted(nodes []core.Node, _ *core.Metrics) error {
+	total := 0
+	for _, n := range nodes {
+		total += n.(*coreReplica).commands
+	}
+	for id, n := range nodes {
+		r := n.(*coreReplica)
+		if r.commits < r.commands {
+			return fmt.Errorf("liveness: R%d 提交的 %d 个命令中，只有 %d 个被 commit", id, r.commands, r.commits)
+		}
+		if executed := len(r.node.executed()); executed < total {
+			return fmt.Errorf("liveness: R%d 只执行了 %d 个命令中的 %d 个", id, total, executed)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package epaxos
+
+import (
+	"testing"
+	"time"
+
+	core "github.com/aQuaYi/Distributed-Algorithms/Core/code"
+	reliablechannel "github.com/aQuaYi/Distributed-Algorithms/Reliable-Channel/code"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_core_consensus(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := core.Config{
+		Nodes:   5,
+		Params:  core.Params{"commands": "4", "interval": "5ms", "keys": "2"},
+		Latency: reliablechannel.Uniform{Min: time.Millisecond, Max: 10 * time.Millisecond},
+	}
+	for _, name := range []string{"epaxos", "multipaxos"} {
+		for seed := int64(0); seed < 10; seed++ {
+			c.Seed = seed
+			res, err := core.Run(name, c)
+			ast.Nil(err)
+			ast.True(res.OK(), "%s seed %d: %s", name, seed, res.Violation)
+			ast.Equal(20, res.Metrics.Get("commits"))
+		}
+	}
+}
+
+func Test_core_fastPath(t *testing.T) {
+	ast := assert.New(t)
+	// key 足够多，命令之间没有冲突，所有的命令都走 fast path
+	c := core.Config{
+		Nodes:   5,
+		Params:  core.Params{"commands": "1", "keys": "1000000"},
+		Latency: reliablechannel.Fixed(10 * time.Millisecond),
+		Seed:    1,
+	}
+	res, _ := core.Run("epaxos", c)
+	ast.True(res.OK(), res.Violation)
+	ast.Equal(5, res.Metrics.Get("commits.fast"))
+	// 每个命令 4 条 preAccept、4 条 preAcceptOK 和 4 条 commit
+	ast.Equal(5*3*4, res.Metrics.Get("messages"))
+	// 所有的命令都冲突时，同时提交的命令互相依赖，需要 slow path
+	c.Params = core.Params{"commands": "1", "keys": "1"}
+	res, _ = core.Run("epaxos", c)
+	ast.True(res.OK(), res.Violation)
+	ast.True(res.Metrics.Get("commits.fast") < 5)
+}
+
+func Test_consistentOrder(t *testing.T) {
+	ast := assert.New(t)
+	//
+	a, b := newReplica(0, 2, KeyConflict), newReplica(1, 2, KeyConflict)
+	nodes := []core.Node{&coreReplica{node: a}, &coreReplica{node: b}}
+	x, y := Command{ID: 1, Key: "k", Write: true}, Command{ID: 2, Key: "k", Write: true}
+	a.order = []Command{x, y}
+	b.order = []Command{x}
+	ast.Nil(consistentOrder(nodes))
+	b.order = []Command{y}
+	ast.NotNil(consistentOrder(nodes))
+}
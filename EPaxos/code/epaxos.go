@@ -0,0 +1,194 @@
+package epaxos
+
+import "time"
+
+type status int
+
+// instance 的状态
+const (
+	preAccepted status = iota
+	accepted
+	committed
+	executed
+)
+
+// instance 是 replica 对某个命令的记录
+type instance struct {
+	cmd    Command
+	seq    int
+	deps   deps
+	status status
+}
+
+// leaderState 是 command leader 为自己的 instance 记录的投票情况
+type leaderState struct {
+	origSeq   int // PreAccept 时的 seq 和 deps
+	origDeps  deps
+	seq       int // 合并了所有 preAcceptOK 的 seq 和 deps
+	deps      deps
+	replies   int  // 收到的 preAcceptOK 的数量，包括 command leader 自己
+	same      bool // 所有的 preAcceptOK 都与 origSeq 和 origDeps 相同
+	acceptOKs int
+	decided   bool // 已经决定了走 fast path 还是 slow path
+}
+
+// Replica 是 EPaxos 中的 replica
+// 每个 replica 都可以作为 command leader，为 client 在本地提交的命令开启一个 instance：
+//  1. PreAccept：command leader 根据本地已知的冲突命令，计算命令的 seq 和 deps，发送给其他 replica。
+//     其他 replica 用自己已知的冲突命令补充 seq 和 deps 后回复
+//  2. 如果 fast quorum 的回复都没有改变 seq 和 deps，命令直接 commit，只需要一轮通信
+//  3. 否则，command leader 合并所有的回复，用 Paxos 的 Accept 让多数派接受，再 commit
+//
+// 互不冲突的命令之间没有依赖，所以可以各自在一轮通信内 commit，不需要经过 leader。
+// 本 demo 没有实现 replica 故障后的恢复（Explicit Prepare），所以也不需要 ballot。
+type Replica struct {
+	id, n     int
+	conflict  Conflict
+	next      int // 下一个 instance 的 slot
+	instances map[InstanceID]*instance
+	leading   map[InstanceID]*leaderState
+	order     []Command // 按顺序执行过的命令
+}
+
+// NewEPaxos 返回在 latency 描述的网络中运行 EPaxos 的模拟
+// latency[i][j] 是从 replica i 到 replica j 的单程延迟，conflict 决定了哪些命令需要排序
+func NewEPaxos(latency [][]time.Duration, conflict Conflict) *Simulation {
+	s := newSimulation(latency)
+	for i := range s.nodes {
+		s.nodes[i] = newReplica(i, len(latency), conflict)
+	}
+	return s
+}
+
+func newReplica(id, n int, conflict Conflict) *Replica {
+	return &Replica{
+		id:        id,
+		n:         n,
+		conflict:  conflict,
+		instances: make(map[InstanceID]*instance, 64),
+		leading:   make(map[InstanceID]*leaderState, 16),
+	}
+}
+
+// fastQuorum 返回 fast path 需要的回复数量，包括 command leader 自己
+// n = 2F+1 时，是 F + ⌊(F+1)/2⌋
+func (r *Replica) fastQuorum() int {
+	f := r.n / 2
+	return f + (f+1)/2
+}
+
+// slowQuorum 返回 Accept 需要的回复数量，包括 command leader 自己
+func (r *Replica) slowQuorum() int {
+	return r.n/2 + 1
+}
+
+// attributes 返回 cmd 相对于本地已知的 instance 的 seq 和 deps
+func (r *Replica) attributes(self InstanceID, cmd Command) (int, deps) {
+	seq, ds := 0, deps{}
+	for id, inst := range r.instances {
+		if id == self || !r.conflict(cmd, inst.cmd) {
+			continue
+		}
+		ds = append(ds, id)
+		if inst.seq > seq {
+			seq = inst.seq
+		}
+	}
+	ds.sort()
+	return seq + 1, ds
+}
+
+func (r *Replica) propose(cmd Command, s network) {
+	id := InstanceID{Replica: r.id, Slot: r.next}
+	r.next++
+	seq, ds := r.attributes(id, cmd)
+	r.instances[id] = &instance{cmd: cmd, seq: seq, deps: ds, status: preAccepted}
+	r.leading[id] = &leaderState{origSeq: seq, origDeps: ds, seq: seq, deps: ds, replies: 1, same: true}
+	s.broadcast(message{kind: preAccept, from: r.id, id: id, cmd: cmd, seq: seq, deps: ds})
+	r.tryDecide(id, s)
+}
+
+func (r *Replica) handle(m message, s network) {
+	switch m.kind {
+	case preAccept:
+		seq, ds := r.attributes(m.id, m.cmd)
+		if m.seq > seq {
+			seq = m.seq
+		}
+		ds = ds.union(m.deps)
+		r.update(m.id, m.cmd, seq, ds, preAccepted)
+		s.send(message{kind: preAcceptOK, from: r.id, to: m.from, id: m.id, seq: seq, deps: ds})
+	case preAcceptOK:
+		ls := r.leading[m.id]
+		if ls.decided {
+			return
+		}
+		ls.replies++
+		if m.seq != ls.origSeq || !m.deps.equal(ls.origDeps) {
+			ls.same = false
+		}
+		if m.seq > ls.seq {
+			ls.seq = m.seq
+		}
+		ls.deps = ls.deps.union(m.deps)
+		r.tryDecide(m.id, s)
+	case accept:
+		r.update(m.id, m.cmd, m.seq, m.deps, accepted)
+		s.send(message{kind: acceptOK, from: r.id, to: m.from, id: m.id})
+	case acceptOK:
+		ls := r.leading[m.id]
+		ls.acceptOKs++
+		if ls.acceptOKs == r.slowQuorum() {
+			r.commit(m.id, false, s)
+		}
+	case commit:
+		r.update(m.id, m.cmd, m.seq, m.deps, committed)
+		r.execute()
+	}
+}
+
+// update 更新本地记录的 instance，已经 commit 的 instance 不会再改变
+func (r *Replica) update(id InstanceID, cmd Command, seq int, ds deps, st status) {
+	inst, ok := r.instances[id]
+	if !ok {
+		inst = &instance{}
+		r.instances[id] = inst
+	} else if inst.status >= committed {
+		return
+	}
+	inst.cmd, inst.seq, inst.deps, inst.status = cmd, seq, ds, st
+}
+
+// tryDecide 在收到 fast quorum 的回复以后，决定走 fast path 还是 slow path
+func (r *Replica) tryDecide(id InstanceID, s network) {
+	ls := r.leading[id]
+	if ls.decided || ls.replies < r.fastQuorum() {
+		return
+	}
+	ls.decided = true
+	inst := r.instances[id]
+	inst.seq, inst.deps = ls.seq, ls.deps
+	if ls.same {
+		r.commit(id, true, s)
+		return
+	}
+	inst.status = accepted
+	ls.acceptOKs = 1
+	s.broadcast(message{kind: accept, from: r.id, id: id, cmd: inst.cmd, seq: ls.seq, deps: ls.deps})
+	if ls.acceptOKs == r.slowQuorum() {
+		r.commit(id, false, s)
+	}
+}
+
+// commit 由 command leader 调用，通知所有的 replica
+func (r *Replica) commit(id InstanceID, fast bool, s network) {
+	inst := r.instances[id]
+	inst.status = committed
+	s.committed(r.id, inst.cmd, fast)
+	s.broadcast(message{kind: commit, from: r.id, id: id, cmd: inst.cmd, seq: inst.seq, deps: inst.deps})
+	r.execute()
+}
+
+func (r *Replica) executed() []Command {
+	return r.order
+}
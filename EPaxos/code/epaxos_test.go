@@ -0,0 +1,178 @@
+package epaxos
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	spec "github.com/aQuaYi/Distributed-Algorithms/Spec/code"
+	"github.com/stretchr/testify/assert"
+)
+
+// checkOrder 检查所有的 replica 都执行了 total 个命令，并且冲突的命令在所有 replica 上的执行顺序相同
+func checkOrder(ast *assert.Assertions, s *Simulation, total int, conflict Conflict) {
+	first := s.Executed(0)
+	ast.Equal(total, len(first))
+	position := make(map[int]int, len(first))
+	for i, c := range first {
+		position[c.ID] = i
+	}
+	for r := 1; r < s.Size(); r++ {
+		order := s.Executed(r)
+		ast.Equal(total, len(order))
+		for i := range order {
+			for j := i + 1; j < len(order); j++ {
+				if conflict(order[i], order[j]) {
+					ast.True(position[order[i].ID] < position[order[j].ID],
+						"R%d 中 %s 和 %s 的执行顺序与 R0 不同", r, order[i], order[j])
+				}
+			}
+		}
+	}
+}
+
+// orderViolation 与 checkOrder 检查同样的性质，但是返回违反的性质，用于 spec.Quick
+func orderViolation(s *Simulation, total int, conflict Conflict) error {
+	first := s.Executed(0)
+	position := make(map[int]int, len(first))
+	for i, c := range first {
+		position[c.ID] = i
+	}
+	for r := 0; r < s.Size(); r++ {
+		order := s.Executed(r)
+		if len(order) != total {
+			return &spec.Violation{Property: "AllExecuted", Reason: fmt.Sprintf("R%d 执行了 %d 个命令，提交了 %d 个", r, len(order), total)}
+		}
+		for i := range order {
+			for j := i + 1; j < len(order); j++ {
+				if conflict(order[i], order[j]) && position[order[i].ID] > position[order[j].ID] {
+					return &spec.Violation{Property: "ConsistentOrder", Reason: fmt.Sprintf("R%d 中 %s 和 %s 的执行顺序与 R0 不同", r, order[i], order[j])}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// quickSimulation 从 choices 中取出 replica 的数量、任意的单程延迟和一组命令，
+// 用 newSimulation 运行以后，检查所有的命令都被执行了，并且冲突的命令在所有 replica 上的执行顺序相同
+func quickSimulation(newSimulation func(latency [][]time.Duration, choices *spec.Choices) (*Simulation, Conflict)) func(*spec.Choices) error {
+	return func(choices *spec.Choices) error {
+		n := 3 + 2*choices.Intn(3)
+		latency := make([][]time.Duration, n)
+		for i := range latency {
+			latency[i] = make([]time.Duration, n)
+			for j := range latency[i] {
+				if i != j {
+					latency[i][j] = time.Duration(choices.Range(1, 200)) * time.Millisecond
+				}
+			}
+		}
+		s, conflict := newSimulation(latency, choices)
+		total := choices.Range(1, 30)
+		for i := 0; i < total; i++ {
+			at := time.Duration(choices.Intn(500)) * time.Millisecond
+			cmd := Command{ID: i, Key: fmt.Sprint(choices.Intn(3)), Write: choices.Bool()}
+			s.Propose(at, choices.Intn(n), cmd)
+		}
+		s.Run()
+		return orderViolation(s, total, conflict)
+	}
+}
+
+func Test_EPaxos_quick(t *testing.T) {
+	ast := assert.New(t)
+	//
+	res := spec.Quick(spec.QuickConfig{Seed: 1, Runs: 300}, quickSimulation(func(latency [][]time.Duration, choices *spec.Choices) (*Simulation, Conflict) {
+		conflict := KeyConflict
+		if choices.Bool() {
+			conflict = AlwaysConflict
+		}
+		return NewEPaxos(latency, conflict), conflict
+	}))
+	ast.True(res.OK(), res.String())
+}
+
+func Test_MultiPaxos_quick(t *testing.T) {
+	ast := assert.New(t)
+	//
+	res := spec.Quick(spec.QuickConfig{Seed: 1, Runs: 300}, quickSimulation(func(latency [][]time.Duration, choices *spec.Choices) (*Simulation, Conflict) {
+		return NewMultiPaxos(latency, choices.Intn(len(latency))), AlwaysConflict
+	}))
+	ast.True(res.OK(), res.String())
+}
+
+func Test_EPaxos_fastPath(t *testing.T) {
+	ast := assert.New(t)
+	//
+	d := 10 * time.Millisecond
+	s := NewEPaxos(UniformLatency(3, d), KeyConflict)
+	for i := 0; i < 9; i++ {
+		s.Propose(0, i%3, Command{ID: i, Key: fmt.Sprint(i), Write: true})
+	}
+	s.Run()
+	sum := s.Summary()
+	// 互不冲突的命令都只需要一轮通信
+	ast.Equal(9, sum.Commits)
+	ast.Equal(9, sum.FastPath)
+	ast.Equal(2*d, sum.Max)
+	checkOrder(ast, s, 9, KeyConflict)
+}
+
+func Test_EPaxos_slowPath(t *testing.T) {
+	ast := assert.New(t)
+	//
+	d := 10 * time.Millisecond
+	s := NewEPaxos(UniformLatency(5, d), AlwaysConflict)
+	for r := 0; r < 5; r++ {
+		s.Propose(0, r, Command{ID: r, Key: "x", Write: true})
+	}
+	s.Run()
+	sum := s.Summary()
+	ast.Equal(5, sum.Commits)
+	// 同时提交的冲突命令，互相看到了对方，需要第二轮通信
+	ast.True(sum.FastPath < 5)
+	ast.Equal(4*d, sum.Max)
+	checkOrder(ast, s, 5, AlwaysConflict)
+}
+
+func Test_EPaxos_randomWorkload(t *testing.T) {
+	ast := assert.New(t)
+	//
+	r := rand.New(rand.NewSource(1))
+	for _, conflict := range []Conflict{KeyConflict, AlwaysConflict} {
+		s := NewEPaxos(GeoLatency(), conflict)
+		total := 200
+		for i := 0; i < total; i++ {
+			at := time.Duration(r.Intn(2000)) * time.Millisecond
+			cmd := Command{ID: i, Key: fmt.Sprint(r.Intn(5)), Write: r.Intn(2) == 0}
+			s.Propose(at, r.Intn(s.Size()), cmd)
+		}
+		s.Run()
+		ast.Equal(total, s.Summary().Commits)
+		checkOrder(ast, s, total, conflict)
+	}
+}
+
+func Test_Replica_execute(t *testing.T) {
+	ast := assert.New(t)
+	//
+	a, b, c := InstanceID{0, 0}, InstanceID{1, 0}, InstanceID{2, 0}
+	r := &Replica{instances: map[InstanceID]*instance{
+		a: {cmd: Command{ID: 1}, seq: 2, deps: deps{b}, status: committed},
+		b: {cmd: Command{ID: 2}, seq: 1, deps: deps{a, c}, status: committed},
+		c: {cmd: Command{ID: 3}, seq: 5, deps: nil, status: preAccepted},
+	}}
+	// c 还没有 commit，依赖它的 a 和 b 都不能执行
+	r.execute()
+	ast.Empty(r.order)
+	// a 和 b 互相依赖，按照 seq 执行；c 被它们依赖，先执行
+	r.instances[c].status = committed
+	r.execute()
+	ast.Equal([]Command{{ID: 3}, {ID: 2}, {ID: 1}}, r.order)
+	ast.Equal(executed, r.instances[a].status)
+	// 已经执行过的 instance 不会再执行
+	r.execute()
+	ast.Equal(3, len(r.order))
+}
@@ -0,0 +1,115 @@
+package epaxos
+
+import "sort"
+
+// execute 执行所有可以执行的 instance
+//
+// 一个 committed 的 instance 可以执行，当且仅当从它出发，沿着 deps 能到达的 instance 都已经 commit。
+// 依赖图中可能有环，所以用 Tarjan 算法找出强连通分量：Tarjan 按照逆拓扑序输出强连通分量，
+// 也就是被依赖的分量先输出，正好是执行的顺序。同一个分量中的 instance 按照 seq 排序，
+// seq 相同时按照 InstanceID 排序。所有的 replica 看到的依赖图相同，所以执行顺序也相同。
+func (r *Replica) execute() {
+	ids := make([]InstanceID, 0, len(r.instances))
+	for id, inst := range r.instances {
+		if inst.status == committed {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].less(ids[j])
+	})
+	for _, id := range ids {
+		if r.instances[id].status == committed {
+			r.executeFrom(id)
+		}
+	}
+}
+
+// tarjan 记录了一次 Tarjan 算法的状态
+type tarjan struct {
+	r       *Replica
+	index   int
+	indexOf map[InstanceID]int
+	lowOf   map[InstanceID]int
+	stack   []InstanceID
+	onStack map[InstanceID]bool
+	sccs    [][]InstanceID
+	blocked bool // 遇到了还没有 commit 的 instance
+}
+
+// executeFrom 执行 id 和它依赖的 instance，如果它们都已经 commit 的话
+func (r *Replica) executeFrom(id InstanceID) {
+	t := &tarjan{
+		r:       r,
+		indexOf: make(map[InstanceID]int),
+		lowOf:   make(map[InstanceID]int),
+		onStack: make(map[InstanceID]bool),
+	}
+	t.visit(id)
+	if t.blocked {
+		return
+	}
+	for _, scc := range t.sccs {
+		sort.Slice(scc, func(i, j int) bool {
+			a, b := r.instances[scc[i]], r.instances[scc[j]]
+			if a.seq != b.seq {
+				return a.seq < b.seq
+			}
+			return scc[i].less(scc[j])
+		})
+		for _, id := range scc {
+			inst := r.instances[id]
+			if inst.status == committed {
+				inst.status = executed
+				r.order = append(r.order, inst.cmd)
+			}
+		}
+	}
+}
+
+func (t *tarjan) visit(id InstanceID) {
+	inst, ok := t.r.instances[id]
+	if !ok || inst.status < committed {
+		t.blocked = true
+		return
+	}
+	if inst.status == executed {
+		return
+	}
+	t.indexOf[id] = t.index
+	t.lowOf[id] = t.index
+	t.index++
+	t.stack = append(t.stack, id)
+	t.onStack[id] = true
+
+	for _, dep := range inst.deps {
+		if d, ok := t.r.instances[dep]; ok && d.status == executed {
+			continue
+		}
+		if _, visited := t.indexOf[dep]; !visited {
+			t.visit(dep)
+			if t.blocked {
+				return
+			}
+			if t.lowOf[dep] < t.lowOf[id] {
+				t.lowOf[id] = t.lowOf[dep]
+			}
+		} else if t.onStack[dep] && t.indexOf[dep] < t.lowOf[id] {
+			t.lowOf[id] = t.indexOf[dep]
+		}
+	}
+
+	if t.lowOf[id] == t.indexOf[id] {
+		var scc []InstanceID
+		for {
+			top := t.stack[len(t.stack)-1]
+			t.stack = t.stack[:len(t.stack)-1]
+			t.onStack[top] = false
+			scc = append(scc, top)
+			if top == id {
+				break
+			}
+		}
+		t.sccs = append(t.sccs, scc)
+	}
+}
@@ -0,0 +1,41 @@
+package epaxos
+
+import "time"
+
+// Regions 是 GeoLatency 中 replica 所在的区域
+var Regions = []string{"Virginia", "California", "Ireland", "Tokyo", "Sydney"}
+
+// geoRTT 是各个区域之间大致的往返延迟，单位是 ms
+var geoRTT = [][]int{
+	{0, 62, 76, 150, 200},
+	{62, 0, 140, 105, 150},
+	{76, 140, 0, 210, 260},
+	{150, 105, 210, 0, 105},
+	{200, 150, 260, 105, 0},
+}
+
+// GeoLatency 返回分布在 Regions 中 5 个区域的 replica 之间的单程延迟
+func GeoLatency() [][]time.Duration {
+	res := make([][]time.Duration, len(geoRTT))
+	for i, row := range geoRTT {
+		res[i] = make([]time.Duration, len(row))
+		for j, rtt := range row {
+			res[i][j] = time.Duration(rtt) * time.Millisecond / 2
+		}
+	}
+	return res
+}
+
+// UniformLatency 返回 n 个 replica 之间的单程延迟都是 d 的网络
+func UniformLatency(n int, d time.Duration) [][]time.Duration {
+	res := make([][]time.Duration, n)
+	for i := range res {
+		res[i] = make([]time.Duration, n)
+		for j := range res[i] {
+			if i != j {
+				res[i][j] = d
+			}
+		}
+	}
+	return res
+}
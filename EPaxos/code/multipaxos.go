@@ -0,0 +1,103 @@
+package epaxos
+
+import "time"
+
+// paxosNode 是 Multi-Paxos 中的 replica，作为 EPaxos 的对照
+// leader 是固定的，并且已经完成了 Phase 1，所以每个命令只需要 Phase 2：
+// 非 leader 的 replica 把命令转发给 leader，leader 分配 slot 并发送 phase2a，
+// 多数派回复 phase2b 以后，leader 通过 decide 通知所有的 replica。
+type paxosNode struct {
+	id, n   int
+	leader  int
+	next    int              // leader 分配的下一个 slot
+	acks    map[int]int      // leader 为每个 slot 收到的 phase2b 的数量
+	origin  map[int]int      // 每个 slot 的命令是在哪个 replica 上提交的
+	decided map[int]*Command // 已经 decide 的 slot
+	applied int              // 下一个要执行的 slot
+	order   []Command
+	pending map[int]Command // leader 上等待 phase2b 的命令
+}
+
+// NewMultiPaxos 返回在 latency 描述的网络中运行 Multi-Paxos 的模拟，leader 是固定的
+func NewMultiPaxos(latency [][]time.Duration, leader int) *Simulation {
+	s := newSimulation(latency)
+	for i := range s.nodes {
+		s.nodes[i] = newPaxosNode(i, len(latency), leader)
+	}
+	return s
+}
+
+func newPaxosNode(id, n, leader int) *paxosNode {
+	return &paxosNode{
+		id:      id,
+		n:       n,
+		leader:  leader,
+		acks:    make(map[int]int, 64),
+		origin:  make(map[int]int, 64),
+		decided: make(map[int]*Command, 64),
+		pending: make(map[int]Command, 64),
+	}
+}
+
+func (p *paxosNode) propose(cmd Command, s network) {
+	if p.id != p.leader {
+		s.send(message{kind: forward, from: p.id, to: p.leader, cmd: cmd, origin: p.id})
+		return
+	}
+	p.start(cmd, p.id, s)
+}
+
+// start 由 leader 调用，为 cmd 分配 slot，origin 是提交 cmd 的 replica
+func (p *paxosNode) start(cmd Command, origin int, s network) {
+	slot := p.next
+	p.next++
+	p.pending[slot] = cmd
+	p.origin[slot] = origin
+	p.acks[slot] = 1
+	s.broadcast(message{kind: phase2a, from: p.id, cmd: cmd, slot: slot, origin: origin})
+	p.tryDecide(slot, s)
+}
+
+func (p *paxosNode) handle(m message, s network) {
+	switch m.kind {
+	case forward:
+		p.start(m.cmd, m.origin, s)
+	case phase2a:
+		s.send(message{kind: phase2b, from: p.id, to: m.from, slot: m.slot})
+	case phase2b:
+		p.acks[m.slot]++
+		p.tryDecide(m.slot, s)
+	case decide:
+		p.learn(m.slot, m.cmd)
+		if m.origin == p.id {
+			s.committed(p.id, m.cmd, false)
+		}
+	}
+}
+
+func (p *paxosNode) tryDecide(slot int, s network) {
+	cmd, ok := p.pending[slot]
+	if !ok || p.acks[slot] < p.n/2+1 {
+		return
+	}
+	delete(p.pending, slot)
+	origin := p.origin[slot]
+	p.learn(slot, cmd)
+	if origin == p.id {
+		s.committed(p.id, cmd, false)
+	}
+	s.broadcast(message{kind: decide, from: p.id, cmd: cmd, slot: slot, origin: origin})
+}
+
+// learn 记录 slot 被 decide 为 cmd，并按照 slot 的顺序执行
+func (p *paxosNode) learn(slot int, cmd Command) {
+	p.decided[slot] = &cmd
+	for p.decided[p.applied] != nil {
+		p.order = append(p.order, *p.decided[p.applied])
+		p.applied++
+	}
+}
+
+func (p *paxosNode) executed() []Command {
+	return p.order
+}
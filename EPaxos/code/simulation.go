@@ -0,0 +1,253 @@
+package epaxos
+
+import (
+	"container/heap"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type msgKind int
+
+// 枚举了所有的 message 类型
+const (
+	preAccept msgKind = iota
+	preAcceptOK
+	accept
+	acceptOK
+	commit
+	// 以下是 Multi-Paxos 的 message
+	forward
+	phase2a
+	phase2b
+	decide
+)
+
+// message 是 replica 之间传递的消息
+type message struct {
+	kind     msgKind
+	from, to int
+	id       InstanceID
+	cmd      Command
+	seq      int
+	deps     deps
+	slot     int // Multi-Paxos 中命令的 slot
+	origin   int // Multi-Paxos 中提交命令的 replica
+}
+
+func (k msgKind) String() string {
+	return [...]string{"preAccept", "preAcceptOK", "accept", "acceptOK", "commit",
+		"forward", "phase2a", "phase2b", "decide"}[k]
+}
+
+// network 是 replica 发送 message 和报告 commit 的网络
+// Simulation 和 core 的适配器都实现了它
+type network interface {
+	send(m message)
+	// broadcast 把 m 发送给除了 m.from 以外的所有 replica
+	broadcast(m message)
+	// committed 记录 replica 得知了 cmd 被 commit
+	committed(replica int, cmd Command, fast bool)
+}
+
+// node 是由 network 驱动的 replica
+type node interface {
+	// propose 处理 client 在本地提交的命令
+	propose(cmd Command, s network)
+	// handle 处理收到的 message
+	handle(m message, s network)
+	// executed 返回按顺序执行过的命令
+	executed() []Command
+}
+
+// event 是 Simulation 中的事件，msg 为 nil 时，是 client 在 replica 提交 cmd
+type event struct {
+	at      time.Duration
+	order   int // 同一时刻的事件，按照加入的顺序处理
+	replica int
+	cmd     Command
+	msg     *message
+}
+
+type eventQueue []*event
+
+func (q eventQueue) Len() int { return len(q) }
+
+func (q eventQueue) Less(i, j int) bool {
+	if q[i].at != q[j].at {
+		return q[i].at < q[j].at
+	}
+	return q[i].order < q[j].order
+}
+
+func (q eventQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *eventQueue) Push(x interface{}) { *q = append(*q, x.(*event)) }
+
+func (q *eventQueue) Pop() interface{} {
+	old := *q
+	e := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return e
+}
+
+// Commit 记录了一个命令从提交到 commit 的过程
+type Commit struct {
+	Command   Command
+	Replica   int // client 提交命令的 replica
+	Proposed  time.Duration
+	Committed time.Duration // Replica 得知命令被 commit 的时间
+	FastPath  bool          // 只用了一轮通信
+}
+
+// Latency 返回 commit 的延迟
+func (c Commit) Latency() time.Duration {
+	return c.Committed - c.Proposed
+}
+
+// Simulation 是一个离散事件模拟
+// message 在 replica 之间的传递时间由 latency 决定，处理 message 不需要时间，
+// 所以同样的输入总会得到同样的结果。
+type Simulation struct {
+	now      time.Duration
+	latency  [][]time.Duration
+	nodes    []node
+	events   eventQueue
+	order    int
+	proposed map[int]time.Duration // 命令的提交时间
+	commits  []Commit
+	messages int
+}
+
+func newSimulation(latency [][]time.Duration) *Simulation {
+	return &Simulation{
+		latency:  latency,
+		nodes:    make([]node, len(latency)),
+		proposed: make(map[int]time.Duration, 64),
+	}
+}
+
+// Now 返回模拟中的当前时间
+func (s *Simulation) Now() time.Duration {
+	return s.now
+}
+
+// Size 返回 replica 的数量
+func (s *Simulation) Size() int {
+	return len(s.nodes)
+}
+
+func (s *Simulation) push(e *event) {
+	s.order++
+	e.order = s.order
+	heap.Push(&s.events, e)
+}
+
+// Propose 安排 client 在 at 时刻，向 replica 提交 cmd
+func (s *Simulation) Propose(at time.Duration, replica int, cmd Command) {
+	s.push(&event{at: at, replica: replica, cmd: cmd})
+}
+
+// send 发送 m，它会在 latency[m.from][m.to] 以后到达
+func (s *Simulation) send(m message) {
+	s.messages++
+	s.push(&event{at: s.now + s.latency[m.from][m.to], replica: m.to, msg: &m})
+}
+
+// broadcast 把 m 发送给除了 m.from 以外的所有 replica
+func (s *Simulation) broadcast(m message) {
+	for to := range s.nodes {
+		if to != m.from {
+			m.to = to
+			s.send(m)
+		}
+	}
+}
+
+// committed 记录 replica 得知了 cmd 被 commit
+// 只记录 client 提交 cmd 的那个 replica
+func (s *Simulation) committed(replica int, cmd Command, fast bool) {
+	s.commits = append(s.commits, Commit{
+		Command:   cmd,
+		Replica:   replica,
+		Proposed:  s.proposed[cmd.ID],
+		Committed: s.now,
+		FastPath:  fast,
+	})
+}
+
+// Run 处理所有的事件，直到没有 message 在途
+func (s *Simulation) Run() {
+	for s.events.Len() > 0 {
+		e := heap.Pop(&s.events).(*event)
+		s.now = e.at
+		if e.msg == nil {
+			s.proposed[e.cmd.ID] = s.now
+			s.nodes[e.replica].propose(e.cmd, s)
+		} else {
+			s.nodes[e.replica].handle(*e.msg, s)
+		}
+	}
+}
+
+// Commits 返回按照 commit 时间排列的 Commit
+func (s *Simulation) Commits() []Commit {
+	return s.commits
+}
+
+// Executed 返回 replica 按顺序执行过的命令
+func (s *Simulation) Executed(replica int) []Command {
+	return s.nodes[replica].executed()
+}
+
+// Messages 返回发送过的 message 数量
+func (s *Simulation) Messages() int {
+	return s.messages
+}
+
+// Summary 统计了一次模拟的结果
+type Summary struct {
+	Commits    int
+	FastPath   int
+	Mean       time.Duration
+	Max        time.Duration
+	PerReplica []time.Duration // 每个 replica 上提交的命令的平均延迟
+}
+
+// Summary 返回 s 的统计结果
+func (s *Simulation) Summary() Summary {
+	res := Summary{PerReplica: make([]time.Duration, len(s.nodes))}
+	counts := make([]int, len(s.nodes))
+	var total time.Duration
+	for _, c := range s.commits {
+		res.Commits++
+		if c.FastPath {
+			res.FastPath++
+		}
+		l := c.Latency()
+		total += l
+		if l > res.Max {
+			res.Max = l
+		}
+		res.PerReplica[c.Replica] += l
+		counts[c.Replica]++
+	}
+	if res.Commits > 0 {
+		res.Mean = total / time.Duration(res.Commits)
+	}
+	for i := range res.PerReplica {
+		if counts[i] > 0 {
+			res.PerReplica[i] /= time.Duration(counts[i])
+		}
+	}
+	return res
+}
+
+func (s Summary) String() string {
+	perReplica := make([]string, len(s.PerReplica))
+	for i, l := range s.PerReplica {
+		perReplica[i] = fmt.Sprintf("R%d %v", i, l)
+	}
+	return fmt.Sprintf("%d 个命令，%d 个走了 fast path，平均延迟 %v，最大延迟 %v\n各 replica 的平均延迟：%s",
+		s.Commits, s.FastPath, s.Mean, s.Max, strings.Join(perReplica, ", "))
+}
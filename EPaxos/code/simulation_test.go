@@ -0,0 +1,87 @@
+package epaxos
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// proposeSpread 让每个 replica 每隔 interval 提交一个命令，共提交 times 轮
+// 同一轮的命令同时提交，key 决定了它们是否冲突
+func proposeSpread(s *Simulation, times int, interval time.Duration, key func(id int) string) int {
+	id := 0
+	for t := 0; t < times; t++ {
+		for r := 0; r < s.Size(); r++ {
+			s.Propose(time.Duration(t)*interval, r, Command{ID: id, Key: key(id), Write: true})
+			id++
+		}
+	}
+	return id
+}
+
+// 在跨越 5 个区域的网络中，比较 EPaxos 和以 Virginia 为 leader 的 Multi-Paxos 的 commit 延迟
+func Test_compareWithMultiPaxos(t *testing.T) {
+	ast := assert.New(t)
+	//
+	distinct := func(id int) string { return fmt.Sprint(id) }
+	ep := NewEPaxos(GeoLatency(), KeyConflict)
+	total := proposeSpread(ep, 20, 500*time.Millisecond, distinct)
+	ep.Run()
+	mp := NewMultiPaxos(GeoLatency(), 0)
+	proposeSpread(mp, 20, 500*time.Millisecond, distinct)
+	mp.Run()
+	//
+	es, ms := ep.Summary(), mp.Summary()
+	t.Logf("EPaxos：%s", es)
+	t.Logf("Multi-Paxos：%s", ms)
+	ast.Equal(total, es.Commits)
+	ast.Equal(total, ms.Commits)
+	ast.Equal(total, es.FastPath)
+	ast.True(es.Mean < ms.Mean)
+	// 在 leader 所在的区域，两者的延迟相同：都是到最近的多数派的往返时间
+	ast.Equal(ms.PerReplica[0], es.PerReplica[0])
+	// 离 leader 越远，EPaxos 的优势越大
+	for r := 1; r < ep.Size(); r++ {
+		ast.True(es.PerReplica[r] < ms.PerReplica[r], "%s", Regions[r])
+	}
+	//
+	hot := func(id int) string { return "hot" }
+	ep = NewEPaxos(GeoLatency(), KeyConflict)
+	proposeSpread(ep, 20, 500*time.Millisecond, hot)
+	ep.Run()
+	hs := ep.Summary()
+	t.Logf("EPaxos，所有命令都冲突：%s", hs)
+	ast.True(hs.FastPath < total)
+	ast.True(hs.Mean > es.Mean)
+	checkOrder(ast, ep, total, KeyConflict)
+}
+
+func Test_Simulation_deterministic(t *testing.T) {
+	ast := assert.New(t)
+	//
+	run := func() *Simulation {
+		s := NewEPaxos(GeoLatency(), AlwaysConflict)
+		proposeSpread(s, 5, 50*time.Millisecond, func(int) string { return "k" })
+		s.Run()
+		return s
+	}
+	a, b := run(), run()
+	ast.Equal(a.Commits(), b.Commits())
+	ast.Equal(a.Executed(3), b.Executed(3))
+	ast.Equal(a.Messages(), b.Messages())
+}
+
+func Test_MultiPaxos_order(t *testing.T) {
+	ast := assert.New(t)
+	//
+	s := NewMultiPaxos(UniformLatency(3, 10*time.Millisecond), 1)
+	total := proposeSpread(s, 3, 0, func(int) string { return "k" })
+	s.Run()
+	// leader 本地的命令需要一轮通信，其他 replica 还要加上转发和 decide
+	sum := s.Summary()
+	ast.Equal(20*time.Millisecond, sum.PerReplica[1])
+	ast.Equal(40*time.Millisecond, sum.PerReplica[0])
+	checkOrder(ast, s, total, AlwaysConflict)
+}
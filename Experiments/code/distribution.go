@@ -0,0 +1,73 @@
+package experiments
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	reliablechannel "github.com/aQuaYi/Distributed-Algorithms/Reliable-Channel/code"
+)
+
+// Distribution 是有名字的延迟分布，名字出现在报告和图例中
+type Distribution struct {
+	Name    string
+	Latency reliablechannel.Latency
+}
+
+// ParseDistribution 解析延迟分布，名字就是 s 本身：
+//
+//	none                没有延迟
+//	fixed:5ms           固定的延迟
+//	uniform:1ms-10ms    在 [1ms, 10ms) 中均匀分布
+//	lognormal:5ms/0.5   中位数为 5ms，sigma 为 0.5 的对数正态分布
+func ParseDistribution(s string) (Distribution, error) {
+	d := Distribution{Name: s}
+	kind, arg := s, ""
+	if i := strings.Index(s, ":"); i >= 0 {
+		kind, arg = s[:i], s[i+1:]
+	}
+	var err error
+	switch kind {
+	case "none":
+		if arg != "" {
+			err = fmt.Errorf("none takes no argument")
+		}
+	case "fixed":
+		var v time.Duration
+		v, err = time.ParseDuration(arg)
+		d.Latency = reliablechannel.Fixed(v)
+	case "uniform":
+		parts := strings.Split(arg, "-")
+		if len(parts) != 2 {
+			err = fmt.Errorf("uniform should be min-max")
+			break
+		}
+		var u reliablechannel.Uniform
+		if u.Min, err = time.ParseDuration(parts[0]); err != nil {
+			break
+		}
+		if u.Max, err = time.ParseDuration(parts[1]); err == nil && u.Max < u.Min {
+			err = fmt.Errorf("max is less than min")
+		}
+		d.Latency = u
+	case "lognormal":
+		parts := strings.Split(arg, "/")
+		if len(parts) != 2 {
+			err = fmt.Errorf("lognormal should be median/sigma")
+			break
+		}
+		var l reliablechannel.LogNormal
+		if l.Median, err = time.ParseDuration(parts[0]); err != nil {
+			break
+		}
+		l.Sigma, err = strconv.ParseFloat(parts[1], 64)
+		d.Latency = l
+	default:
+		err = fmt.Errorf("unknown distribution %q", kind)
+	}
+	if err != nil {
+		return d, fmt.Errorf("experiments: bad latency %q: %v", s, err)
+	}
+	return d, nil
+}
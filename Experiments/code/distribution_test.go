@@ -0,0 +1,31 @@
+package experiments
+
+import (
+	"testing"
+	"time"
+
+	reliablechannel "github.com/aQuaYi/Distributed-Algorithms/Reliable-Channel/code"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ParseDistribution(t *testing.T) {
+	ast := assert.New(t)
+	//
+	for s, want := range map[string]reliablechannel.Latency{
+		"none":              nil,
+		"fixed:5ms":         reliablechannel.Fixed(5 * time.Millisecond),
+		"uniform:1ms-10ms":  reliablechannel.Uniform{Min: time.Millisecond, Max: 10 * time.Millisecond},
+		"lognormal:5ms/0.5": reliablechannel.LogNormal{Median: 5 * time.Millisecond, Sigma: 0.5},
+	} {
+		d, err := ParseDistribution(s)
+		ast.Nil(err, s)
+		ast.Equal(Distribution{Name: s, Latency: want}, d)
+	}
+	//
+	for _, s := range []string{"", "none:1", "fixed", "uniform:10ms-1ms", "uniform:1ms", "lognormal:5ms", "pareto:1"} {
+		_, err := ParseDistribution(s)
+		ast.Error(err, s)
+	}
+	_, err := ParseDistribution("gauss:1ms")
+	ast.Equal(`experiments: bad latency "gauss:1ms": unknown distribution "gauss"`, err.Error())
+}
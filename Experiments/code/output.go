@@ -0,0 +1,183 @@
+package experiments
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// Columns 是可以画图的列，也是 CSV 中除了参数以外的列
+var Columns = []string{"throughput", "opLatencyMs", "messages", "messagesPerOp", "elapsedMs", "failures"}
+
+// Value 返回 p 中名为 column 的值
+func (p Point) Value(column string) (float64, error) {
+	switch column {
+	case "throughput":
+		return p.Throughput, nil
+	case "opLatencyMs":
+		return p.OpLatency, nil
+	case "messages":
+		return p.Messages, nil
+	case "messagesPerOp":
+		return p.MessagesPerOp, nil
+	case "elapsedMs":
+		return p.Elapsed, nil
+	case "failures":
+		return float64(p.Failures), nil
+	}
+	return 0, fmt.Errorf("experiments: unknown column %q", column)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', 6, 64)
+}
+
+// WriteCSV 把 r 写成 CSV，每个 Point 一行，参数在前，Columns 在后，
+// 最后是所有 Point 中出现过的计数器，按照字母顺序排列，已经作为 Columns 输出的 messages 除外
+func (r *Report) WriteCSV(w io.Writer) error {
+	metrics := r.metricNames()
+	cw := csv.NewWriter(w)
+	header := append([]string{"algorithm", "nodes", "lossRate", "latency", "runs"}, Columns...)
+	if err := cw.Write(append(header, metrics...)); err != nil {
+		return err
+	}
+	for _, p := range r.Points {
+		row := []string{r.Algorithm, strconv.Itoa(p.Nodes), formatFloat(p.LossRate), p.Latency, strconv.Itoa(p.Runs)}
+		for _, c := range Columns {
+			v, _ := p.Value(c)
+			row = append(row, formatFloat(v))
+		}
+		for _, m := range metrics {
+			row = append(row, formatFloat(p.Metrics[m]))
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func (r *Report) metricNames() []string {
+	seen := map[string]bool{"messages": true}
+	var res []string
+	for _, p := range r.Points {
+		for name := range p.Metrics {
+			if !seen[name] {
+				seen[name] = true
+				res = append(res, name)
+			}
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+// WriteJSON 把 r 写成缩进的 JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// series 按照 Point 出现的顺序，把它们分成曲线，每条曲线上的点按照 Nodes 排列
+func (r *Report) series() ([]string, map[string][]Point) {
+	var names []string
+	points := make(map[string][]Point, 8)
+	for _, p := range r.Points {
+		name := p.Series()
+		if _, ok := points[name]; !ok {
+			names = append(names, name)
+		}
+		points[name] = append(points[name], p)
+	}
+	for _, ps := range points {
+		sort.SliceStable(ps, func(i, j int) bool { return ps[i].Nodes < ps[j].Nodes })
+	}
+	return names, points
+}
+
+// WriteGnuplot 写出可以直接交给 gnuplot 的脚本，横轴是 node 的数量，纵轴是 column
+// 数据以 inline data block 的形式写在脚本中，每条曲线一个 block，
+// 所以只需要 gnuplot -p 这一个文件就能画出图
+func (r *Report) WriteGnuplot(w io.Writer, column string) error {
+	if _, err := (Point{}).Value(column); err != nil {
+		return err
+	}
+	names, points := r.series()
+	fmt.Fprintf(w, "set title %q\n", r.Algorithm+" "+column)
+	fmt.Fprintln(w, `set xlabel "nodes"`)
+	fmt.Fprintf(w, "set ylabel %q\n", column)
+	fmt.Fprintln(w, "set key outside")
+	for i, name := range names {
+		fmt.Fprintf(w, "$s%d << EOD\n# %s\n", i, name)
+		for _, p := range points[name] {
+			v, _ := p.Value(column)
+			fmt.Fprintf(w, "%d %s\n", p.Nodes, formatFloat(v))
+		}
+		fmt.Fprintln(w, "EOD")
+	}
+	for i, name := range names {
+		sep := ", \\"
+		if i == len(names)-1 {
+			sep = ""
+		}
+		prefix := "    "
+		if i == 0 {
+			prefix = "plot "
+		}
+		fmt.Fprintf(w, "%s$s%d with linespoints title %q%s\n", prefix, i, name, sep)
+	}
+	return nil
+}
+
+// echartsOption 是 ECharts 折线图的 option
+type echartsOption struct {
+	Title   map[string]string   `json:"title"`
+	Tooltip map[string]string   `json:"tooltip"`
+	Legend  map[string][]string `json:"legend"`
+	XAxis   echartsAxis         `json:"xAxis"`
+	YAxis   echartsAxis         `json:"yAxis"`
+	Series  []echartsSeries     `json:"series"`
+}
+
+type echartsAxis struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+}
+
+type echartsSeries struct {
+	Name string       `json:"name"`
+	Type string       `json:"type"`
+	Data [][2]float64 `json:"data"`
+}
+
+// WriteECharts 写出 ECharts 折线图的 option，横轴是 node 的数量，纵轴是 column
+// 在网页中 echarts.init(dom).setOption(option) 即可画出图
+func (r *Report) WriteECharts(w io.Writer, column string) error {
+	if _, err := (Point{}).Value(column); err != nil {
+		return err
+	}
+	names, points := r.series()
+	option := echartsOption{
+		Title:   map[string]string{"text": r.Algorithm + " " + column},
+		Tooltip: map[string]string{"trigger": "axis"},
+		Legend:  map[string][]string{"data": names},
+		XAxis:   echartsAxis{Type: "value", Name: "nodes"},
+		YAxis:   echartsAxis{Type: "value", Name: column},
+	}
+	for _, name := range names {
+		s := echartsSeries{Name: name, Type: "line"}
+		for _, p := range points[name] {
+			v, _ := p.Value(column)
+			s.Data = append(s.Data, [2]float64{float64(p.Nodes), v})
+		}
+		option.Series = append(option.Series, s)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(option)
+}
@@ -0,0 +1,96 @@
+package experiments
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestReport() *Report {
+	return &Report{
+		Algorithm: "lamport",
+		Seeds:     1,
+		Points: []Point{
+			{Nodes: 4, Latency: "fixed:1ms", Runs: 1, Throughput: 20, Metrics: map[string]float64{"grants": 8}},
+			{Nodes: 2, Latency: "fixed:1ms", Runs: 1, Throughput: 40, Metrics: map[string]float64{"grants": 4, "messages": 12}},
+			{Nodes: 2, LossRate: 0.1, Latency: "fixed:1ms", Runs: 1, Failures: 1, Metrics: map[string]float64{"messages.lost": 1}},
+		},
+	}
+}
+
+func Test_Report_WriteCSV(t *testing.T) {
+	ast := assert.New(t)
+	//
+	var b bytes.Buffer
+	ast.Nil(newTestReport().WriteCSV(&b))
+	rows, err := csv.NewReader(&b).ReadAll()
+	ast.Nil(err)
+	ast.Equal(4, len(rows))
+	ast.Equal([]string{"algorithm", "nodes", "lossRate", "latency", "runs",
+		"throughput", "opLatencyMs", "messages", "messagesPerOp", "elapsedMs", "failures",
+		"grants", "messages.lost"}, rows[0])
+	ast.Equal([]string{"lamport", "2", "0.1", "fixed:1ms", "1", "0", "0", "0", "0", "0", "1", "0", "1"}, rows[3])
+}
+
+func Test_Report_WriteJSON(t *testing.T) {
+	ast := assert.New(t)
+	//
+	var b bytes.Buffer
+	r := newTestReport()
+	ast.Nil(r.WriteJSON(&b))
+	var got Report
+	ast.Nil(json.Unmarshal(b.Bytes(), &got))
+	ast.Equal(*r, got)
+}
+
+func Test_Report_WriteGnuplot(t *testing.T) {
+	ast := assert.New(t)
+	//
+	var b bytes.Buffer
+	ast.Nil(newTestReport().WriteGnuplot(&b, "throughput"))
+	ast.Equal(`set title "lamport throughput"
+set xlabel "nodes"
+set ylabel "throughput"
+set key outside
+$s0 << EOD
+# fixed:1ms loss=0
+2 40
+4 20
+EOD
+$s1 << EOD
+# fixed:1ms loss=0.1
+2 0
+EOD
+plot $s0 with linespoints title "fixed:1ms loss=0", \
+    $s1 with linespoints title "fixed:1ms loss=0.1"
+`, b.String())
+	//
+	ast.Error(newTestReport().WriteGnuplot(&b, "p99"))
+}
+
+func Test_Report_WriteECharts(t *testing.T) {
+	ast := assert.New(t)
+	//
+	var b bytes.Buffer
+	ast.Nil(newTestReport().WriteECharts(&b, "failures"))
+	var option struct {
+		Legend struct{ Data []string }
+		Series []struct {
+			Name string
+			Type string
+			Data [][2]float64
+		}
+	}
+	ast.Nil(json.Unmarshal(b.Bytes(), &option))
+	ast.Equal([]string{"fixed:1ms loss=0", "fixed:1ms loss=0.1"}, option.Legend.Data)
+	ast.Equal([][2]float64{{2, 0}, {4, 0}}, option.Series[0].Data)
+	ast.Equal([][2]float64{{2, 1}}, option.Series[1].Data)
+	ast.Equal("line", option.Series[1].Type)
+	//
+	ast.True(strings.Contains(b.String(), `"type": "value"`))
+	ast.Error(newTestReport().WriteECharts(&b, "p99"))
+}
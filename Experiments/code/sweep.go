@@ -0,0 +1,145 @@
+package experiments
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	core "github.com/aQuaYi/Distributed-Algorithms/Core/code"
+)
+
+// Sweep 描述一组实验：在 Nodes × LossRates × Latencies 的每个组合上，
+// 用 Seeds 个不同的种子运行注册到 core 的算法 Algorithm。
+// 所有的运行都在 core 的虚拟时钟上进行，同样的 Sweep 总会得到同样的 Report。
+type Sweep struct {
+	Algorithm string
+	Params    core.Params
+	Nodes     []int
+	LossRates []float64 // 为空时只有 0
+	Latencies []Distribution
+	Seeds     int           // 每个组合运行的次数，种子是 0 到 Seeds-1，默认为 1
+	MaxTime   time.Duration // 每次运行的模拟时间上限，见 core.Config.MaxTime
+	// Ops 是算法完成一次操作时累加的计数器，用来计算吞吐量，默认为 grants
+	Ops string
+	// Wait 是算法累加的等待时间计数器，单位是毫秒，用来计算每次操作的平均延迟，默认为 wait.ms
+	Wait string
+}
+
+// Point 是一个参数组合上的结果，数值都是所有种子的平均值
+type Point struct {
+	Nodes    int     `json:"nodes"`
+	LossRate float64 `json:"lossRate"`
+	Latency  string  `json:"latency"`
+	Runs     int     `json:"runs"`
+	Failures int     `json:"failures"` // 报告了 Violation 的运行次数
+	// Elapsed 是模拟时间，单位是毫秒
+	Elapsed float64 `json:"elapsedMs"`
+	// Throughput 是每秒模拟时间完成的操作数量
+	Throughput float64 `json:"throughput"`
+	// OpLatency 是每次操作的平均等待时间，单位是毫秒
+	OpLatency float64 `json:"opLatencyMs"`
+	Messages  float64 `json:"messages"`
+	// MessagesPerOp 是每次操作平均需要的 message 数量
+	MessagesPerOp float64            `json:"messagesPerOp"`
+	Metrics       map[string]float64 `json:"metrics"`
+	Violations    []string           `json:"violations,omitempty"`
+}
+
+// Report 是一组实验的全部结果
+type Report struct {
+	Algorithm string  `json:"algorithm"`
+	Seeds     int     `json:"seeds"`
+	Points    []Point `json:"points"`
+}
+
+// ErrNoNodes 表示 Sweep 中没有设置 Nodes
+var ErrNoNodes = errors.New("experiments: sweep needs at least one node count")
+
+// Run 依次运行 s 中的每个组合
+func (s Sweep) Run() (*Report, error) {
+	a, ok := core.Lookup(s.Algorithm)
+	if !ok {
+		return nil, fmt.Errorf("experiments: unknown algorithm %q", s.Algorithm)
+	}
+	if len(s.Nodes) == 0 {
+		return nil, ErrNoNodes
+	}
+	seeds := s.Seeds
+	if seeds <= 0 {
+		seeds = 1
+	}
+	losses := s.LossRates
+	if len(losses) == 0 {
+		losses = []float64{0}
+	}
+	latencies := s.Latencies
+	if len(latencies) == 0 {
+		latencies = []Distribution{{Name: "none"}}
+	}
+	ops, wait := s.Ops, s.Wait
+	if ops == "" {
+		ops = "grants"
+	}
+	if wait == "" {
+		wait = "wait.ms"
+	}
+	report := &Report{Algorithm: s.Algorithm, Seeds: seeds}
+	for _, n := range s.Nodes {
+		for _, loss := range losses {
+			for _, d := range latencies {
+				p := Point{Nodes: n, LossRate: loss, Latency: d.Name, Metrics: make(map[string]float64, 8)}
+				for seed := 0; seed < seeds; seed++ {
+					res := core.Simulate(a, core.Config{
+						Nodes:    n,
+						Params:   s.Params,
+						Seed:     int64(seed),
+						Latency:  d.Latency,
+						LossRate: loss,
+						MaxTime:  s.MaxTime,
+					})
+					p.add(res)
+				}
+				p.finish(ops, wait)
+				report.Points = append(report.Points, p)
+			}
+		}
+	}
+	return report, nil
+}
+
+// add 把一次运行的结果累加到 p 中
+func (p *Point) add(res *core.Result) {
+	p.Runs++
+	if !res.OK() {
+		p.Failures++
+		p.Violations = append(p.Violations, res.Violation)
+	}
+	p.Elapsed += float64(res.Elapsed) / float64(time.Millisecond)
+	for _, name := range res.Metrics.Names() {
+		p.Metrics[name] += float64(res.Metrics.Get(name))
+	}
+}
+
+// finish 把累加的结果换算成平均值，并计算吞吐量和延迟
+func (p *Point) finish(ops, wait string) {
+	runs := float64(p.Runs)
+	p.Elapsed /= runs
+	for name := range p.Metrics {
+		p.Metrics[name] /= runs
+	}
+	p.Messages = p.Metrics["messages"]
+	done := p.Metrics[ops]
+	if done == 0 {
+		return
+	}
+	if p.Elapsed > 0 {
+		p.Throughput = done / (p.Elapsed / 1000)
+	}
+	p.OpLatency = p.Metrics[wait] / done
+	p.MessagesPerOp = p.Messages / done
+}
+
+// Series 返回 p 所在曲线的名字，同一条曲线上的点只有 Nodes 不同
+func (p Point) Series() string {
+	return fmt.Sprintf("%s loss=%g", p.Latency, p.LossRate)
+}
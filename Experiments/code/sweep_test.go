@@ -0,0 +1,75 @@
+package experiments
+
+import (
+	"testing"
+	"time"
+
+	_ "github.com/aQuaYi/Distributed-Algorithms/Mutual-Exclusion/code"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestSweep() Sweep {
+	fixed, _ := ParseDistribution("fixed:5ms")
+	uniform, _ := ParseDistribution("uniform:1ms-20ms")
+	return Sweep{
+		Algorithm: "lamport",
+		Params:    map[string]string{"requests": "2", "hold": "1ms"},
+		Nodes:     []int{2, 4},
+		Latencies: []Distribution{fixed, uniform},
+		Seeds:     3,
+	}
+}
+
+func Test_Sweep_Run(t *testing.T) {
+	ast := assert.New(t)
+	//
+	r, err := newTestSweep().Run()
+	ast.Nil(err)
+	ast.Equal(4, len(r.Points))
+	p := r.Points[0]
+	ast.Equal(2, p.Nodes)
+	ast.Equal("fixed:5ms", p.Latency)
+	ast.Equal(3, p.Runs)
+	ast.Equal(0, p.Failures)
+	// 每次申请都有 1 条 request、1 条 acknowledgment 和 1 条 release
+	ast.Equal(4.0, p.Metrics["grants"])
+	ast.Equal(12.0, p.Messages)
+	ast.Equal(3.0, p.MessagesPerOp)
+	ast.True(p.Throughput > 0)
+	ast.True(p.OpLatency >= 10, "申请至少要等待 request 和 acknowledgment 的往返")
+	// 参数相同时，结果也相同
+	again, _ := newTestSweep().Run()
+	ast.Equal(r, again)
+	// node 越多，每次申请需要的 message 越多
+	ast.True(r.Points[2].MessagesPerOp > p.MessagesPerOp)
+}
+
+func Test_Sweep_Run_loss(t *testing.T) {
+	ast := assert.New(t)
+	//
+	s := newTestSweep()
+	s.Nodes = []int{3}
+	s.Latencies = s.Latencies[:1]
+	s.LossRates = []float64{0, 0.2}
+	s.MaxTime = time.Second
+	r, err := s.Run()
+	ast.Nil(err)
+	ast.Equal(0, r.Points[0].Failures)
+	ast.Equal(3, r.Points[1].Failures, "Lamport 算法假设通道是可靠的")
+	ast.True(r.Points[1].Metrics["messages.lost"] > 0)
+	ast.Contains(r.Points[1].Violations[0], "deadlock")
+}
+
+func Test_Sweep_Run_errors(t *testing.T) {
+	ast := assert.New(t)
+	//
+	_, err := Sweep{Algorithm: "bakery", Nodes: []int{3}}.Run()
+	ast.Equal(`experiments: unknown algorithm "bakery"`, err.Error())
+	_, err = Sweep{Algorithm: "lamport"}.Run()
+	ast.Equal(ErrNoNodes, err)
+	// 默认没有延迟，每个组合只运行一次
+	r, err := Sweep{Algorithm: "raymond", Nodes: []int{3}}.Run()
+	ast.Nil(err)
+	ast.Equal("none", r.Points[0].Latency)
+	ast.Equal(1, r.Seeds)
+}
@@ -0,0 +1,97 @@
+package failuredetector
+
+// Class 是 Chandra 和 Toueg 定义的故障检测器的类别
+// 所有的类别都满足强完备性：崩溃的 process 最终会被每一个正确的 process 永久地怀疑。
+// 它们的区别在于准确性：
+//
+//	P   强准确性：没有 process 在崩溃前被怀疑
+//	◇P  最终强准确性：某个时刻以后，正确的 process 不会被任何正确的 process 怀疑
+//	S   弱准确性：存在一个正确的 process，从来不被怀疑
+//	◇S  最终弱准确性：某个时刻以后，存在一个正确的 process，不被任何正确的 process 怀疑
+type Class int
+
+// 枚举了故障检测器的类别，从强到弱
+const (
+	ClassP Class = iota
+	ClassEventuallyP
+	ClassS
+	ClassEventuallyS
+)
+
+func (c Class) String() string {
+	switch c {
+	case ClassP:
+		return "P"
+	case ClassEventuallyP:
+		return "◇P"
+	case ClassS:
+		return "S"
+	default:
+		return "◇S"
+	}
+}
+
+// Implies 返回 true，如果 c 类的故障检测器也属于 d 类
+func (c Class) Implies(d Class) bool {
+	switch c {
+	case ClassP:
+		return true
+	case ClassEventuallyP:
+		return d == ClassEventuallyP || d == ClassEventuallyS
+	case ClassS:
+		return d == ClassS || d == ClassEventuallyS
+	default:
+		return d == ClassEventuallyS
+	}
+}
+
+// Detector 是一个 process 本地的故障检测器模块，告诉 process 它现在怀疑哪些 process 已经崩溃了
+type Detector interface {
+	// Suspects 返回 true，如果现在怀疑 id 已经崩溃
+	Suspects(id int) bool
+	// Suspected 返回现在怀疑的所有 process，按 id 升序排列
+	Suspected() []int
+	// Class 返回故障检测器的类别
+	Class() Class
+}
+
+// 以下的接口构成了故障检测器的层级
+// 算法的参数写成它所需要的最弱的接口，就可以接受所有更强的故障检测器，
+// 例如只需要 ◇S 的共识算法，接受 EventuallyStrong，也就能运行在 P 和 ◇P 上。
+
+// EventuallyStrong 是 ◇S 类的故障检测器
+type EventuallyStrong interface {
+	Detector
+	eventuallyWeakAccuracy()
+}
+
+// Strong 是 S 类的故障检测器
+type Strong interface {
+	EventuallyStrong
+	weakAccuracy()
+}
+
+// EventuallyPerfect 是 ◇P 类的故障检测器
+type EventuallyPerfect interface {
+	EventuallyStrong
+	eventuallyStrongAccuracy()
+}
+
+// Perfect 是 P 类的故障检测器
+type Perfect interface {
+	Strong
+	EventuallyPerfect
+	strongAccuracy()
+}
+
+// Leader 返回 ids 中第一个没有被 d 怀疑的 process，都被怀疑时返回 -1
+// 在 ◇P 上，所有正确的 process 最终会选出同一个正确的 leader，也就是 Ω。
+// 在更弱的故障检测器上，不同的 process 可能会一直选出不同的 leader。
+func Leader(d EventuallyStrong, ids []int) int {
+	for _, id := range ids {
+		if !d.Suspects(id) {
+			return id
+		}
+	}
+	return -1
+}
@@ -0,0 +1,57 @@
+package failuredetector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 检查各个实现在层级中的位置
+var (
+	_ Perfect           = (*PerfectDetector)(nil)
+	_ EventuallyPerfect = (*EventuallyPerfectDetector)(nil)
+	_ Strong            = (*StrongDetector)(nil)
+	_ EventuallyStrong  = (*EventuallyStrongDetector)(nil)
+)
+
+func Test_Class_String(t *testing.T) {
+	ast := assert.New(t)
+	//
+	ast.Equal("P", ClassP.String())
+	ast.Equal("◇P", ClassEventuallyP.String())
+	ast.Equal("S", ClassS.String())
+	ast.Equal("◇S", ClassEventuallyS.String())
+}
+
+func Test_Class_Implies(t *testing.T) {
+	ast := assert.New(t)
+	//
+	all := []Class{ClassP, ClassEventuallyP, ClassS, ClassEventuallyS}
+	for _, c := range all {
+		ast.True(c.Implies(c), c.String())
+		ast.True(ClassP.Implies(c), c.String())
+		ast.True(c.Implies(ClassEventuallyS), c.String())
+	}
+	ast.False(ClassEventuallyP.Implies(ClassS))
+	ast.False(ClassS.Implies(ClassEventuallyP))
+	ast.False(ClassEventuallyS.Implies(ClassS))
+	ast.False(ClassS.Implies(ClassP))
+}
+
+// fixed 是怀疑固定的 process 的故障检测器
+type fixed map[int]bool
+
+func (f fixed) Suspects(id int) bool { return f[id] }
+func (f fixed) Suspected() []int     { return nil }
+func (f fixed) Class() Class         { return ClassEventuallyS }
+
+func (f fixed) eventuallyWeakAccuracy() {}
+
+func Test_Leader(t *testing.T) {
+	ast := assert.New(t)
+	//
+	ids := []int{0, 1, 2}
+	ast.Equal(0, Leader(fixed{}, ids))
+	ast.Equal(2, Leader(fixed{0: true, 1: true}, ids))
+	ast.Equal(-1, Leader(fixed{0: true, 1: true, 2: true}, ids))
+}
@@ -0,0 +1,180 @@
+package failuredetector
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+)
+
+// Config 是 heartbeat 故障检测器的参数
+type Config struct {
+	// Interval 是发送 heartbeat 的间隔
+	Interval time.Duration
+	// Timeout 是等待 heartbeat 的时限，超过 Timeout 没有收到对方的 heartbeat，就怀疑它崩溃了
+	Timeout time.Duration
+	// Increment 只用于 ◇P，每次发现自己怀疑错了，就把对那个 process 的 Timeout 增加 Increment
+	Increment time.Duration
+	// Env 提供了发送 heartbeat 和判断超时所用的时间，零值使用真实的时间
+	Env sim.Env
+}
+
+// heartbeat 定期给所有的 peer 发送 heartbeat，并检查是否按时收到了 peer 的 heartbeat
+type heartbeat struct {
+	me        int
+	peers     []int
+	net       *Network
+	config    Config
+	permanent bool // 为 true 时，怀疑以后就不再撤销
+
+	mutex     sync.Mutex
+	lastHeard map[int]time.Time     // 最后一次收到各个 peer 的 heartbeat 的时间
+	timeouts  map[int]time.Duration // 对各个 peer 的 timeout
+	suspected map[int]bool
+	mistakes  int // 撤销怀疑的次数
+	done      chan struct{}
+}
+
+func newHeartbeat(me int, peers []int, net *Network, config Config, permanent bool) *heartbeat {
+	h := &heartbeat{
+		me:        me,
+		net:       net,
+		config:    config,
+		permanent: permanent,
+		lastHeard: make(map[int]time.Time, len(peers)),
+		timeouts:  make(map[int]time.Duration, len(peers)),
+		suspected: make(map[int]bool, len(peers)),
+		done:      make(chan struct{}),
+	}
+	now := config.Env.Now()
+	for _, p := range peers {
+		if p == me {
+			continue
+		}
+		h.peers = append(h.peers, p)
+		h.lastHeard[p] = now
+		h.timeouts[p] = config.Timeout
+	}
+
+	net.register(me, h.receive)
+
+	go h.loop()
+
+	return h
+}
+
+func (h *heartbeat) loop() {
+	ticker := h.config.Env.NewTicker(h.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.done:
+			return
+		case now := <-ticker.C():
+			for _, p := range h.peers {
+				h.net.send(h.me, p)
+			}
+			h.check(now)
+		}
+	}
+}
+
+// check 怀疑所有超时的 peer
+func (h *heartbeat) check(now time.Time) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for _, p := range h.peers {
+		if !h.suspected[p] && now.Sub(h.lastHeard[p]) > h.timeouts[p] {
+			h.suspected[p] = true
+		}
+	}
+}
+
+func (h *heartbeat) receive(from int) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if _, ok := h.lastHeard[from]; !ok {
+		return
+	}
+	h.lastHeard[from] = h.config.Env.Now()
+	if h.suspected[from] && !h.permanent {
+		// 怀疑错了，说明对 from 的 timeout 太短
+		delete(h.suspected, from)
+		h.timeouts[from] += h.config.Increment
+		h.mistakes++
+	}
+}
+
+// Suspects 实现了 Detector 接口
+func (h *heartbeat) Suspects(id int) bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.suspected[id]
+}
+
+// Suspected 实现了 Detector 接口
+func (h *heartbeat) Suspected() []int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	res := make([]int, 0, len(h.suspected))
+	for p := range h.suspected {
+		res = append(res, p)
+	}
+	sort.Ints(res)
+	return res
+}
+
+// Stop 停止发送和检查 heartbeat
+func (h *heartbeat) Stop() {
+	h.net.unregister(h.me)
+	close(h.done)
+}
+
+// PerfectDetector 是基于 heartbeat 的 P
+// 只有在同步系统中才能实现 P：必须事先知道 heartbeat 延迟的上限，
+// 并且 Config.Timeout 大于 Config.Interval 与这个上限之和。
+// PerfectDetector 信任这个假设，一旦怀疑就不再撤销。
+// 如果网络的延迟超过了假设的上限，它会永久地怀疑一个正确的 process，违反强准确性。
+type PerfectDetector struct {
+	*heartbeat
+}
+
+// NewPerfect 返回 process me 的 P，它监视 peers 中除了 me 以外的 process
+func NewPerfect(me int, peers []int, net *Network, config Config) *PerfectDetector {
+	return &PerfectDetector{heartbeat: newHeartbeat(me, peers, net, config, true)}
+}
+
+// Class 实现了 Detector 接口
+func (d *PerfectDetector) Class() Class { return ClassP }
+
+func (d *PerfectDetector) eventuallyWeakAccuracy()   {}
+func (d *PerfectDetector) weakAccuracy()             {}
+func (d *PerfectDetector) eventuallyStrongAccuracy() {}
+func (d *PerfectDetector) strongAccuracy()           {}
+
+// EventuallyPerfectDetector 是基于 heartbeat 的 ◇P
+// 在部分同步的系统中，heartbeat 的延迟在某个未知的时刻以后才有上限，这个上限也是未知的。
+// 所以每次收到被怀疑的 process 的 heartbeat，都撤销怀疑，并增加对它的 timeout。
+// timeout 最终会超过延迟的上限，此后就不会再怀疑正确的 process 了。
+type EventuallyPerfectDetector struct {
+	*heartbeat
+}
+
+// NewEventuallyPerfect 返回 process me 的 ◇P，它监视 peers 中除了 me 以外的 process
+func NewEventuallyPerfect(me int, peers []int, net *Network, config Config) *EventuallyPerfectDetector {
+	return &EventuallyPerfectDetector{heartbeat: newHeartbeat(me, peers, net, config, false)}
+}
+
+// Class 实现了 Detector 接口
+func (d *EventuallyPerfectDetector) Class() Class { return ClassEventuallyP }
+
+// Mistakes 返回撤销怀疑的次数，也就是怀疑错了的次数
+func (d *EventuallyPerfectDetector) Mistakes() int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.mistakes
+}
+
+func (d *EventuallyPerfectDetector) eventuallyWeakAccuracy()   {}
+func (d *EventuallyPerfectDetector) eventuallyStrongAccuracy() {}
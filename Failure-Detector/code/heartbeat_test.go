@@ -0,0 +1,85 @@
+package failuredetector
+
+import (
+	"testing"
+	"time"
+
+	reliablechannel "github.com/aQuaYi/Distributed-Algorithms/Reliable-Channel/code"
+	"github.com/stretchr/testify/assert"
+)
+
+var testConfig = Config{
+	Interval:  5 * time.Millisecond,
+	Timeout:   30 * time.Millisecond,
+	Increment: 30 * time.Millisecond,
+}
+
+func Test_PerfectDetector_completeness(t *testing.T) {
+	ast := assert.New(t)
+	//
+	net := NewNetwork(reliablechannel.Uniform{Max: 2 * time.Millisecond}, 0)
+	ids := []int{0, 1, 2}
+	ds := make([]*PerfectDetector, len(ids))
+	for i := range ds {
+		ds[i] = NewPerfect(i, ids, net, testConfig)
+		defer ds[i].Stop()
+	}
+	//
+	time.Sleep(50 * time.Millisecond)
+	for _, d := range ds {
+		ast.Empty(d.Suspected())
+		ast.Equal(ClassP, d.Class())
+	}
+	net.Crash(2)
+	ast.True(net.Crashed(2))
+	time.Sleep(100 * time.Millisecond)
+	ast.Equal([]int{2}, ds[0].Suspected())
+	ast.Equal([]int{2}, ds[1].Suspected())
+	ast.False(ds[0].Suspects(1))
+	ast.True(net.Sent() > 0)
+}
+
+func Test_PerfectDetector_boundViolated(t *testing.T) {
+	ast := assert.New(t)
+	//
+	net := NewNetwork(reliablechannel.Fixed(time.Millisecond), 0)
+	// 0 发往 1 的 heartbeat，延迟超过了 P 所假设的上限
+	net.SetLatency(0, 1, reliablechannel.Fixed(60*time.Millisecond))
+	ids := []int{0, 1}
+	d0 := NewPerfect(0, ids, net, testConfig)
+	defer d0.Stop()
+	d1 := NewPerfect(1, ids, net, testConfig)
+	defer d1.Stop()
+	//
+	time.Sleep(150 * time.Millisecond)
+	// heartbeat 仍然在到达，但是 P 不会撤销怀疑
+	ast.True(d1.Suspects(0))
+	ast.False(d0.Suspects(1))
+}
+
+func Test_EventuallyPerfectDetector_converges(t *testing.T) {
+	ast := assert.New(t)
+	//
+	net := NewNetwork(reliablechannel.Fixed(time.Millisecond), 0)
+	net.SetLatency(0, 1, reliablechannel.Fixed(60*time.Millisecond))
+	ids := []int{0, 1, 2}
+	ds := make([]*EventuallyPerfectDetector, len(ids))
+	for i := range ds {
+		ds[i] = NewEventuallyPerfect(i, ids, net, testConfig)
+		defer ds[i].Stop()
+	}
+	//
+	time.Sleep(50 * time.Millisecond)
+	ast.True(ds[1].Suspects(0), "heartbeat 迟到时，◇P 也会怀疑错")
+	time.Sleep(150 * time.Millisecond)
+	ast.False(ds[1].Suspects(0), "timeout 增加以后，不再怀疑正确的 process")
+	ast.True(ds[1].Mistakes() > 0)
+	ast.Equal(0, ds[2].Mistakes())
+	// 最终强准确性并不影响强完备性
+	net.Crash(0)
+	time.Sleep(200 * time.Millisecond)
+	ast.True(ds[1].Suspects(0))
+	ast.True(ds[2].Suspects(0))
+	ast.Equal(1, Leader(ds[1], ids))
+	ast.Equal(1, Leader(ds[2], ids))
+}
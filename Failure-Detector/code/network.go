@@ -0,0 +1,110 @@
+package failuredetector
+
+import (
+	"sync"
+	"time"
+
+	reliablechannel "github.com/aQuaYi/Distributed-Algorithms/Reliable-Channel/code"
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+)
+
+// Network 模拟了 process 之间传递 heartbeat 的网络
+// heartbeat 不会丢失，但是会按照链路的延迟分布被延迟。崩溃的 process 不再发送和接收 heartbeat。
+type Network struct {
+	mutex    sync.Mutex
+	env      sim.Env
+	latency  reliablechannel.Latency
+	links    map[[2]int]reliablechannel.Latency
+	handlers map[int]func(from int)
+	crashed  map[int]bool
+	sent     int
+}
+
+// NewNetwork 返回链路延迟默认服从 latency 的网络，延迟的随机抽样由 seed 决定
+func NewNetwork(latency reliablechannel.Latency, seed int64) *Network {
+	return NewNetworkWithEnv(latency, sim.Env{Rand: sim.NewRand(seed)})
+}
+
+// NewNetworkWithEnv 与 NewNetwork 一样，但是延迟的抽样和 heartbeat 的投递都由 env 决定
+func NewNetworkWithEnv(latency reliablechannel.Latency, env sim.Env) *Network {
+	return &Network{
+		env:      env,
+		latency:  latency,
+		links:    make(map[[2]int]reliablechannel.Latency, 16),
+		handlers: make(map[int]func(from int), 16),
+		crashed:  make(map[int]bool, 16),
+	}
+}
+
+// SetLatency 单独设置从 from 到 to 的链路的延迟分布
+func (n *Network) SetLatency(from, to int, latency reliablechannel.Latency) {
+	n.mutex.Lock()
+	n.links[[2]int{from, to}] = latency
+	n.mutex.Unlock()
+}
+
+// Crash 让 id 崩溃
+func (n *Network) Crash(id int) {
+	n.mutex.Lock()
+	n.crashed[id] = true
+	n.mutex.Unlock()
+}
+
+// Crashed 返回 true，如果 id 已经崩溃
+func (n *Network) Crashed(id int) bool {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return n.crashed[id]
+}
+
+// Sent 返回网络中发送过的 heartbeat 数量
+func (n *Network) Sent() int {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return n.sent
+}
+
+func (n *Network) register(id int, handler func(from int)) {
+	n.mutex.Lock()
+	n.handlers[id] = handler
+	n.mutex.Unlock()
+}
+
+func (n *Network) unregister(id int) {
+	n.mutex.Lock()
+	delete(n.handlers, id)
+	n.mutex.Unlock()
+}
+
+// send 把 from 的 heartbeat 发送给 to
+func (n *Network) send(from, to int) {
+	n.mutex.Lock()
+	if n.crashed[from] {
+		n.mutex.Unlock()
+		return
+	}
+	n.sent++
+	latency, ok := n.links[[2]int{from, to}]
+	if !ok {
+		latency = n.latency
+	}
+	var delay time.Duration
+	if latency != nil {
+		delay = latency.Sample(n.env)
+	}
+	n.mutex.Unlock()
+
+	n.env.AfterFunc(delay, func() { n.deliver(from, to) })
+}
+
+func (n *Network) deliver(from, to int) {
+	n.mutex.Lock()
+	handler, ok := n.handlers[to]
+	if n.crashed[to] {
+		ok = false
+	}
+	n.mutex.Unlock()
+	if ok {
+		handler(from)
+	}
+}
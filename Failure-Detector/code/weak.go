@@ -0,0 +1,94 @@
+package failuredetector
+
+import (
+	"sort"
+	"time"
+
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+)
+
+// noisy 在一个更强的故障检测器之上，随机地怀疑正确的 process，
+// 只保留 S 或 ◇S 所要求的最弱的保证，用来观察算法在弱故障检测器上的表现。
+// 除了 trusted 以外，每次查询时，每个 process 都有 rate 的概率被错误地怀疑；
+// trusted 只有在 stable 之前才会被错误地怀疑。
+type noisy struct {
+	inner   Detector
+	ids     []int
+	trusted int
+	rate    float64
+	stable  time.Time
+	env     sim.Env
+}
+
+func newNoisy(inner Detector, ids []int, trusted int, rate float64, stable time.Time, env sim.Env) *noisy {
+	return &noisy{
+		inner:   inner,
+		ids:     append([]int(nil), ids...),
+		trusted: trusted,
+		rate:    rate,
+		stable:  stable,
+		env:     env,
+	}
+}
+
+// Suspects 实现了 Detector 接口
+func (n *noisy) Suspects(id int) bool {
+	if n.inner.Suspects(id) {
+		return true
+	}
+	if id == n.trusted && !n.env.Now().Before(n.stable) {
+		return false
+	}
+	return n.env.Float64() < n.rate
+}
+
+// Suspected 实现了 Detector 接口
+func (n *noisy) Suspected() []int {
+	var res []int
+	for _, id := range n.ids {
+		if n.Suspects(id) {
+			res = append(res, id)
+		}
+	}
+	sort.Ints(res)
+	return res
+}
+
+// StrongDetector 是 S：除了 trusted 以外，正确的 process 也会被错误地怀疑
+type StrongDetector struct {
+	*noisy
+}
+
+// NewStrong 在 P 之上构造 S，ids 是所有被监视的 process，不包括 inner 所在的 process 自己
+// trusted 必须是正确的 process，它从来不会被怀疑，其他的 process 每次查询时都有 rate 的概率被错误地怀疑
+func NewStrong(inner Perfect, ids []int, trusted int, rate float64, seed int64) *StrongDetector {
+	return &StrongDetector{noisy: newNoisy(inner, ids, trusted, rate, time.Time{}, sim.Env{Rand: sim.NewRand(seed)})}
+}
+
+// Class 实现了 Detector 接口
+func (d *StrongDetector) Class() Class { return ClassS }
+
+func (d *StrongDetector) eventuallyWeakAccuracy() {}
+func (d *StrongDetector) weakAccuracy()           {}
+
+// EventuallyStrongDetector 是 ◇S：在 stable 之前，所有正确的 process 都可能被错误地怀疑，
+// 此后只有 trusted 不会被怀疑
+type EventuallyStrongDetector struct {
+	*noisy
+}
+
+// NewEventuallyStrong 在 ◇P 之上构造 ◇S，ids 是所有被监视的 process，不包括 inner 所在的 process 自己
+// trusted 必须是正确的 process，从现在开始经过 stable 以后，它不会再被 NewEventuallyStrong 额外地怀疑
+func NewEventuallyStrong(inner EventuallyPerfect, ids []int, trusted int, rate float64, stable time.Duration, seed int64) *EventuallyStrongDetector {
+	return NewEventuallyStrongWithEnv(inner, ids, trusted, rate, stable, sim.Env{Rand: sim.NewRand(seed)})
+}
+
+// NewEventuallyStrongWithEnv 与 NewEventuallyStrong 一样，但是 stable 按照 env 的时间计算，错误的怀疑也由 env 抽取
+func NewEventuallyStrongWithEnv(inner EventuallyPerfect, ids []int, trusted int, rate float64, stable time.Duration, env sim.Env) *EventuallyStrongDetector {
+	return &EventuallyStrongDetector{noisy: newNoisy(inner, ids, trusted, rate, env.Now().Add(stable), env)}
+}
+
+// Class 实现了 Detector 接口
+func (d *EventuallyStrongDetector) Class() Class { return ClassEventuallyS }
+
+func (d *EventuallyStrongDetector) eventuallyWeakAccuracy() {}
@@ -0,0 +1,89 @@
+package failuredetector
+
+import (
+	"testing"
+	"time"
+
+	reliablechannel "github.com/aQuaYi/Distributed-Algorithms/Reliable-Channel/code"
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_StrongDetector(t *testing.T) {
+	ast := assert.New(t)
+	//
+	net := NewNetwork(reliablechannel.Fixed(time.Millisecond), 0)
+	ids := []int{0, 1, 2, 3}
+	p := NewPerfect(0, ids, net, testConfig)
+	defer p.Stop()
+	for _, id := range ids[1:] {
+		defer NewPerfect(id, ids, net, testConfig).Stop()
+	}
+	d := NewStrong(p, ids[1:], 1, 0.5, 0)
+	ast.Equal(ClassS, d.Class())
+	//
+	net.Crash(3)
+	time.Sleep(100 * time.Millisecond)
+	falsely := 0
+	for i := 0; i < 1000; i++ {
+		ast.False(d.Suspects(1), "trusted 从来不会被怀疑")
+		ast.True(d.Suspects(3), "崩溃的 process 一直被怀疑")
+		if d.Suspects(2) {
+			falsely++
+		}
+	}
+	ast.InDelta(500, falsely, 100)
+	ast.Contains(d.Suspected(), 3)
+	ast.NotContains(d.Suspected(), 1)
+}
+
+func Test_EventuallyStrongDetector(t *testing.T) {
+	ast := assert.New(t)
+	//
+	net := NewNetwork(reliablechannel.Fixed(time.Millisecond), 0)
+	ids := []int{0, 1, 2}
+	p := NewEventuallyPerfect(0, ids, net, testConfig)
+	defer p.Stop()
+	for _, id := range ids[1:] {
+		defer NewEventuallyPerfect(id, ids, net, testConfig).Stop()
+	}
+	d := NewEventuallyStrong(p, ids[1:], 2, 1, 50*time.Millisecond, 0)
+	ast.Equal(ClassEventuallyS, d.Class())
+	//
+	ast.Equal([]int{1, 2}, d.Suspected(), "稳定以前，所有的 process 都可能被怀疑")
+	ast.Equal(-1, Leader(d, []int{1, 2}))
+	time.Sleep(60 * time.Millisecond)
+	ast.Equal([]int{1}, d.Suspected())
+	ast.Equal(2, Leader(d, []int{1, 2}))
+}
+
+func Test_EventuallyStrongDetector_virtualClock(t *testing.T) {
+	ast := assert.New(t)
+	//
+	clock := sim.NewVirtual(time.Unix(0, 0))
+	env := sim.Deterministic(clock, 0)
+	config := testConfig
+	config.Env = env
+	net := NewNetworkWithEnv(reliablechannel.Fixed(time.Millisecond), env)
+	ids := []int{0, 1, 2}
+	p := NewEventuallyPerfect(0, ids, net, config)
+	defer p.Stop()
+	for _, id := range ids[1:] {
+		defer NewEventuallyPerfect(id, ids, net, config).Stop()
+	}
+	d := NewEventuallyStrongWithEnv(p, ids[1:], 2, 1, time.Second, env)
+	// advance 按照 heartbeat 的间隔推进虚拟时间，每一步都给 detector 的 goroutine 留出处理 tick 的时间
+	advance := func(d time.Duration) {
+		for end := clock.Now().Add(d); clock.Now().Before(end); {
+			clock.Advance(config.Interval)
+			time.Sleep(time.Millisecond)
+		}
+	}
+	//
+	net.Crash(1)
+	advance(500 * time.Millisecond)
+	ast.Equal([]int{1}, p.Suspected(), "虚拟时间超过 Timeout 以后，崩溃的 process 被怀疑")
+	ast.Equal([]int{1, 2}, d.Suspected(), "虚拟时间还没有到 stable")
+	advance(600 * time.Millisecond)
+	ast.Equal([]int{1}, d.Suspected())
+}
@@ -0,0 +1,14 @@
+package idgen
+
+// Generator 生成全局唯一的 ID
+// 每个 worker 拥有自己的 Generator，不同的 worker 之间不需要协调
+type Generator interface {
+	Next() (int64, error)
+}
+
+const (
+	// workerBits 是 ID 中 worker 所占的 bit 数，最多 1024 个 worker
+	workerBits = 10
+	// MaxWorker 是最大的 worker ID
+	MaxWorker = 1<<workerBits - 1
+)
@@ -0,0 +1,86 @@
+package idgen
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func lamports(ast *assert.Assertions, n int) []*Lamport {
+	res := make([]*Lamport, n)
+	for i := range res {
+		l, err := NewLamport(int64(i))
+		ast.Nil(err)
+		res[i] = l
+	}
+	return res
+}
+
+func Test_Lamport_unique(t *testing.T) {
+	ast := assert.New(t)
+	//
+	// 时钟的偏差对 Lamport 没有任何影响
+	sim := skewed(8, 1)
+	ls := lamports(ast, 8)
+	gens := make([]Generator, len(ls))
+	for i, l := range ls {
+		gens[i] = l
+	}
+	ids, workers := generate(ast, sim, gens, 20000, 100*time.Microsecond, 2)
+	inversions := check(ast, ids, workers)
+	// 没有通信的 worker 之间，顺序与真实时间无关
+	ast.True(inversions > 0)
+}
+
+func Test_Lamport_causalOrder(t *testing.T) {
+	ast := assert.New(t)
+	//
+	// 每个 worker 生成 ID 之前，都收到了上一个 ID，所以 ID 按照因果顺序递增
+	// 同样的因果链上，时钟存在偏差的 Snowflake 会出现逆序
+	sim := skewed(8, 1)
+	ls := lamports(ast, 8)
+	sfs := snowflakes(ast, sim, 0)
+	r := rand.New(rand.NewSource(3))
+	var last int64
+	var sids []int64
+	for i := 0; i < 20000; i++ {
+		sim.Advance(100 * time.Microsecond)
+		w := r.Intn(len(ls))
+		ls[w].Observe(last)
+		id, _ := ls[w].Next()
+		ast.True(id > last)
+		last = id
+		//
+		sid, err := sfs[w].Next()
+		if err != nil {
+			continue
+		}
+		sids = append(sids, sid)
+	}
+	inversions := backwards(sids)
+	ast.True(inversions > 0)
+	t.Logf("因果链上，Snowflake 出现了 %d 次逆序", inversions)
+}
+
+func Test_Lamport_Observe(t *testing.T) {
+	ast := assert.New(t)
+	//
+	ls := lamports(ast, 2)
+	a, _ := ls[0].Next()
+	a, _ = ls[0].Next()
+	ls[1].Observe(a)
+	b, _ := ls[1].Next()
+	ast.True(a < b)
+	ts, worker := ParseLamport(b)
+	ast.Equal(int64(3), ts)
+	ast.Equal(int64(1), worker)
+	// 更旧的 ID 不会让时间戳后退
+	ls[1].Observe(a)
+	c, _ := ls[1].Next()
+	ast.True(b < c)
+	//
+	_, err := NewLamport(MaxWorker + 1)
+	ast.NotNil(err)
+}
@@ -0,0 +1,80 @@
+package idgen
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrClockBackwards 表示时钟回退的幅度超过了 Snowflake 能够容忍的范围
+	ErrClockBackwards = errors.New("idgen: clock moved backwards")
+	// ErrSequenceExhausted 表示这一毫秒内的 sequence 已经用完了，需要等时钟进入下一毫秒
+	ErrSequenceExhausted = errors.New("idgen: sequence exhausted in this millisecond")
+)
+
+const (
+	// sequenceBits 是 Snowflake ID 中 sequence 所占的 bit 数，每毫秒最多 4096 个 ID
+	sequenceBits = 12
+	maxSequence  = 1<<sequenceBits - 1
+	// timeBits 是 Snowflake ID 中毫秒数所占的 bit 数，可以使用大约 69 年
+	timeBits = 63 - workerBits - sequenceBits
+)
+
+// Snowflake 生成 Twitter Snowflake 风格的 ID
+//
+//	| 0 | 41 bit 毫秒数 | 10 bit worker | 12 bit sequence |
+//
+// 同一个 worker 的 ID 由时间和 sequence 保证唯一且单调递增，不同的 worker 由 worker 字段区分。
+// ID 大致按照生成的时间排序，但是不同 worker 的时钟存在偏差时，
+// 后生成的 ID 可能比另一个 worker 先生成的 ID 更小。
+type Snowflake struct {
+	worker   int64
+	now      func() time.Duration
+	tolerate time.Duration
+	last     int64 // 上一个 ID 的毫秒数
+	sequence int64
+}
+
+// NewSnowflake 返回 worker 的 Snowflake，now 是 worker 的物理时钟
+// 时钟回退不超过 tolerate 时，继续使用上一个 ID 的毫秒数，保证 ID 单调递增；
+// 回退得更多时，Next 返回 ErrClockBackwards
+func NewSnowflake(worker int64, now func() time.Duration, tolerate time.Duration) (*Snowflake, error) {
+	if worker < 0 || worker > MaxWorker {
+		return nil, fmt.Errorf("idgen: worker %d is out of [0, %d]", worker, MaxWorker)
+	}
+	return &Snowflake{
+		worker:   worker,
+		now:      now,
+		tolerate: tolerate,
+		last:     -1,
+	}, nil
+}
+
+// Next 实现了 Generator 接口
+func (s *Snowflake) Next() (int64, error) {
+	ms := int64(s.now() / time.Millisecond)
+	if ms < 0 || ms >= 1<<timeBits {
+		return 0, fmt.Errorf("idgen: time %dms is out of range", ms)
+	}
+	switch {
+	case ms > s.last:
+		s.last, s.sequence = ms, 0
+	case time.Duration(s.last-ms)*time.Millisecond > s.tolerate:
+		return 0, ErrClockBackwards
+	case s.sequence == maxSequence:
+		return 0, ErrSequenceExhausted
+	default:
+		// 同一毫秒内，或者时钟回退在容忍范围之内
+		s.sequence++
+	}
+	return s.last<<(workerBits+sequenceBits) | s.worker<<sequenceBits | s.sequence, nil
+}
+
+// ParseSnowflake 把 Snowflake ID 拆分成时间、worker 和 sequence
+func ParseSnowflake(id int64) (time.Duration, int64, int64) {
+	ms := id >> (workerBits + sequenceBits)
+	worker := id >> sequenceBits & MaxWorker
+	sequence := id & maxSequence
+	return time.Duration(ms) * time.Millisecond, worker, sequence
+}
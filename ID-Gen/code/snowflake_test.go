@@ -0,0 +1,169 @@
+package idgen
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	clocksync "github.com/aQuaYi/Distributed-Algorithms/Clock-Sync/code"
+	"github.com/stretchr/testify/assert"
+)
+
+// skewed 返回 n 个时钟存在偏差和漂移的 process
+func skewed(n int, seed int64) *clocksync.Simulation {
+	sim := clocksync.NewSimulation(n, clocksync.Config{
+		MaxOffset: 50 * time.Millisecond,
+		MaxDrift:  1e-3,
+	}, seed)
+	// 让所有时钟的读数都是正数
+	sim.Advance(time.Second)
+	return sim
+}
+
+// generate 让随机选中的 worker 依次生成 count 个 ID，每两个 ID 之间真实时间流逝 interval
+// 返回按照真实时间排序的 ID，以及生成它们的 worker
+func generate(ast *assert.Assertions, sim *clocksync.Simulation, gens []Generator, count int, interval time.Duration, seed int64) ([]int64, []int) {
+	r := rand.New(rand.NewSource(seed))
+	ids, workers := make([]int64, 0, count), make([]int, 0, count)
+	for len(ids) < count {
+		sim.Advance(interval)
+		w := r.Intn(len(gens))
+		id, err := gens[w].Next()
+		if err == ErrSequenceExhausted {
+			continue
+		}
+		ast.Nil(err)
+		ids, workers = append(ids, id), append(workers, w)
+	}
+	return ids, workers
+}
+
+// check 检查 ID 全局唯一，且每个 worker 的 ID 单调递增，返回后生成的 ID 比先生成的 ID 小的次数
+func check(ast *assert.Assertions, ids []int64, workers []int) int {
+	seen := make(map[int64]bool, len(ids))
+	last := make(map[int]int64)
+	inversions := 0
+	for i, id := range ids {
+		ast.False(seen[id], "重复的 ID %d", id)
+		seen[id] = true
+		if prev, ok := last[workers[i]]; ok {
+			ast.True(prev < id, "worker %d 的 ID 没有单调递增", workers[i])
+		}
+		last[workers[i]] = id
+		if i > 0 && id < ids[i-1] {
+			inversions++
+		}
+	}
+	return inversions
+}
+
+// backwards 返回 ids 中，后生成的 ID 的毫秒数比先生成的 ID 更小的次数
+// 同一毫秒内，不同 worker 的 ID 按照 worker 排序，不算在内
+func backwards(ids []int64) int {
+	res := 0
+	for i := 1; i < len(ids); i++ {
+		prev, _, _ := ParseSnowflake(ids[i-1])
+		cur, _, _ := ParseSnowflake(ids[i])
+		if cur < prev {
+			res++
+		}
+	}
+	return res
+}
+
+func snowflakes(ast *assert.Assertions, sim *clocksync.Simulation, tolerate time.Duration) []Generator {
+	gens := make([]Generator, sim.Size())
+	for i := range gens {
+		s, err := NewSnowflake(int64(i), sim.Source(i), tolerate)
+		ast.Nil(err)
+		gens[i] = s
+	}
+	return gens
+}
+
+func Test_Snowflake_uniqueUnderSkew(t *testing.T) {
+	ast := assert.New(t)
+	//
+	sim := skewed(8, 1)
+	ids, workers := generate(ast, sim, snowflakes(ast, sim, 0), 20000, 100*time.Microsecond, 2)
+	check(ast, ids, workers)
+	// 时钟快的 worker 生成的 ID，比之后时钟慢的 worker 生成的 ID 还大
+	inversions := backwards(ids)
+	ast.True(inversions > 0)
+	t.Logf("skew %v，%d 个 ID 中有 %d 次逆序", sim.Skew(), len(ids), inversions)
+	//
+	// 没有偏差时，只有同一毫秒内不同 worker 之间会逆序
+	sim = clocksync.NewSimulation(8, clocksync.Config{}, 1)
+	sim.Advance(time.Second)
+	ids, workers = generate(ast, sim, snowflakes(ast, sim, 0), 20000, 100*time.Microsecond, 2)
+	check(ast, ids, workers)
+	ast.Equal(0, backwards(ids))
+}
+
+func Test_Snowflake_clockBackwards(t *testing.T) {
+	ast := assert.New(t)
+	//
+	sim := skewed(1, 1)
+	strict, _ := NewSnowflake(1, sim.Source(0), 0)
+	tolerant, _ := NewSnowflake(2, sim.Source(0), 20*time.Millisecond)
+	a, err := strict.Next()
+	ast.Nil(err)
+	b, err := tolerant.Next()
+	ast.Nil(err)
+	// NTP 把时钟往回调了 10ms
+	sim.Clock(0).Adjust(-10 * time.Millisecond)
+	_, err = strict.Next()
+	ast.Equal(ErrClockBackwards, err)
+	c, err := tolerant.Next()
+	ast.Nil(err)
+	ast.True(b < c, "容忍范围之内，继续使用上一个毫秒数")
+	ta, _, _ := ParseSnowflake(b)
+	tc, _, _ := ParseSnowflake(c)
+	ast.Equal(ta, tc)
+	// 时钟追上以后，strict 也恢复了
+	sim.Advance(11 * time.Millisecond)
+	d, err := strict.Next()
+	ast.Nil(err)
+	ast.True(a < d)
+	//
+	// 回退超过容忍范围
+	sim.Clock(0).Adjust(-time.Second)
+	_, err = tolerant.Next()
+	ast.Equal(ErrClockBackwards, err)
+}
+
+func Test_Snowflake_sequence(t *testing.T) {
+	ast := assert.New(t)
+	//
+	now := 5 * time.Millisecond
+	s, _ := NewSnowflake(MaxWorker, func() time.Duration { return now }, 0)
+	for i := 0; i <= maxSequence; i++ {
+		id, err := s.Next()
+		ast.Nil(err)
+		ms, worker, seq := ParseSnowflake(id)
+		ast.Equal(now, ms)
+		ast.Equal(int64(MaxWorker), worker)
+		ast.Equal(int64(i), seq)
+	}
+	_, err := s.Next()
+	ast.Equal(ErrSequenceExhausted, err)
+	now += time.Millisecond
+	id, err := s.Next()
+	ast.Nil(err)
+	_, _, seq := ParseSnowflake(id)
+	ast.Equal(int64(0), seq)
+}
+
+func Test_NewSnowflake_invalid(t *testing.T) {
+	ast := assert.New(t)
+	//
+	now := func() time.Duration { return -time.Millisecond }
+	_, err := NewSnowflake(MaxWorker+1, now, 0)
+	ast.NotNil(err)
+	_, err = NewSnowflake(-1, now, 0)
+	ast.NotNil(err)
+	s, err := NewSnowflake(0, now, 0)
+	ast.Nil(err)
+	_, err = s.Next()
+	ast.NotNil(err, "时间不能是负数")
+}
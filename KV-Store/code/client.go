@@ -0,0 +1,147 @@
+package kvstore
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aQuaYi/Distributed-Algorithms/Raft/code/labrpc"
+	"github.com/aQuaYi/Distributed-Algorithms/Raft/code/rsm"
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+)
+
+// Clerk 是 KVServer 集群的客户端
+// Clerk 会一直重试，直到请求成功
+type Clerk struct {
+	servers []*labrpc.ClientEnd // LocalGet 直接访问指定的 server
+
+	mutex sync.Mutex // 保证同一个 Clerk 的请求是串行的
+	clerk *rsm.Clerk
+}
+
+// MakeClerk 返回一个通过 servers 访问集群的 Clerk
+func MakeClerk(servers []*labrpc.ClientEnd) *Clerk {
+	return MakeClerkWithEnv(servers, sim.Env{})
+}
+
+// MakeClerkWithEnv 与 MakeClerk 一样，但是 ck 从 env 读取时间
+func MakeClerkWithEnv(servers []*labrpc.ClientEnd, env sim.Env) *Clerk {
+	return &Clerk{
+		servers: servers,
+		clerk:   rsm.MakeClerk(servers, env),
+	}
+}
+
+// Get 返回 key 的值，key 不存在时返回 false
+func (ck *Clerk) Get(key string) (string, bool) {
+	value, exists, _ := ck.get(key)
+	return value, exists
+}
+
+// get 返回 key 的值和版本
+func (ck *Clerk) get(key string) (string, bool, int) {
+	ck.mutex.Lock()
+	defer ck.mutex.Unlock()
+
+	args := GetArgs{Key: key}
+	var value string
+	var exists bool
+	var version int
+	ck.clerk.Call(func(server *labrpc.ClientEnd) bool {
+		var reply GetReply
+		if !server.Call("KVServer.Get", &args, &reply) {
+			return false
+		}
+		switch reply.Err {
+		case OK:
+			value, exists, version = reply.Value, true, reply.Version
+			return true
+		case ErrNoKey:
+			version = reply.Version
+			return true
+		}
+		return false
+	})
+	return value, exists, version
+}
+
+// LocalGet 在 server 本地按照 mode 读取 key，只尝试一次
+// Err 为 OK 或者 ErrNoKey 时读取成功，其他的 Err 表示 server 此时无法按照 mode 提供读取，
+// 调用方可以换一个 server，或者改用 Get
+func (ck *Clerk) LocalGet(server int, key string, mode ReadMode, maxStaleness time.Duration) (string, Err) {
+	args := LocalGetArgs{Key: key, Mode: mode, MaxStaleness: maxStaleness}
+	var reply GetReply
+	if !ck.servers[server].Call("KVServer.LocalGet", &args, &reply) {
+		return "", ErrTimeout
+	}
+	return reply.Value, reply.Err
+}
+
+// Put 把 key 的值设置为 value
+func (ck *Clerk) Put(key, value string) {
+	ck.mutex.Lock()
+	defer ck.mutex.Unlock()
+
+	args := PutArgs{Key: key, Value: value, ClientID: ck.clerk.ClientID(), Seq: ck.clerk.NextSeq()}
+	ck.clerk.Call(func(server *labrpc.ClientEnd) bool {
+		var reply PutReply
+		return server.Call("KVServer.Put", &args, &reply) && reply.Err == OK
+	})
+}
+
+// Delete 删除 key
+func (ck *Clerk) Delete(key string) {
+	ck.mutex.Lock()
+	defer ck.mutex.Unlock()
+
+	args := DeleteArgs{Key: key, ClientID: ck.clerk.ClientID(), Seq: ck.clerk.NextSeq()}
+	ck.clerk.Call(func(server *labrpc.ClientEnd) bool {
+		var reply DeleteReply
+		return server.Call("KVServer.Delete", &args, &reply) && reply.Err == OK
+	})
+}
+
+// CompareAndSwap 在 key 的值为 old 时，把它设置为 new，并返回 true
+// 不存在的 key 视为空字符串
+func (ck *Clerk) CompareAndSwap(key, old, new string) bool {
+	ck.mutex.Lock()
+	defer ck.mutex.Unlock()
+
+	args := CASArgs{Key: key, Old: old, New: new, ClientID: ck.clerk.ClientID(), Seq: ck.clerk.NextSeq()}
+	var swapped bool
+	ck.clerk.Call(func(server *labrpc.ClientEnd) bool {
+		var reply CASReply
+		if !server.Call("KVServer.CompareAndSwap", &args, &reply) {
+			return false
+		}
+		switch reply.Err {
+		case OK:
+			swapped = true
+			return true
+		case ErrMismatch:
+			return true
+		}
+		return false
+	})
+	return swapped
+}
+
+// txn 发送事务操作，直到 leader 应用了它
+func (ck *Clerk) txn(args TxnArgs) TxnReply {
+	ck.mutex.Lock()
+	defer ck.mutex.Unlock()
+
+	args.ClientID, args.Seq = ck.clerk.ClientID(), ck.clerk.NextSeq()
+	var res TxnReply
+	ck.clerk.Call(func(server *labrpc.ClientEnd) bool {
+		var reply TxnReply
+		if !server.Call("KVServer.Txn", &args, &reply) {
+			return false
+		}
+		if reply.Err == ErrWrongLeader || reply.Err == ErrTimeout {
+			return false
+		}
+		res = reply
+		return true
+	})
+	return res
+}
@@ -0,0 +1,34 @@
+package kvstore
+
+import (
+	raft "github.com/aQuaYi/Distributed-Algorithms/Raft/code"
+	"github.com/aQuaYi/Distributed-Algorithms/Raft/code/labrpc"
+	"github.com/aQuaYi/Distributed-Algorithms/Raft/code/rsm"
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+)
+
+// Cluster 在 labrpc 模拟的网络中运行一组 KVServer
+type Cluster struct {
+	*rsm.Cluster
+	servers []*KVServer
+}
+
+// MakeCluster 启动由 n 个 KVServer 组成的集群
+func MakeCluster(n int) *Cluster {
+	return MakeClusterWithEnv(n, sim.Env{})
+}
+
+// MakeClusterWithEnv 与 MakeCluster 一样，但是网络、server 和 Clerk 都从 env 读取时间和随机数
+func MakeClusterWithEnv(n int, env sim.Env) *Cluster {
+	c := &Cluster{servers: make([]*KVServer, n)}
+	c.Cluster = rsm.MakeCluster(n, env, func(servers []*labrpc.ClientEnd, me int, persister *raft.Persister) rsm.Service {
+		c.servers[me] = StartKVServerWithEnv(servers, me, persister, env)
+		return c.servers[me]
+	})
+	return c
+}
+
+// MakeClerk 返回一个可以访问所有 server 的 Clerk
+func (c *Cluster) MakeClerk() *Clerk {
+	return MakeClerkWithEnv(c.ClientEnds(), c.Env())
+}
@@ -0,0 +1,206 @@
+package kvstore
+
+import (
+	"fmt"
+
+	"github.com/aQuaYi/Distributed-Algorithms/Raft/code/labgob"
+	"github.com/aQuaYi/Distributed-Algorithms/Raft/code/rsm"
+)
+
+func init() {
+	// Op 会作为 raft.LogEntry.Command 在 server 之间传递
+	labgob.Register(Op{})
+}
+
+// Err 是 RPC 的结果
+type Err = rsm.Err
+
+// 枚举了所有的 Err
+const (
+	OK                 = rsm.OK
+	ErrNoKey       Err = "ErrNoKey"
+	ErrWrongLeader     = rsm.ErrWrongLeader
+	ErrTimeout         = rsm.ErrTimeout
+	ErrWait        Err = "ErrWait"     // 锁被更老的事务持有，稍后重试
+	ErrAborted     Err = "ErrAborted"  // 事务需要 abort
+	ErrMismatch    Err = "ErrMismatch" // CompareAndSwap 时，key 的值与期望不符
+	ErrStale       Err = "ErrStale"    // 本地的数据比允许的更旧
+)
+
+type opType int
+
+// 枚举了 Op 的所有类型
+const (
+	opPut opType = iota
+	opDelete
+	opNoop // leader 上任后，为了 commit 当前 term 的 log 而提交的空操作；leader 空闲时，也用它推进 follower 的时间戳
+	opCAS
+	// 以下是事务的操作
+	opLock
+	opPrepare
+	opCommit
+	opAbort
+)
+
+func (t opType) String() string {
+	switch t {
+	case opPut:
+		return "Put"
+	case opDelete:
+		return "Delete"
+	case opCAS:
+		return "CAS"
+	case opLock:
+		return "Lock"
+	case opPrepare:
+		return "Prepare"
+	case opCommit:
+		return "Commit"
+	case opAbort:
+		return "Abort"
+	default:
+		return "Noop"
+	}
+}
+
+// Op 是写入 raft log 的命令
+// 只有修改 state machine 的操作才需要写入 log，Get 通过 ReadIndex 完成
+type Op struct {
+	Type     opType
+	Key      string
+	Value    string
+	ClientID int64  // 发起操作的 Clerk
+	Seq      int    // 操作在 Clerk 中的序号，用于去重
+	Expected string // opCAS 期望的旧值
+	// 以下是事务操作的参数
+	Txn        TxnID
+	Write      bool         // opLock 申请的是写锁
+	Optimistic bool         // opPrepare 需要验证读集合
+	Reads      []KeyVersion // opPrepare 需要验证的读集合
+	Writes     []KeyValue   // opPrepare 携带的写集合
+	// TS 是 leader 写入 log 时的 HLC 时间戳，follower 应用它时，知道自己的数据新到了什么时候
+	TS Timestamp
+}
+
+// Request 返回发起 op 的 Clerk 和 op 的序号，实现了 rsm.Command 接口
+// no-op 的 ClientID 为 0，不会被去重
+func (op Op) Request() (int64, int) {
+	return op.ClientID, op.Seq
+}
+
+func (op Op) String() string {
+	switch op.Type {
+	case opCAS:
+		return fmt.Sprintf("%s{%q:%q->%q, C%d:S%d}", op.Type, op.Key, op.Expected, op.Value, op.ClientID, op.Seq)
+	case opLock:
+		return fmt.Sprintf("%s{%q, write:%t, %s, C%d:S%d}", op.Type, op.Key, op.Write, op.Txn, op.ClientID, op.Seq)
+	case opPrepare, opCommit, opAbort:
+		return fmt.Sprintf("%s{%s, C%d:S%d}", op.Type, op.Txn, op.ClientID, op.Seq)
+	}
+	return fmt.Sprintf("%s{%q:%q, C%d:S%d}", op.Type, op.Key, op.Value, op.ClientID, op.Seq)
+}
+
+// PutArgs 是 Put 的参数
+type PutArgs struct {
+	Key      string
+	Value    string
+	ClientID int64
+	Seq      int
+}
+
+// PutReply 是 Put 的返回值
+type PutReply struct {
+	Err Err
+}
+
+// DeleteArgs 是 Delete 的参数
+type DeleteArgs struct {
+	Key      string
+	ClientID int64
+	Seq      int
+}
+
+// DeleteReply 是 Delete 的返回值
+type DeleteReply struct {
+	Err Err
+}
+
+// CASArgs 是 CompareAndSwap 的参数
+type CASArgs struct {
+	Key      string
+	Old      string
+	New      string
+	ClientID int64
+	Seq      int
+}
+
+// CASReply 是 CompareAndSwap 的返回值
+type CASReply struct {
+	Err Err
+}
+
+// GetArgs 是 Get 的参数
+type GetArgs struct {
+	Key string
+}
+
+// GetReply 是 Get 的返回值
+type GetReply struct {
+	Err     Err
+	Value   string
+	Version int       // key 被修改过的次数，乐观事务用它验证读集合
+	Applied Timestamp // 读取时，server 最后应用的 log 的 HLC 时间戳
+}
+
+// TxnID 是事务的 id
+// Start 是事务第一次开始的时间，abort 后重试的事务沿用原来的 Start，
+// wait-die 据此判断事务的新老
+type TxnID struct {
+	Start int64
+	ID    int64
+}
+
+func (id TxnID) String() string {
+	return fmt.Sprintf("T%d.%d", id.Start, id.ID)
+}
+
+// olderThan 判断 id 是否比 other 更老
+func (id TxnID) olderThan(other TxnID) bool {
+	if id.Start != other.Start {
+		return id.Start < other.Start
+	}
+	return id.ID < other.ID
+}
+
+// KeyValue 是事务写入的一个 key
+type KeyValue struct {
+	Key   string
+	Value string
+}
+
+// KeyVersion 是乐观事务读取的一个 key，以及读取时的版本
+type KeyVersion struct {
+	Key     string
+	Version int
+}
+
+// TxnArgs 是事务操作的参数
+type TxnArgs struct {
+	Type       opType
+	Txn        TxnID
+	Key        string
+	Write      bool
+	Optimistic bool
+	Reads      []KeyVersion
+	Writes     []KeyValue
+	ClientID   int64
+	Seq        int
+}
+
+// TxnReply 是事务操作的返回值，opLock 成功时，带有 key 的值
+type TxnReply struct {
+	Err     Err
+	Value   string
+	Exists  bool
+	Version int
+}
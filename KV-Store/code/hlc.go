@@ -0,0 +1,74 @@
+package kvstore
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Timestamp 是 hybrid logical clock 的时间戳
+// Wall 是物理时钟的部分，单位是纳秒；Wall 相同时，用 Logical 区分先后
+type Timestamp struct {
+	Wall    int64
+	Logical int
+}
+
+// Less 返回 true，如果 t 早于 other
+func (t Timestamp) Less(other Timestamp) bool {
+	if t.Wall != other.Wall {
+		return t.Wall < other.Wall
+	}
+	return t.Logical < other.Logical
+}
+
+func (t Timestamp) String() string {
+	return fmt.Sprintf("%d.%d", t.Wall, t.Logical)
+}
+
+// HLC 是 Kulkarni 等人提出的 hybrid logical clock
+// 它的读数总是不小于物理时钟，并且像 Lamport clock 一样，收到的 message 的时间戳一定小于之后的读数。
+// 物理时钟同步得越好，Wall 与真实时间就越接近；但是 HLC 只能追上更快的时钟，
+// 物理时钟比别人慢的 process 无法从 HLC 得知自己慢了多少。
+type HLC struct {
+	mutex    sync.Mutex
+	physical func() time.Time
+	last     Timestamp
+}
+
+// NewHLC 返回以 physical 为物理时钟的 HLC
+func NewHLC(physical func() time.Time) *HLC {
+	return &HLC{physical: physical}
+}
+
+// Now 返回本地事件或者发送 message 的时间戳
+func (c *HLC) Now() Timestamp {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if pt := c.physical().UnixNano(); pt > c.last.Wall {
+		c.last = Timestamp{Wall: pt}
+	} else {
+		c.last.Logical++
+	}
+	return c.last
+}
+
+// Update 根据收到的时间戳 remote 调整时钟，返回收到 message 的时间戳
+func (c *HLC) Update(remote Timestamp) Timestamp {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	pt := c.physical().UnixNano()
+	switch {
+	case pt > c.last.Wall && pt > remote.Wall:
+		c.last = Timestamp{Wall: pt}
+	case c.last.Wall == remote.Wall:
+		if remote.Logical > c.last.Logical {
+			c.last.Logical = remote.Logical
+		}
+		c.last.Logical++
+	case c.last.Wall > remote.Wall:
+		c.last.Logical++
+	default:
+		c.last = Timestamp{Wall: remote.Wall, Logical: remote.Logical + 1}
+	}
+	return c.last
+}
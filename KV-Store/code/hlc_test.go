@@ -0,0 +1,35 @@
+package kvstore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_HLC(t *testing.T) {
+	ast := assert.New(t)
+	//
+	now := time.Unix(0, 100)
+	c := NewHLC(func() time.Time { return now })
+	ast.Equal(Timestamp{Wall: 100}, c.Now())
+	ast.Equal(Timestamp{Wall: 100, Logical: 1}, c.Now(), "物理时钟没有前进时，增加 Logical")
+	//
+	ast.Equal(Timestamp{Wall: 500, Logical: 4}, c.Update(Timestamp{Wall: 500, Logical: 3}), "追上更快的时钟")
+	ast.Equal(Timestamp{Wall: 500, Logical: 5}, c.Now(), "物理时钟落后时，不会倒退")
+	ast.Equal(Timestamp{Wall: 500, Logical: 6}, c.Update(Timestamp{Wall: 200}))
+	ast.Equal(Timestamp{Wall: 500, Logical: 10}, c.Update(Timestamp{Wall: 500, Logical: 9}))
+	//
+	now = time.Unix(0, 600)
+	ast.Equal(Timestamp{Wall: 600}, c.Update(Timestamp{Wall: 550}))
+	ast.Equal(Timestamp{Wall: 600, Logical: 1}, c.Now())
+}
+
+func Test_Timestamp_Less(t *testing.T) {
+	ast := assert.New(t)
+	//
+	ast.True(Timestamp{Wall: 1, Logical: 9}.Less(Timestamp{Wall: 2}))
+	ast.True(Timestamp{Wall: 2}.Less(Timestamp{Wall: 2, Logical: 1}))
+	ast.False(Timestamp{Wall: 2, Logical: 1}.Less(Timestamp{Wall: 2, Logical: 1}))
+	ast.Equal("2.1", Timestamp{Wall: 2, Logical: 1}.String())
+}
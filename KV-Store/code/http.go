@@ -0,0 +1,59 @@
+package kvstore
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// pathPrefix 是 HTTP 接口的路径前缀，key 紧跟在前缀后面
+const pathPrefix = "/kv/"
+
+// NewHandler 返回通过 ck 访问集群的 HTTP 接口
+//
+//	GET    /kv/{key}  读取 key，key 不存在时返回 404
+//	PUT    /kv/{key}  把 request body 设置为 key 的值
+//	DELETE /kv/{key}  删除 key
+func NewHandler(ck *Clerk) http.Handler {
+	return &handler{ck: ck}
+}
+
+type handler struct {
+	ck *Clerk
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, pathPrefix) {
+		http.NotFound(w, r)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, pathPrefix)
+	if key == "" {
+		http.Error(w, "key is required", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		value, ok := h.ck.Get(key)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(value))
+	case http.MethodPut, http.MethodPost:
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		h.ck.Put(key, string(body))
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		h.ck.Delete(key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, PUT, POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
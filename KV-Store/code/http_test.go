@@ -0,0 +1,50 @@
+package kvstore
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Handler(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := MakeCluster(3)
+	defer c.Cleanup()
+	server := httptest.NewServer(NewHandler(c.MakeClerk()))
+	defer server.Close()
+	//
+	do := func(method, path, body string) (int, string) {
+		req, _ := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		ast.Nil(err)
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, string(b)
+	}
+	//
+	code, _ := do(http.MethodGet, "/kv/name", "")
+	ast.Equal(http.StatusNotFound, code)
+	//
+	code, _ = do(http.MethodPut, "/kv/name", "raft")
+	ast.Equal(http.StatusNoContent, code)
+	code, body := do(http.MethodGet, "/kv/name", "")
+	ast.Equal(http.StatusOK, code)
+	ast.Equal("raft", body)
+	//
+	code, _ = do(http.MethodDelete, "/kv/name", "")
+	ast.Equal(http.StatusNoContent, code)
+	code, _ = do(http.MethodGet, "/kv/name", "")
+	ast.Equal(http.StatusNotFound, code)
+	//
+	code, _ = do(http.MethodPatch, "/kv/name", "")
+	ast.Equal(http.StatusMethodNotAllowed, code)
+	code, _ = do(http.MethodGet, "/kv/", "")
+	ast.Equal(http.StatusBadRequest, code)
+	code, _ = do(http.MethodGet, "/other", "")
+	ast.Equal(http.StatusNotFound, code)
+}
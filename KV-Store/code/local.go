@@ -0,0 +1,117 @@
+package kvstore
+
+import "time"
+
+// closeInterval 是 leader 空闲时提交 no-op 的间隔
+const closeInterval = 50 * time.Millisecond
+
+// ReadMode 是 LocalGet 读取的方式
+type ReadMode int
+
+const (
+	// ReadIndexRead 由 server 向 leader 获取 ReadIndex，等自己应用到该位置以后，在本地读取
+	// leader 通过一轮心跳确认了自己的身份，所以与 Get 一样是线性一致的，并且不依赖于时钟；
+	// 但是联系不到 leader 时，读取会失败。
+	ReadIndexRead ReadMode = iota
+	// StaleRead 由 server 直接在本地读取，只要本地数据的 HLC 时间戳距离现在不超过 MaxStaleness
+	// 不需要联系 leader，被隔离的 server 在 MaxStaleness 以内依然可以读取；
+	// 但是数据有多旧是用 server 自己的物理时钟判断的，时钟比 leader 慢的 server 会低估数据的陈旧程度。
+	StaleRead
+)
+
+// LocalGetArgs 是 LocalGet 的参数
+type LocalGetArgs struct {
+	Key          string
+	Mode         ReadMode
+	MaxStaleness time.Duration // StaleRead 允许的数据陈旧程度
+}
+
+// ReadIndexArgs 是 ReadIndex 的参数
+type ReadIndexArgs struct {
+	From int // 发起请求的 server
+}
+
+// ReadIndexReply 是 ReadIndex 的返回值
+type ReadIndexReply struct {
+	Err   Err
+	Index int
+}
+
+// ReadIndex 是 RPC handler，leader 为 follower 获取 ReadIndex
+func (kv *KVServer) ReadIndex(args *ReadIndexArgs, reply *ReadIndexReply) {
+	index, ok := kv.readIndex()
+	if !ok {
+		reply.Err = ErrWrongLeader
+		return
+	}
+	reply.Err, reply.Index = OK, index
+}
+
+// LocalGet 是 RPC handler，在 kv 本地读取 key，kv 不必是 leader
+func (kv *KVServer) LocalGet(args *LocalGetArgs, reply *GetReply) {
+	if args.Mode == StaleRead {
+		kv.staleRead(args, reply)
+		return
+	}
+
+	readIndex, ok := kv.leaderReadIndex()
+	if !ok {
+		reply.Err = ErrWrongLeader
+		return
+	}
+	kv.readAt(readIndex, args.Key, reply)
+}
+
+func (kv *KVServer) staleRead(args *LocalGetArgs, reply *GetReply) {
+	kv.rsm.Lock()
+	defer kv.rsm.Unlock()
+
+	staleness := time.Duration(kv.clock.Now().Wall - kv.appliedTS.Wall)
+	if staleness > args.MaxStaleness {
+		reply.Err, reply.Applied = ErrStale, kv.appliedTS
+		return
+	}
+	kv.read(args.Key, reply)
+}
+
+// leaderReadIndex 返回 leader 确认过的 ReadIndex
+// kv 自己是 leader 时直接获取，否则依次询问其他的 server，只有 leader 会回复 OK
+func (kv *KVServer) leaderReadIndex() (int, bool) {
+	if _, isLeader := kv.Raft().GetState(); isLeader {
+		return kv.readIndex()
+	}
+	args := ReadIndexArgs{From: kv.me}
+	for id, end := range kv.peers {
+		if id == kv.me {
+			continue
+		}
+		var reply ReadIndexReply
+		if end.Call("KVServer.ReadIndex", &args, &reply) && reply.Err == OK {
+			return reply.Index, true
+		}
+	}
+	return -1, false
+}
+
+// closeLoop 在 leader 超过 closeInterval 没有应用任何 log 时提交 no-op
+// no-op 带着 leader 的 HLC 时间戳，follower 应用它以后，就知道自己的数据至少新到了这个时间。
+// 否则没有写入的时候，follower 无法区分是没有新的数据，还是自己落后了。
+// stamp 会跳过 follower，此后 leader 的身份依然由 rf.Start 再检查一次。
+func (kv *KVServer) closeLoop() {
+	for {
+		kv.env.Sleep(closeInterval)
+		kv.rsm.Lock()
+		if kv.rsm.Killed() {
+			kv.rsm.Unlock()
+			return
+		}
+		idle := kv.env.Since(kv.applied) >= closeInterval
+		kv.rsm.Unlock()
+		if !idle {
+			continue
+		}
+		if noop, ok := kv.stamp(Op{Type: opNoop}); ok {
+			kv.Raft().Start(noop)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package kvstore
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_LocalGet_readIndexOnFollower(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := MakeCluster(3)
+	defer c.Cleanup()
+	ck := c.MakeClerk()
+	ck.Put("x", "0")
+	follower := (c.Leader() + 1) % 3
+	// follower 可能还没有应用刚刚的写入，但是它会等到 leader 给出的 ReadIndex
+	for i := 1; i <= 10; i++ {
+		ck.Put("x", fmt.Sprint(i))
+		value, err := ck.LocalGet(follower, "x", ReadIndexRead, 0)
+		ast.Equal(OK, err)
+		ast.Equal(fmt.Sprint(i), value)
+	}
+	// 被隔离的 follower 联系不到 leader，不会返回过期的值
+	c.Disconnect(follower)
+	ck.Put("x", "new")
+	_, err := ck.LocalGet(follower, "x", ReadIndexRead, 0)
+	ast.Equal(ErrWrongLeader, err)
+}
+
+func Test_LocalGet_staleRead(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := MakeCluster(3)
+	defer c.Cleanup()
+	ck := c.MakeClerk()
+	ck.Put("x", "old")
+	follower := (c.Leader() + 1) % 3
+	// 没有写入时，leader 提交的 no-op 依然让 follower 的数据保持新鲜
+	time.Sleep(300 * time.Millisecond)
+	value, err := ck.LocalGet(follower, "x", StaleRead, 200*time.Millisecond)
+	ast.Equal(OK, err)
+	ast.Equal("old", value)
+	//
+	c.Disconnect(follower)
+	ck.Put("x", "new")
+	time.Sleep(300 * time.Millisecond)
+	_, err = ck.LocalGet(follower, "x", StaleRead, 200*time.Millisecond)
+	ast.Equal(ErrStale, err, "被隔离的 follower 知道自己的数据已经太旧了")
+	value, err = ck.LocalGet(follower, "x", StaleRead, time.Minute)
+	ast.Equal(OK, err, "允许更旧的数据时，被隔离的 follower 依然可以读取")
+	ast.Equal("old", value)
+}
+
+func Test_LocalGet_clockSkew(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := MakeCluster(3)
+	defer c.Cleanup()
+	ck := c.MakeClerk()
+	ck.Put("x", "old")
+	leader := c.Leader()
+	slow, fast := (leader+1)%3, (leader+2)%3
+	c.servers[slow].SetClockSkew(-time.Second)
+	c.servers[fast].SetClockSkew(time.Second)
+	time.Sleep(300 * time.Millisecond)
+	//
+	c.Disconnect(slow)
+	ck.Put("x", "new")
+	time.Sleep(300 * time.Millisecond)
+	// slow 的 HLC 停在了最后收到的 leader 的时间戳上，它以为自己的数据还很新
+	value, err := ck.LocalGet(slow, "x", StaleRead, 100*time.Millisecond)
+	ast.Equal(OK, err)
+	ast.Equal("old", value, "时钟慢的 follower 返回了超过陈旧程度上限的数据")
+	// fast 的数据是新的，但是它以为自己落后了 1s，这是安全的，只是无法提供读取
+	_, err = ck.LocalGet(fast, "x", StaleRead, 100*time.Millisecond)
+	ast.Equal(ErrStale, err)
+	// ReadIndex 不依赖于时钟
+	value, err = ck.LocalGet(fast, "x", ReadIndexRead, 0)
+	ast.Equal(OK, err)
+	ast.Equal("new", value)
+}
+
+func Test_KVServer_closeLoopSkipsFollowers(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := MakeCluster(3)
+	defer c.Cleanup()
+	ck := c.MakeClerk()
+	ck.Put("x", "0")
+	// slow 的物理时钟落后于 HLC，每次读取 HLC 都会推进 Logical
+	slow := (c.Leader() + 1) % 3
+	c.servers[slow].SetClockSkew(-time.Second)
+	c.Disconnect(slow)
+	time.Sleep(2 * closeInterval)
+	//
+	last := func() Timestamp {
+		hlc := c.servers[slow].clock
+		hlc.mutex.Lock()
+		defer hlc.mutex.Unlock()
+		return hlc.last
+	}
+	before := last()
+	time.Sleep(4 * closeInterval)
+	ast.Equal(before, last(), "follower 不提交 no-op，也不会读取 HLC")
+}
@@ -0,0 +1,218 @@
+package kvstore
+
+import (
+	"sync/atomic"
+	"time"
+
+	raft "github.com/aQuaYi/Distributed-Algorithms/Raft/code"
+	"github.com/aQuaYi/Distributed-Algorithms/Raft/code/labrpc"
+	"github.com/aQuaYi/Distributed-Algorithms/Raft/code/rsm"
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+)
+
+// KVServer 是 key-value 服务器，其 state machine 通过 raft 复制
+type KVServer struct {
+	rsm   *rsm.Server
+	me    int
+	peers []*labrpc.ClientEnd // 所有 server 的端点，follower 通过它们向 leader 获取 ReadIndex
+	env   sim.Env
+
+	// 以下属性由 rsm 的锁保护
+	data     map[string]string
+	versions map[string]int        // 每个 key 被修改过的次数
+	locks    map[string]*lockState // 事务持有的锁
+	txns     map[TxnID]*txnState   // 在本 server 上还没有结束的事务
+
+	clock     *HLC
+	skew      int64     // 物理时钟与 env 的偏差，单位是纳秒，用于模拟时钟不同步
+	appliedTS Timestamp // 最后应用的 log 的 HLC 时间戳，由 rsm 的锁保护
+	applied   time.Time // 最后一次应用 log 的时间，由 rsm 的锁保护
+}
+
+// StartKVServer 启动一个 KVServer
+// servers 是所有 server 的 raft 端点，me 是自己在 servers 中的索引
+func StartKVServer(servers []*labrpc.ClientEnd, me int, persister *raft.Persister) *KVServer {
+	return StartKVServerWithEnv(servers, me, persister, sim.Env{})
+}
+
+// StartKVServerWithEnv 与 StartKVServer 一样，但是 kv 和它的 raft 从 env 读取时间和随机数
+func StartKVServerWithEnv(servers []*labrpc.ClientEnd, me int, persister *raft.Persister, env sim.Env) *KVServer {
+	kv := &KVServer{
+		me:       me,
+		peers:    servers,
+		env:      env,
+		data:     make(map[string]string, 1024),
+		versions: make(map[string]int, 1024),
+		locks:    make(map[string]*lockState, 64),
+		txns:     make(map[TxnID]*txnState, 16),
+	}
+	kv.clock = NewHLC(func() time.Time {
+		return env.Now().Add(time.Duration(atomic.LoadInt64(&kv.skew)))
+	})
+	kv.rsm = rsm.StartServer(servers, me, persister, kv, env)
+
+	go kv.closeLoop()
+
+	return kv
+}
+
+// Raft 返回 kv 所使用的 raft
+func (kv *KVServer) Raft() *raft.Raft {
+	return kv.rsm.Raft()
+}
+
+// Kill 停止 kv
+func (kv *KVServer) Kill() {
+	kv.rsm.Kill()
+}
+
+// SetClockSkew 让 kv 的物理时钟比 env 快 skew，skew 为负数时表示慢
+func (kv *KVServer) SetClockSkew(skew time.Duration) {
+	atomic.StoreInt64(&kv.skew, int64(skew))
+}
+
+// stamp 给 op 加上 HLC 时间戳，kv 不是 leader 时返回 false
+// 只有 leader 写入 log 的时间戳才有意义，follower 的 op 反正会被 rf.Start 拒绝，
+// 所以先用 GetState 跳过 follower，以免它们白白推进 HLC
+func (kv *KVServer) stamp(op Op) (Op, bool) {
+	if _, isLeader := kv.Raft().GetState(); !isLeader {
+		return op, false
+	}
+	op.TS = kv.clock.Now()
+	return op, true
+}
+
+// submit 给 op 加上 HLC 时间戳，写入 raft log，并等待其被应用，返回 Apply 的结果
+func (kv *KVServer) submit(op Op) (interface{}, Err) {
+	op, ok := kv.stamp(op)
+	if !ok {
+		return nil, ErrWrongLeader
+	}
+	return kv.rsm.Submit(op)
+}
+
+// Apply 应用 log 中的 Op，实现了 rsm.StateMachine 接口
+// CAS 返回 Err，事务操作返回 txnResult，其他的 Op 返回 nil
+func (kv *KVServer) Apply(command interface{}, index int) interface{} {
+	op, ok := command.(Op)
+	if !ok {
+		return nil
+	}
+	kv.clock.Update(op.TS)
+	if kv.appliedTS.Less(op.TS) {
+		kv.appliedTS = op.TS
+	}
+	kv.applied = kv.env.Now()
+
+	switch op.Type {
+	case opNoop:
+	case opPut:
+		kv.put(op.Key, op.Value)
+	case opDelete:
+		delete(kv.data, op.Key)
+		kv.versions[op.Key]++
+	case opCAS:
+		return kv.cas(op.Key, op.Expected, op.Value)
+	default:
+		return kv.applyTxn(op)
+	}
+	return nil
+}
+
+// cas 在 key 的值为 old 时，把它设置为 value，不存在的 key 视为空字符串
+func (kv *KVServer) cas(key, old, value string) Err {
+	if kv.data[key] != old {
+		return ErrMismatch
+	}
+	kv.put(key, value)
+	return OK
+}
+
+func (kv *KVServer) put(key, value string) {
+	kv.data[key] = value
+	kv.versions[key]++
+}
+
+// Put 是 RPC handler
+func (kv *KVServer) Put(args *PutArgs, reply *PutReply) {
+	_, reply.Err = kv.submit(Op{
+		Type:     opPut,
+		Key:      args.Key,
+		Value:    args.Value,
+		ClientID: args.ClientID,
+		Seq:      args.Seq,
+	})
+}
+
+// Delete 是 RPC handler
+func (kv *KVServer) Delete(args *DeleteArgs, reply *DeleteReply) {
+	_, reply.Err = kv.submit(Op{
+		Type:     opDelete,
+		Key:      args.Key,
+		ClientID: args.ClientID,
+		Seq:      args.Seq,
+	})
+}
+
+// CompareAndSwap 是 RPC handler
+func (kv *KVServer) CompareAndSwap(args *CASArgs, reply *CASReply) {
+	res, err := kv.submit(Op{
+		Type:     opCAS,
+		Key:      args.Key,
+		Value:    args.New,
+		Expected: args.Old,
+		ClientID: args.ClientID,
+		Seq:      args.Seq,
+	})
+	if err != OK {
+		reply.Err = err
+		return
+	}
+	reply.Err = res.(Err)
+}
+
+// Get 是 RPC handler，利用 ReadIndex 提供线性一致的读取
+func (kv *KVServer) Get(args *GetArgs, reply *GetReply) {
+	readIndex, ok := kv.readIndex()
+	if !ok {
+		reply.Err = ErrWrongLeader
+		return
+	}
+	kv.readAt(readIndex, args.Key, reply)
+}
+
+// readAt 等到 readIndex 被应用以后，从本地的 state machine 读取 key
+func (kv *KVServer) readAt(readIndex int, key string, reply *GetReply) {
+	if !kv.rsm.WaitApplied(readIndex) {
+		reply.Err = ErrTimeout
+		return
+	}
+
+	kv.rsm.Lock()
+	defer kv.rsm.Unlock()
+
+	kv.read(key, reply)
+}
+
+// read 从本地的 state machine 读取 key
+// 利用调用方的锁进行锁定
+func (kv *KVServer) read(key string, reply *GetReply) {
+	reply.Version = kv.versions[key]
+	reply.Applied = kv.appliedTS
+	value, exists := kv.data[key]
+	if !exists {
+		reply.Err = ErrNoKey
+		return
+	}
+	reply.Err, reply.Value = OK, value
+}
+
+// readIndex 获取 ReadIndex
+// 如果 leader 还没有 commit 过当前 term 的 log，rsm 会先提交一条带着 HLC 时间戳的 no-op
+func (kv *KVServer) readIndex() (int, bool) {
+	noop, ok := kv.stamp(Op{Type: opNoop})
+	if !ok {
+		return -1, false
+	}
+	return kv.rsm.ReadIndex(noop)
+}
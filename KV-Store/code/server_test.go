@@ -0,0 +1,125 @@
+package kvstore
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Cluster_PutGetDelete(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := MakeCluster(3)
+	defer c.Cleanup()
+	ck := c.MakeClerk()
+	//
+	_, ok := ck.Get("a")
+	ast.False(ok)
+	//
+	ck.Put("a", "1")
+	value, ok := ck.Get("a")
+	ast.True(ok)
+	ast.Equal("1", value)
+	//
+	ck.Put("a", "2")
+	value, _ = ck.Get("a")
+	ast.Equal("2", value)
+	//
+	ck.Delete("a")
+	_, ok = ck.Get("a")
+	ast.False(ok)
+}
+
+func Test_Cluster_concurrentClerks(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := MakeCluster(3)
+	defer c.Cleanup()
+	//
+	clerks, times := 5, 10
+	var wg sync.WaitGroup
+	wg.Add(clerks)
+	for i := 0; i < clerks; i++ {
+		go func(i int) {
+			defer wg.Done()
+			ck := c.MakeClerk()
+			key := fmt.Sprintf("k%d", i)
+			for j := 0; j < times; j++ {
+				ck.Put(key, fmt.Sprint(j))
+				// 同一个 Clerk 一定能读到自己刚刚写入的值
+				value, _ := ck.Get(key)
+				ast.Equal(fmt.Sprint(j), value)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func Test_Cluster_readAfterLeaderIsolated(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := MakeCluster(3)
+	defer c.Cleanup()
+	ck := c.MakeClerk()
+	//
+	ck.Put("x", "old")
+	oldLeader := c.Leader()
+	ast.NotEqual(-1, oldLeader)
+	// 隔离 leader，但 Clerk 依然可以访问它
+	c.Disconnect(oldLeader)
+	ck.Put("x", "new")
+	// 旧 leader 无法确认自己的身份，所以不会返回过期的值
+	var reply GetReply
+	c.servers[oldLeader].Get(&GetArgs{Key: "x"}, &reply)
+	ast.NotEqual(OK, reply.Err)
+	//
+	value, _ := ck.Get("x")
+	ast.Equal("new", value)
+	//
+	c.Connect(oldLeader)
+	time.Sleep(time.Second)
+	value, _ = c.MakeClerk().Get("x")
+	ast.Equal("new", value)
+}
+
+func Test_KVServer_Apply(t *testing.T) {
+	ast := assert.New(t)
+	//
+	kv := newTxnKVServer()
+	ts := kv.clock.Now()
+	ast.Nil(kv.Apply(Op{Type: opPut, Key: "a", Value: "1", ClientID: 7, Seq: 1, TS: ts}, 1))
+	ast.Equal("1", kv.data["a"])
+	ast.Equal(ts, kv.appliedTS)
+	// CAS 返回 Err，事务操作返回 txnResult
+	ast.Equal(ErrMismatch, kv.Apply(Op{Type: opCAS, Key: "a", Expected: "0", Value: "2", ClientID: 7, Seq: 2}, 2))
+	ast.Equal(OK, kv.Apply(Op{Type: opCAS, Key: "a", Expected: "1", Value: "2", ClientID: 7, Seq: 3}, 3))
+	ast.Equal(txnResult{err: OK, value: "2", exists: true, version: 2},
+		kv.Apply(Op{Type: opLock, Key: "a", Txn: TxnID{Start: 1}, ClientID: 7, Seq: 4}, 4))
+	// 更早的时间戳不会让 appliedTS 倒退，no-op 不修改数据
+	ast.Nil(kv.Apply(Op{Type: opNoop}, 5))
+	ast.Equal(ts, kv.appliedTS)
+	ast.Equal(map[string]string{"a": "2"}, kv.data)
+}
+
+func Test_Cluster_CompareAndSwap(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := MakeCluster(3)
+	defer c.Cleanup()
+	ck := c.MakeClerk()
+	//
+	ast.True(ck.CompareAndSwap("a", "", "1"), "不存在的 key 视为空字符串")
+	ast.False(ck.CompareAndSwap("a", "", "2"))
+	ast.True(ck.CompareAndSwap("a", "1", "2"))
+	value, _ := ck.Get("a")
+	ast.Equal("2", value)
+}
+
+func Test_Op_String(t *testing.T) {
+	ast := assert.New(t)
+	op := Op{Type: opPut, Key: "k", Value: "v", ClientID: 1, Seq: 2}
+	ast.Equal(`Put{"k":"v", C1:S2}`, op.String())
+}
@@ -0,0 +1,50 @@
+package kvstore
+
+import "github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+
+// Shards 运行多个 Cluster，每个 Cluster 负责一个 shard
+// key 按照 hash 分配到 shard 上，跨 shard 的事务由 TxnClient 通过 2PC 协调
+type Shards struct {
+	groups []*Cluster
+	env    sim.Env
+}
+
+// MakeShards 启动 shards 个 shard，每个 shard 由 replicas 个 KVServer 组成
+func MakeShards(shards, replicas int) *Shards {
+	return MakeShardsWithEnv(shards, replicas, sim.Env{})
+}
+
+// MakeShardsWithEnv 与 MakeShards 一样，但是所有的 shard 和 TxnClient 都从 env 读取时间和随机数
+func MakeShardsWithEnv(shards, replicas int, env sim.Env) *Shards {
+	s := &Shards{groups: make([]*Cluster, shards), env: env}
+	for i := range s.groups {
+		s.groups[i] = MakeClusterWithEnv(replicas, env)
+	}
+	return s
+}
+
+// Group 返回负责第 i 个 shard 的 Cluster
+func (s *Shards) Group(i int) *Cluster {
+	return s.groups[i]
+}
+
+// ShardOf 返回 key 所在的 shard
+func (s *Shards) ShardOf(key string) int {
+	return shardOf(key, len(s.groups))
+}
+
+// MakeTxnClient 返回一个可以访问所有 shard 的 TxnClient
+func (s *Shards) MakeTxnClient(mode Mode) *TxnClient {
+	clerks := make([]*Clerk, len(s.groups))
+	for i, c := range s.groups {
+		clerks[i] = c.MakeClerk()
+	}
+	return MakeTxnClientWithEnv(clerks, mode, s.env)
+}
+
+// Cleanup 关闭所有的 shard
+func (s *Shards) Cleanup() {
+	for _, c := range s.groups {
+		c.Cleanup()
+	}
+}
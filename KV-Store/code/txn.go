@@ -0,0 +1,197 @@
+package kvstore
+
+// lockState 是一个 key 上的锁
+// 同一时刻，要么只有一个写者，要么有多个读者
+type lockState struct {
+	writer  TxnID // 零值表示没有写者
+	readers map[TxnID]bool
+}
+
+func (l *lockState) isFree() bool {
+	return l.writer == TxnID{} && len(l.readers) == 0
+}
+
+// txnState 是事务在一个 shard 上的状态
+type txnState struct {
+	keys     map[string]bool // 事务在本 shard 上持有锁的 key
+	writes   []KeyValue      // prepare 时收到的写集合
+	prepared bool
+}
+
+// txnResult 是事务操作被应用后的结果
+type txnResult struct {
+	err     Err
+	value   string
+	exists  bool
+	version int
+}
+
+// 利用 rsm 的锁进行锁定
+func (kv *KVServer) applyTxn(op Op) txnResult {
+	switch op.Type {
+	case opLock:
+		return kv.lock(op.Txn, op.Key, op.Write)
+	case opPrepare:
+		return txnResult{err: kv.prepare(op)}
+	case opCommit:
+		kv.commit(op.Txn)
+	case opAbort:
+		kv.release(op.Txn)
+	}
+	return txnResult{err: OK}
+}
+
+func (kv *KVServer) txn(id TxnID) *txnState {
+	t, ok := kv.txns[id]
+	if !ok {
+		t = &txnState{keys: make(map[string]bool, 4)}
+		kv.txns[id] = t
+	}
+	return t
+}
+
+// conflicts 返回与 id 申请的锁冲突的事务
+func (kv *KVServer) conflicts(key string, id TxnID, write bool) []TxnID {
+	l, ok := kv.locks[key]
+	if !ok {
+		return nil
+	}
+	var res []TxnID
+	if l.writer != (TxnID{}) && l.writer != id {
+		res = append(res, l.writer)
+	}
+	if write {
+		for r := range l.readers {
+			if r != id {
+				res = append(res, r)
+			}
+		}
+	}
+	return res
+}
+
+// grant 把 key 上的锁交给 id，调用前需要确认没有冲突
+func (kv *KVServer) grant(key string, id TxnID, write bool) {
+	l, ok := kv.locks[key]
+	if !ok {
+		l = &lockState{readers: make(map[TxnID]bool, 2)}
+		kv.locks[key] = l
+	}
+	if write {
+		l.writer = id
+		delete(l.readers, id)
+	} else if l.writer != id {
+		l.readers[id] = true
+	}
+	kv.txn(id).keys[key] = true
+}
+
+// lock 为 2PL 的事务申请 key 上的锁，成功时返回 key 的值
+//
+// 锁被其他事务持有时，使用 wait-die 避免死锁：
+// 比所有持有者都老的事务可以等待，否则直接 abort。
+// 等待只会发生在老事务等新事务的时候，等待图中不会出现环。
+// abort 的事务沿用原来的 Start 重试，总会变成最老的事务，所以不会饿死。
+func (kv *KVServer) lock(id TxnID, key string, write bool) txnResult {
+	holders := kv.conflicts(key, id, write)
+	for _, h := range holders {
+		if !id.olderThan(h) {
+			return txnResult{err: ErrAborted}
+		}
+	}
+	if len(holders) > 0 {
+		return txnResult{err: ErrWait}
+	}
+	kv.grant(key, id, write)
+	value, exists := kv.data[key]
+	return txnResult{err: OK, value: value, exists: exists, version: kv.versions[key]}
+}
+
+// prepare 是 2PC 的第一阶段，返回 OK 表示本 shard 承诺可以 commit
+//
+// 2PL 的事务已经持有了写集合的写锁，只需要记下写集合。
+// 乐观的事务在这里验证读集合的版本没有变化，并且读写集合都没有被其他事务锁住，
+// 然后为它们加锁，直到 commit 或者 abort。验证失败时不等待，直接 abort。
+func (kv *KVServer) prepare(op Op) Err {
+	if op.Optimistic {
+		for _, r := range op.Reads {
+			if kv.versions[r.Key] != r.Version || len(kv.conflicts(r.Key, op.Txn, false)) > 0 {
+				return ErrAborted
+			}
+		}
+		for _, w := range op.Writes {
+			if len(kv.conflicts(w.Key, op.Txn, true)) > 0 {
+				return ErrAborted
+			}
+		}
+		for _, r := range op.Reads {
+			kv.grant(r.Key, op.Txn, false)
+		}
+		for _, w := range op.Writes {
+			kv.grant(w.Key, op.Txn, true)
+		}
+	} else {
+		for _, w := range op.Writes {
+			l, ok := kv.locks[w.Key]
+			if !ok || l.writer != op.Txn {
+				return ErrAborted
+			}
+		}
+	}
+	t := kv.txn(op.Txn)
+	t.writes = op.Writes
+	t.prepared = true
+	return OK
+}
+
+// commit 是 2PC 的第二阶段，应用写集合并释放锁
+func (kv *KVServer) commit(id TxnID) {
+	t, ok := kv.txns[id]
+	if !ok || !t.prepared {
+		return
+	}
+	for _, w := range t.writes {
+		kv.put(w.Key, w.Value)
+	}
+	kv.release(id)
+}
+
+// release 释放 id 持有的所有锁，并丢弃它的写集合
+func (kv *KVServer) release(id TxnID) {
+	t, ok := kv.txns[id]
+	if !ok {
+		return
+	}
+	for key := range t.keys {
+		l := kv.locks[key]
+		if l.writer == id {
+			l.writer = TxnID{}
+		}
+		delete(l.readers, id)
+		if l.isFree() {
+			delete(kv.locks, key)
+		}
+	}
+	delete(kv.txns, id)
+}
+
+// Txn 是 RPC handler，处理事务的 Lock、Prepare、Commit 和 Abort
+func (kv *KVServer) Txn(args *TxnArgs, reply *TxnReply) {
+	res, err := kv.submit(Op{
+		Type:       args.Type,
+		Key:        args.Key,
+		ClientID:   args.ClientID,
+		Seq:        args.Seq,
+		Txn:        args.Txn,
+		Write:      args.Write,
+		Optimistic: args.Optimistic,
+		Reads:      args.Reads,
+		Writes:     args.Writes,
+	})
+	if err != OK {
+		reply.Err = err
+		return
+	}
+	r := res.(txnResult)
+	reply.Err, reply.Value, reply.Exists, reply.Version = r.err, r.value, r.exists, r.version
+}
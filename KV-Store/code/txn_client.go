@@ -0,0 +1,292 @@
+package kvstore
+
+import (
+	"errors"
+	"hash/fnv"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aQuaYi/Distributed-Algorithms/Raft/code/rsm"
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+)
+
+// lockRetryInterval 是 2PL 的事务等待锁时，重试的间隔
+const lockRetryInterval = 10 * time.Millisecond
+
+var (
+	// ErrTxnAborted 表示事务因为冲突被 abort，可以重试
+	ErrTxnAborted = errors.New("kvstore: transaction aborted")
+	// ErrTxnDone 表示事务已经 commit 或者 abort 了
+	ErrTxnDone = errors.New("kvstore: transaction already finished")
+)
+
+// Mode 是事务的并发控制方式
+type Mode int
+
+// 枚举了所有的 Mode
+const (
+	// TwoPhaseLocking 在读写时就加锁，commit 或者 abort 时才释放，用 wait-die 处理死锁
+	TwoPhaseLocking Mode = iota
+	// Optimistic 读写时不加锁，在 prepare 时验证读集合没有被修改
+	Optimistic
+)
+
+func (m Mode) String() string {
+	if m == Optimistic {
+		return "Optimistic"
+	}
+	return "2PL"
+}
+
+// shardOf 返回 key 所在的 shard
+func shardOf(key string, shards int) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(shards))
+}
+
+// TxnClient 在多个 shard 上执行事务，每个 shard 是一组通过 raft 复制的 KVServer
+// TxnClient 同时也是 2PC 的 coordinator
+type TxnClient struct {
+	shards []*Clerk
+	mode   Mode
+	env    sim.Env
+}
+
+// MakeTxnClient 返回一个 TxnClient，shards[i] 是访问第 i 个 shard 的 Clerk
+func MakeTxnClient(shards []*Clerk, mode Mode) *TxnClient {
+	return MakeTxnClientWithEnv(shards, mode, sim.Env{})
+}
+
+// MakeTxnClientWithEnv 与 MakeTxnClient 一样，但是事务的时间戳和重试的等待都来自 env
+func MakeTxnClientWithEnv(shards []*Clerk, mode Mode, env sim.Env) *TxnClient {
+	return &TxnClient{
+		shards: shards,
+		mode:   mode,
+		env:    env,
+	}
+}
+
+// Begin 开始一个新的事务
+func (tc *TxnClient) Begin() *Txn {
+	return tc.begin(tc.env.Now().UnixNano())
+}
+
+func (tc *TxnClient) begin(start int64) *Txn {
+	return &Txn{
+		tc:     tc,
+		id:     TxnID{Start: start, ID: rsm.RandomID()},
+		reads:  make(map[string]read, 8),
+		writes: make(map[string]string, 8),
+		locked: make(map[int]bool, len(tc.shards)),
+	}
+}
+
+// Run 在事务中执行 fn，并 commit 事务
+// 事务因为冲突被 abort 时，沿用原来的 Start 重试，fn 返回其他错误时，abort 事务并返回该错误
+func (tc *TxnClient) Run(fn func(tx *Txn) error) error {
+	start := tc.env.Now().UnixNano()
+	for {
+		tx := tc.begin(start)
+		err := fn(tx)
+		if err == nil {
+			err = tx.Commit()
+		} else {
+			tx.Abort()
+		}
+		if err != ErrTxnAborted {
+			return err
+		}
+		tc.env.Sleep(time.Duration(tc.env.Intn(20)) * time.Millisecond)
+	}
+}
+
+// read 是事务读到的值
+type read struct {
+	value   string
+	exists  bool
+	version int
+	locked  bool // 2PL 的事务已经持有写锁
+}
+
+// Txn 是一个事务
+// 写入的值缓存在 Txn 中，直到 commit 时才通过 2PC 写入各个 shard。
+// Txn 不能被多个 goroutine 同时使用。
+type Txn struct {
+	tc     *TxnClient
+	id     TxnID
+	reads  map[string]read
+	writes map[string]string
+	locked map[int]bool // 持有锁的 shard
+	done   bool
+}
+
+// ID 返回事务的 id
+func (tx *Txn) ID() TxnID {
+	return tx.id
+}
+
+// Get 返回 key 的值，key 不存在时返回 false
+// 事务会读到自己写入的值
+func (tx *Txn) Get(key string) (string, bool, error) {
+	return tx.get(key, false)
+}
+
+// GetForUpdate 与 Get 相同，但 2PL 的事务会直接申请写锁
+// 先读后写同一个 key 时，避免了两个事务都持有读锁，又都想升级为写锁的冲突
+func (tx *Txn) GetForUpdate(key string) (string, bool, error) {
+	return tx.get(key, true)
+}
+
+func (tx *Txn) get(key string, forUpdate bool) (string, bool, error) {
+	if tx.done {
+		return "", false, ErrTxnDone
+	}
+	if value, ok := tx.writes[key]; ok {
+		return value, true, nil
+	}
+	if r, ok := tx.reads[key]; ok && (r.locked || !forUpdate || tx.tc.mode == Optimistic) {
+		return r.value, r.exists, nil
+	}
+
+	var r read
+	if tx.tc.mode == Optimistic {
+		r.value, r.exists, r.version = tx.tc.shards[shardOf(key, len(tx.tc.shards))].get(key)
+	} else {
+		reply, err := tx.lock(key, forUpdate)
+		if err != nil {
+			return "", false, err
+		}
+		r.value, r.exists = reply.Value, reply.Exists
+		r.locked = forUpdate
+	}
+	tx.reads[key] = r
+	return r.value, r.exists, nil
+}
+
+// Put 把 key 的值设置为 value，在 commit 以后生效
+func (tx *Txn) Put(key, value string) error {
+	if tx.done {
+		return ErrTxnDone
+	}
+	if tx.tc.mode == TwoPhaseLocking {
+		if _, ok := tx.writes[key]; !ok && !tx.reads[key].locked {
+			if _, err := tx.lock(key, true); err != nil {
+				return err
+			}
+		}
+	}
+	tx.writes[key] = value
+	return nil
+}
+
+// lock 申请 key 上的锁，锁被更老的事务持有时会一直等待
+// 事务被 abort 时，释放所有的锁，并返回 ErrTxnAborted
+func (tx *Txn) lock(key string, write bool) (TxnReply, error) {
+	shard := shardOf(key, len(tx.tc.shards))
+	tx.locked[shard] = true
+	args := TxnArgs{Type: opLock, Txn: tx.id, Key: key, Write: write}
+	for {
+		reply := tx.tc.shards[shard].txn(args)
+		switch reply.Err {
+		case OK:
+			return reply, nil
+		case ErrWait:
+			tx.tc.env.Sleep(lockRetryInterval)
+		default:
+			tx.Abort()
+			return reply, ErrTxnAborted
+		}
+	}
+}
+
+// Commit 使用 2PC 提交事务
+// 所有的参与者都 prepare 成功以后，事务才会 commit，否则 abort 并返回 ErrTxnAborted
+func (tx *Txn) Commit() error {
+	if tx.done {
+		return ErrTxnDone
+	}
+
+	optimistic := tx.tc.mode == Optimistic
+	prepares := make(map[int]*TxnArgs, len(tx.tc.shards))
+	participant := func(shard int) *TxnArgs {
+		args, ok := prepares[shard]
+		if !ok {
+			args = &TxnArgs{Type: opPrepare, Txn: tx.id, Optimistic: optimistic}
+			prepares[shard] = args
+		}
+		return args
+	}
+	for shard := range tx.locked {
+		participant(shard)
+	}
+	for key, value := range tx.writes {
+		args := participant(shardOf(key, len(tx.tc.shards)))
+		args.Writes = append(args.Writes, KeyValue{Key: key, Value: value})
+	}
+	if optimistic {
+		for key, r := range tx.reads {
+			args := participant(shardOf(key, len(tx.tc.shards)))
+			args.Reads = append(args.Reads, KeyVersion{Key: key, Version: r.version})
+		}
+	}
+
+	// 第一阶段：所有的参与者都投票同意，才能 commit
+	shards := make([]int, 0, len(prepares))
+	for shard := range prepares {
+		shards = append(shards, shard)
+	}
+	sort.Ints(shards)
+	votes := tx.broadcast(shards, func(shard int) TxnArgs {
+		return *prepares[shard]
+	})
+	for _, vote := range votes {
+		if vote != OK {
+			// 投票失败的参与者没有 prepare，但是可能持有 2PL 的锁，所以通知所有的参与者
+			tx.abort(shards)
+			return ErrTxnAborted
+		}
+	}
+
+	// 第二阶段
+	tx.broadcast(shards, func(int) TxnArgs {
+		return TxnArgs{Type: opCommit, Txn: tx.id}
+	})
+	tx.done = true
+	return nil
+}
+
+// Abort 放弃事务，释放所有的锁
+func (tx *Txn) Abort() {
+	if tx.done {
+		return
+	}
+	shards := make([]int, 0, len(tx.locked))
+	for shard := range tx.locked {
+		shards = append(shards, shard)
+	}
+	tx.abort(shards)
+}
+
+func (tx *Txn) abort(shards []int) {
+	tx.broadcast(shards, func(int) TxnArgs {
+		return TxnArgs{Type: opAbort, Txn: tx.id}
+	})
+	tx.done = true
+}
+
+// broadcast 并行地向 shards 发送 argsOf 生成的事务操作，返回各个 shard 的结果
+func (tx *Txn) broadcast(shards []int, argsOf func(shard int) TxnArgs) []Err {
+	res := make([]Err, len(shards))
+	var wg sync.WaitGroup
+	for i, shard := range shards {
+		wg.Add(1)
+		go func(i, shard int) {
+			defer wg.Done()
+			res[i] = tx.tc.shards[shard].txn(argsOf(shard)).Err
+		}(i, shard)
+	}
+	wg.Wait()
+	return res
+}
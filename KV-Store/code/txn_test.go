@@ -0,0 +1,231 @@
+package kvstore
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var modes = []Mode{TwoPhaseLocking, Optimistic}
+
+func Test_Txn_commitAcrossShards(t *testing.T) {
+	ast := assert.New(t)
+	//
+	for _, mode := range modes {
+		s := MakeShards(2, 3)
+		tc := s.MakeTxnClient(mode)
+		keys := []string{"a", "b", "c", "d"}
+		shards := make(map[int]bool)
+		//
+		tx := tc.Begin()
+		for _, key := range keys {
+			shards[s.ShardOf(key)] = true
+			ast.Nil(tx.Put(key, key+"1"), "%s", mode)
+		}
+		value, exists, err := tx.Get("a")
+		ast.Nil(err)
+		ast.True(exists, "事务可以读到自己写入的值")
+		ast.Equal("a1", value)
+		ast.Nil(tx.Commit(), "%s", mode)
+		ast.Equal(ErrTxnDone, tx.Commit())
+		ast.Equal(2, len(shards), "事务需要跨越两个 shard")
+		//
+		for _, key := range keys {
+			value, exists := s.Group(s.ShardOf(key)).MakeClerk().Get(key)
+			ast.True(exists, "%s %s", mode, key)
+			ast.Equal(key+"1", value)
+		}
+		s.Cleanup()
+	}
+}
+
+func Test_Txn_abortDiscardsWrites(t *testing.T) {
+	ast := assert.New(t)
+	//
+	for _, mode := range modes {
+		s := MakeShards(2, 3)
+		tc := s.MakeTxnClient(mode)
+		//
+		tx := tc.Begin()
+		ast.Nil(tx.Put("a", "1"))
+		ast.Nil(tx.Put("b", "1"))
+		tx.Abort()
+		_, _, err := tx.Get("a")
+		ast.Equal(ErrTxnDone, err)
+		// abort 释放了锁，后面的事务可以修改同样的 key
+		ast.Nil(tc.Run(func(tx *Txn) error {
+			_, exists, err := tx.Get("a")
+			ast.False(exists, "%s", mode)
+			if err != nil {
+				return err
+			}
+			return tx.Put("a", "2")
+		}))
+		value, _ := s.Group(s.ShardOf("a")).MakeClerk().Get("a")
+		ast.Equal("2", value)
+		s.Cleanup()
+	}
+}
+
+func Test_Txn_optimisticValidation(t *testing.T) {
+	ast := assert.New(t)
+	//
+	s := MakeShards(2, 3)
+	defer s.Cleanup()
+	tc := s.MakeTxnClient(Optimistic)
+	//
+	t1 := tc.Begin()
+	_, _, err := t1.Get("x")
+	ast.Nil(err)
+	ast.Nil(t1.Put("y", "from t1"))
+	// t2 在 t1 读取 x 以后修改了 x
+	t2 := tc.Begin()
+	ast.Nil(t2.Put("x", "from t2"))
+	ast.Nil(t2.Commit())
+	// t1 读到的 x 已经过期，验证失败
+	ast.Equal(ErrTxnAborted, t1.Commit())
+	_, exists := s.Group(s.ShardOf("y")).MakeClerk().Get("y")
+	ast.False(exists)
+}
+
+// Test_Txn_bankTransfer 让多个 client 并发地在跨 shard 的账户之间转账
+// 转账的顺序是随机的，2PL 会遇到死锁，乐观事务会遇到验证失败，但总金额始终不变
+func Test_Txn_bankTransfer(t *testing.T) {
+	ast := assert.New(t)
+	//
+	const accounts, initial, clients, transfers = 5, 100, 4, 4
+	for _, mode := range modes {
+		s := MakeShards(3, 3)
+		name := func(i int) string {
+			return fmt.Sprintf("account-%d", i)
+		}
+		ast.Nil(s.MakeTxnClient(mode).Run(func(tx *Txn) error {
+			for i := 0; i < accounts; i++ {
+				if err := tx.Put(name(i), strconv.Itoa(initial)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}))
+		//
+		var wg sync.WaitGroup
+		for c := 0; c < clients; c++ {
+			wg.Add(1)
+			go func(c int) {
+				defer wg.Done()
+				tc := s.MakeTxnClient(mode)
+				rnd := rand.New(rand.NewSource(int64(c)))
+				for i := 0; i < transfers; i++ {
+					from := rnd.Intn(accounts)
+					to := (from + 1 + rnd.Intn(accounts-1)) % accounts
+					err := tc.Run(func(tx *Txn) error {
+						return transfer(tx, name(from), name(to), 10)
+					})
+					ast.Nil(err)
+				}
+			}(c)
+		}
+		wg.Wait()
+		//
+		total := 0
+		ast.Nil(s.MakeTxnClient(mode).Run(func(tx *Txn) error {
+			total = 0
+			for i := 0; i < accounts; i++ {
+				value, _, err := tx.Get(name(i))
+				if err != nil {
+					return err
+				}
+				n, _ := strconv.Atoi(value)
+				total += n
+			}
+			return nil
+		}))
+		ast.Equal(accounts*initial, total, "%s", mode)
+		s.Cleanup()
+	}
+}
+
+func transfer(tx *Txn, from, to string, amount int) error {
+	a, _, err := tx.GetForUpdate(from)
+	if err != nil {
+		return err
+	}
+	b, _, err := tx.GetForUpdate(to)
+	if err != nil {
+		return err
+	}
+	x, _ := strconv.Atoi(a)
+	y, _ := strconv.Atoi(b)
+	if err := tx.Put(from, strconv.Itoa(x-amount)); err != nil {
+		return err
+	}
+	return tx.Put(to, strconv.Itoa(y+amount))
+}
+
+func newTxnKVServer() *KVServer {
+	return &KVServer{
+		data:     make(map[string]string),
+		versions: make(map[string]int),
+		locks:    make(map[string]*lockState),
+		txns:     make(map[TxnID]*txnState),
+		clock:    NewHLC(time.Now),
+	}
+}
+
+func Test_KVServer_waitDie(t *testing.T) {
+	ast := assert.New(t)
+	//
+	kv := newTxnKVServer()
+	older, younger := TxnID{Start: 1}, TxnID{Start: 2}
+	ast.Equal(OK, kv.lock(older, "a", false).err)
+	ast.Equal(OK, kv.lock(younger, "a", false).err, "读锁互相兼容")
+	// 老事务等待新事务
+	ast.Equal(ErrWait, kv.lock(older, "a", true).err)
+	// 新事务不等待老事务
+	ast.Equal(ErrAborted, kv.lock(younger, "a", true).err)
+	kv.release(younger)
+	ast.Equal(OK, kv.lock(older, "a", true).err, "升级为写锁")
+	ast.Equal(ErrAborted, kv.lock(younger, "a", false).err)
+	//
+	kv.prepare(Op{Txn: older, Writes: []KeyValue{{Key: "a", Value: "1"}}})
+	kv.commit(older)
+	ast.Equal("1", kv.data["a"])
+	ast.Equal(0, len(kv.locks))
+	ast.Equal(0, len(kv.txns))
+}
+
+func Test_KVServer_prepare(t *testing.T) {
+	ast := assert.New(t)
+	//
+	kv := newTxnKVServer()
+	kv.put("a", "0")
+	t1, t2 := TxnID{Start: 1}, TxnID{Start: 2}
+	// 2PL 的事务必须已经持有写锁
+	ast.Equal(ErrAborted, kv.prepare(Op{Txn: t1, Writes: []KeyValue{{Key: "a", Value: "1"}}}))
+	// 乐观的事务验证读集合的版本
+	stale := Op{Txn: t1, Optimistic: true, Reads: []KeyVersion{{Key: "a", Version: 0}}}
+	ast.Equal(ErrAborted, kv.prepare(stale))
+	fresh := Op{Txn: t1, Optimistic: true, Reads: []KeyVersion{{Key: "a", Version: 1}}}
+	ast.Equal(OK, kv.prepare(fresh))
+	// t1 prepare 以后持有 a 的读锁，t2 不能写 a
+	ast.Equal(ErrAborted, kv.prepare(Op{Txn: t2, Optimistic: true, Writes: []KeyValue{{Key: "a", Value: "2"}}}))
+	kv.commit(t1)
+	ast.Equal(OK, kv.prepare(Op{Txn: t2, Optimistic: true, Writes: []KeyValue{{Key: "a", Value: "2"}}}))
+	kv.commit(t2)
+	ast.Equal("2", kv.data["a"])
+	ast.Equal(2, kv.versions["a"])
+}
+
+func Test_Op_String_txn(t *testing.T) {
+	ast := assert.New(t)
+	//
+	op := Op{Type: opLock, Key: "k", Write: true, Txn: TxnID{Start: 3, ID: 4}, ClientID: 1, Seq: 2}
+	ast.Equal(`Lock{"k", write:true, T3.4, C1:S2}`, op.String())
+	op = Op{Type: opCommit, Txn: TxnID{Start: 3, ID: 4}, ClientID: 1, Seq: 2}
+	ast.Equal(`Commit{T3.4, C1:S2}`, op.String())
+}
@@ -0,0 +1,80 @@
+package kademlia
+
+import (
+	"fmt"
+	"testing"
+
+	chord "github.com/aQuaYi/Distributed-Algorithms/Chord/code"
+	transport "github.com/aQuaYi/Distributed-Algorithms/Transport/code"
+	"github.com/stretchr/testify/assert"
+)
+
+// 在同样规模的模拟网络中，比较 Chord 和 Kademlia 的查找延迟和抗 churn 能力
+// Chord 的查找是递归的，延迟是跳数；Kademlia 的查找是迭代并行的，延迟是轮数
+func Test_compareWithChord(t *testing.T) {
+	ast := assert.New(t)
+	//
+	size, keys := 64, 200
+	//
+	csn := transport.NewSimNetwork()
+	cnodes := make([]*chord.Node, 0, size)
+	for i := 0; i < size; i++ {
+		n, _ := chord.NewNode(fmt.Sprintf("node-%d", i), csn)
+		if i == 0 {
+			n.Create()
+		} else {
+			n.Join(cnodes[0].Ref().Addr)
+		}
+		cnodes = append(cnodes, n)
+		for r := 0; r < 2; r++ {
+			for _, cn := range cnodes {
+				cn.Stabilize()
+			}
+		}
+	}
+	for _, n := range cnodes {
+		n.FixAllFingers()
+	}
+	//
+	ksn := transport.NewSimNetwork()
+	knodes := makeNetwork(ksn, size, 8)
+	// 两者运行在同一种 SimNetwork 上，RPC 次数可以直接比较
+	chordCalls, kadCalls := csn.Calls(), ksn.Calls()
+	chordHops, kadRounds := 0, 0
+	for i := 0; i < keys; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		cnodes[i%size].Put(key, key)
+		_, hops := cnodes[(i+1)%size].Lookup(key)
+		chordHops += hops
+		knodes[i%size].Put(key, key)
+		_, _, rounds := knodes[(i+1)%size].Get(key)
+		kadRounds += rounds
+	}
+	t.Logf("平均查找延迟：Chord %.2f 跳，Kademlia %.2f 轮",
+		float64(chordHops)/float64(keys), float64(kadRounds)/float64(keys))
+	t.Logf("Put 和查找的 RPC 次数：Chord %d，Kademlia %d", csn.Calls()-chordCalls, ksn.Calls()-kadCalls)
+	// 让 1/4 的 node 突然失效，且都不做任何维护
+	for i := 0; i < size; i += 4 {
+		cnodes[i].Leave()
+		knodes[i].Leave()
+	}
+	chordFound, kadFound := 0, 0
+	for i := 0; i < keys; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		from := 1 + i%(size-1)
+		if from%4 == 0 {
+			from++
+		}
+		if _, ok, err := cnodes[from].Get(key); err == nil && ok {
+			chordFound++
+		}
+		if _, ok, _ := knodes[from].Get(key); ok {
+			kadFound++
+		}
+	}
+	t.Logf("1/4 的 node 失效以后，能够读取到的 key：Chord %d/%d，Kademlia %d/%d",
+		chordFound, keys, kadFound, keys)
+	// Chord 的每个 key 只有一份，Kademlia 的每个 key 有 k 份
+	ast.True(kadFound >= chordFound)
+	ast.Equal(keys, kadFound)
+}
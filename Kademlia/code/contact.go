@@ -0,0 +1,47 @@
+package kademlia
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"sort"
+)
+
+// b 是 ID 的位数，也是 k-bucket 的数量
+const b = 64
+
+// hash 把 key 映射到 ID 空间
+func hash(key string) uint64 {
+	sum := sha1.Sum([]byte(key))
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+// distance 是 Kademlia 的 XOR 距离
+func distance(x, y uint64) uint64 {
+	return x ^ y
+}
+
+// bucketIndex 返回 other 应该放入 me 的第几个 k-bucket
+// 第 i 个 k-bucket 存放与 me 距离在 [2^i, 2^(i+1)) 之间的 contact
+// other == me 时，返回 -1
+func bucketIndex(me, other uint64) int {
+	return bits.Len64(distance(me, other)) - 1
+}
+
+// Contact 是 node 的联系方式
+type Contact struct {
+	ID   uint64
+	Addr string
+}
+
+func (c Contact) String() string {
+	return fmt.Sprintf("<%016x:%s>", c.ID, c.Addr)
+}
+
+// sortByDistance 把 cs 按照到 target 的距离，由近到远排序
+func sortByDistance(cs []Contact, target uint64) {
+	sort.Slice(cs, func(i, j int) bool {
+		return distance(cs[i].ID, target) < distance(cs[j].ID, target)
+	})
+}
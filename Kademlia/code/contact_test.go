@@ -0,0 +1,34 @@
+package kademlia
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_distance(t *testing.T) {
+	ast := assert.New(t)
+	ast.Equal(uint64(0), distance(7, 7))
+	ast.Equal(uint64(6), distance(5, 3))
+	ast.Equal(distance(5, 3), distance(3, 5))
+}
+
+func Test_bucketIndex(t *testing.T) {
+	ast := assert.New(t)
+	ast.Equal(-1, bucketIndex(9, 9))
+	ast.Equal(0, bucketIndex(8, 9))
+	ast.Equal(3, bucketIndex(0, 8))
+	ast.Equal(63, bucketIndex(0, 1<<63))
+}
+
+func Test_sortByDistance(t *testing.T) {
+	ast := assert.New(t)
+	cs := []Contact{{ID: 8}, {ID: 1}, {ID: 3}, {ID: 2}}
+	sortByDistance(cs, 0)
+	ast.Equal([]Contact{{ID: 1}, {ID: 2}, {ID: 3}, {ID: 8}}, cs)
+	// XOR 距离与数值距离不同
+	sortByDistance(cs, 3)
+	ast.Equal(uint64(3), cs[0].ID)
+	ast.Equal(uint64(2), cs[1].ID)
+	ast.Equal(uint64(1), cs[2].ID)
+}
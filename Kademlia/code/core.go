@@ -0,0 +1,302 @@
+package kademlia
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	core "github.com/aQuaYi/Distributed-Algorithms/Core/code"
+)
+
+func init() {
+	core.Register(core.Algorithm{
+		Name:    "kademlia",
+		Kind:    "dht",
+		Summary: "Kademlia 的迭代查找，node 依次通过 P0 加入网络，全部加入以后各自查找几个 key",
+		Params: map[string]string{
+			"k":       "k-bucket 的容量，默认为 20",
+			"lookups": "每个 node 查找的 key 的数量，默认为 3",
+			"join":    "相邻两个 node 加入网络的间隔，默认为 50ms",
+			"timeout": "等待 FindNode 回复的时间，超时的 node 被当作失效，默认为 100ms",
+		},
+		New:   newCoreKademlia,
+		Check: allFound,
+	})
+}
+
+// findNodeRequest 是 FindNode 请求的 message，Lookup 是发起请求的查找的编号
+type findNodeRequest struct {
+	Lookup int
+	FindNodeArgs
+}
+
+func (r findNodeRequest) String() string {
+	return fmt.Sprintf("L%d %016x", r.Lookup, r.Target)
+}
+
+// findNodeResponse 是 FindNode 回复的 message
+type findNodeResponse struct {
+	Lookup int
+	From   Contact
+	FindNodeReply
+}
+
+func (r findNodeResponse) String() string {
+	return fmt.Sprintf("L%d %d 个 contact", r.Lookup, len(r.Contacts))
+}
+
+// coreKademlia 把 Node 的迭代查找改写成由 message 驱动的 core.Node
+// 同步的 Call 被拆成了请求和回复，一轮查询的回复全部收到或者超时以后，才开始下一轮。
+// 除了 P0，node i 在 i 个 join 以后通过 P0 加入网络。所有的 node 在 n+1 个 join 以后开始查找，
+// 这时所有的 node 都已经加入了，查找的结果应该是整个网络中最近的 node。
+// 模拟中的 node 不会失效，所以 k-bucket 满了以后，不 Ping 最久没有见到的 contact，直接丢弃新的 contact。
+type coreKademlia struct {
+	me      Contact
+	k       int
+	rt      *routingTable
+	ctx     *core.Context
+	lookups int
+	join    time.Duration
+	timeout time.Duration
+	next    int             // 下一个查找的编号
+	active  map[int]*lookup // 进行中的查找
+	found   []found         // 完成了的 lookups 次查找
+}
+
+// found 是一次完成了的查找
+type found struct {
+	target  uint64
+	closest Contact // 找到的最近的 node，没有找到任何 node 时为零值
+}
+
+func newCoreKademlia(id, n int, params core.Params) core.Node {
+	k := params.Int("k", 20)
+	me := coreContact(id)
+	return &coreKademlia{
+		me:      me,
+		k:       k,
+		rt:      newRoutingTable(me, k),
+		lookups: params.Int("lookups", 3),
+		join:    params.Duration("join", 50*time.Millisecond),
+		timeout: params.Duration("timeout", 100*time.Millisecond),
+		active:  make(map[int]*lookup, 8),
+	}
+}
+
+// coreContact 返回 Pid 的 Contact，地址就是 id
+func coreContact(id int) Contact {
+	addr := strconv.Itoa(id)
+	return Contact{ID: hash(addr), Addr: addr}
+}
+
+func (n *coreKademlia) Start(ctx *core.Context) {
+	n.ctx = ctx
+	id := ctx.ID()
+	ctx.AfterFunc(time.Duration(ctx.Size()+1)*n.join, n.findKeys)
+	if id == 0 {
+		return
+	}
+	ctx.AfterFunc(time.Duration(id)*n.join, func() {
+		n.ctx.Tracef("通过 P0 加入网络")
+		n.rt.update(coreContact(0))
+		// 与 Join 一样，查找自己，再刷新比最近邻居更远的 k-bucket
+		n.find(n.me.ID, func(closest []Contact, _ int) {
+			from := 0
+			if len(closest) > 0 {
+				from = bucketIndex(n.me.ID, closest[0].ID) + 1
+			}
+			for i := from; i < b; i++ {
+				n.find(n.me.ID^(1<<uint(i)), func([]Contact, int) {})
+			}
+		})
+	})
+}
+
+// findKeys 依次查找 lookups 个 key
+func (n *coreKademlia) findKeys() {
+	if len(n.found) == n.lookups {
+		return
+	}
+	target := hash(fmt.Sprintf("P%d/%d", n.ctx.ID(), len(n.found)))
+	n.find(target, func(closest []Contact, rounds int) {
+		res := found{target: target}
+		if len(closest) > 0 {
+			res.closest = closest[0]
+		}
+		n.found = append(n.found, res)
+		n.ctx.Count("lookups", 1)
+		n.ctx.Count("lookup.rounds", rounds)
+		n.ctx.Tracef("找到了距离 %016x 最近的 %s，用了 %d 轮", target, res.closest, rounds)
+		n.findKeys()
+	})
+}
+
+func (n *coreKademlia) Handle(ctx *core.Context, msg core.Message) {
+	switch m := msg.Payload.(type) {
+	case findNodeRequest:
+		n.seen(m.From)
+		ctx.Send(msg.From, "FindNodeReply", findNodeResponse{
+			Lookup:        m.Lookup,
+			From:          n.me,
+			FindNodeReply: FindNodeReply{Contacts: n.rt.closest(m.Target, n.k)},
+		})
+	case findNodeResponse:
+		n.seen(m.From)
+		if l, ok := n.active[m.Lookup]; ok {
+			l.reply(m.From, m.Contacts)
+		}
+	}
+}
+
+// seen 用 c 更新路由表
+func (n *coreKademlia) seen(c Contact) {
+	n.rt.update(c)
+}
+
+// find 开始查找距离 target 最近的 k 个 node，查找结束以后调用 done
+func (n *coreKademlia) find(target uint64, done func(closest []Contact, rounds int)) {
+	l := &lookup{
+		n:           n,
+		id:          n.next,
+		target:      target,
+		shortlist:   n.rt.closest(target, n.k),
+		queried:     map[uint64]bool{n.me.ID: true},
+		waiting:     make(map[uint64]bool, alpha),
+		failed:      make(map[uint64]bool, alpha),
+		parallelism: alpha,
+		done:        done,
+	}
+	n.next++
+	n.active[l.id] = l
+	l.round()
+}
+
+// lookup 是 iterativeFind 的一次查找
+type lookup struct {
+	n             *coreKademlia
+	id            int
+	target        uint64
+	shortlist     []Contact
+	queried       map[uint64]bool
+	waiting       map[uint64]bool // 这一轮中还没有回复的 node
+	failed        map[uint64]bool
+	closestBefore uint64 // 这一轮开始时，shortlist 中最近的距离
+	parallelism   int
+	rounds        int
+	done          func(closest []Contact, rounds int)
+}
+
+// round 询问 shortlist 中 parallelism 个最近的、还没有询问过的 node
+// 没有可以询问的 node 时，查找结束
+func (l *lookup) round() {
+	batch := make([]Contact, 0, l.parallelism)
+	for _, c := range l.shortlist {
+		if len(batch) == l.parallelism {
+			break
+		}
+		if !l.queried[c.ID] {
+			batch = append(batch, c)
+		}
+	}
+	if len(batch) == 0 {
+		delete(l.n.active, l.id)
+		l.done(l.shortlist, l.rounds)
+		return
+	}
+	l.rounds++
+	l.closestBefore = 0
+	if len(l.shortlist) > 0 {
+		l.closestBefore = distance(l.shortlist[0].ID, l.target)
+	}
+	for _, c := range batch {
+		c := c
+		l.queried[c.ID] = true
+		l.waiting[c.ID] = true
+		to, _ := strconv.Atoi(c.Addr)
+		l.n.ctx.Send(to, "FindNode", findNodeRequest{
+			Lookup:       l.id,
+			FindNodeArgs: FindNodeArgs{From: l.n.me, Target: l.target},
+		})
+		l.n.ctx.AfterFunc(l.n.timeout, func() {
+			if l.waiting[c.ID] {
+				l.fail(c)
+			}
+		})
+	}
+}
+
+// reply 把 from 回复的 contacts 合并进 shortlist
+func (l *lookup) reply(from Contact, contacts []Contact) {
+	if !l.waiting[from.ID] {
+		return
+	}
+	delete(l.waiting, from.ID)
+	seen := make(map[uint64]bool, len(l.shortlist))
+	for _, c := range l.shortlist {
+		seen[c.ID] = true
+	}
+	for _, c := range contacts {
+		if c.ID == l.n.me.ID || seen[c.ID] || l.failed[c.ID] {
+			continue
+		}
+		seen[c.ID] = true
+		l.shortlist = append(l.shortlist, c)
+	}
+	l.endRound()
+}
+
+// fail 把超时的 c 从 shortlist 和路由表中删除
+func (l *lookup) fail(c Contact) {
+	delete(l.waiting, c.ID)
+	l.failed[c.ID] = true
+	l.n.rt.remove(c)
+	for i := range l.shortlist {
+		if l.shortlist[i].ID == c.ID {
+			l.shortlist = append(l.shortlist[:i], l.shortlist[i+1:]...)
+			break
+		}
+	}
+	l.n.ctx.Count("lookup.timeouts", 1)
+	l.endRound()
+}
+
+// endRound 在这一轮的回复全部收到或者超时以后，开始下一轮
+// 这一轮没能找到更近的 node 时，下一轮询问所有还没有询问过的 node
+func (l *lookup) endRound() {
+	if len(l.waiting) > 0 {
+		return
+	}
+	sortByDistance(l.shortlist, l.target)
+	if len(l.shortlist) > l.n.k {
+		l.shortlist = l.shortlist[:l.n.k]
+	}
+	if len(l.shortlist) > 0 && distance(l.shortlist[0].ID, l.target) < l.closestBefore {
+		l.parallelism = alpha
+	} else {
+		l.parallelism = l.n.k
+	}
+	l.round()
+}
+
+// allFound 检查每个 node 都完成了全部的查找，并且找到了距离目标最近的 node
+func allFound(nodes []core.Node, _ *core.Metrics) error {
+	for id, node := range nodes {
+		n := node.(*coreKademlia)
+		if len(n.found) < n.lookups {
+			return fmt.Errorf("liveness: P%d 的 %d 次查找中，只完成了 %d 次", id, n.lookups, len(n.found))
+		}
+		for _, f := range n.found {
+			closest := Contact{}
+			for other := range nodes {
+				c := coreContact(other)
+				if other != id && (closest.Addr == "" || distance(c.ID, f.target) < distance(closest.ID, f.target)) {
+					closest = c
+				}
+			}
+			if f.closest != closest {
+				return fmt.Errorf("FindNode: P%d 查找 %016x 找到了 %s，但是最近的 node 是 %s", id, f.target, f.closest, closest)
+			}
+		}
+	}
+	return nil
+}
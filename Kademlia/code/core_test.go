@@ -0,0 +1,27 @@
+package kademlia
+
+import (
+	"testing"
+	"time"
+
+	core "github.com/aQuaYi/Distributed-Algorithms/Core/code"
+	reliablechannel "github.com/aQuaYi/Distributed-Algorithms/Reliable-Channel/code"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_core_kademlia(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := core.Config{
+		Nodes:   30,
+		Params:  core.Params{"k": "4"},
+		Latency: reliablechannel.Uniform{Min: time.Millisecond, Max: 10 * time.Millisecond},
+	}
+	for seed := int64(0); seed < 10; seed++ {
+		c.Seed = seed
+		res, err := core.Run("kademlia", c)
+		ast.Nil(err)
+		ast.True(res.OK(), "seed %d: %s", seed, res.Violation)
+		ast.Equal(90, res.Metrics.Get("lookups"))
+	}
+}
@@ -0,0 +1,116 @@
+package kademlia
+
+import (
+	probabilistic "github.com/aQuaYi/Distributed-Algorithms/Probabilistic/code"
+)
+
+const (
+	// digestFPR 是 Digest 返回的 Bloom filter 的 false positive 概率
+	digestFPR = 0.01
+	// sketchPrecision 是 Sketch 返回的 HyperLogLog 的精度，一共 2^12 个 register
+	sketchPrecision = 12
+)
+
+// RepublishStats 统计了一次 Republish 的数据量
+type RepublishStats struct {
+	Pairs       int // 需要重新发布的 (key, node) 对的数量
+	Sent        int // 实际发送的 key 的数量
+	FilterBytes int // 收到的 Bloom filter 的字节数
+	SentBytes   int // 实际发送的 key 和 value 的字节数
+	NaiveBytes  int // 不使用 Bloom filter 时，需要发送的 key 和 value 的字节数
+}
+
+// Republish 把 n 保存的 key-value 重新发布到距离 key 最近的 k 个 node 上
+// node 的加入和失效会改变每个 key 最近的 k 个 node，所以需要定期重新发布。
+// 大部分时候对方已经保存了这些 key，因此先向对方要一个 Bloom filter，
+// 只发送 filter 中没有的 key。被误判的 key 这次不会发送，
+// 但下次 Republish 使用了不同的 seed，很可能就不会再被误判了。
+func (n *Node) Republish() RepublishStats {
+	n.mutex.Lock()
+	n.republishes++
+	seed := n.republishes
+	data := make(map[string]string, len(n.data))
+	for key, value := range n.data {
+		data[key] = value
+	}
+	n.mutex.Unlock()
+
+	// 按照 node 汇总需要发送的 key
+	targets := make(map[Contact][]string)
+	for key := range data {
+		hk := hash(key)
+		closest, _, _, _ := n.iterativeFind(hk, "", false)
+		closest = append(closest, n.me)
+		sortByDistance(closest, hk)
+		if len(closest) > n.k {
+			closest = closest[:n.k]
+		}
+		for _, c := range closest {
+			if c != n.me {
+				targets[c] = append(targets[c], key)
+			}
+		}
+	}
+
+	var st RepublishStats
+	for c, keys := range targets {
+		var reply DigestReply
+		if err := n.tp.Call(c.Addr, "Digest", &DigestArgs{From: n.me, Seed: seed}, &reply); err != nil {
+			continue
+		}
+		st.FilterBytes += reply.Filter.Size()
+		for _, key := range keys {
+			size := len(key) + len(data[key])
+			st.Pairs++
+			st.NaiveBytes += size
+			if reply.Filter.Test(key) {
+				continue
+			}
+			n.tp.Call(c.Addr, "Store", &StoreArgs{From: n.me, Key: key, Value: data[key]}, &StoreReply{})
+			st.Sent++
+			st.SentBytes += size
+		}
+	}
+	return st
+}
+
+// EstimateKeys 估计整个网络中不同 key 的数量，同时返回收到的 HyperLogLog 的字节数
+// 每个 key 保存在 k 个 node 上，把各个 node 的 Keys 相加会重复计数，
+// 交换 key 的列表虽然可以去重，消息的大小却与 key 的数量成正比。
+// HyperLogLog 的大小是固定的，合并以后重复的 key 只会被计数一次。
+// 只有路由表中的 node 会被询问，网络很大时，结果只反映 n 附近的 key。
+func (n *Node) EstimateKeys() (uint64, int) {
+	res := n.sketch()
+	bytes := 0
+	for _, c := range n.rt.closest(n.me.ID, n.rt.size()) {
+		var reply SketchReply
+		if err := n.tp.Call(c.Addr, "Sketch", &SketchArgs{From: n.me}, &reply); err != nil {
+			continue
+		}
+		bytes += reply.Sketch.Size()
+		res.Merge(reply.Sketch)
+	}
+	return res.Count(), bytes
+}
+
+// digest 返回 n 保存的所有 key 的 Bloom filter
+func (n *Node) digest(seed uint64) *probabilistic.Bloom {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	res := probabilistic.NewBloom(len(n.data), digestFPR, seed)
+	for key := range n.data {
+		res.Add(key)
+	}
+	return res
+}
+
+// sketch 返回 n 保存的所有 key 的 HyperLogLog
+func (n *Node) sketch() *probabilistic.HyperLogLog {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	res := probabilistic.NewHyperLogLog(sketchPrecision)
+	for key := range n.data {
+		res.Add(key)
+	}
+	return res
+}
@@ -0,0 +1,105 @@
+package kademlia
+
+import (
+	"fmt"
+	"testing"
+
+	transport "github.com/aQuaYi/Distributed-Algorithms/Transport/code"
+	"github.com/stretchr/testify/assert"
+)
+
+// drop 删除 n 上保存的 key，模拟副本的丢失
+func (n *Node) drop(key string) {
+	n.mutex.Lock()
+	delete(n.data, key)
+	n.mutex.Unlock()
+}
+
+func Test_Node_Republish(t *testing.T) {
+	ast := assert.New(t)
+	//
+	k := 4
+	sn := transport.NewSimNetwork()
+	nodes := makeNetwork(sn, 32, k)
+	size := 200
+	for i := 0; i < size; i++ {
+		nodes[i%len(nodes)].Put(fmt.Sprintf("k%d", i), fmt.Sprint(i))
+	}
+	// 所有的副本都在，Bloom filter 让 Republish 几乎不用发送任何 key
+	var st RepublishStats
+	for _, n := range nodes {
+		s := n.Republish()
+		st.Pairs += s.Pairs
+		st.Sent += s.Sent
+		st.FilterBytes += s.FilterBytes
+		st.SentBytes += s.SentBytes
+		st.NaiveBytes += s.NaiveBytes
+	}
+	ast.Equal(size*k*(k-1), st.Pairs)
+	ast.Equal(0, st.Sent)
+	ast.True(st.FilterBytes+st.SentBytes < st.NaiveBytes, "%+v", st)
+	t.Logf("%+v", st)
+}
+
+func Test_Node_RepublishRestoresLostKeys(t *testing.T) {
+	ast := assert.New(t)
+	//
+	k := 4
+	sn := transport.NewSimNetwork()
+	nodes := makeNetwork(sn, 32, k)
+	size := 200
+	for i := 0; i < size; i++ {
+		nodes[i%len(nodes)].Put(fmt.Sprintf("k%d", i), fmt.Sprint(i))
+	}
+	// nodes[0] 丢失了一半的 key
+	lost := 0
+	for i := 0; i < size; i += 2 {
+		if _, ok := nodes[0].fetch(fmt.Sprintf("k%d", i)); ok {
+			nodes[0].drop(fmt.Sprintf("k%d", i))
+			lost++
+		}
+	}
+	ast.True(lost > 0)
+	//
+	sent := 0
+	for _, n := range nodes[1:] {
+		sent += n.Republish().Sent
+	}
+	// 被 Bloom filter 误判的 key 会在下一次 Republish 中补上
+	for _, n := range nodes[1:] {
+		sent += n.Republish().Sent
+	}
+	ast.True(sent >= lost, "%d %d", sent, lost)
+	//
+	total := 0
+	for _, n := range nodes {
+		total += n.Keys()
+	}
+	ast.Equal(size*k, total)
+}
+
+func Test_Node_EstimateKeys(t *testing.T) {
+	ast := assert.New(t)
+	//
+	// 路由表能容纳所有的 node 时，EstimateKeys 才能覆盖整个网络
+	k := 8
+	sn := transport.NewSimNetwork()
+	nodes := makeNetwork(sn, 12, k)
+	size := 2000
+	for i := 0; i < size; i++ {
+		nodes[i%len(nodes)].Put(fmt.Sprintf("k%d", i), fmt.Sprint(i))
+	}
+	//
+	sum := 0
+	for _, n := range nodes {
+		sum += n.Keys()
+	}
+	ast.Equal(size*k, sum, "直接相加会重复计数")
+	//
+	for _, n := range nodes[:3] {
+		estimate, bytes := n.EstimateKeys()
+		ast.InDelta(float64(size), float64(estimate), float64(size)*0.05)
+		// 消息的大小与 key 的数量无关
+		ast.True(bytes <= (len(nodes)-1)*(1<<sketchPrecision+1), "%d", bytes)
+	}
+}
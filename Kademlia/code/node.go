@@ -0,0 +1,260 @@
+package kademlia
+
+import (
+	"fmt"
+	"sync"
+
+	transport "github.com/aQuaYi/Distributed-Algorithms/Transport/code"
+)
+
+// alpha 是迭代查找时，每一轮并行查询的 node 数量
+const alpha = 3
+
+// Node 是 Kademlia 网络中的一个 node
+type Node struct {
+	me Contact
+	k  int // k-bucket 的容量，也是每个 key 的副本数量
+	tp transport.Transport
+	rt *routingTable
+
+	mutex       sync.Mutex
+	data        map[string]string
+	republishes uint64 // Republish 的次数，用作 Bloom filter 的 seed
+}
+
+// NewNode 在 tp 上创建一个地址为 addr，k-bucket 容量为 k 的 node
+// node 的 ID 是其真实地址的 hash
+func NewNode(addr string, k int, tp transport.Transport) (*Node, error) {
+	n := &Node{
+		k:    k,
+		tp:   tp,
+		data: make(map[string]string, 64),
+	}
+	actual, err := tp.Register(addr, &Service{n: n})
+	if err != nil {
+		return nil, err
+	}
+	n.me = Contact{ID: hash(actual), Addr: actual}
+	n.rt = newRoutingTable(n.me, k)
+	return n, nil
+}
+
+func (n *Node) String() string {
+	return fmt.Sprintf("Node%s", n.me)
+}
+
+// Contact 返回 n 的联系方式
+func (n *Node) Contact() Contact {
+	return n.me
+}
+
+// Join 通过 addr 上的 node 加入网络
+func (n *Node) Join(addr string) error {
+	var reply PingReply
+	if err := n.tp.Call(addr, "Ping", &PingArgs{From: n.me}, &reply); err != nil {
+		return err
+	}
+	n.rt.update(reply.Me)
+
+	// 查找自己，让附近的 node 认识自己，同时填充自己的路由表
+	closest, _, _, _ := n.iterativeFind(n.me.ID, "", false)
+
+	// 刷新比最近邻居更远的 k-bucket
+	from := 0
+	if len(closest) > 0 {
+		from = bucketIndex(n.me.ID, closest[0].ID) + 1
+	}
+	for i := from; i < b; i++ {
+		n.iterativeFind(n.me.ID^(1<<uint(i)), "", false)
+	}
+	return nil
+}
+
+// Leave 让 n 离开网络，相当于 n 失效了
+func (n *Node) Leave() {
+	n.tp.Unregister(n.me.Addr)
+}
+
+// FindNode 返回网络中距离 target 最近的 k 个 node，以及查找所用的轮数
+func (n *Node) FindNode(target uint64) ([]Contact, int) {
+	closest, _, _, rounds := n.iterativeFind(target, "", false)
+	return closest, rounds
+}
+
+// Put 把 key-value 保存到距离 key 最近的 k 个 node 上
+func (n *Node) Put(key, value string) int {
+	target := hash(key)
+	closest, _, _, rounds := n.iterativeFind(target, "", false)
+
+	// 自己也可能是最近的 k 个 node 之一
+	closest = append(closest, n.me)
+	sortByDistance(closest, target)
+	if len(closest) > n.k {
+		closest = closest[:n.k]
+	}
+
+	for _, c := range closest {
+		n.tp.Call(c.Addr, "Store", &StoreArgs{From: n.me, Key: key, Value: value}, &StoreReply{})
+	}
+	return rounds
+}
+
+// Get 在网络中查找 key 的值，同时返回查找所用的轮数
+func (n *Node) Get(key string) (string, bool, int) {
+	if v, ok := n.fetch(key); ok {
+		return v, true, 0
+	}
+	_, v, ok, rounds := n.iterativeFind(hash(key), key, true)
+	return v, ok, rounds
+}
+
+// iterativeFind 是 Kademlia 的迭代查找过程
+// 每一轮并行地询问 shortlist 中 alpha 个最近的、还没有询问过的 node，
+// 如果某一轮没能找到更近的 node，下一轮就询问 shortlist 中所有还没有询问过的 node，
+// 直到 shortlist 中最近的 k 个 node 都被询问过为止。
+// findValue 为 true 时，一旦找到 key 就立即返回
+func (n *Node) iterativeFind(target uint64, key string, findValue bool) ([]Contact, string, bool, int) {
+	shortlist := n.rt.closest(target, n.k)
+	queried := make(map[uint64]bool, n.k*2)
+	queried[n.me.ID] = true
+	rounds := 0
+	parallelism := alpha
+
+	for {
+		batch := make([]Contact, 0, parallelism)
+		for _, c := range shortlist {
+			if len(batch) == parallelism {
+				break
+			}
+			if !queried[c.ID] {
+				batch = append(batch, c)
+			}
+		}
+		if len(batch) == 0 {
+			return shortlist, "", false, rounds
+		}
+		rounds++
+
+		results := n.queryAll(batch, target, key, findValue)
+
+		var closestBefore uint64
+		if len(shortlist) > 0 {
+			closestBefore = distance(shortlist[0].ID, target)
+		}
+
+		failed := make(map[uint64]bool, len(batch))
+		for i, res := range results {
+			queried[batch[i].ID] = true
+			if res.err != nil {
+				// 失效的 node 从 shortlist 和路由表中删除
+				failed[batch[i].ID] = true
+				n.rt.remove(batch[i])
+			}
+		}
+
+		seen := make(map[uint64]bool, len(shortlist)*2)
+		merged := make([]Contact, 0, len(shortlist)+n.k*len(batch))
+		add := func(c Contact) {
+			if c.ID == n.me.ID || seen[c.ID] || failed[c.ID] {
+				return
+			}
+			seen[c.ID] = true
+			merged = append(merged, c)
+		}
+		for _, c := range shortlist {
+			add(c)
+		}
+		for _, res := range results {
+			if res.found {
+				return shortlist, res.value, true, rounds
+			}
+			for _, c := range res.contacts {
+				add(c)
+			}
+		}
+
+		shortlist = merged
+		sortByDistance(shortlist, target)
+		if len(shortlist) > n.k {
+			shortlist = shortlist[:n.k]
+		}
+
+		if len(shortlist) > 0 && distance(shortlist[0].ID, target) < closestBefore {
+			parallelism = alpha
+		} else {
+			parallelism = n.k
+		}
+	}
+}
+
+type findResult struct {
+	contacts []Contact
+	value    string
+	found    bool
+	err      error
+}
+
+// queryAll 并行地询问 batch 中的 node
+func (n *Node) queryAll(batch []Contact, target uint64, key string, findValue bool) []findResult {
+	results := make([]findResult, len(batch))
+	var wg sync.WaitGroup
+	wg.Add(len(batch))
+	for i, c := range batch {
+		go func(i int, c Contact) {
+			defer wg.Done()
+			if findValue {
+				var reply FindValueReply
+				err := n.tp.Call(c.Addr, "FindValue", &FindValueArgs{From: n.me, Key: key}, &reply)
+				results[i] = findResult{contacts: reply.Contacts, value: reply.Value, found: reply.Found, err: err}
+			} else {
+				var reply FindNodeReply
+				err := n.tp.Call(c.Addr, "FindNode", &FindNodeArgs{From: n.me, Target: target}, &reply)
+				results[i] = findResult{contacts: reply.Contacts, err: err}
+			}
+			if err := results[i].err; err == nil {
+				n.seen(c)
+			}
+		}(i, c)
+	}
+	wg.Wait()
+	return results
+}
+
+// seen 用 c 更新路由表
+// c 所在的 k-bucket 满了的时候，只有最久没有见到的 contact 失效了，才会被 c 替换
+func (n *Node) seen(c Contact) {
+	stale, full := n.rt.update(c)
+	if !full {
+		return
+	}
+	// 在另一个 goroutine 中检查，避免 RPC 之间相互嵌套
+	go func() {
+		var reply PingReply
+		if err := n.tp.Call(stale.Addr, "Ping", &PingArgs{From: n.me}, &reply); err != nil {
+			n.rt.evict(stale, c)
+			return
+		}
+		// stale 还活着，移动到末尾，放弃 c
+		n.rt.update(stale)
+	}()
+}
+
+func (n *Node) store(key, value string) {
+	n.mutex.Lock()
+	n.data[key] = value
+	n.mutex.Unlock()
+}
+
+func (n *Node) fetch(key string) (string, bool) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	v, ok := n.data[key]
+	return v, ok
+}
+
+// Keys 返回保存在 n 上的 key 的数量
+func (n *Node) Keys() int {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return len(n.data)
+}
@@ -0,0 +1,116 @@
+package kademlia
+
+import (
+	"fmt"
+	"testing"
+
+	transport "github.com/aQuaYi/Distributed-Algorithms/Transport/code"
+	"github.com/stretchr/testify/assert"
+)
+
+// makeNetwork 在 sn 上创建由 size 个 node 组成的网络
+func makeNetwork(sn *transport.SimNetwork, size, k int) []*Node {
+	nodes := make([]*Node, 0, size)
+	for i := 0; i < size; i++ {
+		n, err := NewNode(fmt.Sprintf("node-%d", i), k, sn)
+		if err != nil {
+			panic(err)
+		}
+		if i > 0 {
+			if err := n.Join(nodes[0].me.Addr); err != nil {
+				panic(err)
+			}
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes
+}
+
+// expectedClosest 通过遍历所有 node，求出距离 target 最近的 count 个 node
+func expectedClosest(nodes []*Node, target uint64, count int) []Contact {
+	cs := make([]Contact, len(nodes))
+	for i, n := range nodes {
+		cs[i] = n.me
+	}
+	sortByDistance(cs, target)
+	return cs[:count]
+}
+
+func Test_Node_FindNode(t *testing.T) {
+	ast := assert.New(t)
+	//
+	k := 8
+	sn := transport.NewSimNetwork()
+	nodes := makeNetwork(sn, 64, k)
+	//
+	for i := 0; i < 50; i++ {
+		from := nodes[i]
+		target := hash(fmt.Sprintf("target-%d", i))
+		actual, _ := from.FindNode(target)
+		// from 自己不会出现在结果中
+		expected := make([]Contact, 0, k)
+		for _, c := range expectedClosest(nodes, target, k+1) {
+			if c != from.me && len(expected) < k {
+				expected = append(expected, c)
+			}
+		}
+		ast.Equal(expected, actual)
+	}
+}
+
+func Test_Node_PutAndGet(t *testing.T) {
+	ast := assert.New(t)
+	//
+	k := 4
+	sn := transport.NewSimNetwork()
+	nodes := makeNetwork(sn, 32, k)
+	//
+	size := 50
+	for i := 0; i < size; i++ {
+		nodes[i%len(nodes)].Put(fmt.Sprintf("k%d", i), fmt.Sprint(i))
+	}
+	//
+	total := 0
+	for _, n := range nodes {
+		total += n.Keys()
+	}
+	ast.Equal(size*k, total, "每个 key 都应该保存了 k 份")
+	//
+	for i := 0; i < size; i++ {
+		v, ok, _ := nodes[(i+7)%len(nodes)].Get(fmt.Sprintf("k%d", i))
+		ast.True(ok)
+		ast.Equal(fmt.Sprint(i), v)
+	}
+	//
+	_, ok, _ := nodes[0].Get("missing")
+	ast.False(ok)
+}
+
+func Test_Node_survivesFailures(t *testing.T) {
+	ast := assert.New(t)
+	//
+	k := 4
+	sn := transport.NewSimNetwork()
+	nodes := makeNetwork(sn, 32, k)
+	size := 50
+	for i := 0; i < size; i++ {
+		nodes[i%len(nodes)].Put(fmt.Sprintf("k%d", i), fmt.Sprint(i))
+	}
+	// 每 4 个 node 失效 1 个，每个 key 的 k 个副本不太可能同时失效
+	live := make([]*Node, 0, len(nodes))
+	for i, n := range nodes {
+		if i%4 == 3 {
+			n.Leave()
+			continue
+		}
+		live = append(live, n)
+	}
+	//
+	found := 0
+	for i := 0; i < size; i++ {
+		if _, ok, _ := live[i%len(live)].Get(fmt.Sprintf("k%d", i)); ok {
+			found++
+		}
+	}
+	ast.Equal(size, found)
+}
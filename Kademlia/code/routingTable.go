@@ -0,0 +1,108 @@
+package kademlia
+
+import "sync"
+
+// routingTable 由 b 个 k-bucket 组成
+// 每个 k-bucket 按照最近一次见到的时间排序，最久没有见到的 contact 排在最前面
+type routingTable struct {
+	me      Contact
+	k       int
+	buckets [b][]Contact
+	mutex   sync.Mutex
+}
+
+func newRoutingTable(me Contact, k int) *routingTable {
+	return &routingTable{
+		me: me,
+		k:  k,
+	}
+}
+
+// update 记录最近见到了 c
+// 如果 c 所在的 k-bucket 已经满了，返回该 k-bucket 中最久没有见到的 contact 和 true，
+// 由调用方检查其是否存活以后，再调用 evict 或者 touch
+func (rt *routingTable) update(c Contact) (Contact, bool) {
+	i := bucketIndex(rt.me.ID, c.ID)
+	if i < 0 {
+		return Contact{}, false
+	}
+
+	rt.mutex.Lock()
+	defer rt.mutex.Unlock()
+
+	bucket := rt.buckets[i]
+	for j := range bucket {
+		if bucket[j].ID == c.ID {
+			// 已经存在，移动到末尾
+			copy(bucket[j:], bucket[j+1:])
+			bucket[len(bucket)-1] = c
+			return Contact{}, false
+		}
+	}
+
+	if len(bucket) < rt.k {
+		rt.buckets[i] = append(bucket, c)
+		return Contact{}, false
+	}
+
+	return bucket[0], true
+}
+
+// evict 用 newer 替换掉已经失效的 stale
+func (rt *routingTable) evict(stale, newer Contact) {
+	i := bucketIndex(rt.me.ID, stale.ID)
+	rt.mutex.Lock()
+	defer rt.mutex.Unlock()
+	bucket := rt.buckets[i]
+	for j := range bucket {
+		if bucket[j].ID == stale.ID {
+			copy(bucket[j:], bucket[j+1:])
+			bucket[len(bucket)-1] = newer
+			return
+		}
+	}
+}
+
+// remove 删除已经失效的 c
+func (rt *routingTable) remove(c Contact) {
+	i := bucketIndex(rt.me.ID, c.ID)
+	if i < 0 {
+		return
+	}
+	rt.mutex.Lock()
+	defer rt.mutex.Unlock()
+	bucket := rt.buckets[i]
+	for j := range bucket {
+		if bucket[j].ID == c.ID {
+			rt.buckets[i] = append(bucket[:j], bucket[j+1:]...)
+			return
+		}
+	}
+}
+
+// closest 返回路由表中距离 target 最近的 count 个 contact
+func (rt *routingTable) closest(target uint64, count int) []Contact {
+	rt.mutex.Lock()
+	res := make([]Contact, 0, count*2)
+	for i := range rt.buckets {
+		res = append(res, rt.buckets[i]...)
+	}
+	rt.mutex.Unlock()
+
+	sortByDistance(res, target)
+	if len(res) > count {
+		res = res[:count]
+	}
+	return res
+}
+
+// size 返回路由表中 contact 的总数
+func (rt *routingTable) size() int {
+	rt.mutex.Lock()
+	defer rt.mutex.Unlock()
+	res := 0
+	for i := range rt.buckets {
+		res += len(rt.buckets[i])
+	}
+	return res
+}
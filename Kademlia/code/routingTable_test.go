@@ -0,0 +1,54 @@
+package kademlia
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_routingTable_update(t *testing.T) {
+	ast := assert.New(t)
+	//
+	k := 2
+	rt := newRoutingTable(Contact{ID: 0}, k)
+	// 4,5,6,7 都在第 2 个 k-bucket 中
+	_, full := rt.update(Contact{ID: 4})
+	ast.False(full)
+	_, full = rt.update(Contact{ID: 5})
+	ast.False(full)
+	//
+	stale, full := rt.update(Contact{ID: 6})
+	ast.True(full)
+	ast.Equal(uint64(4), stale.ID)
+	// 再次见到 4 以后，5 成为了最久没有见到的 contact
+	rt.update(Contact{ID: 4})
+	stale, _ = rt.update(Contact{ID: 6})
+	ast.Equal(uint64(5), stale.ID)
+	// 忽略自己
+	_, full = rt.update(Contact{ID: 0})
+	ast.False(full)
+	ast.Equal(k, rt.size())
+}
+
+func Test_routingTable_evict(t *testing.T) {
+	ast := assert.New(t)
+	//
+	rt := newRoutingTable(Contact{ID: 0}, 2)
+	rt.update(Contact{ID: 4})
+	rt.update(Contact{ID: 5})
+	rt.evict(Contact{ID: 4}, Contact{ID: 6})
+	ast.Equal([]Contact{{ID: 5}, {ID: 6}}, rt.buckets[2])
+}
+
+func Test_routingTable_closest(t *testing.T) {
+	ast := assert.New(t)
+	//
+	rt := newRoutingTable(Contact{ID: 0}, 20)
+	for i := uint64(1); i <= 32; i++ {
+		rt.update(Contact{ID: i})
+	}
+	rt.remove(Contact{ID: 9})
+	//
+	actual := rt.closest(8, 3)
+	ast.Equal([]Contact{{ID: 8}, {ID: 10}, {ID: 11}}, actual)
+}
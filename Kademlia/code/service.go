@@ -0,0 +1,123 @@
+package kademlia
+
+import (
+	probabilistic "github.com/aQuaYi/Distributed-Algorithms/Probabilistic/code"
+)
+
+// PingArgs 是 Ping 的参数
+type PingArgs struct {
+	From Contact
+}
+
+// PingReply 是 Ping 的返回值
+type PingReply struct {
+	Me Contact
+}
+
+// FindNodeArgs 是 FindNode 的参数
+type FindNodeArgs struct {
+	From   Contact
+	Target uint64
+}
+
+// FindNodeReply 是 FindNode 的返回值
+type FindNodeReply struct {
+	Contacts []Contact // 被调用方所知的距离 Target 最近的 k 个 contact
+}
+
+// FindValueArgs 是 FindValue 的参数
+type FindValueArgs struct {
+	From Contact
+	Key  string
+}
+
+// FindValueReply 是 FindValue 的返回值
+// Found 为 false 时，Contacts 才有意义
+type FindValueReply struct {
+	Value    string
+	Found    bool
+	Contacts []Contact
+}
+
+// StoreArgs 是 Store 的参数
+type StoreArgs struct {
+	From       Contact
+	Key, Value string
+}
+
+// StoreReply 是 Store 的返回值
+type StoreReply struct{}
+
+// Service 把 Node 的方法以 RPC 的形式暴露出去
+// 收到任何 RPC，都会用发送方更新自己的路由表
+type Service struct {
+	n *Node
+}
+
+// Ping 用于检查 node 是否存活
+func (s *Service) Ping(args *PingArgs, reply *PingReply) error {
+	s.n.seen(args.From)
+	reply.Me = s.n.me
+	return nil
+}
+
+// FindNode 返回距离 args.Target 最近的 k 个 contact
+func (s *Service) FindNode(args *FindNodeArgs, reply *FindNodeReply) error {
+	s.n.seen(args.From)
+	reply.Contacts = s.n.rt.closest(args.Target, s.n.k)
+	return nil
+}
+
+// FindValue 如果保存了 args.Key，就返回其值，否则与 FindNode 一样
+func (s *Service) FindValue(args *FindValueArgs, reply *FindValueReply) error {
+	s.n.seen(args.From)
+	if v, ok := s.n.fetch(args.Key); ok {
+		reply.Value, reply.Found = v, true
+		return nil
+	}
+	reply.Contacts = s.n.rt.closest(hash(args.Key), s.n.k)
+	return nil
+}
+
+// Store 把 key-value 保存在 node 上
+func (s *Service) Store(args *StoreArgs, reply *StoreReply) error {
+	s.n.seen(args.From)
+	s.n.store(args.Key, args.Value)
+	return nil
+}
+
+// DigestArgs 是 Digest 的参数
+type DigestArgs struct {
+	From Contact
+	Seed uint64
+}
+
+// DigestReply 是 Digest 的返回值
+type DigestReply struct {
+	Filter *probabilistic.Bloom // 被调用方保存的所有 key
+}
+
+// Digest 返回保存在 node 上的 key 的 Bloom filter
+// 每次使用不同的 args.Seed，同一个 key 不会总是被误判为已经保存了
+func (s *Service) Digest(args *DigestArgs, reply *DigestReply) error {
+	s.n.seen(args.From)
+	reply.Filter = s.n.digest(args.Seed)
+	return nil
+}
+
+// SketchArgs 是 Sketch 的参数
+type SketchArgs struct {
+	From Contact
+}
+
+// SketchReply 是 Sketch 的返回值
+type SketchReply struct {
+	Sketch *probabilistic.HyperLogLog
+}
+
+// Sketch 返回保存在 node 上的 key 的 HyperLogLog
+func (s *Service) Sketch(args *SketchArgs, reply *SketchReply) error {
+	s.n.seen(args.From)
+	reply.Sketch = s.n.sketch()
+	return nil
+}
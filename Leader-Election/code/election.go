@@ -0,0 +1,227 @@
+package election
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	reliablechannel "github.com/aQuaYi/Distributed-Algorithms/Reliable-Channel/code"
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+)
+
+// Config 是选举的参数
+type Config struct {
+	// Weights 是每个 process 的票数，为 nil 时每个 process 一票
+	// 得到的票数超过总票数的一半，才能成为 leader
+	Weights []int
+	// Priorities 是每个 process 的优先级，为 nil 时都是 0
+	// 发现没有 leader 以后，优先级高的 process 先参选
+	Priorities []int
+	// Lease 是 leader 的 lease，投票的 process 在 Lease 以内不会投票给其他 process
+	Lease time.Duration
+	// Renew 是 leader 续约的间隔，需要明显小于 Lease
+	Renew time.Duration
+	// Backoff 是发现没有 leader 以后，优先级每低一名，多等待的时间
+	// 实际的等待时间还会加上 [0, Backoff/2) 的随机抖动，避免同时参选
+	Backoff time.Duration
+	// Latency 是 message 的延迟分布，为 nil 时没有延迟
+	Latency reliablechannel.Latency
+	// Env 提供了定时器和随机数，零值使用真实的时间
+	Env sim.Env
+}
+
+// Provider 提供当前的 leader
+// Multi-Paxos、chain replication 这类需要 leader 的协议，可以通过它决定把请求发给谁，
+// 并用 token 作为 fencing token，拒绝过期的 leader 的请求。
+type Provider interface {
+	// Leader 返回 lease 还有效的 leader 和它的 token，没有 leader 时 ok 为 false
+	Leader() (id int, token uint64, ok bool)
+}
+
+// Metrics 统计了选举的情况
+type Metrics struct {
+	Campaigns int             // 参选的次数
+	Elections int             // 成为 leader 的次数，不包括续约
+	Renewals  int             // 续约成功的次数
+	Messages  int             // 发送的 message 数量
+	Failovers []time.Duration // 每次 leader 失效以后，到选出新的 leader 所经过的时间
+}
+
+// MaxFailover 返回最长的 failover 时间
+func (m Metrics) MaxFailover() time.Duration {
+	var res time.Duration
+	for _, d := range m.Failovers {
+		if d > res {
+			res = d
+		}
+	}
+	return res
+}
+
+// Group 是参与选举的一组 process，每个 process 既投票，也可以参选
+// 它是基于 lease 的选举：得到多数票的 candidate 成为 leader，投票的 process 在 lease 以内不会改投他人，
+// 所以 lease 有效期间最多只有一个 leader。leader 需要在 lease 到期前再次得到多数票来续约。
+// 每次有新的 leader 上任，term 都会增大，term 就是 fencing token。
+type Group struct {
+	mutex   sync.Mutex
+	config  Config
+	procs   []*process
+	total   int // 总票数
+	metrics Metrics
+	lost    time.Time // 上一个 leader 失效的时间，零值表示还没有失效或者已经统计过了
+}
+
+// NewGroup 返回 n 个 process 组成的 Group，调用 Start 以后开始选举
+func NewGroup(n int, c Config) *Group {
+	g := &Group{
+		config: c,
+		procs:  make([]*process, n),
+	}
+	for i := range g.procs {
+		g.procs[i] = newProcess(i)
+		g.total += g.weight(i)
+	}
+	return g
+}
+
+// Start 让所有的 process 开始运行
+// 此时还没有 leader，优先级最高的 process 会最先参选
+func (g *Group) Start() {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	for _, p := range g.procs {
+		g.watch(p, 0)
+	}
+}
+
+// Size 返回 process 的数量
+func (g *Group) Size() int {
+	return len(g.procs)
+}
+
+// Leader 实现了 Provider 接口
+func (g *Group) Leader() (int, uint64, bool) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	for _, p := range g.procs {
+		if g.isLeader(p) {
+			return p.id, p.campaign, true
+		}
+	}
+	return -1, 0, false
+}
+
+// IsLeader 返回 true 和 token，如果 id 认为自己是 lease 还有效的 leader
+func (g *Group) IsLeader(id int) (uint64, bool) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	p := g.procs[id]
+	return p.campaign, g.isLeader(p)
+}
+
+// Metrics 返回选举的统计
+func (g *Group) Metrics() Metrics {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	res := g.metrics
+	res.Failovers = append([]time.Duration(nil), g.metrics.Failovers...)
+	return res
+}
+
+// Crash 让 id 崩溃，它不再发送和处理 message
+func (g *Group) Crash(id int) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	p := g.procs[id]
+	if g.isLeader(p) {
+		g.lost = g.config.Env.Now()
+	}
+	p.crashed = true
+	p.generation++
+}
+
+// Restart 重启崩溃的 id，它只保留了 stable storage 中的 term，丢失了投票时做出的承诺
+// 所以它要先等待一个 Lease 才能投票，这时它之前承诺的 lease 一定都已经过期了
+func (g *Group) Restart(id int) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	old := g.procs[id]
+	if !old.crashed {
+		return
+	}
+	p := newProcess(id)
+	p.generation = old.generation + 1
+	p.term = old.term
+	p.recovering = g.config.Env.Now().Add(g.config.Lease)
+	g.procs[id] = p
+	g.watch(p, g.config.Lease)
+}
+
+func (g *Group) weight(id int) int {
+	if g.config.Weights == nil {
+		return 1
+	}
+	return g.config.Weights[id]
+}
+
+// rank 返回 id 的优先级在所有 process 中的名次，从 0 开始，优先级相同时 id 小的在前
+func (g *Group) rank(id int) int {
+	if g.config.Priorities == nil {
+		return id
+	}
+	ids := make([]int, len(g.procs))
+	for i := range ids {
+		ids[i] = i
+	}
+	sort.SliceStable(ids, func(i, j int) bool {
+		return g.config.Priorities[ids[i]] > g.config.Priorities[ids[j]]
+	})
+	for r, i := range ids {
+		if i == id {
+			return r
+		}
+	}
+	return len(ids)
+}
+
+// backoff 返回 id 发现没有 leader 以后，参选之前等待的时间
+func (g *Group) backoff(id int) time.Duration {
+	d := g.config.Backoff * time.Duration(g.rank(id))
+	if jitter := int64(g.config.Backoff / 2); jitter > 0 {
+		d += time.Duration(g.config.Env.Int63n(jitter))
+	}
+	return d
+}
+
+// after 在 d 以后执行 f，p 在此期间崩溃或者重启时，不再执行
+// 需要在持有 g.mutex 时调用
+func (g *Group) after(p *process, d time.Duration, f func()) {
+	generation := p.generation
+	g.config.Env.AfterFunc(d, func() {
+		g.mutex.Lock()
+		defer g.mutex.Unlock()
+		if p.crashed || p.generation != generation {
+			return
+		}
+		f()
+	})
+}
+
+// send 把 m 从 from 发送给 to
+// 需要在持有 g.mutex 时调用
+func (g *Group) send(from, to int, m message) {
+	g.metrics.Messages++
+	var delay time.Duration
+	if from != to && g.config.Latency != nil {
+		delay = g.config.Latency.Sample(g.config.Env)
+	}
+	g.config.Env.AfterFunc(delay, func() {
+		g.mutex.Lock()
+		defer g.mutex.Unlock()
+		p := g.procs[to]
+		if p.crashed {
+			return
+		}
+		g.handle(p, from, m)
+	})
+}
@@ -0,0 +1,191 @@
+package election
+
+import (
+	"testing"
+	"time"
+
+	epaxos "github.com/aQuaYi/Distributed-Algorithms/EPaxos/code"
+	leaselock "github.com/aQuaYi/Distributed-Algorithms/Lease-Lock/code"
+	reliablechannel "github.com/aQuaYi/Distributed-Algorithms/Reliable-Channel/code"
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+	"github.com/stretchr/testify/assert"
+)
+
+var testConfig = Config{
+	Lease:   time.Second,
+	Renew:   300 * time.Millisecond,
+	Backoff: 100 * time.Millisecond,
+	Latency: reliablechannel.Uniform{Min: 5 * time.Millisecond, Max: 15 * time.Millisecond},
+}
+
+func newTestGroup(n int, c Config, seed int64) (*Group, *sim.Virtual) {
+	clock := sim.NewVirtual(time.Unix(0, 0))
+	c.Env = sim.Deterministic(clock, seed)
+	g := NewGroup(n, c)
+	g.Start()
+	return g, clock
+}
+
+// run 每次把时间推进 1ms，一共推进 d，返回期间同时存在的 leader 的最大数量
+func run(g *Group, clock *sim.Virtual, d time.Duration) int {
+	most := 0
+	for i := time.Duration(0); i < d; i += time.Millisecond {
+		clock.Advance(time.Millisecond)
+		count := 0
+		for id := range g.procs {
+			if _, ok := g.IsLeader(id); ok {
+				count++
+			}
+		}
+		if count > most {
+			most = count
+		}
+	}
+	return most
+}
+
+func Test_Group_electsOneLeader(t *testing.T) {
+	ast := assert.New(t)
+	//
+	for seed := int64(0); seed < 20; seed++ {
+		g, clock := newTestGroup(5, testConfig, seed)
+		ast.Equal(1, run(g, clock, 2*time.Second), "seed %d", seed)
+		id, token, ok := g.Leader()
+		ast.True(ok)
+		ast.Equal(0, id, "没有设置优先级时，id 小的先参选")
+		ast.Equal(uint64(1), token)
+		m := g.Metrics()
+		ast.Equal(1, m.Elections)
+		ast.True(m.Renewals > 0)
+		ast.Empty(m.Failovers)
+	}
+}
+
+func Test_Group_failover(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := testConfig
+	c.Priorities = []int{1, 0, 5, 3, 0}
+	g, clock := newTestGroup(5, c, 1)
+	run(g, clock, 2*time.Second)
+	id, token, ok := g.Leader()
+	ast.True(ok)
+	ast.Equal(2, id, "优先级最高的先参选")
+	//
+	g.Crash(2)
+	ast.Equal(1, run(g, clock, 3*time.Second))
+	next, nextToken, ok := g.Leader()
+	ast.True(ok)
+	ast.Equal(3, next, "剩下的 process 中优先级最高的接任")
+	ast.True(nextToken > token)
+	m := g.Metrics()
+	ast.Equal(1, len(m.Failovers))
+	// voter 的承诺到期，加上 3 号的 backoff，加上一次往返
+	ast.True(m.MaxFailover() <= c.Lease+c.Backoff+c.Backoff/2+2*15*time.Millisecond, "failover %s", m.MaxFailover())
+}
+
+func Test_Group_randomCrashes(t *testing.T) {
+	ast := assert.New(t)
+	//
+	for seed := int64(0); seed < 5; seed++ {
+		g, clock := newTestGroup(5, testConfig, seed)
+		rnd := sim.NewRand(seed)
+		var last uint64
+		for i := 0; i < 10; i++ {
+			ast.True(run(g, clock, 700*time.Millisecond) <= 1, "seed %d", seed)
+			if id, token, ok := g.Leader(); ok {
+				ast.True(token >= last, "fencing token 不会变小")
+				last = token
+				if rnd.Intn(2) == 0 {
+					g.Crash(id)
+				}
+			}
+			for id := 0; id < g.Size(); id++ {
+				if g.procs[id].crashed && rnd.Intn(3) == 0 {
+					g.Restart(id)
+				}
+			}
+		}
+	}
+}
+
+func Test_Group_weights(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := testConfig
+	c.Weights = []int{3, 1, 1, 1, 1}
+	g, clock := newTestGroup(5, c, 2)
+	run(g, clock, 2*time.Second)
+	//
+	g.Crash(0)
+	run(g, clock, 3*time.Second)
+	_, _, ok := g.Leader()
+	ast.True(ok, "剩下的 4 票超过了总票数 7 的一半")
+	//
+	g.Crash(1)
+	ast.Equal(0, run(g, clock, 5*time.Second), "剩下的 3 票不够")
+	//
+	g.Restart(0)
+	run(g, clock, 3*time.Second)
+	_, _, ok = g.Leader()
+	ast.True(ok)
+}
+
+func Test_Group_restartWaitsLease(t *testing.T) {
+	ast := assert.New(t)
+	//
+	g, clock := newTestGroup(3, testConfig, 3)
+	run(g, clock, 2*time.Second)
+	g.Crash(1)
+	g.Crash(2)
+	run(g, clock, 2*time.Second)
+	_, _, ok := g.Leader()
+	ast.False(ok, "leader 无法续约，lease 到期后卸任")
+	//
+	g.Restart(1)
+	ast.Equal(0, run(g, clock, testConfig.Lease-50*time.Millisecond), "重启的 process 在 Lease 以内不投票")
+	run(g, clock, time.Second)
+	_, _, ok = g.Leader()
+	ast.True(ok)
+}
+
+func Test_Group_fencing(t *testing.T) {
+	ast := assert.New(t)
+	//
+	r := leaselock.NewFencedResource()
+	g, clock := newTestGroup(5, testConfig, 4)
+	run(g, clock, 2*time.Second)
+	old, stale, _ := g.Leader()
+	ast.Nil(r.Write(stale, "old"))
+	// 旧的 leader 在写入之前暂停了很久，它的 lease 已经到期，新的 leader 已经上任
+	g.Crash(old)
+	run(g, clock, 3*time.Second)
+	_, token, ok := g.Leader()
+	ast.True(ok)
+	ast.Nil(r.Write(token, "new"))
+	ast.Equal(leaselock.ErrStaleToken, r.Write(stale, "stale"))
+	ast.Equal([]string{"old", "new"}, r.Data())
+}
+
+func Test_Group_multiPaxosLeader(t *testing.T) {
+	ast := assert.New(t)
+	//
+	var p Provider
+	g, clock := newTestGroup(5, testConfig, 5)
+	p = g
+	run(g, clock, 2*time.Second)
+	g.Crash(0)
+	run(g, clock, 3*time.Second)
+	leader, _, ok := p.Leader()
+	ast.True(ok)
+	//
+	s := epaxos.NewMultiPaxos(epaxos.UniformLatency(5, 10*time.Millisecond), leader)
+	for i := 0; i < 10; i++ {
+		s.Propose(time.Duration(i)*time.Millisecond, i%5, epaxos.Command{ID: i, Key: "x", Write: true})
+	}
+	s.Run()
+	ast.Equal(10, s.Summary().Commits)
+	for id := 1; id < 5; id++ {
+		ast.Equal(s.Executed(0), s.Executed(id))
+	}
+}
@@ -0,0 +1,206 @@
+package election
+
+import "time"
+
+type msgType int
+
+const (
+	// 请求投票，续约时也发送它
+	requestVote msgType = iota
+	// 投票的回复
+	vote
+)
+
+type message struct {
+	kind    msgType
+	term    uint64
+	round   int // candidate 的第几轮请求，用来丢弃过期的回复
+	granted bool
+}
+
+// process 既是 voter，也是 candidate
+type process struct {
+	id         int
+	crashed    bool
+	generation int       // 每次崩溃和重启都会增加，让崩溃前设置的定时器失效
+	recovering time.Time // 重启以后，在这个时间之前不投票
+
+	// voter 的状态，term 保存在 stable storage 中，重启以后不会丢失
+	term    uint64    // 见过的最大的 term
+	holder  int       // 最后一次投票给了谁
+	promise time.Time // 在这个时间之前，不会投票给 holder 以外的 candidate
+
+	// candidate 的状态
+	campaign uint64    // 正在竞选或者担任 leader 的 term，也就是 fencing token
+	round    int       // 当前这一轮请求的编号
+	votes    int       // 当前这一轮得到的票数
+	sentAt   time.Time // 当前这一轮请求的发送时间
+	leading  bool
+	leaseEnd time.Time // leader 的 lease 的到期时间
+}
+
+func newProcess(id int) *process {
+	return &process{
+		id:     id,
+		holder: -1,
+	}
+}
+
+// isLeader 返回 true，如果 p 是 lease 还有效的 leader
+// 需要在持有 g.mutex 时调用
+func (g *Group) isLeader(p *process) bool {
+	return !p.crashed && p.leading && g.config.Env.Now().Before(p.leaseEnd)
+}
+
+// watch 在 d 以后检查 p 是否需要参选
+func (g *Group) watch(p *process, d time.Duration) {
+	g.after(p, d, func() { g.check(p) })
+}
+
+// check 在 p 没有对其他 candidate 的承诺时，等待 backoff 以后参选
+// 其他 process 的 lease 只会让 p 的承诺延长，所以 p 只需要在承诺到期时再检查。
+func (g *Group) check(p *process) {
+	if p.leading {
+		return
+	}
+	now := g.config.Env.Now()
+	if now.Before(p.recovering) {
+		g.watch(p, p.recovering.Sub(now))
+		return
+	}
+	if p.holder != p.id && now.Before(p.promise) {
+		g.watch(p, p.promise.Sub(now))
+		return
+	}
+	g.after(p, g.backoff(p.id), func() {
+		if p.leading {
+			return
+		}
+		if p.holder != p.id && g.config.Env.Now().Before(p.promise) {
+			// 等待期间，已经投票给了优先级更高的 candidate
+			g.check(p)
+			return
+		}
+		g.campaign(p)
+	})
+}
+
+// campaign 让 p 以更大的 term 参选
+func (g *Group) campaign(p *process) {
+	if p.term > p.campaign {
+		p.campaign = p.term
+	}
+	p.campaign++
+	g.metrics.Campaigns++
+	round := g.requestVotes(p)
+	g.after(p, g.config.Renew, func() {
+		if p.round != round || p.leading {
+			return
+		}
+		// 没有得到多数票，可能是选票被瓜分了。
+		// 放弃这一轮以后，p 不会再成为这一轮的 leader，所以可以收回投给自己的票，
+		// 否则两个 candidate 都只投票给自己，谁也无法当选。
+		p.votes = -1
+		if p.holder == p.id {
+			p.promise = time.Time{}
+		}
+		g.check(p)
+	})
+}
+
+// renew 让 term 的 leader 续约，term 不变
+func (g *Group) renew(p *process, term uint64) {
+	if !p.leading || p.campaign != term {
+		return
+	}
+	g.requestVotes(p)
+	g.after(p, g.config.Renew, func() { g.renew(p, term) })
+}
+
+// requestVotes 开始新的一轮请求，返回这一轮的编号
+func (g *Group) requestVotes(p *process) int {
+	p.round++
+	p.votes = 0
+	p.sentAt = g.config.Env.Now()
+	for to := range g.procs {
+		g.send(p.id, to, message{kind: requestVote, term: p.campaign, round: p.round})
+	}
+	return p.round
+}
+
+func (g *Group) handle(p *process, from int, m message) {
+	switch m.kind {
+	case requestVote:
+		g.handleRequest(p, from, m)
+	case vote:
+		g.handleVote(p, from, m)
+	}
+}
+
+// handleRequest 决定是否投票给 from
+// 已经承诺给其他 candidate 时，承诺到期之前都不投票；
+// 投票给新的 candidate 时，它的 term 必须比见过的都大，续约时 term 可以相等。
+func (g *Group) handleRequest(p *process, from int, m message) {
+	now := g.config.Env.Now()
+	granted := !now.Before(p.recovering)
+	if p.holder == from {
+		granted = granted && m.term >= p.term
+	} else {
+		granted = granted && !now.Before(p.promise) && m.term > p.term
+	}
+	if granted {
+		p.term, p.holder = m.term, from
+		p.promise = now.Add(g.config.Lease)
+	}
+	g.send(p.id, from, message{kind: vote, term: p.term, round: m.round, granted: granted})
+}
+
+// handleVote 统计这一轮得到的票数，超过总票数的一半时，成为 leader 或者完成续约
+// lease 从发送请求的时间开始计算，早于 voter 承诺的开始时间，所以 leader 的 lease 总是先到期。
+func (g *Group) handleVote(p *process, from int, m message) {
+	if m.round != p.round || p.votes < 0 {
+		return
+	}
+	if !m.granted {
+		if m.term > p.term {
+			p.term = m.term
+		}
+		return
+	}
+	p.votes += g.weight(from)
+	if p.votes*2 <= g.total {
+		return
+	}
+	p.votes = -1 // 这一轮已经完成
+	p.leaseEnd = p.sentAt.Add(g.config.Lease)
+	if p.leading {
+		g.metrics.Renewals++
+		return
+	}
+	p.leading = true
+	g.metrics.Elections++
+	if !g.lost.IsZero() {
+		g.metrics.Failovers = append(g.metrics.Failovers, g.config.Env.Now().Sub(g.lost))
+		g.lost = time.Time{}
+	}
+	term := p.campaign
+	g.after(p, g.config.Renew, func() { g.renew(p, term) })
+	g.expire(p, term)
+}
+
+// expire 在 term 的 lease 到期时让 p 卸任
+func (g *Group) expire(p *process, term uint64) {
+	if !p.leading || p.campaign != term {
+		return
+	}
+	now := g.config.Env.Now()
+	if now.Before(p.leaseEnd) {
+		g.after(p, p.leaseEnd.Sub(now), func() { g.expire(p, term) })
+		return
+	}
+	p.leading = false
+	if g.lost.IsZero() {
+		g.lost = p.leaseEnd
+	}
+	g.check(p)
+}
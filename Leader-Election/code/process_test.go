@@ -0,0 +1,64 @@
+package election
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Group_handleRequest(t *testing.T) {
+	ast := assert.New(t)
+	//
+	clock := sim.NewVirtual(time.Unix(0, 0))
+	g := NewGroup(3, Config{Lease: time.Second, Env: sim.Deterministic(clock, 0)})
+	p := g.procs[0]
+	// 1 和 2 都还没有开始第 1 轮，会丢弃 p 的回复
+	g.handleRequest(p, 1, message{kind: requestVote, term: 1, round: 1})
+	ast.Equal(1, p.holder)
+	ast.Equal(uint64(1), p.term)
+	//
+	g.handleRequest(p, 2, message{kind: requestVote, term: 5, round: 1})
+	ast.Equal(1, p.holder, "承诺到期之前，不投票给其他 candidate")
+	ast.Equal(uint64(1), p.term, "拒绝不会提高 term")
+	//
+	clock.Advance(500 * time.Millisecond)
+	g.handleRequest(p, 1, message{kind: requestVote, term: 1, round: 1})
+	ast.Equal(clock.Now().Add(time.Second), p.promise, "续约时 term 可以相等")
+	//
+	clock.Advance(time.Second)
+	g.handleRequest(p, 2, message{kind: requestVote, term: 1, round: 1})
+	ast.Equal(1, p.holder, "新的 candidate 的 term 必须更大")
+	g.handleRequest(p, 2, message{kind: requestVote, term: 2, round: 1})
+	ast.Equal(2, p.holder)
+	ast.Equal(uint64(2), p.term)
+}
+
+func Test_Group_handleVote(t *testing.T) {
+	ast := assert.New(t)
+	//
+	clock := sim.NewVirtual(time.Unix(0, 0))
+	g := NewGroup(3, Config{
+		Weights: []int{1, 1, 3},
+		Lease:   time.Second,
+		Renew:   300 * time.Millisecond,
+		Env:     sim.Deterministic(clock, 0),
+	})
+	p := g.procs[0]
+	p.campaign = 1
+	round := g.requestVotes(p)
+	//
+	g.handleVote(p, 1, message{kind: vote, term: 1, round: round, granted: true})
+	g.handleVote(p, 0, message{kind: vote, term: 1, round: round, granted: true})
+	ast.False(p.leading, "2 票没有超过总票数 5 的一半")
+	g.handleVote(p, 2, message{kind: vote, term: 7, round: round})
+	ast.Equal(uint64(7), p.term, "从拒绝中得知更大的 term")
+	//
+	g.handleVote(p, 2, message{kind: vote, term: 1, round: round - 1, granted: true})
+	ast.False(p.leading, "丢弃上一轮的回复")
+	g.handleVote(p, 2, message{kind: vote, term: 1, round: round, granted: true})
+	ast.True(p.leading)
+	ast.Equal(p.sentAt.Add(time.Second), p.leaseEnd)
+	ast.Equal(1, g.Metrics().Elections)
+}
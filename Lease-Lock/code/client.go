@@ -0,0 +1,137 @@
+package leaselock
+
+import (
+	"sync"
+	"time"
+
+	reliablechannel "github.com/aQuaYi/Distributed-Algorithms/Reliable-Channel/code"
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+)
+
+// Lease 是 client 持有锁的凭证
+type Lease struct {
+	// Token 是 fencing token，每次授予锁时都会增大
+	// 访问 resource 时带上 Token，resource 就可以拒绝过期持有者的写入
+	Token uint64
+	// deadline 是 client 认为 lease 到期的时间
+	// client 在发送请求之前就开始计时，所以会比 server 更早认为 lease 到期
+	deadline time.Time
+	env      sim.Env // 与 client 所在的网络使用同一个时间
+}
+
+// Valid 返回在 client 看来，lease 是否还有效
+// 即使 Valid 返回 true，client 也可能因为停顿而在 lease 过期后才使用它，
+// 所以 resource 必须检查 Token
+func (l *Lease) Valid() bool {
+	return l.env.Now().Before(l.deadline)
+}
+
+// Client 通过 reliablechannel.Endpoint 访问 Server
+type Client struct {
+	ep     *reliablechannel.Endpoint
+	server int
+
+	mutex   sync.Mutex
+	nextID  int
+	pending map[int]chan *response
+	closed  bool
+}
+
+// NewClient 返回通过 ep 访问 ID 为 server 的 Server 的 client
+func NewClient(ep *reliablechannel.Endpoint, server int) *Client {
+	c := &Client{
+		ep:      ep,
+		server:  server,
+		pending: make(map[int]chan *response),
+	}
+	go c.receiveLoop()
+	return c
+}
+
+func (c *Client) receiveLoop() {
+	for {
+		d, ok := c.ep.Receive()
+		if !ok {
+			break
+		}
+		resp := d.Payload.(*response)
+		c.mutex.Lock()
+		ch := c.pending[resp.id]
+		delete(c.pending, resp.id)
+		c.mutex.Unlock()
+		ch <- resp
+	}
+	// ep 被关闭了，唤醒所有还在等待的调用
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.closed = true
+	for id, ch := range c.pending {
+		close(ch)
+		delete(c.pending, id)
+	}
+}
+
+// call 发送 req 并等待 server 的回复
+func (c *Client) call(req *request) (*response, error) {
+	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		return nil, ErrClosed
+	}
+	req.id = c.nextID
+	c.nextID++
+	ch := make(chan *response, 1)
+	c.pending[req.id] = ch
+	c.mutex.Unlock()
+
+	c.ep.Send(c.server, req)
+
+	resp, ok := <-ch
+	if !ok {
+		return nil, ErrClosed
+	}
+	return resp, resp.err
+}
+
+// Acquire 阻塞到获得锁为止，返回的 lease 在 ttl 后到期
+func (c *Client) Acquire(ttl time.Duration) (*Lease, error) {
+	start := c.ep.Env().Now()
+	resp, err := c.call(&request{op: opAcquire, ttl: ttl})
+	if err != nil {
+		return nil, err
+	}
+	// client 无法知道 server 是何时授予的锁，只好保守地认为，lease 从发送请求时就开始了
+	l := &Lease{Token: resp.token, deadline: start.Add(ttl), env: c.ep.Env()}
+	if !l.Valid() {
+		// 排队等待的时间超过了 ttl，立即续约一次，续约时 lease 从发送续约请求时开始
+		if err := c.Renew(l, ttl); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// Renew 把 lease 延长到 ttl 以后
+// lease 已经被 server 收回时，返回 ErrLeaseExpired
+func (c *Client) Renew(l *Lease, ttl time.Duration) error {
+	start := c.ep.Env().Now()
+	if _, err := c.call(&request{op: opRenew, token: l.Token, ttl: ttl}); err != nil {
+		return err
+	}
+	l.deadline = start.Add(ttl)
+	return nil
+}
+
+// Release 释放 lease 对应的锁
+// lease 已经被 server 收回时，返回 ErrLeaseExpired
+func (c *Client) Release(l *Lease) error {
+	_, err := c.call(&request{op: opRelease, token: l.Token})
+	l.deadline = time.Time{}
+	return err
+}
+
+// Close 关闭 client 的 endpoint
+// 持有的锁不会被释放，要等到 lease 过期后才会被 server 收回，就像 client 崩溃了一样
+func (c *Client) Close() {
+	c.ep.Close()
+}
@@ -0,0 +1,196 @@
+package leaselock
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	reliablechannel "github.com/aQuaYi/Distributed-Algorithms/Reliable-Channel/code"
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+	"github.com/stretchr/testify/assert"
+)
+
+// serverID 是测试中 server 的 endpoint ID，client 的 ID 从 1 开始
+const serverID = 0
+
+type testEnv struct {
+	clock   *sim.Virtual
+	net     *reliablechannel.LossyNetwork
+	ep      *reliablechannel.Endpoint
+	server  *Server
+	clients []*Client
+}
+
+// newTestEnv 在会丢包、重复和乱序的网络上，启动一个 server 和 n 个 client
+// 网络、重传和 lease 都使用虚拟时间，测试的结果不受机器负载的影响
+func newTestEnv(n int) *testEnv {
+	clock := sim.NewVirtual(time.Unix(0, 0))
+	env := &testEnv{
+		clock: clock,
+		net:   reliablechannel.NewLossyNetworkWithEnv(0.2, 0.1, 2*time.Millisecond, sim.Deterministic(clock, 1)),
+	}
+	env.ep = reliablechannel.NewEndpoint(serverID, env.net, 10*time.Millisecond)
+	env.server = NewServer(env.ep)
+	go env.server.Serve()
+	for i := 1; i <= n; i++ {
+		ep := reliablechannel.NewEndpoint(i, env.net, 10*time.Millisecond)
+		env.clients = append(env.clients, NewClient(ep, serverID))
+	}
+	return env
+}
+
+// run 在 f 返回之前，一毫秒一毫秒地推进虚拟时间
+// 每一步之后稍等片刻，让 server 和 client 的 goroutine 处理完送达的消息
+func (env *testEnv) run(f func()) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f()
+	}()
+	for {
+		select {
+		case <-done:
+			return
+		case <-time.After(time.Millisecond):
+			env.clock.Advance(time.Millisecond)
+		}
+	}
+}
+
+// sleep 像 run 一样，把虚拟时间推进 d
+func (env *testEnv) sleep(d time.Duration) {
+	for end := env.clock.Now().Add(d); env.clock.Now().Before(end); {
+		time.Sleep(time.Millisecond)
+		env.clock.Advance(time.Millisecond)
+	}
+}
+
+func (env *testEnv) close() {
+	for _, c := range env.clients {
+		c.Close()
+	}
+	env.ep.Close()
+}
+
+func Test_Client_mutualExclusion(t *testing.T) {
+	ast := assert.New(t)
+	//
+	env := newTestEnv(4)
+	defer env.close()
+	r := NewFencedResource()
+	//
+	var mutex sync.Mutex
+	holding := 0
+	var wg sync.WaitGroup
+	for _, c := range env.clients {
+		wg.Add(1)
+		go func(c *Client) {
+			defer wg.Done()
+			for i := 0; i < 5; i++ {
+				l, err := c.Acquire(time.Second)
+				ast.Nil(err)
+				mutex.Lock()
+				holding++
+				ast.Equal(1, holding)
+				mutex.Unlock()
+				ast.Nil(r.Write(l.Token, "w"))
+				mutex.Lock()
+				holding--
+				mutex.Unlock()
+				ast.Nil(c.Release(l))
+			}
+		}(c)
+	}
+	env.run(wg.Wait)
+	ast.Equal(20, len(r.Data()))
+	ast.Equal(0, env.server.Revoked())
+}
+
+func Test_Client_renew(t *testing.T) {
+	ast := assert.New(t)
+	//
+	env := newTestEnv(2)
+	defer env.close()
+	holder, other := env.clients[0], env.clients[1]
+	ttl := 100 * time.Millisecond
+	//
+	var l *Lease
+	var err error
+	env.run(func() { l, err = holder.Acquire(ttl) })
+	ast.Nil(err)
+	ast.True(l.Valid())
+	acquired := make(chan *Lease)
+	go func() {
+		l, _ := other.Acquire(ttl)
+		acquired <- l
+	}()
+	// 只要按时续约，锁就不会被收回
+	for i := 0; i < 5; i++ {
+		env.sleep(ttl / 2)
+		env.run(func() { err = holder.Renew(l, ttl) })
+		ast.Nil(err)
+	}
+	ast.Equal(0, env.server.Revoked())
+	env.run(func() { err = holder.Release(l) })
+	ast.Nil(err)
+	ast.False(l.Valid())
+	//
+	var next *Lease
+	env.run(func() { next = <-acquired })
+	ast.Equal(l.Token+1, next.Token)
+	ast.True(next.Valid())
+}
+
+func Test_Client_holderCrashed(t *testing.T) {
+	ast := assert.New(t)
+	//
+	env := newTestEnv(2)
+	defer env.close()
+	crashed, other := env.clients[0], env.clients[1]
+	ttl := 50 * time.Millisecond
+	//
+	var l0, l1 *Lease
+	var err error
+	env.run(func() { l0, err = crashed.Acquire(ttl) })
+	ast.Nil(err)
+	// 持有者崩溃了，没有释放锁
+	crashed.Close()
+	_, err = crashed.Acquire(ttl)
+	ast.Equal(ErrClosed, err)
+	// lease 过期后，锁被收回，交给了下一个等待者
+	env.run(func() { l1, err = other.Acquire(ttl) })
+	ast.Nil(err)
+	ast.True(l0.Token < l1.Token)
+	ast.Equal(1, env.server.Revoked())
+}
+
+func Test_Client_staleHolder(t *testing.T) {
+	ast := assert.New(t)
+	//
+	env := newTestEnv(2)
+	defer env.close()
+	stale, other := env.clients[0], env.clients[1]
+	ttl := 50 * time.Millisecond
+	r := NewFencedResource()
+	//
+	var l0, l1 *Lease
+	var err error
+	env.run(func() { l0, err = stale.Acquire(ttl) })
+	ast.Nil(err)
+	ast.Nil(r.Write(l0.Token, "stale-1"))
+	// 持有者停顿的时间超过了 lease，锁被转交给了 other
+	env.run(func() { l1, err = other.Acquire(ttl) })
+	ast.Nil(err)
+	ast.False(l0.Valid())
+	ast.Nil(r.Write(l1.Token, "other"))
+	// 过期的持有者醒来以后，写入会被拒绝，也无法续约和释放
+	ast.Equal(ErrStaleToken, r.Write(l0.Token, "stale-2"))
+	env.run(func() { err = stale.Renew(l0, ttl) })
+	ast.Equal(ErrLeaseExpired, err)
+	env.run(func() { err = stale.Release(l0) })
+	ast.Equal(ErrLeaseExpired, err)
+	ast.Equal([]string{"stale-1", "other"}, r.Data())
+	//
+	env.run(func() { err = other.Release(l1) })
+	ast.Nil(err)
+}
@@ -0,0 +1,58 @@
+package leaselock
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// 可能返回的错误
+var (
+	// ErrLeaseExpired 表示 lease 已经过期，锁被 server 收回了
+	ErrLeaseExpired = errors.New("leaselock: lease has expired")
+	// ErrStaleToken 表示写入者的 fencing token 比 resource 见过的旧
+	ErrStaleToken = errors.New("leaselock: stale fencing token")
+	// ErrClosed 表示 client 已经被关闭了
+	ErrClosed = errors.New("leaselock: client is closed")
+)
+
+type opType int
+
+const (
+	opAcquire opType = iota
+	opRenew
+	opRelease
+)
+
+func (o opType) String() string {
+	switch o {
+	case opAcquire:
+		return "Acquire"
+	case opRenew:
+		return "Renew"
+	case opRelease:
+		return "Release"
+	default:
+		panic("出现了未知的 opType")
+	}
+}
+
+// request 是 client 发给 server 的请求
+type request struct {
+	id    int
+	op    opType
+	token uint64        // Renew 和 Release 时，client 持有的 token
+	ttl   time.Duration // Acquire 和 Renew 时，希望 lease 持续的时间
+}
+
+func (r *request) String() string {
+	return fmt.Sprintf("{%d:%s token:%d ttl:%s}", r.id, r.op, r.token, r.ttl)
+}
+
+// response 是 server 对 request 的回复
+// 对于 Acquire，server 会等到锁被授予时才回复
+type response struct {
+	id    int
+	token uint64
+	err   error
+}
@@ -0,0 +1,39 @@
+package leaselock
+
+import "sync"
+
+// FencedResource 是利用 fencing token 保护的 resource
+// 它记住见过的最大 token，拒绝所有带着更小 token 的写入。
+// 锁被收回后，即使过期的持有者还以为自己持有锁，它的写入也会被拒绝。
+type FencedResource struct {
+	mutex    sync.Mutex
+	maxToken uint64
+	data     []string
+}
+
+// NewFencedResource 返回一个空的 FencedResource
+func NewFencedResource() *FencedResource {
+	return &FencedResource{}
+}
+
+// Write 带着 token 写入 data
+// token 比见过的最大 token 小时，返回 ErrStaleToken
+func (r *FencedResource) Write(token uint64, data string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if token < r.maxToken {
+		return ErrStaleToken
+	}
+	r.maxToken = token
+	r.data = append(r.data, data)
+	return nil
+}
+
+// Data 按照写入的顺序，返回所有被接受的写入
+func (r *FencedResource) Data() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	res := make([]string, len(r.data))
+	copy(res, r.data)
+	return res
+}
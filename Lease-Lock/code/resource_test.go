@@ -0,0 +1,19 @@
+package leaselock
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_FencedResource_Write(t *testing.T) {
+	ast := assert.New(t)
+	//
+	r := NewFencedResource()
+	ast.Nil(r.Write(1, "a"))
+	ast.Nil(r.Write(1, "b"))
+	ast.Nil(r.Write(3, "c"))
+	ast.Equal(ErrStaleToken, r.Write(2, "d"))
+	ast.Nil(r.Write(3, "e"))
+	ast.Equal([]string{"a", "b", "c", "e"}, r.Data())
+}
@@ -0,0 +1,125 @@
+package leaselock
+
+import (
+	"sync"
+	"time"
+
+	reliablechannel "github.com/aQuaYi/Distributed-Algorithms/Reliable-Channel/code"
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+)
+
+// waiter 是正在等待锁的 Acquire 请求
+type waiter struct {
+	from int
+	req  *request
+}
+
+// Server 管理一把带 lease 的锁
+// 锁每次被授予时，都会附带一个单调递增的 fencing token。
+// 持有者需要在 lease 到期前 Renew，否则 server 会收回锁，转交给下一个等待者，
+// 所以持有者崩溃或者停顿太久，都不会让锁永远无法被获取。
+type Server struct {
+	ep *reliablechannel.Endpoint
+
+	mutex    sync.Mutex
+	holder   int       // 持有锁的 client，-1 表示锁是空闲的
+	token    uint64    // 最后一次授予锁时的 token
+	deadline time.Time // 当前 lease 到期的时间
+	timer    sim.Timer
+	waiters  []waiter // 按照到达的顺序排队
+	revoked  int      // 由于 lease 过期而被收回的次数
+}
+
+// NewServer 返回通过 ep 提供服务的 server，调用 Serve 后开始处理请求
+func NewServer(ep *reliablechannel.Endpoint) *Server {
+	return &Server{
+		ep:     ep,
+		holder: -1,
+	}
+}
+
+// Serve 处理请求，直到 ep 被关闭
+func (s *Server) Serve() {
+	for {
+		d, ok := s.ep.Receive()
+		if !ok {
+			return
+		}
+		s.handle(d.From, d.Payload.(*request))
+	}
+}
+
+// Revoked 返回由于 lease 过期而被收回锁的次数
+func (s *Server) Revoked() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.revoked
+}
+
+func (s *Server) handle(from int, req *request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	switch req.op {
+	case opAcquire:
+		s.waiters = append(s.waiters, waiter{from: from, req: req})
+		if s.holder == -1 {
+			s.grantNext()
+		}
+	case opRenew:
+		resp := &response{id: req.id, token: req.token}
+		if s.isHolding(from, req.token) {
+			s.deadline = s.ep.Env().Now().Add(req.ttl)
+			s.timer.Reset(req.ttl)
+		} else {
+			resp.err = ErrLeaseExpired
+		}
+		s.ep.Send(from, resp)
+	case opRelease:
+		resp := &response{id: req.id, token: req.token}
+		if s.isHolding(from, req.token) {
+			s.timer.Stop()
+			s.grantNext()
+		} else {
+			resp.err = ErrLeaseExpired
+		}
+		s.ep.Send(from, resp)
+	}
+}
+
+func (s *Server) isHolding(client int, token uint64) bool {
+	return s.holder == client && s.token == token
+}
+
+// grantNext 把锁授予下一个等待者，没有等待者时，锁变为空闲
+// 需要在持有 s.mutex 时调用
+func (s *Server) grantNext() {
+	if len(s.waiters) == 0 {
+		s.holder = -1
+		return
+	}
+	w := s.waiters[0]
+	s.waiters = s.waiters[1:]
+
+	s.token++
+	s.holder = w.from
+	token := s.token
+	// lease 从 server 授予锁的时刻开始计算
+	s.deadline = s.ep.Env().Now().Add(w.req.ttl)
+	s.timer = s.ep.Env().AfterFunc(w.req.ttl, func() { s.expire(token) })
+
+	s.ep.Send(w.from, &response{id: w.req.id, token: token})
+}
+
+// expire 在 token 的 lease 到期时被调用
+func (s *Server) expire(token uint64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	// timer.Stop 和 timer.Reset 无法阻止已经触发的 expire，
+	// 所以要检查 token 是否还有效，lease 是否被续约过
+	if s.holder == -1 || s.token != token || s.ep.Env().Now().Before(s.deadline) {
+		return
+	}
+	s.revoked++
+	s.grantNext()
+}
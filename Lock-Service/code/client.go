@@ -0,0 +1,207 @@
+package lockservice
+
+import (
+	"sync"
+
+	"github.com/aQuaYi/Distributed-Algorithms/Raft/code/labrpc"
+	"github.com/aQuaYi/Distributed-Algorithms/Raft/code/rsm"
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+)
+
+// keepAliveInterval 是 Client 发送 KeepAlive 的间隔，一个有效期内至少会发送 3 次
+const keepAliveInterval = SessionTimeout / 3
+
+// Client 是 LockServer 集群的客户端
+// 每个 Client 拥有一个 session，它获取的锁都属于这个 session。
+// session 在 Close 或者过期时结束，其持有的锁会被自动释放。
+type Client struct {
+	servers []*labrpc.ClientEnd
+	env     sim.Env
+
+	mutex  sync.Mutex // 保证同一个 Client 的请求是串行的
+	ck     *rsm.Clerk
+	closed bool
+
+	stop chan struct{} // 关闭以后，keepAlive 停止
+}
+
+// MakeClient 返回一个通过 servers 访问集群的 Client
+// 调用 Open 以后，才能获取锁
+func MakeClient(servers []*labrpc.ClientEnd) *Client {
+	return MakeClientWithEnv(servers, sim.Env{})
+}
+
+// MakeClientWithEnv 与 MakeClient 一样，但是 c 从 env 读取时间
+func MakeClientWithEnv(servers []*labrpc.ClientEnd, env sim.Env) *Client {
+	return &Client{
+		servers: servers,
+		env:     env,
+		ck:      rsm.MakeClerk(servers, env),
+		stop:    make(chan struct{}),
+	}
+}
+
+// Session 返回 c 的 session，与 clientID 相同
+func (c *Client) Session() int64 {
+	return c.ck.ClientID()
+}
+
+// submit 把 op 发送给 leader，直到它被应用
+// 利用调用方的锁进行锁定
+func (c *Client) submit(t opType, name string) OpReply {
+	session := c.ck.ClientID()
+	args := OpArgs{Type: t, Session: session, Name: name, ClientID: session, Seq: c.ck.NextSeq()}
+	var res OpReply
+	c.ck.Call(func(server *labrpc.ClientEnd) bool {
+		var reply OpReply
+		if !server.Call("LockServer.Submit", &args, &reply) {
+			return false
+		}
+		switch reply.Err {
+		case ErrWrongLeader, ErrTimeout:
+			return false
+		}
+		res = reply
+		return true
+	})
+	return res
+}
+
+// Open 创建 c 的 session，并开始定期发送 KeepAlive
+func (c *Client) Open() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		return ErrClosed
+	}
+	c.submit(opOpen, "")
+	go c.keepAlive()
+	return nil
+}
+
+// keepAlive 定期通知 leader，c 依然活着
+// 与 call 不同，失败了也不重试，等下一次发送，所以可以与 c 的其他请求并发执行
+func (c *Client) keepAlive() {
+	args := KeepAliveArgs{Session: c.ck.ClientID()}
+	leader := 0
+	ticker := c.env.NewTicker(keepAliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C():
+		}
+		for i := 0; i < len(c.servers); i++ {
+			id := (leader + i) % len(c.servers)
+			var reply KeepAliveReply
+			if !c.servers[id].Call("LockServer.KeepAlive", &args, &reply) {
+				continue
+			}
+			if reply.Err == OK {
+				leader = id
+				break
+			}
+			if reply.Err == ErrNoSession {
+				// session 已经过期，没有必要再发送了
+				return
+			}
+		}
+	}
+}
+
+// TryLock 尝试获取名为 name 的锁，锁被其他 session 持有时，返回 false
+// 已经持有该锁时，返回原来的 Sequencer
+func (c *Client) TryLock(name string) (Sequencer, bool, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		return Sequencer{}, false, ErrClosed
+	}
+	reply := c.submit(opAcquire, name)
+	switch reply.Err {
+	case OK:
+		return Sequencer{Name: name, Session: c.ck.ClientID(), Token: reply.Token}, true, nil
+	case ErrNoSession:
+		return Sequencer{}, false, ErrSessionExpired
+	}
+	return Sequencer{}, false, nil
+}
+
+// Lock 阻塞到获取了名为 name 的锁
+func (c *Client) Lock(name string) (Sequencer, error) {
+	for {
+		s, ok, err := c.TryLock(name)
+		if ok || err != nil {
+			return s, err
+		}
+		c.env.Sleep(rsm.RetryInterval)
+	}
+}
+
+// Unlock 释放名为 name 的锁
+func (c *Client) Unlock(name string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		return ErrClosed
+	}
+	if c.submit(opRelease, name).Err != OK {
+		return ErrNotHeld
+	}
+	return nil
+}
+
+// Check 检查 s 是否依然有效，即 s 对应的那次授予之后，锁没有被释放过
+// resource 可以用它拒绝已经失去锁的持有者的请求
+func (c *Client) Check(s Sequencer) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	args := CheckArgs{Sequencer: s}
+	var valid bool
+	c.ck.Call(func(server *labrpc.ClientEnd) bool {
+		var reply CheckReply
+		if !server.Call("LockServer.CheckSequencer", &args, &reply) {
+			return false
+		}
+		switch reply.Err {
+		case OK:
+			valid = true
+			return true
+		case ErrStaleSequencer:
+			return true
+		}
+		return false
+	})
+	return valid
+}
+
+// Close 结束 c 的 session，释放其持有的所有锁
+func (c *Client) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		return ErrClosed
+	}
+	c.closed = true
+	close(c.stop)
+	c.submit(opClose, "")
+	return nil
+}
+
+// Crash 模拟 Client 崩溃：不再发送 KeepAlive，也不会通知 server
+// session 过期以后，server 会释放它持有的锁
+func (c *Client) Crash() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if !c.closed {
+		c.closed = true
+		close(c.stop)
+	}
+}
@@ -0,0 +1,132 @@
+package lockservice
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aQuaYi/Distributed-Algorithms/Raft/code/labgob"
+	"github.com/aQuaYi/Distributed-Algorithms/Raft/code/rsm"
+)
+
+func init() {
+	// Op 会作为 raft.LogEntry.Command 在 server 之间传递
+	labgob.Register(Op{})
+}
+
+var (
+	// ErrSessionExpired 表示 session 已经过期，它持有的锁都已经被释放
+	ErrSessionExpired = errors.New("lockservice: session has expired")
+	// ErrNotHeld 表示释放了一把自己没有持有的锁
+	ErrNotHeld = errors.New("lockservice: lock is not held by this session")
+	// ErrClosed 表示 Client 已经关闭了 session
+	ErrClosed = errors.New("lockservice: client is closed")
+)
+
+// Err 是 RPC 的结果
+type Err = rsm.Err
+
+// 枚举了所有的 Err
+const (
+	OK                    = rsm.OK
+	ErrWrongLeader        = rsm.ErrWrongLeader
+	ErrTimeout            = rsm.ErrTimeout
+	ErrLocked         Err = "ErrLocked"         // 锁被其他 session 持有
+	ErrNoSession      Err = "ErrNoSession"      // session 不存在或者已经过期
+	ErrNotHolder      Err = "ErrNotHolder"      // 释放了自己没有持有的锁
+	ErrStaleSequencer Err = "ErrStaleSequencer" // 只在 CheckSequencer 中使用
+)
+
+type opType int
+
+// 枚举了 Op 的所有类型
+const (
+	opOpen    opType = iota // 创建 session
+	opClose                 // client 主动关闭 session
+	opExpire                // leader 发现 session 过期
+	opAcquire               // 获取锁
+	opRelease               // 释放锁
+	opNoop                  // leader 上任后，为了 commit 当前 term 的 log 而提交的空操作
+)
+
+func (t opType) String() string {
+	switch t {
+	case opOpen:
+		return "Open"
+	case opClose:
+		return "Close"
+	case opExpire:
+		return "Expire"
+	case opAcquire:
+		return "Acquire"
+	case opRelease:
+		return "Release"
+	default:
+		return "Noop"
+	}
+}
+
+// Op 是写入 raft log 的命令
+// lock table 和 session 都只通过 Op 修改，所以所有的 server 都会得到同样的状态
+type Op struct {
+	Type     opType
+	Session  int64
+	Name     string // 锁的名称
+	ClientID int64  // 发起操作的 Client，opExpire 由 leader 发起，ClientID 为 0
+	Seq      int    // 操作在 Client 中的序号，用于去重
+}
+
+// Request 返回发起 op 的 Client 和 op 的序号，实现了 rsm.Command 接口
+func (op Op) Request() (int64, int) {
+	return op.ClientID, op.Seq
+}
+
+func (op Op) String() string {
+	return fmt.Sprintf("%s{S%d, %q, C%d:%d}", op.Type, op.Session, op.Name, op.ClientID, op.Seq)
+}
+
+// Sequencer 描述了一次锁的授予，相当于 fencing token
+// Token 是授予锁的命令在 raft log 中的 index，同一把锁后一次授予的 Token 一定更大
+type Sequencer struct {
+	Name    string
+	Session int64
+	Token   int
+}
+
+func (s Sequencer) String() string {
+	return fmt.Sprintf("%s#%d", s.Name, s.Token)
+}
+
+// OpArgs 是所有修改 lock table 的 RPC 的参数
+type OpArgs struct {
+	Type     opType
+	Session  int64
+	Name     string
+	ClientID int64
+	Seq      int
+}
+
+// OpReply 是所有修改 lock table 的 RPC 的返回值
+type OpReply struct {
+	Err   Err
+	Token int // opAcquire 成功时，锁的 Token
+}
+
+// KeepAliveArgs 是 KeepAlive 的参数
+type KeepAliveArgs struct {
+	Session int64
+}
+
+// KeepAliveReply 是 KeepAlive 的返回值
+type KeepAliveReply struct {
+	Err Err
+}
+
+// CheckArgs 是 CheckSequencer 的参数
+type CheckArgs struct {
+	Sequencer Sequencer
+}
+
+// CheckReply 是 CheckSequencer 的返回值
+type CheckReply struct {
+	Err Err
+}
@@ -0,0 +1,233 @@
+package lockservice
+
+import (
+	"time"
+
+	raft "github.com/aQuaYi/Distributed-Algorithms/Raft/code"
+	"github.com/aQuaYi/Distributed-Algorithms/Raft/code/labrpc"
+	"github.com/aQuaYi/Distributed-Algorithms/Raft/code/rsm"
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+)
+
+const (
+	// SessionTimeout 是 session 的有效期，client 需要在此之前 KeepAlive
+	// 它需要比 raft 的选举超时与一次失败的 KeepAlive 之和长得多，否则 leader 切换时，活着的 session 也会过期
+	SessionTimeout = 3 * time.Second
+	// sweepInterval 是 leader 检查 session 是否过期的间隔
+	sweepInterval = SessionTimeout / 5
+)
+
+// lock 是 lock table 中的一把锁
+type lock struct {
+	session int64
+	token   int
+}
+
+// LockServer 是 Chubby 风格的锁服务器，lock table 和 session 通过 raft 复制
+// session 的过期时间只由 leader 本地的时钟决定，leader 发现过期时，把 opExpire 写入 raft log，
+// 所有的 server 应用 opExpire 时，才会删除 session 并释放它持有的锁，所以 lock table 总是一致的。
+type LockServer struct {
+	rsm *rsm.Server
+	env sim.Env
+
+	// 以下属性由 rsm 的锁保护
+	sessions map[int64]bool
+	locks    map[string]lock
+
+	// 以下属性只在 leader 上有意义，不需要复制
+	lastSeen map[int64]time.Time // 每个 session 最近一次 KeepAlive 的时间
+	term     int                 // 上次检查 session 时的 term
+}
+
+// StartLockServer 启动一个 LockServer
+// servers 是所有 server 的 raft 端点，me 是自己在 servers 中的索引
+func StartLockServer(servers []*labrpc.ClientEnd, me int, persister *raft.Persister) *LockServer {
+	return StartLockServerWithEnv(servers, me, persister, sim.Env{})
+}
+
+// StartLockServerWithEnv 与 StartLockServer 一样，但是 ls 和它的 raft 从 env 读取时间和随机数
+func StartLockServerWithEnv(servers []*labrpc.ClientEnd, me int, persister *raft.Persister, env sim.Env) *LockServer {
+	ls := &LockServer{
+		env:      env,
+		sessions: make(map[int64]bool, 16),
+		locks:    make(map[string]lock, 16),
+		lastSeen: make(map[int64]time.Time, 16),
+	}
+	ls.rsm = rsm.StartServer(servers, me, persister, ls, env)
+
+	go ls.sweepLoop()
+
+	return ls
+}
+
+// Raft 返回 ls 所使用的 raft
+func (ls *LockServer) Raft() *raft.Raft {
+	return ls.rsm.Raft()
+}
+
+// Kill 停止 ls
+func (ls *LockServer) Kill() {
+	ls.rsm.Kill()
+}
+
+// Apply 应用 log 中 index 处的 Op，返回 OpReply，实现了 rsm.StateMachine 接口
+func (ls *LockServer) Apply(command interface{}, index int) interface{} {
+	op, ok := command.(Op)
+	if !ok {
+		return nil
+	}
+	reply := OpReply{Err: OK}
+	switch op.Type {
+	case opOpen:
+		ls.sessions[op.Session] = true
+		ls.lastSeen[op.Session] = ls.env.Now()
+	case opClose, opExpire:
+		ls.endSession(op.Session)
+	case opAcquire:
+		reply.Err, reply.Token = ls.acquire(op.Session, op.Name, index)
+	case opRelease:
+		if l, ok := ls.locks[op.Name]; ok && l.session == op.Session {
+			delete(ls.locks, op.Name)
+		} else {
+			reply.Err = ErrNotHolder
+		}
+	}
+	return reply
+}
+
+// endSession 删除 session，并释放它持有的所有锁，锁是 ephemeral 的
+func (ls *LockServer) endSession(session int64) {
+	delete(ls.sessions, session)
+	delete(ls.lastSeen, session)
+	for name, l := range ls.locks {
+		if l.session == session {
+			delete(ls.locks, name)
+		}
+	}
+}
+
+func (ls *LockServer) acquire(session int64, name string, index int) (Err, int) {
+	if !ls.sessions[session] {
+		return ErrNoSession, 0
+	}
+	l, held := ls.locks[name]
+	switch {
+	case !held:
+		ls.locks[name] = lock{session: session, token: index}
+		return OK, index
+	case l.session == session:
+		return OK, l.token
+	default:
+		return ErrLocked, 0
+	}
+}
+
+// sweepLoop 在 leader 上定期检查 session 是否过期
+func (ls *LockServer) sweepLoop() {
+	for {
+		ls.env.Sleep(sweepInterval)
+		term, isLeader := ls.Raft().GetState()
+
+		ls.rsm.Lock()
+		if ls.rsm.Killed() {
+			ls.rsm.Unlock()
+			return
+		}
+		if !isLeader {
+			ls.rsm.Unlock()
+			continue
+		}
+		now := ls.env.Now()
+		if term != ls.term {
+			// 新的 leader 不知道 client 最近一次 KeepAlive 的时间，
+			// 与 Chubby 一样，给所有的 session 一个完整的有效期作为宽限期
+			ls.term = term
+			for s := range ls.sessions {
+				ls.lastSeen[s] = now
+			}
+		}
+		var expired []int64
+		for s := range ls.sessions {
+			if now.Sub(ls.lastSeen[s]) > SessionTimeout {
+				expired = append(expired, s)
+				// 在 opExpire 被应用之前，不要重复提交
+				ls.lastSeen[s] = now
+			}
+		}
+		ls.rsm.Unlock()
+
+		for _, s := range expired {
+			ls.Raft().Start(Op{Type: opExpire, Session: s})
+		}
+	}
+}
+
+// Submit 是 RPC handler，所有修改 lock table 和 session 的请求都通过它写入 raft log
+func (ls *LockServer) Submit(args *OpArgs, reply *OpReply) {
+	value, err := ls.rsm.Submit(Op{
+		Type:     args.Type,
+		Session:  args.Session,
+		Name:     args.Name,
+		ClientID: args.ClientID,
+		Seq:      args.Seq,
+	})
+	if err != OK {
+		reply.Err = err
+		return
+	}
+	*reply = value.(OpReply)
+}
+
+// KeepAlive 是 RPC handler，延长 session 的有效期
+// KeepAlive 不写入 raft log，只有 leader 会记录它的时间。
+// 被隔离的旧 leader 依然认为自己是 leader，所以要先通过 ReadIndex 确认身份，
+// 否则 Client 会一直向旧 leader 发送 KeepAlive，而真正的 leader 会让 session 过期。
+func (ls *LockServer) KeepAlive(args *KeepAliveArgs, reply *KeepAliveReply) {
+	if _, ok := ls.rsm.ReadIndex(Op{Type: opNoop}); !ok {
+		reply.Err = ErrWrongLeader
+		return
+	}
+
+	ls.rsm.Lock()
+	defer ls.rsm.Unlock()
+
+	if !ls.sessions[args.Session] {
+		reply.Err = ErrNoSession
+		return
+	}
+	ls.lastSeen[args.Session] = ls.env.Now()
+	reply.Err = OK
+}
+
+// CheckSequencer 是 RPC handler，检查 args.Sequencer 是否依然持有锁
+// resource 可以在执行请求之前调用它，拒绝过期持有者的请求。利用 ReadIndex 保证读到最新的 lock table
+func (ls *LockServer) CheckSequencer(args *CheckArgs, reply *CheckReply) {
+	readIndex, ok := ls.rsm.ReadIndex(Op{Type: opNoop})
+	if !ok {
+		reply.Err = ErrWrongLeader
+		return
+	}
+	if !ls.rsm.WaitApplied(readIndex) {
+		reply.Err = ErrTimeout
+		return
+	}
+
+	ls.rsm.Lock()
+	defer ls.rsm.Unlock()
+
+	s := args.Sequencer
+	if l, ok := ls.locks[s.Name]; ok && l.session == s.Session && l.token == s.Token {
+		reply.Err = OK
+		return
+	}
+	reply.Err = ErrStaleSequencer
+}
+
+// Holder 返回 name 在 ls 本地的 lock table 中的持有者，没有持有者时返回 false
+// 只用于观察，不保证读到最新的状态
+func (ls *LockServer) Holder(name string) (int64, bool) {
+	ls.rsm.Lock()
+	defer ls.rsm.Unlock()
+	l, ok := ls.locks[name]
+	return l.session, ok
+}
@@ -0,0 +1,214 @@
+package lockservice
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aQuaYi/Distributed-Algorithms/Raft/code/rsm"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Cluster_lockUnlock(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := MakeCluster(3)
+	defer c.Cleanup()
+	a, b := c.MakeClient(), c.MakeClient()
+	ast.NoError(a.Open())
+	ast.NoError(b.Open())
+	defer a.Close()
+	defer b.Close()
+	//
+	s, ok, err := a.TryLock("x")
+	ast.True(ok)
+	ast.NoError(err)
+	ast.Equal(a.Session(), s.Session)
+	// 重复获取，得到同一个 Sequencer
+	again, ok, _ := a.TryLock("x")
+	ast.True(ok)
+	ast.Equal(s, again)
+	//
+	_, ok, err = b.TryLock("x")
+	ast.False(ok)
+	ast.NoError(err)
+	ast.Equal(ErrNotHeld, b.Unlock("x"))
+	//
+	ast.NoError(a.Unlock("x"))
+	ast.False(a.Check(s))
+	next, ok, _ := b.TryLock("x")
+	ast.True(ok)
+	ast.True(next.Token > s.Token)
+	ast.True(b.Check(next))
+}
+
+func Test_Cluster_mutualExclusion(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := MakeCluster(3)
+	defer c.Cleanup()
+	//
+	clients, times := 4, 5
+	holders, maxHolders := 0, 0
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	wg.Add(clients)
+	for i := 0; i < clients; i++ {
+		go func() {
+			defer wg.Done()
+			cl := c.MakeClient()
+			cl.Open()
+			defer cl.Close()
+			for j := 0; j < times; j++ {
+				_, err := cl.Lock("x")
+				ast.NoError(err)
+				mutex.Lock()
+				holders++
+				if holders > maxHolders {
+					maxHolders = holders
+				}
+				mutex.Unlock()
+				time.Sleep(time.Millisecond)
+				mutex.Lock()
+				holders--
+				mutex.Unlock()
+				ast.NoError(cl.Unlock("x"))
+			}
+		}()
+	}
+	wg.Wait()
+	ast.Equal(1, maxHolders)
+}
+
+func Test_Cluster_closeReleasesLocks(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := MakeCluster(3)
+	defer c.Cleanup()
+	a, b := c.MakeClient(), c.MakeClient()
+	a.Open()
+	b.Open()
+	defer b.Close()
+	//
+	s, _, _ := a.TryLock("x")
+	a.TryLock("y")
+	ast.NoError(a.Close())
+	ast.Equal(ErrClosed, a.Close())
+	_, _, err := a.TryLock("x")
+	ast.Equal(ErrClosed, err)
+	//
+	_, ok, _ := b.TryLock("x")
+	ast.True(ok)
+	_, ok, _ = b.TryLock("y")
+	ast.True(ok)
+	ast.False(b.Check(s))
+}
+
+func Test_Cluster_sessionExpires(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := MakeCluster(3)
+	defer c.Cleanup()
+	a, b := c.MakeClient(), c.MakeClient()
+	a.Open()
+	b.Open()
+	defer b.Close()
+	s, _, _ := a.TryLock("x")
+	// KeepAlive 让 session 在有效期之后依然存在
+	time.Sleep(SessionTimeout + 2*sweepInterval)
+	_, ok, _ := b.TryLock("x")
+	ast.False(ok)
+	ast.True(b.Check(s))
+	//
+	a.Crash()
+	ast.True(waitReleased(c, "x", 2*SessionTimeout))
+	ast.False(b.Check(s))
+	_, ok, _ = b.TryLock("x")
+	ast.True(ok)
+}
+
+// waitReleased 等待所有的 server 都释放了名为 name 的锁，超时返回 false
+func waitReleased(c *Cluster, name string, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		released := true
+		for _, ls := range c.servers {
+			if _, held := ls.Holder(name); held {
+				released = false
+			}
+		}
+		if released {
+			return true
+		}
+		time.Sleep(rsm.RetryInterval)
+	}
+	return false
+}
+
+func Test_Cluster_leaderFailover(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := MakeCluster(3)
+	defer c.Cleanup()
+	a, b := c.MakeClient(), c.MakeClient()
+	a.Open()
+	b.Open()
+	defer a.Close()
+	defer b.Close()
+	s, _, _ := a.TryLock("x")
+	//
+	oldLeader := c.Leader()
+	ast.NotEqual(-1, oldLeader)
+	c.Disconnect(oldLeader)
+	// 新 leader 给所有的 session 一个宽限期，a 依然在发送 KeepAlive，所以不会失去锁
+	time.Sleep(SessionTimeout + 2*sweepInterval)
+	_, ok, _ := b.TryLock("x")
+	ast.False(ok)
+	ast.True(b.Check(s))
+	//
+	ast.NoError(a.Unlock("x"))
+	next, ok, _ := b.TryLock("x")
+	ast.True(ok)
+	ast.True(next.Token > s.Token)
+	// 被隔离的旧 leader 无法确认 Sequencer
+	var reply CheckReply
+	c.servers[oldLeader].CheckSequencer(&CheckArgs{Sequencer: next}, &reply)
+	ast.NotEqual(OK, reply.Err)
+	c.Connect(oldLeader)
+}
+
+func Test_LockServer_Apply(t *testing.T) {
+	ast := assert.New(t)
+	//
+	ls := &LockServer{
+		sessions: make(map[int64]bool),
+		locks:    make(map[string]lock),
+		lastSeen: make(map[int64]time.Time),
+	}
+	ast.Equal(OpReply{Err: ErrNoSession}, ls.Apply(Op{Type: opAcquire, Session: 1, Name: "x", ClientID: 1, Seq: 1}, 1))
+	//
+	ls.Apply(Op{Type: opOpen, Session: 1, ClientID: 1, Seq: 2}, 2)
+	ls.Apply(Op{Type: opOpen, Session: 2, ClientID: 2, Seq: 1}, 3)
+	ast.Equal(OpReply{Err: OK, Token: 4}, ls.Apply(Op{Type: opAcquire, Session: 1, Name: "x", ClientID: 1, Seq: 3}, 4))
+	// 已经持有锁时，返回原来的 Token
+	ast.Equal(OpReply{Err: OK, Token: 4}, ls.Apply(Op{Type: opAcquire, Session: 1, Name: "x", ClientID: 1, Seq: 4}, 5))
+	ast.Equal(OpReply{Err: ErrLocked}, ls.Apply(Op{Type: opAcquire, Session: 2, Name: "x", ClientID: 2, Seq: 2}, 6))
+	ast.Equal(OpReply{Err: ErrNotHolder}, ls.Apply(Op{Type: opRelease, Session: 2, Name: "x", ClientID: 2, Seq: 3}, 7))
+	ast.Equal(lock{session: 1, token: 4}, ls.locks["x"])
+	// session 过期，释放它持有的锁
+	ls.Apply(Op{Type: opExpire, Session: 1}, 8)
+	ls.Apply(Op{Type: opExpire, Session: 1}, 9)
+	ast.Empty(ls.locks)
+	ast.False(ls.sessions[1])
+	ast.True(ls.sessions[2])
+	//
+	ls.Apply(Op{Type: opNoop}, 10)
+	ast.Equal(1, len(ls.sessions))
+}
+
+func Test_Op_String(t *testing.T) {
+	ast := assert.New(t)
+	op := Op{Type: opAcquire, Session: 1, Name: "x", ClientID: 1, Seq: 2}
+	ast.Equal(`Acquire{S1, "x", C1:2}`, op.String())
+	ast.Equal("x#3", Sequencer{Name: "x", Token: 3}.String())
+}
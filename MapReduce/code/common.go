@@ -0,0 +1,124 @@
+package mapreduce
+
+import (
+	"fmt"
+	"hash/fnv"
+)
+
+// KeyValue 是 map 输出的中间结果，也是 reduce 的输出
+type KeyValue struct {
+	Key   string
+	Value string
+}
+
+// MapFunc 把一份输入转换成一组中间结果
+type MapFunc func(input string) []KeyValue
+
+// ReduceFunc 把同一个 key 的所有中间结果合并成一个值
+type ReduceFunc func(key string, values []string) string
+
+// ihash 决定了中间结果属于哪一个 reduce 任务
+func ihash(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() & 0x7fffffff)
+}
+
+// TaskType 是任务的类型
+type TaskType int
+
+// 枚举了所有的 TaskType
+const (
+	MapTask TaskType = iota
+	ReduceTask
+	WaitTask // 暂时没有可以分配的任务，稍后再来
+	ExitTask // 整个 job 已经完成了
+)
+
+func (t TaskType) String() string {
+	switch t {
+	case MapTask:
+		return "Map"
+	case ReduceTask:
+		return "Reduce"
+	case WaitTask:
+		return "Wait"
+	case ExitTask:
+		return "Exit"
+	}
+	return fmt.Sprintf("TaskType(%d)", int(t))
+}
+
+// Task 是 coordinator 分配给 worker 的任务
+type Task struct {
+	Type    TaskType
+	ID      int
+	Input   string   // map 任务的输入
+	NReduce int      // map 任务需要把中间结果分成 NReduce 份
+	MapAddr []string // reduce 任务需要从 MapAddr[i] 上取得第 i 个 map 任务的中间结果
+}
+
+// GetTaskArgs 是 GetTask 的参数
+type GetTaskArgs struct {
+	Worker string // worker 的地址，其他 worker 从这里取得它的 map 任务的中间结果
+}
+
+// ReportArgs 是 Report 的参数
+type ReportArgs struct {
+	Worker string
+	Type   TaskType
+	ID     int
+	Output []KeyValue // reduce 任务的输出
+	// Lost 不为空时，reduce 任务没能完成，因为无法从这些 worker 上取得 map 任务的中间结果
+	Lost []string
+}
+
+// FetchArgs 是 Fetch 的参数
+type FetchArgs struct {
+	Map       int
+	Partition int
+}
+
+// FetchReply 是 Fetch 的返回值
+type FetchReply struct {
+	Pairs []KeyValue
+	OK    bool
+}
+
+// Empty 是不需要返回值的 RPC 的占位符
+type Empty struct{}
+
+// Service 把 Coordinator 和 Worker 的方法以 RPC 的形式暴露出去
+// coordinator 只提供 GetTask 和 Report，worker 只提供 Fetch
+// 方法的签名满足 net/rpc 的要求
+type Service struct {
+	c *Coordinator
+	w *Worker
+}
+
+// GetTask 为 worker 分配一个任务
+func (s *Service) GetTask(args *GetTaskArgs, reply *Task) error {
+	if s.c == nil {
+		return fmt.Errorf("mapreduce: not a coordinator")
+	}
+	*reply = s.c.getTask(args.Worker)
+	return nil
+}
+
+// Report 报告任务的结果
+func (s *Service) Report(args *ReportArgs, reply *Empty) error {
+	if s.c == nil {
+		return fmt.Errorf("mapreduce: not a coordinator")
+	}
+	s.c.report(args)
+	return nil
+}
+
+// Fetch 返回 map 任务的一份中间结果
+func (s *Service) Fetch(args *FetchArgs, reply *FetchReply) error {
+	if s.w == nil {
+		return fmt.Errorf("mapreduce: not a worker")
+	}
+	reply.Pairs, reply.OK = s.w.fetch(args.Map, args.Partition)
+	return nil
+}
@@ -0,0 +1,225 @@
+package mapreduce
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+	transport "github.com/aQuaYi/Distributed-Algorithms/Transport/code"
+)
+
+// Stats 统计了 job 的执行情况
+type Stats struct {
+	Assigned    int // 分配出去的任务数量，包括备份任务和重新执行的任务
+	Backups     int // 因为原来的 worker 太慢而分配的备份任务数量
+	ReExecuted  int // 中间结果丢失以后，重新执行的 map 任务数量
+	LostReduces int // 因为无法取得中间结果而失败的 reduce 任务数量
+}
+
+type taskState int
+
+const (
+	idle taskState = iota
+	running
+	done
+)
+
+// task 是 coordinator 记录的任务状态
+type task struct {
+	state   taskState
+	started time.Time // 最近一次被分配的时间
+	addr    string    // map 任务完成以后，保存中间结果的 worker
+}
+
+// Coordinator 把 job 拆分成 map 任务和 reduce 任务，分配给向它请求任务的 worker
+//
+// 所有的 map 任务完成以后，才会分配 reduce 任务。运行超过 StragglerTimeout 的任务，
+// 会被再分配给另一个 worker，先完成的那个生效。worker 崩溃和 worker 太慢无法区分，
+// 所以这同时处理了这两种情况。map 任务的中间结果保存在执行它的 worker 上，
+// 这个 worker 崩溃时，即使 map 任务已经完成了，也需要重新执行。
+type Coordinator struct {
+	tp        transport.Transport
+	addr      string
+	straggler time.Duration
+	env       sim.Env
+
+	mutex   sync.Mutex
+	inputs  []string
+	nReduce int
+	maps    []task
+	reduces []task
+	output  map[string]string
+	stats   Stats
+	done    chan struct{}
+}
+
+// NewCoordinator 在 tp 的 addr 上启动 coordinator，把 inputs 中的每一份输入作为一个 map 任务，
+// 中间结果分成 nReduce 份。运行超过 straggler 的任务会被分配给其他 worker
+func NewCoordinator(addr string, tp transport.Transport, inputs []string, nReduce int, straggler time.Duration) (*Coordinator, error) {
+	return NewCoordinatorWithEnv(addr, tp, inputs, nReduce, straggler, sim.Env{})
+}
+
+// NewCoordinatorWithEnv 与 NewCoordinator 一样，但是按照 env 的时间判断任务是否运行得太久
+func NewCoordinatorWithEnv(addr string, tp transport.Transport, inputs []string, nReduce int, straggler time.Duration, env sim.Env) (*Coordinator, error) {
+	c := &Coordinator{
+		tp:        tp,
+		straggler: straggler,
+		env:       env,
+		inputs:    inputs,
+		nReduce:   nReduce,
+		maps:      make([]task, len(inputs)),
+		reduces:   make([]task, nReduce),
+		output:    make(map[string]string, 64),
+		done:      make(chan struct{}),
+	}
+	actual, err := tp.Register(addr, &Service{c: c})
+	if err != nil {
+		return nil, err
+	}
+	c.addr = actual
+	return c, nil
+}
+
+// Addr 返回 coordinator 真实的地址
+func (c *Coordinator) Addr() string {
+	return c.addr
+}
+
+// Wait 阻塞到 job 完成或者超时，返回 job 是否已经完成
+func (c *Coordinator) Wait(timeout time.Duration) bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+	}
+	select {
+	case <-c.done:
+		return true
+	case <-c.env.After(timeout):
+		return false
+	}
+}
+
+// Output 返回所有 reduce 任务的输出
+func (c *Coordinator) Output() map[string]string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	res := make(map[string]string, len(c.output))
+	for k, v := range c.output {
+		res[k] = v
+	}
+	return res
+}
+
+// Stats 返回 job 的统计
+func (c *Coordinator) Stats() Stats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.stats
+}
+
+// Close 停止接受 worker 的请求
+func (c *Coordinator) Close() {
+	c.tp.Unregister(c.addr)
+}
+
+func (c *Coordinator) getTask(worker string) Task {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	now := c.env.Now()
+	if id, ok := c.pick(c.maps, now); ok {
+		return Task{Type: MapTask, ID: id, Input: c.inputs[id], NReduce: c.nReduce}
+	}
+	if !allDone(c.maps) {
+		return Task{Type: WaitTask}
+	}
+	if id, ok := c.pick(c.reduces, now); ok {
+		addrs := make([]string, len(c.maps))
+		for i := range c.maps {
+			addrs[i] = c.maps[i].addr
+		}
+		return Task{Type: ReduceTask, ID: id, MapAddr: addrs}
+	}
+	if !allDone(c.reduces) {
+		return Task{Type: WaitTask}
+	}
+	return Task{Type: ExitTask}
+}
+
+// pick 从 tasks 中选出一个可以分配的任务：优先选择空闲的任务，其次是运行太久的任务
+// 利用调用方的锁进行锁定
+func (c *Coordinator) pick(tasks []task, now time.Time) (int, bool) {
+	straggler := -1
+	for i := range tasks {
+		switch t := &tasks[i]; {
+		case t.state == idle:
+			t.state, t.started = running, now
+			c.stats.Assigned++
+			return i, true
+		case t.state == running && straggler == -1 && now.Sub(t.started) > c.straggler:
+			straggler = i
+		}
+	}
+	if straggler == -1 {
+		return 0, false
+	}
+	tasks[straggler].started = now
+	c.stats.Assigned++
+	c.stats.Backups++
+	return straggler, true
+}
+
+func allDone(tasks []task) bool {
+	for _, t := range tasks {
+		if t.state != done {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Coordinator) report(args *ReportArgs) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	switch args.Type {
+	case MapTask:
+		if t := &c.maps[args.ID]; t.state != done {
+			t.state, t.addr = done, args.Worker
+		}
+	case ReduceTask:
+		t := &c.reduces[args.ID]
+		if t.state == done {
+			// 备份任务的重复结果
+			return
+		}
+		if len(args.Lost) > 0 {
+			c.lose(args.Lost)
+			t.state = idle
+			c.stats.LostReduces++
+			return
+		}
+		t.state = done
+		for _, kv := range args.Output {
+			c.output[kv.Key] = kv.Value
+		}
+		if allDone(c.reduces) {
+			close(c.done)
+		}
+	}
+}
+
+// lose 重新执行中间结果保存在 addrs 上的 map 任务
+// 多个 reduce 任务可能报告同一个 worker，已经重新执行过的 map 任务不会受到影响
+// 利用调用方的锁进行锁定
+func (c *Coordinator) lose(addrs []string) {
+	lost := make(map[string]bool, len(addrs))
+	for _, addr := range addrs {
+		lost[addr] = true
+	}
+	for i := range c.maps {
+		if t := &c.maps[i]; t.state == done && lost[t.addr] {
+			t.state, t.addr = idle, ""
+			c.stats.ReExecuted++
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package mapreduce
+
+import (
+	"testing"
+	"time"
+
+	transport "github.com/aQuaYi/Distributed-Algorithms/Transport/code"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Coordinator_schedule(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c, err := NewCoordinator("coordinator", transport.NewSimNetwork(), []string{"a", "b"}, 2, 50*time.Millisecond)
+	ast.Nil(err)
+	//
+	m0, m1 := c.getTask("w0"), c.getTask("w1")
+	ast.Equal(Task{Type: MapTask, ID: 0, Input: "a", NReduce: 2}, m0)
+	ast.Equal(Task{Type: MapTask, ID: 1, Input: "b", NReduce: 2}, m1)
+	ast.Equal(WaitTask, c.getTask("w2").Type, "map 任务都在运行，reduce 任务要等它们完成")
+	// m1 运行得太久，被备份执行，先完成的生效
+	time.Sleep(60 * time.Millisecond)
+	c.report(&ReportArgs{Worker: "w0", Type: MapTask, ID: 0})
+	backup := c.getTask("w2")
+	ast.Equal(1, backup.ID)
+	c.report(&ReportArgs{Worker: "w2", Type: MapTask, ID: 1})
+	c.report(&ReportArgs{Worker: "w1", Type: MapTask, ID: 1})
+	//
+	r0 := c.getTask("w0")
+	ast.Equal(Task{Type: ReduceTask, ID: 0, MapAddr: []string{"w0", "w2"}}, r0)
+	// w2 崩溃了，它的中间结果丢失，map 任务 1 需要重新执行
+	c.report(&ReportArgs{Worker: "w0", Type: ReduceTask, ID: 0, Lost: []string{"w2"}})
+	ast.Equal(Task{Type: MapTask, ID: 1, Input: "b", NReduce: 2}, c.getTask("w1"))
+	ast.Equal(WaitTask, c.getTask("w0").Type)
+	c.report(&ReportArgs{Worker: "w1", Type: MapTask, ID: 1})
+	//
+	for i := 0; i < 2; i++ {
+		r := c.getTask("w0")
+		ast.Equal(ReduceTask, r.Type)
+		ast.Equal([]string{"w0", "w1"}, r.MapAddr)
+		c.report(&ReportArgs{Worker: "w0", Type: ReduceTask, ID: r.ID, Output: []KeyValue{{Key: []string{"x", "y"}[r.ID], Value: "1"}}})
+	}
+	ast.True(c.Wait(0))
+	ast.Equal(ExitTask, c.getTask("w0").Type)
+	ast.Equal(map[string]string{"x": "1", "y": "1"}, c.Output())
+	ast.Equal(Stats{Assigned: 7, Backups: 1, ReExecuted: 1, LostReduces: 1}, c.Stats())
+}
+
+func Test_TaskType_String(t *testing.T) {
+	ast := assert.New(t)
+	//
+	ast.Equal("Map", MapTask.String())
+	ast.Equal("Reduce", ReduceTask.String())
+	ast.Equal("Wait", WaitTask.String())
+	ast.Equal("Exit", ExitTask.String())
+	ast.Equal("TaskType(9)", TaskType(9).String())
+}
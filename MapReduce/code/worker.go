@@ -0,0 +1,178 @@
+package mapreduce
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+	transport "github.com/aQuaYi/Distributed-Algorithms/Transport/code"
+)
+
+// pollInterval 是没有可以分配的任务时，worker 再次请求前等待的时间
+const pollInterval = 10 * time.Millisecond
+
+// Worker 不断地向 coordinator 请求任务并执行
+// map 任务的中间结果按照 ihash(key) % NReduce 分成若干份，保存在 worker 本地，
+// reduce 任务通过 Fetch 从执行 map 任务的 worker 上取得属于自己的那一份，这就是 shuffle。
+type Worker struct {
+	tp          transport.Transport
+	addr        string
+	coordinator string
+	mapf        MapFunc
+	reducef     ReduceFunc
+	env         sim.Env
+
+	mutex        sync.Mutex
+	intermediate map[int][][]KeyValue // map 任务的 ID -> 各个 partition 的中间结果
+	crashed      bool
+	executed     int
+	done         chan struct{}
+}
+
+// StartWorker 在 tp 的 addr 上启动 worker，向 coordinator 请求任务
+func StartWorker(addr, coordinator string, tp transport.Transport, mapf MapFunc, reducef ReduceFunc) (*Worker, error) {
+	return StartWorkerWithEnv(addr, coordinator, tp, mapf, reducef, sim.Env{})
+}
+
+// StartWorkerWithEnv 与 StartWorker 一样，但是没有任务时按照 env 的时间等待
+func StartWorkerWithEnv(addr, coordinator string, tp transport.Transport, mapf MapFunc, reducef ReduceFunc, env sim.Env) (*Worker, error) {
+	w := &Worker{
+		tp:           tp,
+		coordinator:  coordinator,
+		mapf:         mapf,
+		reducef:      reducef,
+		env:          env,
+		intermediate: make(map[int][][]KeyValue, 8),
+		done:         make(chan struct{}),
+	}
+	actual, err := tp.Register(addr, &Service{w: w})
+	if err != nil {
+		return nil, err
+	}
+	w.addr = actual
+	go w.loop()
+	return w, nil
+}
+
+// Addr 返回 worker 真实的地址
+func (w *Worker) Addr() string {
+	return w.addr
+}
+
+// Crash 模拟 w 崩溃：不再请求和报告任务，保存在本地的中间结果也无法再被取得
+func (w *Worker) Crash() {
+	w.mutex.Lock()
+	w.crashed = true
+	w.mutex.Unlock()
+	w.tp.Unregister(w.addr)
+}
+
+// Executed 返回 w 完成的任务数量
+func (w *Worker) Executed() int {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.executed
+}
+
+// Done 在 w 退出以后被关闭
+func (w *Worker) Done() <-chan struct{} {
+	return w.done
+}
+
+func (w *Worker) alive() bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return !w.crashed
+}
+
+func (w *Worker) loop() {
+	defer close(w.done)
+	for {
+		if !w.alive() {
+			return
+		}
+		var t Task
+		if err := w.tp.Call(w.coordinator, "GetTask", &GetTaskArgs{Worker: w.addr}, &t); err != nil {
+			return
+		}
+		if t.Type == ExitTask {
+			return
+		}
+		if t.Type == WaitTask {
+			w.env.Sleep(pollInterval)
+			continue
+		}
+		var report ReportArgs
+		switch t.Type {
+		case MapTask:
+			report = w.doMap(t)
+		case ReduceTask:
+			report = w.doReduce(t)
+		}
+		if !w.alive() {
+			return
+		}
+		if err := w.tp.Call(w.coordinator, "Report", &report, &Empty{}); err != nil {
+			return
+		}
+		w.mutex.Lock()
+		w.executed++
+		w.mutex.Unlock()
+	}
+}
+
+func (w *Worker) doMap(t Task) ReportArgs {
+	partitions := make([][]KeyValue, t.NReduce)
+	for _, kv := range w.mapf(t.Input) {
+		r := ihash(kv.Key) % t.NReduce
+		partitions[r] = append(partitions[r], kv)
+	}
+	w.mutex.Lock()
+	w.intermediate[t.ID] = partitions
+	w.mutex.Unlock()
+	return ReportArgs{Worker: w.addr, Type: MapTask, ID: t.ID}
+}
+
+func (w *Worker) doReduce(t Task) ReportArgs {
+	report := ReportArgs{Worker: w.addr, Type: ReduceTask, ID: t.ID}
+	var pairs []KeyValue
+	lost := make(map[string]bool)
+	for m, addr := range t.MapAddr {
+		var reply FetchReply
+		if err := w.tp.Call(addr, "Fetch", &FetchArgs{Map: m, Partition: t.ID}, &reply); err != nil || !reply.OK {
+			lost[addr] = true
+			continue
+		}
+		pairs = append(pairs, reply.Pairs...)
+	}
+	if len(lost) > 0 {
+		for addr := range lost {
+			report.Lost = append(report.Lost, addr)
+		}
+		sort.Strings(report.Lost)
+		return report
+	}
+	// 按照 key 排序，同一个 key 的所有值交给一次 reduce
+	sort.SliceStable(pairs, func(i, j int) bool { return pairs[i].Key < pairs[j].Key })
+	for i := 0; i < len(pairs); {
+		j := i
+		var values []string
+		for ; j < len(pairs) && pairs[j].Key == pairs[i].Key; j++ {
+			values = append(values, pairs[j].Value)
+		}
+		report.Output = append(report.Output, KeyValue{Key: pairs[i].Key, Value: w.reducef(pairs[i].Key, values)})
+		i = j
+	}
+	return report
+}
+
+func (w *Worker) fetch(m, partition int) ([]KeyValue, bool) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	partitions, ok := w.intermediate[m]
+	if !ok || partition >= len(partitions) {
+		return nil, false
+	}
+	return partitions[partition], true
+}
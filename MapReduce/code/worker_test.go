@@ -0,0 +1,216 @@
+package mapreduce
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	cert "github.com/aQuaYi/Distributed-Algorithms/Cert/code"
+	codec "github.com/aQuaYi/Distributed-Algorithms/Codec/code"
+	transport "github.com/aQuaYi/Distributed-Algorithms/Transport/code"
+	"github.com/stretchr/testify/assert"
+)
+
+func wordCountMap(input string) []KeyValue {
+	words := strings.Fields(input)
+	res := make([]KeyValue, len(words))
+	for i, w := range words {
+		res[i] = KeyValue{Key: w, Value: "1"}
+	}
+	return res
+}
+
+func wordCountReduce(key string, values []string) string {
+	return strconv.Itoa(len(values))
+}
+
+// corpus 返回 n 份输入，以及其中每个单词出现的次数
+func corpus(n int) ([]string, map[string]string) {
+	inputs := make([]string, n)
+	counts := make(map[string]int)
+	for i := range inputs {
+		var words []string
+		for j := 0; j < 50; j++ {
+			w := fmt.Sprintf("w%d", (i*7+j*j)%37)
+			words = append(words, w)
+			counts[w]++
+		}
+		inputs[i] = strings.Join(words, " ")
+	}
+	want := make(map[string]string, len(counts))
+	for w, c := range counts {
+		want[w] = strconv.Itoa(c)
+	}
+	return inputs, want
+}
+
+// startWorkers 在 tp 上启动 n 个执行 word count 的 worker，addr 为空时，使用 "worker-i"
+func startWorkers(ast *assert.Assertions, tp transport.Transport, coordinator, addr string, n int) []*Worker {
+	res := make([]*Worker, n)
+	for i := range res {
+		a := addr
+		if a == "" {
+			a = fmt.Sprintf("worker-%d", i)
+		}
+		w, err := StartWorker(a, coordinator, tp, wordCountMap, wordCountReduce)
+		ast.Nil(err)
+		res[i] = w
+	}
+	return res
+}
+
+// block 返回在 release 关闭之前一直阻塞的 MapFunc 和 ReduceFunc，started 在第一次被调用时关闭
+func block(started, release chan struct{}) (MapFunc, ReduceFunc) {
+	var once sync.Once
+	wait := func() {
+		once.Do(func() { close(started) })
+		<-release
+	}
+	mapf := func(input string) []KeyValue {
+		wait()
+		return wordCountMap(input)
+	}
+	reducef := func(key string, values []string) string {
+		wait()
+		return wordCountReduce(key, values)
+	}
+	return mapf, reducef
+}
+
+func Test_Worker_wordCount(t *testing.T) {
+	ast := assert.New(t)
+	//
+	sn := transport.NewSimNetwork()
+	inputs, want := corpus(20)
+	c, err := NewCoordinator("coordinator", sn, inputs, 5, time.Second)
+	ast.Nil(err)
+	workers := startWorkers(ast, sn, c.Addr(), "", 4)
+	//
+	ast.True(c.Wait(10 * time.Second))
+	ast.Equal(want, c.Output())
+	for _, w := range workers {
+		<-w.Done()
+	}
+	executed := 0
+	for _, w := range workers {
+		executed += w.Executed()
+	}
+	ast.Equal(len(inputs)+5, executed)
+	ast.Equal(Stats{Assigned: len(inputs) + 5}, c.Stats())
+}
+
+func Test_Worker_overTCP(t *testing.T) {
+	ast := assert.New(t)
+	//
+	tp := transport.NewTCPTransport("MapReduce")
+	defer tp.Close()
+	inputs, want := corpus(10)
+	c, err := NewCoordinator("127.0.0.1:0", tp, inputs, 3, time.Second)
+	ast.Nil(err)
+	startWorkers(ast, tp, c.Addr(), "127.0.0.1:0", 3)
+	//
+	ast.True(c.Wait(10 * time.Second))
+	ast.Equal(want, c.Output())
+}
+
+func Test_Worker_overCompressedTCP(t *testing.T) {
+	ast := assert.New(t)
+	//
+	// 只压缩 shuffle 时传输的中间结果
+	var stats codec.Stats
+	tp := transport.NewTCPTransportWithOptions("MapReduce", transport.TCPOptions{
+		Codec: &codec.Policy{
+			Methods: map[string]codec.Codec{"MapReduce.Fetch": codec.Snappy},
+			MinSize: 256,
+			Stats:   &stats,
+		},
+	})
+	defer tp.Close()
+	inputs, want := corpus(10)
+	c, err := NewCoordinator("127.0.0.1:0", tp, inputs, 3, time.Second)
+	ast.Nil(err)
+	startWorkers(ast, tp, c.Addr(), "127.0.0.1:0", 3)
+	//
+	ast.True(c.Wait(10 * time.Second))
+	ast.Equal(want, c.Output())
+	st := stats.Snapshot()
+	ast.True(st.Wire < st.Raw, "%v", st)
+}
+
+func Test_Worker_overMutualTLS(t *testing.T) {
+	ast := assert.New(t)
+	//
+	ca, err := cert.NewCA("mapreduce-ca")
+	ast.Nil(err)
+	tcp := func(name string) *transport.TCPTransport {
+		config, err := ca.Config(name, "127.0.0.1")
+		ast.Nil(err)
+		tp := transport.NewTCPTransportWithOptions("MapReduce", transport.TCPOptions{TLS: config})
+		t.Cleanup(tp.Close)
+		return tp
+	}
+	inputs, want := corpus(10)
+	c, err := NewCoordinator("127.0.0.1:0", tcp("coordinator"), inputs, 3, time.Second)
+	ast.Nil(err)
+	startWorkers(ast, tcp("worker"), c.Addr(), "127.0.0.1:0", 3)
+	//
+	ast.True(c.Wait(10 * time.Second))
+	ast.Equal(want, c.Output())
+	// 没有证书的 worker 领不到任务
+	tp := transport.NewTCPTransportWithOptions("MapReduce", transport.TCPOptions{TLS: &tls.Config{RootCAs: ca.Pool()}})
+	defer tp.Close()
+	ast.NotNil(tp.Call(c.Addr(), "GetTask", &GetTaskArgs{Worker: "intruder"}, &Task{}))
+}
+
+func Test_Worker_straggler(t *testing.T) {
+	ast := assert.New(t)
+	//
+	sn := transport.NewSimNetwork()
+	inputs, want := corpus(10)
+	c, err := NewCoordinator("coordinator", sn, inputs, 3, 100*time.Millisecond)
+	ast.Nil(err)
+	// slow 慢得离谱，它领取的任务会被备份执行
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	mapf, reducef := block(started, release)
+	slow, err := StartWorker("slow", c.Addr(), sn, mapf, reducef)
+	ast.Nil(err)
+	<-started
+	startWorkers(ast, sn, c.Addr(), "", 2)
+	//
+	ast.True(c.Wait(10 * time.Second))
+	ast.Equal(want, c.Output())
+	ast.Equal(1, c.Stats().Backups)
+	ast.Equal(0, slow.Executed())
+}
+
+func Test_Worker_lostIntermediate(t *testing.T) {
+	ast := assert.New(t)
+	//
+	sn := transport.NewSimNetwork()
+	inputs, want := corpus(10)
+	c, err := NewCoordinator("coordinator", sn, inputs, 3, 200*time.Millisecond)
+	ast.Nil(err)
+	// 只有 first 执行 map 任务，所有的中间结果都在它那里
+	// first 在执行第一个 reduce 任务时卡住，然后崩溃了
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	_, reducef := block(started, release)
+	first, err := StartWorker("first", c.Addr(), sn, wordCountMap, reducef)
+	ast.Nil(err)
+	<-started
+	ast.Equal(len(inputs), first.Executed())
+	first.Crash()
+	//
+	startWorkers(ast, sn, c.Addr(), "", 2)
+	ast.True(c.Wait(10 * time.Second))
+	ast.Equal(want, c.Output())
+	st := c.Stats()
+	ast.True(st.LostReduces > 0)
+	ast.True(st.ReExecuted > 0)
+	t.Logf("%+v", st)
+}
@@ -0,0 +1,123 @@
+package merkle
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// Hash 是树中节点的 hash
+type Hash [sha256.Size]byte
+
+func (h Hash) String() string {
+	return fmt.Sprintf("%x", h[:4])
+}
+
+// Tree 是覆盖整个 key 空间的 Merkle 树
+//
+// key 按照 hash 的前 depth 个 bit 被分到 2^depth 个 bucket 中，每个 bucket 是一个叶子，
+// 叶子的 hash 由 bucket 中所有的 key 和 value 决定，内部节点的 hash 由两个孩子决定。
+// 两棵树的某个节点 hash 相同，就可以认为它覆盖的所有 key 都相同，不需要继续比较。
+//
+// 节点按照完全二叉树的方式编号：1 是 root，i 的孩子是 2i 和 2i+1，叶子是 [2^depth, 2^(depth+1))。
+type Tree struct {
+	depth  int
+	hashes []Hash
+	keys   [][]string // 每个 bucket 中排好序的 key
+}
+
+// Build 用 items 中的 key 和 value 建立深度为 depth 的树
+// value 可以是值本身，也可以是值的摘要
+func Build(depth int, items map[string][]byte) *Tree {
+	if depth < 0 || depth > 24 {
+		panic(fmt.Sprintf("merkle: invalid depth %d", depth))
+	}
+	leaves := 1 << uint(depth)
+	t := &Tree{
+		depth:  depth,
+		hashes: make([]Hash, 2*leaves),
+		keys:   make([][]string, leaves),
+	}
+	for key := range items {
+		b := t.Bucket(key)
+		t.keys[b] = append(t.keys[b], key)
+	}
+	var buf bytes.Buffer
+	for b, keys := range t.keys {
+		sort.Strings(keys)
+		buf.Reset()
+		for _, key := range keys {
+			writeBytes(&buf, []byte(key))
+			writeBytes(&buf, items[key])
+		}
+		t.hashes[leaves+b] = sha256.Sum256(buf.Bytes())
+	}
+	for i := leaves - 1; i >= 1; i-- {
+		t.hashes[i] = sha256.Sum256(append(t.hashes[2*i][:], t.hashes[2*i+1][:]...))
+	}
+	return t
+}
+
+// writeBytes 带着长度写入 p，避免不同的 key 和 value 拼接出相同的内容
+func writeBytes(buf *bytes.Buffer, p []byte) {
+	var n [binary.MaxVarintLen64]byte
+	buf.Write(n[:binary.PutUvarint(n[:], uint64(len(p)))])
+	buf.Write(p)
+}
+
+// Depth 返回树的深度
+func (t *Tree) Depth() int {
+	return t.depth
+}
+
+// Root 返回 root 的 hash
+func (t *Tree) Root() Hash {
+	return t.hashes[1]
+}
+
+// Node 返回节点 i 的 hash
+func (t *Tree) Node(i int) Hash {
+	return t.hashes[i]
+}
+
+// Bucket 返回 key 所在的 bucket
+func (t *Tree) Bucket(key string) int {
+	if t.depth == 0 {
+		return 0
+	}
+	h := sha256.Sum256([]byte(key))
+	return int(binary.BigEndian.Uint32(h[:4]) >> uint(32-t.depth))
+}
+
+// Keys 返回 bucket 中的所有 key
+func (t *Tree) Keys(bucket int) []string {
+	return t.keys[bucket]
+}
+
+// Diff 从 root 开始逐层比较 a 和 b，返回 hash 不同的 bucket，以及比较过的节点数量
+// 比较过的节点数量就是一次同步中需要交换的 hash 的数量
+func Diff(a, b *Tree) (buckets []int, compared int) {
+	if a.depth != b.depth {
+		panic("merkle: trees of different depth")
+	}
+	leaves := 1 << uint(a.depth)
+	level := []int{1}
+	for len(level) > 0 {
+		var next []int
+		for _, i := range level {
+			compared++
+			if a.hashes[i] == b.hashes[i] {
+				continue
+			}
+			if i >= leaves {
+				buckets = append(buckets, i-leaves)
+				continue
+			}
+			next = append(next, 2*i, 2*i+1)
+		}
+		level = next
+	}
+	return buckets, compared
+}
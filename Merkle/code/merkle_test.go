@@ -0,0 +1,82 @@
+package merkle
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func items(n int) map[string][]byte {
+	res := make(map[string][]byte, n)
+	for i := 0; i < n; i++ {
+		res[fmt.Sprintf("key%d", i)] = []byte(fmt.Sprintf("value%d", i))
+	}
+	return res
+}
+
+func Test_Build_sameItemsSameRoot(t *testing.T) {
+	ast := assert.New(t)
+	//
+	a, b := Build(6, items(1000)), Build(6, items(1000))
+	ast.Equal(a.Root(), b.Root())
+	buckets, compared := Diff(a, b)
+	ast.Equal(0, len(buckets))
+	ast.Equal(1, compared, "root 相同时只需要比较一次")
+	//
+	keys := 0
+	for i := 0; i < 1<<6; i++ {
+		keys += len(a.Keys(i))
+	}
+	ast.Equal(1000, keys)
+}
+
+func Test_Diff_findsChangedBuckets(t *testing.T) {
+	ast := assert.New(t)
+	//
+	depth := 8
+	base := items(10000)
+	changed := items(10000)
+	changed["key42"] = []byte("new")
+	delete(changed, "key4242")
+	changed["extra"] = []byte("x")
+	a, b := Build(depth, base), Build(depth, changed)
+	ast.NotEqual(a.Root(), b.Root())
+	//
+	buckets, compared := Diff(a, b)
+	want := map[int]bool{a.Bucket("key42"): true, a.Bucket("key4242"): true, a.Bucket("extra"): true}
+	ast.Equal(len(want), len(buckets))
+	for _, bucket := range buckets {
+		ast.True(want[bucket], "%d", bucket)
+	}
+	// 每一层最多只需要比较 2 * 不同的 bucket 数量 个节点
+	ast.True(compared <= 1+2*len(want)*depth, "%d", compared)
+}
+
+func Test_Build_valueBoundaries(t *testing.T) {
+	ast := assert.New(t)
+	//
+	// key 和 value 的边界不同，内容就不同
+	a := Build(0, map[string][]byte{"ab": []byte("c")})
+	b := Build(0, map[string][]byte{"a": []byte("bc")})
+	ast.NotEqual(a.Root(), b.Root())
+	ast.Equal(0, a.Bucket("anything"))
+}
+
+func Test_Diff_differentDepth(t *testing.T) {
+	ast := assert.New(t)
+	//
+	ast.Panics(func() { Diff(Build(2, nil), Build(3, nil)) })
+	ast.Panics(func() { Build(-1, nil) })
+}
+
+func Benchmark_Build(b *testing.B) {
+	for _, n := range []int{1000, 100000} {
+		its := items(n)
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				Build(10, its)
+			}
+		})
+	}
+}
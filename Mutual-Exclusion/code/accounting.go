@@ -0,0 +1,106 @@
+package mutualexclusion
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MessageAccount 按照类型和所服务的申请，统计 message 的数量
+// 每条 message 都归属于它所服务的申请：Lamport 算法中，是 message 中的 timestamp；
+// Raymond 算法中，是令牌申请和令牌被发送时，所代表的队首的申请。
+// 发给 OTHERS 的 message，每个接收方算作一条。
+type MessageAccount struct {
+	Total     int
+	ByType    map[string]int // 按照 message 的类型统计
+	ByRequest map[string]int // 按照 message 所服务的申请统计
+}
+
+func newMessageAccount() *MessageAccount {
+	return &MessageAccount{
+		ByType:    make(map[string]int, 8),
+		ByRequest: make(map[string]int, 64),
+	}
+}
+
+func (a *MessageAccount) add(mt msgType, request string, count int) {
+	a.Total += count
+	a.ByType[mt.String()] += count
+	a.ByRequest[request] += count
+}
+
+// PerEntry 返回 entries 次占用资源时，平均每次需要的 message 数量
+// Raymond 算法中，持有 token 的 process 不需要 message 就可以占用资源，
+// 这样的申请不会出现在 ByRequest 中，所以占用的次数需要另外给出
+func (a *MessageAccount) PerEntry(entries int) float64 {
+	if entries == 0 {
+		return 0
+	}
+	return float64(a.Total) / float64(entries)
+}
+
+// MaxPerRequest 返回单次申请最多用了多少条 message
+func (a *MessageAccount) MaxPerRequest() int {
+	res := 0
+	for _, n := range a.ByRequest {
+		res = max(res, n)
+	}
+	return res
+}
+
+func (a *MessageAccount) String() string {
+	types := make([]string, 0, len(a.ByType))
+	for t, n := range a.ByType {
+		types = append(types, fmt.Sprintf("%s %d", t, n))
+	}
+	sort.Strings(types)
+	return fmt.Sprintf("共 %d 条 message（%s），单次申请最多用了 %d 条",
+		a.Total, strings.Join(types, "，"), a.MaxPerRequest())
+}
+
+// accountingBus 在 bus 之上统计发送的 message
+type accountingBus struct {
+	bus
+	all     int
+	mutex   sync.Mutex
+	account *MessageAccount
+}
+
+func newAccountingBus(all int, b bus) *accountingBus {
+	return &accountingBus{
+		bus:     b,
+		all:     all,
+		account: newMessageAccount(),
+	}
+}
+
+func (b *accountingBus) send(msg *message) {
+	count := 1
+	if msg.to == OTHERS {
+		count = b.all - 1
+	}
+	request := "未归属"
+	if msg.timestamp != nil {
+		request = msg.timestamp.String()
+	}
+	b.mutex.Lock()
+	b.account.add(msg.msgType, request, count)
+	b.mutex.Unlock()
+	b.bus.send(msg)
+}
+
+// Account 返回目前为止的统计结果
+func (b *accountingBus) Account() *MessageAccount {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	res := newMessageAccount()
+	res.Total = b.account.Total
+	for k, v := range b.account.ByType {
+		res.ByType[k] = v
+	}
+	for k, v := range b.account.ByRequest {
+		res.ByRequest[k] = v
+	}
+	return res
+}
@@ -0,0 +1,152 @@
+package mutualexclusion
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aQuaYi/observer"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_MessageAccount(t *testing.T) {
+	ast := assert.New(t)
+	//
+	a := newMessageAccount()
+	a.add(requestResource, "(1,0)", 2)
+	a.add(acknowledgment, "(1,0)", 1)
+	a.add(acknowledgment, "(1,0)", 1)
+	a.add(requestResource, "(2,1)", 2)
+	ast.Equal(6, a.Total)
+	ast.Equal(4, a.ByType[requestResource.String()])
+	ast.Equal(4, a.ByRequest["(1,0)"])
+	ast.Equal(4, a.MaxPerRequest())
+	ast.Equal(3.0, a.PerEntry(2))
+	ast.Equal(0.0, a.PerEntry(0))
+	ast.Contains(a.String(), "共 6 条 message")
+	ast.Contains(a.String(), "单次申请最多用了 4 条")
+}
+
+// runAccounting 让 all 个 process 各申请 times 次，返回所有 message 的统计
+// 资源被释放以后，释放的 message 才会发出，所以最多再等待 want 条 message 一秒钟
+func runAccounting(all, k, times, want int, newProcess func(me int, r Resource, b bus) Process) *MessageAccount {
+	s := newSemaphore(k, all*times)
+	b := newAccountingBus(all, newObserverBus(observer.NewProperty(nil)))
+	ps := make([]Process, all)
+	for i := range ps {
+		ps[i] = newProcess(i, s, b)
+	}
+	for _, p := range ps {
+		go func(p Process) {
+			for i := 0; i < times; i++ {
+				p.Request()
+			}
+		}(p)
+	}
+	s.wait()
+	deadline := time.Now().Add(time.Second)
+	for b.Account().Total < want && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	return b.Account()
+}
+
+func Test_accountingBus(t *testing.T) {
+	ast := assert.New(t)
+	//
+	all, times := 5, 20
+	want := 3 * (all - 1) * all * times
+	lamport := runAccounting(all, 1, times, want, func(me int, r Resource, b bus) Process {
+		return newProcessWithBus(all, me, r, b)
+	})
+	ast.Equal(want, lamport.Total)
+	ast.Len(lamport.ByRequest, all*times)
+	ast.Equal(3*(all-1), lamport.MaxPerRequest())
+	for req, n := range lamport.ByRequest {
+		ast.Equal(3*(all-1), n, req)
+	}
+	ast.Equal(float64(3*(all-1)), lamport.PerEntry(all*times))
+	// 同时允许 k 个 process 占用资源，并不会减少 message
+	kProcess := runAccounting(all, 2, times, want, func(me int, r Resource, b bus) Process {
+		return newKProcessWithBus(all, 2, me, r, b)
+	})
+	ast.Equal(want, kProcess.Total)
+	for req, n := range kProcess.ByRequest {
+		ast.Equal(3*(all-1), n, req)
+	}
+}
+
+func Test_accountingBus_suppressAcks(t *testing.T) {
+	ast := assert.New(t)
+	//
+	all, times := 5, 20
+	// 申请和释放各 N-1 条是省不掉的
+	atLeast := 2 * (all - 1) * all * times
+	a := runAccounting(all, 1, times, atLeast, func(me int, r Resource, b bus) Process {
+		return newProcessSuppressingAcks(all, me, r, b)
+	})
+	ast.True(a.Total >= atLeast)
+	ast.True(a.Total <= 3*(all-1)*all*times)
+	ast.Equal(a.Total-atLeast, a.ByType[acknowledgment.String()])
+}
+
+func benchmarkAcks(b *testing.B, newProcess func(all, me int, r Resource, b bus) Process) {
+	all := 16
+	times := b.N/all + 1
+	b.ResetTimer()
+	a := runAccounting(all, 1, times, 0, func(me int, r Resource, b bus) Process {
+		return newProcess(all, me, r, b)
+	})
+	b.StopTimer()
+	b.ReportMetric(a.PerEntry(all*times), "msgs/entry")
+}
+
+// Benchmark_acks 比较省略回复前后，每次占用资源需要的 message 数量
+func Benchmark_acks(b *testing.B) {
+	b.Run("ack", func(b *testing.B) {
+		benchmarkAcks(b, newProcessWithBus)
+	})
+	b.Run("suppress", func(b *testing.B) {
+		benchmarkAcks(b, newProcessSuppressingAcks)
+	})
+}
+
+func Test_accountingBus_raymond(t *testing.T) {
+	ast := assert.New(t)
+	//
+	all, times := 7, 20
+	tree := binaryTree(all)
+	a := runAccounting(all, 1, times, 0, func(me int, r Resource, b bus) Process {
+		return newRaymondProcess(tree, me, r, b)
+	})
+	// 7 个节点的平衡二叉树，直径是 4
+	ast.True(a.PerEntry(all*times) <= 2*4)
+	ast.True(a.MaxPerRequest() > 0)
+	ast.NotContains(a.ByRequest, "未归属")
+	ast.Equal(a.Total, a.ByType[tokenRequest.String()]+a.ByType[tokenPrivilege.String()])
+}
+
+func Test_SimulationResult_Account(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := ModelConfig{Processes: 4, Requests: 5}
+	res := Simulate(c, 1)
+	ast.Equal(res.Messages, res.Account.Total)
+	ast.Len(res.Account.ByRequest, 4*5)
+	for req, n := range res.Account.ByRequest {
+		ast.Equal(3*3, n, req)
+	}
+	// 省略回复以后，每次申请最少只需要 2(N-1) 条 message
+	c.SuppressAcks = true
+	res = Simulate(c, 1)
+	ast.Len(res.Account.ByRequest, 4*5)
+	for req, n := range res.Account.ByRequest {
+		ast.True(n >= 2*3 && n <= 3*3, req)
+	}
+	c.SuppressAcks = false
+	//
+	c.Processes = 7
+	res = SimulateRaymond(c, 1)
+	ast.Equal(res.Messages, res.Account.Total)
+	ast.True(res.Account.PerEntry(len(res.Grants)) <= 2*4)
+	ast.NotContains(res.Account.ByRequest, "未归属")
+}
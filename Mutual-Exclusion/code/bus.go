@@ -0,0 +1,88 @@
+package mutualexclusion
+
+import (
+	reliablechannel "github.com/aQuaYi/Distributed-Algorithms/Reliable-Channel/code"
+	"github.com/aQuaYi/observer"
+)
+
+// bus 是 process 之间收发 message 的通道
+type bus interface {
+	// send 把 msg 发送给 msg.to，msg.to 为 OTHERS 时，发送给其他所有 process
+	send(msg *message)
+	// receiver 返回 me 接收 message 的函数
+	// 接收函数会阻塞到收到下一条 message，通道关闭时返回 false
+	receiver(me int) func() (*message, bool)
+}
+
+// recycler 是可以回收 message 的 bus
+// process 处理完收到的 message 以后，调用 recycle 交还给 bus
+type recycler interface {
+	recycle(msg *message)
+}
+
+// recycleFunc 返回 b 回收 message 的函数，b 不能回收时，返回什么也不做的函数
+func recycleFunc(b bus) func(*message) {
+	if r, ok := b.(recycler); ok {
+		return r.recycle
+	}
+	return func(*message) {}
+}
+
+// observerBus 利用 observer.Property 广播 message
+// 所有的 process 看到的是同一个全局有序的 message 序列，
+// 所以 Lamport 算法要求的可靠 FIFO 通道是被“免费”满足的
+type observerBus struct {
+	prop observer.Property
+}
+
+func newObserverBus(prop observer.Property) bus {
+	return &observerBus{prop: prop}
+}
+
+func (b *observerBus) send(msg *message) {
+	b.prop.Update(msg)
+}
+
+func (b *observerBus) receiver(me int) func() (*message, bool) {
+	// stream 的观察起点位置，由调用 receiver 的时机决定
+	stream := b.prop.Observe()
+	return func() (*message, bool) {
+		return stream.Next().(*message), true
+	}
+}
+
+// reliableBus 在不可靠的网络上，利用 reliablechannel.Endpoint 收发 message
+// 每一对 process 之间的 message 都满足先发送先到达
+type reliableBus struct {
+	all int
+	ep  *reliablechannel.Endpoint
+}
+
+func newReliableBus(all int, ep *reliablechannel.Endpoint) bus {
+	return &reliableBus{
+		all: all,
+		ep:  ep,
+	}
+}
+
+func (b *reliableBus) send(msg *message) {
+	if msg.to != OTHERS {
+		b.ep.Send(msg.to, msg)
+		return
+	}
+	for i := 0; i < b.all; i++ {
+		if i != msg.from {
+			b.ep.Send(i, msg)
+		}
+	}
+}
+
+func (b *reliableBus) receiver(me int) func() (*message, bool) {
+	return func() (*message, bool) {
+		d, ok := b.ep.Receive()
+		if !ok {
+			return nil, false
+		}
+		return d.Payload.(*message), true
+	}
+}
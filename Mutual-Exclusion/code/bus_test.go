@@ -0,0 +1,81 @@
+package mutualexclusion
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	reliablechannel "github.com/aQuaYi/Distributed-Algorithms/Reliable-Channel/code"
+	"github.com/aQuaYi/observer"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_observerBus(t *testing.T) {
+	ast := assert.New(t)
+	//
+	b := newObserverBus(observer.NewProperty(nil))
+	next := b.receiver(0)
+	msg := newMessage(requestResource, 1, 1, OTHERS, newTimestamp(1, 1))
+	b.send(msg)
+	//
+	actual, ok := next()
+	ast.True(ok)
+	ast.Equal(msg, actual)
+}
+
+func Test_reliableBus_sendToOthers(t *testing.T) {
+	ast := assert.New(t)
+	//
+	all := 3
+	net := reliablechannel.NewLossyNetwork(0, 0, 0)
+	buses := make([]bus, all)
+	for i := range buses {
+		ep := reliablechannel.NewEndpoint(i, net, 10*time.Millisecond)
+		defer ep.Close()
+		buses[i] = newReliableBus(all, ep)
+	}
+	//
+	msg := newMessage(requestResource, 1, 0, OTHERS, newTimestamp(1, 0))
+	buses[0].send(msg)
+	for i := 1; i < all; i++ {
+		actual, ok := buses[i].receiver(i)()
+		ast.True(ok)
+		ast.Equal(msg, actual)
+	}
+}
+
+func runOverLossyNetwork(all, occupyTimesPerProcess int, lossRate float64) {
+	rsc := newResource(all * occupyTimesPerProcess)
+
+	net := reliablechannel.NewLossyNetwork(lossRate, lossRate, time.Millisecond)
+
+	ps := make([]Process, all)
+	for i := range ps {
+		ep := reliablechannel.NewEndpoint(i, net, 5*time.Millisecond)
+		defer ep.Close()
+		ps[i] = newProcessWithBus(all, i, rsc, newReliableBus(all, ep))
+	}
+
+	for _, p := range ps {
+		go func(p Process, times int) {
+			for i := 0; i < times; i++ {
+				p.Request()
+			}
+		}(p, occupyTimesPerProcess)
+	}
+
+	rsc.wait()
+}
+
+func Test_process_overLossyNetwork(t *testing.T) {
+	ast := assert.New(t)
+	//
+	for _, lossRate := range []float64{0, 0.1, 0.3} {
+		name := fmt.Sprintf("丢包率 %.1f", lossRate)
+		t.Run(name, func(t *testing.T) {
+			ast.NotPanics(func() {
+				runOverLossyNetwork(4, 20, lossRate)
+			})
+		})
+	}
+}
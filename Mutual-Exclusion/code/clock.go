@@ -1,8 +1,9 @@
 package mutualexclusion
 
 import (
-	"math/rand"
 	"sync"
+
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
 )
 
 // Clock 是系统的逻辑时钟接口
@@ -20,10 +21,10 @@ type clock struct {
 	rwmu sync.RWMutex
 }
 
-// 每个 process 的 clock 的 initial time，都是随机的
-func newClock() Clock {
+// 每个 process 的 clock 的 initial time，都是随机的，随机数来自 env
+func newClock(env sim.Env) Clock {
 	return &clock{
-		time: 1 + rand.Intn(100),
+		time: 1 + env.Intn(100),
 	}
 }
 
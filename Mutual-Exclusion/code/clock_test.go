@@ -3,13 +3,14 @@ package mutualexclusion
 import (
 	"testing"
 
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
 	"github.com/stretchr/testify/assert"
 )
 
 func Test_clock_update(t *testing.T) {
 	ast := assert.New(t)
 	//
-	c := newClock()
+	c := newClock(sim.Env{})
 	newTime := 1000
 	ast.True(newTime+1 >= c.Now())
 	//
@@ -23,8 +24,20 @@ func Test_clock_update(t *testing.T) {
 func Test_clock_tick(t *testing.T) {
 	ast := assert.New(t)
 	//
-	c := newClock()
+	c := newClock(sim.Env{})
 	expected := c.Now() + 1
 	actual := c.Tick()
 	ast.Equal(expected, actual)
 }
+
+func Test_newClock_env(t *testing.T) {
+	ast := assert.New(t)
+	//
+	// 同样的 seed 得到同样的 initial time
+	for seed := int64(0); seed < 10; seed++ {
+		a := newClock(sim.Deterministic(nil, seed))
+		b := newClock(sim.Deterministic(nil, seed))
+		ast.Equal(a.Now(), b.Now())
+		ast.True(1 <= a.Now() && a.Now() <= 100)
+	}
+}
@@ -0,0 +1,145 @@
+package mutualexclusion
+
+import (
+	"fmt"
+	"time"
+
+	core "github.com/aQuaYi/Distributed-Algorithms/Core/code"
+)
+
+func init() {
+	params := map[string]string{
+		"requests": "每个 process 申请资源的次数，默认为 3",
+		"hold":     "每次占用资源的时长，默认为 10ms",
+	}
+	core.Register(core.Algorithm{
+		Name:    "lamport",
+		Kind:    "mutex",
+		Summary: "Lamport 的 mutual exclusion 算法，申请广播给所有的 process",
+		Params:  params,
+		New: newCoreMutex(func(id, n int) scaleNode {
+			return &scaleLamport{me: id}
+		}),
+		Invariant: atMostOneOccupier,
+		Check:     allGranted,
+	})
+	core.Register(core.Algorithm{
+		Name:    "raymond",
+		Kind:    "mutex",
+		Summary: "Raymond 的 mutual exclusion 算法，token 沿着二叉树传递",
+		Params:  params,
+		New: newCoreMutex(func(id, n int) scaleNode {
+			holder := binaryTree(n)[id]
+			if holder < 0 {
+				holder = id
+			}
+			return &scaleRaymond{me: id, holder: holder}
+		}),
+		Invariant: atMostOneOccupier,
+		Check:     allGranted,
+	})
+}
+
+func (e scaleEvent) String() string {
+	return fmt.Sprintf("{Time:%d, %s}", e.msgTime, &e.ts)
+}
+
+// coreMutex 把 SimulateScale 中的 scaleNode 接到 core 上
+// 每个 process 申请 requests 次资源，每次占用 hold 以后释放，然后立刻再次申请。
+type coreMutex struct {
+	node      scaleNode
+	ctx       *core.Context
+	requests  int
+	remaining int
+	hold      time.Duration
+	occupying bool
+	grants    int
+	requested time.Time
+}
+
+func newCoreMutex(newNode func(id, n int) scaleNode) func(id, n int, params core.Params) core.Node {
+	return func(id, n int, params core.Params) core.Node {
+		requests := params.Int("requests", 3)
+		return &coreMutex{
+			node:      newNode(id, n),
+			requests:  requests,
+			remaining: requests,
+			hold:      params.Duration("hold", 10*time.Millisecond),
+		}
+	}
+}
+
+func (m *coreMutex) Start(ctx *core.Context) {
+	m.ctx = ctx
+	m.request()
+}
+
+func (m *coreMutex) Handle(ctx *core.Context, msg core.Message) {
+	e := msg.Payload.(scaleEvent)
+	e.from = msg.From
+	m.step(e)
+}
+
+func (m *coreMutex) request() {
+	if m.remaining == 0 {
+		return
+	}
+	m.remaining--
+	m.requested = m.ctx.Now()
+	m.ctx.Tracef("申请资源")
+	m.step(scaleEvent{local: true, msgType: requestResource})
+}
+
+// step 让 node 处理 e，开始占用资源时，在 hold 以后释放
+func (m *coreMutex) step(e scaleEvent) {
+	if !m.node.handle(e, m) {
+		return
+	}
+	m.occupying = true
+	m.grants++
+	m.ctx.Count("grants", 1)
+	m.ctx.Count("wait.ms", int(m.ctx.Clock().Since(m.requested)/time.Millisecond))
+	m.ctx.Tracef("占用资源")
+	m.ctx.AfterFunc(m.hold, func() {
+		m.occupying = false
+		m.ctx.Tracef("释放资源")
+		m.step(scaleEvent{local: true, msgType: releaseResource})
+		m.request()
+	})
+}
+
+// 以下实现了 scaleNet 接口
+
+func (m *coreMutex) size() int {
+	return m.ctx.Size()
+}
+
+func (m *coreMutex) send(from, to int, e scaleEvent) {
+	m.ctx.Send(to, e.msgType.String(), e)
+}
+
+func (m *coreMutex) broadcast(from int, e scaleEvent) {
+	m.ctx.Broadcast(e.msgType.String(), e)
+}
+
+func atMostOneOccupier(nodes []core.Node) error {
+	count := 0
+	for _, n := range nodes {
+		if n.(*coreMutex).occupying {
+			count++
+		}
+	}
+	if count > 1 {
+		return fmt.Errorf("AlwaysAtMostOneOccupier: %d 个 process 同时占用资源", count)
+	}
+	return nil
+}
+
+func allGranted(nodes []core.Node, _ *core.Metrics) error {
+	for id, n := range nodes {
+		if m := n.(*coreMutex); m.grants < m.requests {
+			return fmt.Errorf("deadlock: P%d 的 %d 次申请中，只有 %d 次被满足", id, m.requests, m.grants)
+		}
+	}
+	return nil
+}
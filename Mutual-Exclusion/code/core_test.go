@@ -0,0 +1,54 @@
+package mutualexclusion
+
+import (
+	"testing"
+	"time"
+
+	core "github.com/aQuaYi/Distributed-Algorithms/Core/code"
+	reliablechannel "github.com/aQuaYi/Distributed-Algorithms/Reliable-Channel/code"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_core_mutex(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := core.Config{
+		Nodes:   5,
+		Params:  core.Params{"requests": "3", "hold": "5ms"},
+		Latency: reliablechannel.Uniform{Min: time.Millisecond, Max: 10 * time.Millisecond},
+	}
+	for _, name := range []string{"lamport", "raymond"} {
+		for seed := int64(0); seed < 10; seed++ {
+			c.Seed = seed
+			res, err := core.Run(name, c)
+			ast.Nil(err)
+			ast.True(res.OK(), "%s seed %d: %s", name, seed, res.Violation)
+			ast.Equal(15, res.Metrics.Get("grants"))
+		}
+	}
+}
+
+func Test_core_lamportMessages(t *testing.T) {
+	ast := assert.New(t)
+	//
+	res, _ := core.Run("lamport", core.Config{Nodes: 4, Params: core.Params{"requests": "2"}})
+	ast.True(res.OK())
+	// 每次申请都有 3 条 request、3 条 acknowledgment 和 3 条 release
+	grants := 4 * 2
+	ast.Equal(grants*3*3, res.Metrics.Get("messages"))
+	ast.Equal(grants*3, res.Metrics.Get("messages."+requestResource.String()))
+}
+
+func Test_core_deterministic(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := core.Config{
+		Nodes:   4,
+		Seed:    7,
+		Latency: reliablechannel.Uniform{Max: 10 * time.Millisecond},
+	}
+	a, _ := core.Run("raymond", c)
+	b, _ := core.Run("raymond", c)
+	ast.Equal(a.Trace, b.Trace)
+	ast.Equal(a.Elapsed, b.Elapsed)
+}
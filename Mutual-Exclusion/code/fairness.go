@@ -0,0 +1,225 @@
+package mutualexclusion
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+)
+
+// EventKind 是 Event 的类型
+type EventKind int
+
+// 枚举了 Event 的所有类型
+const (
+	Requested EventKind = iota // process 申请资源
+	Granted                    // process 占用资源
+)
+
+func (k EventKind) String() string {
+	if k == Requested {
+		return "申请"
+	}
+	return "占用"
+}
+
+// Event 是运行 trace 中与公平性有关的一个事件
+type Event struct {
+	Kind    EventKind
+	Process int
+	// At 是事件发生的时刻，模拟运行中是步数，真实运行中是从开始运行经过的纳秒数
+	At int64
+}
+
+// ProcessFairness 是一个 process 的统计数据，等待时间的单位与 Event.At 相同
+type ProcessFairness struct {
+	Process  int
+	Requests int // 申请的次数
+	Grants   int // 占用的次数
+	MinWait  int64
+	MeanWait float64
+	MaxWait  int64
+	Pending  int64 // 运行结束时，还没有被满足的申请已经等待的时间，-1 表示没有
+	Starved  bool
+}
+
+// FairnessReport 是 AnalyzeFairness 的结果
+type FairnessReport struct {
+	Processes []ProcessFairness
+	// GrantIndex 是各个 process 占用次数的 Jain 公平指数，范围是 [1/n, 1]，1 表示完全公平
+	GrantIndex float64
+	// WaitIndex 是各个 process 平均等待时间的 Jain 公平指数
+	WaitIndex float64
+	// Threshold 是判断饥饿的等待时间
+	Threshold int64
+	// Starved 是被判断为饥饿的 process
+	Starved []int
+}
+
+// jain 返回 xs 的 Jain 公平指数 (Σx)² / (n·Σx²)
+// xs 全为 0 时，认为是完全公平的
+func jain(xs []float64) float64 {
+	var sum, squares float64
+	for _, x := range xs {
+		sum += x
+		squares += x * x
+	}
+	if squares == 0 {
+		return 1
+	}
+	return sum * sum / (float64(len(xs)) * squares)
+}
+
+// AnalyzeFairness 统计 events 中 processes 个 process 的公平性
+// 每个 process 的第 k 次占用，对应它的第 k 次申请。
+// 某次等待，或者运行结束时还没有被满足的申请已经等待的时间，超过 threshold 的 process 被判断为饥饿。
+// threshold 为 0 时，使用所有等待时间的平均值的 10 倍。
+func AnalyzeFairness(processes int, events []Event, threshold int64) *FairnessReport {
+	res := &FairnessReport{Processes: make([]ProcessFairness, processes)}
+	requestedAt := make([][]int64, processes)
+	var end, total int64
+	count := 0
+	for i := range res.Processes {
+		res.Processes[i] = ProcessFairness{Process: i, Pending: -1}
+	}
+	for _, e := range events {
+		if e.Process < 0 || e.Process >= processes {
+			continue
+		}
+		p := &res.Processes[e.Process]
+		end = max64(end, e.At)
+		if e.Kind == Requested {
+			p.Requests++
+			requestedAt[e.Process] = append(requestedAt[e.Process], e.At)
+			continue
+		}
+		if p.Grants >= len(requestedAt[e.Process]) {
+			// 没有对应的申请
+			continue
+		}
+		wait := e.At - requestedAt[e.Process][p.Grants]
+		if p.Grants == 0 || wait < p.MinWait {
+			p.MinWait = wait
+		}
+		p.MaxWait = max64(p.MaxWait, wait)
+		p.MeanWait += float64(wait)
+		p.Grants++
+		total += wait
+		count++
+	}
+	if threshold == 0 && count > 0 {
+		threshold = 10 * total / int64(count)
+	}
+	res.Threshold = threshold
+	grants := make([]float64, processes)
+	waits := make([]float64, processes)
+	for i := range res.Processes {
+		p := &res.Processes[i]
+		if p.Grants > 0 {
+			p.MeanWait /= float64(p.Grants)
+		}
+		if p.Grants < len(requestedAt[i]) {
+			p.Pending = end - requestedAt[i][p.Grants]
+		}
+		p.Starved = p.MaxWait > threshold || p.Pending > threshold
+		if p.Starved {
+			res.Starved = append(res.Starved, i)
+		}
+		grants[i] = float64(p.Grants)
+		waits[i] = p.MeanWait
+	}
+	res.GrantIndex = jain(grants)
+	res.WaitIndex = jain(waits)
+	return res
+}
+
+func max64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func (r *FairnessReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "占用次数的 Jain 指数 %.3f，平均等待的 Jain 指数 %.3f\n", r.GrantIndex, r.WaitIndex)
+	for _, p := range r.Processes {
+		fmt.Fprintf(&b, "P%d: 申请 %d 次，占用 %d 次，等待 min %d, mean %.1f, max %d", p.Process, p.Requests, p.Grants, p.MinWait, p.MeanWait, p.MaxWait)
+		if p.Pending >= 0 {
+			fmt.Fprintf(&b, "，还有申请已经等待了 %d", p.Pending)
+		}
+		if p.Starved {
+			b.WriteString("，饥饿")
+		}
+		b.WriteString("\n")
+	}
+	if len(r.Starved) == 0 {
+		fmt.Fprintf(&b, "没有 process 的等待超过 %d", r.Threshold)
+	} else {
+		fmt.Fprintf(&b, "饥饿的 process：%v，等待超过了 %d", r.Starved, r.Threshold)
+	}
+	return b.String()
+}
+
+// recorder 记录 goroutine 实现的 process 在真实运行中的 Event
+// 用 process 包装 Process，用 resource 包装 Resource，就可以得到与模拟运行一样的 trace
+type recorder struct {
+	mutex  sync.Mutex
+	env    sim.Env // Event 时间的来源
+	start  time.Time
+	events []Event
+}
+
+func newRecorder(env sim.Env) *recorder {
+	return &recorder{env: env, start: env.Now()}
+}
+
+func (r *recorder) record(kind EventKind, process int) {
+	r.mutex.Lock()
+	r.events = append(r.events, Event{Kind: kind, Process: process, At: int64(r.env.Since(r.start))})
+	r.mutex.Unlock()
+}
+
+// Events 返回按时间排序的 Event
+func (r *recorder) Events() []Event {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	res := append([]Event(nil), r.events...)
+	sort.SliceStable(res, func(i, j int) bool { return res[i].At < res[j].At })
+	return res
+}
+
+type recordedProcess struct {
+	Process
+	me int
+	r  *recorder
+}
+
+// Request 在申请之前记录 Requested
+// 上一次的占用还没有结束时，Request 会阻塞，这段时间也被算作等待
+func (p *recordedProcess) Request() {
+	p.r.record(Requested, p.me)
+	p.Process.Request()
+}
+
+func (r *recorder) process(me int, p Process) Process {
+	return &recordedProcess{Process: p, me: me, r: r}
+}
+
+type recordedResource struct {
+	Resource
+	r *recorder
+}
+
+// Occupy 记录 Granted，timestamp 中的 process 就是占用者
+func (rr *recordedResource) Occupy(ts Timestamp) {
+	rr.r.record(Granted, ts.(*timestamp).process)
+	rr.Resource.Occupy(ts)
+}
+
+func (r *recorder) resource(rsc Resource) Resource {
+	return &recordedResource{Resource: rsc, r: r}
+}
@@ -0,0 +1,116 @@
+package mutualexclusion
+
+import (
+	"testing"
+
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+	"github.com/aQuaYi/observer"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_jain(t *testing.T) {
+	ast := assert.New(t)
+	//
+	ast.Equal(1.0, jain([]float64{3, 3, 3}))
+	ast.Equal(0.25, jain([]float64{1, 0, 0, 0}))
+	ast.Equal(1.0, jain([]float64{0, 0}))
+	ast.InDelta(0.9, jain([]float64{1, 2}), 0.001)
+}
+
+func Test_AnalyzeFairness(t *testing.T) {
+	ast := assert.New(t)
+	//
+	events := []Event{
+		{Requested, 0, 0},
+		{Requested, 1, 1},
+		{Granted, 0, 2},
+		{Requested, 0, 3},
+		{Granted, 1, 10},
+		{Granted, 0, 12},
+		{Requested, 1, 13},
+		{Granted, 2, 14},   // 没有对应的申请
+		{Requested, 5, 15}, // 不存在的 process
+		{Requested, 0, 40},
+		{Granted, 0, 41},
+	}
+	r := AnalyzeFairness(3, events, 20)
+	p0, p1, p2 := r.Processes[0], r.Processes[1], r.Processes[2]
+	ast.Equal(3, p0.Requests)
+	ast.Equal(3, p0.Grants)
+	ast.Equal(int64(1), p0.MinWait)
+	ast.Equal(int64(9), p0.MaxWait)
+	ast.InDelta(4.0, p0.MeanWait, 0.001)
+	ast.Equal(int64(-1), p0.Pending)
+	ast.False(p0.Starved)
+	//
+	ast.Equal(2, p1.Requests)
+	ast.Equal(1, p1.Grants)
+	ast.Equal(int64(9), p1.MaxWait)
+	ast.Equal(int64(41-13), p1.Pending)
+	ast.True(p1.Starved)
+	//
+	ast.Equal(0, p2.Grants)
+	ast.Equal([]int{1}, r.Starved)
+	ast.InDelta(jain([]float64{3, 1, 0}), r.GrantIndex, 0.001)
+	ast.Contains(r.String(), "饥饿的 process：[1]")
+	// threshold 为 0 时，使用平均等待时间的 10 倍
+	ast.Equal(int64(10*(12+9)/4), AnalyzeFairness(3, events, 0).Threshold)
+}
+
+func Test_SimulationResult_Fairness(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := ModelConfig{Processes: 4, Requests: 5}
+	for _, res := range []*SimulationResult{Simulate(c, 1), SimulateRaymond(c, 1)} {
+		r := res.Fairness()
+		ast.Equal(1.0, r.GrantIndex)
+		ast.Empty(r.Starved)
+		ast.Len(res.Events, 2*20)
+		for i, p := range r.Processes {
+			ast.Equal(5, p.Grants)
+			ast.Equal(int64(-1), p.Pending)
+			ast.Equal(i, p.Process)
+		}
+		ast.Contains(r.String(), "没有 process 的等待超过")
+	}
+	// 乱序送达时，Lamport 算法中有的申请永远不会被满足
+	res := Simulate(ModelConfig{Processes: 3, Requests: 3, NonFIFO: true}, 3)
+	ast.Contains(res.Violation, "EventuallyGranted(P1#3)")
+	ast.True(res.Fairness().Processes[1].Pending >= 0)
+}
+
+func Test_recorder(t *testing.T) {
+	ast := assert.New(t)
+	//
+	all, times := 4, 50
+	for _, name := range []string{"lamport", "raymond"} {
+		rec := newRecorder(sim.Env{})
+		s := newSemaphore(1, all*times)
+		rsc := rec.resource(s)
+		prop := observer.NewProperty(nil)
+		tree := binaryTree(all)
+		ps := make([]Process, all)
+		for i := range ps {
+			if name == "lamport" {
+				ps[i] = rec.process(i, newProcess(all, i, rsc, prop))
+			} else {
+				ps[i] = rec.process(i, newRaymondProcess(tree, i, rsc, newObserverBus(prop)))
+			}
+		}
+		for _, p := range ps {
+			go func(p Process) {
+				for i := 0; i < times; i++ {
+					p.Request()
+				}
+			}(p)
+		}
+		s.wait()
+		//
+		r := AnalyzeFairness(all, rec.Events(), 0)
+		ast.Equal(1.0, r.GrantIndex, name)
+		for _, p := range r.Processes {
+			ast.Equal(times, p.Requests, name)
+			ast.Equal(times, p.Grants, name)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package mutualexclusion
+
+import (
+	"math/rand"
+
+	spec "github.com/aQuaYi/Distributed-Algorithms/Spec/code"
+)
+
+// runSchedule 按照 choices 运行 c 中的系统
+// 返回违反的性质，以及实际执行的步骤，没有违反性质时，返回的 violation 为空
+func runSchedule(c ModelConfig, choices []int) (violation string, steps []string) {
+	r := simulate(c, choices)
+	return r.Violation, r.Steps
+}
+
+// shrink 用 spec.Shrink 缩短导致 violation 的 choices，返回仍然会导致 violation 的、尽可能短的 choices
+func shrink(c ModelConfig, choices []int, violation string) []int {
+	return spec.Shrink(choices, func(cs []int) bool {
+		v, _ := runSchedule(c, cs)
+		return v == violation
+	})
+}
+
+// Fuzz 用 seed 生成 runs 个随机的调度，在 c 中的系统上运行
+// 与 ModelCheck 的穷举不同，Fuzz 可以用于更大的规模，但是不能证明没有错误，
+// 所以 ModelResult.Complete 总是 false，ModelResult.States 是全部调度经过的状态数量之和。
+// 发现错误时，会把导致错误的调度缩短以后，放在 ModelResult.Schedule 中
+func Fuzz(c ModelConfig, seed int64, runs int) *ModelResult {
+	rnd := rand.New(rand.NewSource(seed))
+	res := &ModelResult{}
+	for r := 0; r < runs; r++ {
+		choices := randomChoices(c, rnd)
+		violation, steps := runSchedule(c, choices)
+		res.States += len(steps) + 1
+		if violation == "" {
+			continue
+		}
+		res.Violation = violation
+		_, res.Schedule = runSchedule(c, shrink(c, choices, violation))
+		return res
+	}
+	return res
+}
@@ -0,0 +1,228 @@
+package mutualexclusion
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+	spec "github.com/aQuaYi/Distributed-Algorithms/Spec/code"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordBus 只记录发送的 message，用于单独测试一个 process
+type recordBus struct {
+	sent []*message
+}
+
+func (b *recordBus) send(msg *message) {
+	b.sent = append(b.sent, msg)
+}
+
+func (b *recordBus) receiver(me int) func() (*message, bool) {
+	return func() (*message, bool) { return nil, false }
+}
+
+// decodeMessages 把 data 每 5 个字节解码成一条 message
+// 解码的结果可以是任意的 message，包括乱序的、不合法的和重复的
+func decodeMessages(data []byte) []*message {
+	var res []*message
+	for ; len(data) >= 5; data = data[5:] {
+		mt := msgType(data[0] % 4) // 3 是 Lamport 算法不认识的类型
+		from := int(data[1]%6) - 1 // -1 和 4 是不存在的 process
+		to := int(data[2]%6) - 1   // -1 是 OTHERS
+		msgTime := int(data[3])
+		var ts Timestamp
+		switch data[4] % 4 {
+		case 0: // 没有 timestamp
+		case 1: // timestamp 属于别的 process
+			ts = newTimestamp(msgTime, (from+1)%4)
+		default:
+			ts = newTimestamp(msgTime, from)
+		}
+		res = append(res, newMessage(mt, msgTime, from, to, ts))
+	}
+	return res
+}
+
+func FuzzProcess(f *testing.F) {
+	f.Add([]byte{0, 2, 0, 10, 2, 2, 2, 0, 11, 2, 1, 2, 1, 12, 2})
+	f.Add([]byte{0, 0, 0, 0, 0, 1, 5, 5, 255, 1, 3, 3, 3, 3, 3})
+	f.Add([]byte{1, 3, 0, 200, 2, 0, 3, 0, 100, 2, 1, 3, 0, 101, 2})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		const all, me = 4, 1
+		b := &recordBus{}
+		p := &process{
+			all:          all,
+			me:           me,
+			k:            1,
+			resource:     newResource(0),
+			bus:          b,
+			clock:        newClock(sim.Env{}),
+			requestQueue: newRequestQueue(),
+			receivedTime: newReceivedTime(all, me),
+			pending:      make(map[int]Timestamp),
+		}
+		requests := 0
+		pending := make(map[int]int) // 各个 process 还没有释放的申请的时间
+		for _, msg := range decodeMessages(data) {
+			before := p.clock.Now()
+			p.handle(msg)
+			now := p.clock.Now()
+			if now < before {
+				t.Fatalf("处理 %s 后，clock 从 %d 倒退到了 %d", msg, before, now)
+			}
+			if p.ignores(msg) || !p.isValid(msg) {
+				continue
+			}
+			if now <= msg.msgTime {
+				t.Fatalf("处理 %s 后，clock 为 %d，没有超过消息的时间", msg, now)
+			}
+			if msg.msgType == acknowledgment {
+				continue
+			}
+			// 合法的 request 和 release 一定带着发送方的 timestamp
+			ts := msg.timestamp.(*timestamp)
+			last, ok := pending[msg.from]
+			switch {
+			case msg.msgType == requestResource && !(ok && last == ts.time):
+				requests++
+				pending[msg.from] = ts.time
+			case msg.msgType == releaseResource && ok && last == ts.time:
+				delete(pending, msg.from)
+			}
+		}
+		// 每一个合法的、不重复的 request 都要回复一个 acknowledgment
+		if len(b.sent) != requests {
+			t.Fatalf("收到了 %d 个合法的 request，却发送了 %d 条 message", requests, len(b.sent))
+		}
+		for _, msg := range b.sent {
+			if msg.msgType != acknowledgment || msg.from != me {
+				t.Fatalf("发送了 %s", msg)
+			}
+		}
+	})
+}
+
+func FuzzSchedule(f *testing.F) {
+	f.Add([]byte{})
+	f.Add([]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+	f.Add([]byte{255, 128, 64, 32, 16, 8, 4, 2, 1, 0})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		choices := make([]int, len(data))
+		for i, d := range data {
+			choices[i] = int(d)
+		}
+		c := ModelConfig{Processes: 3, Requests: 2}
+		if violation, steps := runSchedule(c, choices); violation != "" {
+			t.Fatal((&ModelResult{Violation: violation, Schedule: steps}).String())
+		}
+	})
+}
+
+func Test_Fuzz(t *testing.T) {
+	ast := assert.New(t)
+	//
+	res := Fuzz(ModelConfig{Processes: 3, Requests: 2}, 0, 200)
+	ast.True(res.OK(), res.String())
+	ast.False(res.Complete)
+	ast.True(res.States > 200)
+}
+
+func Test_Fuzz_skipRule52(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := ModelConfig{Processes: 3, Requests: 2, SkipRule52: true}
+	res := Fuzz(c, 0, 200)
+	ast.False(res.OK())
+	ast.Contains(res.Violation, "AlwaysAtMostOneOccupier")
+	// 缩短后的调度，与穷举得到的最短反例一样长
+	ast.Equal(5, len(res.Schedule), res.String())
+}
+
+func Test_shrink(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := ModelConfig{Processes: 3, Requests: 2, SkipRule52: true}
+	rnd := rand.New(rand.NewSource(0))
+	choices := make([]int, 100)
+	violation := ""
+	for violation == "" {
+		for i := range choices {
+			choices[i] = rnd.Intn(256)
+		}
+		violation, _ = runSchedule(c, choices)
+	}
+	shrunk := shrink(c, choices, violation)
+	ast.True(len(shrunk) < len(choices))
+	v, _ := runSchedule(c, shrunk)
+	ast.Equal(violation, v)
+	// 再也不能删除任何一个选择
+	for i := range shrunk {
+		candidate := append(append([]int(nil), shrunk[:i]...), shrunk[i+1:]...)
+		v, _ := runSchedule(c, candidate)
+		ast.NotEqual(violation, v)
+	}
+}
+
+// quickSchedule 返回 spec.Quick 使用的 run：从 choices 中取出 process 的数量、申请的次数、
+// newModel 需要的选择，以及送达 message 的顺序，检查默认的性质
+func quickSchedule(newModel func(c ModelConfig, choices *spec.Choices) model) func(*spec.Choices) error {
+	return func(choices *spec.Choices) error {
+		c := ModelConfig{Processes: choices.Range(2, 8), Requests: choices.Range(1, 4)}
+		s := newModel(c, choices)
+		schedule := make([]int, c.Processes*c.Requests*(3*(c.Processes-1)+2))
+		for i := range schedule {
+			schedule[i] = choices.Intn(256)
+		}
+		if r := simulateModel(c, s, schedule); !r.OK() {
+			return errors.New(r.Violation)
+		}
+		return nil
+	}
+}
+
+func Test_Quick_lamport(t *testing.T) {
+	ast := assert.New(t)
+	//
+	res := spec.Quick(spec.QuickConfig{Seed: 1, Runs: 500}, quickSchedule(func(c ModelConfig, _ *spec.Choices) model {
+		return newMCState(c)
+	}))
+	ast.True(res.OK(), res.String())
+}
+
+func Test_Quick_raymond(t *testing.T) {
+	ast := assert.New(t)
+	//
+	// 随机的树：Pi 的父节点是 P0 到 Pi-1 中的一个
+	res := spec.Quick(spec.QuickConfig{Seed: 1, Runs: 500}, quickSchedule(func(c ModelConfig, choices *spec.Choices) model {
+		tree := make([]int, c.Processes)
+		tree[0] = -1
+		for i := 1; i < len(tree); i++ {
+			tree[i] = choices.Intn(i)
+		}
+		return newRMState(c, tree)
+	}))
+	ast.True(res.OK(), res.String())
+}
+
+func Test_Quick_skipRule52(t *testing.T) {
+	ast := assert.New(t)
+	//
+	res := spec.Quick(spec.QuickConfig{Seed: 1}, func(choices *spec.Choices) error {
+		c := ModelConfig{Processes: choices.Range(2, 6), Requests: choices.Range(1, 4), SkipRule52: true}
+		schedule := make([]int, c.Processes*c.Requests*(3*(c.Processes-1)+2))
+		for i := range schedule {
+			schedule[i] = choices.Intn(256)
+		}
+		if v, _ := runSchedule(c, schedule); v != "" {
+			return errors.New(v)
+		}
+		return nil
+	})
+	ast.False(res.OK())
+	ast.Contains(res.Err.Error(), "AlwaysAtMostOneOccupier")
+	// 缩短以后，规模缩小到了 3 个 process 各申请 1 次，调度不超过穷举得到的最短反例
+	ast.Equal([]int{1, 0}, res.Choices[:2], res.String())
+	ast.True(len(res.Choices)-2 <= 5, res.String())
+}
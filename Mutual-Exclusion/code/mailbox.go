@@ -0,0 +1,157 @@
+package mutualexclusion
+
+import (
+	"sync"
+)
+
+// OverflowPolicy 决定 mailbox 满了以后，如何处理新的 message
+type OverflowPolicy int
+
+// 枚举了所有的 OverflowPolicy
+const (
+	// Block 让发送方阻塞到 mailbox 有空位，也就是背压
+	Block OverflowPolicy = iota
+	// DropNewest 丢弃新的 message，会破坏算法对可靠通道的假设，只用来做实验
+	DropNewest
+)
+
+func (o OverflowPolicy) String() string {
+	if o == Block {
+		return "阻塞"
+	}
+	return "丢弃"
+}
+
+// MailboxStats 是 mailboxBus 的统计数据
+type MailboxStats struct {
+	Delivered int // 放入 mailbox 的 message 数量
+	Dropped   int // 因为 mailbox 已满而被丢弃的 message 数量
+	Blocked   int // 因为 mailbox 已满而阻塞了发送方的次数
+	MaxDepth  int // mailbox 中积压的 message 数量的最大值
+	Recycled  int // 所有的接收方都处理完以后，被放回 messagePool 的 message 数量
+}
+
+// mailboxBus 给每个 process 一个容量有限的 mailbox
+// 与 observerBus 不同，message 只会被放入接收方的 mailbox，
+// process 不需要扫描发给别人的 message，没有被接收的 message 也不会无限积压。
+// 每个发送方按顺序放入 mailbox，所以每一对 process 之间的 message 满足先发送先到达。
+//
+// 使用 Block 时，如果 capacity 太小，两个 process 可能互相等待对方的 mailbox 出现空位而死锁。
+// Lamport 算法中，Pi 的 mailbox 里，来自每个 Pj 的 message 最多有 3 条：
+// Pj 的释放、Pj 的下一次申请，以及对 Pi 的申请的回复，所以 capacity 不小于 3(N-1) 就不会死锁。
+type mailboxBus struct {
+	policy    OverflowPolicy
+	mailboxes []chan *message
+	done      chan struct{}
+	closeOnce sync.Once
+
+	mutex sync.Mutex
+	stats MailboxStats
+}
+
+func newMailboxBus(all, capacity int, policy OverflowPolicy) *mailboxBus {
+	b := &mailboxBus{
+		policy:    policy,
+		mailboxes: make([]chan *message, all),
+		done:      make(chan struct{}),
+	}
+	for i := range b.mailboxes {
+		b.mailboxes[i] = make(chan *message, capacity)
+	}
+	return b
+}
+
+func (b *mailboxBus) send(msg *message) {
+	if msg.to != OTHERS {
+		msg.retain(1)
+		b.put(msg.to, msg)
+		return
+	}
+	// 先把所有的接收方计算在内，以免先收到的 process 处理完以后，就回收了 msg
+	// 放入最后一个 mailbox 以后，msg 随时可能被回收，所以不能再读取 msg.from
+	from := msg.from
+	msg.retain(int32(len(b.mailboxes) - 1))
+	for i := range b.mailboxes {
+		if i != from {
+			b.put(i, msg)
+		}
+	}
+}
+
+// put 把 msg 放入 to 的 mailbox，bus 关闭以后，直接丢弃 msg
+// 没有放入 mailbox 的 msg，也就少了一个接收方
+func (b *mailboxBus) put(to int, msg *message) {
+	mailbox := b.mailboxes[to]
+	select {
+	case mailbox <- msg:
+		b.record(len(mailbox), false, false)
+		return
+	case <-b.done:
+		b.recycle(msg)
+		return
+	default:
+	}
+	// mailbox 已满
+	if b.policy == DropNewest {
+		b.record(0, true, false)
+		b.recycle(msg)
+		return
+	}
+	b.record(0, false, true)
+	select {
+	case mailbox <- msg:
+		b.record(len(mailbox), false, false)
+	case <-b.done:
+		b.recycle(msg)
+	}
+}
+
+func (b *mailboxBus) record(depth int, dropped, blocked bool) {
+	b.mutex.Lock()
+	switch {
+	case dropped:
+		b.stats.Dropped++
+	case blocked:
+		b.stats.Blocked++
+	default:
+		b.stats.Delivered++
+		b.stats.MaxDepth = max(b.stats.MaxDepth, depth)
+	}
+	b.mutex.Unlock()
+}
+
+func (b *mailboxBus) receiver(me int) func() (*message, bool) {
+	mailbox := b.mailboxes[me]
+	return func() (*message, bool) {
+		select {
+		case msg := <-mailbox:
+			return msg, true
+		case <-b.done:
+			return nil, false
+		}
+	}
+}
+
+// recycle 在接收方处理完 msg 以后调用，所有的接收方都处理完时，msg 会被放回 messagePool
+// 所以接收方不能在处理完以后，继续持有 msg
+func (b *mailboxBus) recycle(msg *message) {
+	if msg.recycle() {
+		b.mutex.Lock()
+		b.stats.Recycled++
+		b.mutex.Unlock()
+	}
+}
+
+// close 让所有的接收函数返回 false，并唤醒阻塞的发送方
+func (b *mailboxBus) close() {
+	b.closeOnce.Do(func() {
+		close(b.done)
+	})
+}
+
+// Stats 返回目前为止的统计数据
+func (b *mailboxBus) Stats() MailboxStats {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.stats
+}
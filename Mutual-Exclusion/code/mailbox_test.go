@@ -0,0 +1,178 @@
+package mutualexclusion
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/aQuaYi/observer"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_mailboxBus(t *testing.T) {
+	ast := assert.New(t)
+	//
+	b := newMailboxBus(3, 2, Block)
+	msg := newMessage(requestResource, 1, 0, OTHERS, newTimestamp(1, 0))
+	b.send(msg)
+	ack := newMessage(acknowledgment, 2, 1, 0, newTimestamp(1, 0))
+	b.send(ack)
+	for i, expected := range []*message{ack, msg, msg} {
+		actual, ok := b.receiver(i)()
+		ast.True(ok)
+		ast.Equal(expected, actual)
+	}
+	ast.Equal(MailboxStats{Delivered: 3, MaxDepth: 1}, b.Stats())
+	//
+	b.close()
+	_, ok := b.receiver(0)()
+	ast.False(ok)
+	b.send(msg) // 关闭以后发送，不会阻塞
+}
+
+func Test_mailboxBus_overflow(t *testing.T) {
+	ast := assert.New(t)
+	//
+	b := newMailboxBus(2, 2, DropNewest)
+	for i := 1; i <= 5; i++ {
+		b.send(newMessage(requestResource, i, 0, 1, newTimestamp(i, 0)))
+	}
+	ast.Equal(MailboxStats{Delivered: 2, Dropped: 3, MaxDepth: 2, Recycled: 3}, b.Stats())
+	next := b.receiver(1)
+	for i := 1; i <= 2; i++ {
+		msg, _ := next()
+		ast.Equal(i, msg.msgTime)
+	}
+	// Block 让发送方等待接收方
+	b = newMailboxBus(2, 1, Block)
+	sent := make(chan struct{})
+	go func() {
+		for i := 1; i <= 3; i++ {
+			b.send(newMessage(requestResource, i, 0, 1, newTimestamp(i, 0)))
+		}
+		close(sent)
+	}()
+	next = b.receiver(1)
+	for i := 1; i <= 3; i++ {
+		time.Sleep(10 * time.Millisecond)
+		msg, _ := next()
+		ast.Equal(i, msg.msgTime)
+	}
+	<-sent
+	ast.Equal(3, b.Stats().Delivered)
+	ast.Equal(2, b.Stats().Blocked)
+	ast.Equal(0, b.Stats().Dropped)
+	// 关闭会唤醒阻塞的发送方
+	b.send(newMessage(requestResource, 4, 0, 1, newTimestamp(4, 0)))
+	go b.close()
+	b.send(newMessage(requestResource, 5, 0, 1, newTimestamp(5, 0)))
+}
+
+// runWithBus 让 all 个 process 通过 b 各申请 times 次
+func runWithBus(all, times int, b bus) {
+	rsc := newResource(all * times)
+	ps := make([]Process, all)
+	for i := range ps {
+		ps[i] = newProcessWithBus(all, i, rsc, b)
+	}
+	for _, p := range ps {
+		go func(p Process) {
+			for i := 0; i < times; i++ {
+				p.Request()
+			}
+		}(p)
+	}
+	rsc.wait()
+}
+
+func Test_process_overMailboxBus(t *testing.T) {
+	ast := assert.New(t)
+	//
+	for _, all := range []int{2, 16, 128} {
+		t.Run(fmt.Sprintf("%d Process", all), func(t *testing.T) {
+			b := newMailboxBus(all, 3*(all-1), Block)
+			defer b.close()
+			ast.NotPanics(func() {
+				runWithBus(all, 4, b)
+			})
+			// capacity 为 3(N-1) 时，mailbox 不会满
+			ast.Equal(0, b.Stats().Blocked)
+			ast.True(b.Stats().MaxDepth <= 3*(all-1))
+		})
+	}
+}
+
+func benchmarkBus(b *testing.B, all int, newBus func() (bus, func())) {
+	b.ReportAllocs()
+	times := b.N/all + 1
+	bs, stop := newBus()
+	defer stop()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	b.ResetTimer()
+	runWithBus(all, times, bs)
+	b.StopTimer()
+	runtime.ReadMemStats(&after)
+	b.ReportMetric(float64(after.NumGC-before.NumGC), "GCs")
+}
+
+func Benchmark_observerBus(b *testing.B) {
+	for _, all := range []int{16, 128} {
+		b.Run(fmt.Sprintf("%d Process", all), func(b *testing.B) {
+			benchmarkBus(b, all, func() (bus, func()) {
+				// observer 的 stream 没有办法关闭，监听的 goroutine 会一直存在
+				return newObserverBus(observer.NewProperty(nil)), func() {}
+			})
+		})
+	}
+}
+
+func Benchmark_mailboxBus(b *testing.B) {
+	for _, all := range []int{16, 128} {
+		b.Run(fmt.Sprintf("%d Process", all), func(b *testing.B) {
+			benchmarkBus(b, all, func() (bus, func()) {
+				mb := newMailboxBus(all, 3*(all-1), Block)
+				return mb, mb.close
+			})
+		})
+	}
+}
+
+func Test_mailboxBus_recycle(t *testing.T) {
+	ast := assert.New(t)
+	// msg 被放回 messagePool 以后，随时可能被别人取出，所以只能通过 Stats 检查，不能再读取 msg
+	b := newMailboxBus(3, 4, Block)
+	msg := newMessage(requestResource, 1, 0, OTHERS, newTimestamp(1, 0))
+	b.send(msg)
+	ast.Equal(int32(2), msg.refs)
+	m1, _ := b.receiver(1)()
+	b.recycle(m1)
+	ast.Equal(int32(1), msg.refs)
+	ast.NotNil(msg.timestamp)
+	ast.Equal(0, b.Stats().Recycled)
+	m2, _ := b.receiver(2)()
+	b.recycle(m2)
+	// 最后一个接收方处理完以后，msg 被放回 messagePool
+	ast.Equal(1, b.Stats().Recycled)
+	// 没有放入 mailbox 的 message 也会被回收
+	b = newMailboxBus(2, 1, DropNewest)
+	b.send(newMessage(acknowledgment, 1, 0, 1, nil))
+	b.send(newMessage(acknowledgment, 2, 0, 1, newTimestamp(1, 0)))
+	ast.Equal(1, b.Stats().Dropped)
+	ast.Equal(1, b.Stats().Recycled)
+	// 不能回收 message 的 bus，recycleFunc 什么也不做
+	msg = newMessage(acknowledgment, 3, 0, 1, newTimestamp(1, 0))
+	recycleFunc(newObserverBus(observer.NewProperty(nil)))(msg)
+	ast.NotNil(msg.timestamp)
+}
+
+func Test_message_recycle(t *testing.T) {
+	ast := assert.New(t)
+	//
+	msg := newMessage(acknowledgment, 1, 0, OTHERS, newTimestamp(1, 0))
+	msg.retain(2)
+	ast.False(msg.recycle())
+	ast.Equal(int32(1), msg.refs)
+	ast.True(msg.recycle(), "最后一个接收方把 msg 放回 messagePool")
+}
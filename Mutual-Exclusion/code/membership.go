@@ -0,0 +1,149 @@
+package mutualexclusion
+
+import (
+	"sort"
+
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+	"github.com/aQuaYi/observer"
+)
+
+// membership 让 process 在不知道 process 总数的情况下运行 Lamport 算法
+// 新的 process 先广播 join，收到 join 的 process 回复 welcome，
+// join 和 welcome 都带着发送方已经发现的 process 和发送方还没有释放的申请。
+// 与 gossip 交换 view 一样，process 把收到的 view 合并到自己的 view 中，
+// 并给新发现的 process 单独发送 join。
+// 从 view 中所有的 process 都收到 join 或者 welcome 以后，发现阶段才结束，此前的 Request 会被推迟。
+//
+// Rule 5.2 所要求的“收到所有 process 更晚的 message”，只针对已经发现的 process，
+// 所以 receivedTime 和 rule 5 的范围会随着新的发现而扩大。
+// 新的 process 只有在发现阶段结束以后才会申请，此时它已经从每个 process 那里得知了还没有释放的申请，
+// 所以它的申请一定排在这些申请的后面。
+type membership struct {
+	me       int
+	known    map[int]bool // 已经发现的 process，不包括自己
+	greeted  map[int]bool // 已经收到 join 或者 welcome 的 process
+	rt       *receivedTime
+	ready    bool // 发现阶段已经结束
+	deferred bool // 发现阶段结束以前收到了 Request
+}
+
+// newAnonymousProcess 返回不知道 process 总数的 process
+// introducer 是已经完成发现阶段的任意一个 process，为负数时，新建一个只有自己的 group。
+// 所有的 process 需要共用 prop，因为只有广播才能让 join 到达还没有发现的 process。
+func newAnonymousProcess(me, introducer int, r Resource, prop observer.Property) Process {
+	return newAnonymousProcessWithEnv(me, introducer, r, prop, sim.Env{})
+}
+
+// newAnonymousProcessWithEnv 与 newAnonymousProcess 一样，但是 process 从 env 读取时间和随机数
+func newAnonymousProcessWithEnv(me, introducer int, r Resource, prop observer.Property, env sim.Env) Process {
+	rt := &receivedTime{trq: new(timeRecordQueue)}
+	m := &membership{
+		me:      me,
+		known:   make(map[int]bool, 16),
+		greeted: make(map[int]bool, 16),
+		rt:      rt,
+		ready:   introducer < 0,
+	}
+	p := &process{
+		me:           me,
+		k:            1,
+		env:          env,
+		resource:     r,
+		bus:          newObserverBus(prop),
+		members:      m,
+		clock:        newClock(env),
+		requestQueue: newRequestQueue(),
+		receivedTime: rt,
+		// 同一个申请可能从广播和 welcome 收到两次，由 pending 去重
+		pending: make(map[int]Timestamp, 16),
+		inbox:   make(chan *message, 16),
+		local:   make(chan processEvent, 1),
+	}
+	var join *message
+	if !m.ready {
+		m.discover(introducer)
+		// 在 event loop 开始修改 view 之前生成 join
+		join = m.greeting(p, joinGroup, OTHERS)
+	}
+
+	p.Listening()
+
+	if join != nil {
+		// Listening 已经开始观察，所以不会错过任何回复
+		p.bus.send(join)
+	}
+
+	debugPrintf("%s 完成创建工作", p)
+
+	return p
+}
+
+// discover 把 id 加入 view，返回 true，如果 id 是新发现的
+func (m *membership) discover(id int) bool {
+	if id == m.me || m.known[id] {
+		return false
+	}
+	m.known[id] = true
+	m.rt.add(id)
+	return true
+}
+
+// view 返回已经发现的 process，包括自己
+func (m *membership) view() []int {
+	res := make([]int, 0, len(m.known)+1)
+	res = append(res, m.me)
+	for id := range m.known {
+		res = append(res, id)
+	}
+	sort.Ints(res)
+	return res
+}
+
+// greeting 返回发送给 to 的 join 或者 welcome
+func (m *membership) greeting(p *process, mt msgType, to int) *message {
+	msg := newMessage(mt, p.clock.Tick(), p.me, to, p.requestTimestamp)
+	msg.members = m.view()
+	return msg
+}
+
+// handleJoin 回复 welcome，并像 welcome 一样处理 join
+func (m *membership) handleJoin(p *process, msg *message) {
+	p.bus.send(m.greeting(p, welcome, msg.from))
+	m.handleWelcome(p, msg)
+}
+
+// handleWelcome 合并对方的 view，并把对方还没有释放的申请当作刚收到的申请处理
+func (m *membership) handleWelcome(p *process, msg *message) {
+	m.greeted[msg.from] = true
+	for _, id := range msg.members {
+		if m.discover(id) {
+			p.bus.send(m.greeting(p, joinGroup, id))
+		}
+	}
+	if msg.timestamp != nil {
+		p.handleRequestMessage(msg)
+	}
+	if m.ready {
+		return
+	}
+	for id := range m.known {
+		if !m.greeted[id] {
+			return
+		}
+	}
+	m.ready = true
+	debugPrintf("%s 发现了 %v", p, m.view())
+	if m.deferred {
+		m.deferred = false
+		p.request()
+	}
+}
+
+// postpone 返回 true，如果发现阶段还没有结束，此时的申请会被推迟到发现阶段结束以后
+func (m *membership) postpone() bool {
+	if m.ready {
+		return false
+	}
+	m.deferred = true
+	return true
+}
@@ -0,0 +1,89 @@
+package mutualexclusion
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aQuaYi/observer"
+	"github.com/stretchr/testify/assert"
+)
+
+// requestAll 让每个 process 各申请 times 次
+func requestAll(ps []Process, times int) {
+	for _, p := range ps {
+		go func(p Process) {
+			for i := 0; i < times; i++ {
+				p.Request()
+			}
+		}(p)
+	}
+}
+
+func Test_anonymous_sequentialJoin(t *testing.T) {
+	ast := assert.New(t)
+	//
+	all, times := 5, 200
+	rsc := newResource(all * times)
+	prop := observer.NewProperty(nil)
+	ps := []Process{newAnonymousProcess(0, -1, rsc, prop)}
+	requestAll(ps, times)
+	// 其他 process 在 P0 不断申请的时候，通过之前加入的 process 陆续加入
+	for i := 1; i < all; i++ {
+		time.Sleep(time.Millisecond)
+		p := newAnonymousProcess(i, i-1, rsc, prop)
+		ps = append(ps, p)
+		requestAll([]Process{p}, times)
+	}
+	rsc.wait()
+	ast.Len(rsc.timestamps, all*times)
+}
+
+func Test_anonymous_concurrentJoin(t *testing.T) {
+	ast := assert.New(t)
+	//
+	all, times := 8, 100
+	rsc := newResource(all * times)
+	prop := observer.NewProperty(nil)
+	founder := newAnonymousProcess(0, -1, rsc, prop)
+	requestAll([]Process{founder}, times)
+	// 同时加入的 process 可能错过彼此的 join，只能从其他 process 的 view 中发现对方
+	var wg sync.WaitGroup
+	for i := 1; i < all; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			requestAll([]Process{newAnonymousProcess(i, 0, rsc, prop)}, times)
+		}(i)
+	}
+	wg.Wait()
+	rsc.wait()
+	ast.Len(rsc.timestamps, all*times)
+}
+
+func Test_anonymous_postponed(t *testing.T) {
+	ast := assert.New(t)
+	//
+	rsc := newResource(1)
+	prop := observer.NewProperty(nil)
+	// P5 不存在，P1 的发现阶段不会结束，所以它的申请会一直被推迟
+	p := newAnonymousProcess(1, 5, rsc, prop)
+	p.Request()
+	time.Sleep(20 * time.Millisecond)
+	ast.Empty(rsc.timestamps)
+	// P5 通过 P1 加入以后，P1 的发现阶段才结束，然后才会申请
+	newAnonymousProcess(5, 1, rsc, prop)
+	rsc.wait()
+	ast.Len(rsc.timestamps, 1)
+}
+
+func Test_membership_view(t *testing.T) {
+	ast := assert.New(t)
+	//
+	m := &membership{me: 4, known: make(map[int]bool), rt: &receivedTime{trq: new(timeRecordQueue)}}
+	ast.True(m.discover(7))
+	ast.True(m.discover(1))
+	ast.False(m.discover(7))
+	ast.False(m.discover(4))
+	ast.Equal([]int{1, 4, 7}, m.view())
+}
@@ -1,6 +1,10 @@
 package mutualexclusion
 
-import "fmt"
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
 
 type message struct {
 	msgType   msgType
@@ -8,19 +12,55 @@ type message struct {
 	to        int // message 接收方的 ID， 当值为 OTHERS 的时候，表示接收方为除 from 外的所有
 	timestamp Timestamp
 	msgTime   int
+	members   []int // 发送方已经发现的 process，只用于 welcome
+	refs      int32 // 还没有处理完这条 message 的接收方的数量，只由 mailboxBus 使用
+}
+
+// messagePool 回收处理完的 message，避免每次发送都在堆上分配
+// 只有能确定所有接收方都已经处理完的 bus，才会把 message 放回去，其他的 bus 交给 GC 回收
+var messagePool = sync.Pool{
+	New: func() interface{} { return new(message) },
 }
 
+// newMessage 从 messagePool 中取出 message，message 持有 ts，直到被回收
 func newMessage(mt msgType, msgTime, from, to int, ts Timestamp) *message {
-	return &message{
+	if ts != nil {
+		retainTimestamp(ts)
+	}
+	m := messagePool.Get().(*message)
+	*m = message{
 		msgType:   mt,
 		msgTime:   msgTime,
 		from:      from,
 		to:        to,
 		timestamp: ts,
 	}
+	return m
+}
+
+// retain 表示又有 n 个接收方持有 m
+func (m *message) retain(n int32) {
+	atomic.AddInt32(&m.refs, n)
+}
+
+// recycle 表示一个接收方处理完了 m，最后一个接收方把 m 放回 messagePool，并返回 true
+// 返回 true 以后，m 随时可能被别人取出，调用方不能再读写 m
+func (m *message) recycle() bool {
+	if atomic.AddInt32(&m.refs, -1) != 0 {
+		return false
+	}
+	if m.timestamp != nil {
+		releaseTimestamp(m.timestamp)
+		m.timestamp = nil
+	}
+	messagePool.Put(m)
+	return true
 }
 
 func (m *message) String() string {
+	if m.timestamp == nil {
+		return fmt.Sprintf("{%s, Time:%d, From:%d, To:%2d}", m.msgType, m.msgTime, m.from, m.to)
+	}
 	return fmt.Sprintf("{%s, Time:%d, From:%d, To:%2d, %s}", m.msgType, m.msgTime, m.from, m.to, m.timestamp)
 }
 
@@ -32,6 +72,12 @@ const (
 	requestResource msgType = iota
 	releaseResource
 	acknowledgment
+	// 以下两种只用于 Raymond 算法
+	tokenRequest
+	tokenPrivilege
+	// 以下两种只用于不知道 process 总数的 process
+	joinGroup
+	welcome
 )
 
 func (mt msgType) String() string {
@@ -40,6 +86,14 @@ func (mt msgType) String() string {
 		return "申请"
 	case releaseResource:
 		return "释放"
+	case tokenRequest:
+		return "令牌申请"
+	case tokenPrivilege:
+		return "令牌"
+	case joinGroup:
+		return "加入"
+	case welcome:
+		return "欢迎"
 	default:
 		return "确认"
 	}
@@ -26,4 +26,8 @@ func Test_Message(t *testing.T) {
 	actual = m.String()
 	ast.Equal(expected, actual)
 	//
+	m = newMessage(tokenRequest, 0, 1, 0, nil)
+	ast.Equal("{令牌申请, Time:0, From:1, To: 0}", m.String())
+	m.msgType = tokenPrivilege
+	ast.Equal("{令牌, Time:0, From:1, To: 0}", m.String())
 }
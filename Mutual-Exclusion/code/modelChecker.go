@@ -0,0 +1,454 @@
+package mutualexclusion
+
+import (
+	"fmt"
+	"strings"
+
+	spec "github.com/aQuaYi/Distributed-Algorithms/Spec/code"
+)
+
+// ModelConfig 是模型检查的配置
+// 状态空间随规模指数增长。3 个 process 各申请 1 次，或者 2 个 process 各申请 3 次，
+// 可以在 1 秒内穷举；更大的规模需要用 MaxStates 限制搜索的范围
+type ModelConfig struct {
+	Processes int // process 的数量
+	Requests  int // 每个 process 申请资源的次数
+	// MaxStates 是最多探索的状态数量，为 0 时不限制
+	MaxStates int
+	// SkipRule52 为 true 时，占用资源前不检查 Rule5.2
+	// 这是一个故意植入的错误，用来演示 checker 给出的反例
+	SkipRule52 bool
+	// NonFIFO 为 true 时，通道中的消息可以以任意顺序送达
+	// 这违反了算法对通道的假设，用来演示 FIFO 的必要性
+	NonFIFO bool
+	// SuppressAcks 为 true 时，如果已经给申请方发送过时间晚于申请的 message，就不再回复这个申请
+	// 这是减少 message 数量的常见优化，见 newProcessSuppressingAcks
+	SuppressAcks bool
+	// Properties 是需要检查的性质，它们检查的状态是 *Snapshot
+	// 为 nil 时，检查 AlwaysAtMostOneOccupier 和每一次申请的 EventuallyGranted
+	Properties []spec.Property
+}
+
+// ModelResult 是模型检查的结果
+type ModelResult struct {
+	States    int      // 探索过的状态数量
+	Complete  bool     // 是否探索了全部的状态
+	Violation string   // 违反的性质和原因，为空表示没有发现错误
+	Schedule  []string // 从初始状态到违反性质的状态所经历的步骤
+}
+
+// OK 返回 true，如果没有发现错误
+func (r *ModelResult) OK() bool {
+	return r.Violation == ""
+}
+
+func (r *ModelResult) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "探索了 %d 个状态", r.States)
+	if !r.Complete {
+		b.WriteString("（未完成）")
+	}
+	if r.OK() {
+		b.WriteString("，没有发现错误")
+		return b.String()
+	}
+	fmt.Fprintf(&b, "，违反了 %s，反例：\n", r.Violation)
+	for i, step := range r.Schedule {
+		fmt.Fprintf(&b, "%3d. %s\n", i+1, step)
+	}
+	return b.String()
+}
+
+// mcMessage 是模型中的 message，与 message 不同，它是值类型，便于复制状态
+type mcMessage struct {
+	msgType msgType
+	msgTime int
+	ts      timestamp
+}
+
+// mcProcess 是模型中 process 的状态
+type mcProcess struct {
+	clock      int
+	queue      sortedQueue[timestamp] // 按照全局排序排列的 request queue
+	received   []int                  // 从各个 process 收到的最新的 message 时间
+	sent       []int                  // 发给各个 process 的最新的 message 时间，不省略回复时为 nil
+	requesting bool
+	request    timestamp
+	occupying  bool
+	remaining  int // 还需要申请的次数
+}
+
+// mcState 是整个系统的状态
+// channels[from*n+to] 是从 from 发往 to 的、还没有送达的 message
+type mcState struct {
+	procs    []mcProcess
+	channels [][]mcMessage
+}
+
+func newMCState(c ModelConfig) *mcState {
+	n := c.Processes
+	s := &mcState{
+		procs:    make([]mcProcess, n),
+		channels: make([][]mcMessage, n*n),
+	}
+	for i := range s.procs {
+		s.procs[i] = mcProcess{
+			received:  make([]int, n),
+			remaining: c.Requests,
+		}
+		if c.SuppressAcks {
+			s.procs[i].sent = make([]int, n)
+		}
+	}
+	return s
+}
+
+func (s *mcState) clone() *mcState {
+	res := &mcState{
+		procs:    make([]mcProcess, len(s.procs)),
+		channels: make([][]mcMessage, len(s.channels)),
+	}
+	for i, p := range s.procs {
+		p.queue = p.queue.clone()
+		p.received = append([]int(nil), p.received...)
+		if p.sent != nil {
+			p.sent = append([]int(nil), p.sent...)
+		}
+		res.procs[i] = p
+	}
+	for i, ch := range s.channels {
+		res.channels[i] = append([]mcMessage(nil), ch...)
+	}
+	return res
+}
+
+// key 是状态的唯一编码，用于判断状态是否已经探索过
+func (s *mcState) key() string {
+	b := make([]byte, 0, 256)
+	put := func(xs ...int) {
+		for _, x := range xs {
+			b = append(b, byte(x), byte(x>>8))
+		}
+	}
+	for _, p := range s.procs {
+		put(p.clock, p.remaining, boolToInt(p.requesting), boolToInt(p.occupying), p.request.time)
+		put(p.received...)
+		put(p.sent...)
+		put(len(p.queue))
+		for _, ts := range p.queue {
+			put(ts.time, ts.process)
+		}
+	}
+	for _, ch := range s.channels {
+		put(len(ch))
+		for _, m := range ch {
+			put(int(m.msgType), m.msgTime, m.ts.time, m.ts.process)
+		}
+	}
+	return string(b)
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func (s *mcState) send(from, to int, msg mcMessage) {
+	i := from*len(s.procs) + to
+	s.channels[i] = append(s.channels[i], msg)
+	if sent := s.procs[from].sent; sent != nil {
+		sent[to] = msg.msgTime
+	}
+}
+
+func (s *mcState) broadcast(from int, msg mcMessage) {
+	for to := range s.procs {
+		if to != from {
+			s.send(from, to, msg)
+		}
+	}
+}
+
+// mcAction 是状态之间的一次转移
+type mcAction struct {
+	kind    string // request, deliver, release
+	process int
+	from    int // deliver 时 message 的发送方
+	index   int // deliver 时 message 在通道中的位置
+}
+
+// actions 返回 s 中所有可以进行的转移
+func (s *mcState) actions(c ModelConfig) []mcAction {
+	var res []mcAction
+	n := len(s.procs)
+	for i, p := range s.procs {
+		if !p.requesting && p.remaining > 0 {
+			res = append(res, mcAction{kind: "request", process: i})
+		}
+		if p.occupying {
+			res = append(res, mcAction{kind: "release", process: i})
+		}
+		for from := 0; from < n; from++ {
+			ch := s.channels[from*n+i]
+			deliverable := len(ch)
+			if !c.NonFIFO && deliverable > 1 {
+				deliverable = 1
+			}
+			for k := 0; k < deliverable; k++ {
+				res = append(res, mcAction{kind: "deliver", process: i, from: from, index: k})
+			}
+		}
+	}
+	return res
+}
+
+func (s *mcState) canOccupy(i int, c ModelConfig) bool {
+	return s.procs[i].canOccupy(i, c.SkipRule52)
+}
+
+// canOccupy 返回 true，如果 process me 满足 Rule 5，可以占用资源
+func (p *mcProcess) canOccupy(me int, skipRule52 bool) bool {
+	if !p.requesting || p.occupying || len(p.queue) == 0 || p.queue[0] != p.request {
+		return false
+	}
+	if skipRule52 {
+		return true
+	}
+	for j, t := range p.received {
+		if j != me && !p.request.IsBefore(t) {
+			return false
+		}
+	}
+	return true
+}
+
+// apply 在 s 上进行 a，并返回 a 的描述
+func (s *mcState) apply(a mcAction, c ModelConfig) string {
+	p := &s.procs[a.process]
+	switch a.kind {
+	case "request":
+		// Rule 1
+		p.clock++
+		p.request = timestamp{time: p.clock, process: a.process}
+		p.requesting = true
+		p.remaining--
+		p.queue.push(p.request)
+		s.broadcast(a.process, mcMessage{msgType: requestResource, msgTime: p.clock, ts: p.request})
+		return fmt.Sprintf("P%d 申请 %s", a.process, &p.request)
+	case "release":
+		// Rule 3
+		p.queue.remove(p.request)
+		p.clock++
+		s.broadcast(a.process, mcMessage{msgType: releaseResource, msgTime: p.clock, ts: p.request})
+		p.occupying = false
+		p.requesting = false
+		return fmt.Sprintf("P%d 释放 %s", a.process, &p.request)
+	}
+	// deliver
+	i := a.from*len(s.procs) + a.process
+	msg := s.channels[i][a.index]
+	s.channels[i] = append(s.channels[i][:a.index], s.channels[i][a.index+1:]...)
+	p.clock = max(p.clock, msg.msgTime+1)
+	p.received[a.from] = msg.msgTime
+	switch msg.msgType {
+	case requestResource:
+		// Rule 2
+		p.queue.push(msg.ts)
+		// 已经发送过更晚的 message 时，对方的 Rule 5.2 不再需要回复
+		if p.sent == nil || p.sent[a.from] <= msg.ts.time {
+			p.clock++
+			s.send(a.process, a.from, mcMessage{msgType: acknowledgment, msgTime: p.clock, ts: msg.ts})
+		}
+	case releaseResource:
+		// Rule 4
+		p.queue.remove(msg.ts)
+	}
+	step := fmt.Sprintf("P%d 收到 P%d 的%s %s", a.process, a.from, msg.msgType, &msg.ts)
+	// 与 process.Listening 一样，每收到一条 message 都检查 Rule 5
+	if s.canOccupy(a.process, c) {
+		p.occupying = true
+		step += fmt.Sprintf("，然后占用 %s", &p.request)
+	}
+	return step
+}
+
+// ample 利用 partial order reduction，从 actions 中选出需要探索的一部分
+// 不同 process 收到 message 的转移是相互独立的，交换它们的顺序，得到的状态相同。
+// 如果 process p 的所有转移满足以下条件，只探索 p 的转移就足够了：
+//  1. 所有发往 p 的通道都不是空的。在 FIFO 通道中，其他 process 以后发给 p 的 message，
+//     只能排在这些 message 后面，所以 p 的转移不会与未来才出现的转移交换顺序
+//  2. p 的转移都不会改变资源的占用情况，也就不会改变 Snapshot，这样才不会错过违反性质的中间状态
+func (s *mcState) ample(actions []mcAction, c ModelConfig) []mcAction {
+	if c.NonFIFO {
+		return actions
+	}
+	n := len(s.procs)
+	best := actions
+	for p := 0; p < n; p++ {
+		ready := true
+		for from := 0; from < n && ready; from++ {
+			ready = from == p || len(s.channels[from*n+p]) > 0
+		}
+		if !ready {
+			continue
+		}
+		var res []mcAction
+		for _, a := range actions {
+			if a.process != p {
+				continue
+			}
+			if a.kind == "release" || s.occupiedAfter(a, c) {
+				ready = false
+				break
+			}
+			res = append(res, a)
+		}
+		if ready && len(res) < len(best) {
+			best = res
+		}
+	}
+	return best
+}
+
+// occupiedAfter 返回 true，如果 a 会让 process 占用资源
+func (s *mcState) occupiedAfter(a mcAction, c ModelConfig) bool {
+	if a.kind != "deliver" {
+		return false
+	}
+	next := s.clone()
+	next.apply(a, c)
+	return next.procs[a.process].occupying
+}
+
+// snapshot 返回 s 对应的 Snapshot，requests 是每个 process 申请资源的次数
+func (s *mcState) snapshot(requests int) *Snapshot {
+	res := &Snapshot{Granted: make([]int, len(s.procs)), Processes: make([]State, len(s.procs))}
+	for i, p := range s.procs {
+		res.Processes[i] = p.state(i)
+		if p.occupying {
+			res.Occupiers = append(res.Occupiers, i)
+		}
+		res.Granted[i] = requests - p.remaining
+		if p.requesting && !p.occupying {
+			res.Granted[i]--
+		}
+	}
+	return res
+}
+
+func (s *mcState) message(a mcAction) (msgType, string) {
+	msg := s.channels[a.from*len(s.procs)+a.process][a.index]
+	return msg.msgType, msg.ts.String()
+}
+
+func (s *mcState) occupying(i int) bool {
+	return s.procs[i].occupying
+}
+
+func (s *mcState) request(i int) string {
+	return s.procs[i].request.String()
+}
+
+// finished 返回 true，如果所有的申请都已经被满足了
+func (s *mcState) finished() bool {
+	for _, p := range s.procs {
+		if p.requesting || p.remaining > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// mcNode 是搜索树中的节点，用于还原反例
+type mcNode struct {
+	state    *mcState
+	monitors spec.Monitors // 检查从初始状态到 state 这条路径的 Monitor
+	parent   *mcNode
+	step     string
+}
+
+func (n *mcNode) schedule() []string {
+	var res []string
+	for ; n.parent != nil; n = n.parent {
+		res = append(res, n.step)
+	}
+	for i, j := 0, len(res)-1; i < j; i, j = i+1, j-1 {
+		res[i], res[j] = res[j], res[i]
+	}
+	return res
+}
+
+// ModelCheck 穷举 Lamport 算法中所有可能的 message 送达顺序，检查每一条路径是否满足 c.Properties。
+// 默认的性质是：
+//  1. safety：任何时候，最多只有一个 process 占用资源
+//  2. liveness：所有的申请最终都会被满足
+//
+// 由于每个 process 的申请次数有限，状态图中没有环，每条路径都会在没有后继的状态结束，
+// 此时检查 Eventually 之类的性质是否已经被满足。此外，没有后继时还有申请没有被满足，就是发生了 deadlock。
+// 使用广度优先搜索，所以找到的反例是最短的。
+func ModelCheck(c ModelConfig) *ModelResult {
+	props := c.Properties
+	if props == nil {
+		props = defaultProperties(c.Processes, c.Requests)
+	}
+
+	res := &ModelResult{}
+	root := &mcNode{state: newMCState(c), monitors: spec.NewMonitors(props...)}
+	fail := func(node *mcNode, violation string) *ModelResult {
+		res.Violation = violation
+		res.Schedule = node.schedule()
+		return res
+	}
+	if err := root.monitors.Step(root.state.snapshot(c.Requests)); err != nil {
+		return fail(root, err.Error())
+	}
+	visited := map[string]bool{root.state.key() + root.monitors.Key(): true}
+	queue := []*mcNode{root}
+
+	for len(queue) > 0 {
+		if c.MaxStates > 0 && res.States >= c.MaxStates {
+			return res
+		}
+		node := queue[0]
+		queue[0] = nil
+		queue = queue[1:]
+		res.States++
+
+		actions := node.state.actions(c)
+		if len(actions) == 0 {
+			if err := node.monitors.Done(); err != nil {
+				return fail(node, err.Error())
+			}
+			if !node.state.finished() {
+				return fail(node, "deadlock: 还有申请没有被满足，但是系统已经无法继续运行")
+			}
+		}
+
+		for _, a := range node.state.ample(actions, c) {
+			next := &mcNode{
+				state:    node.state.clone(),
+				monitors: node.monitors.Clone(),
+				parent:   node,
+			}
+			next.step = next.state.apply(a, c)
+			// 先检查再去重，这样每一个状态都会被检查到
+			if err := next.monitors.Step(next.state.snapshot(c.Requests)); err != nil {
+				return fail(next, err.Error())
+			}
+			key := next.state.key() + next.monitors.Key()
+			if visited[key] {
+				continue
+			}
+			visited[key] = true
+			queue = append(queue, next)
+		}
+		// 还原反例时只需要 step，释放已经探索过的状态
+		node.state = nil
+		node.monitors = nil
+	}
+
+	res.Complete = true
+	return res
+}
@@ -0,0 +1,107 @@
+package mutualexclusion
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	spec "github.com/aQuaYi/Distributed-Algorithms/Spec/code"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ModelCheck(t *testing.T) {
+	ast := assert.New(t)
+	//
+	for _, c := range []ModelConfig{
+		{Processes: 2, Requests: 3},
+		{Processes: 3, Requests: 1},
+	} {
+		name := fmt.Sprintf("%d Process × %d 次", c.Processes, c.Requests)
+		t.Run(name, func(t *testing.T) {
+			res := ModelCheck(c)
+			ast.True(res.OK(), res.String())
+			ast.True(res.Complete)
+		})
+	}
+}
+
+func Test_ModelCheck_suppressAcks(t *testing.T) {
+	ast := assert.New(t)
+	//
+	for _, c := range []ModelConfig{
+		{Processes: 2, Requests: 3, SuppressAcks: true},
+		{Processes: 3, Requests: 1, SuppressAcks: true},
+	} {
+		res := ModelCheck(c)
+		ast.True(res.OK(), res.String())
+		ast.True(res.Complete)
+	}
+}
+
+func Test_ModelCheck_maxStates(t *testing.T) {
+	ast := assert.New(t)
+	//
+	res := ModelCheck(ModelConfig{Processes: 3, Requests: 2, MaxStates: 10000})
+	ast.True(res.OK(), res.String())
+	ast.False(res.Complete)
+	ast.Equal(10000, res.States)
+	ast.True(strings.Contains(res.String(), "未完成"))
+}
+
+func Test_ModelCheck_skipRule52(t *testing.T) {
+	ast := assert.New(t)
+	//
+	res := ModelCheck(ModelConfig{Processes: 3, Requests: 1, SkipRule52: true})
+	ast.False(res.OK())
+	ast.True(strings.HasPrefix(res.Violation, "AlwaysAtMostOneOccupier"))
+	// 广度优先搜索找到的是最短的反例
+	ast.Equal([]string{
+		"P0 申请 <T1:P0>",
+		"P1 申请 <T1:P1>",
+		"P2 收到 P1 的申请 <T1:P1>",
+		"P0 收到 P1 的申请 <T1:P1>，然后占用 <T1:P0>",
+		"P1 收到 P2 的确认 <T1:P1>，然后占用 <T1:P1>",
+	}, res.Schedule)
+	t.Log(res)
+}
+
+func Test_ModelCheck_nonFIFO(t *testing.T) {
+	ast := assert.New(t)
+	//
+	res := ModelCheck(ModelConfig{Processes: 2, Requests: 1, NonFIFO: true})
+	ast.False(res.OK())
+	ast.True(strings.Contains(res.String(), "反例"))
+	t.Log(res)
+}
+
+func Test_ModelCheck_properties(t *testing.T) {
+	ast := assert.New(t)
+	//
+	// P1 的申请不可能在 P0 之前被满足，这个错误的性质会被 checker 发现
+	res := ModelCheck(ModelConfig{
+		Processes: 2,
+		Requests:  1,
+		Properties: []spec.Property{
+			spec.Always("P0FirstOrNeverP1", func(state interface{}) bool {
+				s := state.(*Snapshot)
+				return s.Granted[1] == 0 || s.Granted[0] > 0
+			}),
+		},
+	})
+	ast.False(res.OK())
+	ast.True(strings.HasPrefix(res.Violation, "P0FirstOrNeverP1"), res.String())
+	//
+	res = ModelCheck(ModelConfig{
+		Processes:  2,
+		Requests:   2,
+		Properties: []spec.Property{AlwaysAtMostKOccupiers(1), EventuallyGranted(1, 2)},
+	})
+	ast.True(res.OK(), res.String())
+	// 只申请了 2 次，不可能被满足 3 次
+	res = ModelCheck(ModelConfig{
+		Processes:  2,
+		Requests:   2,
+		Properties: []spec.Property{EventuallyGranted(0, 3)},
+	})
+	ast.Equal("EventuallyGranted(P0#3): 直到运行结束，条件也没有被满足", res.Violation)
+}
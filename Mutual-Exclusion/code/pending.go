@@ -0,0 +1,95 @@
+package mutualexclusion
+
+import (
+	"time"
+
+	failuredetector "github.com/aQuaYi/Distributed-Algorithms/Failure-Detector/code"
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+)
+
+// track 记录 from 的申请 ts，返回 false，如果已经记录过了
+// pending 持有 ts 的一个引用，直到 untrack 或者 forget，
+// 不依赖 request queue 的引用，因为 enqueue 不一定会放入 ts
+// 每个 process 释放上一个申请以后才会再次申请，所以 from 还有别的申请时，那个申请的释放一定丢失了，
+// 用 ts 替换它，以免它永远留在 request queue 中
+func (p *process) track(from int, ts Timestamp) bool {
+	last, ok := p.pending[from]
+	if ok && last.IsEqual(ts) {
+		return false
+	}
+	if ok {
+		p.dequeue(last)
+		releaseTimestamp(last)
+	}
+	retainTimestamp(ts)
+	p.pending[from] = ts
+	return true
+}
+
+// untrack 删除 from 已经释放的申请 ts，并从 request queue 中删除它，返回 false，如果 ts 不是 from 还没有释放的申请
+// release 中的 timestamp 与 request queue 中的可能不是同一个值，所以要用 pending 中记录的值来删除
+func (p *process) untrack(from int, ts Timestamp) bool {
+	last, ok := p.pending[from]
+	if !ok || !last.IsEqual(ts) {
+		return false
+	}
+	delete(p.pending, from)
+	p.dequeue(last)
+	releaseTimestamp(last)
+	return true
+}
+
+// forget 在故障检测器确认 id 已经崩溃以后，在 event loop 中调用
+// 崩溃的 process 不会再释放它的申请，也不会再发出任何 message，
+// 不删除它的申请，或者继续等待它的 message，所有的 process 都会永远等下去
+func (p *process) forget(id int) {
+	if id == p.me || p.dead[id] {
+		return
+	}
+	if p.dead == nil {
+		p.dead = make(map[int]bool, 4)
+	}
+	p.dead[id] = true
+	if ts, ok := p.pending[id]; ok {
+		delete(p.pending, id)
+		p.dequeue(ts)
+		releaseTimestamp(ts)
+	}
+	p.receivedTime.Remove(id)
+	debugPrintf("%s 删除了崩溃的 P%d 后的 request queue 是 %s", p, id, p.requestQueue)
+	p.checkRule5()
+}
+
+// newProcessWithDetector 与 newKProcessWithEnv 相同，只是每隔 interval 询问一次 d，删除崩溃了的 process 的申请
+// 只有 P 才能保证被怀疑的 process 真的崩溃了，错误地删除一个正确的 process 的申请，会破坏 mutual exclusion
+func newProcessWithDetector(all, me int, r Resource, b bus, d failuredetector.Perfect, interval time.Duration, env sim.Env) Process {
+	p := newKProcessWithEnv(all, 1, me, r, b, env).(*process)
+	go p.watch(d, interval)
+	return p
+}
+
+// watch 把 d 新怀疑的 process 交给 event loop，event loop 退出时返回
+// 询问的间隔由 p.env 计时
+func (p *process) watch(d failuredetector.Perfect, interval time.Duration) {
+	ticker := p.env.NewTicker(interval)
+	defer ticker.Stop()
+	reported := make(map[int]bool, 4)
+	for {
+		select {
+		case <-p.stopped:
+			return
+		case <-ticker.C():
+		}
+		for _, id := range d.Suspected() {
+			if reported[id] {
+				continue
+			}
+			reported[id] = true
+			select {
+			case p.crashed <- id:
+			case <-p.stopped:
+				return
+			}
+		}
+	}
+}
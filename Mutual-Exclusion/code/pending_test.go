@@ -0,0 +1,152 @@
+package mutualexclusion
+
+import (
+	"testing"
+	"time"
+
+	failuredetector "github.com/aQuaYi/Distributed-Algorithms/Failure-Detector/code"
+	reliablechannel "github.com/aQuaYi/Distributed-Algorithms/Reliable-Channel/code"
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestProcess(all, me int, b bus) *process {
+	return &process{
+		all:          all,
+		me:           me,
+		k:            1,
+		resource:     newResource(1),
+		bus:          b,
+		clock:        newClock(sim.Env{}),
+		requestQueue: newRequestQueue(),
+		receivedTime: newReceivedTime(all, me),
+		pending:      make(map[int]Timestamp),
+		local:        make(chan processEvent, 1),
+	}
+}
+
+func Test_process_track(t *testing.T) {
+	ast := assert.New(t)
+	//
+	b := &recordBus{}
+	p := newTestProcess(3, 0, b)
+	p.handle(newMessage(requestResource, 3, 2, OTHERS, newTimestamp(3, 2)))
+	// 重复的申请，即使不是同一个值，也不会再次放入 request queue，也不会再次回复
+	p.handle(newMessage(requestResource, 3, 2, OTHERS, newTimestamp(3, 2)))
+	ast.Equal([]Timestamp{newTimestamp(3, 2)}, p.state().Queue)
+	ast.Equal(1, len(b.sent))
+	// 未知的释放什么也不做
+	p.handle(newMessage(releaseResource, 4, 2, OTHERS, newTimestamp(1, 2)))
+	p.handle(newMessage(releaseResource, 4, 1, OTHERS, newTimestamp(3, 1)))
+	ast.Equal([]Timestamp{newTimestamp(3, 2)}, p.state().Queue)
+	// 释放中的 timestamp 与申请中的不是同一个值，也能删除
+	p.handle(newMessage(releaseResource, 5, 2, OTHERS, newTimestamp(3, 2)))
+	ast.Empty(p.state().Queue)
+	ast.Empty(p.pending)
+	// 新的申请替换了释放丢失了的申请
+	p.handle(newMessage(requestResource, 6, 1, OTHERS, newTimestamp(6, 1)))
+	p.handle(newMessage(requestResource, 8, 1, OTHERS, newTimestamp(8, 1)))
+	ast.Equal([]Timestamp{newTimestamp(8, 1)}, p.state().Queue)
+}
+
+func Test_process_forget(t *testing.T) {
+	ast := assert.New(t)
+	//
+	b := &recordBus{}
+	p := newTestProcess(3, 0, b)
+	p.clock.Update(100)
+	// P2 的申请排在最前面，然后它崩溃了
+	p.handle(newMessage(requestResource, 1, 2, OTHERS, newTimestamp(1, 2)))
+	p.request()
+	p.handle(newMessage(acknowledgment, 200, 1, 0, p.requestTimestamp))
+	ast.False(p.isOccupying)
+	//
+	p.forget(2)
+	ast.True(p.isOccupying, "不再等待 P2 的释放和回复")
+	ast.Equal([]Timestamp{copyTimestamp(p.requestTimestamp)}, p.state().Queue)
+	// 崩溃了的 process 的 message 都会被丢弃
+	sent := len(b.sent)
+	p.handle(newMessage(requestResource, 300, 2, OTHERS, newTimestamp(300, 2)))
+	ast.Equal(sent, len(b.sent))
+	ast.Equal(1, len(p.state().Queue))
+	// 重复的通知和关于自己的通知什么也不做
+	p.forget(2)
+	p.forget(0)
+	ast.Equal(map[int]bool{2: true}, p.dead)
+}
+
+func Test_process_withDetector(t *testing.T) {
+	ast := assert.New(t)
+	//
+	const all = 3
+	config := failuredetector.Config{Interval: 2 * time.Millisecond, Timeout: 20 * time.Millisecond}
+	net := failuredetector.NewNetwork(reliablechannel.Fixed(time.Millisecond), 1)
+	b := newMailboxBus(all, 3*(all-1), Block)
+	defer b.close()
+	// P2 申请以后就崩溃了，既不会释放，也不会回复别人的申请
+	crashed := newTimestamp(1, 2)
+	b.send(newMessage(requestResource, 1, 2, OTHERS, crashed))
+	rsc := newResource(2)
+	ps := make([]Process, 2)
+	for i := range ps {
+		d := failuredetector.NewPerfect(i, []int{0, 1, 2}, net, config)
+		defer d.Stop()
+		ps[i] = newProcessWithDetector(all, i, rsc, b, d, config.Interval, config.Env)
+	}
+	for _, p := range ps {
+		p.Request()
+	}
+	done := make(chan struct{})
+	go func() {
+		rsc.wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("删除 P2 的申请以后，P0 和 P1 仍然没有占用资源")
+	}
+	for _, p := range ps {
+		for _, ts := range p.Snapshot().Queue {
+			ast.False(ts.IsEqual(crashed))
+		}
+	}
+}
+
+// suspecting 总是怀疑 ids 中的 process，只用于测试 watch
+type suspecting struct {
+	failuredetector.Perfect
+	ids []int
+}
+
+func (s suspecting) Suspected() []int {
+	return s.ids
+}
+
+func Test_process_watchEnv(t *testing.T) {
+	ast := assert.New(t)
+	//
+	clock := sim.NewVirtual(time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC))
+	p := newTestProcess(3, 0, &recordBus{})
+	p.env = sim.Deterministic(clock, 1)
+	p.stopped = make(chan struct{})
+	p.crashed = make(chan int)
+	defer close(p.stopped)
+	go p.watch(suspecting{ids: []int{2}}, time.Second)
+	for clock.Pending() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	// 虚拟时钟没有前进，watch 就不会询问故障检测器
+	select {
+	case id := <-p.crashed:
+		t.Fatalf("虚拟时钟没有前进，却收到了 P%d", id)
+	case <-time.After(20 * time.Millisecond):
+	}
+	clock.Advance(time.Second)
+	select {
+	case id := <-p.crashed:
+		ast.Equal(2, id)
+	case <-time.After(5 * time.Second):
+		t.Fatal("虚拟时钟前进了 interval，watch 却没有报告 P2")
+	}
+}
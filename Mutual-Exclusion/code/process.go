@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"sync"
 
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
 	"github.com/aQuaYi/observer"
 )
 
@@ -14,180 +15,439 @@ const OTHERS = -1
 type Process interface {
 	// Request 会申请占用资源
 	// 如果上次 Request 后，还没有占用并释放资源，会发生阻塞
-	// 非线程安全
+	// 非线程安全，同一个 Process 的 Request 只能在一个 goroutine 中调用
 	Request()
+	// Snapshot 返回 process 当前的内部状态
+	// 线程安全，可以在任何 goroutine 中调用
+	Snapshot() State
 }
 
+// process 的所有状态都只在它自己的 event loop 中读写
+// 收到的 message、Request 发出的申请和释放资源，都会变成 event loop 中依次处理的事件，
+// 所以除了 clock 等自带锁的组件以外，process 不需要任何锁。
 type process struct {
-	me int            // process 的 ID
-	wg sync.WaitGroup // 阻塞 Request() 用
+	all int            // process 的总数
+	me  int            // process 的 ID
+	k   int            // 最多可以有 k 个 process 同时占用资源
+	wg  sync.WaitGroup // 阻塞 Request() 用
+	env sim.Env        // 时间和随机数的来源
 
 	clock        Clock
 	resource     Resource
 	receivedTime ReceivedTime
 	requestQueue RequestQueue
+	bus          bus
+	members      *membership // 为 nil 时，process 在创建时就知道 process 的总数
 
-	mutex sync.Mutex
-	// 为了保证发送消息的原子性，
-	// 从生成 timestamp 开始到 prop.update 完成，这个过程需要上锁
-	prop observer.Property
-	// 操作以下属性，需要加锁
+	inbox   chan *message     // 收到的 message
+	local   chan processEvent // Request 和释放资源产生的本地事件
+	inspect chan chan State   // Snapshot 的请求，event loop 把 State 放入其中
+	stopped chan struct{}     // event loop 退出时关闭
+	crashed chan int          // 故障检测器新发现的崩溃了的 process
+
+	// 以下属性只能在 event loop 中读写
 	isOccupying      bool
 	requestTimestamp Timestamp
+	lastClock        int // 上一个事件处理完时的 clock，用于检查 clock 不会倒退
+	// pending 是其他 process 还没有释放的申请
+	// 每个 process 同时最多只有一个申请，所以重复收到的申请和未知的释放都可以被识别出来
+	pending map[int]Timestamp
+	dead    map[int]bool // 故障检测器确认已经崩溃的 process，不再处理它们的 message
+	// lastSent[i] 是最近一次发给 Pi 的 message 的时间，为 nil 时，不省略回复
+	lastSent []int
+	// violations 为 nil 时，违反不变式会让 event loop panic
+	// 否则把错误放入 violations 以后退出 event loop，panic 发生在 event loop 的 goroutine 中，测试无法捕获
+	violations chan<- error
 }
 
+// processEvent 是 process 的本地事件
+type processEvent int
+
+const (
+	eventRequest processEvent = iota // 申请资源
+	eventRelease                     // 释放资源
+)
+
 func (p *process) String() string {
 	return fmt.Sprintf("[%d]P%d", p.clock.Now(), p.me)
 }
 
 func newProcess(all, me int, r Resource, prop observer.Property) Process {
-	p := &process{
-		me:           me,
-		resource:     r,
-		prop:         prop,
-		clock:        newClock(),
-		requestQueue: newRequestQueue(),
-		receivedTime: newReceivedTime(all, me),
+	return newProcessWithBus(all, me, r, newObserverBus(prop))
+}
+
+// NewProcesses 返回 len(rs) 个 Lamport 算法的 process，Pi 占用 rs[i]
+// 它们通过同一个 observer.Property 广播 message，rs 中可以是同一个 Resource。
+// 这是在 package 以外使用 Process 的入口，例如 examples/printserver。
+func NewProcesses(rs []Resource) []Process {
+	return NewProcessesWithEnv(rs, sim.Env{})
+}
+
+// NewProcessesWithEnv 与 NewProcesses 一样，但是 process 从 env 读取时间和随机数
+func NewProcessesWithEnv(rs []Resource, env sim.Env) []Process {
+	prop := observer.NewProperty(nil)
+	ps := make([]Process, len(rs))
+	// 需要一口气同时生成，保证所有的 stream 都能从同样的位置开始观察
+	for i, r := range rs {
+		ps[i] = newKProcessWithEnv(len(rs), 1, i, r, newObserverBus(prop), env)
 	}
+	return ps
+}
 
-	p.Listening()
+func newProcessWithBus(all, me int, r Resource, b bus) Process {
+	return newKProcessWithBus(all, 1, me, r, b)
+}
+
+// newKProcessWithBus 返回 k-mutual-exclusion 算法中的 process
+// 最多可以有 k 个 process 同时占用 r，k 为 1 时就是 Lamport 的算法
+func newKProcessWithBus(all, k, me int, r Resource, b bus) Process {
+	return newKProcessWithQueue(all, k, me, r, b, newRequestQueue())
+}
 
+// newKProcessWithQueue 与 newKProcessWithBus 相同，只是使用 rq 作为 request queue
+func newKProcessWithQueue(all, k, me int, r Resource, b bus, rq RequestQueue) Process {
+	return newLamportProcess(all, k, me, r, b, rq, false, sim.Env{})
+}
+
+// newKProcessWithEnv 与 newKProcessWithBus 相同，只是 process 从 env 读取时间和随机数
+func newKProcessWithEnv(all, k, me int, r Resource, b bus, env sim.Env) Process {
+	return newLamportProcess(all, k, me, r, b, newRequestQueue(), false, env)
+}
+
+// newProcessSuppressingAcks 与 newProcessWithBus 相同，只是会省略不必要的回复
+func newProcessSuppressingAcks(all, me int, r Resource, b bus) Process {
+	return newLamportProcess(all, 1, me, r, b, newRequestQueue(), true, sim.Env{})
+}
+
+// newLamportProcess 创建并启动 process
+// suppressAcks 为 true 时，如果已经给申请方发送过时间晚于申请的 message，就不再回复这个申请。
+// Rule 5.2 只要求申请方收到每个 process 在申请之后发出的某一条 message，
+// 那条更晚的 message 已经满足了这个要求，回复就是多余的。
+func newLamportProcess(all, k, me int, r Resource, b bus, rq RequestQueue, suppressAcks bool, env sim.Env) Process {
+	p := makeLamportProcess(all, k, me, r, b, rq, suppressAcks, env)
+	p.Listening()
 	debugPrintf("%s 完成创建工作", p)
+	return p
+}
 
+// newKProcessWithViolations 与 newKProcessWithQueue 相同，只是把违反的不变式放入 violations，而不是 panic
+func newKProcessWithViolations(all, k, me int, r Resource, b bus, rq RequestQueue, violations chan<- error) Process {
+	p := makeLamportProcess(all, k, me, r, b, rq, false, sim.Env{})
+	p.violations = violations
+	p.Listening()
+	return p
+}
+
+// makeLamportProcess 创建 process，但是还没有启动 event loop
+func makeLamportProcess(all, k, me int, r Resource, b bus, rq RequestQueue, suppressAcks bool, env sim.Env) *process {
+	p := &process{
+		all:          all,
+		me:           me,
+		k:            k,
+		env:          env,
+		resource:     r,
+		bus:          b,
+		clock:        newClock(env),
+		requestQueue: rq,
+		receivedTime: newReceivedTime(all, me),
+		pending:      make(map[int]Timestamp, all),
+		inbox:        make(chan *message, all),
+		// 同一时刻最多只有一个申请或者一个释放在等待处理
+		local: make(chan processEvent, 1),
+	}
+	if suppressAcks {
+		p.lastSent = make([]int, all)
+	}
 	return p
 }
 
 func (p *process) Listening() {
+	p.inspect = make(chan chan State)
+	p.stopped = make(chan struct{})
+	p.crashed = make(chan int)
+
 	// stream 的观察起点位置，由上层调用 newProcess 的方式决定
-	// 在生成完所有的 process 后，再更新 prop，
+	// 在生成完所有的 process 后，再发送消息，
 	// 才能保证所有的 process 都能收到全部消息
-	stream := p.prop.Observe()
+	next := p.bus.receiver(p.me)
+	recycle := recycleFunc(p.bus)
 
 	debugPrintf("%s 获取了 stream 开始监听", p)
 
 	go func() {
 		for {
-			msg := stream.Next().(*message)
-			if msg.from == p.me ||
-				(msg.msgType == acknowledgment && msg.to != p.me) {
-				// 忽略不该看见的消息
+			msg, ok := next()
+			if !ok {
+				close(p.inbox)
+				return
+			}
+			// observerBus 会把所有的 message 发给每一个 process，
+			// 在交给 event loop 之前过滤掉，可以省去大部分的 goroutine 切换
+			if p.ignores(msg) {
+				recycle(msg)
 				continue
 			}
+			p.inbox <- msg
+		}
+	}()
 
-			p.updateTime(msg.from, msg.msgTime)
+	go p.loop(recycle)
+}
 
-			switch msg.msgType {
-			// case acknowledgment: 收到此类消息只用更新时钟，前面已经做了
-			case requestResource:
-				p.handleRequestMessage(msg)
-			case releaseResource:
-				p.handleReleaseMessage(msg)
+// loop 是 process 的 event loop，依次处理所有的事件，bus 关闭后退出
+func (p *process) loop(recycle func(*message)) {
+	defer close(p.stopped)
+	p.lastClock = p.clock.Now()
+	for {
+		select {
+		case msg, ok := <-p.inbox:
+			if !ok {
+				return
+			}
+			p.handle(msg)
+			recycle(msg)
+		case e := <-p.local:
+			if e == eventRequest {
+				p.request()
+			} else {
+				p.releaseResource()
 			}
-			p.checkRule5()
+		case id := <-p.crashed:
+			p.forget(id)
+		case reply := <-p.inspect:
+			reply <- p.state()
+			continue
 		}
-	}()
+		if v := p.invariantViolation(); v != "" {
+			err := fmt.Errorf("%s 违反了不变式：%s", p, v)
+			if p.violations == nil {
+				panic(err.Error())
+			}
+			p.violations <- err
+			return
+		}
+	}
 }
 
-func (p *process) updateTime(from, time int) {
-	p.mutex.Lock()
+// invariantViolation 在每个事件处理完以后，检查 process 的不变式，返回违反的不变式
+func (p *process) invariantViolation() string {
+	now := p.clock.Now()
+	if now < p.lastClock {
+		return fmt.Sprintf("clock 从 %d 倒退到了 %d", p.lastClock, now)
+	}
+	p.lastClock = now
+	if p.requestTimestamp == nil {
+		if p.isOccupying {
+			return "没有申请，却占用了资源"
+		}
+		return ""
+	}
+	if ts, ok := p.requestTimestamp.(*timestamp); !ok || ts.process != p.me {
+		return fmt.Sprintf("自己的申请 %s 不属于自己", p.requestTimestamp)
+	}
+	if !p.requestQueue.Contains(p.requestTimestamp) {
+		return fmt.Sprintf("申请 %s 不在 request queue 中", p.requestTimestamp)
+	}
+	if p.isOccupying && p.requestQueue.Rank(p.requestTimestamp) >= p.k {
+		return fmt.Sprintf("占用资源时，申请 %s 没有排在前 %d 位", p.requestTimestamp, p.k)
+	}
+	return ""
+}
+
+// handle 处理收到的一条 message
+func (p *process) handle(msg *message) {
+	if p.ignores(msg) {
+		return
+	}
+	if !p.isValid(msg) {
+		debugPrintf("%s 丢弃了不合法的 %s", p, msg)
+		return
+	}
+	if p.dead[msg.from] {
+		debugPrintf("%s 丢弃了已经崩溃的 P%d 的 %s", p, msg.from, msg)
+		return
+	}
+
+	if p.members != nil {
+		p.members.discover(msg.from)
+	}
+	p.updateTime(msg.from, msg.msgTime)
+
+	switch msg.msgType {
+	// case acknowledgment: 收到此类消息只用更新时钟，前面已经做了
+	case requestResource:
+		p.handleRequestMessage(msg)
+	case releaseResource:
+		p.handleReleaseMessage(msg)
+	case joinGroup:
+		p.members.handleJoin(p, msg)
+	case welcome:
+		p.members.handleWelcome(p, msg)
+	}
+	p.checkRule5()
+}
+
+// ignores 返回 true，如果 msg 是不该看见的消息
+// 只读取 msg 和不变的 p.me，所以可以在 event loop 以外调用
+func (p *process) ignores(msg *message) bool {
+	return msg.from == p.me ||
+		(msg.to != OTHERS && msg.to != p.me)
+}
 
+// isValid 检查 msg 的格式
+// 正常运行时不会出现不合法的 message，但是 process 不应该因为它们而崩溃
+func (p *process) isValid(msg *message) bool {
+	if msg.from < 0 || (p.members == nil && msg.from >= p.all) {
+		return false
+	}
+	switch msg.msgType {
+	case acknowledgment:
+		return true
+	case joinGroup, welcome:
+		return p.members != nil
+	case requestResource, releaseResource:
+		ts, ok := msg.timestamp.(*timestamp)
+		// request 和 release 中的 timestamp 一定属于发送方
+		return ok && ts != nil && ts.process == msg.from
+	default:
+		return false
+	}
+}
+
+func (p *process) updateTime(from, time int) {
 	// 收到消息的第一件，更新自己的 clock
 	p.clock.Update(time)
 	// 然后为了 Rule5(ii) 记录收到消息的时间
 	// NOTICE: 接收时间一定要是对方发出的时间
 	p.receivedTime.Update(from, time)
-
-	p.mutex.Unlock()
 }
 
 func (p *process) handleRequestMessage(msg *message) {
-
+	if !p.track(msg.from, msg.timestamp) {
+		debugPrintf("%s 忽略了重复的 %s", p, msg)
+		return
+	}
 	// rule 2.1: 把 msg.timestamp 放入自己的 requestQueue 当中
-	p.requestQueue.Push(msg.timestamp)
+	p.enqueue(msg.timestamp)
 
 	debugPrintf("%s 添加了 %s 后的 request queue 是 %s", p, msg.timestamp, p.requestQueue)
 
-	p.mutex.Lock()
+	// 已经发送过更晚的 message 时，对方的 Rule 5.2 不再需要回复
+	if p.lastSent != nil && p.lastSent[msg.from] > msg.timestamp.(*timestamp).time {
+		return
+	}
 
 	// rule 2.2: 给对方发送一条 acknowledge 消息
-	p.prop.Update(newMessage(
+	p.send(newMessage(
 		acknowledgment,
 		p.clock.Tick(),
 		p.me,
 		msg.from,
 		msg.timestamp,
 	))
-
-	p.mutex.Unlock()
 }
 
 func (p *process) handleReleaseMessage(msg *message) {
 	// rule 4: 从 request queue 中删除相应的申请
-	p.requestQueue.Remove(msg.timestamp)
+	if !p.untrack(msg.from, msg.timestamp) {
+		debugPrintf("%s 忽略了未知的 %s", p, msg)
+		return
+	}
 	debugPrintf("%s 删除了 %s 后的 request queue 是 %s", p, msg.timestamp, p.requestQueue)
 }
 
 func (p *process) checkRule5() {
-	p.mutex.Lock()
 	if p.isSatisfiedRule5() {
 		p.occupyResource()
 		go func() {
 			// process 释放资源的时机交给 goroutine 调度
-			p.releaseResource()
+			p.local <- eventRelease
 		}()
 	}
-	p.mutex.Unlock()
 }
 
 func (p *process) isSatisfiedRule5() bool {
-	// 利用 checkRule5 的锁进行锁定
 	return !p.isOccupying && // 还没有占领资源
 		p.requestTimestamp != nil && // 已经申请资源
-		p.requestTimestamp.IsEqual(p.requestQueue.Min()) && // Rule5.1 申请排在第一位
+		p.requestQueue.Rank(p.requestTimestamp) < p.k && // Rule5.1 申请排在前 k 位
 		p.requestTimestamp.IsBefore(p.receivedTime.Min()) // Rule5.2: 申请后，收到全部回复
 }
 
 func (p *process) occupyResource() {
-	// 利用 checkRule5 的锁进行锁定
 	debugPrintf("%s 准备占用资源 %s", p, p.requestQueue)
 	p.isOccupying = true
 	p.resource.Occupy(p.requestTimestamp)
 }
 
 func (p *process) releaseResource() {
-	p.mutex.Lock()
-
 	ts := p.requestTimestamp
 	// rule 3: 先释放资源
 	p.resource.Release(ts)
 	// rule 3: 在 requestQueue 中删除 ts
-	p.requestQueue.Remove(ts)
+	p.dequeue(ts)
 	// rule 3: 把释放的消息发送给其他 process
 	msg := newMessage(releaseResource, p.clock.Tick(), p.me, OTHERS, ts)
-	p.prop.Update(msg)
+	p.send(msg)
 	p.isOccupying = false
 	p.requestTimestamp = nil
-
-	p.mutex.Unlock()
+	// 释放消息和其他 process 的 request queue 还持有 ts，它们都放手以后，ts 才会被回收
+	releaseTimestamp(ts)
 
 	p.wg.Done()
 }
 
-func (p *process) Request() {
-	p.wg.Wait()
-	p.wg.Add(1)
-
-	p.mutex.Lock()
-
+// request 在 event loop 中处理 Request 发出的申请
+func (p *process) request() {
+	if p.members != nil && p.members.postpone() {
+		return
+	}
 	p.clock.Tick() // 做事之前，先更新 clock
-	ts := newTimestamp(p.clock.Now(), p.me)
+	// requestTimestamp 持有 ts 的第一个引用，释放资源以后放手
+	ts := getTimestamp(p.clock.Now(), p.me)
 	msg := newMessage(requestResource, p.clock.Now(), p.me, OTHERS, ts)
 	// Rule 1.1: 发送申请信息给其他的 process
-	p.prop.Update(msg)
+	p.send(msg)
 	// Rule 1.2: 把申请消息放入自己的 request queue
-	p.requestQueue.Push(ts)
+	p.enqueue(ts)
 	// 修改辅助属性，便于后续检查
 	p.requestTimestamp = ts
+}
+
+// enqueue 把 ts 放入 request queue，request queue 持有 ts，直到 dequeue
+func (p *process) enqueue(ts Timestamp) {
+	if p.requestQueue.Contains(ts) {
+		return
+	}
+	retainTimestamp(ts)
+	p.requestQueue.Push(ts)
+}
 
-	p.mutex.Unlock()
+// dequeue 从 request queue 中删除 ts，并放开 request queue 持有的 ts
+func (p *process) dequeue(ts Timestamp) {
+	if !p.requestQueue.Contains(ts) {
+		return
+	}
+	p.requestQueue.Remove(ts)
+	releaseTimestamp(ts)
+}
+
+// send 发送 msg，需要省略回复时，记录发给每个 process 的最新时间
+// 发送以后 msg 随时可能被回收，所以要先记录
+func (p *process) send(msg *message) {
+	if p.lastSent != nil {
+		if msg.to == OTHERS {
+			for i := range p.lastSent {
+				p.lastSent[i] = msg.msgTime
+			}
+		} else {
+			p.lastSent[msg.to] = msg.msgTime
+		}
+	}
+	p.bus.send(msg)
+}
+
+func (p *process) Request() {
+	p.wg.Wait()
+	p.wg.Add(1)
+	p.local <- eventRequest
 }
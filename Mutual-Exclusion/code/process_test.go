@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"log"
 	"testing"
+	"time"
 
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
 	"github.com/aQuaYi/observer"
 	"github.com/stretchr/testify/assert"
 )
@@ -65,7 +67,7 @@ func Test_process_String(t *testing.T) {
 	ast := assert.New(t)
 	//
 	me := 1
-	clock := newClock()
+	clock := newClock(sim.Env{})
 	p := &process{
 		me:    me,
 		clock: clock,
@@ -76,3 +78,90 @@ func Test_process_String(t *testing.T) {
 	actual := p.String()
 	ast.Equal(expected, actual)
 }
+
+func Test_process_invariantViolation(t *testing.T) {
+	ast := assert.New(t)
+	//
+	p := &process{
+		all:          3,
+		me:           1,
+		k:            1,
+		clock:        newClock(sim.Env{}),
+		requestQueue: newRequestQueue(),
+	}
+	ast.Equal("", p.invariantViolation())
+	//
+	p.isOccupying = true
+	ast.Equal("没有申请，却占用了资源", p.invariantViolation())
+	//
+	p.requestTimestamp = newTimestamp(p.clock.Now(), 2)
+	ast.Contains(p.invariantViolation(), "不属于自己")
+	//
+	p.requestTimestamp = newTimestamp(p.clock.Now(), 1)
+	ast.Contains(p.invariantViolation(), "不在 request queue 中")
+	// 队列中只有别人的申请时，自己的申请依然不在 request queue 中
+	p.requestQueue.Push(newTimestamp(0, 0))
+	ast.Contains(p.invariantViolation(), "不在 request queue 中")
+	// 与自己的申请相等的另一个值也不算
+	p.requestQueue.Push(newTimestamp(p.clock.Now(), 1))
+	ast.Contains(p.invariantViolation(), "不在 request queue 中")
+	//
+	p.requestQueue = newRequestQueue()
+	p.requestQueue.Push(newTimestamp(0, 0))
+	p.requestQueue.Push(p.requestTimestamp)
+	ast.Contains(p.invariantViolation(), "没有排在前 1 位")
+	p.k = 2
+	ast.Equal("", p.invariantViolation())
+	//
+	p.lastClock = p.clock.Now() + 1
+	ast.Contains(p.invariantViolation(), "倒退")
+}
+
+// droppingQueue 会丢掉 me 自己的申请
+type droppingQueue struct {
+	RequestQueue
+	me int
+}
+
+func (q droppingQueue) Push(ls Less) {
+	if ls.(*timestamp).process != q.me {
+		q.RequestQueue.Push(ls)
+	}
+}
+
+func Test_process_violations(t *testing.T) {
+	ast := assert.New(t)
+	//
+	violations := make(chan error, 1)
+	b := newMailboxBus(2, 6, Block)
+	defer b.close()
+	rsc := newResource(1)
+	newKProcessWithViolations(2, 1, 0, rsc, b, newRequestQueue(), violations)
+	p := newKProcessWithViolations(2, 1, 1, rsc, b, droppingQueue{RequestQueue: newRequestQueue(), me: 1}, violations)
+	go p.Request()
+	select {
+	case err := <-violations:
+		ast.Contains(err.Error(), "P1 违反了不变式")
+		ast.Contains(err.Error(), "不在 request queue 中")
+	case <-time.After(10 * time.Second):
+		t.Fatal("没有报告 P1 丢掉了自己的申请")
+	}
+}
+
+func Test_NewProcesses(t *testing.T) {
+	ast := assert.New(t)
+	//
+	all, times := 3, 5
+	rsc := newResource(all * times)
+	ps := NewProcesses([]Resource{rsc, rsc, rsc})
+	ast.Len(ps, all)
+	for _, p := range ps {
+		go func(p Process) {
+			for i := 0; i < times; i++ {
+				p.Request()
+			}
+		}(p)
+	}
+	rsc.wait()
+	ast.Len(rsc.timestamps, all*times)
+}
@@ -0,0 +1,112 @@
+package mutualexclusion
+
+import (
+	"fmt"
+	"sync"
+
+	spec "github.com/aQuaYi/Distributed-Algorithms/Spec/code"
+)
+
+// Snapshot 是检查 spec.Property 时使用的系统状态
+// 模拟运行和模型检查都会生成 Snapshot，所以同一组 Property 可以用在两个地方
+type Snapshot struct {
+	Occupiers []int // 正在占用资源的 process
+	Granted   []int // 各个 process 已经占用资源的次数
+	// Processes 是各个 process 的 State，只有 Lamport 算法的模型检查会填写，其他时候为 nil
+	Processes []State
+}
+
+// AlwaysAtMostOneOccupier 要求任何时候最多只有一个 process 占用资源
+func AlwaysAtMostOneOccupier() spec.Property {
+	return spec.Always("AlwaysAtMostOneOccupier", func(state interface{}) bool {
+		return len(state.(*Snapshot).Occupiers) <= 1
+	})
+}
+
+// AlwaysAtMostKOccupiers 要求任何时候最多只有 k 个 process 占用资源
+func AlwaysAtMostKOccupiers(k int) spec.Property {
+	name := fmt.Sprintf("AlwaysAtMost%dOccupiers", k)
+	return spec.Always(name, func(state interface{}) bool {
+		return len(state.(*Snapshot).Occupiers) <= k
+	})
+}
+
+// EventuallyGranted 要求 process 的第 nth 次申请最终会被满足，nth 从 1 开始
+func EventuallyGranted(process, nth int) spec.Property {
+	name := fmt.Sprintf("EventuallyGranted(P%d#%d)", process, nth)
+	return spec.Eventually(name, func(state interface{}) bool {
+		return state.(*Snapshot).Granted[process] >= nth
+	})
+}
+
+// defaultProperties 是 all 个 process 各申请 requests 次时，算法应该满足的性质
+func defaultProperties(all, requests int) []spec.Property {
+	res := []spec.Property{AlwaysAtMostOneOccupier()}
+	for p := 0; p < all; p++ {
+		for n := 1; n <= requests; n++ {
+			res = append(res, EventuallyGranted(p, n))
+		}
+	}
+	return res
+}
+
+// monitoredResource 在每次占用和释放资源时，检查系统是否满足 props
+// 它只记录发现的第一个错误，而不会 panic，被包装的 Resource 仍然会进行自己的检查
+type monitoredResource struct {
+	Resource
+	mutex    sync.Mutex
+	monitors spec.Monitors
+	snapshot Snapshot
+	err      error
+}
+
+func newMonitoredResource(r Resource, all int, props ...spec.Property) *monitoredResource {
+	return &monitoredResource{
+		Resource: r,
+		monitors: spec.NewMonitors(props...),
+		snapshot: Snapshot{Granted: make([]int, all)},
+	}
+}
+
+func (m *monitoredResource) Occupy(ts Timestamp) {
+	p := ts.(*timestamp).process
+	m.mutex.Lock()
+	m.snapshot.Occupiers = append(m.snapshot.Occupiers, p)
+	m.snapshot.Granted[p]++
+	m.step()
+	m.mutex.Unlock()
+
+	m.Resource.Occupy(ts)
+}
+
+func (m *monitoredResource) Release(ts Timestamp) {
+	m.Resource.Release(ts)
+
+	p := ts.(*timestamp).process
+	m.mutex.Lock()
+	for i, o := range m.snapshot.Occupiers {
+		if o == p {
+			m.snapshot.Occupiers = append(m.snapshot.Occupiers[:i], m.snapshot.Occupiers[i+1:]...)
+			break
+		}
+	}
+	m.step()
+	m.mutex.Unlock()
+}
+
+// step 需要在持有 m.mutex 时调用
+func (m *monitoredResource) step() {
+	if m.err == nil {
+		m.err = m.monitors.Step(&m.snapshot)
+	}
+}
+
+// done 在运行结束后调用，返回运行中发现的第一个错误
+func (m *monitoredResource) done() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.err == nil {
+		m.err = m.monitors.Done()
+	}
+	return m.err
+}
@@ -0,0 +1,48 @@
+package mutualexclusion
+
+import (
+	"testing"
+
+	"github.com/aQuaYi/observer"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_monitoredResource(t *testing.T) {
+	ast := assert.New(t)
+	//
+	all, times := 4, 50
+	rsc := newResource(all * times)
+	m := newMonitoredResource(rsc, all, defaultProperties(all, times)...)
+	prop := observer.NewProperty(nil)
+	ps := make([]Process, all)
+	for i := range ps {
+		ps[i] = newProcess(all, i, m, prop)
+	}
+	for _, p := range ps {
+		go func(p Process) {
+			for i := 0; i < times; i++ {
+				p.Request()
+			}
+		}(p)
+	}
+	rsc.wait()
+	ast.Nil(m.done())
+}
+
+func Test_monitoredResource_violation(t *testing.T) {
+	ast := assert.New(t)
+	//
+	m := newMonitoredResource(newSemaphore(2, 2), 2, AlwaysAtMostOneOccupier(), EventuallyGranted(1, 2))
+	ts0, ts1 := newTimestamp(1, 0), newTimestamp(1, 1)
+	m.Occupy(ts0)
+	m.Occupy(ts1)
+	m.Release(ts0)
+	m.Release(ts1)
+	// 只记录第一个错误
+	ast.Equal("AlwaysAtMostOneOccupier: 出现了不满足条件的状态", m.done().Error())
+	//
+	m = newMonitoredResource(newResource(1), 2, EventuallyGranted(1, 2))
+	m.Occupy(ts1)
+	m.Release(ts1)
+	ast.Equal("EventuallyGranted(P1#2): 直到运行结束，条件也没有被满足", m.done().Error())
+}
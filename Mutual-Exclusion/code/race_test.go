@@ -0,0 +1,64 @@
+//go:build race
+
+package mutualexclusion
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aQuaYi/observer"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test_process_race 只在 go test -race 时运行，test.sh race 会运行它
+// 让多组 process 在不同的 bus 上同时运行，由 race detector 检查数据竞争，
+// 由 process 的 event loop 检查每个事件之后的不变式，违反的不变式通过 violations 报告给测试
+func Test_process_race(t *testing.T) {
+	ast := assert.New(t)
+	//
+	all, times := 8, 30
+	buses := map[string]func() bus{
+		"observer": func() bus { return newObserverBus(observer.NewProperty(nil)) },
+		"mailbox":  func() bus { return newMailboxBus(all, 3*(all-1), Block) },
+	}
+	var wg sync.WaitGroup
+	for name, newBus := range buses {
+		for k := 1; k <= 3; k++ {
+			wg.Add(1)
+			go func(name string, k int, b bus) {
+				defer wg.Done()
+				violations := make(chan error, all)
+				s := newSemaphore(k, all*times)
+				ps := make([]Process, all)
+				for i := range ps {
+					ps[i] = newKProcessWithViolations(all, k, i, s, b, newRequestQueue(), violations)
+				}
+				for _, p := range ps {
+					go func(p Process) {
+						for i := 0; i < times; i++ {
+							p.Request()
+						}
+					}(p)
+				}
+				done := make(chan struct{})
+				go func() {
+					s.wait()
+					close(done)
+				}()
+				select {
+				case <-done:
+				case err := <-violations:
+					ast.Fail(fmt.Sprintf("%s k=%d", name, k), err.Error())
+					return
+				case <-time.After(time.Minute):
+					ast.Fail(fmt.Sprintf("%s k=%d", name, k), "超时")
+					return
+				}
+				ast.True(s.maxOccupying <= k, fmt.Sprintf("%s k=%d", name, k))
+			}(name, k, newBus())
+		}
+	}
+	wg.Wait()
+}
@@ -0,0 +1,153 @@
+package mutualexclusion
+
+import (
+	"fmt"
+	"sync"
+)
+
+// binaryTree 返回 n 个 process 组成的平衡二叉树，tree[i] 是 Pi 的父节点，根节点 P0 的父节点为 -1
+func binaryTree(n int) []int {
+	tree := make([]int, n)
+	for i := range tree {
+		tree[i] = (i - 1) / 2
+	}
+	tree[0] = -1
+	return tree
+}
+
+// raymondProcess 是 Raymond 算法中的 process
+// 所有的 process 组成一棵树，只有持有 token 的 process 才能占用资源，token 只沿着树的边传递。
+// 每个 process 只知道 holder，也就是在树上通往 token 的方向的邻居，
+// 并用一个本地的 FIFO 队列，记录向自己申请 token 的邻居，自己申请时，把自己也放入队列。
+// 申请和 token 都只在树上的一条路径上传递，平衡的树中，每次占用资源只需要 O(log N) 条 message。
+type raymondProcess struct {
+	me       int
+	wg       sync.WaitGroup // 阻塞 Request() 用
+	resource Resource
+	bus      bus
+
+	mutex  sync.Mutex
+	holder int            // 通往 token 的邻居，持有 token 时是自己
+	asked  bool           // 是否已经向 holder 申请过 token
+	queue  []raymondEntry // 向自己申请 token 的邻居，以及自己
+	using  bool           // 是否正在占用资源
+	times  int            // 申请资源的次数
+	ts     Timestamp      // 自己当前的申请
+}
+
+// raymondEntry 是 raymondProcess 队列中的一项
+// 算法本身只需要 from，ts 是这一项最初的申请，只用来统计 message 所服务的申请
+type raymondEntry struct {
+	from int
+	ts   Timestamp
+}
+
+func (p *raymondProcess) String() string {
+	return fmt.Sprintf("P%d(holder:P%d)", p.me, p.holder)
+}
+
+// newRaymondProcess 返回树 tree 中的 Pme，tree 的根节点持有 token
+// 与 Lamport 算法不同，占用资源的顺序与 timestamp 无关，r 只能检查同时占用的数量
+func newRaymondProcess(tree []int, me int, r Resource, b bus) Process {
+	p := &raymondProcess{
+		me:       me,
+		resource: r,
+		bus:      b,
+		holder:   tree[me],
+	}
+	if p.holder < 0 {
+		p.holder = me
+	}
+
+	next := p.bus.receiver(p.me)
+	recycle := recycleFunc(p.bus)
+	go func() {
+		for {
+			msg, ok := next()
+			if !ok {
+				return
+			}
+			p.handle(msg)
+			recycle(msg)
+		}
+	}()
+
+	return p
+}
+
+// handle 处理收到的一条 message
+func (p *raymondProcess) handle(msg *message) {
+	if msg.to != p.me || msg.from == p.me {
+		// observerBus 会把 message 广播给所有的 process
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	switch msg.msgType {
+	case tokenRequest:
+		p.queue = append(p.queue, raymondEntry{from: msg.from, ts: msg.timestamp})
+	case tokenPrivilege:
+		p.holder = p.me
+	default:
+		debugPrintf("%s 丢弃了不合法的 %s", p, msg)
+		return
+	}
+	p.assignPrivilege()
+	p.makeRequest()
+}
+
+// assignPrivilege 在 p.mutex 的保护下，把空闲的 token 交给队首
+func (p *raymondProcess) assignPrivilege() {
+	if p.holder != p.me || p.using || len(p.queue) == 0 {
+		return
+	}
+	head := p.queue[0]
+	p.queue = p.queue[1:]
+	p.asked = false
+	if head.from == p.me {
+		p.using = true
+		p.resource.Occupy(p.ts)
+		go func() {
+			// process 释放资源的时机交给 goroutine 调度
+			p.releaseResource()
+		}()
+		return
+	}
+	p.holder = head.from
+	p.bus.send(newMessage(tokenPrivilege, 0, p.me, head.from, head.ts))
+}
+
+// makeRequest 在 p.mutex 的保护下，替队列中的申请向 holder 申请 token
+func (p *raymondProcess) makeRequest() {
+	if p.holder == p.me || len(p.queue) == 0 || p.asked {
+		return
+	}
+	p.asked = true
+	p.bus.send(newMessage(tokenRequest, 0, p.me, p.holder, p.queue[0].ts))
+}
+
+func (p *raymondProcess) releaseResource() {
+	p.mutex.Lock()
+
+	p.resource.Release(p.ts)
+	p.using = false
+	p.assignPrivilege()
+	p.makeRequest()
+
+	p.mutex.Unlock()
+
+	p.wg.Done()
+}
+
+func (p *raymondProcess) Request() {
+	p.wg.Wait()
+	p.wg.Add(1)
+
+	p.mutex.Lock()
+	p.times++
+	p.ts = newTimestamp(p.times, p.me)
+	p.queue = append(p.queue, raymondEntry{from: p.me, ts: p.ts})
+	p.assignPrivilege()
+	p.makeRequest()
+	p.mutex.Unlock()
+}
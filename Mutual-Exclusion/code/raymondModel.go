@@ -0,0 +1,183 @@
+package mutualexclusion
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// rmEntry 是 rmProcess 队列中的一项，request 是这一项最初的申请
+type rmEntry struct {
+	from    int
+	request string
+}
+
+// rmMessage 是模型中 Raymond 算法的 message，request 是它所服务的申请
+type rmMessage struct {
+	msgType msgType
+	request string
+}
+
+// rmProcess 是模型中 raymondProcess 的状态
+type rmProcess struct {
+	holder     int
+	asked      bool
+	queue      []rmEntry
+	using      bool
+	requesting bool
+	remaining  int // 还需要申请的次数
+}
+
+// rmState 是 Raymond 算法中整个系统的状态
+// channels[from*n+to] 是从 from 发往 to 的、还没有送达的 message
+type rmState struct {
+	procs    []rmProcess
+	channels [][]rmMessage
+	requests int
+}
+
+func newRMState(c ModelConfig, tree []int) *rmState {
+	n := c.Processes
+	s := &rmState{
+		procs:    make([]rmProcess, n),
+		channels: make([][]rmMessage, n*n),
+		requests: c.Requests,
+	}
+	for i := range s.procs {
+		s.procs[i] = rmProcess{holder: tree[i], remaining: c.Requests}
+		if tree[i] < 0 {
+			s.procs[i].holder = i
+		}
+	}
+	return s
+}
+
+func (s *rmState) send(from, to int, msg rmMessage) {
+	i := from*len(s.procs) + to
+	s.channels[i] = append(s.channels[i], msg)
+}
+
+// assignPrivilege 与 raymondProcess.assignPrivilege 相同，返回 true 表示 Pi 开始占用资源
+func (s *rmState) assignPrivilege(i int) bool {
+	p := &s.procs[i]
+	if p.holder != i || p.using || len(p.queue) == 0 {
+		return false
+	}
+	head := p.queue[0]
+	p.queue = p.queue[1:]
+	p.asked = false
+	if head.from == i {
+		p.using = true
+		return true
+	}
+	p.holder = head.from
+	s.send(i, head.from, rmMessage{msgType: tokenPrivilege, request: head.request})
+	return false
+}
+
+// makeRequest 与 raymondProcess.makeRequest 相同
+func (s *rmState) makeRequest(i int) {
+	p := &s.procs[i]
+	if p.holder == i || len(p.queue) == 0 || p.asked {
+		return
+	}
+	p.asked = true
+	s.send(i, p.holder, rmMessage{msgType: tokenRequest, request: p.queue[0].request})
+}
+
+func (s *rmState) actions(c ModelConfig) []mcAction {
+	var res []mcAction
+	n := len(s.procs)
+	for i, p := range s.procs {
+		if !p.requesting && p.remaining > 0 {
+			res = append(res, mcAction{kind: "request", process: i})
+		}
+		if p.using {
+			res = append(res, mcAction{kind: "release", process: i})
+		}
+		for from := 0; from < n; from++ {
+			deliverable := len(s.channels[from*n+i])
+			if !c.NonFIFO && deliverable > 1 {
+				deliverable = 1
+			}
+			for k := 0; k < deliverable; k++ {
+				res = append(res, mcAction{kind: "deliver", process: i, from: from, index: k})
+			}
+		}
+	}
+	return res
+}
+
+func (s *rmState) apply(a mcAction, c ModelConfig) string {
+	p := &s.procs[a.process]
+	var step string
+	switch a.kind {
+	case "request":
+		p.requesting = true
+		p.remaining--
+		p.queue = append(p.queue, rmEntry{from: a.process, request: s.request(a.process)})
+		step = fmt.Sprintf("P%d 申请 %s", a.process, s.request(a.process))
+	case "release":
+		p.using = false
+		p.requesting = false
+		step = fmt.Sprintf("P%d 释放 %s", a.process, s.request(a.process))
+	default:
+		i := a.from*len(s.procs) + a.process
+		msg := s.channels[i][a.index]
+		s.channels[i] = append(s.channels[i][:a.index], s.channels[i][a.index+1:]...)
+		if msg.msgType == tokenRequest {
+			p.queue = append(p.queue, rmEntry{from: a.from, request: msg.request})
+		} else {
+			p.holder = a.process
+		}
+		step = fmt.Sprintf("P%d 收到 P%d 的%s", a.process, a.from, msg.msgType)
+	}
+	if s.assignPrivilege(a.process) {
+		step += fmt.Sprintf("，然后占用 %s", s.request(a.process))
+	}
+	s.makeRequest(a.process)
+	return step
+}
+
+func (s *rmState) message(a mcAction) (msgType, string) {
+	msg := s.channels[a.from*len(s.procs)+a.process][a.index]
+	return msg.msgType, msg.request
+}
+
+func (s *rmState) snapshot(requests int) *Snapshot {
+	res := &Snapshot{Granted: make([]int, len(s.procs))}
+	for i, p := range s.procs {
+		if p.using {
+			res.Occupiers = append(res.Occupiers, i)
+		}
+		res.Granted[i] = requests - p.remaining
+		if p.requesting && !p.using {
+			res.Granted[i]--
+		}
+	}
+	return res
+}
+
+func (s *rmState) finished() bool {
+	for _, p := range s.procs {
+		if p.requesting || p.remaining > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *rmState) occupying(i int) bool {
+	return s.procs[i].using
+}
+
+// request 用 Pi#k 表示 Pi 的第 k 次申请，与 EventuallyGranted 的名称一致
+func (s *rmState) request(i int) string {
+	return fmt.Sprintf("P%d#%d", i, s.requests-s.procs[i].remaining)
+}
+
+// SimulateRaymond 与 Simulate 一样，用 seed 生成随机的调度，运行 Raymond 算法
+// c.Processes 个 process 组成平衡二叉树，P0 是根节点，一开始持有 token。c.SkipRule52 不起作用。
+func SimulateRaymond(c ModelConfig, seed int64) *SimulationResult {
+	s := newRMState(c, binaryTree(c.Processes))
+	return simulateModel(c, s, randomChoices(c, rand.New(rand.NewSource(seed))))
+}
@@ -0,0 +1,155 @@
+package mutualexclusion
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	reliablechannel "github.com/aQuaYi/Distributed-Algorithms/Reliable-Channel/code"
+	"github.com/aQuaYi/observer"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_binaryTree(t *testing.T) {
+	ast := assert.New(t)
+	//
+	ast.Equal([]int{-1}, binaryTree(1))
+	ast.Equal([]int{-1, 0, 0, 1, 1, 2, 2}, binaryTree(7))
+}
+
+// countingBus 统计经过 bus 发送的 message 数量
+type countingBus struct {
+	bus
+	mutex sync.Mutex
+	sent  int
+}
+
+func (b *countingBus) send(msg *message) {
+	b.mutex.Lock()
+	b.sent++
+	b.mutex.Unlock()
+	b.bus.send(msg)
+}
+
+func runRaymond(all, occupyTimesPerProcess int, b bus) {
+	s := newSemaphore(1, all*occupyTimesPerProcess)
+	tree := binaryTree(all)
+	ps := make([]Process, all)
+	for i := range ps {
+		ps[i] = newRaymondProcess(tree, i, s, b)
+	}
+	for _, p := range ps {
+		go func(p Process, times int) {
+			for i := 0; i < times; i++ {
+				p.Request()
+			}
+		}(p, occupyTimesPerProcess)
+	}
+	s.wait()
+}
+
+func Test_raymondProcess(t *testing.T) {
+	ast := assert.New(t)
+	//
+	times := 100
+	for _, all := range []int{1, 2, 7, 15} {
+		name := fmt.Sprintf("%d Process × %d 次", all, times)
+		t.Run(name, func(t *testing.T) {
+			b := &countingBus{bus: newObserverBus(observer.NewProperty(nil))}
+			ast.NotPanics(func() {
+				runRaymond(all, times, b)
+			})
+			// 每次占用，申请和 token 最多各走一遍树的直径
+			depth := 0
+			for n := all; n > 1; n /= 2 {
+				depth++
+			}
+			ast.True(b.sent <= all*times*2*2*depth, "%d 条 message", b.sent)
+		})
+	}
+}
+
+func Test_raymondProcess_overLossyNetwork(t *testing.T) {
+	ast := assert.New(t)
+	//
+	all := 7
+	net := reliablechannel.NewLossyNetwork(0.1, 0.1, time.Millisecond)
+	var b multiBus
+	for i := 0; i < all; i++ {
+		ep := reliablechannel.NewEndpoint(i, net, 5*time.Millisecond)
+		defer ep.Close()
+		b = append(b, newReliableBus(all, ep))
+	}
+	ast.NotPanics(func() {
+		runRaymond(all, 20, b)
+	})
+}
+
+// multiBus 让每个 process 使用自己的 reliableBus
+type multiBus []bus
+
+func (b multiBus) send(msg *message) {
+	b[msg.from].send(msg)
+}
+
+func (b multiBus) receiver(me int) func() (*message, bool) {
+	return b[me].receiver(me)
+}
+
+func Test_raymondProcess_handleInvalid(t *testing.T) {
+	ast := assert.New(t)
+	//
+	b := &recordBus{}
+	p := newRaymondProcess(binaryTree(3), 1, newSemaphore(1, 0), b).(*raymondProcess)
+	p.handle(newMessage(requestResource, 0, 0, 1, newTimestamp(0, 0)))
+	p.handle(newMessage(tokenRequest, 0, 2, 0, nil))
+	ast.Empty(b.sent)
+	// 没有 token 时，替 P2 向 holder 申请，而且只申请一次
+	p.handle(newMessage(tokenRequest, 0, 2, 1, nil))
+	p.handle(newMessage(tokenRequest, 0, 0, 1, nil))
+	ast.Equal(1, len(b.sent))
+	ast.Equal(tokenRequest, b.sent[0].msgType)
+	ast.Equal(0, b.sent[0].to)
+	// 收到 token 后，交给队首的 P2，再替队列中的 P0 申请
+	p.handle(newMessage(tokenPrivilege, 0, 0, 1, nil))
+	ast.Equal(3, len(b.sent))
+	ast.Equal(tokenPrivilege, b.sent[1].msgType)
+	ast.Equal(2, b.sent[1].to)
+	ast.Equal(tokenRequest, b.sent[2].msgType)
+	ast.Equal(2, b.sent[2].to)
+}
+
+func Test_SimulateRaymond(t *testing.T) {
+	ast := assert.New(t)
+	//
+	for seed := int64(0); seed < 20; seed++ {
+		c := ModelConfig{Processes: 7, Requests: 5}
+		res := SimulateRaymond(c, seed)
+		ast.True(res.OK(), res.String())
+		ast.Equal(35, len(res.Grants))
+		// 树的直径是 4
+		ast.True(res.Messages <= 35*2*4)
+		ast.Equal(res, SimulateRaymond(c, seed))
+	}
+	// Raymond 算法不依赖 FIFO 通道
+	for seed := int64(0); seed < 20; seed++ {
+		res := SimulateRaymond(ModelConfig{Processes: 5, Requests: 3, NonFIFO: true}, seed)
+		ast.True(res.OK(), res.String())
+	}
+}
+
+func Test_SimulateRaymond_fewerMessages(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := ModelConfig{Processes: 31, Requests: 3}
+	lamport := Simulate(c, 0)
+	raymond := SimulateRaymond(c, 0)
+	ast.True(lamport.OK())
+	ast.True(raymond.OK())
+	perEntry := func(r *SimulationResult) float64 {
+		return float64(r.Messages) / float64(len(r.Grants))
+	}
+	ast.Equal(float64(3*30), perEntry(lamport))
+	ast.True(perEntry(raymond) < 2*8, "%.1f", perEntry(raymond))
+}
@@ -12,6 +12,8 @@ type ReceivedTime interface {
 	Update(process, time int)
 	// Min 返回从各个 process 接收时间的最小值
 	Min() int
+	// Remove 不再记录 process 的接收时间，Min 也不再考虑它
+	Remove(process int)
 }
 
 type receivedTime struct {
@@ -42,13 +44,43 @@ func (rt *receivedTime) Update(id, time int) {
 	rt.mutex.Unlock()
 }
 
-// 返回 rt 中的最小值
+// 返回 rt 中的最小值，还没有其他 process 时，返回最大的 int
 func (rt *receivedTime) Min() int {
 	rt.mutex.Lock()
 	defer rt.mutex.Unlock()
+	if len(*rt.trq) == 0 {
+		return int(^uint(0) >> 1)
+	}
 	return (*rt.trq)[0].time
 }
 
+// add 开始记录从 id 接收到的时间，已经在记录的 id 什么也不做
+// 只有不知道 process 总数的 process 才需要在运行中添加
+func (rt *receivedTime) add(id int) {
+	rt.mutex.Lock()
+	defer rt.mutex.Unlock()
+	for len(rt.trs) <= id {
+		rt.trs = append(rt.trs, nil)
+	}
+	if rt.trs[id] != nil {
+		return
+	}
+	rt.trs[id] = &timeRecord{}
+	heap.Push(rt.trq, rt.trs[id])
+}
+
+// Remove 在故障检测器确认 id 已经崩溃以后调用，Rule5(ii) 不再等待它的 message
+// 没有在记录的 id 什么也不做
+func (rt *receivedTime) Remove(id int) {
+	rt.mutex.Lock()
+	defer rt.mutex.Unlock()
+	if id >= len(rt.trs) || rt.trs[id] == nil {
+		return
+	}
+	heap.Remove(rt.trq, rt.trs[id].index)
+	rt.trs[id] = nil
+}
+
 // timeRecord 是 priorityQueue 中的元素
 type timeRecord struct {
 	time  int
@@ -41,3 +41,34 @@ func Test_timeRecordQueue_Pop(t *testing.T) {
 	actual := heap.Pop(trq).(*timeRecord)
 	ast.Equal(expected.time, actual.time)
 }
+
+func Test_receivedTime_add(t *testing.T) {
+	ast := assert.New(t)
+	rt := &receivedTime{trq: new(timeRecordQueue)}
+	// 还没有其他 process 时，任何申请都满足 Rule5(ii)
+	ast.True(newTimestamp(1<<40, 0).IsBefore(rt.Min()))
+	rt.add(3)
+	ast.Equal(0, rt.Min())
+	rt.Update(3, 5)
+	// 重复添加不会重置接收时间
+	rt.add(3)
+	ast.Equal(5, rt.Min())
+	rt.add(1)
+	ast.Equal(0, rt.Min())
+}
+
+func Test_receivedTime_Remove(t *testing.T) {
+	ast := assert.New(t)
+	rt := newReceivedTime(3, 0)
+	rt.Update(1, 5)
+	ast.Equal(0, rt.Min())
+	// 不再等待 P2 的 message
+	rt.Remove(2)
+	ast.Equal(5, rt.Min())
+	// 重复删除，或者删除没有记录的 process，什么也不做
+	rt.Remove(2)
+	rt.Remove(7)
+	ast.Equal(5, rt.Min())
+	rt.Remove(1)
+	ast.True(newTimestamp(1<<40, 0).IsBefore(rt.Min()))
+}
@@ -0,0 +1,184 @@
+package mutualexclusion
+
+import (
+	"encoding/json"
+	"fmt"
+
+	recovery "github.com/aQuaYi/Distributed-Algorithms/Rollback-Recovery/code"
+)
+
+// inputRequest 是发给 recoverableProcess 的申请
+var inputRequest = []byte("request")
+
+// releaseInput 返回释放 ts 的外部输入
+// 回滚以后，外部世界会重新发送 checkpoint 以后的输入，带着 ts 才能忽略已经被回滚了的占用的释放
+func releaseInput(ts timestamp) []byte {
+	return []byte(fmt.Sprintf("release %d", ts.time))
+}
+
+// recoverableProcess 是可以 checkpoint 和恢复的 Lamport 算法的 process，运行在 recovery.System 上
+// 它复用了模型中的 mcProcess，所有的状态都是值，处理 message 的过程是确定性的，
+// 所以崩溃以后，重放 message log 就能回到崩溃前的状态。
+// 申请和释放都是外部输入，同样会被写入 message log。
+type recoverableProcess struct {
+	me, n int
+	mcProcess
+	waiting  int // 还在排队的外部申请，上一次申请释放以后才会发出
+	released int // 提前收到释放的申请的时间
+}
+
+// recoverableState 是 recoverableProcess 状态的编码
+type recoverableState struct {
+	Clock      int
+	Queue      [][2]int
+	Received   []int
+	Requesting bool
+	Request    [2]int
+	Occupying  bool
+	Waiting    int
+	Released   int
+}
+
+// recoverableMessage 是 recoverableProcess 之间的 message 的编码
+type recoverableMessage struct {
+	Type msgType
+	Time int
+	TS   [2]int
+}
+
+func newRecoverableProcess(n int) func(id int) recovery.Process {
+	return func(id int) recovery.Process {
+		return &recoverableProcess{
+			me:        id,
+			n:         n,
+			mcProcess: mcProcess{received: make([]int, n)},
+		}
+	}
+}
+
+func (p *recoverableProcess) Handle(from int, payload []byte) []recovery.Output {
+	var out []recovery.Output
+	if from == recovery.External {
+		out = p.input(payload)
+	} else {
+		var msg recoverableMessage
+		if err := json.Unmarshal(payload, &msg); err != nil {
+			return nil
+		}
+		out = p.receive(from, msg)
+	}
+	for p.canOccupy(p.me, false) {
+		p.occupying = true
+		if p.released != p.request.time {
+			break
+		}
+		// 回滚以后，外部世界重新发送的释放，可能先于重新得到的占用到达
+		out = append(out, p.input(releaseInput(p.request))...)
+	}
+	return out
+}
+
+// input 处理外部的申请和释放
+func (p *recoverableProcess) input(payload []byte) []recovery.Output {
+	var out []recovery.Output
+	var time int
+	if _, err := fmt.Sscanf(string(payload), "release %d", &time); err == nil {
+		out = p.releaseRequest(time)
+	} else {
+		p.waiting++
+	}
+	if !p.requesting && p.waiting > 0 {
+		p.waiting--
+		out = append(out, p.sendRequest()...)
+	}
+	return out
+}
+
+// broadcast 返回发给其他所有 process 的 msg
+func (p *recoverableProcess) broadcast(msg recoverableMessage) []recovery.Output {
+	payload, _ := json.Marshal(msg)
+	res := make([]recovery.Output, 0, p.n-1)
+	for to := 0; to < p.n; to++ {
+		if to != p.me {
+			res = append(res, recovery.Output{To: to, Payload: payload})
+		}
+	}
+	return res
+}
+
+func (p *recoverableProcess) sendRequest() []recovery.Output {
+	// Rule 1
+	p.clock++
+	p.request = timestamp{time: p.clock, process: p.me}
+	p.requesting = true
+	p.queue.push(p.request)
+	return p.broadcast(recoverableMessage{Type: requestResource, Time: p.clock, TS: [2]int{p.request.time, p.me}})
+}
+
+func (p *recoverableProcess) releaseRequest(time int) []recovery.Output {
+	if !p.requesting || p.request.time != time {
+		return nil
+	}
+	if !p.occupying {
+		p.released = time
+		return nil
+	}
+	// Rule 3
+	p.queue.remove(p.request)
+	p.clock++
+	p.occupying, p.requesting = false, false
+	return p.broadcast(recoverableMessage{Type: releaseResource, Time: p.clock, TS: [2]int{p.request.time, p.me}})
+}
+
+func (p *recoverableProcess) receive(from int, msg recoverableMessage) []recovery.Output {
+	if msg.Time+1 > p.clock {
+		p.clock = msg.Time + 1
+	}
+	p.received[from] = msg.Time
+	ts := timestamp{time: msg.TS[0], process: msg.TS[1]}
+	switch msg.Type {
+	case requestResource:
+		// Rule 2
+		p.queue.push(ts)
+		p.clock++
+		payload, _ := json.Marshal(recoverableMessage{Type: acknowledgment, Time: p.clock, TS: msg.TS})
+		return []recovery.Output{{To: from, Payload: payload}}
+	case releaseResource:
+		// Rule 4
+		p.queue.remove(ts)
+	}
+	return nil
+}
+
+func (p *recoverableProcess) Checkpoint() []byte {
+	s := recoverableState{
+		Clock:      p.clock,
+		Queue:      make([][2]int, len(p.queue)),
+		Received:   p.received,
+		Requesting: p.requesting,
+		Request:    [2]int{p.request.time, p.request.process},
+		Occupying:  p.occupying,
+		Waiting:    p.waiting,
+		Released:   p.released,
+	}
+	for i, ts := range p.queue {
+		s.Queue[i] = [2]int{ts.time, ts.process}
+	}
+	res, _ := json.Marshal(s)
+	return res
+}
+
+func (p *recoverableProcess) Restore(state []byte) error {
+	var s recoverableState
+	if err := json.Unmarshal(state, &s); err != nil {
+		return err
+	}
+	p.clock, p.received, p.requesting, p.occupying = s.Clock, s.Received, s.Requesting, s.Occupying
+	p.waiting, p.released = s.Waiting, s.Released
+	p.request = timestamp{time: s.Request[0], process: s.Request[1]}
+	p.queue = nil
+	for _, ts := range s.Queue {
+		p.queue = append(p.queue, timestamp{time: ts[0], process: ts[1]})
+	}
+	return nil
+}
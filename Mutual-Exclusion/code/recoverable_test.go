@@ -0,0 +1,132 @@
+package mutualexclusion
+
+import (
+	"math/rand"
+	"testing"
+
+	recovery "github.com/aQuaYi/Distributed-Algorithms/Rollback-Recovery/code"
+	"github.com/stretchr/testify/assert"
+)
+
+// runRecoverable 让 n 个 process 各申请 requests 次，fault 在每一步之前注入故障
+// 返回按顺序占用资源的申请，以及同时占用资源的最大 process 数量
+// 有 process 崩溃时，即使没有可以送达的 message，也继续运行，等待 fault 恢复它
+func runRecoverable(n, requests int, seed int64, fault func(s *recovery.System, step int)) ([]timestamp, int, *recovery.System) {
+	s := recovery.NewSystem(n, newRecoverableProcess(n))
+	for i := 0; i < n; i++ {
+		for j := 0; j < requests; j++ {
+			s.Input(i, inputRequest)
+		}
+	}
+	rnd := rand.New(rand.NewSource(seed))
+	granted := make(map[timestamp]bool)
+	var grants []timestamp
+	most := 0
+	for step := 0; ; step++ {
+		fault(s, step)
+		if !s.Step(rnd.Intn) && !crashed(s) {
+			break
+		}
+		occupiers := 0
+		for i := 0; i < n; i++ {
+			if s.Crashed(i) {
+				continue
+			}
+			p := s.Process(i).(*recoverableProcess)
+			if !p.occupying {
+				continue
+			}
+			occupiers++
+			// 恢复的 process 重放到占用资源时，外部世界已经发出过释放了
+			if !granted[p.request] {
+				granted[p.request] = true
+				grants = append(grants, p.request)
+				s.Input(i, releaseInput(p.request))
+			}
+		}
+		if occupiers > most {
+			most = occupiers
+		}
+	}
+	return grants, most, s
+}
+
+func crashed(s *recovery.System) bool {
+	for i := 0; i < s.Size(); i++ {
+		if s.Crashed(i) {
+			return true
+		}
+	}
+	return false
+}
+
+func Test_recoverable_noFault(t *testing.T) {
+	ast := assert.New(t)
+	//
+	grants, most, _ := runRecoverable(3, 3, 1, func(*recovery.System, int) {})
+	ast.Len(grants, 9)
+	ast.Equal(1, most)
+}
+
+func Test_recoverable_crashAndRecover(t *testing.T) {
+	ast := assert.New(t)
+	//
+	for seed := int64(0); seed < 20; seed++ {
+		var replayed int
+		grants, most, _ := runRecoverable(3, 3, seed, func(s *recovery.System, step int) {
+			switch step {
+			case 10:
+				ast.NoError(s.StartCheckpoint(0))
+			case 25:
+				s.Crash(1)
+			case 40:
+				n, err := s.Recover(1)
+				ast.NoError(err)
+				replayed = n
+			}
+		})
+		// 崩溃的 process 恢复以后，所有的申请依然按照全局排序依次占用资源
+		ast.Equal(1, most, "seed %d", seed)
+		ast.Len(grants, 9, "seed %d", seed)
+		for i := 1; i < len(grants); i++ {
+			ast.True(grants[i-1].before(grants[i]), "seed %d: %v", seed, grants)
+		}
+		ast.True(replayed > 0, "seed %d", seed)
+	}
+}
+
+func Test_recoverable_rollback(t *testing.T) {
+	ast := assert.New(t)
+	//
+	for seed := int64(0); seed < 20; seed++ {
+		_, most, s := runRecoverable(3, 2, seed, func(s *recovery.System, step int) {
+			if step == 5 {
+				ast.NoError(s.StartCheckpoint(2))
+			}
+			// checkpoint 完成以后，所有的 process 同时丢失了 log，只能回滚到 checkpoint
+			if step >= 30 && s.Last() != nil && s.Stats().Rollbacks == 0 {
+				ast.NoError(s.Rollback())
+			}
+		})
+		ast.Equal(1, most, "seed %d", seed)
+		// 回滚撤销了 checkpoint 以后的占用，但是最终所有的申请都被满足了
+		for i := 0; i < 3; i++ {
+			p := s.Process(i).(*recoverableProcess)
+			ast.False(p.requesting, "seed %d", seed)
+			ast.Equal(0, p.waiting, "seed %d", seed)
+		}
+		ast.Equal(1, s.Stats().Rollbacks, "seed %d", seed)
+	}
+}
+
+func Test_recoverableProcess_checkpoint(t *testing.T) {
+	ast := assert.New(t)
+	//
+	p := newRecoverableProcess(3)(0).(*recoverableProcess)
+	p.Handle(recovery.External, inputRequest)
+	p.Handle(recovery.External, inputRequest)
+	q := newRecoverableProcess(3)(0)
+	ast.NoError(q.Restore(p.Checkpoint()))
+	ast.Equal(p, q)
+	ast.Error(q.Restore([]byte("{")))
+}
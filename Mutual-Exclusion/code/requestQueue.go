@@ -2,6 +2,7 @@ package mutualexclusion
 
 import (
 	"container/heap"
+	"sort"
 	"strings"
 	"sync"
 )
@@ -10,17 +11,27 @@ import (
 type RequestQueue interface {
 	// Min 返回最小的 Timestamp 值
 	Min() Less
-	// Push 把元素加入 RequestQueue 中
+	// Push 把元素加入 RequestQueue 中，元素已经在 RequestQueue 中时，什么也不做
 	Push(Less)
-	// Remove 在 RequestQueue 中删除 Less
+	// Remove 在 RequestQueue 中删除 Less，Less 不在 RequestQueue 中时，什么也不做
 	Remove(Less)
+	// Rank 返回 RequestQueue 中排在 Less 前面的元素个数
+	Rank(Less) int
+	// Contains 判断 Less 本身是否在 RequestQueue 中，与它相等的其他元素不算
+	Contains(Less) bool
+	// Items 按照全局排序返回 RequestQueue 中的所有元素
+	Items() []Less
 	// String 输出 RequestQueue 的细节
 	String() string
 }
 
+// requestQueue 是基于堆的 RequestQueue
+// Push 和 Remove 都是 O(log n)：requestOf 记录了每个元素在堆中的位置，所以可以删除任意的元素。
+// Rank 需要与堆中的每个元素比较，是 O(n)。
 type requestQueue struct {
 	rpq       *requestPriorityQueue
 	requestOf map[Less]*request
+	free      []*request // 被删除的 request，Push 时重复使用，避免每次都在堆上分配
 	mutex     sync.Mutex
 }
 
@@ -42,8 +53,19 @@ func (rq *requestQueue) Min() Less {
 
 func (rq *requestQueue) Push(ls Less) {
 	rq.mutex.Lock()
-	r := &request{
-		ls: ls,
+	// 重复放入同一个元素，会让 requestOf 丢掉前一个元素在堆中的位置，它就再也删不掉了
+	if _, ok := rq.requestOf[ls]; ok {
+		rq.mutex.Unlock()
+		return
+	}
+	var r *request
+	if n := len(rq.free); n > 0 {
+		r, rq.free = rq.free[n-1], rq.free[:n-1]
+		r.ls = ls
+	} else {
+		r = &request{
+			ls: ls,
+		}
 	}
 
 	rq.requestOf[ls] = r
@@ -53,11 +75,48 @@ func (rq *requestQueue) Push(ls Less) {
 
 func (rq *requestQueue) Remove(ls Less) {
 	rq.mutex.Lock()
-	rq.rpq.remove(rq.requestOf[ls])
-	delete(rq.requestOf, ls)
+	// 删除不存在的元素时，什么也不做
+	if r, ok := rq.requestOf[ls]; ok {
+		rq.rpq.remove(r)
+		delete(rq.requestOf, ls)
+		r.ls = nil
+		rq.free = append(rq.free, r)
+	}
 	rq.mutex.Unlock()
 }
 
+func (rq *requestQueue) Rank(ls Less) int {
+	rq.mutex.Lock()
+	defer rq.mutex.Unlock()
+	// 堆中的元素只是部分有序，只能逐个比较
+	res := 0
+	for _, r := range *rq.rpq {
+		if r.ls.Less(ls) {
+			res++
+		}
+	}
+	return res
+}
+
+func (rq *requestQueue) Contains(ls Less) bool {
+	rq.mutex.Lock()
+	defer rq.mutex.Unlock()
+	_, ok := rq.requestOf[ls]
+	return ok
+}
+
+func (rq *requestQueue) Items() []Less {
+	rq.mutex.Lock()
+	res := make([]Less, 0, len(*rq.rpq))
+	for _, r := range *rq.rpq {
+		res = append(res, r.ls)
+	}
+	rq.mutex.Unlock()
+	// 堆中的元素只是部分有序
+	sort.Slice(res, func(i, j int) bool { return res[i].Less(res[j]) })
+	return res
+}
+
 func (rq *requestQueue) String() string {
 	return rq.rpq.String()
 }
@@ -119,3 +178,92 @@ func (q *requestPriorityQueue) Pop() interface{} {
 func (q *requestPriorityQueue) remove(r *request) {
 	heap.Remove(q, r.index)
 }
+
+// sliceRequestQueue 是用有序 slice 实现的 RequestQueue
+// Push 和 Remove 需要移动 O(n) 个元素，Rank 只需要 O(log n) 次比较。
+// 只用来与基于堆的 requestQueue 对比，队列很短时，两者的差别不大。
+type sliceRequestQueue struct {
+	items []Less // 按照全局排序排列
+	mutex sync.Mutex
+}
+
+func newSliceRequestQueue() RequestQueue {
+	return &sliceRequestQueue{}
+}
+
+// search 返回第一个不小于 ls 的元素的位置
+func (sq *sliceRequestQueue) search(ls Less) int {
+	return sort.Search(len(sq.items), func(i int) bool { return !sq.items[i].Less(ls) })
+}
+
+func (sq *sliceRequestQueue) Min() Less {
+	sq.mutex.Lock()
+	defer sq.mutex.Unlock()
+	if len(sq.items) == 0 {
+		return nil
+	}
+	return sq.items[0]
+}
+
+func (sq *sliceRequestQueue) Push(ls Less) {
+	sq.mutex.Lock()
+	i := sq.search(ls)
+	for j := i; j < len(sq.items) && !ls.Less(sq.items[j]); j++ {
+		if sq.items[j] == ls {
+			sq.mutex.Unlock()
+			return
+		}
+	}
+	sq.items = append(sq.items, nil)
+	copy(sq.items[i+1:], sq.items[i:])
+	sq.items[i] = ls
+	sq.mutex.Unlock()
+}
+
+func (sq *sliceRequestQueue) Remove(ls Less) {
+	sq.mutex.Lock()
+	// 删除不存在的元素时，什么也不做
+	// 与 requestQueue 一样，只删除 ls 本身，而不是与它相等的其他元素
+	for i := sq.search(ls); i < len(sq.items) && !ls.Less(sq.items[i]); i++ {
+		if sq.items[i] == ls {
+			sq.items = append(sq.items[:i], sq.items[i+1:]...)
+			break
+		}
+	}
+	sq.mutex.Unlock()
+}
+
+func (sq *sliceRequestQueue) Rank(ls Less) int {
+	sq.mutex.Lock()
+	defer sq.mutex.Unlock()
+	return sq.search(ls)
+}
+
+func (sq *sliceRequestQueue) Contains(ls Less) bool {
+	sq.mutex.Lock()
+	defer sq.mutex.Unlock()
+	for i := sq.search(ls); i < len(sq.items) && !ls.Less(sq.items[i]); i++ {
+		if sq.items[i] == ls {
+			return true
+		}
+	}
+	return false
+}
+
+func (sq *sliceRequestQueue) Items() []Less {
+	sq.mutex.Lock()
+	defer sq.mutex.Unlock()
+	return append([]Less(nil), sq.items...)
+}
+
+func (sq *sliceRequestQueue) String() string {
+	sq.mutex.Lock()
+	defer sq.mutex.Unlock()
+	var b strings.Builder
+	b.WriteString("{request queue:")
+	for _, ls := range sq.items {
+		b.WriteString(ls.String())
+	}
+	b.WriteString("}")
+	return b.String()
+}
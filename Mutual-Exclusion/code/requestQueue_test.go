@@ -1,6 +1,8 @@
 package mutualexclusion
 
 import (
+	"fmt"
+	"math/rand"
 	"strings"
 	"testing"
 
@@ -94,3 +96,179 @@ func Test_requestQueue_String(t *testing.T) {
 		ast.True(strings.Contains(rqs, tss))
 	}
 }
+
+func Test_requestQueue_Rank(t *testing.T) {
+	ast := assert.New(t)
+	//
+	half := 10
+	size := half * 2
+	tss := makeIncreasingTimestamps(half)
+	rq := newRequestQueue()
+	// 乱序放入
+	for i := 0; i < size; i += 2 {
+		rq.Push(tss[i])
+	}
+	for i := 1; i < size; i += 2 {
+		rq.Push(tss[i])
+	}
+	//
+	for i, ts := range tss {
+		ast.Equal(i, rq.Rank(ts))
+	}
+	rq.Remove(tss[0])
+	ast.Equal(size-2, rq.Rank(tss[size-1]))
+}
+
+func Test_requestQueue_reuse(t *testing.T) {
+	ast := assert.New(t)
+	//
+	tss := makeIncreasingTimestamps(4)
+	rq := newRequestQueue()
+	for _, ts := range tss {
+		rq.Push(ts)
+	}
+	for _, ts := range tss {
+		rq.Remove(ts)
+	}
+	// 被删除的 request 会被重复使用，不再分配内存
+	allocs := testing.AllocsPerRun(100, func() {
+		for _, ts := range tss {
+			rq.Push(ts)
+		}
+		for _, ts := range tss {
+			rq.Remove(ts)
+		}
+	})
+	ast.Equal(0.0, allocs)
+	ast.Nil(rq.Min())
+}
+
+func Test_sliceRequestQueue(t *testing.T) {
+	ast := assert.New(t)
+	// 随机地 Push 和 Remove，两种实现的结果必须相同
+	rnd := rand.New(rand.NewSource(1))
+	heapQueue, sliceQueue := newRequestQueue(), newSliceRequestQueue()
+	var inQueue []Timestamp
+	for i := 0; i < 2000; i++ {
+		if len(inQueue) == 0 || rnd.Intn(3) > 0 {
+			// 真实运行中的 timestamp 不会相等
+			ts := newTimestamp(rnd.Intn(100), i)
+			heapQueue.Push(ts)
+			sliceQueue.Push(ts)
+			inQueue = append(inQueue, ts)
+		} else {
+			k := rnd.Intn(len(inQueue))
+			ts := inQueue[k]
+			inQueue = append(inQueue[:k], inQueue[k+1:]...)
+			heapQueue.Remove(ts)
+			sliceQueue.Remove(ts)
+		}
+		ast.True(heapQueue.Min().(*timestamp).IsEqual(sliceQueue.Min()) || len(inQueue) == 0)
+		probe := newTimestamp(rnd.Intn(100), rnd.Intn(2000))
+		ast.Equal(heapQueue.Rank(probe), sliceQueue.Rank(probe))
+	}
+	// 删除不存在的元素时，什么也不做
+	sliceQueue.Remove(newTimestamp(1000, 0))
+	ast.Contains(sliceQueue.String(), "{request queue:<T")
+}
+
+func Test_process_sliceRequestQueue(t *testing.T) {
+	ast := assert.New(t)
+	//
+	all, times := 4, 20
+	rsc := newResource(all * times)
+	b := newMailboxBus(all, 3*(all-1), Block)
+	defer b.close()
+	ps := make([]Process, all)
+	for i := range ps {
+		ps[i] = newKProcessWithQueue(all, 1, i, rsc, b, newSliceRequestQueue())
+	}
+	for _, p := range ps {
+		go func(p Process) {
+			for i := 0; i < times; i++ {
+				p.Request()
+			}
+		}(p)
+	}
+	ast.NotPanics(rsc.wait)
+}
+
+// benchmarkRequestQueue 在有 size 个元素的队列中，反复删除一个随机的元素，再放入一个新的元素
+func benchmarkRequestQueue(b *testing.B, size int, newQueue func() RequestQueue) {
+	rnd := rand.New(rand.NewSource(1))
+	rq := newQueue()
+	tss := make([]Timestamp, size)
+	for i := range tss {
+		tss[i] = newTimestamp(rnd.Intn(size*10), i)
+		rq.Push(tss[i])
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		k := rnd.Intn(size)
+		rq.Remove(tss[k])
+		tss[k] = newTimestamp(rnd.Intn(size*10), k)
+		rq.Push(tss[k])
+		rq.Min()
+	}
+}
+
+func Benchmark_RequestQueue(b *testing.B) {
+	queues := []struct {
+		name     string
+		newQueue func() RequestQueue
+	}{
+		{"heap", newRequestQueue},
+		{"slice", newSliceRequestQueue},
+	}
+	for _, size := range []int{16, 1024, 65536} {
+		for _, q := range queues {
+			b.Run(fmt.Sprintf("%s/%d", q.name, size), func(b *testing.B) {
+				benchmarkRequestQueue(b, size, q.newQueue)
+			})
+		}
+	}
+}
+
+func Test_RequestQueue_Items(t *testing.T) {
+	ast := assert.New(t)
+	//
+	tss := makeIncreasingTimestamps(5)
+	for _, rq := range []RequestQueue{newRequestQueue(), newSliceRequestQueue()} {
+		ast.Empty(rq.Items())
+		for _, i := range rand.New(rand.NewSource(1)).Perm(len(tss)) {
+			rq.Push(tss[i])
+		}
+		rq.Remove(tss[3])
+		expected := make([]Less, 0, len(tss)-1)
+		for i, ts := range tss {
+			if i != 3 {
+				expected = append(expected, ts)
+			}
+		}
+		ast.Equal(expected, rq.Items())
+	}
+}
+
+func Test_RequestQueue_idempotent(t *testing.T) {
+	ast := assert.New(t)
+	//
+	for _, rq := range []RequestQueue{newRequestQueue(), newSliceRequestQueue()} {
+		a, b := newTimestamp(1, 0), newTimestamp(2, 1)
+		rq.Push(a)
+		rq.Push(b)
+		// 重复放入同一个元素，什么也不做
+		rq.Push(a)
+		ast.Equal([]Less{a, b}, rq.Items())
+		// 删除不存在的元素，什么也不做
+		rq.Remove(newTimestamp(3, 2))
+		ast.Equal([]Less{a, b}, rq.Items())
+		ast.True(rq.Contains(a))
+		ast.False(rq.Contains(newTimestamp(1, 0)), "只认元素本身，不认相等的值")
+		// 删除一次以后，a 就不在队列中了
+		rq.Remove(a)
+		ast.False(rq.Contains(a))
+		ast.Equal(b, rq.Min())
+		rq.Remove(b)
+		ast.Nil(rq.Min())
+	}
+}
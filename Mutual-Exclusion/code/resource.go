@@ -6,10 +6,12 @@ import (
 	"sync"
 	"time"
 
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
 	"github.com/montanaflynn/stats"
 )
 
 // Resource 是 Process 占用资源的接口
+// Release 返回以后，process 可能会重复使用 Timestamp，Resource 需要保存它时，要保存它的 String() 或者副本
 type Resource interface {
 	// Occupy 表示占用资源
 	Occupy(Timestamp)
@@ -18,16 +20,23 @@ type Resource interface {
 }
 
 type resource struct {
-	lastOccupiedBy Timestamp      // 记录上次占用资源的 timestamp
+	lastOccupiedBy timestamp      // 记录上次占用资源的 timestamp
 	occupiedBy     Timestamp      // 记录当前占用资源的 timestamp, nil 表示资源未被占用
-	timestamps     []Timestamp    // 按顺序保存占用资源的 timestamp
+	timestamps     []timestamp    // 按顺序保存占用资源的 timestamp，保存的是值，process 回收 timestamp 也不受影响
 	times          []time.Time    // 记录每次占用资源的起止时间，用于分析算法的效率
 	wg             sync.WaitGroup // 完成全部占用前，阻塞主 goroutine
+	env            sim.Env        // times 中时间的来源
 }
 
 func newResource(times int) *resource {
+	return newResourceWithEnv(times, sim.Env{})
+}
+
+// newResourceWithEnv 与 newResource 一样，但是 r 从 env 读取占用和释放的时间
+func newResourceWithEnv(times int, env sim.Env) *resource {
 	r := &resource{
-		lastOccupiedBy: newTimestamp(-1, -1),
+		lastOccupiedBy: timestamp{time: -1, process: -1},
+		env:            env,
 	}
 	r.wg.Add(times)
 	return r
@@ -38,20 +47,20 @@ func (r *resource) wait() {
 }
 
 func (r *resource) Occupy(ts Timestamp) {
-	r.times = append(r.times, time.Now())
+	r.times = append(r.times, r.env.Now())
 
 	if r.occupiedBy != nil {
 		msg := fmt.Sprintf("资源正在被 %s 占据，%s 却想获取资源。", r.occupiedBy, ts)
 		panic(msg)
 	}
 
-	if !r.lastOccupiedBy.Less(ts) {
-		msg := fmt.Sprintf("资源上次被 %s 占据，这次 %s 却想占据资源。", r.lastOccupiedBy, ts)
+	if !r.lastOccupiedBy.before(valueOf(ts)) {
+		msg := fmt.Sprintf("资源上次被 %s 占据，这次 %s 却想占据资源。", &r.lastOccupiedBy, ts)
 		panic(msg)
 	}
 
 	r.occupiedBy = ts
-	r.timestamps = append(r.timestamps, ts)
+	r.timestamps = append(r.timestamps, valueOf(ts))
 	debugPrintf("~~~ @resource: %s occupied ~~~ ", ts)
 }
 
@@ -61,8 +70,8 @@ func (r *resource) Release(ts Timestamp) {
 		panic(msg)
 	}
 
-	r.lastOccupiedBy, r.occupiedBy = ts, nil
-	r.times = append(r.times, time.Now())
+	r.lastOccupiedBy, r.occupiedBy = valueOf(ts), nil
+	r.times = append(r.times, r.env.Now())
 	debugPrintf("~~~ @resource: %s released ~~~ ", ts)
 
 	r.wg.Done() // 完成一次占用
@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -21,8 +22,22 @@ func Test_resource_occupyAndRelease(t *testing.T) {
 	// 释放
 	r.Release(ts)
 	r.wait()
-	ast.Equal(ts, r.lastOccupiedBy)
-	ast.Equal(ts, r.timestamps[0])
+	ast.Equal(timestamp{time: 0, process: p}, r.lastOccupiedBy)
+	ast.Equal(timestamp{time: 0, process: p}, r.timestamps[0])
+}
+
+func Test_resource_env(t *testing.T) {
+	ast := assert.New(t)
+	//
+	start := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := sim.NewVirtual(start)
+	r := newResourceWithEnv(1, sim.Deterministic(clock, 1))
+	ts := newTimestamp(0, 0)
+	r.Occupy(ts)
+	clock.Advance(time.Second)
+	r.Release(ts)
+	// 占用和释放的时间都来自虚拟时钟
+	ast.Equal([]time.Time{start, start.Add(time.Second)}, r.times)
 }
 
 func Test_resource_occupy_occupyInvalidResource(t *testing.T) {
@@ -0,0 +1,325 @@
+package mutualexclusion
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// ScaleConfig 描述了一次大规模的模拟运行
+type ScaleConfig struct {
+	Algorithm  string // lamport 或者 raymond
+	Processes  int
+	Requesters int // 随机选出的申请资源的 process 的数量，为 0 时所有的 process 都会申请
+	Requests   int // 每个申请者申请资源的次数
+	Seed       int64
+}
+
+// ScaleResult 是 SimulateScale 的结果
+type ScaleResult struct {
+	ScaleConfig
+	Steps       int     // 处理的事件数量，包括本地的申请和释放
+	Messages    int     // 送达的 message 数量
+	Grants      int     // 占用资源的次数
+	MaxQueue    int     // 单个 process 的队列长度的最大值
+	MaxInFlight int     // 同时在途的 message 数量的最大值
+	MeanWait    float64 // 从申请到占用，平均经过的步数
+	MaxWait     int
+	Violation   string // 为空表示没有发现错误
+}
+
+// OK 返回 true，如果没有发现错误
+func (r *ScaleResult) OK() bool {
+	return r.Violation == ""
+}
+
+func (r *ScaleResult) String() string {
+	res := fmt.Sprintf("%s：%d 个 process，%d 个申请者各申请 %d 次，运行了 %d 步，送达了 %d 条 message",
+		r.Algorithm, r.Processes, r.Requesters, r.Requests, r.Steps, r.Messages)
+	if r.Grants > 0 {
+		res += fmt.Sprintf("，平均每次占用 %.1f 条", float64(r.Messages)/float64(r.Grants))
+	}
+	res += fmt.Sprintf("\n队列最长 %d，在途 message 最多 %d 条，等待的步数：mean %.1f, max %d",
+		r.MaxQueue, r.MaxInFlight, r.MeanWait, r.MaxWait)
+	if r.OK() {
+		return res + "\n没有发现错误"
+	}
+	return res + "\n违反了 " + r.Violation
+}
+
+// scaleEvent 是 SimulateScale 中的事件
+// local 为 true 时，是 process 自己的申请或释放，否则是收到的 message
+type scaleEvent struct {
+	local   bool
+	msgType msgType
+	from    int
+	msgTime int
+	ts      timestamp
+}
+
+// scaleNet 是 scaleNode 发送 message 的网络
+// SimulateScale 的事件循环和 core 的模拟器都实现了它
+type scaleNet interface {
+	size() int
+	send(from, to int, e scaleEvent)
+	broadcast(from int, e scaleEvent)
+}
+
+// scaleNode 是没有 goroutine 的 process，由 SimulateScale 的事件循环驱动
+type scaleNode interface {
+	// handle 处理一个事件，返回 true 表示开始占用资源
+	handle(e scaleEvent, s scaleNet) bool
+	// queueLen 返回 process 的队列长度
+	queueLen() int
+}
+
+// scaleSim 是 SimulateScale 的事件循环
+// 每个 process 有一个 FIFO 的 inbox，每一步随机选择一个不为空的 inbox，处理队首的事件。
+// 发往同一个 process 的 message 按照发送的顺序处理，所以每一对 process 之间的通道满足 FIFO。
+// 与 simulate 不同，不需要 N² 个通道，也不需要在每一步列出所有的转移。
+type scaleSim struct {
+	n        int
+	nodes    []scaleNode
+	inboxes  [][]scaleEvent
+	ready    []int // inbox 不为空的 process
+	position []int // position[i] 是 i 在 ready 中的位置，-1 表示不在
+	inFlight int
+	rand     *rand.Rand
+}
+
+func (s *scaleSim) push(to int, e scaleEvent) {
+	s.inboxes[to] = append(s.inboxes[to], e)
+	if !e.local {
+		s.inFlight++
+	}
+	if s.position[to] < 0 {
+		s.position[to] = len(s.ready)
+		s.ready = append(s.ready, to)
+	}
+}
+
+// pop 随机选择一个不为空的 inbox，取出队首的事件
+func (s *scaleSim) pop() (int, scaleEvent) {
+	return s.popFrom(s.rand.Intn(len(s.ready)))
+}
+
+// popFrom 取出 ready[k] 的 inbox 中队首的事件
+func (s *scaleSim) popFrom(k int) (int, scaleEvent) {
+	to := s.ready[k]
+	e := s.inboxes[to][0]
+	s.inboxes[to] = s.inboxes[to][1:]
+	if !e.local {
+		s.inFlight--
+	}
+	if len(s.inboxes[to]) == 0 {
+		s.inboxes[to] = nil
+		last := s.ready[len(s.ready)-1]
+		s.ready[k], s.position[last] = last, k
+		s.ready = s.ready[:len(s.ready)-1]
+		s.position[to] = -1
+	}
+	return to, e
+}
+
+func (s *scaleSim) size() int {
+	return s.n
+}
+
+func (s *scaleSim) send(from, to int, e scaleEvent) {
+	e.from = from
+	s.push(to, e)
+}
+
+func (s *scaleSim) broadcast(from int, e scaleEvent) {
+	for to := 0; to < s.n; to++ {
+		if to != from {
+			s.send(from, to, e)
+		}
+	}
+}
+
+// scaleLamport 是 Lamport 算法中的 process
+// 为了让每个 process 只占用 O(1) 的额外空间，用收到的回复数量代替 receivedTime：
+// 回复一定是在收到申请以后发出的，它的时间一定晚于申请，所以收到全部 N-1 个回复时，Rule 5.2 一定成立。
+type scaleLamport struct {
+	me         int
+	clock      int
+	queue      sortedQueue[timestamp] // 按照全局排序排列的 request queue
+	request    timestamp
+	requesting bool
+	occupying  bool
+	acks       int
+}
+
+func (p *scaleLamport) handle(e scaleEvent, s scaleNet) bool {
+	switch {
+	case e.local && e.msgType == requestResource:
+		// Rule 1
+		p.clock++
+		p.request = timestamp{time: p.clock, process: p.me}
+		p.requesting = true
+		p.acks = 0
+		p.queue.push(p.request)
+		s.broadcast(p.me, scaleEvent{msgType: requestResource, msgTime: p.clock, ts: p.request})
+	case e.local:
+		// Rule 3
+		p.queue.remove(p.request)
+		p.clock++
+		s.broadcast(p.me, scaleEvent{msgType: releaseResource, msgTime: p.clock, ts: p.request})
+		p.occupying = false
+		p.requesting = false
+		return false
+	default:
+		p.clock = max(p.clock, e.msgTime+1)
+		switch e.msgType {
+		case requestResource:
+			// Rule 2
+			p.queue.push(e.ts)
+			p.clock++
+			s.send(p.me, e.from, scaleEvent{msgType: acknowledgment, msgTime: p.clock, ts: e.ts})
+		case releaseResource:
+			// Rule 4
+			p.queue.remove(e.ts)
+		case acknowledgment:
+			if p.requesting && e.ts == p.request {
+				p.acks++
+			}
+		}
+	}
+	// Rule 5
+	if p.requesting && !p.occupying && p.acks == s.size()-1 && p.queue[0] == p.request {
+		p.occupying = true
+		return true
+	}
+	return false
+}
+
+func (p *scaleLamport) queueLen() int {
+	return len(p.queue)
+}
+
+// scaleRaymond 是 Raymond 算法中的 process，逻辑与 raymondProcess 相同
+type scaleRaymond struct {
+	me     int
+	holder int
+	asked  bool
+	queue  []int
+	using  bool
+}
+
+func (p *scaleRaymond) handle(e scaleEvent, s scaleNet) bool {
+	switch {
+	case e.local && e.msgType == requestResource:
+		p.queue = append(p.queue, p.me)
+	case e.local:
+		p.using = false
+	case e.msgType == tokenRequest:
+		p.queue = append(p.queue, e.from)
+	case e.msgType == tokenPrivilege:
+		p.holder = p.me
+	}
+	occupied := p.assignPrivilege(s)
+	if p.holder != p.me && len(p.queue) > 0 && !p.asked {
+		p.asked = true
+		s.send(p.me, p.holder, scaleEvent{msgType: tokenRequest})
+	}
+	return occupied
+}
+
+func (p *scaleRaymond) assignPrivilege(s scaleNet) bool {
+	if p.holder != p.me || p.using || len(p.queue) == 0 {
+		return false
+	}
+	head := p.queue[0]
+	p.queue = p.queue[1:]
+	p.asked = false
+	if head == p.me {
+		p.using = true
+		return true
+	}
+	p.holder = head
+	s.send(p.me, head, scaleEvent{msgType: tokenPrivilege})
+	return false
+}
+
+func (p *scaleRaymond) queueLen() int {
+	return len(p.queue)
+}
+
+// SimulateScale 在没有 goroutine 的事件循环中运行 c 中的系统，直到所有的申请都被满足
+// 每个 process 只是一个由事件循环驱动的状态机，所以可以模拟上千个 process，
+// 用来测量算法的规模特性：message 数量、队列长度和等待时间。同样的 c 总会得到同样的结果。
+func SimulateScale(c ScaleConfig) *ScaleResult {
+	res := &ScaleResult{ScaleConfig: c}
+	n := c.Processes
+	if res.Requesters == 0 {
+		res.Requesters = n
+	}
+	s := &scaleSim{
+		n:        n,
+		nodes:    make([]scaleNode, n),
+		inboxes:  make([][]scaleEvent, n),
+		position: make([]int, n),
+		rand:     rand.New(rand.NewSource(c.Seed)),
+	}
+	tree := binaryTree(n)
+	for i := range s.nodes {
+		s.position[i] = -1
+		switch c.Algorithm {
+		case "lamport":
+			s.nodes[i] = &scaleLamport{me: i}
+		case "raymond":
+			holder := tree[i]
+			if holder < 0 {
+				holder = i
+			}
+			s.nodes[i] = &scaleRaymond{me: i, holder: holder}
+		default:
+			res.Violation = fmt.Sprintf("未知的算法 %q", c.Algorithm)
+			return res
+		}
+	}
+
+	remaining := make([]int, n)
+	requestedAt := make([]int, n)
+	for _, i := range s.rand.Perm(n)[:res.Requesters] {
+		remaining[i] = c.Requests - 1
+		s.push(i, scaleEvent{local: true, msgType: requestResource})
+	}
+	occupying, totalWait := 0, 0
+	for len(s.ready) > 0 {
+		i, e := s.pop()
+		res.Steps++
+		if !e.local {
+			res.Messages++
+		} else if e.msgType == requestResource {
+			requestedAt[i] = res.Steps
+		} else {
+			occupying--
+		}
+		if s.nodes[i].handle(e, s) {
+			occupying++
+			if occupying > 1 {
+				res.Violation = fmt.Sprintf("AlwaysAtMostOneOccupier: 第 %d 步有 %d 个 process 同时占用资源", res.Steps, occupying)
+				return res
+			}
+			res.Grants++
+			wait := res.Steps - requestedAt[i]
+			totalWait += wait
+			res.MaxWait = max(res.MaxWait, wait)
+			// 释放资源的时机由事件循环的调度决定
+			s.push(i, scaleEvent{local: true, msgType: releaseResource})
+		} else if e.local && e.msgType == releaseResource && remaining[i] > 0 {
+			remaining[i]--
+			s.push(i, scaleEvent{local: true, msgType: requestResource})
+		}
+		res.MaxQueue = max(res.MaxQueue, s.nodes[i].queueLen())
+		res.MaxInFlight = max(res.MaxInFlight, s.inFlight)
+	}
+	if res.Grants > 0 {
+		res.MeanWait = float64(totalWait) / float64(res.Grants)
+	}
+	if want := res.Requesters * c.Requests; res.Grants < want {
+		res.Violation = fmt.Sprintf("deadlock: %d 次申请中，只有 %d 次被满足", want, res.Grants)
+	}
+	return res
+}
@@ -0,0 +1,78 @@
+package mutualexclusion
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_scaleSim(t *testing.T) {
+	ast := assert.New(t)
+	//
+	s := &scaleSim{
+		n:        3,
+		inboxes:  make([][]scaleEvent, 3),
+		position: []int{-1, -1, -1},
+	}
+	s.send(0, 2, scaleEvent{msgTime: 1})
+	s.send(1, 2, scaleEvent{msgTime: 2})
+	s.push(0, scaleEvent{local: true})
+	ast.Equal([]int{2, 0}, s.ready)
+	ast.Equal(2, s.inFlight)
+	// 发往同一个 process 的 message 按照发送的顺序处理
+	s.rand = nil
+	s.ready = []int{2}
+	s.position = []int{-1, -1, 0}
+	to, e := s.popFrom(0)
+	ast.Equal(2, to)
+	ast.Equal(1, e.msgTime)
+	ast.Equal(0, e.from)
+	to, e = s.popFrom(0)
+	ast.Equal(2, e.msgTime)
+	ast.Equal(1, e.from)
+	ast.Empty(s.ready)
+	ast.Equal(-1, s.position[to])
+	ast.Equal(0, s.inFlight)
+}
+
+func Test_SimulateScale(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := ScaleConfig{Algorithm: "lamport", Processes: 8, Requests: 3, Seed: 1}
+	res := SimulateScale(c)
+	ast.True(res.OK())
+	ast.Equal(8*3, res.Grants)
+	ast.Equal(3*7*8*3, res.Messages)
+	ast.Equal(res, SimulateScale(c))
+	ast.Contains(res.String(), "没有发现错误")
+	//
+	c.Algorithm = "raymond"
+	res = SimulateScale(c)
+	ast.True(res.OK())
+	ast.Equal(8*3, res.Grants)
+	// 8 个节点的平衡二叉树，直径是 5
+	ast.True(float64(res.Messages)/float64(res.Grants) <= 2*5)
+	ast.True(res.MaxQueue <= 3)
+	//
+	c.Algorithm = "ricart"
+	ast.Contains(SimulateScale(c).Violation, "未知的算法")
+}
+
+func Test_SimulateScale_thousands(t *testing.T) {
+	ast := assert.New(t)
+	//
+	raymond := SimulateScale(ScaleConfig{Algorithm: "raymond", Processes: 5000, Requests: 1, Seed: 1})
+	ast.True(raymond.OK())
+	ast.Equal(5000, raymond.Grants)
+	// 平衡二叉树的直径是 O(log N)
+	ast.True(float64(raymond.Messages)/float64(raymond.Grants) <= 2*24)
+	// 每个 process 的队列中，最多只有自己和 3 个邻居
+	ast.True(raymond.MaxQueue <= 4)
+	//
+	lamport := SimulateScale(ScaleConfig{Algorithm: "lamport", Processes: 5000, Requesters: 20, Requests: 1, Seed: 1})
+	ast.True(lamport.OK())
+	ast.Equal(20, lamport.Grants)
+	ast.Equal(3*4999*20, lamport.Messages)
+	ast.True(lamport.MaxQueue <= 20)
+	ast.True(lamport.MaxInFlight >= 4999)
+}
@@ -0,0 +1,73 @@
+package mutualexclusion
+
+import (
+	"fmt"
+	"sync"
+)
+
+// semaphore 是最多可以同时被 k 个 process 占用的 Resource
+// 与 resource 不同，占用 semaphore 的先后顺序不一定与 timestamp 的顺序一致，
+// 所以只检查同时占用的数量，以及释放者是不是占用者
+type semaphore struct {
+	k            int
+	mutex        sync.Mutex
+	occupiedBy   []Timestamp    // 当前占用资源的 timestamp
+	maxOccupying int            // 同时占用资源的 process 数量的最大值
+	timestamps   []timestamp    // 按顺序保存占用资源的 timestamp 的值
+	wg           sync.WaitGroup // 完成全部占用前，阻塞主 goroutine
+}
+
+func newSemaphore(k, times int) *semaphore {
+	s := &semaphore{k: k}
+	s.wg.Add(times)
+	return s
+}
+
+func (s *semaphore) wait() {
+	s.wg.Wait()
+}
+
+func (s *semaphore) indexOf(ts Timestamp) int {
+	for i, o := range s.occupiedBy {
+		if o.IsEqual(ts) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *semaphore) Occupy(ts Timestamp) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.indexOf(ts) >= 0 {
+		msg := fmt.Sprintf("%s 已经占据了资源，却想再次占据资源。", ts)
+		panic(msg)
+	}
+
+	if len(s.occupiedBy) >= s.k {
+		msg := fmt.Sprintf("资源正在被 %v 占据，%s 却想获取资源。", s.occupiedBy, ts)
+		panic(msg)
+	}
+
+	s.occupiedBy = append(s.occupiedBy, ts)
+	s.maxOccupying = max(s.maxOccupying, len(s.occupiedBy))
+	s.timestamps = append(s.timestamps, valueOf(ts))
+	debugPrintf("~~~ @semaphore: %s occupied, %d/%d ~~~ ", ts, len(s.occupiedBy), s.k)
+}
+
+func (s *semaphore) Release(ts Timestamp) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	i := s.indexOf(ts)
+	if i < 0 {
+		msg := fmt.Sprintf("%s 想要释放正在被 %v 占据的资源。", ts, s.occupiedBy)
+		panic(msg)
+	}
+
+	s.occupiedBy = append(s.occupiedBy[:i], s.occupiedBy[i+1:]...)
+	debugPrintf("~~~ @semaphore: %s released, %d/%d ~~~ ", ts, len(s.occupiedBy), s.k)
+
+	s.wg.Done() // 完成一次占用
+}
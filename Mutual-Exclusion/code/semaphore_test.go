@@ -0,0 +1,101 @@
+package mutualexclusion
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/aQuaYi/observer"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_semaphore_occupyAndRelease(t *testing.T) {
+	ast := assert.New(t)
+	//
+	k := 3
+	s := newSemaphore(k, k)
+	tss := makeIncreasingTimestamps(2)[:k]
+	// 占用的顺序与 timestamp 的顺序无关
+	for i := k - 1; i >= 0; i-- {
+		s.Occupy(tss[i])
+	}
+	ast.Equal(k, s.maxOccupying)
+	for i := range tss {
+		s.Release(tss[i])
+	}
+	s.wait()
+	ast.Equal(0, len(s.occupiedBy))
+	ast.Equal(k, len(s.timestamps))
+}
+
+func Test_semaphore_Occupy_panicOfFull(t *testing.T) {
+	ast := assert.New(t)
+	//
+	s := newSemaphore(1, 1)
+	ts0 := newTimestamp(0, 0)
+	ts1 := newTimestamp(0, 1)
+	s.Occupy(ts0)
+	//
+	expected := fmt.Sprintf("资源正在被 %v 占据，%s 却想获取资源。", []Timestamp{ts0}, ts1)
+	ast.PanicsWithValue(expected, func() { s.Occupy(ts1) })
+}
+
+func Test_semaphore_Occupy_panicOfOccupyTwice(t *testing.T) {
+	ast := assert.New(t)
+	//
+	s := newSemaphore(2, 1)
+	ts := newTimestamp(0, 0)
+	s.Occupy(ts)
+	//
+	expected := fmt.Sprintf("%s 已经占据了资源，却想再次占据资源。", ts)
+	ast.PanicsWithValue(expected, func() { s.Occupy(ts) })
+}
+
+func Test_semaphore_Release_panicOfReleaseByOther(t *testing.T) {
+	ast := assert.New(t)
+	//
+	s := newSemaphore(2, 1)
+	ts0 := newTimestamp(0, 0)
+	ts1 := newTimestamp(0, 1)
+	s.Occupy(ts0)
+	//
+	expected := fmt.Sprintf("%s 想要释放正在被 %v 占据的资源。", ts1, []Timestamp{ts0})
+	ast.PanicsWithValue(expected, func() { s.Release(ts1) })
+}
+
+func runSemaphore(all, k, occupyTimesPerProcess int) *semaphore {
+	s := newSemaphore(k, all*occupyTimesPerProcess)
+
+	prop := observer.NewProperty(nil)
+
+	ps := make([]Process, all)
+	for i := range ps {
+		ps[i] = newKProcessWithBus(all, k, i, s, newObserverBus(prop))
+	}
+
+	for _, p := range ps {
+		go func(p Process, times int) {
+			for i := 0; i < times; i++ {
+				p.Request()
+			}
+		}(p, occupyTimesPerProcess)
+	}
+
+	s.wait()
+	return s
+}
+
+func Test_process_kMutualExclusion(t *testing.T) {
+	ast := assert.New(t)
+	//
+	all := 8
+	for k := 1; k <= all; k *= 2 {
+		name := fmt.Sprintf("%d Process 中最多 %d 个同时占用", all, k)
+		t.Run(name, func(t *testing.T) {
+			var s *semaphore
+			ast.NotPanics(func() {
+				s = runSemaphore(all, k, 500)
+			})
+			ast.True(s.maxOccupying <= k)
+		})
+	}
+}
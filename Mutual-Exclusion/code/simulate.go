@@ -0,0 +1,154 @@
+package mutualexclusion
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+
+	spec "github.com/aQuaYi/Distributed-Algorithms/Spec/code"
+)
+
+// SimulationResult 是一次模拟运行的结果
+type SimulationResult struct {
+	Processes int
+	Requests  int
+	Violation string   // 违反的性质，为空表示没有发现错误
+	Steps     []string // 每一步转移的描述，可以作为 trace 输出
+	Messages  int      // 送达的 message 数量
+	Grants    []string // 按顺序占用资源的申请
+	Waits     []int    // 每次申请从发出到占用资源，系统经过的步数
+	Events    []Event  // 申请和占用的 trace，Event.At 是步数
+	// Account 按照类型和所服务的申请，统计送达的 message
+	Account *MessageAccount
+}
+
+// OK 返回 true，如果没有发现错误
+func (r *SimulationResult) OK() bool {
+	return r.Violation == ""
+}
+
+func (r *SimulationResult) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d 个 process 各申请 %d 次，运行了 %d 步，送达了 %d 条 message", r.Processes, r.Requests, len(r.Steps), r.Messages)
+	if len(r.Grants) > 0 {
+		fmt.Fprintf(&b, "，平均每次占用 %.1f 条\n", float64(r.Messages)/float64(len(r.Grants)))
+		min, max, sum := r.Waits[0], r.Waits[0], 0
+		for _, w := range r.Waits {
+			if w < min {
+				min = w
+			}
+			if w > max {
+				max = w
+			}
+			sum += w
+		}
+		fmt.Fprintf(&b, "等待的步数：min %d, mean %.1f, max %d\n", min, float64(sum)/float64(len(r.Waits)), max)
+		fmt.Fprintf(&b, "占用顺序：%s", strings.Join(r.Grants, " "))
+	}
+	if r.OK() {
+		b.WriteString("\n没有发现错误")
+	} else {
+		fmt.Fprintf(&b, "\n违反了 %s", r.Violation)
+	}
+	return b.String()
+}
+
+// Fairness 分析这次运行的公平性，等待的步数超过平均值 10 倍的 process 被判断为饥饿
+func (r *SimulationResult) Fairness() *FairnessReport {
+	return AnalyzeFairness(r.Processes, r.Events, 0)
+}
+
+// Simulate 用 seed 生成一个随机的调度，在 c 中的系统上运行到所有的申请都被满足为止
+// 与启动 goroutine 的 process 不同，同样的 seed 总会得到同样的运行结果。
+func Simulate(c ModelConfig, seed int64) *SimulationResult {
+	return simulate(c, randomChoices(c, rand.New(rand.NewSource(seed))))
+}
+
+// randomChoices 返回足够让 c 中的系统运行结束的随机调度
+func randomChoices(c ModelConfig, rnd *rand.Rand) []int {
+	// 每个 process 的每次申请最多产生 3*(Processes-1) 条 message 和 2 次本地转移
+	length := c.Processes * c.Requests * (3*(c.Processes-1) + 2)
+	res := make([]int, length)
+	for i := range res {
+		res[i] = rnd.Intn(256)
+	}
+	return res
+}
+
+// model 是可以被模拟运行的确定性状态机
+type model interface {
+	// actions 返回所有可以进行的转移
+	actions(c ModelConfig) []mcAction
+	// apply 进行 a，并返回 a 的描述
+	apply(a mcAction, c ModelConfig) string
+	// snapshot 返回当前状态对应的 Snapshot
+	snapshot(requests int) *Snapshot
+	// finished 返回 true，如果所有的申请都已经被满足了
+	finished() bool
+	// occupying 返回 Pi 是否正在占用资源
+	occupying(i int) bool
+	// request 返回 Pi 当前申请的描述
+	request(i int) string
+	// message 返回 deliver 转移 a 将要送达的 message 的类型，以及它所服务的申请
+	message(a mcAction) (msgType, string)
+}
+
+// simulate 按照 choices 运行 c 中的 Lamport 算法
+func simulate(c ModelConfig, choices []int) *SimulationResult {
+	return simulateModel(c, newMCState(c), choices)
+}
+
+// simulateModel 按照 choices 运行 s
+// 每一步都从当时所有可以进行的转移中，选择第 choices[i]%len(actions) 个，
+// 转移用完或者 choices 用完时结束。
+func simulateModel(c ModelConfig, s model, choices []int) *SimulationResult {
+	res := &SimulationResult{Processes: c.Processes, Requests: c.Requests, Account: newMessageAccount()}
+	props := c.Properties
+	if props == nil {
+		props = defaultProperties(c.Processes, c.Requests)
+	}
+	monitors := spec.NewMonitors(props...)
+	if err := monitors.Step(s.snapshot(c.Requests)); err != nil {
+		res.Violation = err.Error()
+		return res
+	}
+	requestedAt := make([]int, c.Processes)
+	for _, choice := range choices {
+		actions := s.actions(c)
+		if len(actions) == 0 {
+			break
+		}
+		a := actions[choice%len(actions)]
+		if a.kind == "deliver" {
+			mt, request := s.message(a)
+			res.Account.add(mt, request, 1)
+		}
+		occupying := s.occupying(a.process)
+		res.Steps = append(res.Steps, s.apply(a, c))
+		switch {
+		case a.kind == "request":
+			requestedAt[a.process] = len(res.Steps)
+			res.Events = append(res.Events, Event{Kind: Requested, Process: a.process, At: int64(len(res.Steps))})
+		case a.kind == "deliver":
+			res.Messages++
+		}
+		if !occupying && s.occupying(a.process) {
+			res.Grants = append(res.Grants, s.request(a.process))
+			res.Waits = append(res.Waits, len(res.Steps)-requestedAt[a.process])
+			res.Events = append(res.Events, Event{Kind: Granted, Process: a.process, At: int64(len(res.Steps))})
+		}
+		if err := monitors.Step(s.snapshot(c.Requests)); err != nil {
+			res.Violation = err.Error()
+			return res
+		}
+	}
+	// 只有运行到了终点，才能检查 Eventually 之类的性质
+	if len(s.actions(c)) == 0 {
+		if err := monitors.Done(); err != nil {
+			res.Violation = err.Error()
+		} else if !s.finished() {
+			res.Violation = "deadlock: 还有申请没有被满足，但是系统已经无法继续运行"
+		}
+	}
+	return res
+}
@@ -0,0 +1,56 @@
+package mutualexclusion
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Simulate(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := ModelConfig{Processes: 4, Requests: 5}
+	res := Simulate(c, 1)
+	ast.True(res.OK(), res.String())
+	ast.Equal(20, len(res.Grants))
+	ast.Equal(20, len(res.Waits))
+	// 每次申请都会产生 (n-1) 条 request、ack 和 release
+	ast.Equal(20*3*3, res.Messages)
+	ast.Contains(res.String(), "没有发现错误")
+	// 同样的 seed 得到同样的运行
+	ast.Equal(res, Simulate(c, 1))
+	ast.NotEqual(res.Steps, Simulate(c, 2).Steps)
+}
+
+func Test_Simulate_skipRule52(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := ModelConfig{Processes: 3, Requests: 3, SkipRule52: true}
+	found := false
+	for seed := int64(0); seed < 20 && !found; seed++ {
+		res := Simulate(c, seed)
+		found = !res.OK()
+		if found {
+			ast.Contains(res.String(), "违反了 AlwaysAtMostOneOccupier")
+		}
+	}
+	ast.True(found)
+}
+
+func Test_Simulate_suppressAcks(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := ModelConfig{Processes: 4, Requests: 5}
+	plain, suppressed := 0, 0
+	for seed := int64(0); seed < 20; seed++ {
+		c.SuppressAcks = false
+		plain += Simulate(c, seed).Messages
+		c.SuppressAcks = true
+		res := Simulate(c, seed)
+		ast.True(res.OK(), res.String())
+		ast.Equal(20, len(res.Grants))
+		suppressed += res.Messages
+	}
+	ast.Equal(20*20*3*3, plain)
+	ast.True(suppressed < plain, "%d 条 message 没有减少", suppressed)
+}
@@ -0,0 +1,46 @@
+package mutualexclusion
+
+import "sort"
+
+// ordered 是可以放入 sortedQueue 的值类型
+// 与 Timestamp 接口不同，比较时不需要类型断言，值也不需要在堆上分配
+type ordered[T any] interface {
+	comparable
+	before(T) bool
+}
+
+// sortedQueue 是按照 before 排列的 slice，队首是最小的元素
+// 模型中的 process 需要复制和编码整个状态，所以使用值类型的 slice，而不是基于堆的 requestQueue
+type sortedQueue[T ordered[T]] []T
+
+// search 返回第一个不在 x 之前的元素的位置
+func (q sortedQueue[T]) search(x T) int {
+	return sort.Search(len(q), func(i int) bool { return !q[i].before(x) })
+}
+
+func (q *sortedQueue[T]) push(x T) {
+	i := sort.Search(len(*q), func(i int) bool { return x.before((*q)[i]) })
+	var zero T
+	*q = append(*q, zero)
+	copy((*q)[i+1:], (*q)[i:])
+	(*q)[i] = x
+}
+
+// remove 删除与 x 相等的元素，x 不存在时，什么也不做
+func (q *sortedQueue[T]) remove(x T) {
+	for i := q.search(x); i < len(*q) && !x.before((*q)[i]); i++ {
+		if (*q)[i] == x {
+			*q = append((*q)[:i], (*q)[i+1:]...)
+			return
+		}
+	}
+}
+
+// rank 返回排在 x 前面的元素个数
+func (q sortedQueue[T]) rank(x T) int {
+	return q.search(x)
+}
+
+func (q sortedQueue[T]) clone() sortedQueue[T] {
+	return append(sortedQueue[T](nil), q...)
+}
@@ -0,0 +1,56 @@
+package mutualexclusion
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_sortedQueue(t *testing.T) {
+	ast := assert.New(t)
+	//
+	var q sortedQueue[timestamp]
+	for _, ts := range []timestamp{{time: 3, process: 1}, {time: 1, process: 2}, {time: 3, process: 0}, {time: 2, process: 5}} {
+		q.push(ts)
+	}
+	ast.Equal(sortedQueue[timestamp]{{time: 1, process: 2}, {time: 2, process: 5}, {time: 3, process: 0}, {time: 3, process: 1}}, q)
+	ast.Equal(2, q.rank(timestamp{time: 3, process: 0}))
+	ast.Equal(4, q.rank(timestamp{time: 9, process: 9}))
+	//
+	c := q.clone()
+	q.remove(timestamp{time: 2, process: 5})
+	q.remove(timestamp{time: 7, process: 7}) // 不存在的元素
+	ast.Equal(sortedQueue[timestamp]{{time: 1, process: 2}, {time: 3, process: 0}, {time: 3, process: 1}}, q)
+	ast.Len(c, 4)
+}
+
+func Test_timestamp_before(t *testing.T) {
+	ast := assert.New(t)
+	//
+	a, b := timestamp{time: 1, process: 2}, timestamp{time: 1, process: 3}
+	ast.True(a.before(b))
+	ast.False(b.before(a))
+	ast.False(a.before(a))
+	ast.Equal(a.before(b), (&a).Less(&b))
+}
+
+func Benchmark_timestamp(b *testing.B) {
+	x, y := newTimestamp(1, 2), newTimestamp(1, 3)
+	b.Run("Timestamp.Less", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			x.Less(y)
+		}
+	})
+	vx, vy := timestamp{time: 1, process: 2}, timestamp{time: 1, process: 3}
+	b.Run("timestamp.before", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			vx.before(vy)
+		}
+	})
+	b.Run("newTimestamp", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			x = newTimestamp(i, 1)
+		}
+	})
+}
@@ -0,0 +1,107 @@
+package mutualexclusion
+
+import (
+	"fmt"
+	"strings"
+)
+
+// State 是单个 process 内部状态的快照
+// 所有的字段都是复制出来的，读取和修改它们都不会影响 process，
+// 所以测试、模型检查和监控都可以直接断言 process 的状态，而不需要读取未导出的字段。
+type State struct {
+	Process int // process 的 ID
+	// Clock 是 process 的逻辑时钟
+	// Raymond 算法没有逻辑时钟，Clock 是 process 申请资源的次数
+	Clock int
+	// Queue 是 request queue 中的全部申请，按照全局排序排列
+	// Raymond 算法中，Queue 是向自己申请 token 的各项的最初的申请，按照到达的顺序排列
+	Queue     []Timestamp
+	Pending   Timestamp // 还没有释放的申请，没有时为 nil
+	Occupying bool      // 是否正在占用资源
+}
+
+func (s State) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "P%d{clock:%d, queue:[", s.Process, s.Clock)
+	for i, ts := range s.Queue {
+		if i > 0 {
+			b.WriteString(" ")
+		}
+		b.WriteString(ts.String())
+	}
+	b.WriteString("], pending:")
+	if s.Pending == nil {
+		b.WriteString("none")
+	} else {
+		b.WriteString(s.Pending.String())
+	}
+	fmt.Fprintf(&b, ", occupying:%t}", s.Occupying)
+	return b.String()
+}
+
+// state 返回 p 的 State，只能在 event loop 中调用
+func (p *process) state() State {
+	res := State{
+		Process:   p.me,
+		Clock:     p.clock.Now(),
+		Occupying: p.isOccupying,
+	}
+	// process 中的 timestamp 会被回收，State 中放的是副本
+	if p.requestTimestamp != nil {
+		res.Pending = copyTimestamp(p.requestTimestamp)
+	}
+	for _, ls := range p.requestQueue.Items() {
+		res.Queue = append(res.Queue, copyTimestamp(ls.(Timestamp)))
+	}
+	return res
+}
+
+// Snapshot 让 event loop 在两个事件之间生成 State，所以读到的状态总是一致的
+// event loop 退出以后，状态不会再改变，直接读取即可
+func (p *process) Snapshot() State {
+	reply := make(chan State, 1)
+	select {
+	case p.inspect <- reply:
+		return <-reply
+	case <-p.stopped:
+		return p.state()
+	}
+}
+
+func (p *raymondProcess) Snapshot() State {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	res := State{
+		Process:   p.me,
+		Clock:     p.times,
+		Occupying: p.using,
+	}
+	for _, e := range p.queue {
+		res.Queue = append(res.Queue, e.ts)
+		if e.from == p.me {
+			res.Pending = p.ts
+		}
+	}
+	if p.using {
+		res.Pending = p.ts
+	}
+	return res
+}
+
+// state 返回模型中 process me 的 State
+func (p *mcProcess) state(me int) State {
+	res := State{
+		Process:   me,
+		Clock:     p.clock,
+		Occupying: p.occupying,
+	}
+	for _, ts := range p.queue {
+		ts := ts
+		res.Queue = append(res.Queue, &ts)
+	}
+	if p.requesting {
+		ts := p.request
+		res.Pending = &ts
+	}
+	return res
+}
@@ -0,0 +1,92 @@
+package mutualexclusion
+
+import (
+	"testing"
+	"time"
+
+	spec "github.com/aQuaYi/Distributed-Algorithms/Spec/code"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_State_String(t *testing.T) {
+	ast := assert.New(t)
+	//
+	s := State{Process: 1, Clock: 3}
+	ast.Equal("P1{clock:3, queue:[], pending:none, occupying:false}", s.String())
+	//
+	s.Queue = []Timestamp{newTimestamp(1, 0), newTimestamp(2, 1)}
+	s.Pending = s.Queue[1]
+	ast.Equal("P1{clock:3, queue:[<T1:P0> <T2:P1>], pending:<T2:P1>, occupying:false}", s.String())
+}
+
+func Test_process_Snapshot(t *testing.T) {
+	ast := assert.New(t)
+	//
+	// 只创建 P0，它永远收不到 P1 的回复，所以申请会一直等待
+	b := newMailboxBus(2, 4, Block)
+	p := newKProcessWithBus(2, 1, 0, newResource(1), b)
+	// clock 的初始值是随机的
+	start := p.Snapshot().Clock
+	ast.Equal(State{Process: 0, Clock: start}, p.Snapshot())
+	//
+	p.Request()
+	var s State
+	for i := 0; i < 100 && s.Pending == nil; i++ {
+		time.Sleep(time.Millisecond)
+		s = p.Snapshot()
+	}
+	ts := newTimestamp(start+1, 0)
+	ast.Equal(State{Process: 0, Clock: start + 1, Queue: []Timestamp{ts}, Pending: ts}, s)
+	// 修改 State 不会影响 process
+	s.Queue[0] = newTimestamp(9, 9)
+	ast.Equal(ts, p.Snapshot().Queue[0])
+	// event loop 退出以后，仍然可以读取最后的状态
+	b.close()
+	<-p.(*process).stopped
+	ast.Equal(ts, p.Snapshot().Pending)
+}
+
+func Test_raymondProcess_Snapshot(t *testing.T) {
+	ast := assert.New(t)
+	//
+	b := &recordBus{}
+	p := newRaymondProcess(binaryTree(3), 1, newSemaphore(1, 1), b)
+	p.(*raymondProcess).handle(newMessage(tokenRequest, 0, 0, 1, newTimestamp(1, 0)))
+	ast.Equal(State{Process: 1, Queue: []Timestamp{newTimestamp(1, 0)}}, p.Snapshot())
+	//
+	p.Request()
+	ts := newTimestamp(1, 1)
+	ast.Equal(State{Process: 1, Clock: 1, Queue: []Timestamp{newTimestamp(1, 0), ts}, Pending: ts}, p.Snapshot())
+}
+
+func Test_ModelCheck_processStates(t *testing.T) {
+	ast := assert.New(t)
+	//
+	// 利用 Snapshot.Processes 检查每个 process 内部的不变式
+	res := ModelCheck(ModelConfig{
+		Processes: 3,
+		Requests:  1,
+		Properties: []spec.Property{
+			spec.Always("PendingInOwnQueue", func(state interface{}) bool {
+				for _, p := range state.(*Snapshot).Processes {
+					if p.Pending == nil {
+						if p.Occupying {
+							return false
+						}
+						continue
+					}
+					found := false
+					for _, ts := range p.Queue {
+						found = found || ts.IsEqual(p.Pending)
+					}
+					if !found || (p.Occupying && !p.Queue[0].IsEqual(p.Pending)) {
+						return false
+					}
+				}
+				return true
+			}),
+		},
+	})
+	ast.True(res.OK(), res.String())
+	ast.True(res.Complete)
+}
@@ -1,6 +1,10 @@
 package mutualexclusion
 
-import "fmt"
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
 
 // Timestamp 是用于全局排序的接口
 type Timestamp interface {
@@ -16,6 +20,9 @@ type Timestamp interface {
 
 type timestamp struct {
 	time, process int
+	// refs 是还持有这个 timestamp 的申请方、message 和 request queue 的数量
+	// 只有从 timestampPool 中取出的 timestamp 才会大于 0，其他的 timestamp 一直是 0，不会被回收
+	refs int32
 }
 
 func newTimestamp(time, process int) Timestamp {
@@ -25,17 +32,69 @@ func newTimestamp(time, process int) Timestamp {
 	}
 }
 
+// timestampPool 回收没有人再持有的 timestamp
+// 一次申请的 timestamp 被申请方、所有相关的 message 和每个 process 的 request queue 共用，
+// 只有它们都放手以后，才能被重复使用，所以用 refs 计数
+var timestampPool = sync.Pool{
+	New: func() interface{} { return new(timestamp) },
+}
+
+// getTimestamp 从 timestampPool 中取出 timestamp，调用方持有第一个引用，用完以后要调用 releaseTimestamp
+func getTimestamp(time, process int) *timestamp {
+	ts := timestampPool.Get().(*timestamp)
+	*ts = timestamp{time: time, process: process, refs: 1}
+	return ts
+}
+
+// retainTimestamp 表示 ts 又多了一个持有者，调用方自己必须已经持有 ts，所以 refs 不会同时降到 0
+func retainTimestamp(ts Timestamp) {
+	if t, ok := ts.(*timestamp); ok && atomic.LoadInt32(&t.refs) > 0 {
+		atomic.AddInt32(&t.refs, 1)
+	}
+}
+
+// releaseTimestamp 表示一个持有者不再使用 ts，最后一个持有者把 ts 放回 timestampPool
+// 放回以后，ts 随时可能被别人取出，调用方不能再读取 ts
+func releaseTimestamp(ts Timestamp) {
+	if t, ok := ts.(*timestamp); ok && atomic.LoadInt32(&t.refs) > 0 {
+		if atomic.AddInt32(&t.refs, -1) == 0 {
+			timestampPool.Put(t)
+		}
+	}
+}
+
+// valueOf 返回 ts 的值，不包括 refs
+func valueOf(ts Timestamp) timestamp {
+	t := ts.(*timestamp)
+	return timestamp{time: t.time, process: t.process}
+}
+
+// copyTimestamp 返回 ts 的副本，需要在 ts 被回收以后继续持有它时使用
+func copyTimestamp(ts Timestamp) Timestamp {
+	if t, ok := ts.(*timestamp); ok {
+		return newTimestamp(t.time, t.process)
+	}
+	return ts
+}
+
 func (ts *timestamp) String() string {
 	return fmt.Sprintf("<T%d:P%d>", ts.time, ts.process)
 }
 
 func (ts *timestamp) Less(tsi interface{}) bool {
-	ts2 := tsi.(*timestamp)
+	// 只读取 time 和 process，复制整个 timestamp 会读到其他 goroutine 正在修改的 refs
+	return valueOf(ts).before(valueOf(tsi.(*timestamp)))
+}
+
+// before 比较两个 timestamp 值的全局排序
+// 与 Less 不同，不需要类型断言，timestamp 可以作为值放入 sortedQueue 之类的泛型容器。
+// Timestamp 接口仍然保留，给需要其他比较方式的实现使用
+func (ts timestamp) before(other timestamp) bool {
 	// 这就是将局部顺序推广到全局顺序的关键
-	if ts.time == ts2.time {
-		return ts.process < ts2.process
+	if ts.time == other.time {
+		return ts.process < other.process
 	}
-	return ts.time < ts2.time
+	return ts.time < other.time
 }
 
 func (ts *timestamp) IsEqual(tsi interface{}) bool {
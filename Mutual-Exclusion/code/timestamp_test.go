@@ -52,3 +52,60 @@ func Test_timestamp_IsBefore(t *testing.T) {
 	ast.False(ts.IsBefore(0))
 	ast.True(ts.IsBefore(2))
 }
+
+func Test_timestampPool(t *testing.T) {
+	ast := assert.New(t)
+	//
+	ts := getTimestamp(1, 2)
+	ast.Equal("<T1:P2>", ts.String())
+	ast.Equal(int32(1), ts.refs)
+	retainTimestamp(ts)
+	ast.Equal(int32(2), ts.refs)
+	releaseTimestamp(ts)
+	ast.Equal(int32(1), ts.refs)
+	// 副本不计数，也不会被回收
+	c := copyTimestamp(ts)
+	ast.True(c.IsEqual(ts))
+	ast.Equal(int32(0), c.(*timestamp).refs)
+	// 不是从 timestampPool 中取出的 timestamp，retain 和 release 什么也不做
+	plain := newTimestamp(1, 2)
+	retainTimestamp(plain)
+	releaseTimestamp(plain)
+	ast.Equal(int32(0), plain.(*timestamp).refs)
+	ast.Equal(timestamp{time: 1, process: 2}, valueOf(ts))
+}
+
+func Test_process_timestampRefs(t *testing.T) {
+	ast := assert.New(t)
+	// recordBus 不回收 message，所以发出的 message 一直持有 timestamp
+	b := &recordBus{}
+	p := newTestProcess(2, 0, b)
+	p.wg.Add(1) // 代替 Request
+	p.request()
+	ts := p.requestTimestamp.(*timestamp)
+	ast.Equal(int32(3), ts.refs, "申请方、申请消息和 request queue")
+	p.handle(newMessage(acknowledgment, ts.time+1, 1, 0, ts))
+	ast.True(p.isOccupying)
+	ast.Equal(int32(4), ts.refs, "还有回复")
+	p.releaseResource()
+	ast.Equal(int32(3), ts.refs, "只剩下三条 message")
+	// 收到别人的申请时，request queue 和 pending 各自持有它，收到释放以后放手
+	other := getTimestamp(10, 1)
+	p.handle(newMessage(requestResource, 10, 1, 0, other))
+	ast.Equal(int32(5), other.refs, "P1、申请消息、request queue、pending 和 P0 的回复")
+	p.handle(newMessage(releaseResource, 11, 1, 0, other))
+	ast.Equal(int32(4), other.refs, "多了释放消息，少了 request queue 和 pending")
+	ast.False(p.requestQueue.Contains(other))
+	// 已经在 request queue 中的申请，pending 依然持有自己的引用
+	again := getTimestamp(12, 1)
+	p.enqueue(again)
+	p.handle(newMessage(requestResource, 12, 1, 0, again))
+	ast.Equal(int32(5), again.refs, "P1、申请消息、request queue、pending 和 P0 的回复")
+	// 替换释放丢失了的申请，以及 forget 时，pending 也会放手
+	last := getTimestamp(14, 1)
+	p.handle(newMessage(requestResource, 14, 1, 0, last))
+	ast.Equal(int32(3), again.refs, "只剩下 P1 和两条 message")
+	p.forget(1)
+	ast.Equal(int32(3), last.refs, "只剩下 P1 和两条 message")
+	ast.Empty(p.pending)
+}
@@ -2,15 +2,12 @@ package mutualexclusion
 
 import (
 	"log"
-	"math/rand"
 	"sync"
-	"time"
 )
 
 func init() {
 	log.SetFlags(log.LstdFlags | log.Lmicroseconds)
 	debugPrintf("程序开始运行")
-	rand.Seed(time.Now().UnixNano())
 }
 
 var needDebug = false
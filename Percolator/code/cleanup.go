@@ -0,0 +1,67 @@
+package percolator
+
+// commitRow 把 key 上 startTS 的锁替换为 commitTS 的 write 记录
+// 锁已经不在时返回 false，对 primary 来说，这意味着事务已经被其他事务回滚了
+func (c *Client) commitRow(key string, startTS, commitTS uint64) bool {
+	return updateRow(c.store, key, func(r *row) bool {
+		if r.Lock == nil || r.Lock.StartTS != startTS {
+			return false
+		}
+		r.addWrite(write{CommitTS: commitTS, StartTS: startTS, Delete: r.Lock.Delete})
+		r.Lock = nil
+		return true
+	})
+}
+
+// rollback 回滚 startTS 的事务在 key 上的修改，返回 false 表示事务在这一行已经提交了
+// 回滚会留下一条 Rollback 记录，迟到的 prewrite 会因为它而失败
+func (c *Client) rollback(key string, startTS uint64) bool {
+	var committed bool
+	updateRow(c.store, key, func(r *row) bool {
+		committed = false
+		if w, ok := r.findWrite(startTS); ok {
+			committed = !w.Rollback
+			return false
+		}
+		if r.Lock != nil && r.Lock.StartTS == startTS {
+			r.Lock = nil
+			delete(r.Data, startTS)
+		}
+		r.addWrite(write{CommitTS: startTS, StartTS: startTS, Rollback: true})
+		return true
+	})
+	return !committed
+}
+
+// resolve 清理 key 上属于其他事务的锁 l，返回 false 表示持有锁的事务还活着
+//
+// 事务是否提交只取决于 primary：
+//   - primary 上有 l.StartTS 的 write 记录，事务已经提交，把 l 向前提交
+//   - primary 上有 l.StartTS 的 Rollback 记录，事务已经回滚，把 l 回滚
+//   - primary 上的锁还在有效期内，事务还活着，等它自己结束
+//   - primary 上的锁已经过期，认为 client 崩溃了，先回滚 primary，再回滚 l
+//
+// 回滚 primary 与提交 primary 都是对同一行的 CompareAndSwap，两者只有一个会成功。
+func (c *Client) resolve(key string, l lock) bool {
+	for {
+		p := loadRow(c.store, l.Primary)
+		if w, ok := p.findWrite(l.StartTS); ok {
+			if w.Rollback {
+				c.rollback(key, l.StartTS)
+			} else {
+				c.commitRow(key, l.StartTS, w.CommitTS)
+			}
+			return true
+		}
+		if p.Lock != nil && p.Lock.StartTS == l.StartTS && c.env.Now().UnixNano() < p.Lock.Expires {
+			return false
+		}
+		if c.rollback(l.Primary, l.StartTS) {
+			if key != l.Primary {
+				c.rollback(key, l.StartTS)
+			}
+			return true
+		}
+		// 回滚之前，事务提交了 primary，重新检查
+	}
+}
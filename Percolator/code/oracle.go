@@ -0,0 +1,57 @@
+package percolator
+
+import (
+	"strconv"
+	"sync"
+)
+
+// oracleKey 是 Oracle 在 Store 中保存已分配时间戳上界的 key
+const oracleKey = "percolator/oracle"
+
+// oracleBatch 是 Oracle 每次向 Store 申请的时间戳数量
+const oracleBatch = 1000
+
+// Oracle 是时间戳分配器（timestamp oracle），分配严格递增的时间戳
+//
+// 与 Percolator 的 oracle 一样，Oracle 每次在 Store 中持久化一批时间戳的上界，
+// 然后在内存中分配这一批时间戳，所以大部分时间戳不需要访问 Store。
+// Oracle 重启以后，从持久化的上界开始分配，不会分配出重复或者回退的时间戳。
+//
+// 快照隔离要求后开始的事务拿到更大的时间戳，所以所有的 Client 需要共享同一个 Oracle。
+type Oracle struct {
+	store Store
+
+	mutex sync.Mutex
+	next  uint64 // 下一个分配的时间戳
+	limit uint64 // 已经持久化的上界，next > limit 时需要申请新的一批
+}
+
+// NewOracle 返回一个把上界持久化在 store 中的 Oracle
+func NewOracle(store Store) *Oracle {
+	return &Oracle{
+		store: store,
+		next:  1,
+	}
+}
+
+// Timestamp 返回一个新的时间戳，时间戳从 1 开始
+func (o *Oracle) Timestamp() uint64 {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	for o.next > o.limit {
+		old, _ := o.store.Get(oracleKey)
+		limit, _ := strconv.ParseUint(old, 10, 64)
+		base := limit
+		if o.next-1 > base {
+			base = o.next - 1
+		}
+		if o.store.CompareAndSwap(oracleKey, old, strconv.FormatUint(base+oracleBatch, 10)) {
+			o.next, o.limit = base+1, base+oracleBatch
+		}
+	}
+
+	ts := o.next
+	o.next++
+	return ts
+}
@@ -0,0 +1,57 @@
+package percolator
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Oracle_monotonic(t *testing.T) {
+	ast := assert.New(t)
+	//
+	store := newMemStore()
+	o := NewOracle(store)
+	last := uint64(0)
+	for i := 0; i < 3*oracleBatch; i++ {
+		ts := o.Timestamp()
+		ast.True(ts > last)
+		last = ts
+	}
+	// 只有每一批的第一个时间戳需要访问 store
+	value, _ := store.Get(oracleKey)
+	ast.Equal("3000", value)
+}
+
+func Test_Oracle_restart(t *testing.T) {
+	ast := assert.New(t)
+	//
+	store := newMemStore()
+	first := NewOracle(store).Timestamp()
+	// 重启以后，从持久化的上界之后开始分配
+	second := NewOracle(store).Timestamp()
+	ast.True(second > first+oracleBatch-1)
+}
+
+func Test_Oracle_concurrent(t *testing.T) {
+	ast := assert.New(t)
+	//
+	o := NewOracle(newMemStore())
+	var mutex sync.Mutex
+	seen := make(map[uint64]bool)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 500; j++ {
+				ts := o.Timestamp()
+				mutex.Lock()
+				seen[ts] = true
+				mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	ast.Equal(8*500, len(seen))
+}
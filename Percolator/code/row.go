@@ -0,0 +1,106 @@
+package percolator
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// rowPrefix 是行在 Store 中的 key 的前缀
+const rowPrefix = "percolator/row/"
+
+// lock 是 lock 列
+// 一行同时只能有一个锁，secondary lock 通过 Primary 找到事务的 primary lock
+type lock struct {
+	StartTS uint64
+	Primary string
+	Delete  bool  // 事务删除了这一行
+	Expires int64 // 锁过期的时间，过期以后，其他事务可以清理它
+}
+
+// write 是 write 列中的一项，记录了 StartTS 的事务在 CommitTS 时的提交
+// Rollback 为 true 时，表示 StartTS 的事务被回滚了，此时 CommitTS 等于 StartTS
+type write struct {
+	CommitTS uint64
+	StartTS  uint64
+	Delete   bool
+	Rollback bool
+}
+
+// row 是 Percolator 中的一行，对应 Bigtable 中同一行的 data、lock 和 write 三列
+// 整行作为一个 value 保存在 Store 中，所以对一行的修改可以通过 CompareAndSwap 原子地完成
+type row struct {
+	Data   map[uint64]string `json:",omitempty"` // StartTS -> value
+	Lock   *lock             `json:",omitempty"`
+	Writes []write           `json:",omitempty"` // 按照 CommitTS 从大到小排列
+}
+
+// decodeRow 解析 Store 中保存的行，不存在的行是空字符串
+func decodeRow(raw string) row {
+	var r row
+	if raw != "" {
+		json.Unmarshal([]byte(raw), &r)
+	}
+	if r.Data == nil {
+		r.Data = make(map[uint64]string, 1)
+	}
+	return r
+}
+
+func (r *row) encode() string {
+	b, _ := json.Marshal(r)
+	return string(b)
+}
+
+// lastWrite 返回 CommitTS 不大于 ts 的最新的提交
+func (r *row) lastWrite(ts uint64) (write, bool) {
+	for _, w := range r.Writes {
+		if w.CommitTS <= ts && !w.Rollback {
+			return w, true
+		}
+	}
+	return write{}, false
+}
+
+// findWrite 返回 startTS 的事务在这一行的提交或者回滚记录
+func (r *row) findWrite(startTS uint64) (write, bool) {
+	for _, w := range r.Writes {
+		if w.StartTS == startTS {
+			return w, true
+		}
+	}
+	return write{}, false
+}
+
+// writtenSince 判断是否有事务在 ts 及以后提交或者回滚过这一行
+func (r *row) writtenSince(ts uint64) bool {
+	return len(r.Writes) > 0 && r.Writes[0].CommitTS >= ts
+}
+
+func (r *row) addWrite(w write) {
+	i := sort.Search(len(r.Writes), func(i int) bool {
+		return r.Writes[i].CommitTS < w.CommitTS
+	})
+	r.Writes = append(r.Writes, write{})
+	copy(r.Writes[i+1:], r.Writes[i:])
+	r.Writes[i] = w
+}
+
+// updateRow 用 fn 修改 key 所在的行，fn 返回 false 时放弃修改
+// 行被并发地修改时，重新读取并再次调用 fn，返回修改是否成功
+func updateRow(store Store, key string, fn func(r *row) bool) bool {
+	for {
+		old, _ := store.Get(rowPrefix + key)
+		r := decodeRow(old)
+		if !fn(&r) {
+			return false
+		}
+		if store.CompareAndSwap(rowPrefix+key, old, r.encode()) {
+			return true
+		}
+	}
+}
+
+func loadRow(store Store, key string) row {
+	raw, _ := store.Get(rowPrefix + key)
+	return decodeRow(raw)
+}
@@ -0,0 +1,243 @@
+package percolator
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+)
+
+const (
+	// lockTTL 是锁的有效期，持有锁的 client 超过有效期还没有提交，就被认为已经崩溃
+	lockTTL = time.Second
+	// readRetryInterval 是读取遇到还在有效期内的锁时，重试的间隔
+	readRetryInterval = 20 * time.Millisecond
+)
+
+var (
+	// ErrConflict 表示事务与其他事务写了同一行，需要重试
+	ErrConflict = errors.New("percolator: write-write conflict")
+	// ErrTxnDone 表示事务已经提交过了
+	ErrTxnDone = errors.New("percolator: transaction already committed")
+)
+
+// Store 是 Percolator 使用的 KV store，需要支持单个 key 上的原子操作
+// kvstore.Clerk 实现了 Store
+type Store interface {
+	Get(key string) (string, bool)
+	CompareAndSwap(key, old, new string) bool
+}
+
+// Client 在 Store 上执行快照隔离的事务
+type Client struct {
+	store  Store
+	oracle *Oracle
+	ttl    time.Duration
+	env    sim.Env
+	wg     sync.WaitGroup // 异步提交 secondary 的 goroutine
+}
+
+// NewClient 返回一个 Client，所有的 Client 需要共享同一个 oracle
+func NewClient(store Store, oracle *Oracle) *Client {
+	return NewClientWithEnv(store, oracle, sim.Env{})
+}
+
+// NewClientWithEnv 与 NewClient 一样，但是锁的有效期按照 env 的时间计算
+// 共享 Store 的 Client 需要使用同一个时间，否则会误判其他 Client 的锁已经过期
+func NewClientWithEnv(store Store, oracle *Oracle, env sim.Env) *Client {
+	return &Client{
+		store:  store,
+		oracle: oracle,
+		ttl:    lockTTL,
+		env:    env,
+	}
+}
+
+// Wait 等待所有异步的 secondary 提交完成
+func (c *Client) Wait() {
+	c.wg.Wait()
+}
+
+// Begin 开始一个新的事务，事务读取 StartTS 时的快照
+func (c *Client) Begin() *Txn {
+	return &Txn{
+		c:       c,
+		startTS: c.oracle.Timestamp(),
+		writes:  make(map[string]mutation, 8),
+	}
+}
+
+// mutation 是事务对一行的修改
+type mutation struct {
+	value  string
+	delete bool
+}
+
+// Txn 是一个快照隔离的事务
+// 读取的是 StartTS 时已经提交的数据，写入缓存在 Txn 中，直到 Commit。
+// Txn 不能被多个 goroutine 同时使用。
+type Txn struct {
+	c       *Client
+	startTS uint64
+	writes  map[string]mutation
+	done    bool
+}
+
+// StartTS 返回事务开始的时间戳
+func (tx *Txn) StartTS() uint64 {
+	return tx.startTS
+}
+
+// Get 返回 key 在快照中的值，key 不存在时返回 false
+// 事务会读到自己写入的值
+func (tx *Txn) Get(key string) (string, bool, error) {
+	if tx.done {
+		return "", false, ErrTxnDone
+	}
+	if m, ok := tx.writes[key]; ok {
+		return m.value, !m.delete, nil
+	}
+
+	for {
+		r := loadRow(tx.c.store, key)
+		if r.Lock != nil && r.Lock.StartTS <= tx.startTS {
+			// 持有锁的事务可能在 StartTS 之前提交，需要等它结束，或者清理它
+			if !tx.c.resolve(key, *r.Lock) {
+				tx.c.env.Sleep(readRetryInterval)
+			}
+			continue
+		}
+		w, ok := r.lastWrite(tx.startTS)
+		if !ok || w.Delete {
+			return "", false, nil
+		}
+		return r.Data[w.StartTS], true, nil
+	}
+}
+
+// Set 把 key 的值设置为 value，在 Commit 以后生效
+func (tx *Txn) Set(key, value string) error {
+	if tx.done {
+		return ErrTxnDone
+	}
+	tx.writes[key] = mutation{value: value}
+	return nil
+}
+
+// Delete 删除 key，在 Commit 以后生效
+func (tx *Txn) Delete(key string) error {
+	if tx.done {
+		return ErrTxnDone
+	}
+	tx.writes[key] = mutation{delete: true}
+	return nil
+}
+
+// commitStep 是 Commit 的步骤，测试用它模拟 client 在步骤之间崩溃
+type commitStep int
+
+const (
+	stepPrewrite commitStep = iota
+	stepCommitPrimary
+	stepCommitSecondaries
+)
+
+// Commit 提交事务
+//
+//  1. Prewrite：选择一行作为 primary，先后在 primary 和其他的 secondary 上写入数据和锁。
+//     如果某一行在 StartTS 之后被提交过，或者被其他事务锁住，说明发生了写写冲突，事务回滚
+//  2. 从 Oracle 获取 CommitTS，把 primary 的锁替换为 write 记录。这是事务的提交点
+//  3. 异步地把 secondary 的锁替换为 write 记录
+//
+// 提交点之后 client 崩溃的话，读到 secondary lock 的事务会根据 primary 把它向前提交。
+func (tx *Txn) Commit() error {
+	return tx.commit(stepCommitSecondaries)
+}
+
+func (tx *Txn) commit(last commitStep) error {
+	if tx.done {
+		return ErrTxnDone
+	}
+	tx.done = true
+	if len(tx.writes) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(tx.writes))
+	for key := range tx.writes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	primary, secondaries := keys[0], keys[1:]
+
+	expires := tx.c.env.Now().Add(tx.c.ttl).UnixNano()
+	for i, key := range keys {
+		if err := tx.prewrite(key, primary, expires); err != nil {
+			for _, k := range keys[:i] {
+				tx.c.rollback(k, tx.startTS)
+			}
+			return err
+		}
+	}
+	if last == stepPrewrite {
+		return nil
+	}
+
+	commitTS := tx.c.oracle.Timestamp()
+	if !tx.c.commitRow(primary, tx.startTS, commitTS) {
+		// primary lock 已经被其他事务清理，事务被回滚了
+		for _, k := range secondaries {
+			tx.c.rollback(k, tx.startTS)
+		}
+		return ErrConflict
+	}
+	if last == stepCommitPrimary {
+		return nil
+	}
+
+	tx.c.wg.Add(1)
+	go func() {
+		defer tx.c.wg.Done()
+		for _, key := range secondaries {
+			tx.c.commitRow(key, tx.startTS, commitTS)
+		}
+	}()
+	return nil
+}
+
+// prewrite 在 key 所在的行写入数据和锁
+func (tx *Txn) prewrite(key, primary string, expires int64) error {
+	m := tx.writes[key]
+	for {
+		var conflict bool
+		var blocker *lock
+		ok := updateRow(tx.c.store, key, func(r *row) bool {
+			conflict, blocker = false, nil
+			if r.writtenSince(tx.startTS) {
+				conflict = true
+				return false
+			}
+			if r.Lock != nil {
+				blocker = r.Lock
+				return false
+			}
+			if !m.delete {
+				r.Data[tx.startTS] = m.value
+			}
+			r.Lock = &lock{StartTS: tx.startTS, Primary: primary, Delete: m.delete, Expires: expires}
+			return true
+		})
+		switch {
+		case ok:
+			return nil
+		case conflict:
+			return ErrConflict
+		}
+		// 被其他事务的锁挡住，只有它已经结束或者过期时，才能继续
+		if !tx.c.resolve(key, *blocker) {
+			return ErrConflict
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package percolator
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	kvstore "github.com/aQuaYi/Distributed-Algorithms/KV-Store/code"
+	"github.com/stretchr/testify/assert"
+)
+
+// memStore 是内存中的 Store
+type memStore struct {
+	mutex sync.Mutex
+	data  map[string]string
+}
+
+func newMemStore() *memStore {
+	return &memStore{data: make(map[string]string)}
+}
+
+func (s *memStore) Get(key string) (string, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	value, ok := s.data[key]
+	return value, ok
+}
+
+func (s *memStore) CompareAndSwap(key, old, new string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.data[key] != old {
+		return false
+	}
+	s.data[key] = new
+	return true
+}
+
+func set(ast *assert.Assertions, c *Client, key, value string) {
+	tx := c.Begin()
+	ast.Nil(tx.Set(key, value))
+	ast.Nil(tx.Commit())
+}
+
+func get(ast *assert.Assertions, c *Client, key string) string {
+	value, _, err := c.Begin().Get(key)
+	ast.Nil(err)
+	return value
+}
+
+func Test_Txn_snapshotIsolation(t *testing.T) {
+	ast := assert.New(t)
+	//
+	store := newMemStore()
+	c := NewClient(store, NewOracle(store))
+	set(ast, c, "x", "1")
+	//
+	t1 := c.Begin()
+	set(ast, c, "x", "2")
+	// t1 读取的是开始时的快照
+	value, exists, err := t1.Get("x")
+	ast.Nil(err)
+	ast.True(exists)
+	ast.Equal("1", value)
+	ast.Equal("2", get(ast, c, "x"))
+	//
+	tx := c.Begin()
+	ast.Nil(tx.Delete("x"))
+	ast.Nil(tx.Commit())
+	_, exists, _ = c.Begin().Get("x")
+	ast.False(exists)
+	ast.Equal(ErrTxnDone, tx.Commit())
+}
+
+func Test_Txn_writeWriteConflict(t *testing.T) {
+	ast := assert.New(t)
+	//
+	store := newMemStore()
+	c := NewClient(store, NewOracle(store))
+	//
+	t1, t2 := c.Begin(), c.Begin()
+	ast.Nil(t1.Set("x", "t1"))
+	ast.Nil(t1.Set("y", "t1"))
+	ast.Nil(t2.Set("y", "t2"))
+	ast.Nil(t2.Set("z", "t2"))
+	ast.Nil(t1.Commit())
+	// t1 在 t2 开始以后提交了 y
+	ast.Equal(ErrConflict, t2.Commit())
+	c.Wait()
+	ast.Equal("t1", get(ast, c, "y"))
+	// t2 被回滚，z 上没有留下数据
+	_, exists, _ := c.Begin().Get("z")
+	ast.False(exists)
+}
+
+// Test_Txn_writeSkew 展示了快照隔离不是可串行化的
+// 两个事务读取同样的数据，写入不同的行，不会发生写写冲突，都可以提交
+func Test_Txn_writeSkew(t *testing.T) {
+	ast := assert.New(t)
+	//
+	store := newMemStore()
+	c := NewClient(store, NewOracle(store))
+	set(ast, c, "alice", "on-call")
+	set(ast, c, "bob", "on-call")
+	c.Wait()
+	// 两个医生都确认另一个人在值班，然后各自下班
+	t1, t2 := c.Begin(), c.Begin()
+	other, _, _ := t1.Get("bob")
+	ast.Equal("on-call", other)
+	ast.Nil(t1.Set("alice", "off"))
+	other, _, _ = t2.Get("alice")
+	ast.Equal("on-call", other)
+	ast.Nil(t2.Set("bob", "off"))
+	ast.Nil(t1.Commit())
+	ast.Nil(t2.Commit())
+	c.Wait()
+	ast.Equal("off", get(ast, c, "alice"))
+	ast.Equal("off", get(ast, c, "bob"))
+}
+
+func Test_Txn_crashBeforeCommitPoint(t *testing.T) {
+	ast := assert.New(t)
+	//
+	store := newMemStore()
+	oracle := NewOracle(store)
+	crashed := NewClient(store, oracle)
+	crashed.ttl = 100 * time.Millisecond
+	c := NewClient(store, oracle)
+	set(ast, c, "b", "old")
+	c.Wait()
+	//
+	tx := crashed.Begin()
+	ast.Nil(tx.Set("a", "new"))
+	ast.Nil(tx.Set("b", "new"))
+	ast.Nil(tx.commit(stepPrewrite))
+	// 读到 secondary lock 以后，等到 primary lock 过期，再回滚事务
+	begin := time.Now()
+	ast.Equal("old", get(ast, c, "b"))
+	ast.True(time.Since(begin) >= 50*time.Millisecond)
+	_, exists, _ := c.Begin().Get("a")
+	ast.False(exists)
+	ast.Nil(loadRow(store, "a").Lock, "primary lock 被清理了")
+	// 迟到的 prewrite 会遇到 Rollback 记录
+	ast.Equal(ErrConflict, tx.prewrite("a", "a", time.Now().Add(time.Second).UnixNano()))
+}
+
+func Test_Txn_crashAfterCommitPoint(t *testing.T) {
+	ast := assert.New(t)
+	//
+	store := newMemStore()
+	oracle := NewOracle(store)
+	crashed := NewClient(store, oracle)
+	c := NewClient(store, oracle)
+	//
+	tx := crashed.Begin()
+	ast.Nil(tx.Set("a", "new"))
+	ast.Nil(tx.Set("b", "new"))
+	ast.Nil(tx.commit(stepCommitPrimary))
+	ast.NotNil(loadRow(store, "b").Lock)
+	// primary 已经提交，secondary lock 被立即向前提交，不需要等锁过期
+	begin := time.Now()
+	ast.Equal("new", get(ast, c, "b"))
+	ast.True(time.Since(begin) < lockTTL/2)
+	ast.Nil(loadRow(store, "b").Lock)
+}
+
+func Test_Txn_readWaitsForLiveLock(t *testing.T) {
+	ast := assert.New(t)
+	//
+	store := newMemStore()
+	c := NewClient(store, NewOracle(store))
+	//
+	tx := c.Begin()
+	ast.Nil(tx.Set("a", "1"))
+	ast.Nil(tx.Set("b", "1"))
+	ast.Nil(tx.commit(stepPrewrite))
+	reader := c.Begin()
+	done := make(chan string)
+	go func() {
+		value, _, _ := reader.Get("b")
+		done <- value
+	}()
+	// tx 在 reader 开始之后提交，reader 看不到它的写入，但需要等它结束才能确定
+	time.Sleep(50 * time.Millisecond)
+	commitTS := c.oracle.Timestamp()
+	ast.True(c.commitRow("a", tx.startTS, commitTS))
+	ast.True(c.commitRow("b", tx.startTS, commitTS))
+	ast.Equal("", <-done)
+	ast.Equal("1", get(ast, c, "b"))
+}
+
+// Test_Txn_bankTransfer 在通过 raft 复制的 KV Store 上并发地转账
+// 发生写写冲突的事务重试，转账前后的总金额不变
+func Test_Txn_bankTransfer(t *testing.T) {
+	ast := assert.New(t)
+	//
+	const accounts, initial, clients, transfers = 4, 100, 3, 3
+	cluster := kvstore.MakeCluster(3)
+	defer cluster.Cleanup()
+	oracle := NewOracle(cluster.MakeClerk())
+	name := func(i int) string {
+		return fmt.Sprintf("account-%d", i)
+	}
+	c := NewClient(cluster.MakeClerk(), oracle)
+	tx := c.Begin()
+	for i := 0; i < accounts; i++ {
+		ast.Nil(tx.Set(name(i), strconv.Itoa(initial)))
+	}
+	ast.Nil(tx.Commit())
+	//
+	conflicts := 0
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c := NewClient(cluster.MakeClerk(), oracle)
+			rnd := rand.New(rand.NewSource(int64(i)))
+			for j := 0; j < transfers; j++ {
+				from := rnd.Intn(accounts)
+				to := (from + 1 + rnd.Intn(accounts-1)) % accounts
+				for {
+					tx := c.Begin()
+					a, _, _ := tx.Get(name(from))
+					b, _, _ := tx.Get(name(to))
+					x, _ := strconv.Atoi(a)
+					y, _ := strconv.Atoi(b)
+					tx.Set(name(from), strconv.Itoa(x-10))
+					tx.Set(name(to), strconv.Itoa(y+10))
+					if tx.Commit() == nil {
+						break
+					}
+					mutex.Lock()
+					conflicts++
+					mutex.Unlock()
+				}
+			}
+			c.Wait()
+		}(i)
+	}
+	wg.Wait()
+	//
+	total := 0
+	snapshot := c.Begin()
+	for i := 0; i < accounts; i++ {
+		value, _, err := snapshot.Get(name(i))
+		ast.Nil(err)
+		n, _ := strconv.Atoi(value)
+		total += n
+	}
+	ast.Equal(accounts*initial, total)
+	t.Logf("%d 个转账，%d 次写写冲突", clients*transfers, conflicts)
+}
@@ -0,0 +1,93 @@
+package probabilistic
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+)
+
+// Bloom 是 Bloom filter，用 m 个 bit 表示一个集合
+//
+// 加入一个元素时，把 k 个 hash 函数指向的 bit 都设置为 1；
+// 查询时，只要有一个 bit 是 0，元素就一定不在集合中，否则元素可能在集合中。
+// 所以 Bloom filter 没有 false negative，只有 false positive。
+//
+// k 个 hash 函数由两个 hash 值组合而成：g_i(x) = h1(x) + i·h2(x)，
+// 这样做不会明显提高 false positive 的概率（Kirsch & Mitzenmacher）。
+type Bloom struct {
+	Bits []uint64
+	M    uint64 // bit 的数量
+	K    uint64 // hash 函数的数量
+	Seed uint64 // 不同的 Seed 让同样的元素映射到不同的 bit 上
+}
+
+// NewBloom 返回一个 Bloom filter，加入 n 个元素以后，false positive 的概率约为 p
+// m = -n·ln(p) / (ln2)^2，k = m/n · ln2
+func NewBloom(n int, p float64, seed uint64) *Bloom {
+	if n < 1 {
+		n = 1
+	}
+	m := uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	if m < 64 {
+		m = 64
+	}
+	k := uint64(math.Round(float64(m) / float64(n) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return &Bloom{
+		Bits: make([]uint64, (m+63)/64),
+		M:    m,
+		K:    k,
+		Seed: seed,
+	}
+}
+
+func (b *Bloom) hashes(key string) (uint64, uint64) {
+	h := fnv.New64a()
+	var seed [8]byte
+	binary.LittleEndian.PutUint64(seed[:], b.Seed)
+	h.Write(seed[:])
+	h.Write([]byte(key))
+	h1 := h.Sum64()
+	h.Write([]byte{0xff})
+	h2 := h.Sum64() | 1 // h2 是奇数，避免 k 个位置重合
+	return h1, h2
+}
+
+// Add 把 key 加入集合
+func (b *Bloom) Add(key string) {
+	h1, h2 := b.hashes(key)
+	for i := uint64(0); i < b.K; i++ {
+		bit := (h1 + i*h2) % b.M
+		b.Bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+// Test 返回 false 时，key 一定不在集合中；返回 true 时，key 可能在集合中
+func (b *Bloom) Test(key string) bool {
+	h1, h2 := b.hashes(key)
+	for i := uint64(0); i < b.K; i++ {
+		bit := (h1 + i*h2) % b.M
+		if b.Bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// EstimatedFPR 根据被设置为 1 的 bit 的比例，估计当前 false positive 的概率
+func (b *Bloom) EstimatedFPR() float64 {
+	ones := 0
+	for _, w := range b.Bits {
+		for ; w != 0; w &= w - 1 {
+			ones++
+		}
+	}
+	return math.Pow(float64(ones)/float64(b.M), float64(b.K))
+}
+
+// Size 返回 Bloom filter 在消息中占用的字节数
+func (b *Bloom) Size() int {
+	return 8*len(b.Bits) + 8*3
+}
@@ -0,0 +1,69 @@
+package probabilistic
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Bloom_falsePositiveRate(t *testing.T) {
+	ast := assert.New(t)
+	//
+	for _, p := range []float64{0.1, 0.01, 0.001} {
+		n := 10000
+		b := NewBloom(n, p, 1)
+		for i := 0; i < n; i++ {
+			b.Add(fmt.Sprintf("member-%d", i))
+		}
+		// 没有 false negative
+		for i := 0; i < n; i++ {
+			ast.True(b.Test(fmt.Sprintf("member-%d", i)))
+		}
+		trials, fp := 100000, 0
+		for i := 0; i < trials; i++ {
+			if b.Test(fmt.Sprintf("other-%d", i)) {
+				fp++
+			}
+		}
+		rate := float64(fp) / float64(trials)
+		ast.True(rate < 1.5*p, "p=%v, rate=%v", p, rate)
+		ast.True(math.Abs(b.EstimatedFPR()-p) < 0.3*p, "p=%v, estimated=%v", p, b.EstimatedFPR())
+		t.Logf("p=%v: %d bit，%d 个 hash，实际 false positive 率 %.4f", p, b.M, b.K, rate)
+	}
+}
+
+func Test_Bloom_seed(t *testing.T) {
+	ast := assert.New(t)
+	//
+	// 不同的 Seed 下，false positive 的元素不同
+	n := 1000
+	a, b := NewBloom(n, 0.05, 1), NewBloom(n, 0.05, 2)
+	for i := 0; i < n; i++ {
+		a.Add(fmt.Sprint(i))
+		b.Add(fmt.Sprint(i))
+	}
+	both, either := 0, 0
+	for i := n; i < n+10000; i++ {
+		x, y := a.Test(fmt.Sprint(i)), b.Test(fmt.Sprint(i))
+		if x && y {
+			both++
+		}
+		if x || y {
+			either++
+		}
+	}
+	ast.True(both*5 < either, "both=%d, either=%d", both, either)
+}
+
+func Test_NewBloom_size(t *testing.T) {
+	ast := assert.New(t)
+	//
+	b := NewBloom(1000, 0.01, 0)
+	// 每个元素约 9.6 bit，7 个 hash 函数
+	ast.Equal(uint64(9586), b.M)
+	ast.Equal(uint64(7), b.K)
+	ast.Equal(8*150+24, b.Size())
+	ast.Equal(uint64(64), NewBloom(0, 0.5, 0).M)
+}
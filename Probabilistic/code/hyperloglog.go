@@ -0,0 +1,91 @@
+package probabilistic
+
+import (
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+// HyperLogLog 用 2^P 个很小的 register 估计集合中不同元素的数量
+//
+// 元素的 hash 的前 P 个 bit 选择 register，register 记录剩下的 bit 中，
+// 第一个 1 出现的最大位置。位置越靠后的 1 越罕见，所以它反映了元素的数量。
+// 估计值的相对标准误差约为 1.04/√(2^P)，与元素的数量无关。
+//
+// 两个 HyperLogLog 按 register 取最大值就是并集的估计，重复的元素不会被重复计数。
+type HyperLogLog struct {
+	P         uint8
+	Registers []uint8
+}
+
+// NewHyperLogLog 返回有 2^p 个 register 的 HyperLogLog，4 <= p <= 16
+func NewHyperLogLog(p uint8) *HyperLogLog {
+	if p < 4 {
+		p = 4
+	}
+	if p > 16 {
+		p = 16
+	}
+	return &HyperLogLog{
+		P:         p,
+		Registers: make([]uint8, 1<<p),
+	}
+}
+
+// Add 把 key 加入集合
+func (h *HyperLogLog) Add(key string) {
+	f := fnv.New64a()
+	f.Write([]byte(key))
+	x := mix(f.Sum64())
+	i := x >> (64 - h.P)
+	rank := uint8(bits.LeadingZeros64(x<<h.P|1<<(h.P-1))) + 1
+	if rank > h.Registers[i] {
+		h.Registers[i] = rank
+	}
+}
+
+// mix 打散 fnv 的结果，让高位也足够随机
+func mix(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// Merge 把 other 合并到 h 中，两者的 P 必须相同
+func (h *HyperLogLog) Merge(other *HyperLogLog) {
+	if h.P != other.P {
+		panic("probabilistic: merging HyperLogLogs of different precision")
+	}
+	for i, r := range other.Registers {
+		if r > h.Registers[i] {
+			h.Registers[i] = r
+		}
+	}
+}
+
+// Count 返回集合中不同元素数量的估计值
+func (h *HyperLogLog) Count() uint64 {
+	m := float64(len(h.Registers))
+	sum, zeros := 0.0, 0
+	for _, r := range h.Registers {
+		sum += 1 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+	alpha := 0.7213 / (1 + 1.079/m)
+	estimate := alpha * m * m / sum
+	// 元素较少时，很多 register 还是 0，用 linear counting 更准确
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return uint64(estimate + 0.5)
+}
+
+// Size 返回 HyperLogLog 在消息中占用的字节数
+func (h *HyperLogLog) Size() int {
+	return len(h.Registers) + 1
+}
@@ -0,0 +1,43 @@
+package probabilistic
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_HyperLogLog_Count(t *testing.T) {
+	ast := assert.New(t)
+	//
+	for _, n := range []int{10, 1000, 100000} {
+		h := NewHyperLogLog(12)
+		for i := 0; i < n; i++ {
+			key := fmt.Sprintf("key-%d", i)
+			h.Add(key)
+			h.Add(key)
+		}
+		count := float64(h.Count())
+		ast.True(math.Abs(count-float64(n)) <= 0.05*float64(n)+1, "n=%d, count=%v", n, count)
+	}
+	ast.Equal(uint64(0), NewHyperLogLog(12).Count())
+}
+
+func Test_HyperLogLog_Merge(t *testing.T) {
+	ast := assert.New(t)
+	//
+	a, b := NewHyperLogLog(12), NewHyperLogLog(12)
+	// 两个集合有一半的元素相同
+	for i := 0; i < 20000; i++ {
+		a.Add(fmt.Sprint(i))
+	}
+	for i := 10000; i < 30000; i++ {
+		b.Add(fmt.Sprint(i))
+	}
+	a.Merge(b)
+	count := float64(a.Count())
+	ast.True(math.Abs(count-30000) < 0.05*30000, "%v", count)
+	ast.Equal(4097, a.Size())
+	ast.Panics(func() { a.Merge(NewHyperLogLog(10)) })
+}
@@ -0,0 +1,121 @@
+package quorum
+
+import (
+	"sort"
+	"strings"
+
+	merkle "github.com/aQuaYi/Distributed-Algorithms/Merkle/code"
+)
+
+// merkleDepth 是 anti-entropy 使用的 Merkle 树的深度
+const merkleDepth = 10
+
+// SyncStats 统计了一次 anti-entropy 同步交换的数据量
+type SyncStats struct {
+	Hashes   int // 交换的 hash 数量
+	Keys     int // 交换的 key 数量，每个 key 会连同它的所有版本一起发送
+	Repaired int // 因此发生了变化的 key 的副本数量
+}
+
+// AntiEntropy 用 Merkle 树比较 node a 和 b 共同负责的 key，只交换不一致的 bucket 中的 key
+// read repair 和 hinted handoff 都只能修复一部分不一致，anti-entropy 在后台定期运行，
+// 保证所有的 replica 最终一致
+func (c *Cluster) AntiEntropy(a, b string) SyncStats {
+	ra, rb := c.replicas[a], c.replicas[b]
+	if !ra.isUp() || !rb.isUp() {
+		return SyncStats{}
+	}
+	ta := merkle.Build(merkleDepth, c.digests(ra, b))
+	tb := merkle.Build(merkleDepth, c.digests(rb, a))
+	buckets, compared := merkle.Diff(ta, tb)
+	st := SyncStats{Hashes: compared}
+	for _, bucket := range buckets {
+		keys := union(ta.Keys(bucket), tb.Keys(bucket))
+		st.Keys += len(keys)
+		for _, key := range keys {
+			st.Repaired += c.exchange(ra, rb, key)
+		}
+	}
+	return st
+}
+
+// FullSync 交换 node a 和 b 共同负责的所有 key，作为 AntiEntropy 的对照
+func (c *Cluster) FullSync(a, b string) SyncStats {
+	ra, rb := c.replicas[a], c.replicas[b]
+	if !ra.isUp() || !rb.isUp() {
+		return SyncStats{}
+	}
+	var keys []string
+	for key := range c.digests(ra, b) {
+		keys = append(keys, key)
+	}
+	for key := range c.digests(rb, a) {
+		keys = append(keys, key)
+	}
+	keys = union(keys)
+	st := SyncStats{Keys: len(keys)}
+	for _, key := range keys {
+		st.Repaired += c.exchange(ra, rb, key)
+	}
+	return st
+}
+
+// digests 返回 r 中与 other 共同负责的 key，以及它们的版本的摘要
+func (c *Cluster) digests(r *replica, other string) map[string][]byte {
+	res := make(map[string][]byte)
+	for _, key := range r.keys() {
+		pl := c.PreferenceList(key)
+		if !contains(pl, r.name) || !contains(pl, other) {
+			continue
+		}
+		vs := r.fetch(key)
+		parts := make([]string, len(vs))
+		for i, v := range vs {
+			parts[i] = v.String()
+		}
+		sort.Strings(parts)
+		res[key] = []byte(strings.Join(parts, "\n"))
+	}
+	return res
+}
+
+// exchange 让 a 和 b 互相补上 key 缺少的版本，返回发生了变化的副本数量
+func (c *Cluster) exchange(a, b *replica, key string) int {
+	siblings := reconcile(append(a.fetch(key), b.fetch(key)...))
+	repaired := 0
+	if a.store(key, siblings...) {
+		repaired++
+	}
+	if b.store(key, siblings...) {
+		repaired++
+	}
+	c.mu.Lock()
+	c.stats.AntiEntropyRepairs += repaired
+	c.mu.Unlock()
+	return repaired
+}
+
+// union 返回去重并排序以后的 key
+func union(lists ...[]string) []string {
+	seen := make(map[string]bool)
+	var res []string
+	for _, list := range lists {
+		for _, key := range list {
+			if !seen[key] {
+				seen[key] = true
+				res = append(res, key)
+			}
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+func contains(nodes []string, node string) bool {
+	for _, n := range nodes {
+		if n == node {
+			return true
+		}
+	}
+	return false
+}
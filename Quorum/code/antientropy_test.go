@@ -0,0 +1,97 @@
+package quorum
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// divergedCluster 写入 keys 个 key，其中 missed 个是在 node A 失效期间写入的
+// 没有 hinted handoff，A 恢复以后缺少这些写入
+func divergedCluster(t testing.TB, keys, missed int) (*Cluster, []string) {
+	c, err := NewCluster(nodes, Config{N: 3, R: 2, W: 2}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := make([]string, keys)
+	for i := range all {
+		all[i] = fmt.Sprintf("key%d", i)
+		if i == keys-missed {
+			c.Settle()
+			c.Down("A")
+		}
+		c.Put(all[i], "v", nil)
+	}
+	c.Settle()
+	c.Up("A")
+	return c, all
+}
+
+func Test_Cluster_AntiEntropy(t *testing.T) {
+	ast := assert.New(t)
+	//
+	const keys, missed = 5000, 20
+	c, all := divergedCluster(t, keys, missed)
+	ast.True(staleReplicas(c, all) > 0)
+	full, _ := divergedCluster(t, keys, missed)
+	//
+	var merkleStats, fullStats SyncStats
+	for i, a := range nodes {
+		for _, b := range nodes[i+1:] {
+			st := c.AntiEntropy(a, b)
+			merkleStats.Hashes += st.Hashes
+			merkleStats.Keys += st.Keys
+			merkleStats.Repaired += st.Repaired
+			st = full.FullSync(a, b)
+			fullStats.Keys += st.Keys
+			fullStats.Repaired += st.Repaired
+		}
+	}
+	ast.Equal(0, staleReplicas(c, all))
+	ast.Equal(0, staleReplicas(full, all))
+	ast.Equal(fullStats.Repaired, merkleStats.Repaired)
+	ast.Equal(merkleStats.Repaired, c.Stats().AntiEntropyRepairs)
+	// Merkle 树只交换了不一致的 bucket 中的 key
+	ast.True(merkleStats.Keys*10 < fullStats.Keys, "%v %v", merkleStats, fullStats)
+	t.Logf("Merkle: %d 个 hash，%d 个 key；全量交换：%d 个 key；修复了 %d 个副本",
+		merkleStats.Hashes, merkleStats.Keys, fullStats.Keys, fullStats.Repaired)
+	// 已经一致的 replica 之间，只需要比较 root
+	st := c.AntiEntropy("A", "B")
+	ast.Equal(SyncStats{Hashes: 1}, st)
+}
+
+func Test_Cluster_AntiEntropyNodeDown(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c, _ := divergedCluster(t, 100, 10)
+	c.Down("B")
+	ast.Equal(SyncStats{}, c.AntiEntropy("A", "B"))
+	ast.Equal(SyncStats{}, c.FullSync("A", "B"))
+}
+
+func Benchmark_AntiEntropy(b *testing.B) {
+	syncs := []struct {
+		name string
+		sync func(c *Cluster, a, b string) SyncStats
+	}{
+		{"merkle", (*Cluster).AntiEntropy},
+		{"full", (*Cluster).FullSync},
+	}
+	for _, keys := range []int{1000, 10000} {
+		for _, s := range syncs {
+			b.Run(fmt.Sprintf("%s/%d", s.name, keys), func(b *testing.B) {
+				exchanged := 0
+				for i := 0; i < b.N; i++ {
+					b.StopTimer()
+					c, _ := divergedCluster(b, keys, 10)
+					b.StartTimer()
+					for _, node := range nodes[1:] {
+						exchanged += s.sync(c, "A", node).Keys
+					}
+				}
+				b.ReportMetric(float64(exchanged)/float64(b.N), "keys/op")
+			})
+		}
+	}
+}
@@ -0,0 +1,300 @@
+package quorum
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+)
+
+// ErrInsufficientReplicas 表示能够访问的 replica 不够 R 或 W 个
+var ErrInsufficientReplicas = errors.New("quorum: not enough replicas are reachable")
+
+// Config 是 Dynamo 风格的可调一致性参数
+type Config struct {
+	// N 是每个 key 的副本数量
+	N int
+	// R 是一次读取至少需要的响应数量
+	R int
+	// W 是一次写入至少需要的确认数量
+	W int
+	// Sloppy 为 true 时，preference list 中失效的 node 会被环上后续健康的 node 替代，
+	// 替代者带着 hint 保存写入，等原本的 node 恢复以后再交还给它
+	Sloppy bool
+	// HintedHandoff 为 true 时，没有替代者的失效 node 错过的写入，由 coordinator 保存为 hint，
+	// 等 node 恢复以后重放给它
+	HintedHandoff bool
+}
+
+// Strict 返回 true，如果 R+W>N，此时读取和写入的 replica 必然有交集，
+// 在没有 sloppy 替代的情况下，总能读到最新的写入
+func (c Config) Strict() bool {
+	return c.R+c.W > c.N
+}
+
+func (c Config) String() string {
+	return fmt.Sprintf("N%d:R%d:W%d:Sloppy:%t:Hints:%t", c.N, c.R, c.W, c.Sloppy, c.HintedHandoff)
+}
+
+// Stats 统计了集群中发生的修复行为
+type Stats struct {
+	ReadRepairs        int // 在读取时修复的 replica 数量
+	BackgroundRepairs  int // 读取发现不一致以后，在后台修复的 replica 数量
+	HintsStored        int // coordinator 替失效的 node 保存的 hint 数量
+	HintedHandoffs     int // 交还给原本 node 的 hint 数量
+	AntiEntropyRepairs int // 通过 anti-entropy 修复的 replica 数量
+}
+
+// target 是一次读写需要访问的 replica
+// 如果 replica 是 sloppy 替代者，hintFor 是它所替代的 node
+type target struct {
+	replica *replica
+	hintFor string
+}
+
+// delivery 是已经返回给客户端，但还没有送达的写入，或者后台的 read repair
+type delivery struct {
+	target      target
+	key         string
+	versions    []Version
+	coordinator *replica // 写入的 coordinator，target 失效时由它保存 hint，read repair 为 nil
+}
+
+// Cluster 模拟了 Dynamo 风格的复制存储
+// 写入会发送给 N 个 replica，但在收到 W 个确认后就会返回，剩下的写入要等到 Settle 时才会送达。
+// 读取只会等待 R 个 replica 的响应。哪些 replica 先响应是随机的。
+type Cluster struct {
+	mu       sync.Mutex
+	config   Config
+	ring     *ring
+	replicas map[string]*replica
+	rand     *rand.Rand
+	inflight []delivery
+	stats    Stats
+}
+
+// NewCluster 返回由 nodes 组成的集群，seed 决定了 replica 响应的先后顺序
+func NewCluster(nodes []string, config Config, seed int64) (*Cluster, error) {
+	if config.N < 1 || config.N > len(nodes) ||
+		config.R < 1 || config.R > config.N ||
+		config.W < 1 || config.W > config.N {
+		return nil, fmt.Errorf("quorum: invalid config %s for %d nodes", config, len(nodes))
+	}
+	c := &Cluster{
+		config:   config,
+		ring:     newRing(nodes),
+		replicas: make(map[string]*replica, len(nodes)),
+		rand:     rand.New(rand.NewSource(seed)),
+	}
+	for _, node := range nodes {
+		if _, ok := c.replicas[node]; ok {
+			return nil, fmt.Errorf("quorum: duplicate node %s", node)
+		}
+		c.replicas[node] = newReplica(node)
+	}
+	return c, nil
+}
+
+// Config 返回集群的参数
+func (c *Cluster) Config() Config {
+	return c.config
+}
+
+// PreferenceList 返回负责保存 key 的 N 个 node
+func (c *Cluster) PreferenceList(key string) []string {
+	return c.ring.walk(key)[:c.config.N]
+}
+
+// Down 让 node 失效，失效的 node 不会响应任何读写
+func (c *Cluster) Down(node string) {
+	c.replicas[node].setUp(false)
+}
+
+// Up 恢复 node，其他 node 替它保管的写入会立即交还给它
+// node 失效期间，它替别人保管的写入也无法交还，现在一并交还
+func (c *Cluster) Up(node string) {
+	r := c.replicas[node]
+	r.setUp(true)
+	for _, other := range c.replicas {
+		if other == r || !other.isUp() {
+			continue
+		}
+		c.handoff(other, r)
+		c.handoff(r, other)
+	}
+}
+
+// handoff 把 holder 替 to 保管的写入交还给 to
+func (c *Cluster) handoff(holder, to *replica) {
+	hs := holder.takeHints(to.name)
+	for _, h := range hs {
+		to.store(h.key, h.version)
+	}
+	for _, h := range hs {
+		if h.substitute {
+			holder.release(h.key)
+		}
+	}
+	c.mu.Lock()
+	c.stats.HintedHandoffs += len(hs)
+	c.mu.Unlock()
+}
+
+// targets 返回 key 的读写需要访问的 replica，顺序是随机的
+func (c *Cluster) targets(key string) []target {
+	all := c.ring.walk(key)
+	res := make([]target, 0, c.config.N)
+	next := c.config.N // 下一个可以作为替代者的 node
+	for _, node := range all[:c.config.N] {
+		if r := c.replicas[node]; r.isUp() {
+			res = append(res, target{replica: r})
+			continue
+		}
+		if !c.config.Sloppy {
+			continue
+		}
+		for ; next < len(all); next++ {
+			if r := c.replicas[all[next]]; r.isUp() {
+				res = append(res, target{replica: r, hintFor: node})
+				next++
+				break
+			}
+		}
+	}
+	c.mu.Lock()
+	c.rand.Shuffle(len(res), func(i, j int) {
+		res[i], res[j] = res[j], res[i]
+	})
+	c.mu.Unlock()
+	return res
+}
+
+// write 返回 true，如果 replica 的内容因此发生了变化
+func (t target) write(key string, vs ...Version) bool {
+	if t.hintFor != "" {
+		return t.replica.keepHint(t.hintFor, key, vs...)
+	}
+	return t.replica.store(key, vs...)
+}
+
+// node 返回 target 原本应该写入的 node
+func (t target) node() string {
+	if t.hintFor != "" {
+		return t.hintFor
+	}
+	return t.replica.name
+}
+
+// Put 把 key 设置为 value
+// ctx 是客户端读取 key 时得到的 Context，新的写入会取代 ctx 所包含的所有版本。
+// 返回新版本的 VClock
+func (c *Cluster) Put(key, value string, ctx VClock) (VClock, error) {
+	ts := c.targets(key)
+	if len(ts) < c.config.W {
+		return nil, ErrInsufficientReplicas
+	}
+	// preference list 中第一个健康的 node 作为 coordinator
+	coordinator := ts[0].replica
+	for _, node := range c.ring.walk(key) {
+		if r := c.replicas[node]; r.isUp() {
+			coordinator = r
+			break
+		}
+	}
+	v := Version{Value: value, Clock: coordinator.stamp(ctx)}
+	for _, t := range ts[:c.config.W] {
+		t.write(key, v)
+	}
+	c.mu.Lock()
+	for _, t := range ts[c.config.W:] {
+		c.inflight = append(c.inflight, delivery{target: t, key: key, versions: []Version{v}, coordinator: coordinator})
+	}
+	c.mu.Unlock()
+	if c.config.HintedHandoff {
+		c.hintMissed(coordinator, key, v, ts)
+	}
+	return v.Clock, nil
+}
+
+// hintMissed 让 coordinator 替 preference list 中失效、并且没有替代者的 node 保存 v
+func (c *Cluster) hintMissed(coordinator *replica, key string, v Version, ts []target) {
+	covered := make(map[string]bool, len(ts))
+	for _, t := range ts {
+		covered[t.node()] = true
+	}
+	for _, node := range c.PreferenceList(key) {
+		if covered[node] {
+			continue
+		}
+		coordinator.holdHint(node, key, v)
+		c.mu.Lock()
+		c.stats.HintsStored++
+		c.mu.Unlock()
+	}
+}
+
+// Get 返回 key 的所有 siblings，如果 key 不存在，返回空的 siblings
+// 响应的 replica 中缺少版本的，会被顺便修复。
+// 如果响应之间不一致，说明其他没有响应的 replica 也可能缺少版本，它们会在后台被修复
+func (c *Cluster) Get(key string) ([]Version, error) {
+	ts := c.targets(key)
+	if len(ts) < c.config.R {
+		return nil, ErrInsufficientReplicas
+	}
+	responders, others := ts[:c.config.R], ts[c.config.R:]
+	var all []Version
+	for _, t := range responders {
+		all = append(all, t.replica.fetch(key)...)
+	}
+	siblings := reconcile(all)
+	// read repair
+	repairs := 0
+	for _, t := range responders {
+		if t.replica.store(key, siblings...) {
+			repairs++
+		}
+	}
+	c.mu.Lock()
+	c.stats.ReadRepairs += repairs
+	if repairs > 0 {
+		for _, t := range others {
+			c.inflight = append(c.inflight, delivery{target: t, key: key, versions: siblings})
+		}
+	}
+	c.mu.Unlock()
+	return siblings, nil
+}
+
+// Settle 送达所有还在路上的写入，并完成后台的 read repair
+// 送往失效 node 的写入，在开启 HintedHandoff 时由 coordinator 保存为 hint，否则会被丢弃
+func (c *Cluster) Settle() {
+	c.mu.Lock()
+	ds := c.inflight
+	c.inflight = nil
+	c.mu.Unlock()
+	for _, d := range ds {
+		switch {
+		case d.target.replica.isUp():
+			changed := d.target.write(d.key, d.versions...)
+			if changed && d.coordinator == nil {
+				c.mu.Lock()
+				c.stats.BackgroundRepairs++
+				c.mu.Unlock()
+			}
+		case d.coordinator != nil && c.config.HintedHandoff && d.coordinator.isUp():
+			for _, v := range d.versions {
+				d.coordinator.holdHint(d.target.node(), d.key, v)
+			}
+			c.mu.Lock()
+			c.stats.HintsStored += len(d.versions)
+			c.mu.Unlock()
+		}
+	}
+}
+
+// Stats 返回集群的统计数据
+func (c *Cluster) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
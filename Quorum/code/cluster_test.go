@@ -0,0 +1,277 @@
+package quorum
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var nodes = []string{"A", "B", "C", "D", "E"}
+
+func newTestCluster(t *testing.T, config Config) *Cluster {
+	c, err := NewCluster(nodes, config, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// staleReads 统计在写入没有完全送达时，读到旧值的次数
+func staleReads(t *testing.T, config Config, times int) int {
+	c := newTestCluster(t, config)
+	stale := 0
+	for i := 0; i < times; i++ {
+		key := fmt.Sprintf("key%d", i%10)
+		siblings, _ := c.Get(key)
+		value := fmt.Sprint(i)
+		c.Put(key, value, Context(siblings))
+		siblings, _ = c.Get(key)
+		if len(siblings) != 1 || siblings[0].Value != value {
+			stale++
+		}
+		// 偶尔才让所有的写入送达
+		if i%7 == 0 {
+			c.Settle()
+		}
+	}
+	return stale
+}
+
+func Test_Cluster_consistencyTradeOff(t *testing.T) {
+	ast := assert.New(t)
+	//
+	times := 200
+	strict := Config{N: 3, R: 2, W: 2}
+	ast.True(strict.Strict())
+	ast.Equal(0, staleReads(t, strict, times))
+	//
+	loose := Config{N: 3, R: 1, W: 1}
+	ast.False(loose.Strict())
+	ast.True(staleReads(t, loose, times) > 0)
+}
+
+func Test_Cluster_concurrentWritesBecomeSiblings(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := newTestCluster(t, Config{N: 3, R: 2, W: 2})
+	key := "cart"
+	c.Put(key, "apple", nil)
+	c.Settle()
+	siblings, _ := c.Get(key)
+	ctx := Context(siblings)
+	// 两个客户端基于同一个版本写入，但是它们的 coordinator 不同
+	coordinator := c.PreferenceList(key)[0]
+	c.Down(coordinator)
+	c.Put(key, "apple,banana", ctx)
+	c.Up(coordinator)
+	c.Put(key, "apple,cherry", ctx)
+	c.Settle()
+	//
+	siblings, err := c.Get(key)
+	ast.Nil(err)
+	ast.Equal(2, len(siblings))
+	ast.Equal(Concurrent, siblings[0].Clock.Compare(siblings[1].Clock))
+	// 客户端合并了 siblings 以后再写入，冲突就消失了
+	c.Put(key, "apple,banana,cherry", Context(siblings))
+	c.Settle()
+	siblings, _ = c.Get(key)
+	ast.Equal(1, len(siblings))
+	ast.Equal("apple,banana,cherry", siblings[0].Value)
+}
+
+func Test_Cluster_readRepair(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := newTestCluster(t, Config{N: 3, R: 3, W: 1})
+	key := "k"
+	c.Put(key, "v", nil)
+	// 没有送达的写入，被读取修复了
+	siblings, _ := c.Get(key)
+	ast.Equal("v", siblings[0].Value)
+	ast.Equal(2, c.Stats().ReadRepairs)
+	for _, node := range c.PreferenceList(key) {
+		ast.Equal(1, len(c.replicas[node].fetch(key)))
+	}
+}
+
+func Test_Cluster_sloppyQuorum(t *testing.T) {
+	ast := assert.New(t)
+	//
+	key := "k"
+	strict := newTestCluster(t, Config{N: 3, R: 2, W: 3})
+	sloppy := newTestCluster(t, Config{N: 3, R: 2, W: 3, Sloppy: true})
+	down := strict.PreferenceList(key)[1]
+	strict.Down(down)
+	sloppy.Down(down)
+	// 严格的 quorum 无法在 node 失效时写入
+	_, err := strict.Put(key, "v", nil)
+	ast.Equal(ErrInsufficientReplicas, err)
+	// sloppy quorum 依然可以写入，由其他 node 暂时替 down 保管
+	_, err = sloppy.Put(key, "v", nil)
+	ast.Nil(err)
+	ast.Equal(0, len(sloppy.replicas[down].fetch(key)))
+	// down 恢复以后，得到了它错过的写入
+	sloppy.Up(down)
+	ast.Equal(1, sloppy.Stats().HintedHandoffs)
+	ast.Equal("v", sloppy.replicas[down].fetch(key)[0].Value)
+	// 替代者不再保存这个 key
+	holders := 0
+	for _, r := range sloppy.replicas {
+		if len(r.fetch(key)) > 0 {
+			holders++
+		}
+	}
+	ast.Equal(3, holders)
+}
+
+func Test_Cluster_hintedHandoff(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := newTestCluster(t, Config{N: 3, R: 2, W: 2, HintedHandoff: true})
+	key := "k"
+	down := c.PreferenceList(key)[2]
+	c.Down(down)
+	_, err := c.Put(key, "v", nil)
+	ast.Nil(err)
+	c.Settle()
+	ast.Equal(0, len(c.replicas[down].fetch(key)))
+	ast.Equal(1, c.Stats().HintsStored)
+	// down 恢复以后，coordinator 重放了它错过的写入
+	c.Up(down)
+	ast.Equal(1, c.Stats().HintedHandoffs)
+	ast.Equal("v", c.replicas[down].fetch(key)[0].Value)
+	// coordinator 自己的数据不受影响
+	for _, node := range c.PreferenceList(key) {
+		ast.Equal(1, len(c.replicas[node].fetch(key)), node)
+	}
+}
+
+func Test_Cluster_hintedHandoffOfInflightWrites(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := newTestCluster(t, Config{N: 3, R: 1, W: 1, HintedHandoff: true})
+	key := "k"
+	c.Put(key, "v", nil)
+	// 写入还没有送达，其他两个 node 就失效了
+	pl := c.PreferenceList(key)
+	c.Down(pl[1])
+	c.Down(pl[2])
+	c.Settle()
+	ast.True(c.Stats().HintsStored > 0)
+	c.Up(pl[1])
+	c.Up(pl[2])
+	for _, node := range pl {
+		ast.Equal(1, len(c.replicas[node].fetch(key)), node)
+	}
+}
+
+func Test_Cluster_backgroundReadRepair(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := newTestCluster(t, Config{N: 3, R: 2, W: 1})
+	key := "k"
+	c.Put(key, "v", nil)
+	// 模拟剩下的写入丢失了，只有一个 replica 拥有 v
+	c.inflight = nil
+	for {
+		siblings, _ := c.Get(key)
+		if len(siblings) == 1 {
+			break
+		}
+	}
+	// 一个响应者被同步修复，没有响应的 replica 在后台修复
+	ast.Equal(1, c.Stats().ReadRepairs)
+	c.Settle()
+	ast.Equal(1, c.Stats().BackgroundRepairs)
+	for _, node := range c.PreferenceList(key) {
+		ast.Equal(1, len(c.replicas[node].fetch(key)), node)
+	}
+}
+
+// staleReplicas 统计 keys 的 preference list 中，缺少最新版本的 replica 数量
+func staleReplicas(c *Cluster, keys []string) int {
+	stale := 0
+	for _, key := range keys {
+		var all []Version
+		for _, r := range c.replicas {
+			all = append(all, r.fetch(key)...)
+		}
+		latest := reconcile(all)
+		for _, node := range c.PreferenceList(key) {
+			if !sameVersions(c.replicas[node].fetch(key), latest) {
+				stale++
+			}
+		}
+	}
+	return stale
+}
+
+// outage 在 node A 失效期间写入 keys，返回 A 恢复以后落后的 replica 数量
+func outage(t *testing.T, config Config, keys []string) (*Cluster, int) {
+	c := newTestCluster(t, config)
+	c.Down("A")
+	for _, key := range keys {
+		if _, err := c.Put(key, "v", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.Settle()
+	c.Up("A")
+	return c, staleReplicas(c, keys)
+}
+
+func Test_Cluster_convergenceAfterOutage(t *testing.T) {
+	ast := assert.New(t)
+	//
+	keys := make([]string, 100)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%d", i)
+	}
+	// 有 hinted handoff 时，A 一恢复就拿到了所有错过的写入
+	_, stale := outage(t, Config{N: 3, R: 2, W: 2, HintedHandoff: true}, keys)
+	ast.Equal(0, stale)
+	// 否则只能依靠读取时的修复，每一轮读取所有的 key 一次
+	c, stale := outage(t, Config{N: 3, R: 2, W: 2}, keys)
+	ast.True(stale > 0)
+	history := []int{stale}
+	for round := 0; round < 20 && stale > 0; round++ {
+		for _, key := range keys {
+			c.Get(key)
+		}
+		c.Settle()
+		stale = staleReplicas(c, keys)
+		history = append(history, stale)
+	}
+	ast.Equal(0, stale)
+	t.Logf("没有 hinted handoff 时，每一轮读取以后落后的 replica 数量：%v", history)
+}
+
+func Test_Cluster_insufficientReplicas(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := newTestCluster(t, Config{N: 3, R: 2, W: 2})
+	key := "k"
+	pl := c.PreferenceList(key)
+	c.Down(pl[0])
+	c.Down(pl[1])
+	_, err := c.Put(key, "v", nil)
+	ast.Equal(ErrInsufficientReplicas, err)
+	_, err = c.Get(key)
+	ast.Equal(ErrInsufficientReplicas, err)
+}
+
+func Test_NewCluster_invalidConfig(t *testing.T) {
+	ast := assert.New(t)
+	//
+	_, err := NewCluster(nodes, Config{N: 6, R: 1, W: 1}, 1)
+	ast.NotNil(err)
+	_, err = NewCluster(nodes, Config{N: 3, R: 4, W: 1}, 1)
+	ast.NotNil(err)
+	_, err = NewCluster([]string{"A", "A"}, Config{N: 1, R: 1, W: 1}, 1)
+	ast.NotNil(err)
+	//
+	c, err := NewCluster(nodes, Config{N: 3, R: 2, W: 2}, 1)
+	ast.Nil(err)
+	ast.Equal("N3:R2:W2:Sloppy:false:Hints:false", c.Config().String())
+}
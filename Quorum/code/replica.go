@@ -0,0 +1,125 @@
+package quorum
+
+import "sync"
+
+// hint 是替别的 node 暂时保管的写入
+type hint struct {
+	key     string
+	version Version
+	// substitute 为 true 时，保管者是 sloppy quorum 中的替代者，自己也保存了这个写入
+	substitute bool
+}
+
+// replica 是集群中的一个存储 node
+type replica struct {
+	mu   sync.Mutex
+	name string
+	up   bool
+	// counter 是 replica 作为 coordinator 时，在 VClock 中使用的计数器
+	counter uint64
+	data    map[string][]Version
+	// hints[node] 是替 node 保管的写入，node 恢复以后交还给它
+	hints map[string][]hint
+}
+
+func newReplica(name string) *replica {
+	return &replica{
+		name:  name,
+		up:    true,
+		data:  make(map[string][]Version),
+		hints: make(map[string][]hint),
+	}
+}
+
+// stamp 为基于 ctx 的新写入生成 VClock
+func (r *replica) stamp(ctx VClock) VClock {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	clock := ctx.Copy()
+	if clock[r.name] > r.counter {
+		r.counter = clock[r.name]
+	}
+	r.counter++
+	clock[r.name] = r.counter
+	return clock
+}
+
+// store 保存 vs，返回 true，如果 replica 的内容因此发生了变化
+func (r *replica) store(key string, vs ...Version) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	old := r.data[key]
+	merged := reconcile(append(append([]Version{}, old...), vs...))
+	r.data[key] = merged
+	return !sameVersions(old, merged)
+}
+
+func (r *replica) fetch(key string) []Version {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Version{}, r.data[key]...)
+}
+
+// keepHint 作为替代者替 node 保存写入，同时 replica 自己也可以用这个写入响应读取
+// 返回 true，如果 replica 的内容因此发生了变化
+func (r *replica) keepHint(node, key string, vs ...Version) bool {
+	r.mu.Lock()
+	for _, v := range vs {
+		r.hints[node] = append(r.hints[node], hint{key: key, version: v, substitute: true})
+	}
+	r.mu.Unlock()
+	return r.store(key, vs...)
+}
+
+// holdHint 作为 coordinator 替失效的 node 保存错过的写入，写入不会成为 replica 自己的数据
+func (r *replica) holdHint(node, key string, v Version) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hints[node] = append(r.hints[node], hint{key: key, version: v})
+}
+
+// takeHints 取出所有替 node 保管的写入
+func (r *replica) takeHints(node string) []hint {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	hs := r.hints[node]
+	delete(r.hints, node)
+	return hs
+}
+
+// release 在作为替代者保存的 key 的写入都已经交还给原本的 node 以后，删除 key
+func (r *replica) release(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, hs := range r.hints {
+		for _, h := range hs {
+			if h.key == key && h.substitute {
+				return
+			}
+		}
+	}
+	delete(r.data, key)
+}
+
+func (r *replica) isUp() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.up
+}
+
+func (r *replica) setUp(up bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.up = up
+}
+
+// keys 返回 replica 保存的所有 key
+func (r *replica) keys() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res := make([]string, 0, len(r.data))
+	for key := range r.data {
+		res = append(res, key)
+	}
+	return res
+}
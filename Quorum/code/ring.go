@@ -0,0 +1,48 @@
+package quorum
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"sort"
+)
+
+func hash(s string) uint64 {
+	sum := sha1.Sum([]byte(s))
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+// ring 是一致性哈希环，每个 node 按照名字的哈希值分布在环上
+type ring struct {
+	points []uint64
+	owners map[uint64]string
+}
+
+func newRing(nodes []string) *ring {
+	r := &ring{
+		points: make([]uint64, 0, len(nodes)),
+		owners: make(map[uint64]string, len(nodes)),
+	}
+	for _, node := range nodes {
+		p := hash(node)
+		r.points = append(r.points, p)
+		r.owners[p] = node
+	}
+	sort.Slice(r.points, func(i, j int) bool {
+		return r.points[i] < r.points[j]
+	})
+	return r
+}
+
+// walk 从 key 在环上的位置出发，按顺时针的顺序返回所有的 node
+// 前 N 个 node 就是 key 的 preference list
+func (r *ring) walk(key string) []string {
+	h := hash(key)
+	start := sort.Search(len(r.points), func(i int) bool {
+		return r.points[i] >= h
+	})
+	res := make([]string, len(r.points))
+	for i := range r.points {
+		res[i] = r.owners[r.points[(start+i)%len(r.points)]]
+	}
+	return res
+}
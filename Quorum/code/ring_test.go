@@ -0,0 +1,28 @@
+package quorum
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ring_walk(t *testing.T) {
+	ast := assert.New(t)
+	//
+	nodes := []string{"A", "B", "C", "D", "E"}
+	r := newRing(nodes)
+	//
+	for _, key := range []string{"x", "y", "z"} {
+		walk := r.walk(key)
+		ast.ElementsMatch(nodes, walk)
+		// 第一个 node 是 key 在环上顺时针方向遇到的第一个 node
+		h := hash(key)
+		first := hash(walk[0])
+		for _, node := range nodes {
+			p := hash(node)
+			if p >= h {
+				ast.True(first >= h && first <= p)
+			}
+		}
+	}
+}
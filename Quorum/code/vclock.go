@@ -0,0 +1,107 @@
+package quorum
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// VClock 是 vector clock，记录了每个 coordinator 在这个版本上执行过的写入次数
+// nil 是合法的空 VClock
+type VClock map[string]uint64
+
+// Ordering 是两个 VClock 之间的关系
+type Ordering int
+
+// 枚举了 VClock 之间的所有关系
+const (
+	// Equal 表示两个 VClock 相同
+	Equal Ordering = iota
+	// Before 表示前者发生在后者之前，后者包含了前者的所有写入
+	Before
+	// After 表示前者发生在后者之后
+	After
+	// Concurrent 表示两者是并发的写入，谁也没有包含谁
+	Concurrent
+)
+
+func (o Ordering) String() string {
+	switch o {
+	case Equal:
+		return "Equal"
+	case Before:
+		return "Before"
+	case After:
+		return "After"
+	case Concurrent:
+		return "Concurrent"
+	default:
+		panic("出现了第5种 Ordering")
+	}
+}
+
+// Compare 返回 vc 相对于 other 的关系
+func (vc VClock) Compare(other VClock) Ordering {
+	less, greater := false, false
+	for node, n := range vc {
+		if n > other[node] {
+			greater = true
+		} else if n < other[node] {
+			less = true
+		}
+	}
+	for node, n := range other {
+		if _, ok := vc[node]; !ok && n > 0 {
+			less = true
+		}
+	}
+	switch {
+	case less && greater:
+		return Concurrent
+	case less:
+		return Before
+	case greater:
+		return After
+	default:
+		return Equal
+	}
+}
+
+// Descends 返回 true，如果 vc 包含了 other 的所有写入
+func (vc VClock) Descends(other VClock) bool {
+	o := vc.Compare(other)
+	return o == Equal || o == After
+}
+
+// Copy 返回 vc 的副本
+func (vc VClock) Copy() VClock {
+	res := make(VClock, len(vc))
+	for node, n := range vc {
+		res[node] = n
+	}
+	return res
+}
+
+// Merge 返回同时包含了 vc 和 other 所有写入的 VClock
+func (vc VClock) Merge(other VClock) VClock {
+	res := vc.Copy()
+	for node, n := range other {
+		if n > res[node] {
+			res[node] = n
+		}
+	}
+	return res
+}
+
+func (vc VClock) String() string {
+	nodes := make([]string, 0, len(vc))
+	for node := range vc {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	parts := make([]string, len(nodes))
+	for i, node := range nodes {
+		parts[i] = fmt.Sprintf("%s:%d", node, vc[node])
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
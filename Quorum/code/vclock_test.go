@@ -0,0 +1,48 @@
+package quorum
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_VClock_Compare(t *testing.T) {
+	ast := assert.New(t)
+	//
+	a := VClock{"A": 1}
+	b := VClock{"A": 2}
+	c := VClock{"A": 1, "B": 1}
+	//
+	ast.Equal(Equal, a.Compare(VClock{"A": 1}))
+	ast.Equal(Before, a.Compare(b))
+	ast.Equal(After, b.Compare(a))
+	ast.Equal(Before, a.Compare(c))
+	ast.Equal(Concurrent, b.Compare(c))
+	ast.Equal(Before, VClock(nil).Compare(a))
+	ast.Equal(Equal, VClock(nil).Compare(VClock{"A": 0}))
+	//
+	ast.True(b.Descends(a))
+	ast.False(b.Descends(c))
+}
+
+func Test_VClock_Merge(t *testing.T) {
+	ast := assert.New(t)
+	//
+	a := VClock{"A": 2, "B": 1}
+	b := VClock{"B": 3, "C": 1}
+	m := a.Merge(b)
+	ast.Equal(VClock{"A": 2, "B": 3, "C": 1}, m)
+	ast.True(m.Descends(a))
+	ast.True(m.Descends(b))
+	// Merge 不会修改原来的 VClock
+	ast.Equal(VClock{"A": 2, "B": 1}, a)
+	//
+	ast.Equal("{A:2, B:3, C:1}", m.String())
+	ast.Equal("{}", VClock(nil).String())
+}
+
+func Test_Ordering_String(t *testing.T) {
+	ast := assert.New(t)
+	ast.Equal("Concurrent", Concurrent.String())
+	ast.Panics(func() { _ = Ordering(4).String() })
+}
@@ -0,0 +1,64 @@
+package quorum
+
+import "fmt"
+
+// Version 是 key 的一个版本
+type Version struct {
+	Value string
+	Clock VClock
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%q%s", v.Value, v.Clock)
+}
+
+// reconcile 把 vs 合并成互相并发的版本，也就是 siblings
+// 被其他版本包含的版本都会被丢弃
+func reconcile(vs []Version) []Version {
+	res := make([]Version, 0, len(vs))
+	for i, v := range vs {
+		obsolete := false
+		for j, w := range vs {
+			o := v.Clock.Compare(w.Clock)
+			// 相同的版本只保留第一个
+			if o == Before || (o == Equal && j < i) {
+				obsolete = true
+				break
+			}
+		}
+		if !obsolete {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// sameVersions 返回 true，如果 a 和 b 包含了同样的版本
+func sameVersions(a, b []Version) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, v := range a {
+		found := false
+		for _, w := range b {
+			if v.Clock.Compare(w.Clock) == Equal {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Context 返回包含了 siblings 中所有写入的 VClock
+// 客户端解决了冲突以后，把它和新的值一起 Put，新的值就会取代所有的 siblings
+func Context(siblings []Version) VClock {
+	var res VClock
+	for _, v := range siblings {
+		res = res.Merge(v.Clock)
+	}
+	return res
+}
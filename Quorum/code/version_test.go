@@ -0,0 +1,34 @@
+package quorum
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_reconcile(t *testing.T) {
+	ast := assert.New(t)
+	//
+	v1 := Version{Value: "1", Clock: VClock{"A": 1}}
+	v2 := Version{Value: "2", Clock: VClock{"A": 2}}
+	v3 := Version{Value: "3", Clock: VClock{"A": 1, "B": 1}}
+	//
+	ast.Equal([]Version{v2}, reconcile([]Version{v1, v2, v2}))
+	ast.Equal([]Version{v2, v3}, reconcile([]Version{v1, v2, v3}))
+	ast.Equal(0, len(reconcile(nil)))
+	//
+	ast.True(sameVersions([]Version{v2, v3}, []Version{v3, v2}))
+	ast.False(sameVersions([]Version{v2}, []Version{v3}))
+}
+
+func Test_Context(t *testing.T) {
+	ast := assert.New(t)
+	//
+	siblings := []Version{
+		{Value: "2", Clock: VClock{"A": 2}},
+		{Value: "3", Clock: VClock{"A": 1, "B": 1}},
+	}
+	ctx := Context(siblings)
+	ast.Equal(VClock{"A": 2, "B": 1}, ctx)
+	ast.Equal(`"2"{A:2}`, siblings[0].String())
+}
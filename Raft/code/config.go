@@ -56,11 +56,16 @@ type config struct {
 	cmds0     int       // number of agreements
 	maxIndex  int
 	maxIndex0 int
+	options   Options // passed to MakeWithOptions()
 }
 
 var ncpuOnce sync.Once
 
 func makeConfig(t *testing.T, n int, unreliable bool) *config {
+	return makeConfigWithOptions(t, n, unreliable, Options{})
+}
+
+func makeConfigWithOptions(t *testing.T, n int, unreliable bool, opts Options) *config {
 	ncpuOnce.Do(func() {
 		if runtime.NumCPU() < 2 {
 			fmt.Printf("warning: only one CPU, which may conceal locking bugs\n")
@@ -79,6 +84,7 @@ func makeConfig(t *testing.T, n int, unreliable bool) *config {
 	cfg.endnames = make([][]string, cfg.n)
 	cfg.logs = make([]map[int]int, cfg.n)
 	cfg.start = time.Now()
+	cfg.options = opts
 
 	cfg.setunreliable(unreliable)
 
@@ -206,7 +212,7 @@ func (cfg *config) start1(i int) {
 		}
 	}()
 
-	rf := Make(ends, i, cfg.saved[i], applyCh)
+	rf := MakeWithOptions(ends, i, cfg.saved[i], applyCh, cfg.options)
 
 	cfg.mu.Lock()
 	cfg.rafts[i] = rf
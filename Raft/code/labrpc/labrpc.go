@@ -55,8 +55,8 @@ import "reflect"
 import "sync"
 import "log"
 import "strings"
-import "math/rand"
 import "time"
+import "github.com/aQuaYi/Distributed-Algorithms/Sim/code"
 import "sync/atomic"
 
 type reqMsg struct {
@@ -127,6 +127,7 @@ type Network struct {
 	endCh          chan reqMsg
 	done           chan struct{} // closed when Network is cleaned up
 	count          int32         // total RPC count, for statistics
+	env            sim.Env       // source of delays and drops, see SetEnv()
 }
 
 func MakeNetwork() *Network {
@@ -180,6 +181,22 @@ func (rn *Network) LongDelays(yes bool) {
 	rn.longDelays = yes
 }
 
+// SetEnv makes the network draw its delays and drops from env,
+// so that a simulator can replay the same schedule.
+func (rn *Network) SetEnv(env sim.Env) {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+
+	rn.env = env
+}
+
+func (rn *Network) getEnv() sim.Env {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+
+	return rn.env
+}
+
 func (rn *Network) ReadEndnameInfo(endname interface{}) (enabled bool,
 	servername interface{}, server *Server, reliable bool, longreordering bool,
 ) {
@@ -208,15 +225,16 @@ func (rn *Network) IsServerDead(endname interface{}, servername interface{}, ser
 
 func (rn *Network) ProcessReq(req reqMsg) {
 	enabled, servername, server, reliable, longreordering := rn.ReadEndnameInfo(req.endname)
+	env := rn.getEnv()
 
 	if enabled && servername != nil && server != nil {
 		if reliable == false {
 			// short delay
-			ms := (rand.Int() % 27)
-			time.Sleep(time.Duration(ms) * time.Millisecond)
+			ms := env.Intn(27)
+			env.Sleep(time.Duration(ms) * time.Millisecond)
 		}
 
-		if reliable == false && (rand.Int()%1000) < 100 {
+		if reliable == false && env.Intn(1000) < 100 {
 			// drop the request, return as if timeout
 			req.replyCh <- replyMsg{false, nil}
 			return
@@ -242,7 +260,7 @@ func (rn *Network) ProcessReq(req reqMsg) {
 			select {
 			case reply = <-ech:
 				replyOK = true
-			case <-time.After(100 * time.Millisecond):
+			case <-env.After(100 * time.Millisecond):
 				serverDead = rn.IsServerDead(req.endname, servername, server)
 				if serverDead {
 					go func() {
@@ -263,16 +281,16 @@ func (rn *Network) ProcessReq(req reqMsg) {
 		if replyOK == false || serverDead == true {
 			// server was killed while we were waiting; return error.
 			req.replyCh <- replyMsg{false, nil}
-		} else if reliable == false && (rand.Int()%1000) < 100 {
+		} else if reliable == false && env.Intn(1000) < 100 {
 			// drop the reply, return as if timeout
 			req.replyCh <- replyMsg{false, nil}
-		} else if longreordering == true && rand.Intn(900) < 600 {
+		} else if longreordering == true && env.Intn(900) < 600 {
 			// delay the response for a while
-			ms := 200 + rand.Intn(1+rand.Intn(2000))
+			ms := 200 + env.Intn(1+env.Intn(2000))
 			// Russ points out that this timer arrangement will decrease
 			// the number of goroutines, so that the race
 			// detector is less likely to get upset.
-			time.AfterFunc(time.Duration(ms)*time.Millisecond, func() {
+			env.AfterFunc(time.Duration(ms)*time.Millisecond, func() {
 				req.replyCh <- reply
 			})
 		} else {
@@ -284,13 +302,13 @@ func (rn *Network) ProcessReq(req reqMsg) {
 		if rn.longDelays {
 			// let Raft tests check that leader doesn't send
 			// RPCs synchronously.
-			ms = (rand.Int() % 7000)
+			ms = env.Intn(7000)
 		} else {
 			// many kv tests require the client to try each
 			// server in fairly rapid succession.
-			ms = (rand.Int() % 100)
+			ms = env.Intn(100)
 		}
-		time.AfterFunc(time.Duration(ms)*time.Millisecond, func() {
+		env.AfterFunc(time.Duration(ms)*time.Millisecond, func() {
 			req.replyCh <- replyMsg{false, nil}
 		})
 	}
@@ -9,13 +9,19 @@ package raft
 // test with the original before submitting.
 //
 
-import "sync"
+import (
+	"encoding/binary"
+	"sync"
+
+	wal "github.com/aQuaYi/Distributed-Algorithms/WAL/code"
+)
 
 // Persister is
 type Persister struct {
 	mu        sync.Mutex
 	raftstate []byte
 	snapshot  []byte
+	log       wal.Log // 不为 nil 时，每次保存都会先写入 log
 }
 
 // MakePersister is
@@ -23,7 +29,63 @@ func MakePersister() *Persister {
 	return &Persister{}
 }
 
+// MakeWALPersister 返回把 state 和 snapshot 保存在 log 中的 Persister
+// log 中的最后一条记录就是上次保存的 state 和 snapshot，
+// 所以崩溃以后，用同一个 log 再次调用 MakeWALPersister 就可以恢复。
+func MakeWALPersister(log wal.Log) (*Persister, error) {
+	ps := &Persister{log: log}
+	last := log.LastIndex()
+	if last < log.FirstIndex() {
+		return ps, nil
+	}
+	data, err := log.Read(last)
+	if err != nil {
+		return nil, err
+	}
+	ps.raftstate, ps.snapshot = decodePersisted(data)
+	return ps, nil
+}
+
+// save 在 ps.mu 的保护下，保存 state 和 snapshot
+// 每次保存的都是完整的 state 和 snapshot，所以写入后，就可以删除之前的记录。
+// log 已经被关闭，或者 storage 已经崩溃，说明 server 已经崩溃了，不用再保存。
+func (ps *Persister) save(state, snapshot []byte) {
+	ps.raftstate = state
+	ps.snapshot = snapshot
+	if ps.log == nil {
+		return
+	}
+	index, err := ps.log.Append(encodePersisted(state, snapshot))
+	if err == nil {
+		err = ps.log.Sync()
+	}
+	if err == nil {
+		err = ps.log.TruncateFront(index)
+	}
+	if err != nil && err != wal.ErrClosed && err != wal.ErrCrashed {
+		// 无法持久化时，Raft 不能再继续运行了
+		panic(err)
+	}
+}
+
+func encodePersisted(state, snapshot []byte) []byte {
+	buf := make([]byte, 4, 4+len(state)+len(snapshot))
+	binary.BigEndian.PutUint32(buf, uint32(len(state)))
+	buf = append(buf, state...)
+	return append(buf, snapshot...)
+}
+
+func decodePersisted(data []byte) (state, snapshot []byte) {
+	n := binary.BigEndian.Uint32(data)
+	state = data[4 : 4+n]
+	if len(data) > int(4+n) {
+		snapshot = data[4+n:]
+	}
+	return state, snapshot
+}
+
 // Copy is
+// 返回的副本只保存在内存中
 func (ps *Persister) Copy() *Persister {
 	ps.mu.Lock()
 	defer ps.mu.Unlock()
@@ -37,7 +99,7 @@ func (ps *Persister) Copy() *Persister {
 func (ps *Persister) SaveRaftState(state []byte) {
 	ps.mu.Lock()
 	defer ps.mu.Unlock()
-	ps.raftstate = state
+	ps.save(state, ps.snapshot)
 }
 
 // ReadRaftState is
@@ -60,8 +122,7 @@ func (ps *Persister) RaftStateSize() int {
 func (ps *Persister) SaveStateAndSnapshot(state []byte, snapshot []byte) {
 	ps.mu.Lock()
 	defer ps.mu.Unlock()
-	ps.raftstate = state
-	ps.snapshot = snapshot
+	ps.save(state, snapshot)
 }
 
 // ReadSnapshot is
@@ -0,0 +1,138 @@
+package raft
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/aQuaYi/Distributed-Algorithms/Raft/code/labrpc"
+	wal "github.com/aQuaYi/Distributed-Algorithms/WAL/code"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Persister_WAL(t *testing.T) {
+	ast := assert.New(t)
+	//
+	path := filepath.Join(t.TempDir(), "raft")
+	log, err := wal.Open(path)
+	ast.NoError(err)
+	ps, err := MakeWALPersister(log)
+	ast.NoError(err)
+	ps.SaveRaftState([]byte("state 1"))
+	ps.SaveStateAndSnapshot([]byte("state 2"), []byte("snapshot 2"))
+	ps.SaveRaftState([]byte("state 3"))
+	// 只保留了最后一次保存的记录
+	ast.Equal(log.FirstIndex(), log.LastIndex())
+	ast.NoError(log.Close())
+	// log 关闭以后，保存的内容不会被写入
+	ps.SaveRaftState([]byte("state 4"))
+	//
+	log, err = wal.Open(path)
+	ast.NoError(err)
+	defer log.Close()
+	ps, err = MakeWALPersister(log)
+	ast.NoError(err)
+	ast.Equal("state 3", string(ps.ReadRaftState()))
+	ast.Equal("snapshot 2", string(ps.ReadSnapshot()))
+}
+
+func Test_Persister_WALEmpty(t *testing.T) {
+	ast := assert.New(t)
+	//
+	ps, err := MakeWALPersister(wal.NewMemoryLog())
+	ast.NoError(err)
+	ast.Equal(0, ps.RaftStateSize())
+	ast.Equal(0, ps.SnapshotSize())
+}
+
+// makeWALCluster 返回用 open 打开的 log 保存状态的 cluster
+func makeWALCluster(t *testing.T, servers int, open func(i int) (*wal.FileLog, error)) (*snapshotCluster, []*wal.FileLog) {
+	c := &snapshotCluster{
+		net:        labrpc.MakeNetwork(),
+		endnames:   make([][]string, servers),
+		rafts:      make([]*Raft, servers),
+		services:   make([]*counterService, servers),
+		persisters: make([]*Persister, servers),
+	}
+	logs := make([]*wal.FileLog, servers)
+	for i := 0; i < servers; i++ {
+		var err error
+		if logs[i], err = open(i); err != nil {
+			t.Fatal(err)
+		}
+		if c.persisters[i], err = MakeWALPersister(logs[i]); err != nil {
+			t.Fatal(err)
+		}
+		c.start(i)
+	}
+	for i := 0; i < servers; i++ {
+		c.setConnected(i, true)
+	}
+	return c, logs
+}
+
+// testRestartFromWAL 在崩溃以后，只用 log 中的内容重启整个 cluster
+// crash 需要让所有 server 的内存状态全部丢失
+func testRestartFromWAL(t *testing.T, open func(i int) (*wal.FileLog, error), crash func(logs []*wal.FileLog)) {
+	ast := assert.New(t)
+	//
+	servers := 3
+	c, logs := makeWALCluster(t, servers, open)
+	leader := c.leader()
+	ast.NotEqual(-1, leader)
+	// 最后的 3 条 log 不在 snapshot 中
+	commands, sum := 5*snapshotEvery+3, 0
+	for i := 1; i <= commands; i++ {
+		c.rafts[leader].Start(i)
+		sum += i
+	}
+	for i := 0; i < servers; i++ {
+		ast.True(c.waitFor(i, func(s counter) bool { return s.LastIndex == commands }))
+	}
+	c.net.Cleanup()
+	crash(logs)
+	//
+	restarted, logs := makeWALCluster(t, servers, open)
+	defer restarted.net.Cleanup()
+	// Kill 什么也不做，测试结束后 Raft 还会继续保存 state，所以要关闭 log
+	defer func() {
+		for _, log := range logs {
+			log.Close()
+		}
+	}()
+	// 上一个 term 的 log，要等新的 leader 提交了自己 term 的 log 以后才能被提交
+	leader = restarted.leader()
+	ast.NotEqual(-1, leader)
+	commands++
+	restarted.rafts[leader].Start(commands)
+	sum += commands
+	for i := 0; i < servers; i++ {
+		ast.True(restarted.waitFor(i, func(s counter) bool { return s.LastIndex == commands }))
+		ast.Equal(sum, restarted.services[i].snapshot().Sum)
+		ast.Equal("", restarted.services[i].err)
+	}
+}
+
+func Test_Snapshot_restoredFromWAL(t *testing.T) {
+	dir := t.TempDir()
+	open := func(i int) (*wal.FileLog, error) {
+		return wal.Open(filepath.Join(dir, fmt.Sprintf("raft-%d", i)))
+	}
+	// 所有的 server 同时崩溃，内存中的状态全部丢失，只剩下 log 文件
+	testRestartFromWAL(t, open, func(logs []*wal.FileLog) {
+		for _, log := range logs {
+			log.Close()
+		}
+	})
+}
+
+func Test_Snapshot_restoredAfterTornWrites(t *testing.T) {
+	// 断电时，正在写入的记录可能只写了一半，甚至已经损坏了
+	storage := wal.NewSimStorage(wal.FaultModel{TornWrites: true, CorruptRate: 0.5}, 0)
+	open := func(i int) (*wal.FileLog, error) {
+		return wal.OpenStorage(storage, fmt.Sprintf("raft-%d", i))
+	}
+	testRestartFromWAL(t, open, func([]*wal.FileLog) {
+		storage.Crash()
+	})
+}
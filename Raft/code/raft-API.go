@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/aQuaYi/Distributed-Algorithms/Raft/code/labrpc"
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
 )
 
 /**
@@ -36,6 +37,25 @@ import (
 //
 func Make(peers []*labrpc.ClientEnd, me int,
 	persister *Persister, applyCh chan ApplyMsg) *Raft {
+	return MakeWithOptions(peers, me, persister, applyCh, Options{})
+}
+
+// Options 是对 Raft 的可选优化，零值就是论文中原始的 Raft
+type Options struct {
+	// PreVote 为 true 时，server 在发起选举之前，会先进行一轮不增加 term 的预投票
+	// 只有确认自己能够赢得选举后，才会真正地发起选举
+	PreVote bool
+	// LeaderLease 为 true 时，server 在最近一个 minElection 内收到过 leader 的消息，
+	// 就拒绝给其他 server 投票，leader 也因此可以通过 LeaseRead 在租约内直接读取
+	LeaderLease bool
+	// Env 提供了时间和随机数，零值使用真实的时间和 math/rand
+	// 模拟器可以通过它控制选举超时和心跳的时机
+	Env sim.Env
+}
+
+// MakeWithOptions 与 Make 一样创建一个 Raft server，但是会启用 opts 中的优化
+func MakeWithOptions(peers []*labrpc.ClientEnd, me int,
+	persister *Persister, applyCh chan ApplyMsg, opts Options) *Raft {
 	rf := &Raft{}
 
 	// Make 函数参数的去处
@@ -43,6 +63,7 @@ func Make(peers []*labrpc.ClientEnd, me int,
 	rf.me = me
 	rf.persister = persister
 	rf.chanApply = applyCh
+	rf.options = opts
 
 	// 需要 persist 的参数
 	rf.currentTerm = 0
@@ -59,6 +80,7 @@ func Make(peers []*labrpc.ClientEnd, me int,
 
 	// initialize from state persisted before a crash
 	rf.readPersist(persister.ReadRaftState())
+	rf.readSnapshot(persister.ReadSnapshot())
 
 	go rf.statesLoop()
 
@@ -69,11 +91,11 @@ func Make(peers []*labrpc.ClientEnd, me int,
 
 func (rf *Raft) statesLoop() {
 	for {
-		switch rf.state {
+		switch rf.getState() {
 		case FOLLOWER:
 			select {
-			case <-time.After(electionTimeout()):
-				rf.state = CANDIDATE
+			case <-rf.options.Env.After(rf.electionTimeout()):
+				rf.setState(CANDIDATE)
 			case <-rf.chanHeartBeat:
 			}
 		case CANDIDATE:
@@ -85,6 +107,10 @@ func (rf *Raft) statesLoop() {
 }
 
 func (rf *Raft) newElection() {
+	if rf.options.PreVote && !rf.preVote() {
+		return
+	}
+
 	rf.mu.Lock()
 
 	rf.currentTerm++
@@ -99,9 +125,9 @@ func (rf *Raft) newElection() {
 	go rf.broadcastRequestVote()
 
 	select {
-	case <-time.After(electionTimeout()):
+	case <-rf.options.Env.After(rf.electionTimeout()):
 	case <-rf.chanHeartBeat:
-		rf.state = FOLLOWER
+		rf.setState(FOLLOWER)
 		DPrintf("%s receives chanHeartbeat", rf)
 	case <-rf.chanBeElected:
 		rf.comeToPower()
@@ -114,6 +140,7 @@ func (rf *Raft) comeToPower() {
 	DPrintf("%s is Leader now", rf)
 	rf.nextIndex = make([]int, len(rf.peers))
 	rf.matchIndex = make([]int, len(rf.peers))
+	rf.leaseAcks = make([]time.Time, len(rf.peers))
 	for i := range rf.peers {
 		rf.nextIndex[i] = rf.getLastIndex() + 1
 		rf.matchIndex[i] = 0
@@ -124,30 +151,45 @@ func (rf *Raft) comeToPower() {
 func (rf *Raft) newHeartBeat() {
 	DPrintf("%s broadcastAppendEntries", rf)
 	rf.broadcastAppendEntries()
-	<-time.After(heartBeat)
+	<-rf.options.Env.After(heartBeat)
 }
 
+// applyLoop 是唯一向 chanApply 发送消息的 goroutine，这保证了 service 收到的消息是有序的
+// 发送消息的时候不持有锁，所以 service 在处理消息时，可以调用 rf.Snapshot
 func (rf *Raft) applyLoop() {
 	for {
 		<-rf.chanCommit
-		DPrintf("%s COMMITTED %s", rf, rf.details())
 		//
 		rf.mu.Lock()
 		//
+		// details 会读取 rf.logs，必须在持有锁的时候求值
+		DPrintf("%s COMMITTED %s", rf, rf.details())
+		var msgs []ApplyMsg
+		if rf.pendingSnapshot != nil {
+			// 先把 snapshot 交给 service，再应用 snapshot 之后的 log
+			if rf.pendingSnapshot.CommandIndex > rf.lastApplied {
+				msgs = append(msgs, *rf.pendingSnapshot)
+				rf.lastApplied = rf.pendingSnapshot.CommandIndex
+			}
+			rf.pendingSnapshot = nil
+		}
 		commitIndex := rf.commitIndex
 		baseIndex := rf.getBaseIndex()
 		for i := rf.lastApplied + 1; i <= commitIndex; i++ {
-			msg := ApplyMsg{
+			msgs = append(msgs, ApplyMsg{
 				CommandValid: true,
 				CommandIndex: i,
 				Command:      rf.logs[i-baseIndex].Command,
-			}
-			rf.chanApply <- msg
-			DPrintf("%s ApplyMSG: %s %s", rf, msg, rf.details())
+			})
 			rf.lastApplied = i
 		}
 		//
 		rf.mu.Unlock()
+		//
+		for _, msg := range msgs {
+			rf.chanApply <- msg
+			DPrintf("%s ApplyMSG: %s", rf, msg)
+		}
 	}
 }
 
@@ -198,7 +240,10 @@ func (rf *Raft) Start(command interface{}) (int, int, bool) {
 // GetState is
 // return currentTerm and whether this server
 // believes it is the leader.
+// service 会在自己的 goroutine 中调用 GetState，所以需要持有 rf.mu
 func (rf *Raft) GetState() (int, bool) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
 
 	var term int
 	var isLeader bool
@@ -224,11 +269,15 @@ func (rf *Raft) GetState() (int, bool) {
 //
 type ApplyMsg struct {
 	CommandValid bool // CommandValid = true 表示， 此消息是用于应用 Command
-	CommandIndex int  // Command 所在的 logEntry.logIndex 值
+	CommandIndex int  // Command 所在的 logEntry.logIndex 值，或者 Snapshot 包含的最后一个 log 的 LogIndex
 	Command      interface{}
+	Snapshot     []byte // CommandValid = false 时，service 需要用 Snapshot 替换自己的 state
 }
 
 func (m ApplyMsg) String() string {
+	if !m.CommandValid && m.Snapshot != nil {
+		return fmt.Sprintf("ApplyMsg{Snapshot,Index:%d,Size:%d}", m.CommandIndex, len(m.Snapshot))
+	}
 	return fmt.Sprintf("ApplyMsg{Valid:%t,Index:%d,Command:%v}", m.CommandValid, m.CommandIndex, m.Command)
 }
 
@@ -79,20 +79,48 @@ func (rf *Raft) broadcastAppendEntries() {
 
 	for id := range rf.peers {
 		if id != rf.me && rf.isLeader() {
-			args := rf.newAppendEntriesArgs(id)
-			go rf.sendAppendEntriesAndDealReply(id, args)
+			go rf.replicateTo(id)()
 		}
 	}
 }
 
-func (rf *Raft) sendAppendEntriesAndDealReply(id int, args AppendEntriesArgs) {
+// replicateTo 返回一个向 server id 同步 log 的函数
+// 如果 id 需要的 log 已经被压缩进了 snapshot，就改为发送 snapshot
+// 返回的函数在 id 认可了 rf 的 leader 身份时返回 true，并以此延长 leader 的租约
+// 利用调用方的锁进行锁定
+func (rf *Raft) replicateTo(id int) func() bool {
+	term := rf.currentTerm
+	var send func() bool
+	if rf.nextIndex[id] <= rf.getBaseIndex() {
+		args := rf.newInstallSnapshotArgs()
+		send = func() bool {
+			return rf.sendInstallSnapshotAndDealReply(id, args)
+		}
+	} else {
+		args := rf.newAppendEntriesArgs(id)
+		send = func() bool {
+			return rf.sendAppendEntriesAndDealReply(id, args)
+		}
+	}
+	return func() bool {
+		sent := rf.options.Env.Now()
+		if !send() {
+			return false
+		}
+		rf.extendLease(id, term, sent)
+		return true
+	}
+}
+
+// 返回 true，如果 server id 在 args.Term 中认可了 rf 的 leader 身份
+func (rf *Raft) sendAppendEntriesAndDealReply(id int, args AppendEntriesArgs) bool {
 	var reply AppendEntriesReply
 
 	DPrintf("%s AppendEntries to R%d with %s", rf, id, args)
 
 	ok := rf.sendAppendEntries(id, args, &reply)
 	if !ok {
-		return
+		return false
 	}
 
 	rf.mu.Lock()
@@ -103,27 +131,28 @@ func (rf *Raft) sendAppendEntriesAndDealReply(id int, args AppendEntriesArgs) {
 		rf.state = FOLLOWER
 		rf.votedFor = NOBODY
 		rf.persist()
-		return
+		return false
 	}
 
 	if rf.currentTerm != args.Term {
 		// term 已经改变
-		return
+		return false
 	}
 
 	if !reply.Success {
 		rf.nextIndex[id] = reply.NextIndex
-		return
+		return true
 	}
 
 	if len(args.Entries) == 0 {
 		// 纯 heartBeat 就无需进一步处理了
-		return
+		return true
 	}
 
 	lastArgsLogIndex := args.Entries[len(args.Entries)-1].LogIndex
 	rf.matchIndex[id] = lastArgsLogIndex
 	rf.nextIndex[id] = lastArgsLogIndex + 1
+	return true
 }
 
 // AppendEntries 会处理收到 AppendEntries RPC
@@ -145,6 +174,7 @@ func (rf *Raft) AppendEntries(args AppendEntriesArgs, reply *AppendEntriesReply)
 	defer rf.persist()
 
 	rf.chanHeartBeat <- struct{}{}
+	rf.lastHeartBeat = rf.options.Env.Now()
 
 	DPrintf("%s 收到了真实有效的信号 %s", rf, args)
 
@@ -163,6 +193,12 @@ func (rf *Raft) AppendEntries(args AppendEntriesArgs, reply *AppendEntriesReply)
 
 	baseIndex := rf.getBaseIndex()
 
+	if args.PrevLogIndex < baseIndex {
+		// PrevLogIndex 已经被压缩进了 snapshot，让 leader 从 snapshot 之后开始发送
+		reply.NextIndex = baseIndex + 1
+		return
+	}
+
 	if args.PrevLogIndex > baseIndex {
 		term := rf.logs[args.PrevLogIndex-baseIndex].LogTerm
 		if args.PrevLogTerm != term {
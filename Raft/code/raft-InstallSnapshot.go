@@ -0,0 +1,155 @@
+package raft
+
+import "fmt"
+
+// Snapshot 是
+// service 通知 rf，state machine 已经把 index 及其之前的 log 都保存进了 snapshot，
+// rf 可以丢弃这些 log 了。
+// service 只能对已经应用过的 log 生成 snapshot
+func (rf *Raft) Snapshot(index int, snapshot []byte) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	baseIndex := rf.getBaseIndex()
+	if index <= baseIndex || index > rf.lastApplied {
+		// 过期的 snapshot，或者包含了还没有应用的 log
+		return
+	}
+
+	rf.logs = compactLogs(rf.logs, index-baseIndex)
+	rf.persistWithSnapshot(snapshot)
+
+	DPrintf("%s SNAPSHOT at %d, logs:%d", rf, index, len(rf.logs))
+}
+
+// compactLogs 丢弃 logs[:i]，logs[i] 作为新的 logs[0]，只保留其 LogIndex 和 LogTerm
+// 使用新的底层数组，让被丢弃的 log 可以被 GC 回收
+func compactLogs(logs []LogEntry, i int) []LogEntry {
+	res := make([]LogEntry, len(logs)-i)
+	copy(res, logs[i:])
+	res[0].Command = nil
+	return res
+}
+
+// InstallSnapshotArgs 是 leader 发送 snapshot 的参数
+type InstallSnapshotArgs struct {
+	Term              int    // leader.currentTerm
+	LeaderID          int    // leader.me
+	LastIncludedIndex int    // snapshot 包含的最后一个 log 的 LogIndex
+	LastIncludedTerm  int    // snapshot 包含的最后一个 log 的 LogTerm
+	Data              []byte // snapshot 的内容
+}
+
+func (a InstallSnapshotArgs) String() string {
+	return fmt.Sprintf("installSnapshotArgs{R%d:T%d, LastIncludedIndex:%d, LastIncludedTerm:%d, Size:%d}",
+		a.LeaderID, a.Term, a.LastIncludedIndex, a.LastIncludedTerm, len(a.Data))
+}
+
+// InstallSnapshotReply 是 follower 回复 leader 的内容
+type InstallSnapshotReply struct {
+	Term int // 回复者的 term
+}
+
+// 利用调用方的锁进行锁定
+func (rf *Raft) newInstallSnapshotArgs() InstallSnapshotArgs {
+	return InstallSnapshotArgs{
+		Term:              rf.currentTerm,
+		LeaderID:          rf.me,
+		LastIncludedIndex: rf.getBaseIndex(),
+		LastIncludedTerm:  rf.logs[0].LogTerm,
+		Data:              rf.persister.ReadSnapshot(),
+	}
+}
+
+func (rf *Raft) sendInstallSnapshot(server int, args InstallSnapshotArgs, reply *InstallSnapshotReply) bool {
+	return rf.peers[server].Call("Raft.InstallSnapshot", args, reply)
+}
+
+// 返回 true，如果 server id 在 args.Term 中认可了 rf 的 leader 身份
+func (rf *Raft) sendInstallSnapshotAndDealReply(id int, args InstallSnapshotArgs) bool {
+	var reply InstallSnapshotReply
+
+	DPrintf("%s InstallSnapshot to R%d with %s", rf, id, args)
+
+	ok := rf.sendInstallSnapshot(id, args, &reply)
+	if !ok {
+		return false
+	}
+
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if reply.Term > rf.currentTerm {
+		rf.currentTerm = reply.Term
+		rf.state = FOLLOWER
+		rf.votedFor = NOBODY
+		rf.persist()
+		return false
+	}
+
+	if rf.currentTerm != args.Term {
+		// term 已经改变
+		return false
+	}
+
+	rf.matchIndex[id] = max(rf.matchIndex[id], args.LastIncludedIndex)
+	rf.nextIndex[id] = max(rf.nextIndex[id], args.LastIncludedIndex+1)
+	return true
+}
+
+// InstallSnapshot 会处理收到的 InstallSnapshot RPC
+func (rf *Raft) InstallSnapshot(args InstallSnapshotArgs, reply *InstallSnapshotReply) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	// 1. Reply immediately if term < currentTerm
+	if args.Term < rf.currentTerm {
+		reply.Term = rf.currentTerm
+		return
+	}
+
+	rf.chanHeartBeat <- struct{}{}
+	rf.lastHeartBeat = rf.options.Env.Now()
+
+	if args.Term > rf.currentTerm {
+		rf.currentTerm = args.Term
+		rf.state = FOLLOWER
+		rf.votedFor = NOBODY
+		rf.persist()
+	}
+
+	reply.Term = rf.currentTerm
+
+	if args.LastIncludedIndex <= rf.commitIndex {
+		// snapshot 中的 log 都已经 commit 了，没有新的内容
+		return
+	}
+
+	DPrintf("%s 收到了 %s", rf, args)
+
+	baseIndex := rf.getBaseIndex()
+	i := args.LastIncludedIndex - baseIndex
+	if args.LastIncludedIndex <= rf.getLastIndex() &&
+		rf.logs[i].LogTerm == args.LastIncludedTerm {
+		// 6. If existing log entry has same index and term as snapshot's last included entry,
+		//    retain log entries following it
+		rf.logs = compactLogs(rf.logs, i)
+	} else {
+		// 7. Discard the entire log
+		rf.logs = []LogEntry{{
+			LogIndex: args.LastIncludedIndex,
+			LogTerm:  args.LastIncludedTerm,
+		}}
+	}
+
+	rf.persistWithSnapshot(args.Data)
+
+	// 8. Reset state machine using snapshot contents
+	rf.commitIndex = args.LastIncludedIndex
+	rf.pendingSnapshot = &ApplyMsg{
+		CommandValid: false,
+		CommandIndex: args.LastIncludedIndex,
+		Snapshot:     args.Data,
+	}
+	rf.chanCommit <- struct{}{}
+}
@@ -0,0 +1,222 @@
+package raft
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aQuaYi/Distributed-Algorithms/Raft/code/labgob"
+	"github.com/aQuaYi/Distributed-Algorithms/Raft/code/labrpc"
+	"github.com/stretchr/testify/assert"
+)
+
+// snapshotEvery 表示 service 每应用多少条 log 就生成一次 snapshot
+const snapshotEvery = 10
+
+// counter 是测试 snapshot 用的 state machine
+type counter struct {
+	LastIndex int
+	Count     int
+	Sum       int
+}
+
+type counterService struct {
+	mu    sync.Mutex
+	rf    *Raft
+	state counter
+	// installed 记录了从 chanApply 收到的 snapshot 的数量
+	installed int
+	err       string
+}
+
+func (s *counterService) run(applyCh chan ApplyMsg) {
+	for m := range applyCh {
+		s.mu.Lock()
+		if m.CommandValid {
+			if m.CommandIndex != s.state.LastIndex+1 {
+				s.err = fmt.Sprintf("apply out of order: want %d, got %d", s.state.LastIndex+1, m.CommandIndex)
+			}
+			s.state.LastIndex = m.CommandIndex
+			s.state.Count++
+			s.state.Sum += m.Command.(int)
+			if m.CommandIndex%snapshotEvery == 0 {
+				w := new(bytes.Buffer)
+				labgob.NewEncoder(w).Encode(s.state)
+				s.rf.Snapshot(m.CommandIndex, w.Bytes())
+			}
+		} else if m.Snapshot != nil {
+			var state counter
+			labgob.NewDecoder(bytes.NewBuffer(m.Snapshot)).Decode(&state)
+			if state.LastIndex != m.CommandIndex {
+				s.err = fmt.Sprintf("snapshot index mismatch: %d != %d", state.LastIndex, m.CommandIndex)
+			}
+			s.state = state
+			s.installed++
+		}
+		s.mu.Unlock()
+	}
+}
+
+func (s *counterService) snapshot() counter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+type snapshotCluster struct {
+	net        *labrpc.Network
+	endnames   [][]string
+	rafts      []*Raft
+	services   []*counterService
+	persisters []*Persister
+}
+
+func makeSnapshotCluster(n int) *snapshotCluster {
+	c := &snapshotCluster{
+		net:        labrpc.MakeNetwork(),
+		endnames:   make([][]string, n),
+		rafts:      make([]*Raft, n),
+		services:   make([]*counterService, n),
+		persisters: make([]*Persister, n),
+	}
+	for i := 0; i < n; i++ {
+		c.persisters[i] = MakePersister()
+		c.start(i)
+	}
+	for i := 0; i < n; i++ {
+		c.setConnected(i, true)
+	}
+	return c
+}
+
+func (c *snapshotCluster) start(i int) {
+	n := len(c.rafts)
+	c.endnames[i] = make([]string, n)
+	ends := make([]*labrpc.ClientEnd, n)
+	for j := 0; j < n; j++ {
+		c.endnames[i][j] = randstring(20)
+		ends[j] = c.net.MakeEnd(c.endnames[i][j])
+		c.net.Connect(c.endnames[i][j], j)
+	}
+	applyCh := make(chan ApplyMsg)
+	rf := Make(ends, i, c.persisters[i], applyCh)
+	s := &counterService{rf: rf}
+	go s.run(applyCh)
+	c.rafts[i], c.services[i] = rf, s
+
+	srv := labrpc.MakeServer()
+	srv.AddService(labrpc.MakeService(rf))
+	c.net.AddServer(i, srv)
+}
+
+func (c *snapshotCluster) setConnected(i int, connected bool) {
+	for j := range c.rafts {
+		c.net.Enable(c.endnames[i][j], connected)
+		c.net.Enable(c.endnames[j][i], connected)
+	}
+}
+
+func (c *snapshotCluster) leader() int {
+	for k := 0; k < 100; k++ {
+		for i, rf := range c.rafts {
+			rf.mu.Lock()
+			isLeader := rf.isLeader()
+			rf.mu.Unlock()
+			if isLeader {
+				return i
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return -1
+}
+
+// waitFor 等待 server i 的 state 满足 cond
+func (c *snapshotCluster) waitFor(i int, cond func(counter) bool) bool {
+	for k := 0; k < 200; k++ {
+		if cond(c.services[i].snapshot()) {
+			return true
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return false
+}
+
+func Test_Snapshot_laggingFollowerCatchesUp(t *testing.T) {
+	ast := assert.New(t)
+	//
+	servers := 3
+	c := makeSnapshotCluster(servers)
+	defer c.net.Cleanup()
+	//
+	leader := c.leader()
+	ast.NotEqual(-1, leader)
+	lagging := (leader + 1) % servers
+	c.setConnected(lagging, false)
+	//
+	commands, sum := 100, 0
+	for i := 1; i <= commands; i++ {
+		c.rafts[leader].Start(i)
+		sum += i
+	}
+	ast.True(c.waitFor(leader, func(s counter) bool { return s.Count == commands }))
+	// leader 的 log 已经被压缩了
+	c.rafts[leader].mu.Lock()
+	ast.True(len(c.rafts[leader].logs) <= snapshotEvery+1, "leader 的 log 没有被压缩")
+	c.rafts[leader].mu.Unlock()
+	//
+	c.setConnected(lagging, true)
+	ast.True(c.waitFor(lagging, func(s counter) bool { return s.LastIndex == commands }), "落后的 follower 没能追上")
+	// 落后的 follower 是通过 snapshot 追上来的
+	ast.Equal(sum, c.services[lagging].snapshot().Sum)
+	c.services[lagging].mu.Lock()
+	ast.True(c.services[lagging].installed > 0, "落后的 follower 应该从 snapshot 恢复，而不是逐条应用 log")
+	c.services[lagging].mu.Unlock()
+	c.rafts[lagging].mu.Lock()
+	ast.True(c.rafts[lagging].getBaseIndex() > 0)
+	c.rafts[lagging].mu.Unlock()
+	//
+	for i := range c.services {
+		ast.Equal("", c.services[i].err)
+	}
+}
+
+func Test_Snapshot_restoredAfterRestart(t *testing.T) {
+	ast := assert.New(t)
+	//
+	servers := 3
+	c := makeSnapshotCluster(servers)
+	defer c.net.Cleanup()
+	leader := c.leader()
+	ast.NotEqual(-1, leader)
+	//
+	commands, sum := 5*snapshotEvery, 0
+	for i := 1; i <= commands; i++ {
+		c.rafts[leader].Start(i)
+		sum += i
+	}
+	for i := 0; i < servers; i++ {
+		ast.True(c.waitFor(i, func(s counter) bool { return s.LastIndex == commands }))
+	}
+	// 用保存的 state 和 snapshot 在新的网络中重启所有的 server
+	restarted := &snapshotCluster{
+		net:        labrpc.MakeNetwork(),
+		endnames:   make([][]string, servers),
+		rafts:      make([]*Raft, servers),
+		services:   make([]*counterService, servers),
+		persisters: make([]*Persister, servers),
+	}
+	defer restarted.net.Cleanup()
+	for i := 0; i < servers; i++ {
+		restarted.persisters[i] = c.persisters[i].Copy()
+		restarted.start(i)
+	}
+	// 即使还没有选出 leader，server 也会先把 snapshot 交给 service
+	for i := 0; i < servers; i++ {
+		ast.True(restarted.waitFor(i, func(s counter) bool { return s.LastIndex == commands }))
+		ast.Equal(sum, restarted.services[i].snapshot().Sum)
+		ast.Equal("", restarted.services[i].err)
+	}
+}
@@ -0,0 +1,62 @@
+package raft
+
+import (
+	"sort"
+	"time"
+)
+
+// LeaseRead 实现了 Raft 博士论文 6.4.1 节中基于租约的只读查询
+// 与 ReadIndex 不同，leader 不用再发送一轮心跳，
+// 只要多数派在 leaseTimeout 之内认可过 rf 的 leader 身份，就可以直接返回 commitIndex。
+// 开启了 Options.LeaderLease 的 server 在收到 leader 消息后的 minElection 内不会投票，
+// 所以租约内不可能出现新的 leader。
+// 这依赖于各个 server 的时钟速度大致相同。
+func (rf *Raft) LeaseRead() (int, bool) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if !rf.options.LeaderLease ||
+		!rf.isLeader() ||
+		rf.logs[rf.commitIndex-rf.getBaseIndex()].LogTerm != rf.currentTerm {
+		return -1, false
+	}
+
+	if rf.options.Env.Since(rf.leaseStart()) >= leaseTimeout {
+		DPrintf("%s lease expired", rf)
+		return -1, false
+	}
+
+	return rf.commitIndex, true
+}
+
+// extendLease 记录 server id 在 term 中认可了 sent 时刻发出的心跳
+func (rf *Raft) extendLease(id, term int, sent time.Time) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.currentTerm != term || !rf.isLeader() {
+		return
+	}
+	if sent.After(rf.leaseAcks[id]) {
+		rf.leaseAcks[id] = sent
+	}
+}
+
+// leaseStart 返回租约的起始时间，即多数派认可 rf 的心跳中最早的发送时间
+// 利用调用方的锁进行锁定
+func (rf *Raft) leaseStart() time.Time {
+	acks := make([]time.Time, 0, len(rf.peers)-1)
+	for id, t := range rf.leaseAcks {
+		if id != rf.me {
+			acks = append(acks, t)
+		}
+	}
+	// 除了 rf 自己，还需要 need 个 server 的认可
+	need := len(rf.peers) / 2
+	if need == 0 {
+		return rf.options.Env.Now()
+	}
+	sort.Slice(acks, func(i, j int) bool {
+		return acks[i].After(acks[j])
+	})
+	return acks[need-1]
+}
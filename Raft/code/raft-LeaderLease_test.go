@@ -0,0 +1,71 @@
+package raft
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_LeaseRead(t *testing.T) {
+	ast := assert.New(t)
+	//
+	servers := 3
+	cfg := makeConfigWithOptions(t, servers, false, Options{LeaderLease: true})
+	defer cfg.cleanup()
+	//
+	index := cfg.one(101, servers, false)
+	leader := cfg.checkOneLeader()
+	readIndex, ok := cfg.rafts[leader].LeaseRead()
+	ast.True(ok)
+	ast.True(readIndex >= index)
+	// follower 不能提供 LeaseRead
+	_, ok = cfg.rafts[(leader+1)%servers].LeaseRead()
+	ast.False(ok)
+	// 失去了多数派的 leader，在租约到期后，不能再提供 LeaseRead
+	cfg.disconnect((leader + 1) % servers)
+	cfg.disconnect((leader + 2) % servers)
+	time.Sleep(leaseTimeout)
+	_, ok = cfg.rafts[leader].LeaseRead()
+	ast.False(ok)
+}
+
+func Test_LeaseRead_disabled(t *testing.T) {
+	ast := assert.New(t)
+	//
+	servers := 3
+	cfg := makeConfig(t, servers, false)
+	defer cfg.cleanup()
+	//
+	cfg.one(101, servers, false)
+	leader := cfg.checkOneLeader()
+	_, ok := cfg.rafts[leader].LeaseRead()
+	ast.False(ok)
+}
+
+func Test_LeaderLease_noNewLeaderWithinLease(t *testing.T) {
+	ast := assert.New(t)
+	//
+	servers := 3
+	cfg := makeConfigWithOptions(t, servers, false, Options{LeaderLease: true})
+	defer cfg.cleanup()
+	//
+	cfg.one(101, servers, false)
+	leader := cfg.checkOneLeader()
+	_, ok := cfg.rafts[leader].LeaseRead()
+	ast.True(ok)
+	// 隔离 leader，在它的租约到期之前，其他 server 不会选出新的 leader
+	cfg.disconnect(leader)
+	isolated := time.Now()
+	for time.Since(isolated) < leaseTimeout {
+		for i := 0; i < servers; i++ {
+			if i != leader {
+				_, isLeader := cfg.rafts[i].GetState()
+				ast.False(isLeader)
+			}
+		}
+		time.Sleep(heartBeat)
+	}
+	// 租约到期后，新的 leader 才能出现
+	ast.NotEqual(leader, cfg.checkOneLeader())
+}
@@ -0,0 +1,78 @@
+package raft
+
+// preVote 实现了 Raft 博士论文 9.6 节中的 PreVote
+// 在增加 term 发起选举之前，先用 currentTerm+1 询问其他 server 是否会投票给自己。
+// 被隔离的 server 无法得到多数派的预投票，也就不会一直增加自己的 term，
+// 重新连上网络后，不会因为 term 过大而打断正常工作的 leader。
+// 返回 true 表示 rf 可以发起真正的选举了
+func (rf *Raft) preVote() bool {
+	rf.mu.Lock()
+	args := RequestVoteArgs{
+		Term:         rf.currentTerm + 1,
+		CandidateID:  rf.me,
+		LastLogIndex: rf.getLastIndex(),
+		LastLogTerm:  rf.getLastTerm(),
+		PreVote:      true,
+	}
+	rf.mu.Unlock()
+
+	DPrintf("%s begin pre-vote\n", rf)
+
+	votes := make(chan bool, len(rf.peers))
+	for i := range rf.peers {
+		if i != rf.me {
+			go func(i int) {
+				votes <- rf.sendPreVoteAndDealReply(i, args)
+			}(i)
+		}
+	}
+
+	count := 1 // 1 是 rf 自己的一票
+	timeout := rf.options.Env.After(rf.electionTimeout())
+	for 2*count <= len(rf.peers) {
+		select {
+		case granted := <-votes:
+			if granted {
+				count++
+			}
+		case <-rf.chanHeartBeat:
+			rf.setState(FOLLOWER)
+			DPrintf("%s receives chanHeartbeat during pre-vote", rf)
+			return false
+		case <-timeout:
+			return false
+		}
+	}
+
+	return true
+}
+
+// 返回 true，如果 server i 同意了 rf 的预投票
+func (rf *Raft) sendPreVoteAndDealReply(i int, args RequestVoteArgs) bool {
+	var reply RequestVoteReply
+
+	ok := rf.sendRequestVote(i, &args, &reply)
+	if !ok {
+		return false
+	}
+
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if reply.Term > rf.currentTerm {
+		rf.currentTerm = reply.Term
+		rf.state = FOLLOWER
+		rf.votedFor = NOBODY
+		rf.persist()
+		return false
+	}
+
+	return reply.VoteGranted
+}
+
+// hasRecentLeader 返回 true，如果 rf 在最近一个 minElection 内收到过 leader 的消息
+// 利用调用方的锁进行锁定
+func (rf *Raft) hasRecentLeader() bool {
+	return rf.isLeader() ||
+		rf.options.Env.Since(rf.lastHeartBeat) < minElection
+}
@@ -0,0 +1,66 @@
+package raft
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rejoinAfterPartition 把一个 follower 隔离一段时间后，再让它重新连上网络
+// 返回隔离前和重新连上网络后的 leader 与 term
+func rejoinAfterPartition(t *testing.T, opts Options) (leader0, term0, leader1, term1 int) {
+	servers := 3
+	cfg := makeConfigWithOptions(t, servers, false, opts)
+	defer cfg.cleanup()
+	//
+	cfg.one(101, servers, false)
+	leader0 = cfg.checkOneLeader()
+	term0 = cfg.checkTerms()
+	//
+	follower := (leader0 + 1) % servers
+	cfg.disconnect(follower)
+	// 足够 follower 发起好几次选举了
+	time.Sleep(4 * maxElection)
+	cfg.connect(follower)
+	//
+	cfg.one(102, servers, true)
+	leader1 = cfg.checkOneLeader()
+	term1 = cfg.checkTerms()
+	return
+}
+
+func Test_PreVote_vanillaRaftIsDisrupted(t *testing.T) {
+	ast := assert.New(t)
+	//
+	_, term0, _, term1 := rejoinAfterPartition(t, Options{})
+	// 被隔离的 follower 带着更大的 term 回来，迫使 leader 下台，重新选举
+	ast.True(term1 > term0, "term0 = %d, term1 = %d", term0, term1)
+}
+
+func Test_PreVote_rejoiningFollowerDoesNotDisrupt(t *testing.T) {
+	ast := assert.New(t)
+	//
+	leader0, term0, leader1, term1 := rejoinAfterPartition(t, Options{PreVote: true})
+	// 被隔离的 follower 无法通过预投票，term 没有增加
+	ast.Equal(term0, term1)
+	ast.Equal(leader0, leader1)
+}
+
+func Test_PreVote_reElection(t *testing.T) {
+	ast := assert.New(t)
+	//
+	servers := 3
+	cfg := makeConfigWithOptions(t, servers, false, Options{PreVote: true})
+	defer cfg.cleanup()
+	//
+	leader0 := cfg.checkOneLeader()
+	// leader 离线后，剩下的 server 依然可以通过预投票选出新的 leader
+	cfg.disconnect(leader0)
+	leader1 := cfg.checkOneLeader()
+	ast.NotEqual(leader0, leader1)
+	// 旧 leader 重新连上网络后，也不会打断新的 leader
+	cfg.connect(leader0)
+	cfg.one(101, servers, true)
+	ast.Equal(leader1, cfg.checkOneLeader())
+}
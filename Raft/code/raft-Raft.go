@@ -3,6 +3,7 @@ package raft
 import (
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/aQuaYi/Distributed-Algorithms/Raft/code/labrpc"
 )
@@ -50,7 +51,15 @@ type Raft struct {
 	state     state
 	voteCount int
 
+	options Options
+	// 最近一次收到当前 leader 消息的时间
+	lastHeartBeat time.Time
+	// leaseAcks[i] 是 server i 最近一次认可 leader 身份的心跳的发送时间
+	leaseAcks []time.Time
+
 	chanApply chan ApplyMsg
+	// 从 leader 收到，但还没有交给 service 的 snapshot
+	pendingSnapshot *ApplyMsg
 
 	//channel
 	chanCommit    chan struct{}
@@ -63,6 +72,19 @@ func (rf *Raft) String() string {
 		rf.me, rf.currentTerm, rf.state, rf.commitIndex, rf.lastApplied)
 }
 
+// getState 和 setState 给不持有 rf.mu 的 statesLoop 使用
+func (rf *Raft) getState() state {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	return rf.state
+}
+
+func (rf *Raft) setState(s state) {
+	rf.mu.Lock()
+	rf.state = s
+	rf.mu.Unlock()
+}
+
 func (rf *Raft) details() string {
 	postfix := ""
 	if rf.state == LEADER {
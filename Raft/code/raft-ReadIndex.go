@@ -0,0 +1,70 @@
+package raft
+
+// ReadIndex 实现了论文 6.4 节中的 read-only 查询优化
+// 只读操作不用写入 log，只要 leader 确认自己依然是 leader，
+// 等到 state machine 应用到了返回的 index，就可以提供线性一致的读取。
+//
+// 返回 false 的情况：
+//  1. rf 不是 leader
+//  2. rf 还没有 commit 过当前 term 的 log，此时 commitIndex 可能落后于真正的 commit 进度，
+//     上层需要先 Start 一条 no-op 的命令
+//  3. 在一个选举超时内，没能得到半数以上 server 的认可
+func (rf *Raft) ReadIndex() (int, bool) {
+	rf.mu.Lock()
+
+	if !rf.isLeader() ||
+		rf.logs[rf.commitIndex-rf.getBaseIndex()].LogTerm != rf.currentTerm {
+		rf.mu.Unlock()
+		return -1, false
+	}
+
+	readIndex := rf.commitIndex
+	term := rf.currentTerm
+
+	sends := make([]func() bool, 0, len(rf.peers))
+	for id := range rf.peers {
+		if id != rf.me {
+			sends = append(sends, rf.replicateTo(id))
+		}
+	}
+
+	rf.mu.Unlock()
+
+	// 发送一轮心跳，确认自己依然是 leader
+	acks := make(chan bool, len(sends))
+	for _, send := range sends {
+		go func(send func() bool) {
+			acks <- send()
+		}(send)
+	}
+
+	count := 1 // 1 是 rf 自己的一票
+	timeout := rf.options.Env.After(minElection)
+	for range sends {
+		if 2*count > len(rf.peers) {
+			break
+		}
+		select {
+		case ack := <-acks:
+			if ack {
+				count++
+			}
+		case <-timeout:
+			return -1, false
+		}
+	}
+
+	if 2*count <= len(rf.peers) {
+		return -1, false
+	}
+
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if !rf.isLeader() || rf.currentTerm != term {
+		return -1, false
+	}
+
+	DPrintf("%s ReadIndex %d", rf, readIndex)
+
+	return readIndex, true
+}
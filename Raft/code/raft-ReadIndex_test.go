@@ -0,0 +1,34 @@
+package raft
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ReadIndex(t *testing.T) {
+	ast := assert.New(t)
+	//
+	servers := 3
+	cfg := makeConfig(t, servers, false)
+	defer cfg.cleanup()
+	//
+	leader := cfg.checkOneLeader()
+	// 还没有 commit 过当前 term 的 log
+	_, ok := cfg.rafts[leader].ReadIndex()
+	ast.False(ok)
+	// follower 不能提供 ReadIndex
+	_, ok = cfg.rafts[(leader+1)%servers].ReadIndex()
+	ast.False(ok)
+	//
+	index := cfg.one(101, servers, false)
+	leader = cfg.checkOneLeader()
+	readIndex, ok := cfg.rafts[leader].ReadIndex()
+	ast.True(ok)
+	ast.True(readIndex >= index)
+	// 失去了多数派的 leader，无法确认自己的身份
+	cfg.disconnect((leader + 1) % servers)
+	cfg.disconnect((leader + 2) % servers)
+	_, ok = cfg.rafts[leader].ReadIndex()
+	ast.False(ok)
+}
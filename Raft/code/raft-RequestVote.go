@@ -11,11 +11,16 @@ type RequestVoteArgs struct {
 	CandidateID  int // candidate requesting vote
 	LastLogIndex int // index of candidate's last log entry
 	LastLogTerm  int // term of candidate's last log entry
+	PreVote      bool
 }
 
 func (a RequestVoteArgs) String() string {
-	return fmt.Sprintf("voteArgs{R%d:T%d;LastIndex:%d;LastTerm:%d}",
-		a.CandidateID, a.Term, a.LastLogIndex, a.LastLogTerm)
+	name := "voteArgs"
+	if a.PreVote {
+		name = "preVoteArgs"
+	}
+	return fmt.Sprintf("%s{R%d:T%d;LastIndex:%d;LastTerm:%d}",
+		name, a.CandidateID, a.Term, a.LastLogIndex, a.LastLogTerm)
 }
 
 // RequestVoteReply is
@@ -47,6 +52,21 @@ func (rf *Raft) RequestVote(args *RequestVoteArgs, reply *RequestVoteReply) {
 		return
 	}
 
+	if (args.PreVote || rf.options.LeaderLease) && rf.hasRecentLeader() {
+		// leader 依然有效，不更新 term，也不投票
+		reply.Term = rf.currentTerm
+		reply.VoteGranted = false
+		return
+	}
+
+	if args.PreVote {
+		// 预投票不会改变 rf 的任何状态
+		reply.Term = rf.currentTerm
+		reply.VoteGranted = args.Term > rf.currentTerm &&
+			isUpToDate(args, rf.getLastTerm(), rf.getLastIndex())
+		return
+	}
+
 	defer rf.persist()
 
 	if args.Term > rf.currentTerm {
@@ -95,7 +115,7 @@ func (rf *Raft) broadcastRequestVote() {
 	rf.mu.Unlock()
 
 	for i := range rf.peers {
-		if i != rf.me && rf.isCandidate() {
+		if i != rf.me && rf.getState() == CANDIDATE {
 			go rf.sendRequestVoteAndDealReply(i, args)
 		}
 	}
@@ -14,16 +14,25 @@ import (
 //
 func (rf *Raft) persist() {
 	// Your code here (2C).
-	// Example:
+	rf.persister.SaveRaftState(rf.encodeState())
+
+	DPrintf("%s PERSISTED", rf)
+}
+
+// persistWithSnapshot 把 state 和 snapshot 作为一个原子操作保存
+func (rf *Raft) persistWithSnapshot(snapshot []byte) {
+	rf.persister.SaveStateAndSnapshot(rf.encodeState(), snapshot)
+
+	DPrintf("%s PERSISTED WITH SNAPSHOT", rf)
+}
+
+func (rf *Raft) encodeState() []byte {
 	w := new(bytes.Buffer)
 	e := labgob.NewEncoder(w)
 	e.Encode(rf.currentTerm)
 	e.Encode(rf.votedFor)
 	e.Encode(rf.logs)
-	data := w.Bytes()
-	rf.persister.SaveRaftState(data)
-
-	DPrintf("%s PERSISTED", rf)
+	return w.Bytes()
 }
 
 //
@@ -39,3 +48,22 @@ func (rf *Raft) readPersist(data []byte) {
 	d.Decode(&rf.votedFor)
 	d.Decode(&rf.logs)
 }
+
+//
+// restore previously persisted snapshot.
+// logs[0] 记录了 snapshot 包含的最后一个 log 的 LogIndex 和 LogTerm
+//
+func (rf *Raft) readSnapshot(data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	baseIndex := rf.getBaseIndex()
+	rf.commitIndex = baseIndex
+	// 重启后，需要先把 snapshot 交给 service
+	rf.pendingSnapshot = &ApplyMsg{
+		CommandValid: false,
+		CommandIndex: baseIndex,
+		Snapshot:     data,
+	}
+	rf.chanCommit <- struct{}{}
+}
@@ -2,7 +2,6 @@ package raft
 
 import (
 	"log"
-	"math/rand"
 	"time"
 )
 
@@ -18,13 +17,15 @@ const (
 	minElection = heartBeat * 10
 	// minElectionInterval 选举过期的最大时间间隔，ms
 	maxElection = minElection * 8 / 5
+	// leaseTimeout leader 租约的时长，比 minElection 略短，以应对各个 server 时钟速度的差异
+	leaseTimeout = minElection * 9 / 10
 
 	// 按照论文 5.6 Timing and availability 的要求
 	// heartBeat 和 minElection 需要相差了一个数量级
 )
 
-func electionTimeout() time.Duration {
+func (rf *Raft) electionTimeout() time.Duration {
 	interval := int(minElection) +
-		rand.Intn(int(maxElection-minElection))
+		rf.options.Env.Intn(int(maxElection-minElection))
 	return time.Duration(interval)
 }
@@ -4,17 +4,34 @@ import (
 	"testing"
 	"time"
 
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
 	"github.com/stretchr/testify/assert"
 )
 
 func Test_ElectionTimeout(t *testing.T) {
 	ast := assert.New(t)
+	rf := &Raft{}
 	for i := 0; i < 1000; i++ {
-		rate := electionTimeout() / heartBeat
+		rate := rf.electionTimeout() / heartBeat
 		ast.True(rate >= 10, "electionTimeout 没有比 heartBeatInterval 大 10 倍")
 	}
 }
 
+func Test_ElectionTimeout_seededEnv(t *testing.T) {
+	ast := assert.New(t)
+	//
+	timeouts := func(seed int64) []time.Duration {
+		rf := &Raft{options: Options{Env: sim.Env{Rand: sim.NewRand(seed)}}}
+		res := make([]time.Duration, 10)
+		for i := range res {
+			res[i] = rf.electionTimeout()
+		}
+		return res
+	}
+	ast.Equal(timeouts(1), timeouts(1), "同样的种子应该产生同样的选举超时")
+	ast.NotEqual(timeouts(1), timeouts(2))
+}
+
 func Test_heartBeat_isInRange(t *testing.T) {
 	ast := assert.New(t)
 	minInterval := 30 * time.Millisecond
@@ -0,0 +1,64 @@
+package rsm
+
+import (
+	crand "crypto/rand"
+	"math/big"
+	"time"
+
+	"github.com/aQuaYi/Distributed-Algorithms/Raft/code/labrpc"
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+)
+
+// RetryInterval 是所有 server 都没能完成请求时，client 重试前等待的时间
+const RetryInterval = 20 * time.Millisecond
+
+// Clerk 是 client 访问 Server 集群的部分：记住 leader，依次尝试各个 server，并为命令编号
+// Clerk 不是并发安全的，client 需要保证自己的请求是串行的
+type Clerk struct {
+	servers  []*labrpc.ClientEnd
+	env      sim.Env
+	clientID int64
+	seq      int
+	leader   int // 上次成功处理请求的 server，大概率依然是 leader
+}
+
+// RandomID 返回一个随机的正数，用作 clientID 等不能重复的 id，0 留给 server 自己发起的命令
+func RandomID() int64 {
+	max := big.NewInt(int64(1) << 62)
+	bigx, _ := crand.Int(crand.Reader, max)
+	return bigx.Int64() + 1
+}
+
+// MakeClerk 返回一个通过 servers 访问集群的 Clerk，ck 从 env 读取时间
+func MakeClerk(servers []*labrpc.ClientEnd, env sim.Env) *Clerk {
+	return &Clerk{
+		servers:  servers,
+		env:      env,
+		clientID: RandomID(),
+	}
+}
+
+// ClientID 返回 ck 的 clientID
+func (ck *Clerk) ClientID() int64 {
+	return ck.clientID
+}
+
+// NextSeq 返回下一个命令的序号
+func (ck *Clerk) NextSeq() int {
+	ck.seq++
+	return ck.seq
+}
+
+// Call 从 leader 开始，依次尝试各个 server，直到 try 返回 true
+func (ck *Clerk) Call(try func(server *labrpc.ClientEnd) bool) {
+	for {
+		for i := 0; i < len(ck.servers); i++ {
+			id := (ck.leader + i) % len(ck.servers)
+			if try(ck.servers[id]) {
+				ck.leader = id
+				return
+			}
+		}
+		ck.env.Sleep(RetryInterval)
+	}
+}
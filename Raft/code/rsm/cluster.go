@@ -0,0 +1,136 @@
+package rsm
+
+import (
+	"fmt"
+	"sync"
+
+	raft "github.com/aQuaYi/Distributed-Algorithms/Raft/code"
+	"github.com/aQuaYi/Distributed-Algorithms/Raft/code/labrpc"
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+)
+
+// Service 是运行在 Cluster 中的 server，它的 RPC handler 和它的 raft 一起注册到 labrpc
+type Service interface {
+	Raft() *raft.Raft
+	Kill()
+}
+
+// StartFunc 启动 service 的第 me 个 server
+// servers 是所有 server 的 raft 端点
+type StartFunc func(servers []*labrpc.ClientEnd, me int, persister *raft.Persister) Service
+
+// Cluster 在 labrpc 模拟的网络中运行一组 Service
+type Cluster struct {
+	mutex     sync.Mutex
+	net       *labrpc.Network
+	env       sim.Env
+	services  []Service
+	endnames  [][]string // endnames[i][j] 是 server i 发往 server j 的端点名称
+	clients   int
+	connected []bool
+}
+
+// MakeCluster 用 start 启动由 n 个 server 组成的集群
+// 网络、server 和 client 都从 env 读取时间和随机数
+func MakeCluster(n int, env sim.Env, start StartFunc) *Cluster {
+	c := &Cluster{
+		net:       labrpc.MakeNetwork(),
+		env:       env,
+		services:  make([]Service, n),
+		endnames:  make([][]string, n),
+		connected: make([]bool, n),
+	}
+	c.net.SetEnv(env)
+
+	for i := 0; i < n; i++ {
+		c.endnames[i] = make([]string, n)
+		ends := make([]*labrpc.ClientEnd, n)
+		for j := 0; j < n; j++ {
+			c.endnames[i][j] = fmt.Sprintf("server-%d-to-%d", i, j)
+			ends[j] = c.net.MakeEnd(c.endnames[i][j])
+			c.net.Connect(c.endnames[i][j], j)
+		}
+
+		svc := start(ends, i, raft.MakePersister())
+		c.services[i] = svc
+
+		srv := labrpc.MakeServer()
+		srv.AddService(labrpc.MakeService(svc))
+		srv.AddService(labrpc.MakeService(svc.Raft()))
+		c.net.AddServer(i, srv)
+	}
+
+	for i := 0; i < n; i++ {
+		c.Connect(i)
+	}
+
+	return c
+}
+
+// Env 返回 c 所使用的 env，client 也应该使用它
+func (c *Cluster) Env() sim.Env {
+	return c.env
+}
+
+// ClientEnds 为一个新的 client 创建访问所有 server 的端点
+func (c *Cluster) ClientEnds() []*labrpc.ClientEnd {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.clients++
+	ends := make([]*labrpc.ClientEnd, len(c.services))
+	for i := range ends {
+		name := fmt.Sprintf("client-%d-to-%d", c.clients, i)
+		ends[i] = c.net.MakeEnd(name)
+		c.net.Connect(name, i)
+		c.net.Enable(name, true)
+	}
+	return ends
+}
+
+// Connect 让 server i 可以与其他已连接的 server 通信
+func (c *Cluster) Connect(i int) {
+	c.setConnected(i, true)
+}
+
+// Disconnect 隔离 server i，但 client 依然可以访问它
+func (c *Cluster) Disconnect(i int) {
+	c.setConnected(i, false)
+}
+
+func (c *Cluster) setConnected(i int, connected bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.connected[i] = connected
+	for j := range c.services {
+		if j == i || c.connected[j] {
+			c.net.Enable(c.endnames[i][j], connected)
+			c.net.Enable(c.endnames[j][i], connected)
+		}
+	}
+}
+
+// Leader 返回当前认为自己是 leader 的 server，没有的话，返回 -1
+func (c *Cluster) Leader() int {
+	for i, svc := range c.services {
+		if _, isLeader := svc.Raft().GetState(); isLeader && c.isConnected(i) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (c *Cluster) isConnected(i int) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.connected[i]
+}
+
+// Cleanup 停止所有的 server，并关闭模拟网络
+func (c *Cluster) Cleanup() {
+	for _, svc := range c.services {
+		svc.Kill()
+	}
+	c.net.Cleanup()
+}
@@ -0,0 +1,209 @@
+package rsm
+
+import (
+	"sync"
+	"time"
+
+	raft "github.com/aQuaYi/Distributed-Algorithms/Raft/code"
+	"github.com/aQuaYi/Distributed-Algorithms/Raft/code/labrpc"
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+)
+
+// waitTimeout 是 server 等待命令被应用的最长时间
+const waitTimeout = time.Second
+
+// Err 是 RPC 的结果，service 可以在此基础上增加自己的 Err
+type Err string
+
+// 枚举了所有 service 都会用到的 Err
+const (
+	OK             Err = "OK"
+	ErrWrongLeader Err = "ErrWrongLeader"
+	ErrTimeout     Err = "ErrTimeout"
+)
+
+// Command 是 client 提交的命令
+// Request 返回发起命令的 client 和命令在 client 中的序号，Server 据此去重。
+// 由 server 自己发起的命令，例如 no-op，clientID 为 0，不去重，也没有人等待它的结果。
+type Command interface {
+	Request() (clientID int64, seq int)
+}
+
+// StateMachine 是由 raft 复制的状态机，每个 service 只需要实现它
+type StateMachine interface {
+	// Apply 应用 log 中 index 处的 command，返回值会交给等待它的 Submit
+	// 重复的命令已经被 Server 去掉了。调用时持有 Server 的锁
+	Apply(command interface{}, index int) interface{}
+}
+
+// result 是 client 最近一次命令的结果，Submit 从中取得返回值
+type result struct {
+	seq   int
+	value interface{}
+}
+
+// Server 把 command 写入 raft log，按照 log 的顺序交给 StateMachine 应用，
+// 并让提交命令的 RPC handler 等到命令被应用以后再返回。
+// 每个 client 最近一次命令的结果保存在 results 中，重传的命令不会被再次应用。
+type Server struct {
+	me      int
+	rf      *raft.Raft
+	applyCh chan raft.ApplyMsg
+	env     sim.Env
+	sm      StateMachine
+
+	mutex       sync.Mutex
+	cond        *sync.Cond
+	results     map[int64]result // 每个 client 最近一次命令的结果
+	lastApplied int              // 已经应用到 state machine 的最大 log index
+	dead        bool
+}
+
+// StartServer 启动一个 Server，由 sm 应用 log 中的命令
+// servers 是所有 server 的 raft 端点，me 是自己在 servers 中的索引，s 和它的 raft 从 env 读取时间和随机数
+func StartServer(servers []*labrpc.ClientEnd, me int, persister *raft.Persister, sm StateMachine, env sim.Env) *Server {
+	s := &Server{
+		me:      me,
+		env:     env,
+		sm:      sm,
+		applyCh: make(chan raft.ApplyMsg),
+		results: make(map[int64]result, 16),
+	}
+	s.cond = sync.NewCond(&s.mutex)
+	s.rf = raft.MakeWithOptions(servers, me, persister, s.applyCh, raft.Options{Env: env})
+
+	go s.applyLoop()
+
+	return s
+}
+
+// Raft 返回 s 所使用的 raft
+func (s *Server) Raft() *raft.Raft {
+	return s.rf
+}
+
+// Kill 停止 s
+func (s *Server) Kill() {
+	s.mutex.Lock()
+	s.dead = true
+	s.mutex.Unlock()
+	s.rf.Kill()
+}
+
+// Killed 返回 s 是否已经停止
+// 利用调用方的锁进行锁定
+func (s *Server) Killed() bool {
+	return s.dead
+}
+
+// Lock 锁定 s，state machine 的状态也由这把锁保护
+func (s *Server) Lock() {
+	s.mutex.Lock()
+}
+
+// Unlock 解锁 s
+func (s *Server) Unlock() {
+	s.mutex.Unlock()
+}
+
+func (s *Server) applyLoop() {
+	for msg := range s.applyCh {
+		if !msg.CommandValid {
+			continue
+		}
+
+		s.mutex.Lock()
+		s.apply(msg.Command, msg.CommandIndex)
+		s.lastApplied = msg.CommandIndex
+		s.cond.Broadcast()
+		s.mutex.Unlock()
+	}
+}
+
+// 利用 applyLoop 的锁进行锁定
+func (s *Server) apply(command interface{}, index int) {
+	cmd, ok := command.(Command)
+	if !ok {
+		s.sm.Apply(command, index)
+		return
+	}
+	clientID, seq := cmd.Request()
+	if clientID == 0 {
+		s.sm.Apply(command, index)
+		return
+	}
+	if seq <= s.results[clientID].seq {
+		// 重复的请求，已经应用过了
+		return
+	}
+	s.results[clientID] = result{seq: seq, value: s.sm.Apply(command, index)}
+}
+
+// waitUntil 阻塞到 done 返回 true 或者超时，返回 done 的最终结果
+// 利用调用方的锁进行锁定
+func (s *Server) waitUntil(done func() bool) bool {
+	timer := s.env.AfterFunc(waitTimeout, func() {
+		s.mutex.Lock()
+		s.cond.Broadcast()
+		s.mutex.Unlock()
+	})
+	defer timer.Stop()
+
+	deadline := s.env.Now().Add(waitTimeout)
+	for !done() && s.env.Now().Before(deadline) {
+		s.cond.Wait()
+	}
+	return done()
+}
+
+// WaitApplied 阻塞到 index 被应用或者超时，超时返回 false
+func (s *Server) WaitApplied(index int) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.waitUntil(func() bool {
+		return s.lastApplied >= index
+	})
+}
+
+// Submit 把 cmd 写入 raft log，并等待其被应用，返回 StateMachine.Apply 的结果
+func (s *Server) Submit(cmd Command) (interface{}, Err) {
+	index, _, isLeader := s.rf.Start(cmd)
+	if !isLeader {
+		return nil, ErrWrongLeader
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	applied := s.waitUntil(func() bool {
+		return s.lastApplied >= index
+	})
+	if !applied {
+		return nil, ErrTimeout
+	}
+	clientID, seq := cmd.Request()
+	res := s.results[clientID]
+	if res.seq != seq {
+		// index 上被 commit 的是其他的命令，说明 leader 已经换了
+		return nil, ErrWrongLeader
+	}
+	return res.value, OK
+}
+
+// ReadIndex 获取 ReadIndex
+// 如果 leader 还没有 commit 过当前 term 的 log，就先提交 noop
+func (s *Server) ReadIndex(noop Command) (int, bool) {
+	if index, ok := s.rf.ReadIndex(); ok {
+		return index, true
+	}
+
+	index, _, isLeader := s.rf.Start(noop)
+	if !isLeader {
+		return -1, false
+	}
+	if !s.WaitApplied(index) {
+		return -1, false
+	}
+
+	return s.rf.ReadIndex()
+}
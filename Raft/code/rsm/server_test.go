@@ -0,0 +1,140 @@
+package rsm
+
+import (
+	"testing"
+
+	raft "github.com/aQuaYi/Distributed-Algorithms/Raft/code"
+	"github.com/aQuaYi/Distributed-Algorithms/Raft/code/labgob"
+	"github.com/aQuaYi/Distributed-Algorithms/Raft/code/labrpc"
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	labgob.Register(addOp{})
+}
+
+// addOp 给 counter 加上 Delta
+type addOp struct {
+	ClientID int64
+	Seq      int
+	Delta    int
+}
+
+func (op addOp) Request() (int64, int) {
+	return op.ClientID, op.Seq
+}
+
+// counter 是测试用的 state machine，返回加完以后的值
+type counter struct {
+	value int
+}
+
+func (c *counter) Apply(command interface{}, index int) interface{} {
+	if op, ok := command.(addOp); ok {
+		c.value += op.Delta
+	}
+	return c.value
+}
+
+// counterServer 是运行在 Cluster 中的 counter
+type counterServer struct {
+	*Server
+	counter *counter
+}
+
+type addReply struct {
+	Err   Err
+	Value int
+}
+
+// Add 是 RPC handler
+func (cs *counterServer) Add(args *addOp, reply *addReply) {
+	value, err := cs.Submit(*args)
+	if reply.Err = err; err == OK {
+		reply.Value = value.(int)
+	}
+}
+
+func makeCounterCluster(n int) (*Cluster, []*counterServer) {
+	servers := make([]*counterServer, n)
+	c := MakeCluster(n, sim.Env{}, func(ends []*labrpc.ClientEnd, me int, persister *raft.Persister) Service {
+		cs := &counterServer{counter: &counter{}}
+		cs.Server = StartServer(ends, me, persister, cs.counter, sim.Env{})
+		servers[me] = cs
+		return cs
+	})
+	return c, servers
+}
+
+func Test_Server_apply(t *testing.T) {
+	ast := assert.New(t)
+	//
+	sm := &counter{}
+	s := &Server{sm: sm, results: make(map[int64]result)}
+	s.apply(addOp{ClientID: 1, Seq: 1, Delta: 1}, 1)
+	s.apply(addOp{ClientID: 1, Seq: 2, Delta: 2}, 2)
+	ast.Equal(result{seq: 2, value: 3}, s.results[1])
+	// 重传的旧请求不会被再次应用
+	s.apply(addOp{ClientID: 1, Seq: 1, Delta: 1}, 3)
+	s.apply(addOp{ClientID: 1, Seq: 2, Delta: 2}, 4)
+	ast.Equal(3, sm.value)
+	// clientID 为 0 的命令不去重，也不记录结果
+	s.apply(addOp{Delta: 10}, 5)
+	s.apply(addOp{Delta: 10}, 6)
+	ast.Equal(23, sm.value)
+	ast.Equal(1, len(s.results))
+	// 不是 Command 的命令也交给 state machine
+	s.apply("noop", 7)
+	ast.Equal(23, sm.value)
+}
+
+func Test_Server_Submit(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c, servers := makeCounterCluster(3)
+	defer c.Cleanup()
+	ck := MakeClerk(c.ClientEnds(), c.Env())
+	//
+	submit := func(delta int) int {
+		op := addOp{ClientID: ck.ClientID(), Seq: ck.NextSeq(), Delta: delta}
+		var reply addReply
+		ck.Call(func(server *labrpc.ClientEnd) bool {
+			return server.Call("counterServer.Add", &op, &reply) && reply.Err == OK
+		})
+		return reply.Value
+	}
+	ast.Equal(1, submit(1))
+	ast.Equal(3, submit(2))
+	//
+	leader := c.Leader()
+	ast.NotEqual(-1, leader)
+	_, err := servers[(leader+1)%3].Submit(addOp{ClientID: ck.ClientID(), Seq: ck.NextSeq(), Delta: 1})
+	ast.Equal(ErrWrongLeader, err)
+	// 重复提交已经应用过的命令，得到第一次的结果
+	v, err := servers[leader].Submit(addOp{ClientID: ck.ClientID(), Seq: 2, Delta: 2})
+	ast.Equal(OK, err)
+	ast.Equal(3, v)
+}
+
+func Test_Server_ReadIndex(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c, servers := makeCounterCluster(3)
+	defer c.Cleanup()
+	var leader int
+	for leader = c.Leader(); leader == -1; leader = c.Leader() {
+		c.Env().Sleep(RetryInterval)
+	}
+	//
+	index, ok := servers[leader].ReadIndex(addOp{})
+	ast.True(ok)
+	ast.True(servers[leader].WaitApplied(index))
+	_, ok = servers[(leader+1)%3].ReadIndex(addOp{})
+	ast.False(ok)
+	// 被隔离的 leader 无法确认自己的身份
+	c.Disconnect(leader)
+	_, ok = servers[leader].ReadIndex(addOp{})
+	ast.False(ok)
+	c.Connect(leader)
+}
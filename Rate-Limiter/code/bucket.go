@@ -0,0 +1,69 @@
+package ratelimiter
+
+import "time"
+
+// Bucket 是单机的 token bucket
+// token 以每秒 Rate 个的速度生成，最多积累 Burst 个，每次请求消耗若干个 token。
+// 时间用 time.Duration 表示，是从模拟开始时计算的时长
+type Bucket struct {
+	Rate   float64
+	Burst  float64
+	tokens float64
+	last   time.Duration
+}
+
+// NewBucket 返回在 now 时装满了 token 的 Bucket
+func NewBucket(rate, burst float64, now time.Duration) *Bucket {
+	return &Bucket{
+		Rate:   rate,
+		Burst:  burst,
+		tokens: burst,
+		last:   now,
+	}
+}
+
+// refill 补上从上次到 now 生成的 token
+func (b *Bucket) refill(now time.Duration) {
+	if now > b.last {
+		b.tokens += b.Rate * (now - b.last).Seconds()
+		b.last = now
+	}
+	if b.tokens > b.Burst {
+		b.tokens = b.Burst
+	}
+}
+
+// Tokens 返回 now 时 bucket 中的 token 数量
+func (b *Bucket) Tokens(now time.Duration) float64 {
+	b.refill(now)
+	return b.tokens
+}
+
+// Take 在 token 足够时消耗 n 个 token 并返回 true，否则什么也不做，返回 false
+func (b *Bucket) Take(now time.Duration, n float64) bool {
+	b.refill(now)
+	if b.tokens < n {
+		return false
+	}
+	b.tokens -= n
+	return true
+}
+
+// TakeUpTo 消耗最多 n 个 token，返回实际消耗的数量
+func (b *Bucket) TakeUpTo(now time.Duration, n float64) float64 {
+	b.refill(now)
+	if n > b.tokens {
+		n = b.tokens
+	}
+	b.tokens -= n
+	return n
+}
+
+// SetRate 修改 token 生成的速度和容量，已经生成的 token 不会超过新的容量
+func (b *Bucket) SetRate(now time.Duration, rate, burst float64) {
+	b.refill(now)
+	b.Rate, b.Burst = rate, burst
+	if b.tokens > burst {
+		b.tokens = burst
+	}
+}
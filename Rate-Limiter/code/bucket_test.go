@@ -0,0 +1,44 @@
+package ratelimiter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Bucket_Take(t *testing.T) {
+	ast := assert.New(t)
+	//
+	b := NewBucket(10, 5, 0)
+	for i := 0; i < 5; i++ {
+		ast.True(b.Take(0, 1))
+	}
+	ast.False(b.Take(0, 1), "burst 用完了")
+	//
+	ast.True(b.Take(100*time.Millisecond, 1), "100ms 生成了 1 个 token")
+	ast.False(b.Take(100*time.Millisecond, 1))
+	// 空闲再久，也最多积累 Burst 个 token
+	ast.Equal(5.0, b.Tokens(time.Hour))
+	ast.False(b.Take(time.Hour, 6))
+	ast.Equal(5.0, b.Tokens(time.Hour), "失败的 Take 不消耗 token")
+}
+
+func Test_Bucket_TakeUpTo(t *testing.T) {
+	ast := assert.New(t)
+	//
+	b := NewBucket(10, 5, 0)
+	ast.Equal(3.0, b.TakeUpTo(0, 3))
+	ast.Equal(2.0, b.TakeUpTo(0, 3))
+	ast.Equal(0.0, b.TakeUpTo(0, 3))
+}
+
+func Test_Bucket_SetRate(t *testing.T) {
+	ast := assert.New(t)
+	//
+	b := NewBucket(10, 10, 0)
+	b.SetRate(0, 100, 4)
+	ast.Equal(4.0, b.Tokens(0), "token 不超过新的容量")
+	b.TakeUpTo(0, 4)
+	ast.InDelta(1.0, b.Tokens(10*time.Millisecond), 1e-9)
+}
@@ -0,0 +1,75 @@
+package ratelimiter
+
+// minShare 是每个 node 至少分到的份额，占平均需求的比例
+// 空闲的 node 也需要一点 token，才能应对突然到来的请求，并让自己的需求被其他 node 知道
+const minShare = 0.1
+
+// allowGossip 只使用 nd 自己的 bucket，不需要联系任何 node
+func (nd *Node) allowGossip(n float64) bool {
+	nd.requested += n
+	return nd.bucket.Take(nd.c.now, n)
+}
+
+// Gossip 进行一轮 gossip，Lease 模式下什么也不做
+// 每个 node 先更新自己的需求，再把自己的 view 发送给 Fanout 个能联系到的随机 peer，
+// peer 保留每个 node 较新的记录。最后，所有的 node 按照各自的 view 重新计算份额。
+func (c *Cluster) Gossip() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.mode != Gossip {
+		return
+	}
+	for _, nd := range c.nodes {
+		last := nd.view[nd.id]
+		demand := last.demand
+		if elapsed := (c.now - last.at).Seconds(); elapsed > 0 {
+			demand = (demand + nd.requested/elapsed) / 2
+		}
+		nd.view[nd.id] = entry{demand: demand, at: c.now}
+		nd.requested = 0
+	}
+	for _, nd := range c.nodes {
+		sent := 0
+		for _, i := range c.rand.Perm(len(c.nodes)) {
+			if sent == c.config.Fanout {
+				break
+			}
+			if i == nd.id || !c.net.Reachable(nd.id, i) {
+				continue
+			}
+			c.nodes[i].merge(nd.view)
+			c.stats.Messages++
+			sent++
+		}
+	}
+	for _, nd := range c.nodes {
+		nd.rebalance()
+	}
+}
+
+// merge 把收到的 view 合并到自己的 view 中
+func (nd *Node) merge(view map[int]entry) {
+	for id, e := range view {
+		if e.at > nd.view[id].at {
+			nd.view[id] = e
+		}
+	}
+}
+
+// rebalance 按照 view 中各个 node 的需求，计算自己在全局 budget 中的份额
+// 超过 Timeout 没有更新的 node 被认为已经失效，它的份额由其他 node 分享。
+// partition 发生以后，每一侧都会认为另一侧失效了，各自使用全部的 budget
+func (nd *Node) rebalance() {
+	c := nd.c
+	total, live := 0.0, 0
+	for id, e := range nd.view {
+		if id != nd.id && c.config.Timeout > 0 && c.now-e.at > c.config.Timeout {
+			continue
+		}
+		total += e.demand
+		live++
+	}
+	floor := minShare*total/float64(live) + 1e-9
+	share := (nd.view[nd.id].demand + floor) / (total + floor*float64(live))
+	nd.bucket.SetRate(c.now, c.config.Rate*share, c.config.Burst*share)
+}
@@ -0,0 +1,31 @@
+package ratelimiter
+
+// allowLease 优先使用 lease 中剩下的 token，不够时向 coordinator 申请新的 lease
+// 全局的 token 只由 coordinator 发放，所以整个集群允许的 token 不会超过 budget；
+// 但是联系不上 coordinator 的 node，lease 到期以后只能拒绝所有的请求。
+// lease 到期时没有用完的 token 不会归还，这部分 budget 就浪费了
+func (nd *Node) allowLease(n float64) bool {
+	c := nd.c
+	if c.now >= nd.expires {
+		nd.leased = 0
+	}
+	if nd.leased < n {
+		if !c.net.Reachable(nd.id, coordinator) {
+			return false
+		}
+		want := c.config.LeaseTokens
+		if want < n-nd.leased {
+			want = n - nd.leased
+		}
+		if nd.id != coordinator {
+			c.stats.Messages += 2
+		}
+		nd.leased += c.global.TakeUpTo(c.now, want)
+		nd.expires = c.now + c.config.LeaseTerm
+	}
+	if nd.leased < n {
+		return false
+	}
+	nd.leased -= n
+	return true
+}
@@ -0,0 +1,188 @@
+package ratelimiter
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Mode 决定了 node 之间如何分享全局的 token
+type Mode int
+
+const (
+	// Lease 由 coordinator 持有全局的 bucket，node 每次向它申请一批 token，
+	// 这批 token 只在一个 lease 内有效
+	Lease Mode = iota
+	// Gossip 让每个 node 持有全局 bucket 的一部分，
+	// node 之间通过 gossip 交换各自的需求，按照需求重新分配份额
+	Gossip
+)
+
+func (m Mode) String() string {
+	switch m {
+	case Lease:
+		return "Lease"
+	case Gossip:
+		return "Gossip"
+	}
+	return fmt.Sprintf("Mode(%d)", int(m))
+}
+
+// coordinator 是 Lease 模式下持有全局 bucket 的 node
+const coordinator = 0
+
+// Config 是分布式 token bucket 的参数
+type Config struct {
+	// Rate 是整个集群每秒允许的 token 数量
+	Rate float64
+	// Burst 是整个集群最多积累的 token 数量
+	Burst float64
+	// LeaseTokens 是 Lease 模式下，node 每次向 coordinator 申请的 token 数量
+	LeaseTokens float64
+	// LeaseTerm 是 lease 的有效期，到期以后没有用完的 token 作废
+	LeaseTerm time.Duration
+	// Fanout 是 Gossip 模式下，每一轮 gossip 中每个 node 联系的 peer 数量
+	Fanout int
+	// Timeout 是 Gossip 模式下，超过 Timeout 没有某个 node 的消息，就认为它失效了，
+	// 把它的份额分给其他 node。Timeout <= 0 时，永远为它保留份额
+	Timeout time.Duration
+}
+
+// Stats 统计了集群处理的请求
+type Stats struct {
+	Admitted float64 // 允许的 token 数量
+	Rejected float64 // 拒绝的 token 数量
+	Messages int     // node 之间发送的消息数量
+}
+
+// Cluster 模拟了共享同一个全局 token bucket 的 node
+// 时间只会在 Advance 时前进，gossip 只会在 Gossip 时发生，所以模拟是确定的
+type Cluster struct {
+	mutex  sync.Mutex
+	mode   Mode
+	config Config
+	net    *Network
+	rand   *rand.Rand
+	now    time.Duration
+	nodes  []*Node
+	global *Bucket // Lease 模式下 coordinator 持有的 bucket
+	stats  Stats
+}
+
+// NewCluster 返回由 size 个 node 组成的集群，seed 决定了 gossip 时选择的 peer
+func NewCluster(size int, mode Mode, config Config, seed int64) *Cluster {
+	c := &Cluster{
+		mode:   mode,
+		config: config,
+		net:    NewNetwork(),
+		rand:   rand.New(rand.NewSource(seed)),
+		nodes:  make([]*Node, size),
+	}
+	switch mode {
+	case Lease:
+		c.global = NewBucket(config.Rate, config.Burst, 0)
+	case Gossip:
+		if c.config.Fanout < 1 {
+			c.config.Fanout = 1
+		}
+	}
+	for i := range c.nodes {
+		c.nodes[i] = newNode(i, c)
+	}
+	return c
+}
+
+// Node 返回第 i 个 node
+func (c *Cluster) Node(i int) *Node {
+	return c.nodes[i]
+}
+
+// Network 返回 node 之间的网络，用来制造 partition
+func (c *Cluster) Network() *Network {
+	return c.net
+}
+
+// Now 返回模拟开始以后经过的时间
+func (c *Cluster) Now() time.Duration {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+// Advance 让时间前进 d
+func (c *Cluster) Advance(d time.Duration) {
+	c.mutex.Lock()
+	c.now += d
+	c.mutex.Unlock()
+}
+
+// Stats 返回整个集群的统计
+func (c *Cluster) Stats() Stats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.stats
+}
+
+// Node 是分布式 rate limiter 中的一个 node
+type Node struct {
+	id       int
+	c        *Cluster
+	admitted float64
+
+	// Lease 模式
+	leased  float64       // 还没有用完的 token
+	expires time.Duration // leased 作废的时间
+
+	// Gossip 模式
+	bucket    *Bucket
+	requested float64       // 上一轮 gossip 以来请求的 token 数量
+	view      map[int]entry // 自己所知的各个 node 的需求
+}
+
+// entry 是 node 在 at 时的需求，单位是每秒请求的 token 数量
+type entry struct {
+	demand float64
+	at     time.Duration
+}
+
+func newNode(id int, c *Cluster) *Node {
+	nd := &Node{id: id, c: c}
+	if c.mode == Gossip {
+		size := float64(len(c.nodes))
+		nd.bucket = NewBucket(c.config.Rate/size, c.config.Burst/size, 0)
+		nd.view = make(map[int]entry, len(c.nodes))
+		for i := range c.nodes {
+			nd.view[i] = entry{}
+		}
+	}
+	return nd
+}
+
+// Allow 返回 true，如果全局的 budget 允许 nd 现在处理需要 n 个 token 的请求
+func (nd *Node) Allow(n int) bool {
+	c := nd.c
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var ok bool
+	switch c.mode {
+	case Lease:
+		ok = nd.allowLease(float64(n))
+	case Gossip:
+		ok = nd.allowGossip(float64(n))
+	}
+	if ok {
+		nd.admitted += float64(n)
+		c.stats.Admitted += float64(n)
+	} else {
+		c.stats.Rejected += float64(n)
+	}
+	return ok
+}
+
+// Admitted 返回 nd 允许的 token 数量
+func (nd *Node) Admitted() float64 {
+	nd.c.mutex.Lock()
+	defer nd.c.mutex.Unlock()
+	return nd.admitted
+}
@@ -0,0 +1,166 @@
+package ratelimiter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var config = Config{
+	Rate:        1000,
+	Burst:       100,
+	LeaseTokens: 20,
+	LeaseTerm:   200 * time.Millisecond,
+	Fanout:      2,
+	Timeout:     time.Second,
+}
+
+const (
+	step           = 10 * time.Millisecond
+	gossipInterval = 100 * time.Millisecond
+)
+
+// run 模拟 duration 时长的请求，每个 step 中第 i 个 node 请求 demand[i] 次，每次 1 个 token
+// 返回每个 node 允许的请求数量
+func run(c *Cluster, duration time.Duration, demand []int) []float64 {
+	before := make([]float64, len(demand))
+	for i := range demand {
+		before[i] = c.Node(i).Admitted()
+	}
+	for t := time.Duration(0); t < duration; t += step {
+		c.Advance(step)
+		if c.Now()%gossipInterval == 0 {
+			c.Gossip()
+		}
+		for i, d := range demand {
+			for j := 0; j < d; j++ {
+				c.Node(i).Allow(1)
+			}
+		}
+	}
+	res := make([]float64, len(demand))
+	for i := range demand {
+		res[i] = c.Node(i).Admitted() - before[i]
+	}
+	return res
+}
+
+func sum(xs []float64) float64 {
+	res := 0.0
+	for _, x := range xs {
+		res += x
+	}
+	return res
+}
+
+// budget 是 duration 内全局 bucket 最多允许的 token 数量
+func budget(duration time.Duration) float64 {
+	return config.Burst + config.Rate*duration.Seconds()
+}
+
+func Test_Cluster_withinBudget(t *testing.T) {
+	ast := assert.New(t)
+	//
+	demand := []int{4, 4, 4, 4, 4} // 每秒 2000 个请求，超过了 Rate
+	duration := 5 * time.Second
+	for _, mode := range []Mode{Lease, Gossip} {
+		c := NewCluster(len(demand), mode, config, 1)
+		admitted := sum(run(c, duration, demand))
+		if mode == Lease {
+			ast.True(admitted <= budget(duration), "%f", admitted)
+		} else {
+			// 各个 node 的 view 不完全相同，份额之和会略微偏离 1
+			ast.InDelta(budget(duration), admitted, 0.05*budget(duration))
+		}
+		ast.True(admitted >= 0.9*config.Rate*duration.Seconds(), "%s %f", mode, admitted)
+		st := c.Stats()
+		ast.Equal(admitted, st.Admitted)
+		ast.Equal(float64(4*len(demand)*int(duration/step)), st.Admitted+st.Rejected)
+	}
+}
+
+func Test_Cluster_skewedDemand(t *testing.T) {
+	ast := assert.New(t)
+	//
+	// node 0 的需求远大于其他 node，gossip 让它分到大部分的份额
+	demand := []int{15, 1, 1, 0, 0}
+	duration := 5 * time.Second
+	for _, mode := range []Mode{Lease, Gossip} {
+		c := NewCluster(len(demand), mode, config, 1)
+		run(c, time.Second, demand)
+		admitted := run(c, duration, demand)
+		ast.True(sum(admitted) <= 1.05*budget(duration), "%s %v", mode, admitted)
+		ast.True(admitted[0] > 0.8*config.Rate*duration.Seconds(), "%s %v", mode, admitted)
+	}
+	// 没有 gossip 时，每个 node 只能使用平均的份额
+	c := NewCluster(len(demand), Gossip, config, 1)
+	admitted := 0.0
+	for i := 0; i < int(duration/step); i++ {
+		c.Advance(step)
+		for j := 0; j < demand[0]; j++ {
+			if c.Node(0).Allow(1) {
+				admitted++
+			}
+		}
+	}
+	ast.InDelta(budget(duration)/float64(len(demand)), admitted, 5)
+}
+
+func Test_Cluster_partition(t *testing.T) {
+	ast := assert.New(t)
+	//
+	demand := []int{4, 4, 4, 4, 4}
+	duration := 5 * time.Second
+	minority, majority := []int{0, 1}, []int{2, 3, 4}
+	side := func(admitted []float64, ids []int) float64 {
+		res := 0.0
+		for _, id := range ids {
+			res += admitted[id]
+		}
+		return res
+	}
+	//
+	// Lease：coordinator 在 minority 一侧，majority 的 lease 到期以后只能拒绝所有的请求
+	c := NewCluster(len(demand), Lease, config, 1)
+	run(c, time.Second, demand)
+	c.Network().Partition(minority)
+	admitted := run(c, duration, demand)
+	ast.True(sum(admitted) <= budget(duration), "%v", admitted)
+	ast.True(side(admitted, majority) <= 3*config.LeaseTokens, "%v", admitted)
+	t.Logf("Lease:  minority %6.0f, majority %6.0f, total %6.0f, budget %6.0f",
+		side(admitted, minority), side(admitted, majority), sum(admitted), budget(duration))
+	//
+	// Gossip：超过 Timeout 以后，两侧都认为对方失效了，各自使用全部的 budget
+	c = NewCluster(len(demand), Gossip, config, 1)
+	run(c, time.Second, demand)
+	c.Network().Partition(minority)
+	admitted = run(c, duration, demand)
+	ast.True(side(admitted, majority) > 0.5*config.Rate*duration.Seconds(), "%v", admitted)
+	ast.True(sum(admitted) > 1.5*budget(duration), "%v", admitted)
+	t.Logf("Gossip: minority %6.0f, majority %6.0f, total %6.0f, budget %6.0f",
+		side(admitted, minority), side(admitted, majority), sum(admitted), budget(duration))
+	//
+	// 永远为失联的 node 保留份额，就不会超出 budget，代价是两侧都只能使用原来的份额
+	never := config
+	never.Timeout = 0
+	c = NewCluster(len(demand), Gossip, never, 1)
+	run(c, time.Second, demand)
+	c.Network().Partition(minority)
+	admitted = run(c, duration, demand)
+	ast.True(sum(admitted) <= 1.05*budget(duration), "%v", admitted)
+	ast.InDelta(0.6*config.Rate*duration.Seconds(), side(admitted, majority), 0.1*config.Rate*duration.Seconds())
+	//
+	// partition 恢复以后，gossip 模式重新回到 budget 之内
+	c.Network().Heal()
+	run(c, time.Second, demand)
+	ast.True(sum(run(c, duration, demand)) <= 1.05*budget(duration))
+}
+
+func Test_Mode_String(t *testing.T) {
+	ast := assert.New(t)
+	//
+	ast.Equal("Lease", Lease.String())
+	ast.Equal("Gossip", Gossip.String())
+	ast.Equal("Mode(7)", Mode(7).String())
+}
@@ -0,0 +1,40 @@
+package ratelimiter
+
+import "sync"
+
+// Network 记录了 node 之间的连通性，可以把 node 分成互不连通的若干组
+type Network struct {
+	mutex sync.Mutex
+	group map[int]int // node 所在的组，不在 map 中的 node 属于组 0
+}
+
+// NewNetwork 返回所有 node 都互相连通的网络
+func NewNetwork() *Network {
+	return &Network{
+		group: make(map[int]int, 16),
+	}
+}
+
+// Partition 把 groups 中的每一组 node 隔离开，没有出现在 groups 中的 node 属于同一组
+func (n *Network) Partition(groups ...[]int) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.group = make(map[int]int, 16)
+	for i, g := range groups {
+		for _, id := range g {
+			n.group[id] = i + 1
+		}
+	}
+}
+
+// Heal 恢复所有 node 之间的连接
+func (n *Network) Heal() {
+	n.Partition()
+}
+
+// Reachable 返回 true，如果 a 和 b 可以互相通信
+func (n *Network) Reachable(a, b int) bool {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return n.group[a] == n.group[b]
+}
@@ -0,0 +1,114 @@
+package reliablechannel
+
+import "time"
+
+// batchEntryHeader 是 batch 中每条消息额外占用的字节数，用来记录消息的长度
+const batchEntryHeader = 2
+
+// batch 是合并在同一个 frame 中发送的多条消息
+// 整个 batch 共用一个序号、一个 frame 头和一个 ack
+type batch []interface{}
+
+// Size 实现了 sizer 接口
+func (b batch) Size() int {
+	size := 0
+	for _, p := range b {
+		size += batchEntryHeader + payloadSize(p)
+	}
+	return size
+}
+
+// messages 返回 payload 中包含的消息数量
+func messages(payload interface{}) int {
+	if b, ok := payload.(batch); ok {
+		return len(b)
+	}
+	return 1
+}
+
+// 利用调用方的锁进行锁定
+// flush 在窗口允许的范围内，把发往 to 的排队消息合并成 frame 发送出去
+//
+// 窗口限制了还没有被确认的 frame 的数量，窗口内的 frame 不需要等待前一个 frame 的 ack，
+// 这就是 pipelining。窗口满了以后积压的消息，会在窗口空出位置时合并成一个 frame，
+// 所以链路越慢，batch 越大。队列中的消息不足 MaxBatch 条时，最多再等待 BatchDelay。
+func (e *Endpoint) flush(to int, now time.Time) {
+	maxBatch := e.config.MaxBatch
+	if maxBatch < 1 {
+		maxBatch = 1
+	}
+	for len(e.queue[to]) > 0 && e.windowOpen(to) {
+		q := e.queue[to]
+		n := len(q)
+		if n > maxBatch {
+			n = maxBatch
+		}
+		if wait := e.config.BatchDelay - now.Sub(q[0].at); n < maxBatch && wait > 0 {
+			e.flushLater(to, wait)
+			return
+		}
+		e.sendFrame(to, q[:n])
+		e.queue[to] = q[n:]
+	}
+	if len(e.queue[to]) == 0 {
+		delete(e.queue, to)
+	}
+}
+
+// 利用调用方的锁进行锁定
+func (e *Endpoint) windowOpen(to int) bool {
+	return e.config.Window <= 0 || len(e.unacked[to]) < e.config.Window
+}
+
+// 利用调用方的锁进行锁定
+// flushLater 在 wait 以后再次 flush 发往 to 的队列，已经有定时器时什么也不做
+func (e *Endpoint) flushLater(to int, wait time.Duration) {
+	if _, ok := e.timers[to]; ok {
+		return
+	}
+	e.timers[to] = e.net.env.AfterFunc(wait, func() {
+		e.mutex.Lock()
+		defer e.mutex.Unlock()
+		delete(e.timers, to)
+		if !e.closed {
+			e.flush(to, e.net.env.Now())
+		}
+	})
+}
+
+// 利用调用方的锁进行锁定
+func (e *Endpoint) sendFrame(to int, qs []queued) {
+	var payload interface{}
+	if len(qs) == 1 {
+		payload = qs[0].payload
+	} else {
+		b := make(batch, len(qs))
+		for i, q := range qs {
+			b[i] = q.payload
+		}
+		payload = b
+	}
+
+	seq := e.nextSeqOf(to)
+	e.nextSeq[to] = seq + 1
+
+	f := newDataFrame(e.me, to, seq, payload)
+	if e.unacked[to] == nil {
+		e.unacked[to] = make(map[int]*pending, 64)
+	}
+	e.unacked[to][seq] = &pending{f: f, lastSent: e.net.env.Now()}
+
+	e.net.send(f)
+}
+
+// 利用调用方的锁进行锁定
+// deliver 把 payload 中的消息按顺序放入 inbox
+func (e *Endpoint) deliver(from int, payload interface{}) {
+	if b, ok := payload.(batch); ok {
+		for _, p := range b {
+			e.inbox = append(e.inbox, Delivery{From: from, Payload: p})
+		}
+		return
+	}
+	e.inbox = append(e.inbox, Delivery{From: from, Payload: payload})
+}
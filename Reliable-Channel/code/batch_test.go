@@ -0,0 +1,287 @@
+package reliablechannel
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_batch_Size(t *testing.T) {
+	ast := assert.New(t)
+	//
+	b := batch{"abc", []byte{1, 2}, 42}
+	ast.Equal(3*batchEntryHeader+3+2, b.Size())
+	ast.Equal(frameHeaderSize+b.Size(), frameSize(newDataFrame(0, 1, 1, b)))
+	ast.Equal(3, messages(b))
+	ast.Equal(1, messages("abc"))
+}
+
+func Test_Endpoint_batchingOverLossyNetwork(t *testing.T) {
+	ast := assert.New(t)
+	//
+	net := NewLossyNetwork(0.3, 0.3, 2*time.Millisecond)
+	config := Config{RTO: 5 * time.Millisecond, Window: 4, MaxBatch: 8}
+	a := NewEndpointWithConfig(0, net, config)
+	b := NewEndpointWithConfig(1, net, config)
+	defer a.Close()
+	defer b.Close()
+	//
+	size := 500
+	for i := 0; i < size; i++ {
+		a.Send(1, i)
+	}
+	for i := 0; i < size; i++ {
+		d, ok := b.Receive()
+		ast.True(ok)
+		ast.Equal(i, d.Payload)
+	}
+	// 窗口满了以后积压的消息被合并了，data frame 比消息少得多
+	ast.True(net.Stats().Sent < size, "%v", net.Stats())
+}
+
+func Test_Endpoint_windowLimitsInFlightFrames(t *testing.T) {
+	ast := assert.New(t)
+	//
+	net := NewLossyNetwork(0, 0, 0)
+	net.SetDefaultLink(Link{Latency: Fixed(50 * time.Millisecond)})
+	config := Config{RTO: time.Second, Window: 4}
+	a := NewEndpointWithConfig(0, net, config)
+	b := NewEndpointWithConfig(1, net, config)
+	defer a.Close()
+	defer b.Close()
+	//
+	size := 20
+	for i := 0; i < size; i++ {
+		a.Send(1, i)
+	}
+	// ack 回来之前，只有窗口中的 frame 被发送了
+	ast.Equal(4, net.Stats().Sent)
+	ast.Equal(size, a.Unacked())
+	//
+	for i := 0; i < size; i++ {
+		d, ok := b.Receive()
+		ast.True(ok)
+		ast.Equal(i, d.Payload)
+	}
+}
+
+func Test_Endpoint_batchDelay(t *testing.T) {
+	ast := assert.New(t)
+	//
+	net := NewLossyNetwork(0, 0, 0)
+	config := Config{RTO: time.Second, MaxBatch: 10, BatchDelay: 20 * time.Millisecond}
+	a := NewEndpointWithConfig(0, net, config)
+	b := NewEndpointWithConfig(1, net, config)
+	defer a.Close()
+	defer b.Close()
+	//
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		a.Send(1, i)
+	}
+	// 不足 MaxBatch 条的消息要等待 BatchDelay
+	ast.Equal(0, net.Stats().Sent)
+	for i := 0; i < 3; i++ {
+		d, ok := b.Receive()
+		ast.True(ok)
+		ast.Equal(i, d.Payload)
+	}
+	ast.True(time.Since(start) >= 20*time.Millisecond)
+	// 凑满 MaxBatch 条以后，立即发送
+	sent := net.Stats().Sent
+	for i := 0; i < 10; i++ {
+		a.Send(1, i)
+	}
+	ast.Equal(sent+1, net.Stats().Sent)
+}
+
+// benchLink 是 benchmark 使用的链路：1ms 的传播延迟，1MB/s 的带宽
+var benchLink = Link{Latency: Fixed(time.Millisecond), Bandwidth: 1 << 20}
+
+// benchConfigs 是 benchmark 比较的 Endpoint 设置
+var benchConfigs = []struct {
+	name   string
+	config Config
+}{
+	{"stop-and-wait", Config{RTO: 100 * time.Millisecond, Window: 1}},
+	{"pipelined", Config{RTO: 100 * time.Millisecond, Window: 32}},
+	{"batched", Config{RTO: 100 * time.Millisecond, Window: 8, MaxBatch: 16}},
+}
+
+// logEntry 是 Raft leader 发给 follower 的 log
+type logEntry struct {
+	Index   int
+	Command []byte
+}
+
+func (l logEntry) Size() int { return 8 + len(l.Command) }
+
+// matchIndex 是 follower 回复给 leader 的已经复制的最大 index
+type matchIndex int
+
+func (matchIndex) Size() int { return 8 }
+
+// replicateLog 模拟 Raft 的 log 复制：leader 0 把 entries 条 log 发给 followers 个 follower，
+// 每个 follower 收到 log 后回复 matchIndex，过半数的 server 复制了的 log 就被 commit 了
+// 返回 commit 所有 log 需要的时间
+func replicateLog(config Config, followers, entries int) time.Duration {
+	net := NewLossyNetwork(0, 0, 0)
+	net.SetDefaultLink(benchLink)
+	eps := make([]*Endpoint, followers+1)
+	for i := range eps {
+		eps[i] = NewEndpointWithConfig(i, net, config)
+	}
+	defer closeEndpoints(eps)
+	for _, f := range eps[1:] {
+		go func(f *Endpoint) {
+			for {
+				d, ok := f.Receive()
+				if !ok {
+					return
+				}
+				f.Send(d.From, matchIndex(d.Payload.(logEntry).Index))
+			}
+		}(f)
+	}
+	//
+	start := time.Now()
+	leader := eps[0]
+	command := make([]byte, 64)
+	for i := 1; i <= entries; i++ {
+		for id := 1; id <= followers; id++ {
+			leader.Send(id, logEntry{Index: i, Command: command})
+		}
+	}
+	match := make(map[int]int, followers)
+	for commit := 0; commit < entries; {
+		d, _ := leader.Receive()
+		match[d.From] = int(d.Payload.(matchIndex))
+		// leader 自己也算一票
+		for idx := commit + 1; idx <= entries; idx++ {
+			count := 1
+			for _, m := range match {
+				if m >= idx {
+					count++
+				}
+			}
+			if 2*count <= followers+1 {
+				break
+			}
+			commit = idx
+		}
+	}
+	return time.Since(start)
+}
+
+// write 是 quorum 写入的请求
+type write struct {
+	Client, Seq int
+	Key, Value  string
+}
+
+func (w write) Size() int { return 16 + len(w.Key) + len(w.Value) }
+
+// written 是 replica 对 write 的回复
+type written struct {
+	Client, Seq int
+}
+
+func (written) Size() int { return 16 }
+
+// quorumWrites 模拟 N=3，W=2 的 quorum 写入：clients 个 client 通过 coordinator 0，
+// 一共写入 writes 次，每个 client 都要等上一次写入得到 W 个 replica 的确认，才开始下一次写入
+// 返回完成所有写入需要的时间
+func quorumWrites(config Config, clients, writes int) time.Duration {
+	const n, w = 3, 2
+	net := NewLossyNetwork(0, 0, 0)
+	net.SetDefaultLink(benchLink)
+	eps := make([]*Endpoint, n+1)
+	for i := range eps {
+		eps[i] = NewEndpointWithConfig(i, net, config)
+	}
+	defer closeEndpoints(eps)
+	for _, r := range eps[1:] {
+		go func(r *Endpoint) {
+			for {
+				d, ok := r.Receive()
+				if !ok {
+					return
+				}
+				req := d.Payload.(write)
+				r.Send(d.From, written{Client: req.Client, Seq: req.Seq})
+			}
+		}(r)
+	}
+	//
+	coordinator := eps[0]
+	done := make([]chan struct{}, clients)
+	for i := range done {
+		done[i] = make(chan struct{}, 1)
+	}
+	go func() {
+		acks := make(map[written]int, writes)
+		for {
+			d, ok := coordinator.Receive()
+			if !ok {
+				return
+			}
+			ack := d.Payload.(written)
+			acks[ack]++
+			if acks[ack] == w {
+				done[ack.Client] <- struct{}{}
+			}
+		}
+	}()
+	//
+	start := time.Now()
+	var wg sync.WaitGroup
+	wg.Add(clients)
+	for c := 0; c < clients; c++ {
+		go func(c int) {
+			defer wg.Done()
+			for seq := 0; seq*clients+c < writes; seq++ {
+				req := write{Client: c, Seq: seq, Key: fmt.Sprintf("key%d", c), Value: "value"}
+				for id := 1; id <= n; id++ {
+					coordinator.Send(id, req)
+				}
+				<-done[c]
+			}
+		}(c)
+	}
+	wg.Wait()
+	return time.Since(start)
+}
+
+func Test_Endpoint_pipeliningAndBatchingImproveThroughput(t *testing.T) {
+	ast := assert.New(t)
+	//
+	stopAndWait, batched := benchConfigs[0].config, benchConfigs[2].config
+	slow, fast := replicateLog(stopAndWait, 4, 200), replicateLog(batched, 4, 200)
+	t.Logf("Raft log 复制：stop-and-wait %v，pipelining + batching %v", slow, fast)
+	ast.True(3*fast < slow)
+	//
+	slow, fast = quorumWrites(stopAndWait, 16, 400), quorumWrites(batched, 16, 400)
+	t.Logf("quorum 写入：stop-and-wait %v，pipelining + batching %v", slow, fast)
+	ast.True(3*fast < slow)
+}
+
+func Benchmark_RaftReplication(b *testing.B) {
+	for _, bc := range benchConfigs {
+		b.Run(bc.name, func(b *testing.B) {
+			elapsed := replicateLog(bc.config, 4, b.N)
+			b.ReportMetric(float64(b.N)/elapsed.Seconds(), "entries/s")
+		})
+	}
+}
+
+func Benchmark_QuorumWrites(b *testing.B) {
+	for _, bc := range benchConfigs {
+		b.Run(bc.name, func(b *testing.B) {
+			elapsed := quorumWrites(bc.config, 64, b.N)
+			b.ReportMetric(float64(b.N)/elapsed.Seconds(), "writes/s")
+		})
+	}
+}
@@ -0,0 +1,306 @@
+package reliablechannel
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+)
+
+// Delivery 是 Endpoint 按序交付给上层的消息
+type Delivery struct {
+	From    int
+	Payload interface{}
+}
+
+// pending 是已经发送，但还没有收到确认的 frame
+type pending struct {
+	f        *frame
+	lastSent time.Time
+}
+
+// queued 是还在排队，等待被发送的消息
+type queued struct {
+	payload interface{}
+	at      time.Time // 进入队列的时间
+}
+
+// Config 是 Endpoint 的设置，不同的算法可以按照各自的消息模式选择不同的设置
+type Config struct {
+	// RTO 是重传超时，超过 RTO 没有被确认的 frame 会被重传
+	RTO time.Duration
+	// Window 是发往每个 peer 的 frame 中，最多可以有多少个还没有被确认
+	// 窗口满了以后，新的消息要排队等待。0 表示不限制，1 就是 stop-and-wait
+	Window int
+	// MaxBatch 是一个 frame 最多可以合并多少条消息，0 和 1 都表示不合并
+	MaxBatch int
+	// BatchDelay 是消息为了凑满 MaxBatch，最多可以等待的时间
+	// 为 0 时不会等待，只有窗口满了以后积压的消息才会被合并
+	BatchDelay time.Duration
+}
+
+// Endpoint 在 LossyNetwork 之上提供可靠的 FIFO 通道
+// 对于任意一对 endpoint，先发送的消息一定先交付，且每条消息只交付一次
+//
+// 实现方式：
+// 1. 发送方给发往每个 peer 的 frame 编上连续的序号
+// 2. 接收方只按序交付，提前到达的 frame 先缓存，已交付过的 frame 直接丢弃
+// 3. 接收方每收到一个 data frame，都回复当前连续收到的最大序号，即累计确认
+// 4. 发送方超过 rto 还没有收到确认的 frame，会被重新发送
+//
+// Config 还可以开启 pipelining 和 batching，参见 batch.go
+type Endpoint struct {
+	me     int
+	net    *LossyNetwork
+	config Config
+
+	mutex      sync.Mutex
+	nextSeq    map[int]int                 // 发往各个 peer 的下一个序号，序号从 1 开始
+	unacked    map[int]map[int]*pending    // 发往各个 peer 还未被确认的 frame
+	queue      map[int][]queued            // 发往各个 peer 还在排队的消息
+	timers     map[int]sim.Timer           // 各个 peer 的队列凑 batch 的定时器
+	expected   map[int]int                 // 期待从各个 peer 收到的下一个序号
+	outOfOrder map[int]map[int]interface{} // 从各个 peer 提前收到的消息
+
+	inbox  []Delivery // 已经可以交付给上层的消息
+	cond   *sync.Cond
+	closed bool
+	done   chan struct{}
+
+	retransmitted int // 重传 frame 的次数
+	duplicates    int // 丢弃的重复 frame 的数量
+}
+
+// NewEndpoint 在 net 上创建 ID 为 me 的 endpoint
+// 超过 rto 没有被确认的消息会被重传
+func NewEndpoint(me int, net *LossyNetwork, rto time.Duration) *Endpoint {
+	return NewEndpointWithConfig(me, net, Config{RTO: rto})
+}
+
+// NewEndpointWithConfig 与 NewEndpoint 一样，但是按照 config 设置窗口和 batch
+func NewEndpointWithConfig(me int, net *LossyNetwork, config Config) *Endpoint {
+	e := &Endpoint{
+		me:         me,
+		net:        net,
+		config:     config,
+		nextSeq:    make(map[int]int, 16),
+		unacked:    make(map[int]map[int]*pending, 16),
+		queue:      make(map[int][]queued, 16),
+		timers:     make(map[int]sim.Timer, 16),
+		expected:   make(map[int]int, 16),
+		outOfOrder: make(map[int]map[int]interface{}, 16),
+		done:       make(chan struct{}),
+	}
+	e.cond = sync.NewCond(&e.mutex)
+
+	net.register(me, e.handle)
+
+	go e.retransmitLoop()
+
+	return e
+}
+
+// Env 返回 e 所在网络的 env，运行在 e 之上的算法应该从它读取时间
+func (e *Endpoint) Env() sim.Env {
+	return e.net.env
+}
+
+// Send 把 payload 可靠地发送给 to
+func (e *Endpoint) Send(to int, payload interface{}) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.closed {
+		return
+	}
+
+	now := e.net.env.Now()
+	e.queue[to] = append(e.queue[to], queued{payload: payload, at: now})
+	e.flush(to, now)
+}
+
+// Receive 阻塞到有消息可以交付为止
+// Endpoint 被 Close 后，返回 false
+func (e *Endpoint) Receive() (Delivery, bool) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	for len(e.inbox) == 0 && !e.closed {
+		e.cond.Wait()
+	}
+
+	if len(e.inbox) == 0 {
+		return Delivery{}, false
+	}
+
+	d := e.inbox[0]
+	e.inbox[0] = Delivery{} // 让 GC 可以回收 payload
+	e.inbox = e.inbox[1:]
+	return d, true
+}
+
+// Unacked 返回还没有被确认的消息数量，包括还在排队的消息
+func (e *Endpoint) Unacked() int {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	res := 0
+	for _, ps := range e.unacked {
+		for _, p := range ps {
+			res += messages(p.f.payload)
+		}
+	}
+	for _, q := range e.queue {
+		res += len(q)
+	}
+	return res
+}
+
+// Retransmitted 返回重传的次数
+func (e *Endpoint) Retransmitted() int {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.retransmitted
+}
+
+// Duplicates 返回被丢弃的重复 frame 的数量
+func (e *Endpoint) Duplicates() int {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.duplicates
+}
+
+// Close 关闭 endpoint，阻塞在 Receive 上的调用会返回
+func (e *Endpoint) Close() {
+	e.mutex.Lock()
+	if e.closed {
+		e.mutex.Unlock()
+		return
+	}
+	e.closed = true
+	close(e.done)
+	for _, t := range e.timers {
+		t.Stop()
+	}
+	e.cond.Broadcast()
+	e.mutex.Unlock()
+
+	e.net.unregister(e.me)
+}
+
+// 利用调用方的锁进行锁定
+func (e *Endpoint) nextSeqOf(to int) int {
+	if seq, ok := e.nextSeq[to]; ok {
+		return seq
+	}
+	return 1
+}
+
+// 利用调用方的锁进行锁定
+func (e *Endpoint) expectedOf(from int) int {
+	if seq, ok := e.expected[from]; ok {
+		return seq
+	}
+	return 1
+}
+
+func (e *Endpoint) handle(f *frame) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.closed {
+		return
+	}
+
+	switch f.kind {
+	case ackFrame:
+		e.handleAck(f)
+	case dataFrame:
+		e.handleData(f)
+	}
+}
+
+// 利用 handle 的锁进行锁定
+func (e *Endpoint) handleAck(f *frame) {
+	// 累计确认：序号不大于 f.seq 的 frame 都已经被对方收到了
+	for seq := range e.unacked[f.from] {
+		if seq <= f.seq {
+			delete(e.unacked[f.from], seq)
+		}
+	}
+	// 窗口中空出了位置，排队的消息可以发送了
+	e.flush(f.from, e.net.env.Now())
+}
+
+// 利用 handle 的锁进行锁定
+func (e *Endpoint) handleData(f *frame) {
+	from := f.from
+	exp := e.expectedOf(from)
+
+	switch {
+	case f.seq < exp:
+		// 已经交付过了，对方可能没有收到 ack 才重传的
+		e.duplicates++
+	case f.seq > exp:
+		// 提前到达，先缓存起来
+		if e.outOfOrder[from] == nil {
+			e.outOfOrder[from] = make(map[int]interface{}, 16)
+		}
+		if _, ok := e.outOfOrder[from][f.seq]; ok {
+			e.duplicates++
+		} else {
+			e.outOfOrder[from][f.seq] = f.payload
+		}
+	default:
+		e.deliver(from, f.payload)
+		exp++
+		// 把缓存中连续的部分也一起交付
+		for {
+			payload, ok := e.outOfOrder[from][exp]
+			if !ok {
+				break
+			}
+			delete(e.outOfOrder[from], exp)
+			e.deliver(from, payload)
+			exp++
+		}
+		e.expected[from] = exp
+		e.cond.Broadcast()
+	}
+
+	// 无论哪种情况，都回复累计确认
+	e.net.send(newAckFrame(e.me, from, exp-1))
+}
+
+func (e *Endpoint) retransmitLoop() {
+	interval := e.config.RTO / 2
+	if interval <= 0 {
+		interval = time.Millisecond
+	}
+	ticker := e.net.env.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.done:
+			return
+		case now := <-ticker.C():
+			e.retransmit(now)
+		}
+	}
+}
+
+func (e *Endpoint) retransmit(now time.Time) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	for _, ps := range e.unacked {
+		for _, p := range ps {
+			if now.Sub(p.lastSent) < e.config.RTO {
+				continue
+			}
+			p.lastSent = now
+			e.retransmitted++
+			e.net.send(p.f)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package reliablechannel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func makeEndpoints(net *LossyNetwork, size int) []*Endpoint {
+	res := make([]*Endpoint, size)
+	for i := range res {
+		res[i] = NewEndpoint(i, net, 5*time.Millisecond)
+	}
+	return res
+}
+
+func closeEndpoints(eps []*Endpoint) {
+	for _, e := range eps {
+		e.Close()
+	}
+}
+
+func Test_Endpoint_fifoOverReliableNetwork(t *testing.T) {
+	ast := assert.New(t)
+	//
+	net := NewLossyNetwork(0, 0, 0)
+	eps := makeEndpoints(net, 2)
+	defer closeEndpoints(eps)
+	//
+	size := 100
+	for i := 0; i < size; i++ {
+		eps[0].Send(1, i)
+	}
+	//
+	for i := 0; i < size; i++ {
+		d, ok := eps[1].Receive()
+		ast.True(ok)
+		ast.Equal(0, d.From)
+		ast.Equal(i, d.Payload)
+	}
+}
+
+func Test_Endpoint_fifoOverLossyNetwork(t *testing.T) {
+	ast := assert.New(t)
+	//
+	net := NewLossyNetwork(0.3, 0.3, 2*time.Millisecond)
+	eps := makeEndpoints(net, 3)
+	defer closeEndpoints(eps)
+	//
+	size := 200
+	for i := 0; i < size; i++ {
+		eps[0].Send(2, i)
+		eps[1].Send(2, -i)
+	}
+	// 每个发送方的消息都是按序、不重复地交付的
+	next := map[int]int{0: 0, 1: 0}
+	for i := 0; i < size*2; i++ {
+		d, ok := eps[2].Receive()
+		ast.True(ok)
+		expected := next[d.From]
+		if d.From == 1 {
+			expected = -expected
+		}
+		ast.Equal(expected, d.Payload)
+		next[d.From]++
+	}
+	ast.Equal(size, next[0])
+	ast.Equal(size, next[1])
+	// 丢包一定会导致重传
+	ast.True(eps[0].Retransmitted() > 0)
+	// 重复的 frame 都被丢弃了
+	ast.True(eps[2].Duplicates() > 0)
+}
+
+func Test_Endpoint_unackedDrainsToZero(t *testing.T) {
+	ast := assert.New(t)
+	//
+	net := NewLossyNetwork(0.2, 0, time.Millisecond)
+	eps := makeEndpoints(net, 2)
+	defer closeEndpoints(eps)
+	//
+	size := 50
+	for i := 0; i < size; i++ {
+		eps[0].Send(1, i)
+	}
+	for i := 0; i < size; i++ {
+		eps[1].Receive()
+	}
+	//
+	deadline := time.Now().Add(2 * time.Second)
+	for eps[0].Unacked() > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	ast.Equal(0, eps[0].Unacked())
+}
+
+func Test_Endpoint_Close(t *testing.T) {
+	ast := assert.New(t)
+	//
+	net := NewLossyNetwork(0, 0, 0)
+	e := NewEndpoint(0, net, time.Millisecond)
+	//
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		e.Close()
+	}()
+	//
+	_, ok := e.Receive()
+	ast.False(ok)
+	// 重复 Close 不会 panic
+	ast.NotPanics(e.Close)
+}
@@ -0,0 +1,52 @@
+package reliablechannel
+
+import "fmt"
+
+// frame 是在 LossyNetwork 中传输的单位
+type frame struct {
+	kind    frameKind
+	from    int // frame 发送方的 ID
+	to      int // frame 接收方的 ID
+	seq     int // data frame 的序号，或者 ack frame 累计确认的序号
+	payload interface{}
+}
+
+func newDataFrame(from, to, seq int, payload interface{}) *frame {
+	return &frame{
+		kind:    dataFrame,
+		from:    from,
+		to:      to,
+		seq:     seq,
+		payload: payload,
+	}
+}
+
+func newAckFrame(from, to, seq int) *frame {
+	return &frame{
+		kind: ackFrame,
+		from: from,
+		to:   to,
+		seq:  seq,
+	}
+}
+
+func (f *frame) String() string {
+	return fmt.Sprintf("{%s, From:%d, To:%d, Seq:%d}", f.kind, f.from, f.to, f.seq)
+}
+
+type frameKind int
+
+// 枚举了 frame 的所有类型
+const (
+	dataFrame frameKind = iota
+	ackFrame
+)
+
+func (k frameKind) String() string {
+	switch k {
+	case dataFrame:
+		return "数据"
+	default:
+		return "确认"
+	}
+}
@@ -0,0 +1,125 @@
+package reliablechannel
+
+import (
+	"math"
+	"time"
+
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+)
+
+// Latency 是单程延迟的分布
+type Latency interface {
+	// Sample 用 rnd 抽取一次延迟，*rand.Rand 和 sim.Env 都可以作为 rnd
+	Sample(rnd sim.Rand) time.Duration
+}
+
+// Fixed 是固定的延迟
+type Fixed time.Duration
+
+// Sample 实现了 Latency 接口
+func (d Fixed) Sample(sim.Rand) time.Duration {
+	return time.Duration(d)
+}
+
+// Uniform 是在 [Min, Max) 中均匀分布的延迟
+type Uniform struct {
+	Min, Max time.Duration
+}
+
+// Sample 实现了 Latency 接口
+func (u Uniform) Sample(rnd sim.Rand) time.Duration {
+	if u.Max <= u.Min {
+		return u.Min
+	}
+	return u.Min + time.Duration(rnd.Int63n(int64(u.Max-u.Min)))
+}
+
+// LogNormal 是对数正态分布的延迟，大部分延迟集中在 Median 附近，偶尔会出现很长的尾巴
+// 真实网络的延迟通常更接近这种分布。Sigma 越大，尾巴越长。
+type LogNormal struct {
+	Median time.Duration
+	Sigma  float64
+}
+
+// Sample 实现了 Latency 接口
+func (l LogNormal) Sample(rnd sim.Rand) time.Duration {
+	return time.Duration(float64(l.Median) * math.Exp(l.Sigma*rnd.NormFloat64()))
+}
+
+// Link 描述了从一个 endpoint 到另一个 endpoint 的单向链路
+type Link struct {
+	// Latency 为 nil 时，没有传播延迟
+	Latency Latency
+	// Bandwidth 是每秒可以发送的字节数，0 表示带宽没有限制
+	// 带宽有限时，frame 要排队等前面的 frame 发送完，才能开始发送
+	Bandwidth int
+}
+
+// frameHeaderSize 是 frame 除去 payload 以外的大小
+const frameHeaderSize = 16
+
+// sizer 是知道自己大小的 payload
+type sizer interface {
+	Size() int
+}
+
+// frameSize 返回 f 在链路上占用的字节数
+// []byte、string 和实现了 Size() int 的 payload 才计算大小，其他的 payload 只计算 frame 头
+func frameSize(f *frame) int {
+	return frameHeaderSize + payloadSize(f.payload)
+}
+
+// payloadSize 返回 payload 的大小，计算方法与 frameSize 相同
+func payloadSize(payload interface{}) int {
+	switch p := payload.(type) {
+	case []byte:
+		return len(p)
+	case string:
+		return len(p)
+	case sizer:
+		return p.Size()
+	}
+	return 0
+}
+
+type linkKey struct {
+	from, to int
+}
+
+// SetLink 设置从 from 到 to 的链路
+func (n *LossyNetwork) SetLink(from, to int, l Link) {
+	n.mutex.Lock()
+	n.links[linkKey{from, to}] = l
+	n.mutex.Unlock()
+}
+
+// SetDefaultLink 设置没有用 SetLink 单独设置过的链路
+func (n *LossyNetwork) SetDefaultLink(l Link) {
+	n.mutex.Lock()
+	n.defaultLink = l
+	n.mutex.Unlock()
+}
+
+// 利用 send 的锁进行锁定
+// transit 返回 f 从现在开始，到达接收方需要的时间，包括排队、发送和传播的时间
+func (n *LossyNetwork) transit(f *frame, now time.Time) time.Duration {
+	key := linkKey{f.from, f.to}
+	l, ok := n.links[key]
+	if !ok {
+		l = n.defaultLink
+	}
+	var res time.Duration
+	if l.Bandwidth > 0 {
+		start := now
+		if busy := n.busyUntil[key]; busy.After(start) {
+			start = busy
+		}
+		sent := start.Add(time.Duration(int64(frameSize(f)) * int64(time.Second) / int64(l.Bandwidth)))
+		n.busyUntil[key] = sent
+		res = sent.Sub(now)
+	}
+	if l.Latency != nil {
+		res += l.Latency.Sample(n.env)
+	}
+	return res
+}
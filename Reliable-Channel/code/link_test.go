@@ -0,0 +1,124 @@
+package reliablechannel
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Latency_Sample(t *testing.T) {
+	ast := assert.New(t)
+	//
+	rnd := rand.New(rand.NewSource(0))
+	ast.Equal(time.Millisecond, Fixed(time.Millisecond).Sample(rnd))
+	ast.Equal(time.Second, Uniform{Min: time.Second, Max: time.Second}.Sample(rnd))
+	below := 0
+	for i := 0; i < 1000; i++ {
+		d := Uniform{Min: time.Millisecond, Max: 2 * time.Millisecond}.Sample(rnd)
+		ast.True(time.Millisecond <= d && d < 2*time.Millisecond)
+		d = LogNormal{Median: 10 * time.Millisecond, Sigma: 0.5}.Sample(rnd)
+		ast.True(d > 0)
+		if d < 10*time.Millisecond {
+			below++
+		}
+	}
+	// 一半的延迟小于中位数
+	ast.InDelta(500, below, 50)
+	ast.Equal(10*time.Millisecond, LogNormal{Median: 10 * time.Millisecond}.Sample(rnd))
+}
+
+func Test_frameSize(t *testing.T) {
+	ast := assert.New(t)
+	//
+	ast.Equal(frameHeaderSize, frameSize(newAckFrame(0, 1, 1)))
+	ast.Equal(frameHeaderSize+3, frameSize(newDataFrame(0, 1, 1, []byte("abc"))))
+	ast.Equal(frameHeaderSize+2, frameSize(newDataFrame(0, 1, 1, "ab")))
+	ast.Equal(frameHeaderSize, frameSize(newDataFrame(0, 1, 1, 42)))
+}
+
+// recordArrivals 让 net 上的 endpoint id 记录 frame 到达的时间
+func recordArrivals(net *LossyNetwork, id int, wg *sync.WaitGroup) func() []time.Duration {
+	var mutex sync.Mutex
+	var arrivals []time.Duration
+	start := time.Now()
+	net.register(id, func(*frame) {
+		mutex.Lock()
+		arrivals = append(arrivals, time.Since(start))
+		mutex.Unlock()
+		wg.Done()
+	})
+	return func() []time.Duration {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return arrivals
+	}
+}
+
+func Test_LossyNetwork_perLinkLatency(t *testing.T) {
+	ast := assert.New(t)
+	//
+	net := NewLossyNetwork(0, 0, 0)
+	net.SetLink(0, 1, Link{Latency: Fixed(50 * time.Millisecond)})
+	var wg sync.WaitGroup
+	wg.Add(2)
+	slow := recordArrivals(net, 1, &wg)
+	fast := recordArrivals(net, 2, &wg)
+	//
+	net.send(newDataFrame(0, 1, 1, nil))
+	net.send(newDataFrame(0, 2, 1, nil))
+	wg.Wait()
+	//
+	ast.True(slow()[0] >= 50*time.Millisecond)
+	ast.True(fast()[0] < 50*time.Millisecond)
+}
+
+func Test_LossyNetwork_bandwidth(t *testing.T) {
+	ast := assert.New(t)
+	//
+	net := NewLossyNetwork(0, 0, 0)
+	// 每个 frame 需要 10ms 才能发送完
+	payload := make([]byte, 1000-frameHeaderSize)
+	net.SetDefaultLink(Link{Bandwidth: 100000})
+	var wg sync.WaitGroup
+	size := 5
+	wg.Add(size)
+	arrivals := recordArrivals(net, 1, &wg)
+	//
+	for i := 1; i <= size; i++ {
+		net.send(newDataFrame(0, 1, i, payload))
+	}
+	wg.Wait()
+	//
+	res := arrivals()
+	ast.Len(res, size)
+	for i, d := range res {
+		ast.True(d >= time.Duration(i+1)*10*time.Millisecond, "frame 要排队发送")
+	}
+}
+
+func Test_Endpoint_overSlowLinks(t *testing.T) {
+	ast := assert.New(t)
+	//
+	net := NewLossyNetwork(0.1, 0, 0)
+	net.SetDefaultLink(Link{
+		Latency:   LogNormal{Median: 2 * time.Millisecond, Sigma: 1},
+		Bandwidth: 1 << 20,
+	})
+	a := NewEndpoint(0, net, 20*time.Millisecond)
+	b := NewEndpoint(1, net, 20*time.Millisecond)
+	defer a.Close()
+	defer b.Close()
+	//
+	size := 50
+	for i := 0; i < size; i++ {
+		a.Send(1, i)
+	}
+	for i := 0; i < size; i++ {
+		d, ok := b.Receive()
+		ast.True(ok)
+		ast.Equal(i, d.Payload)
+	}
+}
@@ -0,0 +1,109 @@
+package reliablechannel
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+)
+
+// LossyNetwork 模拟了一个不可靠的网络
+// 经过它发送的 frame 可能会丢失、重复，也可能乱序到达
+// 每条链路的延迟分布和带宽可以用 SetLink 单独设置
+type LossyNetwork struct {
+	lossRate float64 // frame 被丢弃的概率
+	dupRate  float64 // frame 被重复投递的概率
+
+	mutex       sync.Mutex
+	handlers    map[int]func(*frame) // 各个 endpoint 接收 frame 的函数
+	stats       Stats
+	env         sim.Env
+	defaultLink Link
+	links       map[linkKey]Link
+	busyUntil   map[linkKey]time.Time // 各条链路发送完已有 frame 的时间
+}
+
+// Stats 记录了网络中 frame 的统计数据
+type Stats struct {
+	Sent       int // 交给网络发送的 frame 数量
+	Dropped    int // 被网络丢弃的 frame 数量
+	Duplicated int // 被网络重复投递的 frame 数量
+}
+
+// NewLossyNetwork 返回一个丢包率为 lossRate，重复率为 dupRate，最大延迟为 maxDelay 的网络
+// 所有链路的延迟都在 [0, maxDelay) 中均匀分布，带宽没有限制
+func NewLossyNetwork(lossRate, dupRate float64, maxDelay time.Duration) *LossyNetwork {
+	return NewLossyNetworkWithEnv(lossRate, dupRate, maxDelay, sim.Env{})
+}
+
+// NewLossyNetworkWithEnv 与 NewLossyNetwork 一样，但是丢包、重复和延迟都由 env 决定
+// 网络上的 Endpoint 也使用 env 的时间设置重传和 batch 的定时器
+func NewLossyNetworkWithEnv(lossRate, dupRate float64, maxDelay time.Duration, env sim.Env) *LossyNetwork {
+	return &LossyNetwork{
+		lossRate:    lossRate,
+		dupRate:     dupRate,
+		handlers:    make(map[int]func(*frame), 16),
+		env:         env,
+		defaultLink: Link{Latency: Uniform{Max: maxDelay}},
+		links:       make(map[linkKey]Link, 16),
+		busyUntil:   make(map[linkKey]time.Time, 16),
+	}
+}
+
+func (n *LossyNetwork) register(id int, handler func(*frame)) {
+	n.mutex.Lock()
+	n.handlers[id] = handler
+	n.mutex.Unlock()
+}
+
+func (n *LossyNetwork) unregister(id int) {
+	n.mutex.Lock()
+	delete(n.handlers, id)
+	n.mutex.Unlock()
+}
+
+// send 把 f 交给网络，网络会按照设定的概率丢弃、重复，并按照链路的设置延迟 f
+func (n *LossyNetwork) send(f *frame) {
+	n.mutex.Lock()
+	n.stats.Sent++
+	if n.env.Float64() < n.lossRate {
+		n.stats.Dropped++
+		n.mutex.Unlock()
+		return
+	}
+	copies := 1
+	if n.env.Float64() < n.dupRate {
+		n.stats.Duplicated++
+		copies++
+	}
+	now := n.env.Now()
+	delays := make([]time.Duration, copies)
+	for i := range delays {
+		delays[i] = n.transit(f, now)
+	}
+	n.mutex.Unlock()
+
+	for _, d := range delays {
+		// 每个副本都复制一份，避免接收方修改到发送方的 frame
+		fc := *f
+		n.env.AfterFunc(d, func() { n.deliver(&fc) })
+	}
+}
+
+func (n *LossyNetwork) deliver(f *frame) {
+	n.mutex.Lock()
+	handler, ok := n.handlers[f.to]
+	n.mutex.Unlock()
+	if !ok {
+		// 接收方已经离开了网络，相当于丢包
+		return
+	}
+	handler(f)
+}
+
+// Stats 返回网络的统计数据
+func (n *LossyNetwork) Stats() Stats {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return n.stats
+}
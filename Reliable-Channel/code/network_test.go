@@ -0,0 +1,89 @@
+package reliablechannel
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_LossyNetwork_dropAll(t *testing.T) {
+	ast := assert.New(t)
+	//
+	net := NewLossyNetwork(1, 0, 0)
+	net.register(1, func(*frame) {
+		ast.Fail("丢包率为 1 的网络不应该投递 frame")
+	})
+	//
+	size := 10
+	for i := 0; i < size; i++ {
+		net.send(newDataFrame(0, 1, i, nil))
+	}
+	time.Sleep(10 * time.Millisecond)
+	//
+	stats := net.Stats()
+	ast.Equal(size, stats.Sent)
+	ast.Equal(size, stats.Dropped)
+}
+
+func Test_LossyNetwork_duplicateAll(t *testing.T) {
+	ast := assert.New(t)
+	//
+	net := NewLossyNetwork(0, 1, 0)
+	var wg sync.WaitGroup
+	size := 10
+	wg.Add(size * 2)
+	net.register(1, func(*frame) { wg.Done() })
+	//
+	for i := 0; i < size; i++ {
+		net.send(newDataFrame(0, 1, i, nil))
+	}
+	wg.Wait()
+	//
+	ast.Equal(size, net.Stats().Duplicated)
+}
+
+func Test_LossyNetwork_deliverToUnknown(t *testing.T) {
+	ast := assert.New(t)
+	net := NewLossyNetwork(0, 0, 0)
+	ast.NotPanics(func() { net.deliver(newAckFrame(0, 1, 0)) })
+}
+
+func Test_LossyNetwork_deterministicEnv(t *testing.T) {
+	ast := assert.New(t)
+	//
+	type arrival struct {
+		seq int
+		at  time.Duration
+	}
+	run := func(seed int64) ([]arrival, Stats) {
+		start := time.Unix(0, 0)
+		clock := sim.NewVirtual(start)
+		net := NewLossyNetworkWithEnv(0.3, 0.2, 10*time.Millisecond, sim.Deterministic(clock, seed))
+		var res []arrival
+		net.register(1, func(f *frame) {
+			res = append(res, arrival{seq: f.seq, at: clock.Since(start)})
+		})
+		for i := 0; i < 100; i++ {
+			net.send(newDataFrame(0, 1, i, nil))
+		}
+		clock.Advance(time.Second)
+		return res, net.Stats()
+	}
+	//
+	first, stats := run(1)
+	ast.True(stats.Dropped > 0 && stats.Duplicated > 0)
+	ast.Equal(stats.Sent-stats.Dropped+stats.Duplicated, len(first))
+	second, _ := run(1)
+	ast.Equal(first, second, "同样的 seed 和虚拟时钟应该得到同样的投递顺序和时间")
+	other, _ := run(2)
+	ast.NotEqual(first, other)
+}
+
+func Test_frame_String(t *testing.T) {
+	ast := assert.New(t)
+	ast.Equal("{数据, From:0, To:1, Seq:2}", newDataFrame(0, 1, 2, nil).String())
+	ast.Equal("{确认, From:1, To:0, Seq:2}", newAckFrame(1, 0, 2).String())
+}
@@ -0,0 +1,156 @@
+package recovery
+
+// Global 是一次 coordinated checkpoint 得到的一致的全局状态
+// 一致是指：如果某个 process 的状态中已经收到了一条 message，那么发送方的状态中也已经发送了它。
+// 发送了但是还没有收到的 message 记录在 InTransit 中。
+type Global struct {
+	States    [][]byte  // 每个 process 的状态
+	InTransit []Message // 记录状态时还在通道中的 message
+}
+
+// snapshot 是正在进行的 coordinated checkpoint
+type snapshot struct {
+	epoch       int
+	recorded    []bool
+	checkpoints []checkpoint
+	open        map[[2]int]bool // 正在记录的通道，收到这个通道的 marker 以后关闭
+	inTransit   []Message
+}
+
+// StartCheckpoint 由 initiator 发起一次 coordinated checkpoint
+// 采用 Chandy 和 Lamport 的 marker 算法，不需要暂停 process 的运行：
+//  1. initiator 记录自己的状态，然后在所有发出的通道上发送 marker
+//  2. process 第一次收到 marker 时，记录自己的状态，这个通道的状态为空，然后在所有发出的通道上发送 marker
+//  3. 记录了状态的 process 在其他通道上收到 marker 之前收到的 message，就是这些通道的状态
+//
+// 所有的 process 都记录了状态，所有的通道都收到了 marker 以后，checkpoint 才完成。
+// 完成以后，每个 process 的 log 中只需要保留它记录状态以后收到的 message。
+// 有 process 崩溃时，不能发起 checkpoint；进行中有 process 崩溃时，放弃这次 checkpoint。
+func (s *System) StartCheckpoint(initiator int) error {
+	if s.snapshot != nil {
+		return ErrBusy
+	}
+	for id := range s.nodes {
+		if s.Crashed(id) {
+			return ErrCrashed
+		}
+	}
+	s.epoch++
+	n := len(s.nodes)
+	s.snapshot = &snapshot{
+		epoch:       s.epoch,
+		recorded:    make([]bool, n),
+		checkpoints: make([]checkpoint, n),
+		open:        make(map[[2]int]bool, n*n),
+	}
+	s.recordState(initiator)
+	s.tryComplete()
+	return nil
+}
+
+// Checkpointing 返回 true，如果有正在进行的 coordinated checkpoint
+func (s *System) Checkpointing() bool {
+	return s.snapshot != nil
+}
+
+// Last 返回最近一次完成的 coordinated checkpoint，还没有完成过时返回 nil
+func (s *System) Last() *Global {
+	return s.last
+}
+
+func (s *System) recordState(id int) {
+	nd, sn := s.nodes[id], s.snapshot
+	sn.recorded[id] = true
+	sn.checkpoints[id] = checkpoint{
+		state:     nd.p.Checkpoint(),
+		sent:      copyCounts(nd.sent),
+		delivered: copyCounts(nd.delivered),
+	}
+	for j := range s.nodes {
+		if j == id {
+			continue
+		}
+		sn.open[[2]int{j, id}] = true
+		s.push(Message{From: id, To: j, epoch: sn.epoch})
+	}
+}
+
+// record 在 m 所在的通道正在记录时，把 m 记录为通道的状态
+func (s *System) record(m Message) {
+	if sn := s.snapshot; sn != nil && sn.open[[2]int{m.From, m.To}] {
+		sn.inTransit = append(sn.inTransit, m)
+	}
+}
+
+func (s *System) receiveMarker(m Message) {
+	sn := s.snapshot
+	if sn == nil || m.epoch != sn.epoch {
+		// 被放弃的 checkpoint 留下的 marker
+		return
+	}
+	if !sn.recorded[m.To] {
+		s.recordState(m.To)
+	}
+	delete(sn.open, [2]int{m.From, m.To})
+	s.tryComplete()
+}
+
+func (s *System) tryComplete() {
+	sn := s.snapshot
+	if len(sn.open) > 0 {
+		return
+	}
+	for _, ok := range sn.recorded {
+		if !ok {
+			return
+		}
+	}
+	g := &Global{
+		States:    make([][]byte, len(s.nodes)),
+		InTransit: sn.inTransit,
+	}
+	for id, nd := range s.nodes {
+		c := sn.checkpoints[id]
+		g.States[id] = c.state
+		nd.stable = c.clone()
+		// 删除 checkpoint 中已经包含了的 message
+		log := nd.log[:0]
+		for _, m := range nd.log {
+			if m.Seq > c.delivered[m.From] {
+				log = append(log, m)
+			}
+		}
+		nd.log = log
+	}
+	s.last, s.snapshot = g, nil
+	s.stats.Checkpoints++
+}
+
+// Rollback 让整个系统回滚到最近一次完成的 coordinated checkpoint，还没有完成过时回滚到初始状态
+// 这用于所有的 process 同时崩溃、并且丢失了 message log 的情况：
+// 每个 process 恢复 checkpoint 中的状态，通道恢复 checkpoint 中的 InTransit，
+// 之后的外部输入由外部世界重新发送。
+func (s *System) Rollback() error {
+	s.channels = make(map[[2]int][]Message, len(s.channels))
+	if s.last != nil {
+		for _, m := range s.last.InTransit {
+			s.push(m)
+		}
+	}
+	for id, nd := range s.nodes {
+		p := s.newProcess(id)
+		if err := p.Restore(nd.stable.state); err != nil {
+			return err
+		}
+		c := nd.stable.clone()
+		nd.p, nd.sent, nd.delivered, nd.log = p, c.sent, c.delivered, nil
+		for _, m := range s.inputs[id] {
+			if m.Seq > c.delivered[External] {
+				s.push(m)
+			}
+		}
+	}
+	s.snapshot = nil
+	s.stats.Rollbacks++
+	return nil
+}
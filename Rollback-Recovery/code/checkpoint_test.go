@@ -0,0 +1,118 @@
+package recovery
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Checkpoint_consistent(t *testing.T) {
+	ast := assert.New(t)
+	//
+	for seed := int64(0); seed < 20; seed++ {
+		s := NewSystem(4, newAccount)
+		rnd := rand.New(rand.NewSource(seed))
+		transfers(s, rnd, 60)
+		started := false
+		for i := 0; s.Step(rnd.Intn); i++ {
+			if i == 20+int(seed) {
+				ast.NoError(s.StartCheckpoint(rnd.Intn(4)))
+				ast.Equal(ErrBusy, s.StartCheckpoint(0))
+				started = true
+			}
+		}
+		ast.True(started)
+		ast.False(s.Checkpointing())
+		ast.Equal(1, s.Stats().Checkpoints)
+		// 余额和在途金额之和与初始的总额相同，说明记录的全局状态是一致的
+		ast.Equal(400, total(s.Last()), "seed %d", seed)
+	}
+}
+
+func Test_Checkpoint_truncatesLog(t *testing.T) {
+	ast := assert.New(t)
+	//
+	s := NewSystem(3, newAccount)
+	rnd := rand.New(rand.NewSource(3))
+	transfers(s, rnd, 20)
+	for s.Step(rnd.Intn) {
+	}
+	ast.True(s.LogSize(0) > 0)
+	ast.NoError(s.StartCheckpoint(0))
+	for s.Step(rnd.Intn) {
+	}
+	// 系统已经静止，checkpoint 包含了所有的 message
+	for id := 0; id < 3; id++ {
+		ast.Equal(0, s.LogSize(id))
+	}
+	ast.Empty(s.Last().InTransit)
+	ast.Equal(300, total(s.Last()))
+	// 恢复时只需要 checkpoint，不需要重放
+	s.Crash(1)
+	replayed, err := s.Recover(1)
+	ast.NoError(err)
+	ast.Equal(0, replayed)
+}
+
+func Test_Checkpoint_abortedByCrash(t *testing.T) {
+	ast := assert.New(t)
+	//
+	s := NewSystem(3, newAccount)
+	ast.NoError(s.StartCheckpoint(0))
+	s.Crash(2)
+	ast.False(s.Checkpointing())
+	ast.Equal(ErrCrashed, s.StartCheckpoint(0))
+	_, err := s.Recover(2)
+	ast.NoError(err)
+	// 被放弃的 checkpoint 留下的 marker 不会影响下一次 checkpoint
+	ast.NoError(s.StartCheckpoint(1))
+	rnd := rand.New(rand.NewSource(1))
+	for s.Step(rnd.Intn) {
+	}
+	ast.Equal(1, s.Stats().Checkpoints)
+}
+
+func Test_Rollback(t *testing.T) {
+	ast := assert.New(t)
+	//
+	// 回滚到 checkpoint 以后，再按照同样的方式运行到静止，最终的余额总和不变
+	for seed := int64(0); seed < 10; seed++ {
+		s := NewSystem(4, newAccount)
+		rnd := rand.New(rand.NewSource(seed))
+		transfers(s, rnd, 40)
+		for i := 0; i < 30 && s.Step(rnd.Intn); i++ {
+		}
+		ast.NoError(s.StartCheckpoint(0))
+		for s.Checkpointing() && s.Step(rnd.Intn) {
+		}
+		transfers(s, rnd, 10)
+		for i := 0; i < 20 && s.Step(rnd.Intn); i++ {
+		}
+		ast.NoError(s.Rollback())
+		for s.Step(rnd.Intn) {
+		}
+		sum := 0
+		for id := 0; id < 4; id++ {
+			sum += s.Process(id).(*account).balance
+		}
+		ast.Equal(400, sum, "seed %d", seed)
+		ast.Equal(1, s.Stats().Rollbacks)
+	}
+}
+
+func Test_Rollback_initial(t *testing.T) {
+	ast := assert.New(t)
+	//
+	// 没有 checkpoint 时，回滚到初始状态，并重新收到所有的外部输入
+	s := NewSystem(2, newAccount)
+	s.Input(0, []byte("1 40"))
+	rnd := rand.New(rand.NewSource(1))
+	for s.Step(rnd.Intn) {
+	}
+	ast.NoError(s.Rollback())
+	ast.Equal("100 0 | 100 0", states(s))
+	for s.Step(rnd.Intn) {
+	}
+	ast.Equal("60 0 | 140 1", states(s))
+}
@@ -0,0 +1,255 @@
+package recovery
+
+import (
+	"errors"
+	"sort"
+)
+
+// External 是外部输入的发送方
+// 外部输入也是 process 收到的 message，恢复时同样需要按照原来的顺序重放
+const External = -1
+
+// 可能返回的错误
+var (
+	ErrCrashed = errors.New("recovery: process has crashed")
+	ErrAlive   = errors.New("recovery: process is alive")
+	ErrEmpty   = errors.New("recovery: channel is empty")
+	ErrBusy    = errors.New("recovery: checkpoint in progress")
+)
+
+// Message 是 process 之间传递的 message
+type Message struct {
+	From, To int
+	// Seq 是从 From 发往 To 的第几条 message，从 1 开始
+	// 恢复的 process 在重放时会再次发送之前发送过的 message，接收方根据 Seq 丢弃它们
+	Seq     int
+	Payload []byte
+	epoch   int // 不为 0 时，是第 epoch 次 checkpoint 的 marker
+}
+
+// Output 是 process 处理 message 时发送的 message
+type Output struct {
+	To      int
+	Payload []byte
+}
+
+// Process 是可以 checkpoint 和恢复的 process
+// Handle 必须是确定性的：同样的状态收到同样的 message，总会得到同样的状态和 Output。
+// 这就是 piecewise deterministic 假设，只有这样，重放 message log 才能重建崩溃前的状态。
+type Process interface {
+	// Handle 处理 from 发来的 payload，返回需要发送的 message
+	Handle(from int, payload []byte) []Output
+	// Checkpoint 返回当前状态的编码
+	Checkpoint() []byte
+	// Restore 把状态恢复成 Checkpoint 返回的编码
+	Restore(state []byte) error
+}
+
+// Stats 统计了 System 的运行情况
+type Stats struct {
+	Delivered   int // 送达并处理的 message，不包括重放的
+	Logged      int // 写入 message log 的 message
+	Duplicates  int // 因为重复而被丢弃的 message
+	Replayed    int // 恢复时重放的 message
+	Checkpoints int // 完成的 coordinated checkpoint
+	Rollbacks   int // 整个系统回滚的次数
+}
+
+// checkpoint 是一个 process 的 checkpoint
+type checkpoint struct {
+	state     []byte
+	sent      map[int]int // 发往每个 process 的最后一条 message 的 Seq
+	delivered map[int]int // 从每个 process 收到的最后一条 message 的 Seq
+}
+
+func (c checkpoint) clone() checkpoint {
+	return checkpoint{state: c.state, sent: copyCounts(c.sent), delivered: copyCounts(c.delivered)}
+}
+
+func copyCounts(m map[int]int) map[int]int {
+	res := make(map[int]int, len(m))
+	for k, v := range m {
+		res[k] = v
+	}
+	return res
+}
+
+// node 保存了一个 process 的易失状态和 stable storage
+// 崩溃时，p、sent 和 delivered 都会丢失，stable 和 log 会保留下来
+type node struct {
+	p         Process
+	sent      map[int]int
+	delivered map[int]int
+	stable    checkpoint // 最近一次完成的 coordinated checkpoint 中，这个 process 的 checkpoint
+	log       []Message  // stable 以后收到的 message，处理之前就写入了 stable storage
+}
+
+// System 在可靠的 FIFO 通道上运行 n 个 process，由调用方决定 message 送达的顺序
+// 它实现了 pessimistic message logging：process 在处理 message 之前，先把它写入 stable storage。
+// 所以单个 process 崩溃以后，只需要恢复它自己的 checkpoint 并重放 log，其他 process 不需要回滚。
+// 另外，System 用 coordinated checkpoint 定期得到一致的全局状态，并删除 checkpoint 以前的 log。
+type System struct {
+	newProcess func(id int) Process
+	nodes      []*node
+	channels   map[[2]int][]Message // channels[{from, to}] 是还没有送达的 message
+	inputs     [][]Message          // 发给每个 process 的全部外部输入，外部世界会保留它们
+	last       *Global              // 最近一次完成的 coordinated checkpoint
+	snapshot   *snapshot            // 正在进行的 coordinated checkpoint
+	epoch      int
+	stats      Stats
+}
+
+// NewSystem 返回由 newProcess 生成的 n 个 process 组成的系统
+// process 的初始状态就是它的第一个 checkpoint
+func NewSystem(n int, newProcess func(id int) Process) *System {
+	s := &System{
+		newProcess: newProcess,
+		nodes:      make([]*node, n),
+		channels:   make(map[[2]int][]Message, n*n),
+		inputs:     make([][]Message, n),
+	}
+	for i := range s.nodes {
+		p := newProcess(i)
+		s.nodes[i] = &node{
+			p:         p,
+			sent:      make(map[int]int, n),
+			delivered: make(map[int]int, n),
+			stable:    checkpoint{state: p.Checkpoint(), sent: map[int]int{}, delivered: map[int]int{}},
+		}
+	}
+	return s
+}
+
+// Size 返回 process 的数量
+func (s *System) Size() int {
+	return len(s.nodes)
+}
+
+// Process 返回 id 的 Process，id 崩溃时返回 nil
+func (s *System) Process(id int) Process {
+	return s.nodes[id].p
+}
+
+// Crashed 返回 true，如果 id 已经崩溃，还没有恢复
+func (s *System) Crashed(id int) bool {
+	return s.nodes[id].p == nil
+}
+
+// Stats 返回运行情况的统计
+func (s *System) Stats() Stats {
+	return s.stats
+}
+
+// LogSize 返回 id 的 message log 的长度
+func (s *System) LogSize(id int) int {
+	return len(s.nodes[id].log)
+}
+
+// Input 把外部输入 payload 发给 to
+func (s *System) Input(to int, payload []byte) {
+	m := Message{From: External, To: to, Seq: len(s.inputs[to]) + 1, Payload: payload}
+	s.inputs[to] = append(s.inputs[to], m)
+	s.push(m)
+}
+
+func (s *System) push(m Message) {
+	key := [2]int{m.From, m.To}
+	s.channels[key] = append(s.channels[key], m)
+}
+
+// Pending 按照 {from, to} 的顺序，返回所有可以送达的通道，也就是不为空并且接收方没有崩溃的通道
+func (s *System) Pending() [][2]int {
+	res := make([][2]int, 0, len(s.channels))
+	for key, ch := range s.channels {
+		if len(ch) > 0 && !s.Crashed(key[1]) {
+			res = append(res, key)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i][0] != res[j][0] {
+			return res[i][0] < res[j][0]
+		}
+		return res[i][1] < res[j][1]
+	})
+	return res
+}
+
+// Step 送达 choose 选出的通道中的第一条 message，没有可以送达的通道时返回 false
+// choose(n) 返回 [0, n) 中的一个数，例如 rand.Intn 或者 spec.Choices.Intn
+func (s *System) Step(choose func(n int) int) bool {
+	pending := s.Pending()
+	if len(pending) == 0 {
+		return false
+	}
+	key := pending[choose(len(pending))]
+	return s.Deliver(key[0], key[1]) == nil
+}
+
+// Deliver 送达从 from 发往 to 的通道中的第一条 message
+func (s *System) Deliver(from, to int) error {
+	key := [2]int{from, to}
+	ch := s.channels[key]
+	if len(ch) == 0 {
+		return ErrEmpty
+	}
+	if s.Crashed(to) {
+		return ErrCrashed
+	}
+	m := ch[0]
+	s.channels[key] = ch[1:]
+	if m.epoch != 0 {
+		s.receiveMarker(m)
+		return nil
+	}
+	nd := s.nodes[to]
+	if m.Seq <= nd.delivered[from] {
+		s.stats.Duplicates++
+		return nil
+	}
+	// pessimistic message logging：先写入 stable storage，再处理
+	nd.log = append(nd.log, m)
+	s.stats.Logged++
+	s.stats.Delivered++
+	s.record(m)
+	s.handle(to, m)
+	return nil
+}
+
+// handle 让 id 处理 m，并发送处理时产生的 message
+func (s *System) handle(id int, m Message) {
+	nd := s.nodes[id]
+	nd.delivered[m.From] = m.Seq
+	for _, out := range nd.p.Handle(m.From, m.Payload) {
+		nd.sent[out.To]++
+		s.push(Message{From: id, To: out.To, Seq: nd.sent[out.To], Payload: out.Payload})
+	}
+}
+
+// Crash 让 id 崩溃，丢失所有的易失状态
+// 正在进行的 coordinated checkpoint 会被放弃
+func (s *System) Crash(id int) {
+	nd := s.nodes[id]
+	nd.p, nd.sent, nd.delivered = nil, nil, nil
+	s.snapshot = nil
+}
+
+// Recover 恢复崩溃的 id：先恢复 checkpoint，再按照顺序重放 log 中的 message，返回重放的数量
+// 重放时会再次发送崩溃前已经发送过的 message，接收方会根据 Seq 丢弃它们。
+// 崩溃前收到、但是没有来得及处理的 message 都还在 log 中，所以不会丢失。
+func (s *System) Recover(id int) (int, error) {
+	nd := s.nodes[id]
+	if nd.p != nil {
+		return 0, ErrAlive
+	}
+	p := s.newProcess(id)
+	if err := p.Restore(nd.stable.state); err != nil {
+		return 0, err
+	}
+	c := nd.stable.clone()
+	nd.p, nd.sent, nd.delivered = p, c.sent, c.delivered
+	for _, m := range nd.log {
+		s.handle(id, m)
+	}
+	s.stats.Replayed += len(nd.log)
+	return len(nd.log), nil
+}
@@ -0,0 +1,154 @@
+package recovery
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// account 是一个银行账户，外部输入 "to amount" 让它向 to 转账，收到的 message 是转入的金额
+// 转账不会凭空产生或者消失，所以一致的全局状态中，余额与在途金额之和总是不变的
+type account struct {
+	balance  int
+	received int // 收到的转账次数
+}
+
+func newAccount(int) Process { return &account{balance: 100} }
+
+func (a *account) Handle(from int, payload []byte) []Output {
+	if from != External {
+		amount, _ := strconv.Atoi(string(payload))
+		a.balance += amount
+		a.received++
+		return nil
+	}
+	var to, amount int
+	fmt.Sscan(string(payload), &to, &amount)
+	if amount > a.balance {
+		amount = a.balance
+	}
+	a.balance -= amount
+	return []Output{{To: to, Payload: []byte(strconv.Itoa(amount))}}
+}
+
+func (a *account) Checkpoint() []byte {
+	return []byte(fmt.Sprintf("%d %d", a.balance, a.received))
+}
+
+func (a *account) Restore(state []byte) error {
+	_, err := fmt.Sscan(string(state), &a.balance, &a.received)
+	return err
+}
+
+func (a *account) String() string {
+	return string(a.Checkpoint())
+}
+
+// transfers 发出 count 个随机的转账
+func transfers(s *System, rnd *rand.Rand, count int) {
+	for i := 0; i < count; i++ {
+		from, to := rnd.Intn(s.Size()), rnd.Intn(s.Size()-1)
+		if to >= from {
+			to++
+		}
+		s.Input(from, []byte(fmt.Sprintf("%d %d", to, 1+rnd.Intn(30))))
+	}
+}
+
+// states 返回所有 process 的状态
+func states(s *System) string {
+	res := make([]string, s.Size())
+	for i := range res {
+		res[i] = string(s.Process(i).Checkpoint())
+	}
+	return strings.Join(res, " | ")
+}
+
+func total(g *Global) int {
+	res := 0
+	for _, state := range g.States {
+		var balance int
+		fmt.Sscan(string(state), &balance)
+		res += balance
+	}
+	for _, m := range g.InTransit {
+		amount, _ := strconv.Atoi(string(m.Payload))
+		res += amount
+	}
+	return res
+}
+
+func Test_System_drain(t *testing.T) {
+	ast := assert.New(t)
+	//
+	s := NewSystem(3, newAccount)
+	s.Input(0, []byte("1 30"))
+	s.Input(0, []byte("2 500"))
+	rnd := rand.New(rand.NewSource(1))
+	for s.Step(rnd.Intn) {
+	}
+	ast.Equal("0 0 | 130 1 | 170 1", states(s))
+	ast.Equal(4, s.Stats().Delivered)
+	ast.Equal(4, s.Stats().Logged)
+	ast.Equal(ErrEmpty, s.Deliver(0, 1))
+}
+
+func Test_System_recover(t *testing.T) {
+	ast := assert.New(t)
+	//
+	for seed := int64(0); seed < 10; seed++ {
+		s := NewSystem(4, newAccount)
+		rnd := rand.New(rand.NewSource(seed))
+		transfers(s, rnd, 40)
+		for i := 0; i < 30 && s.Step(rnd.Intn); i++ {
+		}
+		before := string(s.Process(2).Checkpoint())
+		s.Crash(2)
+		ast.True(s.Crashed(2))
+		ast.Nil(s.Process(2))
+		// 其他 process 继续运行，发给 2 的 message 留在通道中
+		for i := 0; i < 20 && s.Step(rnd.Intn); i++ {
+		}
+		for _, key := range s.Pending() {
+			ast.NotEqual(2, key[1])
+		}
+		replayed, err := s.Recover(2)
+		ast.NoError(err)
+		ast.Equal(s.LogSize(2), replayed)
+		// 重放 log 以后，恢复到了崩溃前的状态
+		ast.Equal(before, string(s.Process(2).Checkpoint()), "seed %d", seed)
+		_, err = s.Recover(2)
+		ast.Equal(ErrAlive, err)
+		for s.Step(rnd.Intn) {
+		}
+		// 重放时再次发送的转账被接收方丢弃了，所以没有重复转入
+		sum, received := 0, 0
+		for id := 0; id < 4; id++ {
+			sum += s.Process(id).(*account).balance
+			received += s.Process(id).(*account).received
+		}
+		ast.Equal(400, sum, "seed %d", seed)
+		ast.Equal(40, received, "seed %d", seed)
+		ast.True(s.Stats().Replayed > 0)
+		ast.True(s.Stats().Duplicates > 0)
+	}
+}
+
+func Test_System_crashedDeliver(t *testing.T) {
+	ast := assert.New(t)
+	//
+	s := NewSystem(2, newAccount)
+	s.Input(1, []byte("0 10"))
+	s.Crash(1)
+	ast.Equal(ErrCrashed, s.Deliver(External, 1))
+	ast.Empty(s.Pending())
+	_, err := s.Recover(1)
+	ast.NoError(err)
+	ast.NoError(s.Deliver(External, 1))
+	ast.NoError(s.Deliver(1, 0))
+	ast.Equal("110 1 | 90 0", states(s))
+}
@@ -0,0 +1,139 @@
+package sequencer
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+)
+
+// Orderer 为全序广播的消息分配全局序号
+// 序号从 1 开始，没有间隔，每个序号只会分配一次。Client 是它的一个实现
+type Orderer interface {
+	Next() int64
+}
+
+// Message 是全序广播的一条消息
+type Message struct {
+	Seq  int64
+	From int
+	Body string
+}
+
+// Group 是基于 sequencer 的全序广播组
+// member 广播消息时，先从 Orderer 取得序号，再把消息直接发给所有的 member。
+// 网络会随机地延迟消息，所以 member 收到消息的顺序各不相同；
+// member 把消息放在 holdback 中，只按照序号的顺序 deliver，所以所有 member deliver 的顺序都相同。
+//
+// 序号没有间隔，member 才能知道下一条该 deliver 的是哪条消息。
+// 如果 member 取得序号以后、发出消息之前崩溃，这个序号之后的消息都无法 deliver。
+type Group struct {
+	env      sim.Env
+	maxDelay time.Duration
+	members  []*Member
+}
+
+// NewGroup 返回由 len(orderers) 个 member 组成的广播组，第 i 个 member 使用 orderers[i] 取得序号
+// 每条消息在网络中的延迟在 [0, maxDelay) 之间，由 seed 决定
+func NewGroup(orderers []Orderer, maxDelay time.Duration, seed int64) *Group {
+	return NewGroupWithEnv(orderers, maxDelay, sim.Env{Rand: sim.NewRand(seed)})
+}
+
+// NewGroupWithEnv 与 NewGroup 一样，但是消息的延迟和投递都由 env 决定
+func NewGroupWithEnv(orderers []Orderer, maxDelay time.Duration, env sim.Env) *Group {
+	g := &Group{
+		env:      env,
+		maxDelay: maxDelay,
+		members:  make([]*Member, len(orderers)),
+	}
+	for i, o := range orderers {
+		m := &Member{
+			id:       i,
+			g:        g,
+			orderer:  o,
+			next:     1,
+			holdback: make(map[int64]Message, 16),
+		}
+		m.cond = sync.NewCond(&m.mutex)
+		g.members[i] = m
+	}
+	return g
+}
+
+// Member 返回第 i 个 member
+func (g *Group) Member(i int) *Member {
+	return g.members[i]
+}
+
+// send 把 msg 发给所有的 member，包括发送者自己
+func (g *Group) send(msg Message) {
+	for _, m := range g.members {
+		var delay time.Duration
+		if g.maxDelay > 0 {
+			delay = time.Duration(g.env.Int63n(int64(g.maxDelay)))
+		}
+		m := m
+		g.env.AfterFunc(delay, func() { m.receive(msg) })
+	}
+}
+
+// Member 是广播组中的一个 member
+type Member struct {
+	id      int
+	g       *Group
+	orderer Orderer
+
+	mutex     sync.Mutex
+	cond      *sync.Cond
+	next      int64             // 下一条要 deliver 的消息的序号
+	holdback  map[int64]Message // 已经收到，但是还不能 deliver 的消息
+	delivered []Message
+}
+
+// Broadcast 向组内所有的 member 广播 body，返回消息的序号
+func (m *Member) Broadcast(body string) int64 {
+	msg := Message{Seq: m.orderer.Next(), From: m.id, Body: body}
+	m.g.send(msg)
+	return msg.Seq
+}
+
+func (m *Member) receive(msg Message) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.holdback[msg.Seq] = msg
+	for {
+		next, ok := m.holdback[m.next]
+		if !ok {
+			break
+		}
+		delete(m.holdback, m.next)
+		m.delivered = append(m.delivered, next)
+		m.next++
+	}
+	m.cond.Broadcast()
+}
+
+// Delivered 返回 m 已经按顺序 deliver 的消息
+func (m *Member) Delivered() []Message {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return append([]Message(nil), m.delivered...)
+}
+
+// Wait 阻塞到 m 已经 deliver 了 n 条消息，或者超时，返回已经 deliver 的消息
+func (m *Member) Wait(n int, timeout time.Duration) []Message {
+	timer := m.g.env.AfterFunc(timeout, func() {
+		m.mutex.Lock()
+		m.cond.Broadcast()
+		m.mutex.Unlock()
+	})
+	defer timer.Stop()
+
+	deadline := m.g.env.Now().Add(timeout)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for len(m.delivered) < n && m.g.env.Now().Before(deadline) {
+		m.cond.Wait()
+	}
+	return append([]Message(nil), m.delivered...)
+}
@@ -0,0 +1,108 @@
+package sequencer
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// counter 是进程内的 Orderer，用于不依赖 raft 的测试
+type counter struct {
+	mutex sync.Mutex
+	next  int64
+}
+
+func (c *counter) Next() int64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.next++
+	return c.next
+}
+
+// broadcastAll 让每个 member 并发地广播 times 条消息，返回所有 member deliver 的消息
+func broadcastAll(g *Group, size, times int) [][]Message {
+	var wg sync.WaitGroup
+	wg.Add(size)
+	for i := 0; i < size; i++ {
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < times; j++ {
+				g.Member(i).Broadcast(fmt.Sprintf("%d-%d", i, j))
+			}
+		}(i)
+	}
+	wg.Wait()
+	res := make([][]Message, size)
+	for i := range res {
+		res[i] = g.Member(i).Wait(size*times, 10*time.Second)
+	}
+	return res
+}
+
+// sameOrder 检查所有 member 都按照序号的顺序 deliver 了同样的消息
+func sameOrder(ast *assert.Assertions, delivered [][]Message, total int) {
+	for i, msgs := range delivered {
+		ast.Equal(total, len(msgs))
+		for j, msg := range msgs {
+			ast.Equal(int64(j+1), msg.Seq)
+		}
+		ast.Equal(delivered[0], msgs, "member %d", i)
+	}
+}
+
+func Test_Group_totalOrder(t *testing.T) {
+	ast := assert.New(t)
+	//
+	size, times := 4, 50
+	seq := &counter{}
+	orderers := make([]Orderer, size)
+	for i := range orderers {
+		orderers[i] = seq
+	}
+	g := NewGroup(orderers, 5*time.Millisecond, 1)
+	sameOrder(ast, broadcastAll(g, size, times), size*times)
+	// 每个 member 自己发出的消息，按照发送的顺序 deliver
+	next := make(map[int]int)
+	for _, msg := range g.Member(0).Delivered() {
+		ast.Equal(fmt.Sprintf("%d-%d", msg.From, next[msg.From]), msg.Body)
+		next[msg.From]++
+	}
+}
+
+func Test_Group_holdback(t *testing.T) {
+	ast := assert.New(t)
+	//
+	g := NewGroup([]Orderer{&counter{}}, 0, 1)
+	m := g.Member(0)
+	m.receive(Message{Seq: 2, Body: "b"})
+	m.receive(Message{Seq: 3, Body: "c"})
+	ast.Equal(0, len(m.Delivered()), "缺少序号 1，后面的消息都不能 deliver")
+	m.receive(Message{Seq: 1, Body: "a"})
+	ast.Equal([]Message{{Seq: 1, Body: "a"}, {Seq: 2, Body: "b"}, {Seq: 3, Body: "c"}}, m.Delivered())
+}
+
+func Test_Group_withSequencer(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := MakeCluster(3)
+	defer c.Cleanup()
+	size, times := 3, 20
+	orderers := make([]Orderer, size)
+	for i := range orderers {
+		orderers[i] = c.MakeClient()
+	}
+	g := NewGroup(orderers, 5*time.Millisecond, 1)
+	//
+	done := make(chan [][]Message)
+	go func() { done <- broadcastAll(g, size, times) }()
+	// 广播的过程中 sequencer 的 leader 被隔离
+	time.Sleep(50 * time.Millisecond)
+	if leader := c.Leader(); leader != -1 {
+		c.Disconnect(leader)
+		defer c.Connect(leader)
+	}
+	sameOrder(ast, <-done, size*times)
+}
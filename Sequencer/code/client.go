@@ -0,0 +1,49 @@
+package sequencer
+
+import (
+	"sync"
+
+	"github.com/aQuaYi/Distributed-Algorithms/Raft/code/labrpc"
+	"github.com/aQuaYi/Distributed-Algorithms/Raft/code/rsm"
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+)
+
+// Client 是 sequencer 集群的客户端
+type Client struct {
+	mutex sync.Mutex // 保证同一个 Client 的请求是串行的
+	ck    *rsm.Clerk
+}
+
+// MakeClient 返回一个通过 servers 访问集群的 Client
+func MakeClient(servers []*labrpc.ClientEnd) *Client {
+	return MakeClientWithEnv(servers, sim.Env{})
+}
+
+// MakeClientWithEnv 与 MakeClient 一样，但是 c 从 env 读取时间
+func MakeClientWithEnv(servers []*labrpc.ClientEnd, env sim.Env) *Client {
+	return &Client{ck: rsm.MakeClerk(servers, env)}
+}
+
+// Next 返回一个新的序号，实现了 Orderer 接口
+func (c *Client) Next() int64 {
+	return c.NextN(1)
+}
+
+// NextN 一次申请 n 个连续的序号，返回其中的第一个
+// 批量申请可以分摊写入 raft log 的代价
+func (c *Client) NextN(n int) int64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	args := NextArgs{ClientID: c.ck.ClientID(), Seq: c.ck.NextSeq(), Count: n}
+	var first int64
+	c.ck.Call(func(server *labrpc.ClientEnd) bool {
+		var reply NextReply
+		if server.Call("Server.Next", &args, &reply) && reply.Err == OK {
+			first = reply.First
+			return true
+		}
+		return false
+	})
+	return first
+}
@@ -0,0 +1,34 @@
+package sequencer
+
+import (
+	raft "github.com/aQuaYi/Distributed-Algorithms/Raft/code"
+	"github.com/aQuaYi/Distributed-Algorithms/Raft/code/labrpc"
+	"github.com/aQuaYi/Distributed-Algorithms/Raft/code/rsm"
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+)
+
+// Cluster 在 labrpc 模拟的网络中运行一组 sequencer Server
+type Cluster struct {
+	*rsm.Cluster
+	servers []*Server
+}
+
+// MakeCluster 启动由 n 个 Server 组成的集群
+func MakeCluster(n int) *Cluster {
+	return MakeClusterWithEnv(n, sim.Env{})
+}
+
+// MakeClusterWithEnv 与 MakeCluster 一样，但是网络、server 和 Client 都从 env 读取时间和随机数
+func MakeClusterWithEnv(n int, env sim.Env) *Cluster {
+	c := &Cluster{servers: make([]*Server, n)}
+	c.Cluster = rsm.MakeCluster(n, env, func(servers []*labrpc.ClientEnd, me int, persister *raft.Persister) rsm.Service {
+		c.servers[me] = StartServerWithEnv(servers, me, persister, env)
+		return c.servers[me]
+	})
+	return c
+}
+
+// MakeClient 返回一个可以访问所有 server 的 Client
+func (c *Cluster) MakeClient() *Client {
+	return MakeClientWithEnv(c.ClientEnds(), c.Env())
+}
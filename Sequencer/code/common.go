@@ -0,0 +1,52 @@
+package sequencer
+
+import (
+	"fmt"
+
+	"github.com/aQuaYi/Distributed-Algorithms/Raft/code/labgob"
+	"github.com/aQuaYi/Distributed-Algorithms/Raft/code/rsm"
+)
+
+func init() {
+	// Op 会作为 raft.LogEntry.Command 在 server 之间传递
+	labgob.Register(Op{})
+}
+
+// Err 是 RPC 的结果
+type Err = rsm.Err
+
+// 枚举了所有的 Err
+const (
+	OK             = rsm.OK
+	ErrWrongLeader = rsm.ErrWrongLeader
+	ErrTimeout     = rsm.ErrTimeout
+)
+
+// Op 是写入 raft log 的命令，申请 Count 个连续的序号
+type Op struct {
+	ClientID int64
+	Seq      int // 操作在 Client 中的序号，用于去重
+	Count    int
+}
+
+// Request 返回发起 op 的 Client 和 op 的序号，实现了 rsm.Command 接口
+func (op Op) Request() (int64, int) {
+	return op.ClientID, op.Seq
+}
+
+func (op Op) String() string {
+	return fmt.Sprintf("Next{C%d:%d, %d}", op.ClientID, op.Seq, op.Count)
+}
+
+// NextArgs 是 Next 的参数
+type NextArgs struct {
+	ClientID int64
+	Seq      int
+	Count    int
+}
+
+// NextReply 是 Next 的返回值，分配到的序号是 [First, First+Count)
+type NextReply struct {
+	Err   Err
+	First int64
+}
@@ -0,0 +1,69 @@
+package sequencer
+
+import (
+	raft "github.com/aQuaYi/Distributed-Algorithms/Raft/code"
+	"github.com/aQuaYi/Distributed-Algorithms/Raft/code/labrpc"
+	"github.com/aQuaYi/Distributed-Algorithms/Raft/code/rsm"
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+)
+
+// Server 是通过 raft 复制的 sequencer
+// 下一个序号只在应用 Op 时增加，所有的 server 按照 log 的顺序应用同样的 Op，
+// 所以 leader 切换以后，新 leader 会从同一个位置继续分配，序号既不会重复，也不会跳过。
+// 重试的请求会被 rsm 去重，返回第一次分配的序号。
+type Server struct {
+	rsm  *rsm.Server
+	next int64 // 下一个要分配的序号，由 rsm 的锁保护
+}
+
+// StartServer 启动一个 Server
+// servers 是所有 server 的 raft 端点，me 是自己在 servers 中的索引
+func StartServer(servers []*labrpc.ClientEnd, me int, persister *raft.Persister) *Server {
+	return StartServerWithEnv(servers, me, persister, sim.Env{})
+}
+
+// StartServerWithEnv 与 StartServer 一样，但是 s 和它的 raft 从 env 读取时间和随机数
+func StartServerWithEnv(servers []*labrpc.ClientEnd, me int, persister *raft.Persister, env sim.Env) *Server {
+	s := &Server{next: 1}
+	s.rsm = rsm.StartServer(servers, me, persister, s, env)
+	return s
+}
+
+// Raft 返回 s 所使用的 raft
+func (s *Server) Raft() *raft.Raft {
+	return s.rsm.Raft()
+}
+
+// Kill 停止 s
+func (s *Server) Kill() {
+	s.rsm.Kill()
+}
+
+// Apply 应用 log 中的 Op，返回分配的第一个序号，实现了 rsm.StateMachine 接口
+func (s *Server) Apply(command interface{}, index int) interface{} {
+	op, ok := command.(Op)
+	if !ok {
+		return nil
+	}
+	first := s.next
+	s.next += int64(op.Count)
+	return first
+}
+
+// Next 是 RPC handler，为 Client 分配 args.Count 个连续的序号
+func (s *Server) Next(args *NextArgs, reply *NextReply) {
+	first, err := s.rsm.Submit(Op{ClientID: args.ClientID, Seq: args.Seq, Count: args.Count})
+	if err != OK {
+		reply.Err = err
+		return
+	}
+	reply.Err, reply.First = OK, first.(int64)
+}
+
+// Issued 返回 s 本地已经分配出去的序号数量
+// 只用于观察，不保证读到最新的状态
+func (s *Server) Issued() int64 {
+	s.rsm.Lock()
+	defer s.rsm.Unlock()
+	return s.next - 1
+}
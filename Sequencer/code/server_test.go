@@ -0,0 +1,98 @@
+package sequencer
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// gapFree 检查 seqs 恰好是 1..len(seqs)
+func gapFree(ast *assert.Assertions, seqs []int64) {
+	sorted := append([]int64(nil), seqs...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	for i, s := range sorted {
+		if !ast.Equal(int64(i+1), s) {
+			return
+		}
+	}
+}
+
+func Test_Cluster_concurrentClients(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := MakeCluster(3)
+	defer c.Cleanup()
+	//
+	clients, times := 5, 20
+	var mutex sync.Mutex
+	var seqs []int64
+	var wg sync.WaitGroup
+	wg.Add(clients)
+	for i := 0; i < clients; i++ {
+		go func() {
+			defer wg.Done()
+			cl := c.MakeClient()
+			last := int64(0)
+			for j := 0; j < times; j++ {
+				s := cl.Next()
+				ast.True(s > last, "同一个 Client 拿到的序号递增")
+				last = s
+				mutex.Lock()
+				seqs = append(seqs, s)
+				mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	gapFree(ast, seqs)
+}
+
+func Test_Cluster_NextN(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := MakeCluster(3)
+	defer c.Cleanup()
+	a, b := c.MakeClient(), c.MakeClient()
+	//
+	ast.Equal(int64(1), a.NextN(10))
+	ast.Equal(int64(11), b.Next())
+	ast.Equal(int64(12), a.NextN(3))
+	ast.Equal(int64(15), b.Next())
+}
+
+func Test_Cluster_leaderFailure(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := MakeCluster(5)
+	defer c.Cleanup()
+	cl := c.MakeClient()
+	//
+	var seqs []int64
+	for round := 0; round < 3; round++ {
+		for i := 0; i < 10; i++ {
+			seqs = append(seqs, cl.Next())
+		}
+		// 隔离 leader，新 leader 从同一个位置继续分配
+		leader := c.Leader()
+		ast.NotEqual(-1, leader)
+		c.Disconnect(leader)
+		for i := 0; i < 10; i++ {
+			seqs = append(seqs, cl.Next())
+		}
+		c.Connect(leader)
+		time.Sleep(100 * time.Millisecond)
+	}
+	gapFree(ast, seqs)
+	//
+	// 所有 server 最终都应用了同样的 log
+	deadline := time.Now().Add(5 * time.Second)
+	for _, s := range c.servers {
+		for s.Issued() != int64(len(seqs)) && time.Now().Before(deadline) {
+			time.Sleep(20 * time.Millisecond)
+		}
+		ast.Equal(int64(len(seqs)), s.Issued())
+	}
+}
@@ -0,0 +1,52 @@
+package sim
+
+import "time"
+
+// Clock 是时间的来源
+// 算法通过 Clock 读取时间、等待和设置定时器，而不是直接调用 time 包，
+// 模拟器就可以用 Virtual 控制时间的流逝
+type Clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+	Sleep(d time.Duration)
+	After(d time.Duration) <-chan time.Time
+	AfterFunc(d time.Duration, f func()) Timer
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer 是 AfterFunc 返回的定时器
+type Timer interface {
+	// Stop 取消定时器，定时器已经触发或者已经被取消时返回 false
+	Stop() bool
+	// Reset 让定时器在 d 以后再触发，定时器还没有触发时返回 true
+	Reset(d time.Duration) bool
+}
+
+// Ticker 每隔一段时间向 C() 发送一次当前的时间
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Real 是真实的时间
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) Since(t time.Time) time.Duration        { return time.Since(t) }
+func (realClock) Sleep(d time.Duration)                  { time.Sleep(d) }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTicker struct{ t *time.Ticker }
+
+func (r realTicker) C() <-chan time.Time { return r.t.C }
+func (r realTicker) Stop()               { r.t.Stop() }
@@ -0,0 +1,74 @@
+package sim
+
+import "time"
+
+// Env 是算法运行的环境，包含了所有不确定性的来源
+// 零值使用真实的时间和 math/rand 的全局函数，与直接调用 time 和 math/rand 的行为相同
+//
+// 各个 package 在自己的结构体中保存一个 Env，通过它读取时间和随机数：
+//
+//	s.env.Now()
+//	s.env.AfterFunc(timeout, s.expire)
+//	s.env.Intn(n)
+type Env struct {
+	Clock Clock // nil 表示 Real
+	Rand  Rand  // nil 表示 Global
+}
+
+// Deterministic 返回使用 clock 和 seed 决定的 Rand 的 Env
+func Deterministic(clock Clock, seed int64) Env {
+	return Env{Clock: clock, Rand: NewRand(seed)}
+}
+
+func (e Env) clock() Clock {
+	if e.Clock == nil {
+		return Real
+	}
+	return e.Clock
+}
+
+func (e Env) rand() Rand {
+	if e.Rand == nil {
+		return Global
+	}
+	return e.Rand
+}
+
+// Now 返回当前的时间
+func (e Env) Now() time.Time { return e.clock().Now() }
+
+// Since 返回从 t 到现在经过的时间
+func (e Env) Since(t time.Time) time.Duration { return e.clock().Since(t) }
+
+// Sleep 等待 d
+func (e Env) Sleep(d time.Duration) { e.clock().Sleep(d) }
+
+// After 返回在 d 以后收到当前时间的 channel
+func (e Env) After(d time.Duration) <-chan time.Time { return e.clock().After(d) }
+
+// AfterFunc 在 d 以后执行 f
+func (e Env) AfterFunc(d time.Duration, f func()) Timer { return e.clock().AfterFunc(d, f) }
+
+// NewTicker 返回周期为 d 的 Ticker
+func (e Env) NewTicker(d time.Duration) Ticker { return e.clock().NewTicker(d) }
+
+// Int63 返回 [0, 2^63) 中的随机数
+func (e Env) Int63() int64 { return e.rand().Int63() }
+
+// Int63n 返回 [0, n) 中的随机数
+func (e Env) Int63n(n int64) int64 { return e.rand().Int63n(n) }
+
+// Intn 返回 [0, n) 中的随机数
+func (e Env) Intn(n int) int { return e.rand().Intn(n) }
+
+// Float64 返回 [0, 1) 中的随机数
+func (e Env) Float64() float64 { return e.rand().Float64() }
+
+// NormFloat64 返回标准正态分布的随机数
+func (e Env) NormFloat64() float64 { return e.rand().NormFloat64() }
+
+// Perm 返回 [0, n) 的随机排列
+func (e Env) Perm(n int) []int { return e.rand().Perm(n) }
+
+// Shuffle 随机打乱 n 个元素
+func (e Env) Shuffle(n int, swap func(i, j int)) { e.rand().Shuffle(n, swap) }
@@ -0,0 +1,43 @@
+package sim
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Env_zeroValueIsReal(t *testing.T) {
+	ast := assert.New(t)
+	//
+	var env Env
+	before := time.Now()
+	now := env.Now()
+	ast.False(now.Before(before))
+	ast.True(env.Intn(10) < 10)
+	select {
+	case <-env.After(time.Millisecond):
+	case <-time.After(time.Second):
+		t.Fatal("real clock did not fire")
+	}
+}
+
+func Test_Deterministic(t *testing.T) {
+	ast := assert.New(t)
+	//
+	run := func() []time.Duration {
+		v := NewVirtual(epoch)
+		env := Deterministic(v, 42)
+		var res []time.Duration
+		for i := 0; i < 5; i++ {
+			env.AfterFunc(time.Duration(env.Intn(1000))*time.Millisecond, func() {
+				res = append(res, env.Since(epoch))
+			})
+		}
+		v.Advance(time.Second)
+		return res
+	}
+	first := run()
+	ast.Equal(5, len(first))
+	ast.Equal(first, run())
+}
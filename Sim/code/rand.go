@@ -0,0 +1,82 @@
+package sim
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// Rand 是随机数的来源，*rand.Rand 实现了这个接口
+type Rand interface {
+	Int63() int64
+	Int63n(n int64) int64
+	Intn(n int) int
+	Float64() float64
+	NormFloat64() float64
+	Perm(n int) []int
+	Shuffle(n int, swap func(i, j int))
+}
+
+// NewRand 返回由 seed 决定的 Rand，可以被多个 goroutine 同时使用
+func NewRand(seed int64) Rand {
+	return &lockedRand{r: rand.New(rand.NewSource(seed))}
+}
+
+type lockedRand struct {
+	mutex sync.Mutex
+	r     *rand.Rand
+}
+
+func (l *lockedRand) Int63() int64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.r.Int63()
+}
+
+func (l *lockedRand) Int63n(n int64) int64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.r.Int63n(n)
+}
+
+func (l *lockedRand) Intn(n int) int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.r.Intn(n)
+}
+
+func (l *lockedRand) Float64() float64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.r.Float64()
+}
+
+func (l *lockedRand) NormFloat64() float64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.r.NormFloat64()
+}
+
+func (l *lockedRand) Perm(n int) []int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.r.Perm(n)
+}
+
+func (l *lockedRand) Shuffle(n int, swap func(i, j int)) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.r.Shuffle(n, swap)
+}
+
+// Global 是 math/rand 的全局函数，每次运行的结果都不同
+var Global Rand = globalRand{}
+
+type globalRand struct{}
+
+func (globalRand) Int63() int64                       { return rand.Int63() }
+func (globalRand) Int63n(n int64) int64               { return rand.Int63n(n) }
+func (globalRand) Intn(n int) int                     { return rand.Intn(n) }
+func (globalRand) Float64() float64                   { return rand.Float64() }
+func (globalRand) NormFloat64() float64               { return rand.NormFloat64() }
+func (globalRand) Perm(n int) []int                   { return rand.Perm(n) }
+func (globalRand) Shuffle(n int, swap func(i, j int)) { rand.Shuffle(n, swap) }
@@ -0,0 +1,43 @@
+package sim
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_NewRand_sameSeedSameSequence(t *testing.T) {
+	ast := assert.New(t)
+	//
+	draw := func(r Rand) []int64 {
+		res := []int64{r.Int63(), r.Int63n(100), int64(r.Intn(100))}
+		for _, p := range r.Perm(5) {
+			res = append(res, int64(p))
+		}
+		return res
+	}
+	ast.Equal(draw(NewRand(7)), draw(NewRand(7)))
+	ast.NotEqual(draw(NewRand(7)), draw(NewRand(8)))
+}
+
+func Test_NewRand_concurrentUse(t *testing.T) {
+	ast := assert.New(t)
+	//
+	r := NewRand(1)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				f := r.Float64()
+				if f < 0 || f >= 1 {
+					t.Error("Float64 out of range")
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	ast.True(r.Intn(10) < 10)
+}
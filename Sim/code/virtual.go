@@ -0,0 +1,212 @@
+package sim
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// Virtual 是由模拟器控制的时间，只有调用 Advance 或者 Step 时，时间才会前进
+// 到期的定时器按照到期时间的先后触发，到期时间相同的，先设置的先触发，
+// 所以只要模拟器按照同样的顺序推进时间，运行就是可以重现的
+//
+// AfterFunc 的函数在 Advance 或者 Step 的调用者的 goroutine 中执行；
+// Sleep 会一直阻塞到其他 goroutine 把时间推进到 d 以后
+type Virtual struct {
+	mutex  sync.Mutex
+	now    time.Time
+	timers timerHeap
+	seq    int // 设置定时器的次数，用来打破到期时间相同的平局
+}
+
+// NewVirtual 返回从 start 开始的 Virtual
+func NewVirtual(start time.Time) *Virtual {
+	return &Virtual{now: start}
+}
+
+// Now 实现了 Clock 接口
+func (v *Virtual) Now() time.Time {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	return v.now
+}
+
+// Since 实现了 Clock 接口
+func (v *Virtual) Since(t time.Time) time.Duration {
+	return v.Now().Sub(t)
+}
+
+// Sleep 实现了 Clock 接口
+func (v *Virtual) Sleep(d time.Duration) {
+	<-v.After(d)
+}
+
+// After 实现了 Clock 接口
+func (v *Virtual) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	v.schedule(d, 0, func(now time.Time) { ch <- now })
+	return ch
+}
+
+// AfterFunc 实现了 Clock 接口
+func (v *Virtual) AfterFunc(d time.Duration, f func()) Timer {
+	return v.schedule(d, 0, func(time.Time) { f() })
+}
+
+// NewTicker 实现了 Clock 接口
+func (v *Virtual) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("sim: non-positive interval for NewTicker")
+	}
+	ch := make(chan time.Time, 1)
+	t := v.schedule(d, d, func(now time.Time) {
+		// 与 time.Ticker 一样，接收方跟不上时丢弃多余的 tick
+		select {
+		case ch <- now:
+		default:
+		}
+	})
+	return &virtualTicker{timer: t, c: ch}
+}
+
+// Advance 把时间推进 d，并按顺序触发期间到期的定时器
+func (v *Virtual) Advance(d time.Duration) {
+	v.mutex.Lock()
+	end := v.now.Add(d)
+	v.mutex.Unlock()
+	for v.fire(end) {
+	}
+	v.mutex.Lock()
+	if v.now.Before(end) {
+		v.now = end
+	}
+	v.mutex.Unlock()
+}
+
+// Step 把时间推进到下一个定时器到期的时间，并触发它
+// 没有定时器时返回 false
+func (v *Virtual) Step() bool {
+	v.mutex.Lock()
+	if len(v.timers) == 0 {
+		v.mutex.Unlock()
+		return false
+	}
+	next := v.timers[0].at
+	v.mutex.Unlock()
+	return v.fire(next)
+}
+
+// Pending 返回还没有触发的定时器的数量
+func (v *Virtual) Pending() int {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	return len(v.timers)
+}
+
+// fire 触发一个在 end 之前到期的定时器，没有这样的定时器时返回 false
+func (v *Virtual) fire(end time.Time) bool {
+	v.mutex.Lock()
+	if len(v.timers) == 0 || v.timers[0].at.After(end) {
+		v.mutex.Unlock()
+		return false
+	}
+	t := heap.Pop(&v.timers).(*virtualTimer)
+	if t.at.After(v.now) {
+		v.now = t.at
+	}
+	now := v.now
+	if t.period > 0 {
+		t.at = t.at.Add(t.period)
+		v.push(t)
+	}
+	v.mutex.Unlock()
+	t.f(now)
+	return true
+}
+
+func (v *Virtual) schedule(d, period time.Duration, f func(time.Time)) *virtualTimer {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	t := &virtualTimer{v: v, at: v.now.Add(d), period: period, f: f}
+	v.push(t)
+	return t
+}
+
+// 利用调用方的锁进行锁定
+func (v *Virtual) push(t *virtualTimer) {
+	v.seq++
+	t.seq = v.seq
+	heap.Push(&v.timers, t)
+}
+
+type virtualTimer struct {
+	v      *Virtual
+	at     time.Time
+	period time.Duration // ticker 的周期，0 表示只触发一次
+	seq    int
+	index  int // 在 heap 中的位置，-1 表示不在 heap 中
+	f      func(now time.Time)
+}
+
+func (t *virtualTimer) Stop() bool {
+	t.v.mutex.Lock()
+	defer t.v.mutex.Unlock()
+	if t.index < 0 {
+		return false
+	}
+	heap.Remove(&t.v.timers, t.index)
+	return true
+}
+
+func (t *virtualTimer) Reset(d time.Duration) bool {
+	t.v.mutex.Lock()
+	defer t.v.mutex.Unlock()
+	active := t.index >= 0
+	if active {
+		heap.Remove(&t.v.timers, t.index)
+	}
+	t.at = t.v.now.Add(d)
+	t.v.push(t)
+	return active
+}
+
+type virtualTicker struct {
+	timer *virtualTimer
+	c     chan time.Time
+}
+
+func (t *virtualTicker) C() <-chan time.Time { return t.c }
+func (t *virtualTicker) Stop()               { t.timer.Stop() }
+
+// timerHeap 按照到期时间和设置的顺序排列定时器
+type timerHeap []*virtualTimer
+
+func (h timerHeap) Len() int { return len(h) }
+
+func (h timerHeap) Less(i, j int) bool {
+	if h[i].at.Equal(h[j].at) {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].at.Before(h[j].at)
+}
+
+func (h timerHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *timerHeap) Push(x interface{}) {
+	t := x.(*virtualTimer)
+	t.index = len(*h)
+	*h = append(*h, t)
+}
+
+func (h *timerHeap) Pop() interface{} {
+	old := *h
+	t := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	t.index = -1
+	return t
+}
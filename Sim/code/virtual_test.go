@@ -0,0 +1,115 @@
+package sim
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var epoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func Test_Virtual_timersFireInDeadlineOrder(t *testing.T) {
+	ast := assert.New(t)
+	//
+	v := NewVirtual(epoch)
+	var fired []int
+	v.AfterFunc(3*time.Second, func() { fired = append(fired, 3) })
+	v.AfterFunc(1*time.Second, func() { fired = append(fired, 1) })
+	v.AfterFunc(2*time.Second, func() { fired = append(fired, 2) })
+	v.AfterFunc(1*time.Second, func() { fired = append(fired, 11) })
+	//
+	v.Advance(1500 * time.Millisecond)
+	ast.Equal([]int{1, 11}, fired)
+	ast.Equal(epoch.Add(1500*time.Millisecond), v.Now())
+	ast.Equal(2, v.Pending())
+	//
+	v.Advance(time.Hour)
+	ast.Equal([]int{1, 11, 2, 3}, fired)
+	ast.Equal(0, v.Pending())
+}
+
+func Test_Virtual_callbackSeesItsDeadline(t *testing.T) {
+	ast := assert.New(t)
+	//
+	v := NewVirtual(epoch)
+	var at []time.Duration
+	v.AfterFunc(time.Second, func() {
+		at = append(at, v.Since(epoch))
+		// 回调中设置的定时器在同一次 Advance 中到期时，也会被触发
+		v.AfterFunc(time.Second, func() { at = append(at, v.Since(epoch)) })
+	})
+	//
+	v.Advance(5 * time.Second)
+	ast.Equal([]time.Duration{time.Second, 2 * time.Second}, at)
+	ast.Equal(5*time.Second, v.Since(epoch))
+}
+
+func Test_Virtual_stopAndReset(t *testing.T) {
+	ast := assert.New(t)
+	//
+	v := NewVirtual(epoch)
+	count := 0
+	timer := v.AfterFunc(time.Second, func() { count++ })
+	ast.True(timer.Stop())
+	ast.False(timer.Stop())
+	v.Advance(2 * time.Second)
+	ast.Equal(0, count)
+	//
+	ast.False(timer.Reset(time.Second))
+	ast.True(timer.Reset(3 * time.Second))
+	v.Advance(2 * time.Second)
+	ast.Equal(0, count)
+	v.Advance(time.Second)
+	ast.Equal(1, count)
+}
+
+func Test_Virtual_ticker(t *testing.T) {
+	ast := assert.New(t)
+	//
+	v := NewVirtual(epoch)
+	ticker := v.NewTicker(time.Second)
+	v.Advance(time.Second)
+	ast.Equal(epoch.Add(time.Second), <-ticker.C())
+	// 没有被接收的 tick 会被丢弃
+	v.Advance(3 * time.Second)
+	ast.Equal(epoch.Add(2*time.Second), <-ticker.C())
+	select {
+	case <-ticker.C():
+		t.Fatal("ticker should drop ticks nobody received")
+	default:
+	}
+	//
+	ticker.Stop()
+	v.Advance(time.Hour)
+	ast.Equal(0, v.Pending())
+	//
+	ast.Panics(func() { v.NewTicker(0) })
+}
+
+func Test_Virtual_sleepAndStep(t *testing.T) {
+	ast := assert.New(t)
+	//
+	v := NewVirtual(epoch)
+	woke := make(chan time.Time)
+	go func() {
+		v.Sleep(time.Minute)
+		woke <- v.Now()
+	}()
+	for v.Pending() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	//
+	ast.True(v.Step())
+	ast.Equal(epoch.Add(time.Minute), <-woke)
+	ast.False(v.Step())
+	//
+	after := v.After(0)
+	select {
+	case <-after:
+		t.Fatal("After(0) should wait for the clock to advance")
+	default:
+	}
+	v.Advance(0)
+	ast.Equal(epoch.Add(time.Minute), <-after)
+}
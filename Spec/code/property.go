@@ -0,0 +1,212 @@
+package spec
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Predicate 是对系统某一时刻状态的判断
+// state 的具体类型由各个算法自己决定
+type Predicate func(state interface{}) bool
+
+// Property 是 TLA+ 风格的性质
+// 系统的一次运行是一个状态序列，Property 描述了这个序列应该满足的条件
+type Property interface {
+	// Name 是性质的名字，出现在违反性质时的错误信息中
+	Name() string
+	// Monitor 返回逐个检查状态的 Monitor
+	Monitor() Monitor
+}
+
+// Monitor 逐个接收一次运行中的状态，检查其是否满足某个性质
+type Monitor interface {
+	// Step 检查下一个状态，发现违反性质时返回错误
+	Step(state interface{}) error
+	// Done 在运行结束时调用，检查那些只有在运行结束时才能确定的性质
+	Done() error
+	// Clone 返回 Monitor 的副本，model checker 在分支处需要复制 Monitor
+	Clone() Monitor
+	// Key 是 Monitor 内部状态的编码
+	// model checker 只有在系统状态和 Key 都相同时，才会认为两个状态是同一个
+	Key() string
+}
+
+// Violation 是违反性质时返回的错误
+type Violation struct {
+	Property string
+	Reason   string
+}
+
+func (v *Violation) Error() string {
+	return fmt.Sprintf("%s: %s", v.Property, v.Reason)
+}
+
+type property struct {
+	name       string
+	newMonitor func() Monitor
+}
+
+func (p *property) Name() string     { return p.name }
+func (p *property) Monitor() Monitor { return p.newMonitor() }
+
+// Always 表示 □P：运行中的每一个状态都满足 p
+func Always(name string, p Predicate) Property {
+	return &property{
+		name:       name,
+		newMonitor: func() Monitor { return &alwaysMonitor{name: name, p: p} },
+	}
+}
+
+type alwaysMonitor struct {
+	name string
+	p    Predicate
+}
+
+func (m *alwaysMonitor) Step(state interface{}) error {
+	if !m.p(state) {
+		return &Violation{Property: m.name, Reason: "出现了不满足条件的状态"}
+	}
+	return nil
+}
+
+func (m *alwaysMonitor) Done() error    { return nil }
+func (m *alwaysMonitor) Clone() Monitor { return m }
+func (m *alwaysMonitor) Key() string    { return "" }
+
+// Eventually 表示 ◇P：运行结束前，至少有一个状态满足 p
+func Eventually(name string, p Predicate) Property {
+	return &property{
+		name:       name,
+		newMonitor: func() Monitor { return &eventuallyMonitor{name: name, p: p} },
+	}
+}
+
+type eventuallyMonitor struct {
+	name      string
+	p         Predicate
+	satisfied bool
+}
+
+func (m *eventuallyMonitor) Step(state interface{}) error {
+	if !m.satisfied && m.p(state) {
+		m.satisfied = true
+	}
+	return nil
+}
+
+func (m *eventuallyMonitor) Done() error {
+	if !m.satisfied {
+		return &Violation{Property: m.name, Reason: "直到运行结束，条件也没有被满足"}
+	}
+	return nil
+}
+
+func (m *eventuallyMonitor) Clone() Monitor {
+	c := *m
+	return &c
+}
+
+func (m *eventuallyMonitor) Key() string {
+	return fmt.Sprint(m.satisfied)
+}
+
+// LeadsTo 表示 P ~> Q，即 □(P => ◇Q)：每当出现满足 p 的状态，之后（包括当时）一定会出现满足 q 的状态
+func LeadsTo(name string, p, q Predicate) Property {
+	return &property{
+		name:       name,
+		newMonitor: func() Monitor { return &leadsToMonitor{name: name, p: p, q: q} },
+	}
+}
+
+type leadsToMonitor struct {
+	name    string
+	p, q    Predicate
+	waiting bool // 出现过满足 p 的状态，还在等待满足 q 的状态
+}
+
+func (m *leadsToMonitor) Step(state interface{}) error {
+	if m.q(state) {
+		m.waiting = false
+	} else if m.p(state) {
+		m.waiting = true
+	}
+	return nil
+}
+
+func (m *leadsToMonitor) Done() error {
+	if m.waiting {
+		return &Violation{Property: m.name, Reason: "前提成立以后，直到运行结束，结论也没有成立"}
+	}
+	return nil
+}
+
+func (m *leadsToMonitor) Clone() Monitor {
+	c := *m
+	return &c
+}
+
+func (m *leadsToMonitor) Key() string {
+	return fmt.Sprint(m.waiting)
+}
+
+// Monitors 同时检查多个性质
+type Monitors []Monitor
+
+// NewMonitors 返回检查 props 的 Monitors
+func NewMonitors(props ...Property) Monitors {
+	res := make(Monitors, len(props))
+	for i, p := range props {
+		res[i] = p.Monitor()
+	}
+	return res
+}
+
+// Step 让每个 Monitor 检查 state，返回发现的第一个错误
+func (ms Monitors) Step(state interface{}) error {
+	for _, m := range ms {
+		if err := m.Step(state); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Done 结束每个 Monitor 的检查，返回发现的第一个错误
+func (ms Monitors) Done() error {
+	for _, m := range ms {
+		if err := m.Done(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Clone 返回所有 Monitor 的副本
+func (ms Monitors) Clone() Monitors {
+	res := make(Monitors, len(ms))
+	for i, m := range ms {
+		res[i] = m.Clone()
+	}
+	return res
+}
+
+// Key 返回所有 Monitor 内部状态的编码
+func (ms Monitors) Key() string {
+	var b strings.Builder
+	for _, m := range ms {
+		b.WriteString(m.Key())
+		b.WriteByte('|')
+	}
+	return b.String()
+}
+
+// Check 检查一次完整的运行 behavior 是否满足全部的 props
+func Check(behavior []interface{}, props ...Property) error {
+	ms := NewMonitors(props...)
+	for _, state := range behavior {
+		if err := ms.Step(state); err != nil {
+			return err
+		}
+	}
+	return ms.Done()
+}
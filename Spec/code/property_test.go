@@ -0,0 +1,67 @@
+package spec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func isPositive(state interface{}) bool { return state.(int) > 0 }
+func isZero(state interface{}) bool     { return state.(int) == 0 }
+func isNegative(state interface{}) bool { return state.(int) < 0 }
+
+func behavior(xs ...int) []interface{} {
+	res := make([]interface{}, len(xs))
+	for i, x := range xs {
+		res[i] = x
+	}
+	return res
+}
+
+func Test_Always(t *testing.T) {
+	ast := assert.New(t)
+	//
+	p := Always("AlwaysPositive", isPositive)
+	ast.Equal("AlwaysPositive", p.Name())
+	ast.Nil(Check(behavior(1, 2, 3), p))
+	err := Check(behavior(1, 0, 3), p)
+	ast.Equal("AlwaysPositive: 出现了不满足条件的状态", err.Error())
+}
+
+func Test_Eventually(t *testing.T) {
+	ast := assert.New(t)
+	//
+	p := Eventually("EventuallyZero", isZero)
+	ast.Nil(Check(behavior(2, 1, 0, 1), p))
+	ast.Equal(&Violation{Property: "EventuallyZero", Reason: "直到运行结束，条件也没有被满足"},
+		Check(behavior(2, 1, 1), p))
+}
+
+func Test_LeadsTo(t *testing.T) {
+	ast := assert.New(t)
+	//
+	p := LeadsTo("NegativeLeadsToZero", isNegative, isZero)
+	ast.Nil(Check(behavior(1, 2), p))
+	ast.Nil(Check(behavior(-1, -2, 0, 1), p))
+	ast.NotNil(Check(behavior(-1, 0, -1, 1), p))
+}
+
+func Test_Monitors_cloneAndKey(t *testing.T) {
+	ast := assert.New(t)
+	//
+	ms := NewMonitors(
+		Always("AlwaysPositive", isPositive),
+		Eventually("EventuallyTwo", func(s interface{}) bool { return s.(int) == 2 }),
+	)
+	ast.Nil(ms.Step(1))
+	key := ms.Key()
+	// 分支以后，两个 Monitor 互不影响
+	branch := ms.Clone()
+	ast.Nil(branch.Step(2))
+	ast.Nil(branch.Done())
+	ast.Equal(key, ms.Key())
+	ast.NotEqual(key, branch.Key())
+	ast.NotNil(ms.Done())
+	//
+	ast.NotNil(ms.Step(-1))
+}
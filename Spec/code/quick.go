@@ -0,0 +1,155 @@
+package spec
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+// Choices 是一次随机运行中做出的全部选择
+// workload 和 message 的送达顺序都从 Choices 中取出，所以记录下 Choices 就可以重现一次运行，
+// 缩短 Choices 就可以缩短反例，这与 Hypothesis 和 rapid 的做法相同。
+type Choices struct {
+	values []int
+	next   int
+	rnd    *rand.Rand // 为 nil 时是在重放，values 用完以后总是选择 0
+}
+
+// Replay 返回重放 values 的 Choices
+func Replay(values []int) *Choices {
+	return &Choices{values: values}
+}
+
+// Intn 返回 [0, n) 中的一个选择，n 必须大于 0
+// 重放的值不小于 n 时取余数，这样缩短以后的 Choices 依然是合法的
+func (c *Choices) Intn(n int) int {
+	if c.next < len(c.values) {
+		v := c.values[c.next]
+		c.next++
+		return v % n
+	}
+	if c.rnd == nil {
+		return 0
+	}
+	v := c.rnd.Intn(n)
+	c.values = append(c.values, v)
+	c.next++
+	return v
+}
+
+// Bool 返回一个随机的 bool，缩短时倾向于 false
+func (c *Choices) Bool() bool {
+	return c.Intn(2) == 1
+}
+
+// Range 返回 [min, max] 中的一个选择，缩短时倾向于 min
+func (c *Choices) Range(min, max int) int {
+	return min + c.Intn(max-min+1)
+}
+
+// Values 返回到目前为止做出的全部选择
+func (c *Choices) Values() []int {
+	return c.values[:c.next]
+}
+
+// Shrink 缩短让 fails 返回 true 的 values，返回仍然让 fails 返回 true 的、尽可能短和小的 values
+// 先尝试删除连续的一段，段的长度从一半开始逐步减小到 1，然后尝试把每个选择都改成 0，
+// 改成 0 不行的话，再尝试不断地减半
+func Shrink(values []int, fails func([]int) bool) []int {
+	res := append([]int(nil), values...)
+	for size := len(res) / 2; size >= 1; size /= 2 {
+		for i := 0; i+size <= len(res); {
+			candidate := append(append([]int(nil), res[:i]...), res[i+size:]...)
+			if fails(candidate) {
+				res = candidate
+				continue
+			}
+			i++
+		}
+	}
+	for i := range res {
+		for res[i] != 0 {
+			old := res[i]
+			if res[i] = 0; fails(res) {
+				break
+			}
+			if res[i] = old / 2; res[i] == 0 || !fails(res) {
+				res[i] = old
+				break
+			}
+		}
+	}
+	return res
+}
+
+// QuickConfig 是 Quick 的配置
+type QuickConfig struct {
+	Seed int64 // 随机数种子，同样的种子总会生成同样的运行
+	Runs int   // 运行的次数，为 0 时运行 100 次
+}
+
+// QuickResult 是 Quick 的结果
+type QuickResult struct {
+	Runs     int   // 实际运行的次数，不包括缩短时的运行
+	Err      error // 缩短以后的反例违反的性质，为 nil 表示没有发现错误
+	Choices  []int // 缩短以后的反例，用 Replay(Choices) 可以重现
+	Original int   // 缩短之前的反例的长度
+}
+
+// OK 返回 true，如果没有发现错误
+func (r *QuickResult) OK() bool {
+	return r.Err == nil
+}
+
+func (r *QuickResult) String() string {
+	if r.OK() {
+		return fmt.Sprintf("运行了 %d 次，没有发现错误", r.Runs)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "第 %d 次运行时违反了 %v\n", r.Runs, r.Err)
+	fmt.Fprintf(&b, "反例从 %d 个选择缩短到了 %d 个：%v", r.Original, len(r.Choices), r.Choices)
+	return b.String()
+}
+
+// Quick 用 c.Seed 生成 c.Runs 个随机的运行，run 从 Choices 中取出所有的随机选择，
+// 返回非 nil 的 error 表示违反了性质。
+// 与穷举的 model checker 不同，Quick 可以用于更大的规模，但是不能证明没有错误。
+// 发现错误时，会用 Shrink 缩短导致错误的选择，缩短后的选择需要违反同一个性质：
+// error 是 *Violation 时，比较 Property，否则比较 Error()
+func Quick(c QuickConfig, run func(*Choices) error) *QuickResult {
+	runs := c.Runs
+	if runs == 0 {
+		runs = 100
+	}
+	rnd := rand.New(rand.NewSource(c.Seed))
+	res := &QuickResult{}
+	for res.Runs < runs {
+		res.Runs++
+		choices := &Choices{rnd: rnd}
+		err := run(choices)
+		if err == nil {
+			continue
+		}
+		fails := func(values []int) bool {
+			return sameFailure(err, run(Replay(values)))
+		}
+		res.Original = len(choices.Values())
+		res.Choices = Shrink(choices.Values(), fails)
+		res.Err = run(Replay(res.Choices))
+		return res
+	}
+	return res
+}
+
+// sameFailure 返回 true，如果 b 与 a 违反了同一个性质
+func sameFailure(a, b error) bool {
+	if b == nil {
+		return false
+	}
+	va, ok := a.(*Violation)
+	if !ok {
+		return a.Error() == b.Error()
+	}
+	vb, ok := b.(*Violation)
+	return ok && va.Property == vb.Property
+}
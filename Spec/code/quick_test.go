@@ -0,0 +1,80 @@
+package spec
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Choices_replay(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := Replay([]int{7, 1, 5})
+	ast.Equal(1, c.Intn(3))
+	ast.True(c.Bool())
+	ast.Equal(10, c.Range(5, 10))
+	// 用完以后总是选择 0
+	ast.Equal(0, c.Intn(100))
+	ast.Equal(3, c.Range(3, 9))
+	ast.Equal([]int{7, 1, 5}, c.Values())
+}
+
+func Test_Shrink(t *testing.T) {
+	ast := assert.New(t)
+	//
+	fails := func(values []int) bool {
+		for _, v := range values {
+			if v >= 10 {
+				return true
+			}
+		}
+		return false
+	}
+	res := Shrink([]int{3, 25, 7, 12, 1}, fails)
+	ast.Equal(1, len(res))
+	ast.True(res[0] >= 10 && res[0] < 20, "%v", res)
+}
+
+// sumBelow 随机生成一串数字，要求它们的和小于 limit
+func sumBelow(limit int) func(*Choices) error {
+	return func(c *Choices) error {
+		sum := 0
+		for i, n := 0, c.Range(0, 20); i < n; i++ {
+			sum += c.Intn(100)
+		}
+		if sum >= limit {
+			return &Violation{Property: "SumBelow", Reason: fmt.Sprintf("和是 %d", sum)}
+		}
+		return nil
+	}
+}
+
+func Test_Quick(t *testing.T) {
+	ast := assert.New(t)
+	//
+	res := Quick(QuickConfig{Seed: 1}, sumBelow(5000))
+	ast.True(res.OK())
+	ast.Equal(100, res.Runs)
+	ast.Contains(res.String(), "没有发现错误")
+
+	res = Quick(QuickConfig{Seed: 1, Runs: 50}, sumBelow(150))
+	ast.False(res.OK())
+	ast.Equal("SumBelow", res.Err.(*Violation).Property)
+	ast.True(len(res.Choices) < res.Original)
+	// 缩短以后的反例可以重现
+	ast.Equal(res.Err.Error(), sumBelow(150)(Replay(res.Choices)).Error())
+	// 同样的种子，同样的结果
+	ast.Equal(res.Choices, Quick(QuickConfig{Seed: 1, Runs: 50}, sumBelow(150)).Choices)
+}
+
+func Test_sameFailure(t *testing.T) {
+	ast := assert.New(t)
+	//
+	a := &Violation{Property: "P", Reason: "1"}
+	ast.True(sameFailure(a, &Violation{Property: "P", Reason: "2"}))
+	ast.False(sameFailure(a, &Violation{Property: "Q", Reason: "1"}))
+	ast.False(sameFailure(a, nil))
+	ast.False(sameFailure(a, fmt.Errorf("P: 1")))
+	ast.True(sameFailure(fmt.Errorf("x"), fmt.Errorf("x")))
+}
@@ -0,0 +1,93 @@
+package taskqueue
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aQuaYi/Distributed-Algorithms/Raft/code/labrpc"
+	"github.com/aQuaYi/Distributed-Algorithms/Raft/code/rsm"
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+)
+
+// Client 是任务队列集群的客户端，producer 和 worker 都通过它访问队列
+type Client struct {
+	visibility time.Duration
+	env        sim.Env
+
+	mutex sync.Mutex // 保证同一个 Client 的请求是串行的
+	ck    *rsm.Clerk
+}
+
+// MakeClient 返回一个通过 servers 访问集群的 Client，visibility 是集群的可见性超时
+func MakeClient(servers []*labrpc.ClientEnd, visibility time.Duration) *Client {
+	return MakeClientWithEnv(servers, visibility, sim.Env{})
+}
+
+// MakeClientWithEnv 与 MakeClient 一样，但是 c 和领取的任务从 env 读取时间
+func MakeClientWithEnv(servers []*labrpc.ClientEnd, visibility time.Duration, env sim.Env) *Client {
+	return &Client{
+		visibility: visibility,
+		env:        env,
+		ck:         rsm.MakeClerk(servers, env),
+	}
+}
+
+// submit 把 args 发送给 leader，直到它被应用
+func (c *Client) submit(args OpArgs) OpReply {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	args.ClientID, args.Seq = c.ck.ClientID(), c.ck.NextSeq()
+	var res OpReply
+	c.ck.Call(func(server *labrpc.ClientEnd) bool {
+		var reply OpReply
+		if !server.Call("Server.Submit", &args, &reply) {
+			return false
+		}
+		switch reply.Err {
+		case ErrWrongLeader, ErrTimeout:
+			return false
+		}
+		res = reply
+		return true
+	})
+	return res
+}
+
+// Push 把 body 作为新的任务放入队尾，返回任务的 ID
+func (c *Client) Push(body string) int64 {
+	return c.submit(OpArgs{Type: opPush, Body: body}).ID
+}
+
+// Pull 为 worker 领取队首的任务，队列为空时返回 false
+// 任务在可见性超时之内不会被其他 worker 领取，worker 需要在此之前 Ack
+func (c *Client) Pull(worker int) (Task, bool) {
+	start := c.env.Now()
+	reply := c.submit(OpArgs{Type: opPull, Worker: worker})
+	if reply.Err != OK {
+		return Task{}, false
+	}
+	// worker 无法知道 leader 是何时记录的 lease，只好保守地认为，lease 从发送请求时就开始了
+	return Task{
+		ID:       reply.ID,
+		Body:     reply.Body,
+		Token:    reply.Token,
+		Attempts: reply.Attempts,
+		deadline: start.Add(c.visibility),
+		env:      c.env,
+	}, true
+}
+
+// Ack 完成任务 t
+// t 的 lease 已经过期，任务被重新投递或者已经被其他 worker 完成时，返回 ErrStaleLease
+func (c *Client) Ack(t Task) error {
+	if c.submit(OpArgs{Type: opAck, Task: t.ID, Token: t.Token}).Err != OK {
+		return ErrStaleLease
+	}
+	return nil
+}
+
+// Release 收回 worker 领取的所有任务，把它们重新放回队列
+func (c *Client) Release(worker int) {
+	c.submit(OpArgs{Type: opRelease, Worker: worker})
+}
@@ -0,0 +1,45 @@
+package taskqueue
+
+import (
+	"time"
+
+	raft "github.com/aQuaYi/Distributed-Algorithms/Raft/code"
+	"github.com/aQuaYi/Distributed-Algorithms/Raft/code/labrpc"
+	"github.com/aQuaYi/Distributed-Algorithms/Raft/code/rsm"
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+)
+
+// Cluster 在 labrpc 模拟的网络中运行一组任务队列 Server
+type Cluster struct {
+	*rsm.Cluster
+	servers    []*Server
+	visibility time.Duration
+}
+
+// MakeCluster 启动由 n 个 Server 组成的集群，可见性超时为 visibility
+func MakeCluster(n int, visibility time.Duration) *Cluster {
+	return MakeClusterWithEnv(n, visibility, sim.Env{})
+}
+
+// MakeClusterWithEnv 与 MakeCluster 一样，但是网络、server 和 Client 都从 env 读取时间和随机数
+func MakeClusterWithEnv(n int, visibility time.Duration, env sim.Env) *Cluster {
+	c := &Cluster{servers: make([]*Server, n), visibility: visibility}
+	c.Cluster = rsm.MakeCluster(n, env, func(servers []*labrpc.ClientEnd, me int, persister *raft.Persister) rsm.Service {
+		c.servers[me] = StartServerWithEnv(servers, me, persister, visibility, env)
+		return c.servers[me]
+	})
+	return c
+}
+
+// MakeClient 返回一个可以访问所有 server 的 Client
+func (c *Cluster) MakeClient() *Client {
+	return MakeClientWithEnv(c.ClientEnds(), c.visibility, c.Env())
+}
+
+// Stats 返回 leader 的队列统计，没有 leader 时返回第一个 server 的
+func (c *Cluster) Stats() Stats {
+	if leader := c.Leader(); leader != -1 {
+		return c.servers[leader].Stats()
+	}
+	return c.servers[0].Stats()
+}
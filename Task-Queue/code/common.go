@@ -0,0 +1,121 @@
+package taskqueue
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aQuaYi/Distributed-Algorithms/Raft/code/labgob"
+	"github.com/aQuaYi/Distributed-Algorithms/Raft/code/rsm"
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+)
+
+func init() {
+	// Op 会作为 raft.LogEntry.Command 在 server 之间传递
+	labgob.Register(Op{})
+}
+
+// ErrStaleLease 表示任务的 lease 已经过期，任务被重新投递给了其他 worker，或者已经完成了
+var ErrStaleLease = errors.New("taskqueue: task lease is stale")
+
+// Err 是 RPC 的结果
+type Err = rsm.Err
+
+// 枚举了所有的 Err
+const (
+	OK                 = rsm.OK
+	ErrWrongLeader     = rsm.ErrWrongLeader
+	ErrTimeout         = rsm.ErrTimeout
+	ErrEmpty       Err = "ErrEmpty"     // 没有等待处理的任务
+	ErrNotLeased   Err = "ErrNotLeased" // Ack 的 Token 不是任务当前的 lease
+)
+
+type opType int
+
+// 枚举了 Op 的所有类型
+const (
+	opPush    opType = iota // 添加任务
+	opPull                  // worker 领取任务
+	opAck                   // worker 完成任务
+	opExpire                // leader 发现任务的可见性超时
+	opRelease               // 故障检测器怀疑 worker 崩溃了，收回它领取的所有任务
+)
+
+func (t opType) String() string {
+	switch t {
+	case opPush:
+		return "Push"
+	case opPull:
+		return "Pull"
+	case opAck:
+		return "Ack"
+	case opExpire:
+		return "Expire"
+	default:
+		return "Release"
+	}
+}
+
+// Op 是写入 raft log 的命令
+type Op struct {
+	Type     opType
+	Body     string // opPush
+	Worker   int    // opPull, opRelease
+	Task     int64  // opAck, opExpire
+	Token    uint64 // opAck, opExpire
+	ClientID int64  // 发起操作的 Client，opExpire 由 leader 发起，ClientID 为 0
+	Seq      int    // 操作在 Client 中的序号，用于去重
+}
+
+// Request 返回发起 op 的 Client 和 op 的序号，实现了 rsm.Command 接口
+func (op Op) Request() (int64, int) {
+	return op.ClientID, op.Seq
+}
+
+func (op Op) String() string {
+	return fmt.Sprintf("%s{W%d, T%d#%d, C%d:%d}", op.Type, op.Worker, op.Task, op.Token, op.ClientID, op.Seq)
+}
+
+// Task 是队列中的任务
+type Task struct {
+	ID   int64
+	Body string
+	// Token 是领取任务的 opPull 在 raft log 中的 index，同一个任务每次被领取时，Token 都会增大
+	// Ack 时带上 Token，过期的 lease 就无法完成任务
+	Token uint64
+	// Attempts 是任务被领取的次数，大于 1 说明任务被重新投递过
+	Attempts int
+	// deadline 是 worker 认为 lease 到期的时间，与 leaselock.Lease 一样，从发送请求时开始计时
+	deadline time.Time
+	env      sim.Env // 领取任务的 Client 的时间来源
+}
+
+// Valid 返回在 worker 看来，任务的 lease 是否还有效
+func (t Task) Valid() bool {
+	return t.env.Now().Before(t.deadline)
+}
+
+// OpArgs 是所有 RPC 的参数
+type OpArgs struct {
+	Type     opType
+	Body     string
+	Worker   int
+	Task     int64
+	Token    uint64
+	ClientID int64
+	Seq      int
+}
+
+// OpReply 是所有 RPC 的返回值
+// opPush 和 opPull 成功时，包含任务的内容
+type OpReply struct {
+	Err      Err
+	ID       int64
+	Body     string
+	Token    uint64
+	Attempts int
+}
+
+func (r *OpReply) setTask(t Task) {
+	r.ID, r.Body, r.Token, r.Attempts = t.ID, t.Body, t.Token, t.Attempts
+}
@@ -0,0 +1,212 @@
+package taskqueue
+
+import (
+	"sort"
+	"time"
+
+	raft "github.com/aQuaYi/Distributed-Algorithms/Raft/code"
+	"github.com/aQuaYi/Distributed-Algorithms/Raft/code/labrpc"
+	"github.com/aQuaYi/Distributed-Algorithms/Raft/code/rsm"
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+)
+
+// task 是队列中的任务，以及它当前的 lease
+type task struct {
+	Task
+	leased bool
+	worker int
+}
+
+// Stats 统计了队列中的任务
+type Stats struct {
+	Pending     int // 等待领取的任务数量
+	Leased      int // 已经被领取，还没有完成的任务数量
+	Done        int // 已经完成的任务数量
+	Redelivered int // 任务被重新投递的次数
+}
+
+// Server 是通过 raft 复制的任务队列
+// worker 领取任务时得到一个 lease，在可见性超时之内，任务不会再被其他 worker 领取。
+// lease 的到期时间只由 leader 本地的时钟决定，leader 发现到期时，把 opExpire 写入 raft log，
+// 所有的 server 应用 opExpire 时才把任务放回队尾，所以队列总是一致的。
+// 故障检测器怀疑 worker 崩溃时，opRelease 会立即收回它领取的所有任务，不必等到可见性超时。
+type Server struct {
+	rsm        *rsm.Server
+	visibility time.Duration
+	env        sim.Env
+
+	// 以下属性由 rsm 的锁保护
+	nextID  int64
+	tasks   map[int64]*task // 还没有完成的任务
+	pending []int64         // 等待领取的任务，按照投递的顺序排列
+	stats   Stats
+
+	// 以下属性只在 leader 上有意义，不需要复制
+	deadlines map[int64]time.Time // 每个被领取的任务的可见性超时
+	term      int                 // 上次检查 lease 时的 term
+}
+
+// StartServer 启动一个 Server，被领取的任务在 visibility 之后重新投递
+// servers 是所有 server 的 raft 端点，me 是自己在 servers 中的索引
+func StartServer(servers []*labrpc.ClientEnd, me int, persister *raft.Persister, visibility time.Duration) *Server {
+	return StartServerWithEnv(servers, me, persister, visibility, sim.Env{})
+}
+
+// StartServerWithEnv 与 StartServer 一样，但是 s 和它的 raft 从 env 读取时间和随机数
+func StartServerWithEnv(servers []*labrpc.ClientEnd, me int, persister *raft.Persister, visibility time.Duration, env sim.Env) *Server {
+	s := &Server{
+		env:        env,
+		visibility: visibility,
+		tasks:      make(map[int64]*task, 16),
+		deadlines:  make(map[int64]time.Time, 16),
+	}
+	s.rsm = rsm.StartServer(servers, me, persister, s, env)
+
+	go s.sweepLoop()
+
+	return s
+}
+
+// Raft 返回 s 所使用的 raft
+func (s *Server) Raft() *raft.Raft {
+	return s.rsm.Raft()
+}
+
+// Kill 停止 s
+func (s *Server) Kill() {
+	s.rsm.Kill()
+}
+
+// Apply 应用 log 中 index 处的 Op，返回 OpReply，实现了 rsm.StateMachine 接口
+func (s *Server) Apply(command interface{}, index int) interface{} {
+	op, ok := command.(Op)
+	if !ok {
+		return nil
+	}
+	reply := OpReply{Err: OK}
+	switch op.Type {
+	case opPush:
+		s.nextID++
+		t := &task{Task: Task{ID: s.nextID, Body: op.Body}}
+		s.tasks[t.ID] = t
+		s.pending = append(s.pending, t.ID)
+		s.stats.Pending++
+		reply.setTask(t.Task)
+	case opPull:
+		if len(s.pending) == 0 {
+			reply.Err = ErrEmpty
+			break
+		}
+		t := s.tasks[s.pending[0]]
+		s.pending = s.pending[1:]
+		t.leased, t.worker = true, op.Worker
+		t.Token = uint64(index)
+		t.Attempts++
+		s.deadlines[t.ID] = s.env.Now().Add(s.visibility)
+		s.stats.Pending--
+		s.stats.Leased++
+		reply.setTask(t.Task)
+	case opAck:
+		t, ok := s.tasks[op.Task]
+		if !ok || !t.leased || t.Token != op.Token {
+			reply.Err = ErrNotLeased
+			break
+		}
+		delete(s.tasks, t.ID)
+		delete(s.deadlines, t.ID)
+		s.stats.Leased--
+		s.stats.Done++
+	case opExpire:
+		if t, ok := s.tasks[op.Task]; ok && t.leased && t.Token == op.Token {
+			s.requeue(t)
+		}
+	case opRelease:
+		var ids []int64
+		for id, t := range s.tasks {
+			if t.leased && t.worker == op.Worker {
+				ids = append(ids, id)
+			}
+		}
+		// 所有的 server 必须以同样的顺序放回队列
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		for _, id := range ids {
+			s.requeue(s.tasks[id])
+		}
+	}
+	return reply
+}
+
+// requeue 收回 t 的 lease，把它放回队尾
+func (s *Server) requeue(t *task) {
+	t.leased = false
+	delete(s.deadlines, t.ID)
+	s.pending = append(s.pending, t.ID)
+	s.stats.Leased--
+	s.stats.Pending++
+	s.stats.Redelivered++
+}
+
+// sweepLoop 在 leader 上定期检查被领取的任务是否超时
+func (s *Server) sweepLoop() {
+	for {
+		s.env.Sleep(s.visibility / 5)
+		term, isLeader := s.Raft().GetState()
+
+		s.rsm.Lock()
+		if s.rsm.Killed() {
+			s.rsm.Unlock()
+			return
+		}
+		if !isLeader {
+			s.rsm.Unlock()
+			continue
+		}
+		now := s.env.Now()
+		if term != s.term {
+			// 新的 leader 不知道任务是何时被领取的，给所有的 lease 一个完整的可见性超时
+			s.term = term
+			for id := range s.deadlines {
+				s.deadlines[id] = now.Add(s.visibility)
+			}
+		}
+		var expired []Op
+		for id, deadline := range s.deadlines {
+			if now.After(deadline) {
+				expired = append(expired, Op{Type: opExpire, Task: id, Token: s.tasks[id].Token})
+				// 在 opExpire 被应用之前，不要重复提交
+				s.deadlines[id] = now.Add(s.visibility)
+			}
+		}
+		s.rsm.Unlock()
+
+		for _, op := range expired {
+			s.Raft().Start(op)
+		}
+	}
+}
+
+// Submit 是 RPC handler，所有的请求都通过它写入 raft log，并等待其被应用
+func (s *Server) Submit(args *OpArgs, reply *OpReply) {
+	value, err := s.rsm.Submit(Op{
+		Type:     args.Type,
+		Body:     args.Body,
+		Worker:   args.Worker,
+		Task:     args.Task,
+		Token:    args.Token,
+		ClientID: args.ClientID,
+		Seq:      args.Seq,
+	})
+	if err != OK {
+		reply.Err = err
+		return
+	}
+	*reply = value.(OpReply)
+}
+
+// Stats 返回 s 本地的队列统计
+// 只用于观察，不保证读到最新的状态
+func (s *Server) Stats() Stats {
+	s.rsm.Lock()
+	defer s.rsm.Unlock()
+	return s.stats
+}
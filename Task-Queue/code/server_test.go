@@ -0,0 +1,116 @@
+package taskqueue
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Cluster_pushPullAck(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := MakeCluster(3, time.Second)
+	defer c.Cleanup()
+	producer, worker := c.MakeClient(), c.MakeClient()
+	//
+	_, ok := worker.Pull(1)
+	ast.False(ok, "队列是空的")
+	for i := 0; i < 3; i++ {
+		ast.Equal(int64(i+1), producer.Push(fmt.Sprint(i)))
+	}
+	// 按照投递的顺序领取
+	for i := 0; i < 3; i++ {
+		task, ok := worker.Pull(1)
+		ast.True(ok)
+		ast.Equal(fmt.Sprint(i), task.Body)
+		ast.Equal(1, task.Attempts)
+		ast.True(task.Valid())
+		ast.NoError(worker.Ack(task))
+		ast.Equal(ErrStaleLease, worker.Ack(task), "任务已经完成了")
+	}
+	_, ok = worker.Pull(1)
+	ast.False(ok)
+	ast.Equal(Stats{Done: 3}, c.Stats())
+}
+
+func Test_Cluster_visibilityTimeout(t *testing.T) {
+	ast := assert.New(t)
+	//
+	visibility := 300 * time.Millisecond
+	c := MakeCluster(3, visibility)
+	defer c.Cleanup()
+	producer, slow, fast := c.MakeClient(), c.MakeClient(), c.MakeClient()
+	//
+	producer.Push("x")
+	first, ok := slow.Pull(1)
+	ast.True(ok)
+	_, ok = fast.Pull(2)
+	ast.False(ok, "可见性超时之内，任务不会被其他 worker 领取")
+	//
+	// slow 没有在可见性超时之内 Ack，任务被重新投递给 fast
+	time.Sleep(visibility * 2)
+	ast.False(first.Valid())
+	second, ok := fast.Pull(2)
+	ast.True(ok)
+	ast.Equal(first.ID, second.ID)
+	ast.Equal(2, second.Attempts)
+	ast.True(second.Token > first.Token)
+	// slow 的 lease 已经过期，它的 Ack 被拒绝
+	ast.Equal(ErrStaleLease, slow.Ack(first))
+	ast.NoError(fast.Ack(second))
+	ast.Equal(Stats{Done: 1, Redelivered: 1}, c.Stats())
+}
+
+func Test_Cluster_Release(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := MakeCluster(3, time.Minute)
+	defer c.Cleanup()
+	cl := c.MakeClient()
+	for i := 0; i < 4; i++ {
+		cl.Push(fmt.Sprint(i))
+	}
+	a, _ := cl.Pull(1)
+	b, _ := cl.Pull(2)
+	cc, _ := cl.Pull(1)
+	ast.Equal(Stats{Pending: 1, Leased: 3}, c.Stats())
+	//
+	// 收回 worker 1 的任务，它们排在原来的任务之后
+	cl.Release(1)
+	ast.Equal(Stats{Pending: 3, Leased: 1, Redelivered: 2}, c.Stats())
+	var bodies []string
+	for {
+		task, ok := cl.Pull(3)
+		if !ok {
+			break
+		}
+		bodies = append(bodies, task.Body)
+	}
+	ast.Equal([]string{"3", a.Body, cc.Body}, bodies)
+	ast.Equal(ErrStaleLease, cl.Ack(a))
+	ast.NoError(cl.Ack(b))
+}
+
+func Test_Cluster_leaderFailure(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := MakeCluster(5, time.Minute)
+	defer c.Cleanup()
+	cl := c.MakeClient()
+	for i := 0; i < 10; i++ {
+		cl.Push(fmt.Sprint(i))
+	}
+	leader := c.Leader()
+	c.Disconnect(leader)
+	// 新 leader 从同样的队列继续
+	for i := 0; i < 10; i++ {
+		task, ok := cl.Pull(1)
+		ast.True(ok)
+		ast.Equal(fmt.Sprint(i), task.Body)
+		ast.NoError(cl.Ack(task))
+	}
+	ast.Equal(Stats{Done: 10}, c.Stats())
+	c.Connect(leader)
+}
@@ -0,0 +1,180 @@
+package taskqueue
+
+import (
+	"sync"
+	"time"
+
+	failuredetector "github.com/aQuaYi/Distributed-Algorithms/Failure-Detector/code"
+)
+
+// pollInterval 是队列为空时，worker 再次领取任务前等待的时间
+const pollInterval = 10 * time.Millisecond
+
+// Worker 不断地从队列中领取任务，交给 handler 处理，处理完成以后 Ack
+// worker 同时运行一个故障检测器模块，借助它向 Monitor 发送 heartbeat
+type Worker struct {
+	id       int
+	client   *Client
+	net      *failuredetector.Network
+	detector *failuredetector.EventuallyPerfectDetector
+	handler  func(Task)
+
+	mutex   sync.Mutex
+	crashed bool
+	stale   int // Ack 时 lease 已经过期的任务数量
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// StartWorker 启动 ID 为 id 的 worker，它在 net 上向 ID 为 monitor 的 Monitor 发送 heartbeat
+func StartWorker(id int, client *Client, net *failuredetector.Network, monitor int, config failuredetector.Config, handler func(Task)) *Worker {
+	w := &Worker{
+		id:       id,
+		client:   client,
+		net:      net,
+		detector: failuredetector.NewEventuallyPerfect(id, []int{id, monitor}, net, config),
+		handler:  handler,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go w.loop()
+	return w
+}
+
+// ID 返回 w 的 ID
+func (w *Worker) ID() int {
+	return w.id
+}
+
+func (w *Worker) loop() {
+	defer close(w.done)
+	for {
+		select {
+		case <-w.stop:
+			return
+		default:
+		}
+		t, ok := w.client.Pull(w.id)
+		if !ok {
+			w.client.env.Sleep(pollInterval)
+			continue
+		}
+		w.handler(t)
+		w.mutex.Lock()
+		crashed := w.crashed
+		w.mutex.Unlock()
+		if crashed {
+			// 崩溃的 worker 不会 Ack，任务会被重新投递
+			return
+		}
+		if err := w.client.Ack(t); err == ErrStaleLease {
+			// 处理得太慢，任务已经被重新投递了，其他 worker 会再处理一次
+			w.mutex.Lock()
+			w.stale++
+			w.mutex.Unlock()
+		}
+	}
+}
+
+// Stale 返回 Ack 时 lease 已经过期的任务数量
+func (w *Worker) Stale() int {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.stale
+}
+
+// Stop 让 w 处理完当前的任务以后停止
+func (w *Worker) Stop() {
+	close(w.stop)
+	<-w.done
+	w.detector.Stop()
+}
+
+// Crash 模拟 w 崩溃：不再发送 heartbeat，正在处理的任务也不会 Ack
+func (w *Worker) Crash() {
+	w.mutex.Lock()
+	w.crashed = true
+	w.mutex.Unlock()
+	w.net.Crash(w.id)
+	w.detector.Stop()
+	close(w.stop)
+}
+
+// Monitor 用故障检测器监视所有的 worker，怀疑某个 worker 崩溃时，立即收回它领取的任务
+// 不必等到可见性超时，任务就会被重新投递。◇P 可能会错误地怀疑一个很慢的 worker，
+// 此时任务会被处理两次，但是慢 worker 的 Ack 会因为 Token 过期而被拒绝
+type Monitor struct {
+	client   *Client
+	detector *failuredetector.EventuallyPerfectDetector
+	interval time.Duration
+
+	mutex    sync.Mutex
+	released map[int]bool // 已经收回了任务，并且依然被怀疑的 worker
+	releases int
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// StartMonitor 在 net 上启动 ID 为 id 的 Monitor，监视 workers
+func StartMonitor(id int, workers []int, client *Client, net *failuredetector.Network, config failuredetector.Config) *Monitor {
+	m := &Monitor{
+		client:   client,
+		detector: failuredetector.NewEventuallyPerfect(id, append([]int{id}, workers...), net, config),
+		interval: config.Interval,
+		released: make(map[int]bool, len(workers)),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go m.loop()
+	return m
+}
+
+func (m *Monitor) loop() {
+	defer close(m.done)
+	ticker := m.client.env.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C():
+		}
+		suspected := m.detector.Suspected()
+		now := make(map[int]bool, len(suspected))
+		for _, w := range suspected {
+			now[w] = true
+			m.mutex.Lock()
+			released := m.released[w]
+			m.mutex.Unlock()
+			if !released {
+				m.client.Release(w)
+				m.mutex.Lock()
+				m.released[w] = true
+				m.releases++
+				m.mutex.Unlock()
+			}
+		}
+		// 撤销了怀疑的 worker，下次被怀疑时还需要再收回一次
+		m.mutex.Lock()
+		for w := range m.released {
+			if !now[w] {
+				delete(m.released, w)
+			}
+		}
+		m.mutex.Unlock()
+	}
+}
+
+// Releases 返回 m 收回 worker 的任务的次数
+func (m *Monitor) Releases() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.releases
+}
+
+// Stop 停止 m
+func (m *Monitor) Stop() {
+	close(m.stop)
+	<-m.done
+	m.detector.Stop()
+}
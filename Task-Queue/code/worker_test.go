@@ -0,0 +1,123 @@
+package taskqueue
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	failuredetector "github.com/aQuaYi/Distributed-Algorithms/Failure-Detector/code"
+	reliablechannel "github.com/aQuaYi/Distributed-Algorithms/Reliable-Channel/code"
+	"github.com/stretchr/testify/assert"
+)
+
+var fdConfig = failuredetector.Config{
+	Interval:  5 * time.Millisecond,
+	Timeout:   50 * time.Millisecond,
+	Increment: 50 * time.Millisecond,
+}
+
+// waitDone 等待 c 中所有 total 个任务都完成，返回是否在 timeout 之内完成
+func waitDone(c *Cluster, total int, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if c.Stats().Done == total {
+			return true
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return false
+}
+
+func Test_Worker_crashRedelivery(t *testing.T) {
+	ast := assert.New(t)
+	//
+	// 可见性超时很长，只有故障检测器才能及时地重新投递崩溃的 worker 的任务
+	c := MakeCluster(3, time.Minute)
+	defer c.Cleanup()
+	net := failuredetector.NewNetwork(reliablechannel.Uniform{Max: time.Millisecond}, 1)
+	const monitor, total = 0, 30
+	producer := c.MakeClient()
+	for i := 0; i < total; i++ {
+		producer.Push(fmt.Sprint(i))
+	}
+	//
+	var mutex sync.Mutex
+	processed := make(map[string]int)
+	stuck := make(chan Task, 1)
+	handler := func(id int) func(Task) {
+		return func(task Task) {
+			if id == 1 && len(stuck) == 0 {
+				// worker 1 在处理第一个任务时卡住，随后崩溃
+				stuck <- task
+				select {}
+			}
+			mutex.Lock()
+			processed[task.Body]++
+			mutex.Unlock()
+		}
+	}
+	ids := []int{1, 2, 3}
+	m := StartMonitor(monitor, ids, c.MakeClient(), net, fdConfig)
+	defer m.Stop()
+	workers := make([]*Worker, len(ids))
+	for i, id := range ids {
+		workers[i] = StartWorker(id, c.MakeClient(), net, monitor, fdConfig, handler(id))
+	}
+	lost := <-stuck
+	workers[0].Crash()
+	//
+	start := time.Now()
+	ast.True(waitDone(c, total, 10*time.Second))
+	t.Logf("worker 崩溃以后 %v 内完成了所有任务", time.Since(start))
+	for _, w := range workers[1:] {
+		w.Stop()
+	}
+	// 每个任务都被处理了，卡住的任务被重新投递给了其他 worker
+	mutex.Lock()
+	for i := 0; i < total; i++ {
+		ast.Equal(1, processed[fmt.Sprint(i)], "%d", i)
+	}
+	mutex.Unlock()
+	st := c.Stats()
+	ast.Equal(Stats{Done: total, Redelivered: st.Redelivered}, st)
+	ast.True(st.Redelivered >= 1)
+	ast.True(m.Releases() >= 1)
+	ast.NotEmpty(lost.Body)
+}
+
+func Test_Worker_slowWorkerIsFenced(t *testing.T) {
+	ast := assert.New(t)
+	//
+	// 可见性超时比 worker 处理任务的时间短，任务被处理了两次，但只有一次 Ack 会成功
+	visibility := 200 * time.Millisecond
+	c := MakeCluster(3, visibility)
+	defer c.Cleanup()
+	net := failuredetector.NewNetwork(reliablechannel.Uniform{Max: time.Millisecond}, 1)
+	c.MakeClient().Push("slow")
+	//
+	var mutex sync.Mutex
+	runs := 0
+	handler := func(task Task) {
+		mutex.Lock()
+		runs++
+		first := runs == 1
+		mutex.Unlock()
+		if first {
+			time.Sleep(3 * visibility)
+		}
+	}
+	a := StartWorker(1, c.MakeClient(), net, 0, fdConfig, handler)
+	time.Sleep(50 * time.Millisecond)
+	b := StartWorker(2, c.MakeClient(), net, 0, fdConfig, handler)
+	ast.True(waitDone(c, 1, 5*time.Second))
+	time.Sleep(3 * visibility)
+	a.Stop()
+	b.Stop()
+	//
+	mutex.Lock()
+	ast.Equal(2, runs, "at-least-once：任务被处理了两次")
+	mutex.Unlock()
+	ast.Equal(1, a.Stale()+b.Stale(), "过期的 lease 无法完成任务")
+	ast.Equal(Stats{Done: 1, Redelivered: 1}, c.Stats())
+}
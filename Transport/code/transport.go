@@ -0,0 +1,231 @@
+package transport
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/rpc"
+	"reflect"
+	"sync"
+
+	codec "github.com/aQuaYi/Distributed-Algorithms/Codec/code"
+)
+
+// ErrUnreachable 表示无法联系上对方
+var ErrUnreachable = errors.New("transport: peer is unreachable")
+
+// Transport 负责 peer 之间的 RPC
+// SimNetwork 在同一个进程内模拟网络，TCPTransport 通过真实的网络通信
+type Transport interface {
+	// Register 让 addr 上的 RPC 都交给 s 处理，返回真实的地址
+	// s 的方法需要符合 net/rpc 的要求
+	Register(addr string, s interface{}) (string, error)
+	// Unregister 让 addr 离开网络
+	Unregister(addr string)
+	// Call 调用 addr 上的 method 方法
+	Call(addr, method string, args, reply interface{}) error
+}
+
+// SimNetwork 是在进程内模拟的网络，可以随时让 peer 失效
+type SimNetwork struct {
+	mutex    sync.Mutex
+	services map[string]interface{}
+	calls    int
+}
+
+// NewSimNetwork 返回一个模拟网络
+func NewSimNetwork() *SimNetwork {
+	return &SimNetwork{
+		services: make(map[string]interface{}, 64),
+	}
+}
+
+// Register 实现了 Transport 接口
+func (sn *SimNetwork) Register(addr string, s interface{}) (string, error) {
+	sn.mutex.Lock()
+	defer sn.mutex.Unlock()
+	if _, ok := sn.services[addr]; ok {
+		return "", fmt.Errorf("transport: address %s is already in use", addr)
+	}
+	sn.services[addr] = s
+	return addr, nil
+}
+
+// Unregister 实现了 Transport 接口
+func (sn *SimNetwork) Unregister(addr string) {
+	sn.mutex.Lock()
+	delete(sn.services, addr)
+	sn.mutex.Unlock()
+}
+
+// Call 实现了 Transport 接口
+// 利用反射直接调用对方注册的 service 的方法
+func (sn *SimNetwork) Call(addr, method string, args, reply interface{}) error {
+	sn.mutex.Lock()
+	s, ok := sn.services[addr]
+	sn.calls++
+	sn.mutex.Unlock()
+
+	if !ok {
+		return ErrUnreachable
+	}
+
+	fn := reflect.ValueOf(s).MethodByName(method)
+	if !fn.IsValid() {
+		return fmt.Errorf("transport: unknown method %s", method)
+	}
+	res := fn.Call([]reflect.Value{reflect.ValueOf(args), reflect.ValueOf(reply)})
+	if err, _ := res[0].Interface().(error); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Calls 返回网络中发生过的 RPC 次数
+func (sn *SimNetwork) Calls() int {
+	sn.mutex.Lock()
+	defer sn.mutex.Unlock()
+	return sn.calls
+}
+
+// TCPTransport 利用 net/rpc 在 TCP 上通信
+// 所有的 service 都以同一个名字注册，method 会被加上这个名字作为前缀
+type TCPTransport struct {
+	name      string
+	opts      TCPOptions
+	mutex     sync.Mutex
+	listeners map[string]net.Listener
+	clients   map[string]*rpc.Client
+}
+
+// TCPOptions 是 TCPTransport 的可选设置，零值就是 net/rpc 默认的 gob 编码
+type TCPOptions struct {
+	// Codec 不为 nil 时，按照它压缩每条消息，通信双方都需要设置
+	Codec *codec.Policy
+	// TLS 不为 nil 时，所有的连接都使用 TLS，它同时用于监听和拨号
+	// 设置了 ClientCAs 和 tls.RequireAndVerifyClientCert 时，就是双向 TLS
+	TLS *tls.Config
+}
+
+// NewTCPTransport 返回一个以 name 注册 service 的 TCPTransport，例如 "Chord"
+func NewTCPTransport(name string) *TCPTransport {
+	return NewTCPTransportWithOptions(name, TCPOptions{})
+}
+
+// NewTCPTransportWithOptions 与 NewTCPTransport 一样，但是会启用 opts 中的设置
+func NewTCPTransportWithOptions(name string, opts TCPOptions) *TCPTransport {
+	return &TCPTransport{
+		name:      name,
+		opts:      opts,
+		listeners: make(map[string]net.Listener, 4),
+		clients:   make(map[string]*rpc.Client, 64),
+	}
+}
+
+// Register 实现了 Transport 接口
+// addr 的端口为 0 时，由系统分配端口，返回值是真实监听的地址
+func (t *TCPTransport) Register(addr string, s interface{}) (string, error) {
+	server := rpc.NewServer()
+	if err := server.RegisterName(t.name, s); err != nil {
+		return "", err
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", err
+	}
+	actual := l.Addr().String()
+	if t.opts.TLS != nil {
+		l = tls.NewListener(l, t.opts.TLS)
+	}
+
+	t.mutex.Lock()
+	t.listeners[actual] = l
+	t.mutex.Unlock()
+
+	go t.accept(server, l)
+
+	return actual, nil
+}
+
+func (t *TCPTransport) accept(server *rpc.Server, l net.Listener) {
+	if t.opts.Codec == nil {
+		server.Accept(l)
+		return
+	}
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go server.ServeCodec(codec.NewServerCodec(conn, t.opts.Codec))
+	}
+}
+
+// Unregister 实现了 Transport 接口
+func (t *TCPTransport) Unregister(addr string) {
+	t.mutex.Lock()
+	l, ok := t.listeners[addr]
+	delete(t.listeners, addr)
+	t.mutex.Unlock()
+	if ok {
+		l.Close()
+	}
+}
+
+// Call 实现了 Transport 接口
+func (t *TCPTransport) Call(addr, method string, args, reply interface{}) error {
+	c, err := t.client(addr)
+	if err != nil {
+		return ErrUnreachable
+	}
+	err = c.Call(t.name+"."+method, args, reply)
+	if err == rpc.ErrShutdown {
+		t.mutex.Lock()
+		delete(t.clients, addr)
+		t.mutex.Unlock()
+		return ErrUnreachable
+	}
+	return err
+}
+
+func (t *TCPTransport) client(addr string) (*rpc.Client, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if c, ok := t.clients[addr]; ok {
+		return c, nil
+	}
+	conn, err := t.dial(addr)
+	if err != nil {
+		return nil, err
+	}
+	var c *rpc.Client
+	if t.opts.Codec == nil {
+		c = rpc.NewClient(conn)
+	} else {
+		c = rpc.NewClientWithCodec(codec.NewClientCodec(conn, t.opts.Codec))
+	}
+	t.clients[addr] = c
+	return c, nil
+}
+
+func (t *TCPTransport) dial(addr string) (net.Conn, error) {
+	if t.opts.TLS == nil {
+		return net.Dial("tcp", addr)
+	}
+	return tls.Dial("tcp", addr, t.opts.TLS)
+}
+
+// Close 关闭所有的连接和监听
+func (t *TCPTransport) Close() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for addr, c := range t.clients {
+		c.Close()
+		delete(t.clients, addr)
+	}
+	for addr, l := range t.listeners {
+		l.Close()
+		delete(t.listeners, addr)
+	}
+}
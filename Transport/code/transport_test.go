@@ -0,0 +1,69 @@
+package transport
+
+import (
+	"errors"
+	"testing"
+
+	codec "github.com/aQuaYi/Distributed-Algorithms/Codec/code"
+	"github.com/stretchr/testify/assert"
+)
+
+// Echo 是测试用的 service
+type Echo struct{}
+
+func (e *Echo) Echo(args *string, reply *string) error {
+	*reply = *args
+	return nil
+}
+
+func (e *Echo) Fail(args *string, reply *string) error {
+	return errors.New(*args)
+}
+
+func Test_SimNetwork(t *testing.T) {
+	ast := assert.New(t)
+	//
+	sn := NewSimNetwork()
+	addr, err := sn.Register("a", &Echo{})
+	ast.Nil(err)
+	ast.Equal("a", addr)
+	_, err = sn.Register("a", &Echo{})
+	ast.NotNil(err)
+	//
+	args, reply := "hello", ""
+	ast.Nil(sn.Call("a", "Echo", &args, &reply))
+	ast.Equal("hello", reply)
+	ast.Equal("hello", sn.Call("a", "Fail", &args, &reply).Error())
+	ast.NotNil(sn.Call("a", "Missing", &args, &reply))
+	// 离开网络以后就无法联系上了
+	sn.Unregister("a")
+	ast.Equal(ErrUnreachable, sn.Call("a", "Echo", &args, &reply))
+	ast.Equal(4, sn.Calls())
+}
+
+func Test_TCPTransport(t *testing.T) {
+	ast := assert.New(t)
+	//
+	var stats codec.Stats
+	tp := NewTCPTransportWithOptions("Echo", TCPOptions{
+		Codec: &codec.Policy{Default: codec.Snappy, Stats: &stats},
+	})
+	defer tp.Close()
+	addr, err := tp.Register("127.0.0.1:0", &Echo{})
+	ast.Nil(err)
+	ast.NotEqual("127.0.0.1:0", addr)
+	//
+	args, reply := "hello", ""
+	ast.Nil(tp.Call(addr, "Echo", &args, &reply))
+	ast.Equal("hello", reply)
+	ast.NotNil(tp.Call(addr, "Fail", &args, &reply))
+	ast.True(stats.Snapshot().Messages > 0)
+	// 以其他名字注册的 service 无法处理这个请求
+	other := NewTCPTransport("Other")
+	defer other.Close()
+	ast.NotNil(other.Call(addr, "Echo", &args, &reply))
+	//
+	tp.Unregister(addr)
+	tp.Close()
+	ast.Equal(ErrUnreachable, tp.Call(addr, "Echo", &args, &reply))
+}
@@ -0,0 +1,293 @@
+package wal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"sync"
+)
+
+// 文件头的格式是 | magic [4]byte | first uint64 | crc32 uint32 |
+const fileHeaderSize = 16
+
+var magic = []byte("WAL\x01")
+
+// FileLog 是保存在 Storage 中单个文件里的 Log
+// 打开文件时，会逐条检查记录的校验和，从第一条不完整或者损坏的记录开始，
+// 后面的内容都被认为是崩溃时没有写完的，会被截掉。
+// TruncateFront 和 TruncateBack 返回时，修改已经写入了 stable storage，
+// 否则崩溃以后，已经被删除的记录可能会重新出现。
+type FileLog struct {
+	mutex   sync.Mutex
+	storage Storage
+	path    string
+	file    File
+	first   uint64
+	offsets []int64 // 每条记录在文件中的起始位置
+	size    int64   // 文件的大小，也是下一条记录的起始位置
+}
+
+// Open 打开 path 处的 FileLog，文件不存在时，会创建一个空的 log
+func Open(path string) (*FileLog, error) {
+	return OpenStorage(osStorage{}, path)
+}
+
+// OpenStorage 打开 s 中名为 name 的 FileLog，文件不存在时，会创建一个空的 log
+func OpenStorage(s Storage, name string) (*FileLog, error) {
+	buf, err := s.ReadFile(name)
+	if os.IsNotExist(err) {
+		if err = writeFile(s, name, 1, nil); err == nil {
+			buf, err = s.ReadFile(name)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	first, ok := decodeHeader(buf)
+	if !ok {
+		return nil, ErrCorrupt
+	}
+	l := &FileLog{
+		storage: s,
+		path:    name,
+		first:   first,
+		size:    fileHeaderSize,
+	}
+	for {
+		_, size, ok := decodeRecord(buf[l.size:])
+		if !ok {
+			break
+		}
+		l.offsets = append(l.offsets, l.size)
+		l.size += int64(size)
+	}
+	if l.file, err = s.Open(name); err != nil {
+		return nil, err
+	}
+	if l.size < int64(len(buf)) {
+		// 截掉崩溃时没有写完的记录
+		if err := l.truncate(l.size); err != nil {
+			l.file.Close()
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+func encodeHeader(first uint64) []byte {
+	buf := make([]byte, fileHeaderSize)
+	copy(buf, magic)
+	binary.BigEndian.PutUint64(buf[4:12], first)
+	binary.BigEndian.PutUint32(buf[12:16], crc32.Checksum(buf[:12], crcTable))
+	return buf
+}
+
+func decodeHeader(buf []byte) (first uint64, ok bool) {
+	if len(buf) < fileHeaderSize ||
+		!bytes.Equal(buf[:4], magic) ||
+		crc32.Checksum(buf[:12], crcTable) != binary.BigEndian.Uint32(buf[12:16]) {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(buf[4:12]), true
+}
+
+// writeFile 用 first 和 records 生成新的 log 文件，原子地替换掉 s 中名为 name 的文件
+func writeFile(s Storage, name string, first uint64, records []byte) error {
+	tmp := name + ".tmp"
+	f, err := s.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err = f.WriteAt(append(encodeHeader(first), records...), 0); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		s.Remove(tmp)
+		return err
+	}
+	return s.Rename(tmp, name)
+}
+
+// Append 在末尾添加一条记录，并返回它的 index
+func (l *FileLog) Append(data []byte) (uint64, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.file == nil {
+		return 0, ErrClosed
+	}
+	record := encodeRecord(data)
+	if _, err := l.file.WriteAt(record, l.size); err != nil {
+		return 0, err
+	}
+	l.offsets = append(l.offsets, l.size)
+	l.size += int64(len(record))
+	return l.lastIndex(), nil
+}
+
+// Sync 把已经 Append 的记录写入 stable storage
+func (l *FileLog) Sync() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.file == nil {
+		return ErrClosed
+	}
+	return l.file.Sync()
+}
+
+// FirstIndex 返回第一条记录的 index
+func (l *FileLog) FirstIndex() uint64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.first
+}
+
+// LastIndex 返回最后一条记录的 index
+func (l *FileLog) LastIndex() uint64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.lastIndex()
+}
+
+func (l *FileLog) lastIndex() uint64 {
+	return l.first + uint64(len(l.offsets)) - 1
+}
+
+// Read 返回 index 处的记录
+func (l *FileLog) Read(index uint64) ([]byte, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.read(index)
+}
+
+func (l *FileLog) read(index uint64) ([]byte, error) {
+	if l.file == nil {
+		return nil, ErrClosed
+	}
+	if index < l.first || index > l.lastIndex() {
+		return nil, ErrOutOfRange
+	}
+	i := index - l.first
+	end := l.size
+	if int(i)+1 < len(l.offsets) {
+		end = l.offsets[i+1]
+	}
+	buf := make([]byte, end-l.offsets[i])
+	if _, err := l.file.ReadAt(buf, l.offsets[i]); err != nil {
+		return nil, err
+	}
+	data, _, ok := decodeRecord(buf)
+	if !ok {
+		return nil, ErrCorrupt
+	}
+	return data, nil
+}
+
+// Iterate 从 from 开始，按顺序把记录交给 fn
+func (l *FileLog) Iterate(from uint64, fn func(index uint64, data []byte) error) error {
+	l.mutex.Lock()
+	if l.file != nil && (from < l.first || from > l.lastIndex()+1) {
+		l.mutex.Unlock()
+		return ErrOutOfRange
+	}
+	l.mutex.Unlock()
+
+	for index := from; ; index++ {
+		// fn 中可能会再次调用 l 的方法，所以每次只在读取时加锁
+		l.mutex.Lock()
+		if l.file != nil && index > l.lastIndex() {
+			l.mutex.Unlock()
+			return nil
+		}
+		data, err := l.read(index)
+		l.mutex.Unlock()
+		if err != nil {
+			return err
+		}
+		if err := fn(index, data); err != nil {
+			return err
+		}
+	}
+}
+
+// TruncateFront 删除 index 之前的所有记录
+// 剩下的记录会被复制到新的文件中，再替换掉原来的文件
+func (l *FileLog) TruncateFront(index uint64) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.file == nil {
+		return ErrClosed
+	}
+	if index < l.first || index > l.lastIndex()+1 {
+		return ErrOutOfRange
+	}
+	start := l.size
+	if index <= l.lastIndex() {
+		start = l.offsets[index-l.first]
+	}
+	records := make([]byte, l.size-start)
+	if _, err := l.file.ReadAt(records, start); err != nil {
+		return err
+	}
+	if err := writeFile(l.storage, l.path, index, records); err != nil {
+		return err
+	}
+	file, err := l.storage.Open(l.path)
+	if err != nil {
+		return err
+	}
+	l.file.Close()
+	l.file = file
+	offsets := l.offsets[index-l.first:]
+	l.offsets = make([]int64, len(offsets))
+	for i, offset := range offsets {
+		l.offsets[i] = offset - start + fileHeaderSize
+	}
+	l.first = index
+	l.size = int64(len(records)) + fileHeaderSize
+	return nil
+}
+
+// TruncateBack 删除 index 之后的所有记录
+func (l *FileLog) TruncateBack(index uint64) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.file == nil {
+		return ErrClosed
+	}
+	if index+1 < l.first || index > l.lastIndex() {
+		return ErrOutOfRange
+	}
+	if index == l.lastIndex() {
+		return nil
+	}
+	size := l.offsets[index+1-l.first]
+	if err := l.truncate(size); err != nil {
+		return err
+	}
+	l.offsets = l.offsets[:index+1-l.first]
+	l.size = size
+	return nil
+}
+
+func (l *FileLog) truncate(size int64) error {
+	if err := l.file.Truncate(size); err != nil {
+		return err
+	}
+	return l.file.Sync()
+}
+
+// Close 关闭 log，没有 Sync 的记录不一定会被保存下来
+func (l *FileLog) Close() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.file == nil {
+		return ErrClosed
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
@@ -0,0 +1,97 @@
+package wal
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func openTemp(t *testing.T) (*FileLog, string) {
+	path := filepath.Join(t.TempDir(), "wal")
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l, path
+}
+
+func reopen(t *testing.T, path string) *FileLog {
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func Test_FileLog_reopen(t *testing.T) {
+	ast := assert.New(t)
+	//
+	l, path := openTemp(t)
+	appendN(l, 1, 5)
+	ast.NoError(l.TruncateFront(2))
+	ast.NoError(l.TruncateBack(4))
+	appendN(l, 5, 6)
+	ast.NoError(l.Sync())
+	ast.NoError(l.Close())
+	//
+	l = reopen(t, path)
+	defer l.Close()
+	ast.Equal(uint64(2), l.FirstIndex())
+	ast.Equal(uint64(6), l.LastIndex())
+	ast.Equal([]string{"2:record 2", "3:record 3", "4:record 4", "5:record 5", "6:record 6"}, collect(l, 2))
+}
+
+func Test_FileLog_tornTail(t *testing.T) {
+	ast := assert.New(t)
+	//
+	l, path := openTemp(t)
+	appendN(l, 1, 3)
+	ast.NoError(l.Close())
+	// 模拟崩溃时，第 3 条记录只写了一半
+	info, _ := os.Stat(path)
+	ast.NoError(os.Truncate(path, info.Size()-3))
+	//
+	l = reopen(t, path)
+	ast.Equal(uint64(2), l.LastIndex())
+	// 半条记录已经从文件中删除了，新的记录可以正常地添加
+	appendN(l, 3, 4)
+	ast.NoError(l.Close())
+	l = reopen(t, path)
+	defer l.Close()
+	ast.Equal([]string{"1:record 1", "2:record 2", "3:record 3", "4:record 4"}, collect(l, 1))
+}
+
+func Test_FileLog_corruptRecord(t *testing.T) {
+	ast := assert.New(t)
+	//
+	l, path := openTemp(t)
+	appendN(l, 1, 3)
+	ast.NoError(l.Close())
+	// 破坏第 2 条记录的内容，它和它后面的记录都不能再被信任
+	buf, _ := ioutil.ReadFile(path)
+	record := fileHeaderSize + recordHeaderSize + len("record 1")
+	buf[record+recordHeaderSize] ^= 0xff
+	ast.NoError(ioutil.WriteFile(path, buf, 0644))
+	//
+	l = reopen(t, path)
+	defer l.Close()
+	ast.Equal(uint64(1), l.LastIndex())
+	ast.Equal([]string{"1:record 1"}, collect(l, 1))
+}
+
+func Test_FileLog_corruptHeader(t *testing.T) {
+	ast := assert.New(t)
+	//
+	l, path := openTemp(t)
+	appendN(l, 1, 3)
+	ast.NoError(l.Close())
+	buf, _ := ioutil.ReadFile(path)
+	buf[5] ^= 0xff
+	ast.NoError(ioutil.WriteFile(path, buf, 0644))
+	//
+	_, err := Open(path)
+	ast.Equal(ErrCorrupt, err)
+}
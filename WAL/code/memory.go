@@ -0,0 +1,132 @@
+package wal
+
+import "sync"
+
+// MemoryLog 是保存在内存中的 Log，Sync 什么也不做
+// 它适合不关心崩溃恢复的测试，也是检验 FileLog 行为的参照
+type MemoryLog struct {
+	mutex   sync.Mutex
+	first   uint64
+	records [][]byte
+	closed  bool
+}
+
+// NewMemoryLog 返回空的 MemoryLog
+func NewMemoryLog() *MemoryLog {
+	return &MemoryLog{first: 1}
+}
+
+// Append 在末尾添加一条记录，并返回它的 index
+func (l *MemoryLog) Append(data []byte) (uint64, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.closed {
+		return 0, ErrClosed
+	}
+	l.records = append(l.records, append([]byte(nil), data...))
+	return l.lastIndex(), nil
+}
+
+// Sync 把已经 Append 的记录写入 stable storage
+func (l *MemoryLog) Sync() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.closed {
+		return ErrClosed
+	}
+	return nil
+}
+
+// FirstIndex 返回第一条记录的 index
+func (l *MemoryLog) FirstIndex() uint64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.first
+}
+
+// LastIndex 返回最后一条记录的 index
+func (l *MemoryLog) LastIndex() uint64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.lastIndex()
+}
+
+func (l *MemoryLog) lastIndex() uint64 {
+	return l.first + uint64(len(l.records)) - 1
+}
+
+// Read 返回 index 处的记录
+func (l *MemoryLog) Read(index uint64) ([]byte, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.closed {
+		return nil, ErrClosed
+	}
+	if index < l.first || index > l.lastIndex() {
+		return nil, ErrOutOfRange
+	}
+	return append([]byte(nil), l.records[index-l.first]...), nil
+}
+
+// Iterate 从 from 开始，按顺序把记录交给 fn
+func (l *MemoryLog) Iterate(from uint64, fn func(index uint64, data []byte) error) error {
+	l.mutex.Lock()
+	if l.closed {
+		l.mutex.Unlock()
+		return ErrClosed
+	}
+	if from < l.first || from > l.lastIndex()+1 {
+		l.mutex.Unlock()
+		return ErrOutOfRange
+	}
+	// fn 中可能会再次调用 l 的方法，所以先复制出来再解锁
+	records := append([][]byte(nil), l.records[from-l.first:]...)
+	l.mutex.Unlock()
+
+	for i, data := range records {
+		if err := fn(from+uint64(i), append([]byte(nil), data...)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TruncateFront 删除 index 之前的所有记录
+func (l *MemoryLog) TruncateFront(index uint64) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.closed {
+		return ErrClosed
+	}
+	if index < l.first || index > l.lastIndex()+1 {
+		return ErrOutOfRange
+	}
+	l.records = append([][]byte(nil), l.records[index-l.first:]...)
+	l.first = index
+	return nil
+}
+
+// TruncateBack 删除 index 之后的所有记录
+func (l *MemoryLog) TruncateBack(index uint64) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.closed {
+		return ErrClosed
+	}
+	if index+1 < l.first || index > l.lastIndex() {
+		return ErrOutOfRange
+	}
+	l.records = l.records[:index+1-l.first]
+	return nil
+}
+
+// Close 关闭 log
+func (l *MemoryLog) Close() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.closed {
+		return ErrClosed
+	}
+	l.closed = true
+	return nil
+}
@@ -0,0 +1,207 @@
+package wal
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"os"
+	"sync"
+)
+
+// FaultModel 描述了 SimStorage 崩溃时，没有 Sync 的数据会变成什么样
+// 零值表示没有 Sync 的数据全部丢失
+type FaultModel struct {
+	// TornWrites 为 true 时，没有 Sync 的数据可能有一部分已经写入了，
+	// 崩溃后会保留随机长度的前缀，最后一条记录可能只有一半
+	TornWrites bool
+	// CorruptRate 是保留下来的没有 Sync 的数据中，有一个字节被损坏的概率
+	CorruptRate float64
+}
+
+// simFile 是 SimStorage 中的一个文件
+type simFile struct {
+	data    []byte // 当前的内容
+	durable []byte // 上一次 Sync 时的内容，崩溃后至少会保留这些
+}
+
+// SimStorage 是模拟的 stable storage，可以按照 FaultModel 模拟崩溃
+// 创建、删除和 rename 文件都会立即持久化，只有文件的内容需要 Sync。
+// 用 OpenStorage 在崩溃后的 SimStorage 上重新打开 FileLog，就可以检验恢复的过程。
+type SimStorage struct {
+	mutex  sync.Mutex
+	faults FaultModel
+	rand   *rand.Rand
+	files  map[string]*simFile
+	epoch  int // 每次崩溃都会加 1，让之前打开的文件失效
+}
+
+// NewSimStorage 返回空的 SimStorage，崩溃时的随机选择由 seed 决定
+func NewSimStorage(faults FaultModel, seed int64) *SimStorage {
+	return &SimStorage{
+		faults: faults,
+		rand:   rand.New(rand.NewSource(seed)),
+		files:  make(map[string]*simFile),
+	}
+}
+
+// Crash 模拟一次崩溃
+// 每个文件只保留上次 Sync 时的内容，以及按照 FaultModel 保留下来的一部分没有 Sync 的数据
+func (s *SimStorage) Crash() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.epoch++
+	for _, f := range s.files {
+		kept := append([]byte(nil), f.durable...)
+		// 只有追加写入的数据，才可能部分写入
+		if s.faults.TornWrites && bytes.HasPrefix(f.data, f.durable) {
+			unsynced := f.data[len(f.durable):]
+			tail := append([]byte(nil), unsynced[:s.rand.Intn(len(unsynced)+1)]...)
+			if len(tail) > 0 && s.rand.Float64() < s.faults.CorruptRate {
+				tail[s.rand.Intn(len(tail))] ^= byte(1 + s.rand.Intn(255))
+			}
+			kept = append(kept, tail...)
+		}
+		f.data = kept
+		f.durable = append([]byte(nil), kept...)
+	}
+}
+
+// Create 创建一个空文件，文件已经存在时，清空它
+func (s *SimStorage) Create(name string) (File, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	f := &simFile{}
+	s.files[name] = f
+	return &simHandle{storage: s, file: f, epoch: s.epoch}, nil
+}
+
+// Open 打开已经存在的文件
+func (s *SimStorage) Open(name string) (File, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	f, ok := s.files[name]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return &simHandle{storage: s, file: f, epoch: s.epoch}, nil
+}
+
+// ReadFile 返回文件的全部内容
+func (s *SimStorage) ReadFile(name string) ([]byte, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	f, ok := s.files[name]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return append([]byte(nil), f.data...), nil
+}
+
+// Rename 用 oldname 替换 newname
+// 与 POSIX 一样，已经打开的文件仍然指向原来的内容
+func (s *SimStorage) Rename(oldname, newname string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	f, ok := s.files[oldname]
+	if !ok {
+		return os.ErrNotExist
+	}
+	delete(s.files, oldname)
+	s.files[newname] = f
+	return nil
+}
+
+// Remove 删除文件
+func (s *SimStorage) Remove(name string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.files[name]; !ok {
+		return os.ErrNotExist
+	}
+	delete(s.files, name)
+	return nil
+}
+
+// simHandle 是 SimStorage 中打开的文件
+type simHandle struct {
+	storage *SimStorage
+	file    *simFile
+	epoch   int
+	closed  bool
+}
+
+// check 在 h.storage.mutex 的保护下，检查 h 是否还能使用
+func (h *simHandle) check() error {
+	if h.closed {
+		return os.ErrClosed
+	}
+	if h.epoch != h.storage.epoch {
+		return ErrCrashed
+	}
+	return nil
+}
+
+func (h *simHandle) ReadAt(p []byte, off int64) (int, error) {
+	h.storage.mutex.Lock()
+	defer h.storage.mutex.Unlock()
+	if err := h.check(); err != nil {
+		return 0, err
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if off >= int64(len(h.file.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, h.file.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (h *simHandle) WriteAt(p []byte, off int64) (int, error) {
+	h.storage.mutex.Lock()
+	defer h.storage.mutex.Unlock()
+	if err := h.check(); err != nil {
+		return 0, err
+	}
+	if end := off + int64(len(p)); end > int64(len(h.file.data)) {
+		h.file.data = append(h.file.data, make([]byte, end-int64(len(h.file.data)))...)
+	}
+	return copy(h.file.data[off:], p), nil
+}
+
+func (h *simHandle) Truncate(size int64) error {
+	h.storage.mutex.Lock()
+	defer h.storage.mutex.Unlock()
+	if err := h.check(); err != nil {
+		return err
+	}
+	if size < int64(len(h.file.data)) {
+		h.file.data = h.file.data[:size]
+	} else {
+		h.file.data = append(h.file.data, make([]byte, size-int64(len(h.file.data)))...)
+	}
+	return nil
+}
+
+func (h *simHandle) Sync() error {
+	h.storage.mutex.Lock()
+	defer h.storage.mutex.Unlock()
+	if err := h.check(); err != nil {
+		return err
+	}
+	h.file.durable = append([]byte(nil), h.file.data...)
+	return nil
+}
+
+func (h *simHandle) Close() error {
+	h.storage.mutex.Lock()
+	defer h.storage.mutex.Unlock()
+	if h.closed {
+		return os.ErrClosed
+	}
+	h.closed = true
+	return nil
+}
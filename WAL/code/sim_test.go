@@ -0,0 +1,82 @@
+package wal
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func openSim(t *testing.T, s *SimStorage) *FileLog {
+	l, err := OpenStorage(s, "wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func Test_SimStorage_loseUnsynced(t *testing.T) {
+	ast := assert.New(t)
+	//
+	s := NewSimStorage(FaultModel{}, 0)
+	l := openSim(t, s)
+	appendN(l, 1, 3)
+	ast.NoError(l.Sync())
+	appendN(l, 4, 5)
+	s.Crash()
+	// 崩溃前打开的 log 不能再使用
+	_, err := l.Append(nil)
+	ast.Equal(ErrCrashed, err)
+	ast.Equal(ErrCrashed, l.Sync())
+	//
+	l = openSim(t, s)
+	ast.Equal(uint64(3), l.LastIndex())
+	ast.Equal([]string{"1:record 1", "2:record 2", "3:record 3"}, collect(l, 1))
+}
+
+func Test_SimStorage_truncateIsDurable(t *testing.T) {
+	ast := assert.New(t)
+	//
+	s := NewSimStorage(FaultModel{TornWrites: true}, 0)
+	l := openSim(t, s)
+	appendN(l, 1, 5)
+	ast.NoError(l.TruncateFront(2))
+	ast.NoError(l.TruncateBack(3))
+	s.Crash()
+	//
+	l = openSim(t, s)
+	ast.Equal(uint64(2), l.FirstIndex())
+	ast.Equal(uint64(3), l.LastIndex())
+}
+
+func Test_SimStorage_tornWrites(t *testing.T) {
+	ast := assert.New(t)
+	//
+	synced, unsynced := 5, 5
+	counts := make(map[int]int)
+	for seed := int64(0); seed < 200; seed++ {
+		s := NewSimStorage(FaultModel{TornWrites: true, CorruptRate: 0.5}, seed)
+		l := openSim(t, s)
+		appendN(l, 1, synced)
+		ast.NoError(l.Sync())
+		appendN(l, synced+1, synced+unsynced)
+		s.Crash()
+		//
+		l = openSim(t, s)
+		last := int(l.LastIndex())
+		counts[last]++
+		// 恢复出来的一定是完整的前缀，并且包含所有 Sync 过的记录
+		ast.True(synced <= last && last <= synced+unsynced, fmt.Sprintf("seed %d: last = %d", seed, last))
+		for i, r := range collect(l, 1) {
+			ast.Equal(fmt.Sprintf("%d:record %d", i+1, i+1), r)
+		}
+		// 截掉损坏的部分以后，可以继续写入
+		index, err := l.Append([]byte("after crash"))
+		ast.NoError(err)
+		ast.Equal(uint64(last+1), index)
+	}
+	// 没有 Sync 的记录，有时全部丢失，有时全部保留，有时保留一部分
+	ast.True(counts[synced] > 0)
+	ast.True(counts[synced+unsynced] > 0)
+	ast.True(len(counts) > 2)
+}
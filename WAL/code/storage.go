@@ -0,0 +1,63 @@
+package wal
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// File 是 Storage 中打开的文件，*os.File 实现了这个接口
+type File interface {
+	ReadAt(p []byte, off int64) (int, error)
+	WriteAt(p []byte, off int64) (int, error)
+	Truncate(size int64) error
+	// Sync 返回以后，文件的内容才会在崩溃后保留下来
+	Sync() error
+	Close() error
+}
+
+// Storage 是 FileLog 使用的 stable storage
+type Storage interface {
+	// Create 创建一个空文件，文件已经存在时，清空它
+	Create(name string) (File, error)
+	// Open 打开已经存在的文件
+	Open(name string) (File, error)
+	// ReadFile 返回文件的全部内容，文件不存在时，返回的错误满足 os.IsNotExist
+	ReadFile(name string) ([]byte, error)
+	// Rename 原子地用 oldname 替换 newname，返回以后，替换的结果会在崩溃后保留下来
+	Rename(oldname, newname string) error
+	// Remove 删除文件
+	Remove(name string) error
+}
+
+// osStorage 是操作系统的文件系统
+type osStorage struct{}
+
+func (osStorage) Create(name string) (File, error) {
+	return os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+}
+
+func (osStorage) Open(name string) (File, error) {
+	return os.OpenFile(name, os.O_RDWR, 0644)
+}
+
+func (osStorage) ReadFile(name string) ([]byte, error) {
+	return ioutil.ReadFile(name)
+}
+
+func (osStorage) Rename(oldname, newname string) error {
+	if err := os.Rename(oldname, newname); err != nil {
+		return err
+	}
+	// 让目录中 rename 的结果也写入 stable storage
+	d, err := os.Open(filepath.Dir(newname))
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+func (osStorage) Remove(name string) error {
+	return os.Remove(name)
+}
@@ -0,0 +1,74 @@
+package wal
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+)
+
+// 可能返回的错误
+var (
+	// ErrClosed 表示 log 已经被关闭了
+	ErrClosed = errors.New("wal: log is closed")
+	// ErrOutOfRange 表示 index 不在 [FirstIndex, LastIndex] 的范围内
+	ErrOutOfRange = errors.New("wal: index out of range")
+	// ErrCorrupt 表示 log 文件的头部已经损坏，无法恢复
+	ErrCorrupt = errors.New("wal: log file is corrupt")
+	// ErrCrashed 表示 SimStorage 崩溃了，崩溃前打开的文件都不能再使用
+	ErrCrashed = errors.New("wal: storage has crashed")
+)
+
+// Log 是 write-ahead log 的接口
+// 记录的 index 从 1 开始连续编号，删除前面的记录以后，剩下记录的 index 不变。
+// Append 返回以后，记录不一定已经写入了 stable storage，只有 Sync 成功返回以后，
+// 之前 Append 的记录才能在崩溃后被恢复。
+type Log interface {
+	// Append 在末尾添加一条记录，并返回它的 index
+	Append(data []byte) (uint64, error)
+	// Sync 把已经 Append 的记录写入 stable storage
+	Sync() error
+	// FirstIndex 返回第一条记录的 index，log 为空时，返回 LastIndex()+1
+	FirstIndex() uint64
+	// LastIndex 返回最后一条记录的 index，从来没有添加过记录时，返回 0
+	LastIndex() uint64
+	// Read 返回 index 处的记录
+	Read(index uint64) ([]byte, error)
+	// Iterate 从 from 开始，按顺序把记录交给 fn，fn 返回错误时停止，并返回这个错误
+	Iterate(from uint64, fn func(index uint64, data []byte) error) error
+	// TruncateFront 删除 index 之前的所有记录，例如 snapshot 以后压缩 log
+	TruncateFront(index uint64) error
+	// TruncateBack 删除 index 之后的所有记录，例如 Raft 中的 follower 删除冲突的 log
+	TruncateBack(index uint64) error
+	// Close 关闭 log，之后的所有操作都会返回 ErrClosed
+	Close() error
+}
+
+// 每条记录的格式是 | length uint32 | crc32 uint32 | data |
+const recordHeaderSize = 8
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+func encodeRecord(data []byte) []byte {
+	buf := make([]byte, recordHeaderSize+len(data))
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(data)))
+	binary.BigEndian.PutUint32(buf[4:8], crc32.Checksum(data, crcTable))
+	copy(buf[recordHeaderSize:], data)
+	return buf
+}
+
+// decodeRecord 从 buf 的开头解码出一条记录，并返回这条记录占用的字节数
+// buf 不完整或者校验和不一致时，ok 为 false
+func decodeRecord(buf []byte) (data []byte, size int, ok bool) {
+	if len(buf) < recordHeaderSize {
+		return nil, 0, false
+	}
+	length := int(binary.BigEndian.Uint32(buf[0:4]))
+	if len(buf)-recordHeaderSize < length {
+		return nil, 0, false
+	}
+	data = buf[recordHeaderSize : recordHeaderSize+length]
+	if crc32.Checksum(data, crcTable) != binary.BigEndian.Uint32(buf[4:8]) {
+		return nil, 0, false
+	}
+	return data, recordHeaderSize + length, true
+}
@@ -0,0 +1,187 @@
+package wal
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// forEachLog 对每一种 Log 的实现运行 test
+func forEachLog(t *testing.T, test func(t *testing.T, l Log)) {
+	t.Run("MemoryLog", func(t *testing.T) {
+		test(t, NewMemoryLog())
+	})
+	t.Run("FileLog", func(t *testing.T) {
+		l, err := Open(filepath.Join(t.TempDir(), "wal"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		test(t, l)
+	})
+	t.Run("SimStorage", func(t *testing.T) {
+		l, err := OpenStorage(NewSimStorage(FaultModel{}, 0), "wal")
+		if err != nil {
+			t.Fatal(err)
+		}
+		test(t, l)
+	})
+}
+
+func appendN(l Log, from, to int) {
+	for i := from; i <= to; i++ {
+		l.Append([]byte(fmt.Sprintf("record %d", i)))
+	}
+}
+
+func collect(l Log, from uint64) []string {
+	var res []string
+	l.Iterate(from, func(index uint64, data []byte) error {
+		res = append(res, fmt.Sprintf("%d:%s", index, data))
+		return nil
+	})
+	return res
+}
+
+func Test_Log_empty(t *testing.T) {
+	forEachLog(t, func(t *testing.T, l Log) {
+		ast := assert.New(t)
+		//
+		ast.Equal(uint64(1), l.FirstIndex())
+		ast.Equal(uint64(0), l.LastIndex())
+		_, err := l.Read(1)
+		ast.Equal(ErrOutOfRange, err)
+		ast.Empty(collect(l, 1))
+	})
+}
+
+func Test_Log_appendAndRead(t *testing.T) {
+	forEachLog(t, func(t *testing.T, l Log) {
+		ast := assert.New(t)
+		//
+		for i := 1; i <= 3; i++ {
+			index, err := l.Append([]byte(fmt.Sprintf("record %d", i)))
+			ast.NoError(err)
+			ast.Equal(uint64(i), index)
+		}
+		ast.NoError(l.Sync())
+		ast.Equal(uint64(1), l.FirstIndex())
+		ast.Equal(uint64(3), l.LastIndex())
+		data, err := l.Read(2)
+		ast.NoError(err)
+		ast.Equal("record 2", string(data))
+		_, err = l.Read(4)
+		ast.Equal(ErrOutOfRange, err)
+		ast.Equal([]string{"2:record 2", "3:record 3"}, collect(l, 2))
+		// 空记录也是合法的记录
+		index, err := l.Append(nil)
+		ast.NoError(err)
+		data, err = l.Read(index)
+		ast.NoError(err)
+		ast.Empty(data)
+	})
+}
+
+func Test_Log_iterateStops(t *testing.T) {
+	forEachLog(t, func(t *testing.T, l Log) {
+		ast := assert.New(t)
+		//
+		appendN(l, 1, 5)
+		stop := errors.New("stop")
+		count := 0
+		err := l.Iterate(1, func(index uint64, data []byte) error {
+			count++
+			if index == 3 {
+				return stop
+			}
+			return nil
+		})
+		ast.Equal(stop, err)
+		ast.Equal(3, count)
+		ast.Equal(ErrOutOfRange, l.Iterate(7, func(uint64, []byte) error { return nil }))
+	})
+}
+
+func Test_Log_truncateFront(t *testing.T) {
+	forEachLog(t, func(t *testing.T, l Log) {
+		ast := assert.New(t)
+		//
+		appendN(l, 1, 5)
+		ast.NoError(l.TruncateFront(3))
+		ast.Equal(uint64(3), l.FirstIndex())
+		ast.Equal(uint64(5), l.LastIndex())
+		_, err := l.Read(2)
+		ast.Equal(ErrOutOfRange, err)
+		ast.Equal([]string{"3:record 3", "4:record 4", "5:record 5"}, collect(l, 3))
+		ast.Equal(ErrOutOfRange, l.TruncateFront(2))
+		// 压缩以后，index 继续增长
+		index, err := l.Append([]byte("record 6"))
+		ast.NoError(err)
+		ast.Equal(uint64(6), index)
+		// 删除全部的记录
+		ast.NoError(l.TruncateFront(7))
+		ast.Equal(uint64(7), l.FirstIndex())
+		ast.Equal(uint64(6), l.LastIndex())
+		ast.Empty(collect(l, 7))
+		index, err = l.Append([]byte("record 7"))
+		ast.NoError(err)
+		ast.Equal(uint64(7), index)
+	})
+}
+
+func Test_Log_truncateBack(t *testing.T) {
+	forEachLog(t, func(t *testing.T, l Log) {
+		ast := assert.New(t)
+		//
+		appendN(l, 1, 5)
+		ast.NoError(l.TruncateBack(3))
+		ast.Equal(uint64(3), l.LastIndex())
+		ast.Equal(ErrOutOfRange, l.TruncateBack(4))
+		// 被删除的 index 会被新的记录重新使用
+		index, err := l.Append([]byte("new 4"))
+		ast.NoError(err)
+		ast.Equal(uint64(4), index)
+		ast.Equal([]string{"3:record 3", "4:new 4"}, collect(l, 3))
+		// 删除全部的记录
+		ast.NoError(l.TruncateFront(2))
+		ast.NoError(l.TruncateBack(1))
+		ast.Equal(uint64(2), l.FirstIndex())
+		ast.Equal(uint64(1), l.LastIndex())
+		ast.Equal(ErrOutOfRange, l.TruncateBack(0))
+	})
+}
+
+func Test_Log_closed(t *testing.T) {
+	forEachLog(t, func(t *testing.T, l Log) {
+		ast := assert.New(t)
+		//
+		appendN(l, 1, 2)
+		ast.NoError(l.Close())
+		_, err := l.Append(nil)
+		ast.Equal(ErrClosed, err)
+		_, err = l.Read(1)
+		ast.Equal(ErrClosed, err)
+		ast.Equal(ErrClosed, l.Sync())
+		ast.Equal(ErrClosed, l.Iterate(1, func(uint64, []byte) error { return nil }))
+		ast.Equal(ErrClosed, l.TruncateFront(2))
+		ast.Equal(ErrClosed, l.TruncateBack(1))
+		ast.Equal(ErrClosed, l.Close())
+	})
+}
+
+func Test_Log_readReturnsCopy(t *testing.T) {
+	forEachLog(t, func(t *testing.T, l Log) {
+		ast := assert.New(t)
+		//
+		data := []byte("abc")
+		l.Append(data)
+		data[0] = 'x'
+		read, _ := l.Read(1)
+		ast.Equal("abc", string(read))
+		read[1] = 'x'
+		read, _ = l.Read(1)
+		ast.Equal("abc", string(read))
+	})
+}
@@ -0,0 +1,181 @@
+package zab
+
+import "sort"
+
+// NewLeaderArgs 是 NewLeader 的参数
+type NewLeaderArgs struct {
+	Epoch     int
+	Leader    int
+	History   []Txn // leader 完整的 history
+	Committed int
+}
+
+// NewLeaderReply 是 NewLeader 的返回值
+type NewLeaderReply struct {
+	Ok    bool
+	Epoch int // follower 的 acceptedEpoch，大于 leader 的 epoch 时，leader 需要退位
+}
+
+// NewLeader 是 RPC handler，对应 ZAB 论文中的 NEWLEADER 和 ACK-LD
+// follower 用 leader 的 history 替换自己的 history，其中没有被 commit 的事务可能会被丢弃。
+// 当选的 leader 拥有所有被 commit 的事务，所以被丢弃的事务一定没有被 commit。
+func (p *Peer) NewLeader(args *NewLeaderArgs, reply *NewLeaderReply) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	reply.Epoch = p.acceptedEpoch
+	if args.Epoch < p.acceptedEpoch || (p.state == LEADING && args.Epoch == p.currentEpoch) {
+		return
+	}
+	if p.state == LEADING {
+		p.becomeLooking()
+	}
+	p.acceptedEpoch = args.Epoch
+	p.currentEpoch = args.Epoch
+	p.state = FOLLOWING
+	p.leader = args.Leader
+	p.history = append([]Txn(nil), args.History...)
+	p.stats.SyncedTxns += len(args.History)
+	p.commit(args.Committed)
+	p.lastHeard = p.env.Now()
+	reply.Ok = true
+}
+
+// ProposeArgs 是 Propose 的参数
+// Txns 是 leader 的 history 中，从 Start 开始的事务
+type ProposeArgs struct {
+	Epoch     int
+	Leader    int
+	Start     int
+	Txns      []Txn
+	Committed int // leader 已经 commit 的事务数量，相当于 ZAB 中的 COMMIT 消息
+}
+
+// ProposeReply 是 Propose 的返回值
+type ProposeReply struct {
+	Ok    bool
+	Epoch int
+	Acked int // follower 的 history 的长度
+}
+
+// Propose 是 RPC handler，对应 ZAB 论文中的 PROPOSE、ACK 和 COMMIT
+// 没有事务的 Propose 就是心跳
+func (p *Peer) Propose(args *ProposeArgs, reply *ProposeReply) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	reply.Epoch = p.acceptedEpoch
+	if args.Epoch < p.acceptedEpoch {
+		return
+	}
+	if p.state != FOLLOWING || p.currentEpoch != args.Epoch || args.Start > len(p.history) {
+		// 还没有与 leader 完成 Synchronization
+		return
+	}
+	// 每个 follower 的消息是按顺序发送的，但是可能会重发
+	for i, txn := range args.Txns {
+		if args.Start+i >= len(p.history) {
+			p.history = append(p.history, txn)
+		}
+	}
+	p.commit(args.Committed)
+	p.lastHeard = p.env.Now()
+	reply.Ok = true
+	reply.Acked = len(p.history)
+}
+
+// replicate 是 leader 中负责 Peer i 的 goroutine
+// 先与 Peer i 完成 Synchronization，然后按顺序向它发送事务，同一时刻只有一个请求在途，所以消息是 FIFO 的
+func (p *Peer) replicate(i, epoch int, notify chan struct{}) {
+	for {
+		p.mu.Lock()
+		if p.dead || p.state != LEADING || p.currentEpoch != epoch {
+			p.mu.Unlock()
+			return
+		}
+		if !p.synced[i] {
+			args := NewLeaderArgs{
+				Epoch:     epoch,
+				Leader:    p.me,
+				History:   append([]Txn(nil), p.history...),
+				Committed: p.committed,
+			}
+			p.mu.Unlock()
+			var reply NewLeaderReply
+			ok := p.peers[i].Call("Peer.NewLeader", &args, &reply)
+			p.mu.Lock()
+			if ok && p.isLeaderOf(epoch) {
+				p.lastAck[i] = p.env.Now()
+				switch {
+				case reply.Ok:
+					p.synced[i] = true
+					p.acked[i] = len(args.History)
+					p.stats.Syncs++
+					p.advanceCommit()
+				case reply.Epoch > epoch:
+					p.becomeLooking()
+				}
+			}
+			p.mu.Unlock()
+		} else {
+			start := p.acked[i]
+			args := ProposeArgs{
+				Epoch:     epoch,
+				Leader:    p.me,
+				Start:     start,
+				Txns:      append([]Txn(nil), p.history[start:]...),
+				Committed: p.committed,
+			}
+			p.mu.Unlock()
+			var reply ProposeReply
+			ok := p.peers[i].Call("Peer.Propose", &args, &reply)
+			p.mu.Lock()
+			if ok && p.isLeaderOf(epoch) {
+				p.lastAck[i] = p.env.Now()
+				switch {
+				case reply.Ok:
+					if reply.Acked > p.acked[i] {
+						p.acked[i] = reply.Acked
+					}
+					p.advanceCommit()
+				case reply.Epoch > epoch:
+					p.becomeLooking()
+				default:
+					p.synced[i] = false
+					p.acked[i] = 0
+				}
+			}
+			caughtUp := p.synced[i] && p.acked[i] == len(p.history)
+			p.mu.Unlock()
+			if !ok || caughtUp {
+				select {
+				case <-notify:
+				case <-p.env.After(heartBeat):
+				}
+			}
+		}
+	}
+}
+
+// isLeaderOf 返回 true，如果 p 依然是 epoch 的 leader
+func (p *Peer) isLeaderOf(epoch int) bool {
+	return !p.dead && p.state == LEADING && p.currentEpoch == epoch
+}
+
+// advanceCommit 让 leader commit 被多数派确认的事务
+// 多数派完成 Synchronization 以后，leader 的整个 history 都被 commit，epoch 也就建立了
+func (p *Peer) advanceCommit() {
+	acked := append([]int(nil), p.acked...)
+	sort.Sort(sort.Reverse(sort.IntSlice(acked)))
+	p.commit(acked[p.quorum()-1])
+
+	synced := 0
+	for _, s := range p.synced {
+		if s {
+			synced++
+		}
+	}
+	if synced >= p.quorum() {
+		p.established = true
+	}
+}
@@ -0,0 +1,133 @@
+package zab
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/aQuaYi/Distributed-Algorithms/Raft/code/labrpc"
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+)
+
+// Cluster 在 labrpc 模拟的网络中运行一组 Peer，并记录每个 Peer 交付的事务
+type Cluster struct {
+	mutex     sync.Mutex
+	net       *labrpc.Network
+	peers     []*Peer
+	endnames  [][]string // endnames[i][j] 是 Peer i 发往 Peer j 的端点名称
+	connected []bool
+	delivered [][]Txn
+}
+
+// MakeCluster 启动由 n 个 Peer 组成的集群
+func MakeCluster(n int) *Cluster {
+	return MakeClusterWithEnv(n, sim.Env{})
+}
+
+// MakeClusterWithEnv 与 MakeCluster 一样，但是网络和 Peer 都从 env 读取时间和随机数
+func MakeClusterWithEnv(n int, env sim.Env) *Cluster {
+	c := &Cluster{
+		net:       labrpc.MakeNetwork(),
+		peers:     make([]*Peer, n),
+		endnames:  make([][]string, n),
+		connected: make([]bool, n),
+		delivered: make([][]Txn, n),
+	}
+	c.net.SetEnv(env)
+
+	for i := 0; i < n; i++ {
+		c.endnames[i] = make([]string, n)
+		ends := make([]*labrpc.ClientEnd, n)
+		for j := 0; j < n; j++ {
+			c.endnames[i][j] = fmt.Sprintf("peer-%d-to-%d", i, j)
+			ends[j] = c.net.MakeEnd(c.endnames[i][j])
+			c.net.Connect(c.endnames[i][j], j)
+		}
+
+		applyCh := make(chan Txn)
+		p := MakeWithEnv(ends, i, applyCh, env)
+		c.peers[i] = p
+		go c.deliver(i, applyCh)
+
+		srv := labrpc.MakeServer()
+		srv.AddService(labrpc.MakeService(p))
+		c.net.AddServer(i, srv)
+	}
+
+	for i := 0; i < n; i++ {
+		c.Connect(i)
+	}
+
+	return c
+}
+
+func (c *Cluster) deliver(i int, applyCh chan Txn) {
+	for txn := range applyCh {
+		c.mutex.Lock()
+		c.delivered[i] = append(c.delivered[i], txn)
+		c.mutex.Unlock()
+	}
+}
+
+// Peer 返回第 i 个 Peer
+func (c *Cluster) Peer(i int) *Peer {
+	return c.peers[i]
+}
+
+// Delivered 返回 Peer i 按顺序交付的事务
+func (c *Cluster) Delivered(i int) []Txn {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]Txn(nil), c.delivered[i]...)
+}
+
+// Connect 让 Peer i 可以与其他已连接的 Peer 通信
+func (c *Cluster) Connect(i int) {
+	c.setConnected(i, true)
+}
+
+// Disconnect 隔离 Peer i
+func (c *Cluster) Disconnect(i int) {
+	c.setConnected(i, false)
+}
+
+func (c *Cluster) setConnected(i int, connected bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.connected[i] = connected
+	for j := range c.peers {
+		if j == i || c.connected[j] {
+			c.net.Enable(c.endnames[i][j], connected)
+			c.net.Enable(c.endnames[j][i], connected)
+		}
+	}
+}
+
+// Leader 返回已经建立了 epoch 的 leader，没有的话，返回 -1
+func (c *Cluster) Leader() int {
+	for i, p := range c.peers {
+		if _, isLeader := p.GetState(); isLeader && c.isConnected(i) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (c *Cluster) isConnected(i int) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.connected[i]
+}
+
+// RPCCount 返回 Peer i 收到的 RPC 数量
+func (c *Cluster) RPCCount(i int) int {
+	return c.net.GetCount(i)
+}
+
+// Cleanup 关闭模拟网络
+func (c *Cluster) Cleanup() {
+	for _, p := range c.peers {
+		p.Kill()
+	}
+	c.net.Cleanup()
+}
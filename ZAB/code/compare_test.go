@@ -0,0 +1,212 @@
+package zab
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	raft "github.com/aQuaYi/Distributed-Algorithms/Raft/code"
+	"github.com/aQuaYi/Distributed-Algorithms/Raft/code/labrpc"
+	"github.com/stretchr/testify/assert"
+)
+
+// raftCluster 与 Cluster 一样，在 labrpc 模拟的网络中运行一组 Raft
+type raftCluster struct {
+	mutex     sync.Mutex
+	net       *labrpc.Network
+	rafts     []*raft.Raft
+	endnames  [][]string
+	connected []bool
+	delivered [][]interface{}
+}
+
+func makeRaftCluster(n int) *raftCluster {
+	c := &raftCluster{
+		net:       labrpc.MakeNetwork(),
+		rafts:     make([]*raft.Raft, n),
+		endnames:  make([][]string, n),
+		connected: make([]bool, n),
+		delivered: make([][]interface{}, n),
+	}
+	for i := 0; i < n; i++ {
+		c.connected[i] = true
+		c.endnames[i] = make([]string, n)
+		ends := make([]*labrpc.ClientEnd, n)
+		for j := 0; j < n; j++ {
+			c.endnames[i][j] = fmt.Sprintf("raft-%d-to-%d", i, j)
+			ends[j] = c.net.MakeEnd(c.endnames[i][j])
+			c.net.Connect(c.endnames[i][j], j)
+			c.net.Enable(c.endnames[i][j], true)
+		}
+		applyCh := make(chan raft.ApplyMsg)
+		c.rafts[i] = raft.Make(ends, i, raft.MakePersister(), applyCh)
+		go func(i int) {
+			for msg := range applyCh {
+				if msg.CommandValid {
+					c.mutex.Lock()
+					c.delivered[i] = append(c.delivered[i], msg.Command)
+					c.mutex.Unlock()
+				}
+			}
+		}(i)
+		srv := labrpc.MakeServer()
+		srv.AddService(labrpc.MakeService(c.rafts[i]))
+		c.net.AddServer(i, srv)
+	}
+	return c
+}
+
+func (c *raftCluster) setConnected(i int, connected bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.connected[i] = connected
+	for j := range c.rafts {
+		c.net.Enable(c.endnames[i][j], connected)
+		c.net.Enable(c.endnames[j][i], connected)
+	}
+}
+
+// leader 返回已连接的 leader，没有的话，返回 -1
+func (c *raftCluster) leader() int {
+	for i, rf := range c.rafts {
+		_, isLeader := rf.GetState()
+		c.mutex.Lock()
+		connected := c.connected[i]
+		c.mutex.Unlock()
+		if isLeader && connected {
+			return i
+		}
+	}
+	return -1
+}
+
+func (c *raftCluster) countDelivered(cmd interface{}) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	count := 0
+	for _, d := range c.delivered {
+		for _, x := range d {
+			if x == cmd {
+				count++
+				break
+			}
+		}
+	}
+	return count
+}
+
+// recovery 记录了一次 leader 故障的恢复过程
+type recovery struct {
+	failover time.Duration // 从隔离 leader 到新的事务被多数派交付
+	catchUp  time.Duration // 从旧 leader 重新连接到它交付了新的事务
+	rpcs     int           // 旧 leader 在 catchUp 期间收到的 RPC 数量
+}
+
+// measure 隔离 leader，并让它提出 stale 个无法 commit 的命令，然后测量恢复的过程
+// start(i, cmd) 让 i 提出 cmd，返回 false 表示 i 不是 leader；delivered(cmd) 返回交付了 cmd 的数量
+func measure(n, stale int, leader func() int, start func(i int, cmd int) bool,
+	delivered func(cmd int) int, connect func(i int, connected bool), rpcs func(i int) int) (recovery, bool) {
+	var r recovery
+	// submit 反复提出 cmd，直到至少 expected 个 server 交付了它
+	submit := func(cmd, expected int) bool {
+		deadline := time.Now().Add(10 * time.Second)
+		for time.Now().Before(deadline) {
+			if l := leader(); l >= 0 && start(l, cmd) {
+				for end := time.Now().Add(time.Second); time.Now().Before(end); {
+					if delivered(cmd) >= expected {
+						return true
+					}
+					time.Sleep(5 * time.Millisecond)
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		return false
+	}
+	for cmd := 1; cmd <= 20; cmd++ {
+		if !submit(cmd, n) {
+			return r, false
+		}
+	}
+	old := leader()
+	connect(old, false)
+	begin := time.Now()
+	for cmd := 1000; cmd < 1000+stale; cmd++ {
+		start(old, cmd)
+	}
+	if !submit(21, n-1) {
+		return r, false
+	}
+	r.failover = time.Since(begin)
+	//
+	before := rpcs(old)
+	connect(old, true)
+	begin = time.Now()
+	for delivered(21) < n {
+		if time.Since(begin) > 10*time.Second {
+			return r, false
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	r.catchUp = time.Since(begin)
+	r.rpcs = rpcs(old) - before
+	return r, true
+}
+
+// 在同样的模拟网络和同样的超时设置下，比较 ZAB 和 Raft 从 leader 故障中恢复的过程
+// 旧 leader 重新加入时，Raft 的 leader 通过 AppendEntries 的一致性检查逐步找到分歧点，
+// ZAB 的 leader 则在 Synchronization 阶段直接发送完整的 history
+func Test_compareRecoveryWithRaft(t *testing.T) {
+	ast := assert.New(t)
+	//
+	n, stale := 5, 50
+	//
+	zc := MakeCluster(n)
+	defer zc.Cleanup()
+	zr, ok := measure(n, stale,
+		zc.Leader,
+		func(i, cmd int) bool {
+			_, ok := zc.peers[i].Start(cmd)
+			return ok
+		},
+		func(cmd int) int {
+			count := 0
+			for i := range zc.peers {
+				for _, txn := range zc.Delivered(i) {
+					if txn.Command == cmd {
+						count++
+						break
+					}
+				}
+			}
+			return count
+		},
+		zc.setConnected,
+		zc.RPCCount)
+	ast.True(ok)
+	checkPrefix(ast, zc)
+	//
+	rc := makeRaftCluster(n)
+	defer rc.net.Cleanup()
+	rr, ok := measure(n, stale,
+		rc.leader,
+		func(i, cmd int) bool {
+			_, _, ok := rc.rafts[i].Start(cmd)
+			return ok
+		},
+		func(cmd int) int {
+			return rc.countDelivered(cmd)
+		},
+		rc.setConnected,
+		func(i int) int {
+			return rc.net.GetCount(i)
+		})
+	ast.True(ok)
+	for _, rf := range rc.rafts {
+		rf.Kill()
+	}
+	//
+	t.Logf("ZAB：failover %v，旧 leader 追上用了 %v 和 %d 个 RPC", zr.failover, zr.catchUp, zr.rpcs)
+	t.Logf("Raft：failover %v，旧 leader 追上用了 %v 和 %d 个 RPC", rr.failover, rr.catchUp, rr.rpcs)
+}
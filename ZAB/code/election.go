@@ -0,0 +1,195 @@
+package zab
+
+import "time"
+
+// VoteArgs 是 Vote 的参数
+type VoteArgs struct {
+	Round        int
+	Candidate    int
+	CurrentEpoch int
+	LastZxid     Zxid
+}
+
+// VoteReply 是 Vote 的返回值
+type VoteReply struct {
+	Round         int
+	Granted       bool
+	AcceptedEpoch int // 用于在 Discovery 阶段选出比所有人都大的 epoch
+}
+
+// Vote 是 RPC handler
+// 与 ZooKeeper 的 Fast Leader Election 一样，只投票给 history 不比自己旧的 candidate，
+// 所以当选的 leader 一定拥有所有被 commit 的事务。
+// history 的新旧先比较 currentEpoch，再比较 lastZxid：Synchronization 会 commit 整个 history，
+// 只比较 lastZxid 的话，会出现 Raft 论文 Figure 8 中的问题。
+func (p *Peer) Vote(args *VoteArgs, reply *VoteReply) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	reply.Round = p.round
+	if p.state == LEADING ||
+		(p.state == FOLLOWING && p.env.Since(p.lastHeard) < minElection) {
+		// 依然能够联系上 leader，不能让被隔离的 Peer 打断当前的 epoch
+		return
+	}
+	if args.Round < p.round {
+		return
+	}
+	if args.Round > p.round {
+		p.round, p.votedFor = args.Round, NOBODY
+		reply.Round = p.round
+		if p.state == FOLLOWING {
+			p.becomeLooking()
+		}
+	}
+	if p.votedFor != NOBODY && p.votedFor != args.Candidate {
+		return
+	}
+	if args.CurrentEpoch < p.currentEpoch ||
+		(args.CurrentEpoch == p.currentEpoch && args.LastZxid.Less(p.lastZxid())) {
+		return
+	}
+	p.votedFor = args.Candidate
+	p.lastHeard = p.env.Now()
+	reply.Granted = true
+	reply.AcceptedEpoch = p.acceptedEpoch
+}
+
+// startElection 开始新一轮的选举
+// 利用 tickLoop 的锁进行锁定
+func (p *Peer) startElection() {
+	p.becomeLooking()
+	p.round++
+	p.votedFor = p.me
+	p.stats.Elections++
+
+	round := p.round
+	args := VoteArgs{Round: round, Candidate: p.me, CurrentEpoch: p.currentEpoch, LastZxid: p.lastZxid()}
+	maxEpoch := p.acceptedEpoch
+	votes := 1
+	for i := range p.peers {
+		if i == p.me {
+			continue
+		}
+		go func(i int) {
+			var reply VoteReply
+			if !p.peers[i].Call("Peer.Vote", &args, &reply) {
+				return
+			}
+
+			p.mu.Lock()
+			defer p.mu.Unlock()
+
+			if reply.Round > p.round && p.state == LOOKING {
+				p.round = reply.Round
+				return
+			}
+			if !reply.Granted || p.round != round || p.state != LOOKING || votes >= p.quorum() {
+				return
+			}
+			votes++
+			if reply.AcceptedEpoch > maxEpoch {
+				maxEpoch = reply.AcceptedEpoch
+			}
+			if votes == p.quorum() {
+				go p.discover(round, maxEpoch+1)
+			}
+		}(i)
+	}
+	if votes >= p.quorum() {
+		// 只有一个 Peer 的集群
+		go p.discover(round, maxEpoch+1)
+	}
+}
+
+// NewEpochArgs 是 NewEpoch 的参数
+type NewEpochArgs struct {
+	Round  int
+	Epoch  int
+	Leader int
+}
+
+// NewEpochReply 是 NewEpoch 的返回值
+type NewEpochReply struct {
+	Ok bool
+}
+
+// NewEpoch 是 RPC handler，对应 ZAB 论文中的 NEWEPOCH 和 ACK-E
+// 接受以后，p 不会再接受更小的 epoch 中的 NewLeader 和 Propose
+func (p *Peer) NewEpoch(args *NewEpochArgs, reply *NewEpochReply) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if args.Epoch <= p.acceptedEpoch || p.state == LEADING ||
+		p.round != args.Round || p.votedFor != args.Leader {
+		return
+	}
+	p.acceptedEpoch = args.Epoch
+	p.state = FOLLOWING
+	p.leader = args.Leader
+	p.lastHeard = p.env.Now()
+	reply.Ok = true
+}
+
+// discover 是 Discovery 阶段，当选的 leader 让多数派接受新的 epoch
+func (p *Peer) discover(round, epoch int) {
+	p.mu.Lock()
+	if p.round != round || p.state != LOOKING {
+		p.mu.Unlock()
+		return
+	}
+	p.acceptedEpoch = epoch
+	p.mu.Unlock()
+
+	args := NewEpochArgs{Round: round, Epoch: epoch, Leader: p.me}
+	acks := make(chan bool, len(p.peers))
+	for i := range p.peers {
+		if i == p.me {
+			continue
+		}
+		go func(i int) {
+			var reply NewEpochReply
+			acks <- p.peers[i].Call("Peer.NewEpoch", &args, &reply) && reply.Ok
+		}(i)
+	}
+	count := 1
+	for i := 1; i < len(p.peers) && count < p.quorum(); i++ {
+		if <-acks {
+			count++
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if count < p.quorum() || p.round != round || p.state != LOOKING || p.acceptedEpoch != epoch {
+		return
+	}
+	p.becomeLeader(epoch)
+}
+
+// becomeLeader 让 p 成为 epoch 的 leader，并开始 Synchronization 阶段
+// 利用调用方的锁进行锁定
+func (p *Peer) becomeLeader(epoch int) {
+	n := len(p.peers)
+	p.state = LEADING
+	p.leader = p.me
+	p.currentEpoch = epoch
+	p.counter = 0
+	p.established = false
+	p.synced = make([]bool, n)
+	p.acked = make([]int, n)
+	p.lastAck = make([]time.Time, n)
+	p.notify = make([]chan struct{}, n)
+	p.leadSince = p.env.Now()
+	p.synced[p.me] = true
+	p.acked[p.me] = len(p.history)
+	for i := range p.peers {
+		if i == p.me {
+			continue
+		}
+		p.lastAck[i] = p.leadSince
+		p.notify[i] = make(chan struct{}, 1)
+		go p.replicate(i, epoch, p.notify[i])
+	}
+	p.advanceCommit()
+}
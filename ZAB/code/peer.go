@@ -0,0 +1,261 @@
+package zab
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aQuaYi/Distributed-Algorithms/Raft/code/labrpc"
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+)
+
+const (
+	// heartBeat 是 leader 发送心跳的时间间隔，与 Raft 相同
+	heartBeat = 50 * time.Millisecond
+	// minElection 是 follower 等待 leader 的最短时间，与 Raft 相同
+	minElection = heartBeat * 10
+	// maxElection 是 follower 等待 leader 的最长时间
+	maxElection = minElection * 8 / 5
+	// tickInterval 是检查超时的时间间隔
+	tickInterval = 10 * time.Millisecond
+
+	// NOBODY 表示 Peer.votedFor 和 Peer.leader 为空
+	NOBODY = -1
+)
+
+func (p *Peer) electionTimeout() time.Duration {
+	interval := int(minElection) +
+		p.env.Intn(int(maxElection-minElection))
+	return time.Duration(interval)
+}
+
+type state int
+
+// Peer 的状态
+const (
+	LOOKING state = iota
+	FOLLOWING
+	LEADING
+)
+
+func (s state) String() string {
+	switch s {
+	case LOOKING:
+		return "Looking"
+	case FOLLOWING:
+		return "Following"
+	default:
+		return "Leading"
+	}
+}
+
+// Stats 统计了 Peer 在恢复过程中的工作量
+type Stats struct {
+	Elections  int // 发起选举的次数
+	Syncs      int // 作为 leader，完成 Synchronization 阶段的 follower 数量
+	SyncedTxns int // 作为 follower，在 Synchronization 阶段收到的事务数量
+}
+
+// Peer 是 ZAB 中的一个 server
+//
+// 与 Raft 一样，Peer 依次经历 3 个阶段：
+//  1. Election 和 Discovery：选出 history 最新的 Peer 作为 leader，并由多数派确定一个新的 epoch
+//  2. Synchronization：leader 把自己完整的 history 发给 follower，follower 用它替换自己的 history
+//  3. Broadcast：leader 按照 Zxid 的顺序提出事务，多数派确认以后 commit
+//
+// 与 Raft 不同的是，leader 的 history 在 Synchronization 阶段一次性地成为所有 follower 的 history，
+// 而不是通过 AppendEntries 的一致性检查，一条一条地向前寻找分歧点。
+type Peer struct {
+	mu    sync.Mutex
+	peers []*labrpc.ClientEnd
+	me    int
+	dead  bool
+	env   sim.Env
+
+	state         state
+	acceptedEpoch int // 最近一次在 NewEpoch 中接受的 epoch
+	currentEpoch  int // 最近一次在 NewLeader 中接受的 epoch
+	history       []Txn
+	committed     int // history 的前 committed 个事务已经 commit
+	applied       int // history 的前 applied 个事务已经交给了 applyCh
+	leader        int
+	round         int // 选举的轮次
+	votedFor      int
+	lastHeard     time.Time // 最近一次收到 leader 消息或投票的时间
+	timeout       time.Duration
+
+	// 以下属性只在 leader 上有意义
+	established bool            // 多数派完成了 Synchronization，可以开始广播
+	counter     int             // 当前 epoch 中，最后一个事务的 Counter
+	synced      []bool          // synced[i] 表示 Peer i 已经完成了 Synchronization
+	acked       []int           // acked[i] 是 Peer i 确认过的 history 的长度
+	lastAck     []time.Time     // lastAck[i] 是最近一次收到 Peer i 回复的时间
+	notify      []chan struct{} // 有新的事务时，通知向 Peer i 发送的 goroutine
+	leadSince   time.Time
+
+	stats    Stats
+	applyCh  chan Txn
+	applyCnd *sync.Cond
+}
+
+// Make 创建并启动一个 Peer
+// 被 commit 的事务会按照 Zxid 的顺序发送到 applyCh
+func Make(peers []*labrpc.ClientEnd, me int, applyCh chan Txn) *Peer {
+	return MakeWithEnv(peers, me, applyCh, sim.Env{})
+}
+
+// MakeWithEnv 与 Make 一样，但是 p 从 env 读取时间和随机数
+func MakeWithEnv(peers []*labrpc.ClientEnd, me int, applyCh chan Txn, env sim.Env) *Peer {
+	p := &Peer{
+		peers:    peers,
+		me:       me,
+		env:      env,
+		state:    LOOKING,
+		leader:   NOBODY,
+		votedFor: NOBODY,
+		applyCh:  applyCh,
+	}
+	p.lastHeard = env.Now()
+	p.timeout = p.electionTimeout()
+	p.applyCnd = sync.NewCond(&p.mu)
+
+	go p.tickLoop()
+	go p.applyLoop()
+
+	return p
+}
+
+func (p *Peer) String() string {
+	return fmt.Sprintf("<Z%d:E%d:%s:C%d:H%d>", p.me, p.currentEpoch, p.state, p.committed, len(p.history))
+}
+
+// GetState 返回 p 的当前 epoch，以及 p 是否为可以接受事务的 leader
+func (p *Peer) GetState() (int, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.currentEpoch, p.state == LEADING && p.established
+}
+
+// Stats 返回 p 的统计数据
+func (p *Peer) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stats
+}
+
+// Start 让 leader 提出一个事务
+// p 不是 leader，或者还没有完成 Synchronization 时，返回 false
+func (p *Peer) Start(command interface{}) (Zxid, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.state != LEADING || !p.established {
+		return Zxid{}, false
+	}
+	p.counter++
+	txn := Txn{Zxid: Zxid{Epoch: p.currentEpoch, Counter: p.counter}, Command: command}
+	p.history = append(p.history, txn)
+	p.acked[p.me] = len(p.history)
+	p.advanceCommit()
+	for i, ch := range p.notify {
+		if i == p.me {
+			continue
+		}
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+	return txn.Zxid, true
+}
+
+// Kill 停止 p
+func (p *Peer) Kill() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.dead = true
+	p.applyCnd.Broadcast()
+}
+
+// lastZxid 返回 history 中最后一个事务的 Zxid
+func (p *Peer) lastZxid() Zxid {
+	if len(p.history) == 0 {
+		return Zxid{}
+	}
+	return p.history[len(p.history)-1].Zxid
+}
+
+func (p *Peer) quorum() int {
+	return len(p.peers)/2 + 1
+}
+
+// becomeLooking 让 p 重新寻找 leader
+func (p *Peer) becomeLooking() {
+	p.state = LOOKING
+	p.leader = NOBODY
+	p.established = false
+	p.lastHeard = p.env.Now()
+	p.timeout = p.electionTimeout()
+}
+
+// commit 把 history 的前 n 个事务标记为 commit
+func (p *Peer) commit(n int) {
+	if n > len(p.history) {
+		n = len(p.history)
+	}
+	if n > p.committed {
+		p.committed = n
+		p.applyCnd.Broadcast()
+	}
+}
+
+func (p *Peer) tickLoop() {
+	for {
+		p.env.Sleep(tickInterval)
+
+		p.mu.Lock()
+		if p.dead {
+			p.mu.Unlock()
+			return
+		}
+		switch {
+		case p.state == LEADING && p.env.Since(p.leadSince) > minElection && !p.hasQuorum():
+			// 联系不上多数派的 leader，不能再提出事务
+			p.becomeLooking()
+		case p.state != LEADING && p.env.Since(p.lastHeard) > p.timeout:
+			p.startElection()
+		}
+		p.mu.Unlock()
+	}
+}
+
+// hasQuorum 返回 true，如果 leader 在一个选举超时内收到了多数派的回复
+func (p *Peer) hasQuorum() bool {
+	count := 1
+	for i, t := range p.lastAck {
+		if i != p.me && p.env.Since(t) < minElection {
+			count++
+		}
+	}
+	return count >= p.quorum()
+}
+
+func (p *Peer) applyLoop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for {
+		for !p.dead && p.applied >= p.committed {
+			p.applyCnd.Wait()
+		}
+		if p.dead {
+			return
+		}
+		txns := append([]Txn(nil), p.history[p.applied:p.committed]...)
+		p.applied = p.committed
+		p.mu.Unlock()
+		for _, txn := range txns {
+			p.applyCh <- txn
+		}
+		p.mu.Lock()
+	}
+}
@@ -0,0 +1,231 @@
+package zab
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// waitLeader 等待集群建立一个 epoch，超时返回 -1
+func waitLeader(c *Cluster) int {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if l := c.Leader(); l >= 0 {
+			return l
+		}
+		time.Sleep(heartBeat)
+	}
+	return -1
+}
+
+// countDelivered 返回交付了 zxid 的 Peer 的数量
+func countDelivered(c *Cluster, zxid Zxid) int {
+	count := 0
+	for i := range c.peers {
+		for _, txn := range c.Delivered(i) {
+			if txn.Zxid == zxid {
+				count++
+				break
+			}
+		}
+	}
+	return count
+}
+
+// one 让 leader 提出 cmd，直到至少 expected 个 Peer 交付了它，超时返回 false
+func one(c *Cluster, cmd interface{}, expected int) (Zxid, bool) {
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		l := c.Leader()
+		if l < 0 {
+			time.Sleep(heartBeat)
+			continue
+		}
+		zxid, ok := c.peers[l].Start(cmd)
+		if !ok {
+			continue
+		}
+		for end := time.Now().Add(2 * time.Second); time.Now().Before(end); {
+			if countDelivered(c, zxid) >= expected {
+				return zxid, true
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	return Zxid{}, false
+}
+
+// checkPrefix 检查所有 Peer 交付的事务互为前缀
+func checkPrefix(ast *assert.Assertions, c *Cluster) {
+	var longest []Txn
+	for i := range c.peers {
+		if d := c.Delivered(i); len(d) > len(longest) {
+			longest = d
+		}
+	}
+	for i := range c.peers {
+		d := c.Delivered(i)
+		ast.Equal(longest[:len(d)], d, "Peer %d 交付的事务不是其他 Peer 的前缀", i)
+	}
+	for i := 1; i < len(longest); i++ {
+		ast.True(longest[i-1].Zxid.Less(longest[i].Zxid))
+	}
+}
+
+func Test_Cluster_broadcast(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := MakeCluster(3)
+	defer c.Cleanup()
+	l := waitLeader(c)
+	ast.NotEqual(-1, l)
+	epoch, _ := c.peers[l].GetState()
+	ast.True(epoch > 0)
+	//
+	for i := 1; i <= 10; i++ {
+		zxid, ok := one(c, i, 3)
+		ast.True(ok)
+		ast.Equal(Zxid{Epoch: epoch, Counter: i}, zxid)
+	}
+	checkPrefix(ast, c)
+	// follower 不能提出事务
+	_, ok := c.peers[(l+1)%3].Start(11)
+	ast.False(ok)
+}
+
+func Test_Cluster_leaderFailure(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := MakeCluster(5)
+	defer c.Cleanup()
+	_, ok := one(c, 1, 5)
+	ast.True(ok)
+	//
+	oldLeader := c.Leader()
+	oldEpoch, _ := c.peers[oldLeader].GetState()
+	c.Disconnect(oldLeader)
+	// 被隔离的 leader 提出的事务无法 commit
+	for i := 100; i < 105; i++ {
+		c.peers[oldLeader].Start(i)
+	}
+	zxid, ok := one(c, 2, 4)
+	ast.True(ok)
+	ast.True(zxid.Epoch > oldEpoch)
+	// 旧 leader 重新连接以后，通过 Synchronization 丢弃没有 commit 的事务
+	c.Connect(oldLeader)
+	_, ok = one(c, 3, 5)
+	ast.True(ok)
+	checkPrefix(ast, c)
+	for _, txn := range c.Delivered(oldLeader) {
+		ast.True(txn.Command.(int) < 100)
+	}
+	ast.True(c.peers[oldLeader].Stats().SyncedTxns >= 2)
+}
+
+func Test_Cluster_minorityPartition(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := MakeCluster(5)
+	defer c.Cleanup()
+	_, ok := one(c, 1, 5)
+	ast.True(ok)
+	l := c.Leader()
+	// leader 和一个 follower 位于少数派中
+	minority := []int{l, (l + 1) % 5}
+	for _, i := range minority {
+		c.Disconnect(i)
+	}
+	zxid, ok := one(c, 2, 3)
+	ast.True(ok)
+	// 联系不上多数派的旧 leader 会退位
+	time.Sleep(2 * minElection)
+	_, isLeader := c.peers[l].GetState()
+	ast.False(isLeader)
+	//
+	for _, i := range minority {
+		c.Connect(i)
+	}
+	_, ok = one(c, 3, 5)
+	ast.True(ok)
+	ast.Equal(5, countDelivered(c, zxid))
+	checkPrefix(ast, c)
+}
+
+func Test_Cluster_singlePeer(t *testing.T) {
+	ast := assert.New(t)
+	//
+	c := MakeCluster(1)
+	defer c.Cleanup()
+	_, ok := one(c, 1, 1)
+	ast.True(ok)
+}
+
+func Test_Peer_Vote(t *testing.T) {
+	ast := assert.New(t)
+	//
+	p := &Peer{
+		state:        LOOKING,
+		votedFor:     NOBODY,
+		round:        2,
+		currentEpoch: 3,
+		history:      []Txn{{Zxid: Zxid{Epoch: 2, Counter: 5}}},
+	}
+	var reply VoteReply
+	p.Vote(&VoteArgs{Round: 1, Candidate: 1, CurrentEpoch: 3, LastZxid: Zxid{Epoch: 3, Counter: 1}}, &reply)
+	ast.False(reply.Granted)
+	ast.Equal(2, reply.Round)
+	// lastZxid 更大，但 currentEpoch 更小的 candidate 可能缺少被 commit 的事务
+	reply = VoteReply{}
+	p.Vote(&VoteArgs{Round: 3, Candidate: 1, CurrentEpoch: 2, LastZxid: Zxid{Epoch: 2, Counter: 9}}, &reply)
+	ast.False(reply.Granted)
+	ast.Equal(3, p.round)
+	//
+	reply = VoteReply{}
+	p.Vote(&VoteArgs{Round: 3, Candidate: 2, CurrentEpoch: 3, LastZxid: Zxid{Epoch: 2, Counter: 5}}, &reply)
+	ast.True(reply.Granted)
+	// 同一轮中只投一票
+	reply = VoteReply{}
+	p.Vote(&VoteArgs{Round: 3, Candidate: 3, CurrentEpoch: 3, LastZxid: Zxid{Epoch: 2, Counter: 6}}, &reply)
+	ast.False(reply.Granted)
+	// 依然能联系上 leader 时，不投票
+	p.state, p.lastHeard = FOLLOWING, time.Now()
+	reply = VoteReply{}
+	p.Vote(&VoteArgs{Round: 9, Candidate: 3, CurrentEpoch: 9}, &reply)
+	ast.False(reply.Granted)
+	ast.Equal(3, p.round)
+}
+
+func Test_Peer_Propose(t *testing.T) {
+	ast := assert.New(t)
+	//
+	p := &Peer{state: LOOKING, leader: NOBODY}
+	p.applyCnd = sync.NewCond(&p.mu)
+	txns := []Txn{{Zxid: Zxid{Epoch: 1, Counter: 1}}, {Zxid: Zxid{Epoch: 2, Counter: 1}}}
+	// 没有完成 Synchronization，不接受事务
+	var reply ProposeReply
+	p.Propose(&ProposeArgs{Epoch: 2, Leader: 1, Txns: txns}, &reply)
+	ast.False(reply.Ok)
+	//
+	var lr NewLeaderReply
+	p.NewLeader(&NewLeaderArgs{Epoch: 2, Leader: 1, History: txns[:1], Committed: 1}, &lr)
+	ast.True(lr.Ok)
+	ast.Equal(FOLLOWING, p.state)
+	ast.Equal(1, p.committed)
+	// 重发的事务不会被重复添加
+	p.Propose(&ProposeArgs{Epoch: 2, Leader: 1, Start: 0, Txns: txns, Committed: 2}, &reply)
+	ast.True(reply.Ok)
+	ast.Equal(2, reply.Acked)
+	ast.Equal(txns, p.history)
+	ast.Equal(2, p.committed)
+	// 旧 epoch 的 leader 会被拒绝
+	p.acceptedEpoch = 3
+	reply = ProposeReply{}
+	p.Propose(&ProposeArgs{Epoch: 2, Leader: 1, Start: 2}, &reply)
+	ast.False(reply.Ok)
+	ast.Equal(3, reply.Epoch)
+	lr = NewLeaderReply{}
+	p.NewLeader(&NewLeaderArgs{Epoch: 2, Leader: 1}, &lr)
+	ast.False(lr.Ok)
+}
@@ -0,0 +1,33 @@
+package zab
+
+import "fmt"
+
+// Zxid 是 ZAB 中事务的 id
+// Epoch 是提出事务的 leader 的 epoch，Counter 是事务在该 epoch 中的序号。
+// 按照 (Epoch, Counter) 的字典序比较，后提出的事务的 Zxid 一定更大。
+type Zxid struct {
+	Epoch   int
+	Counter int
+}
+
+// Less 返回 true，如果 z 在 other 之前
+func (z Zxid) Less(other Zxid) bool {
+	if z.Epoch != other.Epoch {
+		return z.Epoch < other.Epoch
+	}
+	return z.Counter < other.Counter
+}
+
+func (z Zxid) String() string {
+	return fmt.Sprintf("%d:%d", z.Epoch, z.Counter)
+}
+
+// Txn 是 leader 广播的事务
+type Txn struct {
+	Zxid    Zxid
+	Command interface{}
+}
+
+func (t Txn) String() string {
+	return fmt.Sprintf("%s{%v}", t.Zxid, t.Command)
+}
@@ -0,0 +1,22 @@
+package zab
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Zxid_Less(t *testing.T) {
+	ast := assert.New(t)
+	//
+	ast.True(Zxid{Epoch: 1, Counter: 9}.Less(Zxid{Epoch: 2, Counter: 1}))
+	ast.True(Zxid{Epoch: 2, Counter: 1}.Less(Zxid{Epoch: 2, Counter: 2}))
+	ast.False(Zxid{Epoch: 2, Counter: 2}.Less(Zxid{Epoch: 2, Counter: 2}))
+	ast.False(Zxid{Epoch: 3}.Less(Zxid{Epoch: 2, Counter: 5}))
+}
+
+func Test_Txn_String(t *testing.T) {
+	ast := assert.New(t)
+	//
+	ast.Equal("2:5{x}", Txn{Zxid: Zxid{Epoch: 2, Counter: 5}, Command: "x"}.String())
+}
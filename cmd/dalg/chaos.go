@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// chaosEvent 是 chaos 计划中的一步
+type chaosEvent struct {
+	at     time.Duration
+	action string        // kill、pause、resume、delay 或者 undelay
+	node   int           // 操作的 node
+	delay  time.Duration // delay 增加的延迟
+}
+
+func (e chaosEvent) String() string {
+	if e.action == "delay" {
+		return fmt.Sprintf("delay %d %s at %s", e.node, e.delay, e.at)
+	}
+	return fmt.Sprintf("%s %d at %s", e.action, e.node, e.at)
+}
+
+// parseChaos 解析一条 chaos 计划，时间从 node 启动时算起，格式与 scenario 的故障相同：
+//
+//	kill 2 at 3s
+//	pause 1 at t=2s
+//	resume 1 at 4s
+//	delay 0 200ms at 1s
+//	undelay 0 at 5s
+func parseChaos(s string, n int) (chaosEvent, error) {
+	var e chaosEvent
+	m := faultPattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil || m[2] == "" {
+		return e, fmt.Errorf("无法解析 chaos %q，需要用 at 指定时间", s)
+	}
+	at, err := time.ParseDuration(m[2])
+	if err != nil {
+		return e, fmt.Errorf("chaos %q 的时间：%v", s, err)
+	}
+	e.at = at
+	fields := strings.Fields(m[1])
+	e.action = fields[0]
+	switch {
+	case e.action == "delay" && len(fields) == 3:
+		if e.delay, err = time.ParseDuration(fields[2]); err != nil {
+			return e, fmt.Errorf("chaos %q 的延迟：%v", s, err)
+		}
+	case (e.action == "kill" || e.action == "pause" || e.action == "resume" || e.action == "undelay") && len(fields) == 2:
+	default:
+		return e, fmt.Errorf("无法解析 chaos %q", s)
+	}
+	if e.node, err = strconv.Atoi(fields[1]); err != nil || e.node < 0 || e.node >= n {
+		return e, fmt.Errorf("chaos %q 中的 node 不在 0 到 %d 之间", s, n-1)
+	}
+	return e, nil
+}
+
+// chaosList 是可以重复设置的 --chaos 参数
+type chaosList []string
+
+func (l *chaosList) String() string { return strings.Join(*l, "; ") }
+
+func (l *chaosList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// chaosTarget 能够杀死、暂停和恢复 node
+type chaosTarget interface {
+	Kill(node int) error
+	Pause(node int) error
+	Resume(node int) error
+}
+
+// processTarget 是本机上的 node 进程
+type processTarget []*exec.Cmd
+
+func (t processTarget) Kill(node int) error   { return t[node].Process.Kill() }
+func (t processTarget) Pause(node int) error  { return pauseProcess(t[node].Process) }
+func (t processTarget) Resume(node int) error { return resumeProcess(t[node].Process) }
+
+// composeTarget 是 docker compose 中的 node 容器
+type composeTarget struct {
+	file string
+	// run 执行 docker 命令，测试时可以替换
+	run func(args ...string) error
+}
+
+func newComposeTarget(file string) composeTarget {
+	return composeTarget{file: file, run: func(args ...string) error {
+		return exec.Command("docker", args...).Run()
+	}}
+}
+
+func (t composeTarget) do(action string, node int) error {
+	return t.run("compose", "-f", t.file, action, fmt.Sprintf("node%d", node))
+}
+
+func (t composeTarget) Kill(node int) error   { return t.do("kill", node) }
+func (t composeTarget) Pause(node int) error  { return t.do("pause", node) }
+func (t composeTarget) Resume(node int) error { return t.do("unpause", node) }
+
+// chaosHooks 是注入网络延迟的 shell 命令
+// 命令中的 {node}、{addr}、{delay} 会被替换成 node 的编号、地址和延迟
+// 例如 docker compose 中可以用 "docker compose exec -T node{node} tc qdisc add dev eth0 root netem delay {delay}"
+type chaosHooks struct {
+	delay   string
+	undelay string
+}
+
+func (h chaosHooks) run(hook string, node int, addr string, delay time.Duration) error {
+	cmd := strings.NewReplacer(
+		"{node}", strconv.Itoa(node),
+		"{addr}", addr,
+		"{delay}", delay.String(),
+	).Replace(hook)
+	if out, err := exec.Command("sh", "-c", cmd).CombinedOutput(); err != nil {
+		return fmt.Errorf("%v：%s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// chaosController 按照计划对 node 注入故障
+type chaosController struct {
+	events []chaosEvent
+	target chaosTarget
+	hooks  chaosHooks
+	addrs  []string // 每个 node 的地址，用于替换 hook 中的 {addr}
+	agg    *aggregator
+}
+
+// checkHooks 确保计划中用到的 hook 都已经设置了
+func (c *chaosController) checkHooks() error {
+	for _, e := range c.events {
+		if (e.action == "delay" && c.hooks.delay == "") || (e.action == "undelay" && c.hooks.undelay == "") {
+			return fmt.Errorf("chaos %q 需要设置 --%s-hook", e, e.action)
+		}
+	}
+	return nil
+}
+
+// run 从 start 开始按照时间顺序执行计划，计划结束以后恢复所有还在暂停的 node
+// 这样所有活着的 node 都能正常退出，并报告最后的状态
+func (c *chaosController) run(start time.Time) {
+	sort.SliceStable(c.events, func(i, j int) bool { return c.events[i].at < c.events[j].at })
+	paused := make(map[int]bool)
+	for _, e := range c.events {
+		time.Sleep(time.Until(start.Add(e.at)))
+		var err error
+		switch e.action {
+		case "kill":
+			c.agg.kill(fmt.Sprintf("node%d", e.node))
+			err = c.target.Kill(e.node)
+			delete(paused, e.node)
+		case "pause":
+			err = c.target.Pause(e.node)
+			paused[e.node] = err == nil
+		case "resume":
+			err = c.target.Resume(e.node)
+			delete(paused, e.node)
+		case "delay":
+			err = c.hooks.run(c.hooks.delay, e.node, c.addrs[e.node], e.delay)
+		case "undelay":
+			err = c.hooks.run(c.hooks.undelay, e.node, c.addrs[e.node], 0)
+		}
+		if err != nil {
+			c.agg.line("chaos", fmt.Sprintf("%s 失败：%v", e, err))
+		} else {
+			c.agg.line("chaos", e.String())
+		}
+	}
+	for node, ok := range paused {
+		if !ok {
+			continue
+		}
+		if err := c.target.Resume(node); err != nil {
+			c.agg.line("chaos", fmt.Sprintf("计划结束，恢复 node%d 失败：%v", node, err))
+		} else {
+			c.agg.line("chaos", fmt.Sprintf("计划结束，恢复 node%d", node))
+		}
+	}
+}
+
+// processSignal 是 pauseProcess 和 resumeProcess 共用的辅助函数
+func processSignal(p *os.Process, sig os.Signal) error {
+	if p == nil {
+		return fmt.Errorf("进程还没有启动")
+	}
+	return p.Signal(sig)
+}
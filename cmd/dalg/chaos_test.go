@@ -0,0 +1,84 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseChaos(t *testing.T) {
+	ast := assert.New(t)
+	//
+	e, err := parseChaos("kill 2 at 3s", 3)
+	ast.NoError(err)
+	ast.Equal(chaosEvent{at: 3 * time.Second, action: "kill", node: 2}, e)
+	e, err = parseChaos("delay 0 200ms at t=1s", 3)
+	ast.NoError(err)
+	ast.Equal(chaosEvent{at: time.Second, action: "delay", node: 0, delay: 200 * time.Millisecond}, e)
+	ast.Equal("delay 0 200ms at 1s", e.String())
+	for _, s := range []string{
+		"kill 2",            // 没有时间
+		"kill 3 at 1s",      // node 不存在
+		"kill leader at 1s", // 只能指定编号
+		"delay 0 at 1s",     // 没有延迟
+		"explode 1 at 1s",
+	} {
+		_, err := parseChaos(s, 3)
+		ast.Error(err, s)
+	}
+}
+
+func Test_composeTarget(t *testing.T) {
+	ast := assert.New(t)
+	//
+	var calls []string
+	target := composeTarget{file: "dc.yml", run: func(args ...string) error {
+		calls = append(calls, strings.Join(args, " "))
+		return nil
+	}}
+	ast.NoError(target.Kill(2))
+	ast.NoError(target.Pause(0))
+	ast.NoError(target.Resume(0))
+	ast.Equal([]string{
+		"compose -f dc.yml kill node2",
+		"compose -f dc.yml pause node0",
+		"compose -f dc.yml unpause node0",
+	}, calls)
+}
+
+func Test_launch_chaos(t *testing.T) {
+	ast := assert.New(t)
+	//
+	os.Setenv("DALG_TEST_MAIN", "1")
+	defer os.Unsetenv("DALG_TEST_MAIN")
+	hooks := filepath.Join(t.TempDir(), "hooks.log")
+	out, err := run("launch", "--n=4", "--port="+freePort(t), "--duration=4s",
+		"--interval=50ms", "--metrics=500ms", "--keys=5",
+		"--chaos=pause 1 at 500ms", "--chaos=resume 1 at 1s", "--chaos=kill 3 at 1s",
+		"--chaos=delay 2 50ms at 1s", "--chaos=undelay 2 at 1500ms",
+		"--delay-hook=echo delay {node} {addr} {delay} >> "+hooks,
+		"--undelay-hook=echo undelay {node} >> "+hooks,
+	)
+	ast.NoError(err, out)
+	ast.Contains(out, "[chaos] kill 3 at 1s")
+	ast.Contains(out, "[chaos] pause 1 at 500ms")
+	ast.Contains(out, "（已被杀死）")
+	ast.Contains(out, "不变量 ring：满足")
+	b, err := ioutil.ReadFile(hooks)
+	ast.NoError(err)
+	ast.Contains(string(b), "delay 2 127.0.0.1:")
+	ast.Contains(string(b), " 50ms\nundelay 2\n")
+}
+
+func Test_checkKeys(t *testing.T) {
+	ast := assert.New(t)
+	//
+	live := []map[string]string{{"keys": "3"}, {"keys": "4"}}
+	ast.Equal("", checkKeys(live, 7))
+	ast.Contains(checkKeys(live[:1], 7), "成功写入了 7 个 key，但是活着的 node 上一共有 3 个")
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	clocksync "github.com/aQuaYi/Distributed-Algorithms/Clock-Sync/code"
+)
+
+// clockSyncConfig 是时钟和网络的参数，网络偶尔会出现延迟尖峰
+var clockSyncConfig = clocksync.Config{
+	MaxOffset:  100 * time.Millisecond,
+	MaxDrift:   1e-4,
+	MinDelay:   time.Millisecond,
+	MaxDelay:   5 * time.Millisecond,
+	SpikeRate:  0.05,
+	SpikeDelay: 50 * time.Millisecond,
+}
+
+func runClockSync(args []string, out io.Writer) error {
+	fs, trace := newFlagSet("clocksync", out)
+	algo := fs.String("algo", "cristian", "算法，可选 cristian、berkeley、ntp")
+	n := fs.Int("n", 5, "process 的数量，process 0 是 server 或者 master")
+	rounds := fs.Int("rounds", 10, "同步的轮数")
+	interval := fs.Duration("interval", 10*time.Second, "两轮同步之间的间隔")
+	seed := fs.Int64("seed", 0, "决定时钟初始状态和网络延迟的随机数种子")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var alg clocksync.Algorithm
+	switch *algo {
+	case "cristian":
+		alg = clocksync.Cristian{Samples: 4}
+	case "berkeley":
+		alg = clocksync.Berkeley{Tolerance: 50 * time.Millisecond}
+	case "ntp":
+		alg = clocksync.NewNTP(0, 8, 0)
+	default:
+		return fmt.Errorf("dalg clocksync: 未知的算法 %q", *algo)
+	}
+	if *n < 2 || *rounds < 1 {
+		return fmt.Errorf("dalg clocksync: 至少需要 2 个 process 和 1 轮同步")
+	}
+	s := clocksync.NewSimulation(*n, clockSyncConfig, *seed)
+	report := clocksync.Run(s, alg, *rounds, *interval)
+	fmt.Fprintf(out, "%s 同步 %d 个时钟 %d 轮\n%s\n", *algo, *n, *rounds, report)
+	lines := make([]string, len(report.After))
+	for i := range report.After {
+		lines[i] = fmt.Sprintf("round %d: skew %s -> %s", i+1, report.Before[i], report.After[i])
+	}
+	return writeTrace(*trace, lines)
+}
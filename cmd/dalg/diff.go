@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// traceSummary 是从一个 trace 文件中提取出来的，用于比较的信息
+type traceSummary struct {
+	path   string
+	events []string       // 去掉了时间戳的每一行
+	kinds  map[string]int // 每种事件的数量
+	safety []string       // 每个安全性质的检查结果，与 safetyProperties 一一对应，空字符串表示满足
+}
+
+// safetyProperty 是可以只根据 trace 检查的安全性质
+// trace 中没有相关的事件时，性质总是满足的
+type safetyProperty struct {
+	name  string
+	check func(events []string) string // 返回违反性质的原因，满足时返回空字符串
+}
+
+var safetyProperties = []safetyProperty{
+	{"AlwaysAtMostOneOccupier", checkOneOccupier},
+	{"StateMachineSafety", checkStateMachine},
+	{"ElectionSafety", checkElection},
+}
+
+var (
+	digits      = regexp.MustCompile(`\d+(\.\d+)?`)
+	occupyLine  = regexp.MustCompile(`^P(\d+) .*然后占用`)
+	releaseLine = regexp.MustCompile(`^P(\d+) 释放`)
+	applyLine   = regexp.MustCompile(`^S(\d+) apply index (\d+): (.*)$`)
+	leaderLine  = regexp.MustCompile(`^S(\d+)\(term (\d+)\) 是 leader$`)
+)
+
+func runDiff(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("dalg diff", flag.ContinueOnError)
+	fs.SetOutput(out)
+	ignoreOrder := fs.Bool("ignore-order", false, "不比较事件的顺序，适合 goroutine 调度不确定的 raft trace")
+	context := fs.Int("context", 3, "顺序不同时，打印分歧之后的行数")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return fmt.Errorf("dalg diff: 需要两个 trace 文件，用法：dalg diff old.trace new.trace")
+	}
+	old, err := loadTrace(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	cur, err := loadTrace(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "%s：%d 个事件，%s：%d 个事件\n", old.path, len(old.events), cur.path, len(cur.events))
+	n := diffKinds(old, cur, out)
+	if !*ignoreOrder {
+		n += diffOrder(old, cur, *context, out)
+	}
+	n += diffSafety(old, cur, out)
+	if n == 0 {
+		fmt.Fprintln(out, "没有发现差异")
+		return nil
+	}
+	return fmt.Errorf("dalg diff: 发现 %d 处差异", n)
+}
+
+// loadTrace 读取 path 中由 --trace 写入的 trace
+func loadTrace(path string) (*traceSummary, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	t := &traceSummary{path: path, kinds: make(map[string]int)}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := stripElapsed(scanner.Text())
+		if line == "" {
+			continue
+		}
+		t.events = append(t.events, line)
+		t.kinds[eventKind(line)]++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	for _, p := range safetyProperties {
+		t.safety = append(t.safety, p.check(t.events))
+	}
+	return t, nil
+}
+
+// stripElapsed 去掉 raft trace 开头的运行时间，每次运行的时间都不同，比较时没有意义
+func stripElapsed(line string) string {
+	line = strings.TrimSpace(line)
+	i := strings.IndexByte(line, ' ')
+	if i < 0 {
+		return line
+	}
+	if _, err := time.ParseDuration(line[:i]); err == nil {
+		return strings.TrimSpace(line[i+1:])
+	}
+	return line
+}
+
+// eventKind 把事件中的数字都换成 #，得到事件的种类
+// 例如 "P2 收到 P0 的申请 <T1:P0>" 的种类是 "P# 收到 P# 的申请 <T#:P#>"
+func eventKind(event string) string {
+	return digits.ReplaceAllString(event, "#")
+}
+
+// diffKinds 打印数量不同的事件种类，返回差异的数量
+func diffKinds(old, cur *traceSummary, out io.Writer) int {
+	kinds := make([]string, 0, len(old.kinds)+len(cur.kinds))
+	for k := range old.kinds {
+		kinds = append(kinds, k)
+	}
+	for k := range cur.kinds {
+		if _, ok := old.kinds[k]; !ok {
+			kinds = append(kinds, k)
+		}
+	}
+	sort.Strings(kinds)
+	n := 0
+	for _, k := range kinds {
+		if old.kinds[k] == cur.kinds[k] {
+			continue
+		}
+		if n == 0 {
+			fmt.Fprintln(out, "事件数量不同：")
+		}
+		n++
+		fmt.Fprintf(out, "  %s：%d -> %d\n", k, old.kinds[k], cur.kinds[k])
+	}
+	return n
+}
+
+// diffOrder 打印两个 trace 第一次出现分歧的位置，返回差异的数量，最多为 1
+func diffOrder(old, cur *traceSummary, context int, out io.Writer) int {
+	i := 0
+	for i < len(old.events) && i < len(cur.events) && old.events[i] == cur.events[i] {
+		i++
+	}
+	if i == len(old.events) && i == len(cur.events) {
+		return 0
+	}
+	fmt.Fprintf(out, "事件顺序从第 %d 个事件开始不同：\n", i+1)
+	for j := i; j < i+context && j < len(old.events); j++ {
+		fmt.Fprintf(out, "  - %s\n", old.events[j])
+	}
+	for j := i; j < i+context && j < len(cur.events); j++ {
+		fmt.Fprintf(out, "  + %s\n", cur.events[j])
+	}
+	return 1
+}
+
+// diffSafety 打印检查结果不同的安全性质，返回差异的数量
+func diffSafety(old, cur *traceSummary, out io.Writer) int {
+	n := 0
+	for i, p := range safetyProperties {
+		if old.safety[i] == cur.safety[i] {
+			continue
+		}
+		if n == 0 {
+			fmt.Fprintln(out, "安全性质的检查结果不同：")
+		}
+		n++
+		fmt.Fprintf(out, "  %s：%s -> %s\n", p.name, verdict(old.safety[i]), verdict(cur.safety[i]))
+	}
+	return n
+}
+
+func verdict(violation string) string {
+	if violation == "" {
+		return "满足"
+	}
+	return "违反，" + violation
+}
+
+// checkOneOccupier 检查 mutex trace 中，是否有两个 process 同时占用资源
+func checkOneOccupier(events []string) string {
+	occupier := -1
+	for i, e := range events {
+		if m := occupyLine.FindStringSubmatch(e); m != nil {
+			if occupier >= 0 {
+				return fmt.Sprintf("第 %d 个事件中 P%s 占用资源时，P%d 还没有释放", i+1, m[1], occupier)
+			}
+			fmt.Sscan(m[1], &occupier)
+		} else if m := releaseLine.FindStringSubmatch(e); m != nil {
+			occupier = -1
+		}
+	}
+	return ""
+}
+
+// checkStateMachine 检查 raft trace 中，各个 server 在同一个 index 上应用的是否是同一个命令
+func checkStateMachine(events []string) string {
+	applied := make(map[string]string)
+	for i, e := range events {
+		m := applyLine.FindStringSubmatch(e)
+		if m == nil {
+			continue
+		}
+		if cmd, ok := applied[m[2]]; ok && cmd != m[3] {
+			return fmt.Sprintf("第 %d 个事件中 S%s 在 index %s 应用了 %s，其他 server 应用的是 %s", i+1, m[1], m[2], m[3], cmd)
+		}
+		applied[m[2]] = m[3]
+	}
+	return ""
+}
+
+// checkElection 检查 raft trace 中，每个 term 是否至多只有一个 leader
+func checkElection(events []string) string {
+	leaders := make(map[string]string)
+	for i, e := range events {
+		m := leaderLine.FindStringSubmatch(e)
+		if m == nil {
+			continue
+		}
+		if s, ok := leaders[m[2]]; ok && s != m[1] {
+			return fmt.Sprintf("第 %d 个事件中 term %s 出现了第二个 leader S%s，之前是 S%s", i+1, m[2], m[1], s)
+		}
+		leaders[m[2]] = m[1]
+	}
+	return ""
+}
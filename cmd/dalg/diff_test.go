@@ -0,0 +1,122 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeLines(t *testing.T, name string, lines ...string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func Test_diff_sameSeed(t *testing.T) {
+	ast := assert.New(t)
+	//
+	dir := t.TempDir()
+	old, cur := filepath.Join(dir, "old.trace"), filepath.Join(dir, "new.trace")
+	_, err := run("mutex", "--n=4", "--requests=5", "--seed=7", "--trace="+old)
+	ast.NoError(err)
+	_, err = run("mutex", "--n=4", "--requests=5", "--seed=7", "--trace="+cur)
+	ast.NoError(err)
+	out, err := run("diff", old, cur)
+	ast.NoError(err)
+	ast.Contains(out, "没有发现差异")
+}
+
+func Test_diff_otherSeed(t *testing.T) {
+	ast := assert.New(t)
+	//
+	dir := t.TempDir()
+	old, cur := filepath.Join(dir, "old.trace"), filepath.Join(dir, "new.trace")
+	_, err := run("mutex", "--n=4", "--requests=5", "--seed=7", "--trace="+old)
+	ast.NoError(err)
+	_, err = run("mutex", "--n=4", "--requests=5", "--seed=8", "--trace="+cur)
+	ast.NoError(err)
+	out, err := run("diff", old, cur)
+	ast.Error(err)
+	ast.Contains(out, "事件顺序从第")
+	ast.NotContains(out, "安全性质")
+	// 忽略顺序时，只报告数量的差异
+	out, _ = run("diff", "--ignore-order", old, cur)
+	ast.NotContains(out, "事件顺序")
+	// 同一个 trace 与自己没有差异
+	_, err = run("diff", "--ignore-order", old, old)
+	ast.NoError(err)
+}
+
+func Test_diff_messageCounts(t *testing.T) {
+	ast := assert.New(t)
+	//
+	old := writeLines(t, "old.trace", "P0 申请 <T1:P0>", "P1 收到 P0 的申请 <T1:P0>")
+	cur := writeLines(t, "new.trace", "P0 申请 <T1:P0>", "P1 收到 P0 的申请 <T1:P0>", "P1 收到 P0 的申请 <T1:P0>")
+	out, err := run("diff", old, cur)
+	ast.Error(err)
+	ast.Contains(out, "P# 收到 P# 的申请 <T#:P#>：1 -> 2")
+	ast.Contains(out, "事件顺序从第 3 个事件开始不同")
+}
+
+func Test_diff_safety(t *testing.T) {
+	ast := assert.New(t)
+	//
+	old := writeLines(t, "old.trace",
+		"P0 收到 P1 的确认 <T1:P0>，然后占用 <T1:P0>",
+		"P0 释放 <T1:P0>",
+		"P1 收到 P0 的释放 <T1:P0>，然后占用 <T2:P1>",
+	)
+	cur := writeLines(t, "new.trace",
+		"P0 收到 P1 的确认 <T1:P0>，然后占用 <T1:P0>",
+		"P1 收到 P0 的确认 <T2:P1>，然后占用 <T2:P1>",
+		"P0 释放 <T1:P0>",
+	)
+	out, err := run("diff", "--ignore-order", old, cur)
+	ast.Error(err)
+	ast.Contains(out, "AlwaysAtMostOneOccupier：满足 -> 违反，第 2 个事件中 P1 占用资源时，P0 还没有释放")
+}
+
+func Test_diff_raft(t *testing.T) {
+	ast := assert.New(t)
+	//
+	old := writeLines(t, "old.trace",
+		"   1.2ms S0(term 1) 是 leader",
+		"   1.5ms S0 start index 1: 100",
+		"   3.1ms S0 apply index 1: 100",
+		"   3.2ms S1 apply index 1: 100",
+	)
+	cur := writeLines(t, "new.trace",
+		"   1.9ms S0(term 1) 是 leader",
+		"   2.4ms S1(term 1) 是 leader",
+		"   2.5ms S0 start index 1: 100",
+		" 3.001ms S0 apply index 1: 100",
+		"  3.33ms S1 apply index 1: 101",
+	)
+	out, err := run("diff", "--ignore-order", old, cur)
+	ast.Error(err)
+	ast.Contains(out, "StateMachineSafety：满足 -> 违反")
+	ast.Contains(out, "ElectionSafety：满足 -> 违反，第 2 个事件中 term 1 出现了第二个 leader S1，之前是 S0")
+	// 只有时间不同的 trace 没有差异
+	same := writeLines(t, "same.trace",
+		"  10.2ms S0(term 1) 是 leader",
+		"  10.5ms S0 start index 1: 100",
+		"  13.1ms S0 apply index 1: 100",
+		"  13.2ms S1 apply index 1: 100",
+	)
+	_, err = run("diff", old, same)
+	ast.NoError(err)
+}
+
+func Test_diff_args(t *testing.T) {
+	ast := assert.New(t)
+	//
+	_, err := run("diff", "only-one.trace")
+	ast.Error(err)
+	_, err = run("diff", "no-such.trace", "no-such-either.trace")
+	ast.Error(err)
+}
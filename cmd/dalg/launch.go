@@ -0,0 +1,415 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// launchConfig 是 dalg launch 的参数，每个 node 都用同样的设置运行 dalg node
+type launchConfig struct {
+	algo     string
+	n        int
+	interval time.Duration
+	metrics  time.Duration
+	duration time.Duration
+	keys     int
+}
+
+// nodeArgs 返回第 i 个 node 的 dalg node 参数，node 0 创建网络，其他 node 通过它加入
+func (c launchConfig) nodeArgs(i int, addr, first string) []string {
+	args := []string{
+		"node",
+		"--algo=" + c.algo,
+		"--addr=" + addr,
+		"--interval=" + c.interval.String(),
+		"--metrics=" + c.metrics.String(),
+		"--duration=" + c.duration.String(),
+		fmt.Sprintf("--keys=%d", c.keys),
+	}
+	if i > 0 {
+		args = append(args, "--join="+first)
+	}
+	return args
+}
+
+func runLaunch(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("dalg launch", flag.ContinueOnError)
+	fs.SetOutput(out)
+	var c launchConfig
+	fs.StringVar(&c.algo, "algo", "chord", "算法，可选的算法与 dalg node 相同")
+	fs.IntVar(&c.n, "n", 3, "node 的数量，每个 node 是一个单独的进程")
+	fs.DurationVar(&c.interval, "interval", 100*time.Millisecond, "node 的维护程序运行的间隔")
+	fs.DurationVar(&c.metrics, "metrics", time.Second, "node 打印 metrics 的间隔")
+	fs.DurationVar(&c.duration, "duration", 5*time.Second, "node 运行的时间")
+	fs.IntVar(&c.keys, "keys", 10, "每个 node 运行到一半时写入的 key 的数量")
+	host := fs.String("host", "127.0.0.1", "本地运行时监听的主机")
+	port := fs.Int("port", 7000, "node i 监听 port+i，使用 docker compose 时每个 node 都监听 port")
+	compose := fs.String("compose", "", "不在本地运行，而是把 docker compose 的配置写入这个文件")
+	up := fs.Bool("up", false, "--compose 时，写好配置以后用 docker compose up 运行 node，并汇总输出")
+	image := fs.String("image", "alpine:3", "--compose 时 node 使用的镜像，镜像中需要能运行静态链接的 dalg")
+	summarize := fs.String("summarize", "", "不运行 node，而是汇总这个文件中 docker compose logs 的输出")
+	var chaos chaosList
+	fs.Var(&chaos, "chaos", "chaos 计划中的一步，例如 \"kill 2 at 3s\"，可以重复")
+	var plan launchPlan
+	fs.StringVar(&plan.hooks.delay, "delay-hook", "", "注入延迟的 shell 命令，{node}、{addr}、{delay} 会被替换")
+	fs.StringVar(&plan.hooks.undelay, "undelay-hook", "", "撤销延迟的 shell 命令，{node}、{addr} 会被替换")
+	checks := fs.String("check", "ring,keys", "最后检查的不变量，用逗号分隔，可选 ring、keys")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if _, ok := nodeAlgorithms[c.algo]; !ok {
+		return fmt.Errorf("dalg launch: 未知的算法 %q", c.algo)
+	}
+	if c.n < 1 || c.duration <= 0 {
+		return fmt.Errorf("dalg launch: 至少需要 1 个 node，并且需要设置 --duration")
+	}
+	for _, name := range strings.Split(*checks, ",") {
+		if _, ok := invariants[name]; !ok {
+			return fmt.Errorf("dalg launch: 未知的不变量 %q", name)
+		}
+		plan.checks = append(plan.checks, name)
+	}
+	for _, s := range chaos {
+		e, err := parseChaos(s, c.n)
+		if err != nil {
+			return fmt.Errorf("dalg launch: %v", err)
+		}
+		plan.chaos = append(plan.chaos, e)
+	}
+	switch {
+	case *summarize != "":
+		return summarizeLogs(*summarize, plan.checks, out)
+	case *compose != "":
+		if err := writeCompose(*compose, c, *image, *port); err != nil || !*up {
+			return err
+		}
+		return launchCompose(*compose, c, plan, *port, out)
+	}
+	return launchLocal(c, plan, *host, *port, out)
+}
+
+// launchPlan 是 node 运行期间注入的故障，以及运行以后检查的不变量
+type launchPlan struct {
+	chaos  []chaosEvent
+	hooks  chaosHooks
+	checks []string
+}
+
+// controller 返回执行 p.chaos 的 chaosController，计划为空时返回 nil
+func (p launchPlan) controller(target chaosTarget, addrs []string, agg *aggregator) (*chaosController, error) {
+	if len(p.chaos) == 0 {
+		return nil, nil
+	}
+	c := &chaosController{events: p.chaos, target: target, hooks: p.hooks, addrs: addrs, agg: agg}
+	return c, c.checkHooks()
+}
+
+// launchLocal 在本机启动 c.n 个 dalg node 进程，按照 plan 注入故障，汇总它们的输出
+func launchLocal(c launchConfig, plan launchPlan, host string, port int, out io.Writer) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	agg := newAggregator(out)
+	cmds := make([]*exec.Cmd, c.n)
+	addrs := make([]string, c.n)
+	for i := range addrs {
+		addrs[i] = fmt.Sprintf("%s:%d", host, port+i)
+	}
+	chaos, err := plan.controller(processTarget(cmds), addrs, agg)
+	if err != nil {
+		return fmt.Errorf("dalg launch: %v", err)
+	}
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := range cmds {
+		cmd := exec.Command(exe, c.nodeArgs(i, addrs[i], addrs[0])...)
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return err
+		}
+		cmd.Stderr = cmd.Stdout
+		if err := cmd.Start(); err != nil {
+			killAll(cmds[:i])
+			return err
+		}
+		cmds[i] = cmd
+		name := fmt.Sprintf("node%d", i)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			scanner := bufio.NewScanner(stdout)
+			for scanner.Scan() {
+				agg.line(name, scanner.Text())
+			}
+		}()
+	}
+	if chaos != nil {
+		go chaos.run(start)
+	}
+
+	// node 会在 duration 以后自己退出，留出加入网络和退出的时间，超时就强行结束
+	timer := time.AfterFunc(c.duration+15*time.Second, func() { killAll(cmds) })
+	defer timer.Stop()
+	wg.Wait()
+	failed := 0
+	for i, cmd := range cmds {
+		name := fmt.Sprintf("node%d", i)
+		if err := cmd.Wait(); err != nil && !agg.killed(name) {
+			agg.line(name, fmt.Sprintf("进程异常退出：%v", err))
+			failed++
+		}
+	}
+	if err := agg.summary(plan.checks); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("dalg launch: %d 个 node 异常退出", failed)
+	}
+	return nil
+}
+
+func killAll(cmds []*exec.Cmd) {
+	for _, cmd := range cmds {
+		if cmd != nil && cmd.Process != nil {
+			cmd.Process.Kill()
+		}
+	}
+}
+
+// writeCompose 把在 docker compose 中运行 c.n 个 node 的配置写入 path
+// 每个 node 是一个 service，在自己的容器中运行挂载进去的 dalg
+func writeCompose(path string, c launchConfig, image string, port int) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# 由 dalg launch --compose 生成，%d 个 %s node\n", c.n, c.algo)
+	b.WriteString("# 先在这个文件所在的目录中编译 dalg：CGO_ENABLED=0 GOOS=linux go build -o dalg ./cmd/dalg\n")
+	b.WriteString("# 然后运行：docker compose up | tee compose.log，最后用 dalg launch --summarize=compose.log 汇总\n")
+	b.WriteString("services:\n")
+	first := fmt.Sprintf("node0:%d", port)
+	for i := 0; i < c.n; i++ {
+		name := fmt.Sprintf("node%d", i)
+		args := append([]string{"/dalg"}, c.nodeArgs(i, fmt.Sprintf("%s:%d", name, port), first)...)
+		for j := range args {
+			args[j] = fmt.Sprintf("%q", args[j])
+		}
+		fmt.Fprintf(&b, "  %s:\n", name)
+		fmt.Fprintf(&b, "    image: %s\n", image)
+		fmt.Fprintf(&b, "    hostname: %s\n", name)
+		b.WriteString("    volumes:\n      - ./dalg:/dalg:ro\n")
+		fmt.Fprintf(&b, "    command: [%s]\n", strings.Join(args, ", "))
+		if i > 0 {
+			b.WriteString("    depends_on:\n      - node0\n")
+		}
+	}
+	return ioutil.WriteFile(path, []byte(b.String()), 0644)
+}
+
+// launchCompose 用 docker compose up 运行 file 中的 node，按照 plan 注入故障，汇总它们的输出
+func launchCompose(file string, c launchConfig, plan launchPlan, port int, out io.Writer) error {
+	agg := newAggregator(out)
+	addrs := make([]string, c.n)
+	for i := range addrs {
+		addrs[i] = fmt.Sprintf("node%d:%d", i, port)
+	}
+	chaos, err := plan.controller(newComposeTarget(file), addrs, agg)
+	if err != nil {
+		return fmt.Errorf("dalg launch: %v", err)
+	}
+	cmd := exec.Command("docker", "compose", "-f", file, "up", "--no-color")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	cmd.Stderr = cmd.Stdout
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	if chaos != nil {
+		go chaos.run(time.Now())
+	}
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		if name, line, ok := splitComposeLine(scanner.Text()); ok {
+			agg.line(name, line)
+		}
+	}
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("dalg launch: docker compose up：%v", err)
+	}
+	return agg.summary(plan.checks)
+}
+
+// summarizeLogs 汇总 docker compose logs 的输出
+func summarizeLogs(path string, checks []string, out io.Writer) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	agg := newAggregator(ioutil.Discard)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if name, line, ok := splitComposeLine(scanner.Text()); ok {
+			agg.line(name, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	agg.out = out
+	return agg.summary(checks)
+}
+
+var composePrefix = regexp.MustCompile(`^\s*(\S+?)(?:-\d+)?\s*\|\s?(.*)$`)
+
+// splitComposeLine 把 "node0-1  | 内容" 分成 service 的名字 node0 和内容
+func splitComposeLine(s string) (name, line string, ok bool) {
+	m := composePrefix.FindStringSubmatch(s)
+	if m == nil {
+		return "", "", false
+	}
+	return m[1], m[2], true
+}
+
+// aggregator 给每个 node 的输出加上前缀，再写入同一个 out，并且记住每个 node 最后的 metrics
+type aggregator struct {
+	mu      sync.Mutex
+	out     io.Writer
+	metrics map[string]map[string]string
+	written int             // node 报告的成功写入的 key 的数量
+	dead    map[string]bool // 被 chaos 杀死的 node
+}
+
+func newAggregator(out io.Writer) *aggregator {
+	return &aggregator{
+		out:     out,
+		metrics: make(map[string]map[string]string),
+		dead:    make(map[string]bool),
+	}
+}
+
+var writtenLine = regexp.MustCompile(`^写入了 (\d+) 个 key$`)
+
+func (a *aggregator) line(node, line string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	fmt.Fprintf(a.out, "[%s] %s\n", node, line)
+	if m := writtenLine.FindStringSubmatch(line); m != nil {
+		k, _ := strconv.Atoi(m[1])
+		a.written += k
+	}
+	if !strings.HasPrefix(line, "metrics ") {
+		return
+	}
+	fields := make(map[string]string)
+	for _, f := range strings.Fields(line)[1:] {
+		if i := strings.IndexByte(f, '='); i > 0 {
+			fields[f[:i]] = f[i+1:]
+		}
+	}
+	a.metrics[node] = fields
+}
+
+// kill 记录 node 被杀死了，它最后的 metrics 已经过时，检查不变量时不再考虑它
+func (a *aggregator) kill(node string) {
+	a.mu.Lock()
+	a.dead[node] = true
+	a.mu.Unlock()
+}
+
+func (a *aggregator) killed(node string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.dead[node]
+}
+
+// invariants 是 launch 最后可以检查的不变量，live 是还活着的 node 最后的 metrics
+// 返回违反不变量的原因，满足时返回空字符串
+var invariants = map[string]func(live []map[string]string, written int) string{
+	"ring": checkRing,
+	"keys": checkKeys,
+}
+
+// checkRing 检查活着的 node 的 successor 是否连成了包含全部活着的 node 的环
+func checkRing(live []map[string]string, written int) string {
+	if len(live) == 0 {
+		return "没有活着的 node"
+	}
+	successor := make(map[string]string, len(live))
+	for _, m := range live {
+		successor[m["addr"]] = m["successor"]
+	}
+	start := live[0]["addr"]
+	addr, visited := start, make(map[string]bool, len(live))
+	for i := 0; i < len(live) && !visited[addr]; i++ {
+		visited[addr] = true
+		addr = successor[addr]
+	}
+	if addr != start || len(visited) != len(live) {
+		return fmt.Sprintf("successor 没有连成包含全部 %d 个 node 的环，从 %s 出发经过了 %d 个以后回到了 %s", len(live), start, len(visited), addr)
+	}
+	return ""
+}
+
+// checkKeys 检查成功写入的 key 是否都还保存在活着的 node 上
+func checkKeys(live []map[string]string, written int) string {
+	total := 0
+	for _, m := range live {
+		k, _ := strconv.Atoi(m["keys"])
+		total += k
+	}
+	if total != written {
+		return fmt.Sprintf("成功写入了 %d 个 key，但是活着的 node 上一共有 %d 个", written, total)
+	}
+	return ""
+}
+
+// summary 打印每个 node 最后的 metrics，并检查 checks 中的不变量
+func (a *aggregator) summary(checks []string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	nodes := make([]string, 0, len(a.metrics))
+	for node := range a.metrics {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	if len(nodes) == 0 {
+		return fmt.Errorf("dalg launch: 没有收到任何 metrics")
+	}
+	fmt.Fprintln(a.out, "各个 node 最后的 metrics：")
+	live := make([]map[string]string, 0, len(nodes))
+	for _, node := range nodes {
+		m := a.metrics[node]
+		state := ""
+		if a.dead[node] {
+			state = "（已被杀死）"
+		} else {
+			live = append(live, m)
+		}
+		fmt.Fprintf(a.out, "  %-8s addr=%s successor=%s predecessor=%s keys=%s%s\n",
+			node, m["addr"], m["successor"], m["predecessor"], m["keys"], state)
+	}
+	failed := 0
+	for _, name := range checks {
+		violation := invariants[name](live, a.written)
+		if violation != "" {
+			failed++
+		}
+		fmt.Fprintf(a.out, "不变量 %s：%s\n", name, verdict(violation))
+	}
+	if failed > 0 {
+		return fmt.Errorf("dalg launch: %d 个不变量被违反", failed)
+	}
+	return nil
+}
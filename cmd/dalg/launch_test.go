@@ -0,0 +1,106 @@
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// launch 用 os.Executable 启动 node，测试时启动的是测试程序自己
+// 设置了 DALG_TEST_MAIN 的测试程序会像 dalg 一样运行
+func TestMain(m *testing.M) {
+	if os.Getenv("DALG_TEST_MAIN") == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// freePort 返回一个当前空闲的端口
+func freePort(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+	return port
+}
+
+func Test_launch_local(t *testing.T) {
+	ast := assert.New(t)
+	//
+	os.Setenv("DALG_TEST_MAIN", "1")
+	defer os.Unsetenv("DALG_TEST_MAIN")
+	out, err := run("launch", "--n=4", "--port="+freePort(t), "--duration=3s",
+		"--interval=50ms", "--metrics=500ms", "--keys=5")
+	ast.NoError(err, out)
+	ast.Contains(out, "[node0] ")
+	ast.Contains(out, "[node3] ")
+	ast.Contains(out, "不变量 ring：满足")
+	ast.Contains(out, "不变量 keys：满足")
+}
+
+func Test_launch_compose(t *testing.T) {
+	ast := assert.New(t)
+	//
+	path := filepath.Join(t.TempDir(), "docker-compose.yml")
+	_, err := run("launch", "--n=3", "--compose="+path)
+	ast.NoError(err)
+	b, err := ioutil.ReadFile(path)
+	ast.NoError(err)
+	compose := string(b)
+	ast.Contains(compose, "  node2:\n")
+	ast.Contains(compose, `"--addr=node1:7000"`)
+	ast.Contains(compose, `"--join=node0:7000"`)
+	ast.NotContains(compose, "node3")
+}
+
+func Test_launch_summarize(t *testing.T) {
+	ast := assert.New(t)
+	//
+	ring := writeLines(t, "ring.log",
+		"node0-1  | <0000000000000001:10.0.0.2:7000> 创建了新的环",
+		"node0-1  | 写入了 3 个 key",
+		"node1-1  | 写入了 4 个 key",
+		"node0-1  | metrics addr=10.0.0.2:7000 successor=10.0.0.3:7000 predecessor=10.0.0.3:7000 keys=3",
+		"node1-1  | metrics addr=10.0.0.3:7000 successor=10.0.0.2:7000 predecessor=10.0.0.2:7000 keys=4",
+	)
+	out, err := run("launch", "--summarize="+ring)
+	ast.NoError(err)
+	ast.Contains(out, "node0    addr=10.0.0.2:7000")
+	ast.Contains(out, "不变量 ring：满足")
+	ast.Contains(out, "不变量 keys：满足")
+
+	broken := writeLines(t, "broken.log",
+		"node0-1  | metrics addr=10.0.0.2:7000 successor=10.0.0.2:7000 predecessor=- keys=0",
+		"node1-1  | metrics addr=10.0.0.3:7000 successor=10.0.0.2:7000 predecessor=- keys=0",
+	)
+	out, err = run("launch", "--summarize="+broken)
+	ast.Error(err)
+	ast.Contains(out, "不变量 ring：违反")
+	_, err = run("launch", "--summarize="+broken, "--check=keys")
+	ast.NoError(err)
+}
+
+func Test_node_badArgs(t *testing.T) {
+	ast := assert.New(t)
+	//
+	_, err := run("node", "--algo=paxos")
+	ast.Error(err)
+	_, err = run("launch", "--algo=paxos")
+	ast.Error(err)
+	_, err = run("launch", "--n=0")
+	ast.Error(err)
+	_, err = run("launch", "--check=liveness")
+	ast.Error(err)
+	_, err = run("launch", "--chaos=kill 5 at 1s")
+	ast.Error(err)
+	// delay 需要 --delay-hook
+	_, err = run("launch", "--chaos=delay 0 100ms at 1s")
+	ast.Error(err)
+}
@@ -0,0 +1,99 @@
+// dalg 在命令行中运行仓库中的各个算法，不用编写测试代码就可以做实验
+//
+// 用法：
+//
+//	dalg mutex --algo=lamport --n=5 --requests=20 --seed=42
+//	dalg raft --nodes=3 --commands=10 --trace=raft.trace
+//	dalg clocksync --algo=ntp --n=5 --rounds=20 --seed=1
+//	dalg scenario scenarios/raft-partition.json
+//	dalg diff old.trace new.trace
+//	dalg launch --algo=chord --n=5 --duration=10s --chaos="kill 3 at 4s"
+//	dalg run --algo=raymond --n=7 --param requests=5 --seed=3
+//	dalg sweep --algo=lamport --n=3,5,7 --latency=fixed:1ms,lognormal:5ms/0.5 --format=gnuplot
+//
+// 每个子命令都会打印运行摘要，--trace 指定文件时，还会把每一步写入这个文件。
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// command 是 dalg 的子命令
+type command struct {
+	summary string
+	run     func(args []string, out io.Writer) error
+}
+
+var commands = map[string]command{
+	"mutex":     {"运行 mutual exclusion 算法的确定性模拟", runMutex},
+	"raft":      {"启动 Raft 集群，提交一些命令", runRaft},
+	"clocksync": {"在漂移的物理时钟上运行时钟同步算法", runClockSync},
+	"scenario":  {"运行 JSON 文件描述的实验，并检查其中的期望", runScenario},
+	"diff":      {"比较两个 trace，报告事件数量、顺序和安全性质的差异", runDiff},
+	"node":      {"在真实的网络上运行一个 node", runNode},
+	"launch":    {"启动多个 dalg node 进程，或者生成 docker compose 的配置", runLaunch},
+	"run":       {"在 core 的模拟器上运行注册过的算法", runCore},
+	"sweep":     {"在参数的组合上反复运行注册过的算法，输出 CSV、JSON 或画图的脚本", runSweep},
+}
+
+func main() {
+	if err := dispatch(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+}
+
+func dispatch(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		usage(out)
+		return fmt.Errorf("dalg: 缺少子命令")
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		usage(out)
+		return fmt.Errorf("dalg: 未知的子命令 %q", args[0])
+	}
+	return cmd.run(args[1:], out)
+}
+
+func usage(out io.Writer) {
+	fmt.Fprintln(out, "用法：dalg <子命令> [参数]，子命令的参数用 dalg <子命令> -h 查看")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(out, "  %-10s %s\n", name, commands[name].summary)
+	}
+}
+
+// newFlagSet 返回子命令 name 的 FlagSet，出错时不会退出程序
+func newFlagSet(name string, out io.Writer) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet("dalg "+name, flag.ContinueOnError)
+	fs.SetOutput(out)
+	trace := fs.String("trace", "", "把运行的每一步写入这个文件")
+	return fs, trace
+}
+
+// writeTrace 把 lines 逐行写入 path，path 为空时什么也不做
+func writeTrace(path string, lines []string) error {
+	if path == "" {
+		return nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(f, line); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
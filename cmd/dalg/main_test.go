@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func run(args ...string) (string, error) {
+	var out bytes.Buffer
+	err := dispatch(args, &out)
+	return out.String(), err
+}
+
+func Test_dispatch(t *testing.T) {
+	ast := assert.New(t)
+	//
+	out, err := run()
+	ast.Error(err)
+	ast.Contains(out, "mutex")
+	_, err = run("paxos")
+	ast.Error(err)
+	_, err = run("mutex", "--algo=bakery")
+	ast.Error(err)
+	_, err = run("mutex", "--no-such-flag")
+	ast.Error(err)
+}
+
+func Test_mutex(t *testing.T) {
+	ast := assert.New(t)
+	//
+	path := filepath.Join(t.TempDir(), "mutex.trace")
+	out, err := run("mutex", "--algo=lamport", "--n=5", "--requests=20", "--seed=42", "--trace="+path)
+	ast.NoError(err)
+	ast.Contains(out, "5 个 process 各申请 20 次")
+	ast.Contains(out, "没有发现错误")
+	// 同样的 seed 得到同样的运行
+	again, _ := run("mutex", "--algo=lamport", "--n=5", "--requests=20", "--seed=42")
+	ast.Equal(out, again)
+	trace, err := ioutil.ReadFile(path)
+	ast.NoError(err)
+	ast.Contains(string(trace), "P0 申请")
+}
+
+func Test_mutex_nonFIFO(t *testing.T) {
+	ast := assert.New(t)
+	//
+	found := false
+	for seed := 0; seed < 50 && !found; seed++ {
+		_, err := run("mutex", "--n=3", "--requests=3", "--non-fifo", fmt.Sprintf("--seed=%d", seed))
+		found = err != nil
+	}
+	ast.True(found, "乱序送达时，应该能找到违反性质的运行")
+}
+
+func Test_clocksync(t *testing.T) {
+	ast := assert.New(t)
+	//
+	for _, algo := range []string{"cristian", "berkeley", "ntp"} {
+		path := filepath.Join(t.TempDir(), algo+".trace")
+		out, err := run("clocksync", "--algo="+algo, "--rounds=5", "--trace="+path)
+		ast.NoError(err)
+		ast.Contains(out, algo)
+		trace, _ := ioutil.ReadFile(path)
+		ast.Equal(5, strings.Count(string(trace), "\n"))
+	}
+}
+
+func Test_raft(t *testing.T) {
+	ast := assert.New(t)
+	//
+	path := filepath.Join(t.TempDir(), "raft.trace")
+	out, err := run("raft", "--nodes=3", "--commands=4", "--crash-leader", "--trace="+path)
+	ast.NoError(err)
+	ast.Contains(out, "3 个 node 提交了 4 条命令")
+	ast.Contains(out, "已断开")
+	trace, _ := ioutil.ReadFile(path)
+	ast.Contains(string(trace), "断开网络")
+	ast.Contains(string(trace), "apply index 4")
+}
+
+func Test_mutex_raymond(t *testing.T) {
+	ast := assert.New(t)
+	//
+	out, err := run("mutex", "--algo=raymond", "--n=15", "--requests=4", "--seed=1")
+	ast.NoError(err)
+	ast.Contains(out, "15 个 process 各申请 4 次")
+	ast.Contains(out, "没有发现错误")
+	out, err = run("mutex", "--algo=raymond", "--n=3", "--requests=4", "--fairness")
+	ast.NoError(err)
+	ast.Contains(out, "Jain 指数")
+	ast.Contains(out, "P2: 申请 4 次，占用 4 次")
+	out, err = run("mutex", "--n=3", "--requests=2", "--messages")
+	ast.NoError(err)
+	ast.Contains(out, "共 36 条 message")
+	ast.Contains(out, "单次申请最多用了 6 条")
+	out, err = run("mutex", "--n=3", "--requests=2", "--messages", "--suppress-acks", "--seed=1")
+	ast.NoError(err)
+	ast.Contains(out, "共 31 条 message")
+	out, err = run("mutex", "--algo=raymond", "--scale", "--n=5000", "--requests=1")
+	ast.NoError(err)
+	ast.Contains(out, "raymond：5000 个 process，5000 个申请者各申请 1 次")
+	_, err = run("mutex", "--scale", "--n=3", "--requesters=4")
+	ast.Error(err)
+	// 乱序送达不会破坏 Raymond 算法
+	_, err = run("mutex", "--algo=raymond", "--n=3", "--requests=3", "--non-fifo", "--seed=5")
+	ast.NoError(err)
+}
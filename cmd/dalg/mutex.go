@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	mutualexclusion "github.com/aQuaYi/Distributed-Algorithms/Mutual-Exclusion/code"
+)
+
+// mutexAlgorithms 是可以确定性模拟的 mutual exclusion 算法
+var mutexAlgorithms = map[string]func(mutualexclusion.ModelConfig, int64) *mutualexclusion.SimulationResult{
+	"lamport": mutualexclusion.Simulate,
+	"raymond": mutualexclusion.SimulateRaymond,
+}
+
+func runMutex(args []string, out io.Writer) error {
+	fs, trace := newFlagSet("mutex", out)
+	algo := fs.String("algo", "lamport", "算法，可选 lamport、raymond")
+	n := fs.Int("n", 3, "process 的数量")
+	requests := fs.Int("requests", 5, "每个 process 申请资源的次数")
+	seed := fs.Int64("seed", 0, "决定 message 送达顺序的随机数种子")
+	nonFIFO := fs.Bool("non-fifo", false, "message 可以乱序送达，违反算法对通道的假设")
+	fairness := fs.Bool("fairness", false, "打印各个 process 的占用次数和等待时间，检查是否有 process 饥饿")
+	messages := fs.Bool("messages", false, "按照类型和所服务的申请，统计送达的 message")
+	suppressAcks := fs.Bool("suppress-acks", false, "lamport 算法已经给申请方发送过更晚的 message 时，省略回复")
+	scale := fs.Bool("scale", false, "使用没有 goroutine 的事件循环，模拟上千个 process，只输出统计数据")
+	requesters := fs.Int("requesters", 0, "--scale 时申请资源的 process 的数量，0 表示全部")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *scale {
+		return runMutexScale(*algo, *n, *requesters, *requests, *seed, out)
+	}
+	simulate, ok := mutexAlgorithms[*algo]
+	if !ok {
+		return fmt.Errorf("dalg mutex: 未知的算法 %q", *algo)
+	}
+	if *n < 2 || *requests < 1 {
+		return fmt.Errorf("dalg mutex: 至少需要 2 个 process，每个至少申请 1 次")
+	}
+	c := mutualexclusion.ModelConfig{Processes: *n, Requests: *requests, NonFIFO: *nonFIFO, SuppressAcks: *suppressAcks}
+	res := simulate(c, *seed)
+	fmt.Fprintln(out, res)
+	if *fairness {
+		fmt.Fprintln(out, res.Fairness())
+	}
+	if *messages {
+		fmt.Fprintln(out, res.Account)
+	}
+	if err := writeTrace(*trace, res.Steps); err != nil {
+		return err
+	}
+	if !res.OK() {
+		return fmt.Errorf("dalg mutex: %s", res.Violation)
+	}
+	return nil
+}
+
+func runMutexScale(algo string, n, requesters, requests int, seed int64, out io.Writer) error {
+	if _, ok := mutexAlgorithms[algo]; !ok {
+		return fmt.Errorf("dalg mutex: 未知的算法 %q", algo)
+	}
+	if n < 2 || requests < 1 || requesters < 0 || requesters > n {
+		return fmt.Errorf("dalg mutex: 至少需要 2 个 process，每个至少申请 1 次，申请者不能多于 process")
+	}
+	res := mutualexclusion.SimulateScale(mutualexclusion.ScaleConfig{
+		Algorithm:  algo,
+		Processes:  n,
+		Requesters: requesters,
+		Requests:   requests,
+		Seed:       seed,
+	})
+	fmt.Fprintln(out, res)
+	if !res.OK() {
+		return fmt.Errorf("dalg mutex: %s", res.Violation)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	chord "github.com/aQuaYi/Distributed-Algorithms/Chord/code"
+	transport "github.com/aQuaYi/Distributed-Algorithms/Transport/code"
+)
+
+// nodeAlgorithms 是可以在单独的进程中，通过真实的网络运行一个 node 的算法
+var nodeAlgorithms = map[string]func(nodeConfig, io.Writer) error{
+	"chord": runChordNode,
+}
+
+// nodeConfig 是 dalg node 的参数
+type nodeConfig struct {
+	addr     string        // 监听的地址，也是其他 node 联系它的地址
+	join     string        // 已经在网络中的 node 的地址，为空时创建新的网络
+	interval time.Duration // 维护程序运行的间隔
+	metrics  time.Duration // 打印 metrics 的间隔
+	duration time.Duration // 运行的时间，0 表示一直运行到收到 SIGINT 或者 SIGTERM
+	keys     int           // 运行到一半时，写入的 key 的数量
+	linger   time.Duration // 运行结束以后，继续响应其他 node 的时间
+}
+
+func runNode(args []string, out io.Writer) error {
+	// node 的输出本身就是日志，所以没有 --trace
+	fs := flag.NewFlagSet("dalg node", flag.ContinueOnError)
+	fs.SetOutput(out)
+	algo := fs.String("algo", "chord", "算法，目前只有 chord")
+	var c nodeConfig
+	fs.StringVar(&c.addr, "addr", "127.0.0.1:7000", "监听的地址，其他 node 通过它联系这个 node")
+	fs.StringVar(&c.join, "join", "", "通过这个地址上的 node 加入网络，为空时创建新的网络")
+	fs.DurationVar(&c.interval, "interval", 100*time.Millisecond, "维护程序运行的间隔")
+	fs.DurationVar(&c.metrics, "metrics", time.Second, "打印 metrics 的间隔")
+	fs.DurationVar(&c.duration, "duration", 0, "运行的时间，0 表示一直运行到收到 SIGINT 或者 SIGTERM")
+	fs.IntVar(&c.keys, "keys", 0, "运行到一半时写入的 key 的数量，需要设置 --duration")
+	fs.DurationVar(&c.linger, "linger", time.Second, "--duration 结束以后继续响应其他 node 的时间")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	run, ok := nodeAlgorithms[*algo]
+	if !ok {
+		return fmt.Errorf("dalg node: 未知的算法 %q", *algo)
+	}
+	return run(c, out)
+}
+
+func runChordNode(c nodeConfig, out io.Writer) error {
+	printf := func(format string, a ...interface{}) {
+		fmt.Fprintf(out, format+"\n", a...)
+	}
+	tp := transport.NewTCPTransport("Chord")
+	defer tp.Close()
+	n, err := chord.NewNode(c.addr, tp)
+	if err != nil {
+		return fmt.Errorf("dalg node: %v", err)
+	}
+	if c.join == "" {
+		n.Create()
+		printf("%s 创建了新的环", n.Ref())
+	} else {
+		// 其他进程可能还没有开始监听，多试几次
+		deadline := time.Now().Add(10 * time.Second)
+		for err = n.Join(c.join); err != nil && time.Now().Before(deadline); err = n.Join(c.join) {
+			time.Sleep(200 * time.Millisecond)
+		}
+		if err != nil {
+			return fmt.Errorf("dalg node: 无法通过 %s 加入环：%v", c.join, err)
+		}
+		printf("%s 通过 %s 加入了环", n.Ref(), c.join)
+	}
+	n.Start(c.interval)
+	defer n.Leave()
+
+	metrics := func() {
+		printf("metrics addr=%s successor=%s predecessor=%s keys=%d",
+			n.Ref().Addr, addrOf(n.Successor()), addrOf(n.Predecessor()), n.Keys())
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	var done, half <-chan time.Time
+	if c.duration > 0 {
+		done = time.After(c.duration)
+		half = time.After(c.duration / 2)
+	}
+	ticker := time.NewTicker(c.metrics)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			metrics()
+		case <-half:
+			written := 0
+			for i := 0; i < c.keys; i++ {
+				key := fmt.Sprintf("%s/%d", n.Ref().Addr, i)
+				if err := n.Put(key, key); err != nil {
+					printf("写入 %s 失败：%v", key, err)
+					continue
+				}
+				written++
+			}
+			if c.keys > 0 {
+				printf("写入了 %d 个 key", written)
+			}
+		case <-done:
+			// 各个进程启动的时间略有不同，先离开的 node 会让其他 node 最后的 metrics 变得混乱
+			// 所以打印 metrics 以后，再等一会儿才离开
+			metrics()
+			select {
+			case <-time.After(c.linger):
+			case <-signals:
+			}
+			return nil
+		case s := <-signals:
+			printf("收到 %v，退出", s)
+			metrics()
+			return nil
+		}
+	}
+}
+
+// addrOf 返回 r 的地址，r 为空时返回 -
+func addrOf(r chord.NodeRef) string {
+	if r.IsNil() {
+		return "-"
+	}
+	return r.Addr
+}
@@ -0,0 +1,18 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// pauseProcess 用 SIGSTOP 暂停进程，进程无法忽略这个信号
+func pauseProcess(p *os.Process) error {
+	return processSignal(p, syscall.SIGSTOP)
+}
+
+// resumeProcess 用 SIGCONT 恢复被暂停的进程
+func resumeProcess(p *os.Process) error {
+	return processSignal(p, syscall.SIGCONT)
+}
@@ -0,0 +1,11 @@
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+var errNoPause = errors.New("dalg: cannot pause a process on windows")
+
+func pauseProcess(p *os.Process) error  { return errNoPause }
+func resumeProcess(p *os.Process) error { return errNoPause }
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	raft "github.com/aQuaYi/Distributed-Algorithms/Raft/code"
+	"github.com/aQuaYi/Distributed-Algorithms/Raft/code/labrpc"
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+)
+
+// raftCluster 是在 labrpc 模拟的网络上运行的 Raft 集群
+type raftCluster struct {
+	net      *labrpc.Network
+	endnames [][]string
+	rafts    []*raft.Raft
+	env      sim.Env
+
+	mu      sync.Mutex
+	groups  []int // 每个 node 所在的分区，只有同一个分区中的 node 才能通信
+	start   time.Time
+	logs    []map[int]interface{} // 每个 node 已经应用的命令
+	lines   []string              // trace
+	leaders []string              // 按顺序出现过的 leader
+	hint    int                   // 上一次提交成功的 leader，submit 从它开始尝试
+}
+
+// seedEnv 返回随机数由 seed 决定的 env，seed 为 0 时，每次运行都不同
+func seedEnv(seed int64) sim.Env {
+	if seed == 0 {
+		return sim.Env{}
+	}
+	return sim.Env{Rand: sim.NewRand(seed)}
+}
+
+func newRaftCluster(n int, env sim.Env) *raftCluster {
+	c := &raftCluster{
+		net:      labrpc.MakeNetwork(),
+		endnames: make([][]string, n),
+		rafts:    make([]*raft.Raft, n),
+		env:      env,
+		groups:   make([]int, n),
+		start:    env.Now(),
+		logs:     make([]map[int]interface{}, n),
+	}
+	c.net.SetEnv(env)
+	for i := 0; i < n; i++ {
+		c.endnames[i] = make([]string, n)
+		ends := make([]*labrpc.ClientEnd, n)
+		for j := 0; j < n; j++ {
+			c.endnames[i][j] = fmt.Sprintf("%d-%d", i, j)
+			ends[j] = c.net.MakeEnd(c.endnames[i][j])
+			c.net.Connect(c.endnames[i][j], j)
+		}
+		applyCh := make(chan raft.ApplyMsg)
+		c.logs[i] = make(map[int]interface{})
+		c.rafts[i] = raft.MakeWithOptions(ends, i, raft.MakePersister(), applyCh, raft.Options{Env: env})
+		go c.apply(i, applyCh)
+		srv := labrpc.MakeServer()
+		srv.AddService(labrpc.MakeService(c.rafts[i]))
+		c.net.AddServer(i, srv)
+	}
+	c.mu.Lock()
+	c.partition(c.groups)
+	c.mu.Unlock()
+	return c
+}
+
+func (c *raftCluster) apply(i int, applyCh chan raft.ApplyMsg) {
+	for m := range applyCh {
+		if !m.CommandValid {
+			continue
+		}
+		c.mu.Lock()
+		c.logs[i][m.CommandIndex] = m.Command
+		c.tracef("S%d apply index %d: %v", i, m.CommandIndex, m.Command)
+		c.mu.Unlock()
+	}
+}
+
+// tracef 在 c.mu 的保护下，记录一行 trace
+func (c *raftCluster) tracef(format string, a ...interface{}) {
+	elapsed := c.env.Since(c.start).Round(time.Millisecond)
+	c.lines = append(c.lines, fmt.Sprintf("%8s ", elapsed)+fmt.Sprintf(format, a...))
+}
+
+// partition 在 c.mu 的保护下，把 node i 放入分区 groups[i]
+func (c *raftCluster) partition(groups []int) {
+	copy(c.groups, groups)
+	for i := range c.rafts {
+		for j := range c.rafts {
+			c.net.Enable(c.endnames[i][j], c.groups[i] == c.groups[j])
+		}
+	}
+	c.tracef("分区 %v", c.groups)
+}
+
+// disconnect 在 c.mu 的保护下，把 node i 放入只有它自己的分区
+func (c *raftCluster) disconnect(i int) {
+	groups := append([]int(nil), c.groups...)
+	groups[i] = -1 - i
+	c.partition(groups)
+}
+
+// connected 在 c.mu 的保护下，返回 node i 是否能与多数 node 通信
+func (c *raftCluster) connected(i int) bool {
+	count := 0
+	for _, g := range c.groups {
+		if g == c.groups[i] {
+			count++
+		}
+	}
+	return count > len(c.groups)/2
+}
+
+// leader 返回自认为是 leader 并且能与多数 node 通信的 node，没有时返回 -1
+func (c *raftCluster) leader() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, rf := range c.rafts {
+		if _, isLeader := rf.GetState(); isLeader && c.connected(i) {
+			return i
+		}
+	}
+	return -1
+}
+
+// submit 把 cmd 交给 leader，直到 cmd 被提交到多数 node 上
+// 被隔离在少数派中的旧 leader 也会接受 cmd，所以每次等待失败后，从下一个 node 开始尝试
+func (c *raftCluster) submit(cmd interface{}, timeout time.Duration) (int, error) {
+	n := len(c.rafts)
+	deadline := c.env.Now().Add(timeout)
+	c.mu.Lock()
+	from := c.hint
+	c.mu.Unlock()
+	for c.env.Now().Before(deadline) {
+		for k := 0; k < n; k++ {
+			i := (from + k) % n
+			index, term, isLeader := c.rafts[i].Start(cmd)
+			if !isLeader {
+				continue
+			}
+			c.mu.Lock()
+			leader := fmt.Sprintf("S%d(term %d)", i, term)
+			if len(c.leaders) == 0 || c.leaders[len(c.leaders)-1] != leader {
+				c.leaders = append(c.leaders, leader)
+				c.tracef("%s 是 leader", leader)
+			}
+			c.tracef("S%d start index %d: %v", i, index, cmd)
+			c.mu.Unlock()
+			// 等待 cmd 被多数 node 应用，leader 可能在此期间失去领导权
+			for k := 0; k < 100; k++ {
+				if c.appliedBy(index, cmd) > n/2 {
+					return index, nil
+				}
+				c.env.Sleep(20 * time.Millisecond)
+			}
+			from = i + 1
+			break
+		}
+		c.env.Sleep(50 * time.Millisecond)
+	}
+	return 0, fmt.Errorf("dalg raft: %v 没能在 %s 内被提交", cmd, timeout)
+}
+
+// appliedBy 返回在 index 处应用了 cmd 的 node 数量
+func (c *raftCluster) appliedBy(index int, cmd interface{}) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	count := 0
+	for _, log := range c.logs {
+		if applied, ok := log[index]; ok && applied == cmd {
+			count++
+		}
+	}
+	return count
+}
+
+func runRaft(args []string, out io.Writer) error {
+	fs, trace := newFlagSet("raft", out)
+	nodes := fs.Int("nodes", 3, "node 的数量")
+	commands := fs.Int("commands", 10, "提交的命令数量")
+	crash := fs.Bool("crash-leader", false, "提交一半的命令以后，断开 leader 的网络")
+	timeout := fs.Duration("timeout", 10*time.Second, "每条命令的提交时限")
+	seed := fs.Int64("seed", 0, "决定选举超时和网络延迟的随机数种子，0 表示每次运行都不同")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *nodes < 1 || *commands < 1 {
+		return fmt.Errorf("dalg raft: 至少需要 1 个 node 和 1 条命令")
+	}
+	c := newRaftCluster(*nodes, seedEnv(*seed))
+	defer c.net.Cleanup()
+	//
+	for i := 1; i <= *commands; i++ {
+		if *crash && i == *commands/2+1 {
+			if j := c.leader(); j >= 0 {
+				c.mu.Lock()
+				c.tracef("S%d 断开网络", j)
+				c.disconnect(j)
+				c.mu.Unlock()
+			}
+		}
+		if _, err := c.submit(i*100, *timeout); err != nil {
+			return err
+		}
+	}
+	//
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elapsed := c.env.Since(c.start).Round(time.Millisecond)
+	fmt.Fprintf(out, "%d 个 node 提交了 %d 条命令，用时 %s\n", *nodes, *commands, elapsed)
+	fmt.Fprintf(out, "leader：%v\n", c.leaders)
+	for i, log := range c.logs {
+		state := "已连接"
+		if !c.connected(i) {
+			state = "已断开"
+		}
+		fmt.Fprintf(out, "S%d（%s）应用了 %d 条命令\n", i, state, len(log))
+	}
+	return writeTrace(*trace, c.lines)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	core "github.com/aQuaYi/Distributed-Algorithms/Core/code"
+	reliablechannel "github.com/aQuaYi/Distributed-Algorithms/Reliable-Channel/code"
+
+	// 以下 package 只在 init 中注册算法
+	_ "github.com/aQuaYi/Distributed-Algorithms/EPaxos/code"
+	_ "github.com/aQuaYi/Distributed-Algorithms/Kademlia/code"
+)
+
+// paramList 是可以重复设置的 --param 参数
+type paramList core.Params
+
+func (l paramList) String() string {
+	parts := make([]string, 0, len(l))
+	for k, v := range l {
+		parts = append(parts, k+"="+v)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, " ")
+}
+
+func (l paramList) Set(s string) error {
+	i := strings.Index(s, "=")
+	if i <= 0 {
+		return fmt.Errorf("参数 %q 应该写成 key=value", s)
+	}
+	l[s[:i]] = s[i+1:]
+	return nil
+}
+
+func runCore(args []string, out io.Writer) error {
+	fs, trace := newFlagSet("run", out)
+	algo := fs.String("algo", "lamport", "注册到 core 的算法，--list 可以列出全部")
+	list := fs.Bool("list", false, "列出所有注册到 core 的算法和它们的参数")
+	n := fs.Int("n", 3, "node 的数量")
+	seed := fs.Int64("seed", 0, "决定 message 延迟和算法中随机数的种子")
+	minLatency := fs.Duration("min-latency", time.Millisecond, "message 延迟的下限")
+	maxLatency := fs.Duration("max-latency", 10*time.Millisecond, "message 延迟的上限")
+	duration := fs.Duration("duration", time.Minute, "模拟时间的上限，0 表示一直运行到没有事件为止")
+	params := paramList{}
+	fs.Var(params, "param", "算法的参数，写成 key=value，可以重复")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *list {
+		listAlgorithms(out)
+		return nil
+	}
+	if *n < 1 || *minLatency < 0 || *maxLatency < *minLatency {
+		return fmt.Errorf("dalg run: 至少需要 1 个 node，延迟的上限不能小于下限")
+	}
+	var latency reliablechannel.Latency = reliablechannel.Fixed(*minLatency)
+	if *maxLatency > *minLatency {
+		latency = reliablechannel.Uniform{Min: *minLatency, Max: *maxLatency}
+	}
+	res, err := core.Run(*algo, core.Config{
+		Nodes:   *n,
+		Params:  core.Params(params),
+		Seed:    *seed,
+		Latency: latency,
+		MaxTime: *duration,
+	})
+	if err != nil {
+		return fmt.Errorf("dalg run: %s", err)
+	}
+	fmt.Fprintln(out, res)
+	if err := writeTrace(*trace, res.Trace); err != nil {
+		return err
+	}
+	if !res.OK() {
+		return fmt.Errorf("dalg run: %s", res.Violation)
+	}
+	return nil
+}
+
+func listAlgorithms(out io.Writer) {
+	for _, a := range core.Algorithms() {
+		fmt.Fprintf(out, "%-10s [%s] %s\n", a.Name, a.Kind, a.Summary)
+		keys := make([]string, 0, len(a.Params))
+		for k := range a.Params {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(out, "  --param %s=...  %s\n", k, a.Params[k])
+		}
+	}
+}
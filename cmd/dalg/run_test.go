@@ -0,0 +1,49 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_run(t *testing.T) {
+	ast := assert.New(t)
+	//
+	path := filepath.Join(t.TempDir(), "run.trace")
+	out, err := run("run", "--algo=raymond", "--n=5", "--param", "requests=2", "--seed=3", "--trace="+path)
+	ast.NoError(err)
+	ast.Contains(out, "raymond：5 个 node")
+	ast.Contains(out, "grants=10")
+	ast.Contains(out, "没有发现错误")
+	data, err := ioutil.ReadFile(path)
+	ast.NoError(err)
+	ast.True(strings.Contains(string(data), "P0 申请资源"))
+	//
+	out, err = run("run", "--algo=epaxos", "--n=5", "--param", "commands=2")
+	ast.NoError(err)
+	ast.Contains(out, "commits=10")
+	ast.Contains(out, "没有发现错误")
+	out, err = run("run", "--algo=kademlia", "--n=10", "--param", "lookups=1")
+	ast.NoError(err)
+	ast.Contains(out, "lookups=10")
+	ast.Contains(out, "没有发现错误")
+	//
+	_, err = run("run", "--algo=bakery")
+	ast.Error(err)
+	_, err = run("run", "--param", "requests")
+	ast.Error(err)
+}
+
+func Test_run_list(t *testing.T) {
+	ast := assert.New(t)
+	//
+	out, err := run("run", "--list")
+	ast.NoError(err)
+	ast.Contains(out, "lamport    [mutex]")
+	ast.Contains(out, "epaxos     [consensus]")
+	ast.Contains(out, "kademlia   [dht]")
+	ast.Contains(out, "--param requests=...")
+}
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	clocksync "github.com/aQuaYi/Distributed-Algorithms/Clock-Sync/code"
+	mutualexclusion "github.com/aQuaYi/Distributed-Algorithms/Mutual-Exclusion/code"
+)
+
+// Scenario 是用 JSON 描述的一次可以重现的实验
+type Scenario struct {
+	Name string `json:"name"`
+	// Algorithm 可选 lamport、raymond、raft、cristian、berkeley、ntp
+	Algorithm string   `json:"algorithm"`
+	Nodes     int      `json:"nodes"`
+	Seed      int64    `json:"seed"`
+	Workload  Workload `json:"workload"`
+	// Faults 是故障计划，每一条的格式见 parseFault
+	Faults []string `json:"faults"`
+	Expect Expect   `json:"expect"`
+}
+
+// Workload 是实验的负载，不同的算法使用不同的字段
+type Workload struct {
+	Requests int      `json:"requests"` // lamport、raymond：每个 process 申请资源的次数
+	Commands int      `json:"commands"` // raft：提交的命令数量
+	Interval Duration `json:"interval"` // raft：提交两条命令之间的间隔
+	Rounds   int      `json:"rounds"`   // clocksync：同步的轮数
+}
+
+// Expect 是实验结束后需要检查的期望，零值的字段不检查
+type Expect struct {
+	// Violation 是期望违反的性质，为空时要求不违反任何性质
+	Violation string `json:"violation"`
+	// Committed 是至少需要提交的命令数量
+	Committed int `json:"committed"`
+	// Leaders 是至少需要出现的 leader 数量
+	Leaders int `json:"leaders"`
+	// AllApplied 为 true 时，要求最后所有的 node 都应用了全部提交的命令
+	AllApplied bool `json:"allApplied"`
+	// MaxElapsed 是运行时间的上限
+	MaxElapsed Duration `json:"maxElapsed"`
+	// MaxResidual 是同步后残余 skew 的上限
+	MaxResidual Duration `json:"maxResidual"`
+}
+
+// Duration 在 JSON 中写作 "500ms" 这样的字符串
+type Duration time.Duration
+
+// UnmarshalJSON 解析 time.ParseDuration 能识别的字符串
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	*d = Duration(v)
+	return err
+}
+
+// fault 是解析后的一条故障
+type fault struct {
+	at     time.Duration
+	action string  // partition、heal、disconnect 或者 reorder
+	groups [][]int // partition 的各个分区
+	node   int     // disconnect 的 node，-1 表示当时的 leader
+}
+
+var faultPattern = regexp.MustCompile(`^(.+?)(?: at (?:t=)?(\S+))?$`)
+
+// parseFault 解析一条故障，可以是：
+//
+//	partition {0,1}|{2,3,4} at t=500ms
+//	heal at 2s
+//	disconnect 2 at 1s
+//	disconnect leader at 1s
+//	reorder messages
+//
+// 前四种用于 raft，时间从实验开始时算起；reorder messages 用于 lamport 和 raymond，让 message 乱序送达
+func parseFault(s string) (fault, error) {
+	f := fault{node: -1}
+	m := faultPattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return f, fmt.Errorf("无法解析故障 %q", s)
+	}
+	if m[2] != "" {
+		at, err := time.ParseDuration(m[2])
+		if err != nil {
+			return f, fmt.Errorf("故障 %q 的时间：%v", s, err)
+		}
+		f.at = at
+	}
+	fields := strings.Fields(m[1])
+	f.action = fields[0]
+	switch {
+	case f.action == "heal" && len(fields) == 1:
+	case f.action == "reorder" && len(fields) == 2 && fields[1] == "messages":
+	case f.action == "disconnect" && len(fields) == 2:
+		if fields[1] != "leader" {
+			n, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return f, fmt.Errorf("故障 %q 中的 node：%v", s, err)
+			}
+			f.node = n
+		}
+	case f.action == "partition" && len(fields) == 2:
+		for _, g := range strings.Split(fields[1], "|") {
+			g = strings.TrimSuffix(strings.TrimPrefix(g, "{"), "}")
+			var group []int
+			for _, x := range strings.Split(g, ",") {
+				n, err := strconv.Atoi(x)
+				if err != nil {
+					return f, fmt.Errorf("故障 %q 中的分区：%v", s, err)
+				}
+				group = append(group, n)
+			}
+			f.groups = append(f.groups, group)
+		}
+	default:
+		return f, fmt.Errorf("无法解析故障 %q", s)
+	}
+	return f, nil
+}
+
+// Outcome 是运行 Scenario 的结果
+type Outcome struct {
+	Summary  string
+	Failures []string // 没有满足的期望
+	Trace    []string
+}
+
+// OK 返回 true，如果满足了所有的期望
+func (o *Outcome) OK() bool {
+	return len(o.Failures) == 0
+}
+
+func (o *Outcome) failf(format string, a ...interface{}) {
+	o.Failures = append(o.Failures, fmt.Sprintf(format, a...))
+}
+
+// LoadScenario 从 r 中读取 Scenario
+func LoadScenario(r io.Reader) (*Scenario, error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	s := &Scenario{}
+	if err := dec.Decode(s); err != nil {
+		return nil, err
+	}
+	if s.Nodes < 1 {
+		return nil, fmt.Errorf("scenario %q: nodes 至少为 1", s.Name)
+	}
+	return s, nil
+}
+
+func loadScenarioFile(path string) (*Scenario, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadScenario(f)
+}
+
+// Run 运行 s，并检查 s.Expect
+func (s *Scenario) Run() (*Outcome, error) {
+	faults := make([]fault, len(s.Faults))
+	for i, str := range s.Faults {
+		f, err := parseFault(str)
+		if err != nil {
+			return nil, err
+		}
+		faults[i] = f
+	}
+	sort.SliceStable(faults, func(i, j int) bool { return faults[i].at < faults[j].at })
+	start := time.Now()
+	var o *Outcome
+	switch s.Algorithm {
+	case "lamport", "raymond":
+		o = s.runMutex(faults)
+	case "raft":
+		o = s.runRaft(faults)
+	case "cristian", "berkeley", "ntp":
+		if len(faults) > 0 {
+			return nil, fmt.Errorf("scenario %q: %s 不支持故障", s.Name, s.Algorithm)
+		}
+		o = s.runClockSync()
+	default:
+		return nil, fmt.Errorf("scenario %q: 未知的算法 %q", s.Name, s.Algorithm)
+	}
+	elapsed := time.Since(start)
+	if max := time.Duration(s.Expect.MaxElapsed); max > 0 && elapsed > max {
+		o.failf("运行了 %s，超过了 %s", elapsed.Round(time.Millisecond), max)
+	}
+	return o, nil
+}
+
+func (s *Scenario) runMutex(faults []fault) *Outcome {
+	c := mutualexclusion.ModelConfig{Processes: s.Nodes, Requests: s.Workload.Requests}
+	for _, f := range faults {
+		if f.action == "reorder" {
+			c.NonFIFO = true
+		}
+	}
+	res := mutexAlgorithms[s.Algorithm](c, s.Seed)
+	o := &Outcome{Summary: res.String(), Trace: res.Steps}
+	switch {
+	case s.Expect.Violation == "" && !res.OK():
+		o.failf("违反了 %s", res.Violation)
+	case s.Expect.Violation != "" && !strings.Contains(res.Violation, s.Expect.Violation):
+		o.failf("期望违反 %s，实际为 %q", s.Expect.Violation, res.Violation)
+	}
+	return o
+}
+
+func (s *Scenario) runClockSync() *Outcome {
+	var alg clocksync.Algorithm
+	switch s.Algorithm {
+	case "cristian":
+		alg = clocksync.Cristian{Samples: 4}
+	case "berkeley":
+		alg = clocksync.Berkeley{Tolerance: 50 * time.Millisecond}
+	case "ntp":
+		alg = clocksync.NewNTP(0, 8, 0)
+	}
+	sim := clocksync.NewSimulation(s.Nodes, clockSyncConfig, s.Seed)
+	report := clocksync.Run(sim, alg, s.Workload.Rounds, 10*time.Second)
+	o := &Outcome{Summary: report.String()}
+	for i := range report.After {
+		o.Trace = append(o.Trace, fmt.Sprintf("round %d: skew %s -> %s", i+1, report.Before[i], report.After[i]))
+	}
+	if max := time.Duration(s.Expect.MaxResidual); max > 0 && report.MaxResidual() > max {
+		o.failf("残余 skew 最大为 %s，超过了 %s", report.MaxResidual(), max)
+	}
+	return o
+}
+
+func (s *Scenario) runRaft(faults []fault) *Outcome {
+	c := newRaftCluster(s.Nodes, seedEnv(s.Seed))
+	defer c.net.Cleanup()
+	// 按照计划注入故障
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, f := range faults {
+			c.env.Sleep(c.start.Add(f.at).Sub(c.env.Now()))
+			c.inject(f)
+		}
+	}()
+	committed := 0
+	for i := 1; i <= s.Workload.Commands; i++ {
+		if _, err := c.submit(i*100, 10*time.Second); err != nil {
+			break
+		}
+		committed++
+		c.env.Sleep(time.Duration(s.Workload.Interval))
+	}
+	<-done
+	// 故障全部结束以后，给落后的 node 一些时间追上来
+	allApplied := func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for _, log := range c.logs {
+			if len(log) < committed {
+				return false
+			}
+		}
+		return true
+	}
+	if s.Expect.AllApplied {
+		for k := 0; k < 100 && !allApplied(); k++ {
+			c.env.Sleep(50 * time.Millisecond)
+		}
+	}
+	//
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	o := &Outcome{
+		Summary: fmt.Sprintf("%d 个 node 提交了 %d/%d 条命令，leader：%v", s.Nodes, committed, s.Workload.Commands, c.leaders),
+		Trace:   c.lines,
+	}
+	if committed < s.Expect.Committed {
+		o.failf("只提交了 %d 条命令，少于 %d 条", committed, s.Expect.Committed)
+	}
+	if len(c.leaders) < s.Expect.Leaders {
+		o.failf("只出现了 %d 个 leader，少于 %d 个", len(c.leaders), s.Expect.Leaders)
+	}
+	if s.Expect.AllApplied {
+		for i, log := range c.logs {
+			if len(log) < committed {
+				o.failf("S%d 只应用了 %d 条命令", i, len(log))
+			}
+		}
+	}
+	return o
+}
+
+// inject 在 c 上注入故障 f
+// disconnect leader 时如果还没有选出 leader，会等到选出 leader 为止，最多等待 5s
+func (c *raftCluster) inject(f fault) {
+	leader := -1
+	if f.action == "disconnect" && f.node < 0 {
+		for k := 0; k < 100 && leader < 0; k++ {
+			if leader = c.leader(); leader < 0 {
+				c.env.Sleep(50 * time.Millisecond)
+			}
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch f.action {
+	case "heal":
+		c.partition(make([]int, len(c.groups)))
+	case "disconnect":
+		if f.node >= 0 {
+			leader = f.node
+		}
+		if leader >= 0 && leader < len(c.groups) {
+			c.tracef("S%d 断开网络", leader)
+			c.disconnect(leader)
+		}
+	case "partition":
+		groups := make([]int, len(c.groups))
+		for i := range groups {
+			groups[i] = -1 - i // 没有列出的 node 被单独隔离
+		}
+		for g, nodes := range f.groups {
+			for _, n := range nodes {
+				if n >= 0 && n < len(groups) {
+					groups[n] = g
+				}
+			}
+		}
+		c.partition(groups)
+	}
+}
+
+func runScenario(args []string, out io.Writer) error {
+	fs, trace := newFlagSet("scenario", out)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("dalg scenario: 需要至少一个 scenario 文件")
+	}
+	failed := 0
+	var lines []string
+	for _, path := range fs.Args() {
+		s, err := loadScenarioFile(path)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		o, err := s.Run()
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		result := "PASS"
+		if !o.OK() {
+			result = "FAIL"
+			failed++
+		}
+		fmt.Fprintf(out, "--- %s: %s (%s)\n%s\n", result, s.Name, path, o.Summary)
+		for _, f := range o.Failures {
+			fmt.Fprintf(out, "    %s\n", f)
+		}
+		lines = append(lines, "# "+s.Name)
+		lines = append(lines, o.Trace...)
+	}
+	if err := writeTrace(*trace, lines); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("dalg scenario: %d 个 scenario 没有满足期望", failed)
+	}
+	return nil
+}
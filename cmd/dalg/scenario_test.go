@@ -0,0 +1,97 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseFault(t *testing.T) {
+	ast := assert.New(t)
+	//
+	f, err := parseFault("partition {0,1}|{2,3,4} at t=500ms")
+	ast.NoError(err)
+	ast.Equal("partition", f.action)
+	ast.Equal(500*time.Millisecond, f.at)
+	ast.Equal([][]int{{0, 1}, {2, 3, 4}}, f.groups)
+	f, err = parseFault("heal at 2s")
+	ast.NoError(err)
+	ast.Equal("heal", f.action)
+	ast.Equal(2*time.Second, f.at)
+	f, err = parseFault("disconnect 2 at 1s")
+	ast.NoError(err)
+	ast.Equal(2, f.node)
+	f, err = parseFault("disconnect leader at 1s")
+	ast.NoError(err)
+	ast.Equal(-1, f.node)
+	f, err = parseFault("reorder messages")
+	ast.NoError(err)
+	ast.Equal("reorder", f.action)
+	//
+	for _, s := range []string{
+		"",
+		"crash 1 at 1s",
+		"heal at soon",
+		"disconnect at 1s",
+		"disconnect x at 1s",
+		"partition {0,a}|{2} at 1s",
+		"reorder",
+	} {
+		_, err := parseFault(s)
+		ast.Error(err, s)
+	}
+}
+
+func Test_LoadScenario(t *testing.T) {
+	ast := assert.New(t)
+	//
+	s, err := LoadScenario(strings.NewReader(`{"name": "x", "algorithm": "raft", "nodes": 3,
+		"workload": {"commands": 2, "interval": "10ms"}, "expect": {"maxElapsed": "1m"}}`))
+	ast.NoError(err)
+	ast.Equal(Duration(10*time.Millisecond), s.Workload.Interval)
+	ast.Equal(Duration(time.Minute), s.Expect.MaxElapsed)
+	// 拼错的字段不会被悄悄地忽略
+	_, err = LoadScenario(strings.NewReader(`{"name": "x", "algorithm": "raft", "nodes": 3, "node": 5}`))
+	ast.Error(err)
+	_, err = LoadScenario(strings.NewReader(`{"name": "x", "algorithm": "raft"}`))
+	ast.Error(err)
+	_, err = LoadScenario(strings.NewReader(`{"name": "x", "nodes": 3, "workload": {"interval": "fast"}}`))
+	ast.Error(err)
+}
+
+func Test_Scenario_Run(t *testing.T) {
+	ast := assert.New(t)
+	//
+	s := &Scenario{Name: "x", Algorithm: "bakery", Nodes: 3}
+	_, err := s.Run()
+	ast.Error(err)
+	s = &Scenario{Name: "x", Algorithm: "ntp", Nodes: 3, Faults: []string{"heal at 1s"}}
+	_, err = s.Run()
+	ast.Error(err)
+	// 没有满足的期望会出现在 Failures 中
+	s = &Scenario{Name: "x", Algorithm: "lamport", Nodes: 3, Workload: Workload{Requests: 2},
+		Expect: Expect{Violation: "AlwaysAtMostOneOccupier"}}
+	o, err := s.Run()
+	ast.NoError(err)
+	ast.False(o.OK())
+	ast.Len(o.Failures, 1)
+}
+
+func Test_scenario(t *testing.T) {
+	ast := assert.New(t)
+	//
+	paths, _ := filepath.Glob("scenarios/*.json")
+	ast.NotEmpty(paths)
+	for _, path := range paths {
+		out, err := run("scenario", path)
+		ast.NoError(err, path)
+		ast.Contains(out, "--- PASS")
+	}
+	_, err := run("scenario")
+	ast.Error(err)
+	_, err = run("scenario", "scenarios/no-such-file.json")
+	ast.Error(err)
+}
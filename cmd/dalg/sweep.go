@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	core "github.com/aQuaYi/Distributed-Algorithms/Core/code"
+	experiments "github.com/aQuaYi/Distributed-Algorithms/Experiments/code"
+)
+
+func runSweep(args []string, out io.Writer) error {
+	fs, _ := newFlagSet("sweep", out)
+	algo := fs.String("algo", "lamport", "注册到 core 的算法，dalg run --list 可以列出全部")
+	nodes := fs.String("n", "3,5,7", "node 的数量，用逗号分隔")
+	losses := fs.String("loss", "0", "message 丢失的概率，用逗号分隔")
+	latencies := fs.String("latency", "fixed:1ms,uniform:1ms-10ms", "延迟分布，用逗号分隔，可以是 none、fixed:5ms、uniform:1ms-10ms 或 lognormal:5ms/0.5")
+	seeds := fs.Int("seeds", 5, "每个组合用不同的种子运行的次数")
+	duration := fs.Duration("duration", time.Minute, "每次运行的模拟时间上限")
+	format := fs.String("format", "csv", "输出的格式：csv、json、gnuplot 或 echarts")
+	column := fs.String("column", "throughput", "gnuplot 和 echarts 的纵轴："+strings.Join(experiments.Columns, "、"))
+	output := fs.String("o", "", "把结果写入这个文件，默认打印出来")
+	params := paramList{}
+	fs.Var(params, "param", "算法的参数，写成 key=value，可以重复")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	s := experiments.Sweep{
+		Algorithm: *algo,
+		Params:    core.Params(params),
+		Seeds:     *seeds,
+		MaxTime:   *duration,
+	}
+	for _, f := range strings.Split(*nodes, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil || n < 1 {
+			return fmt.Errorf("dalg sweep: node 的数量 %q 不是正整数", f)
+		}
+		s.Nodes = append(s.Nodes, n)
+	}
+	for _, f := range strings.Split(*losses, ",") {
+		loss, err := strconv.ParseFloat(strings.TrimSpace(f), 64)
+		if err != nil || loss < 0 || loss > 1 {
+			return fmt.Errorf("dalg sweep: 丢失概率 %q 不在 0 到 1 之间", f)
+		}
+		s.LossRates = append(s.LossRates, loss)
+	}
+	for _, f := range strings.Split(*latencies, ",") {
+		d, err := experiments.ParseDistribution(strings.TrimSpace(f))
+		if err != nil {
+			return fmt.Errorf("dalg sweep: %s", err)
+		}
+		s.Latencies = append(s.Latencies, d)
+	}
+	var write func(r *experiments.Report, w io.Writer) error
+	switch *format {
+	case "csv":
+		write = (*experiments.Report).WriteCSV
+	case "json":
+		write = (*experiments.Report).WriteJSON
+	case "gnuplot":
+		write = func(r *experiments.Report, w io.Writer) error { return r.WriteGnuplot(w, *column) }
+	case "echarts":
+		write = func(r *experiments.Report, w io.Writer) error { return r.WriteECharts(w, *column) }
+	default:
+		return fmt.Errorf("dalg sweep: 未知的格式 %q", *format)
+	}
+
+	r, err := s.Run()
+	if err != nil {
+		return fmt.Errorf("dalg sweep: %s", err)
+	}
+	if *output == "" {
+		return write(r, out)
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := write(r, f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "%d 个组合的结果写入了 %s\n", len(r.Points), *output)
+	return nil
+}
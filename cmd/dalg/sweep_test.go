@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_sweep(t *testing.T) {
+	ast := assert.New(t)
+	//
+	out, err := run("sweep", "--n=2,3", "--latency=fixed:1ms", "--loss=0,0.5", "--seeds=2", "--param", "requests=1")
+	ast.NoError(err)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	ast.Equal(5, len(lines))
+	ast.True(strings.HasPrefix(lines[0], "algorithm,nodes,lossRate,latency,runs,throughput"))
+	ast.True(strings.HasPrefix(lines[1], "lamport,2,0,fixed:1ms,2,"))
+	//
+	path := filepath.Join(t.TempDir(), "sweep.gp")
+	out, err = run("sweep", "--algo=raymond", "--n=3", "--seeds=1", "--format=gnuplot", "--column=messages", "-o", path)
+	ast.NoError(err)
+	ast.Equal("2 个组合的结果写入了 "+path+"\n", out)
+	data, err := ioutil.ReadFile(path)
+	ast.NoError(err)
+	ast.Contains(string(data), `set title "raymond messages"`)
+	//
+	out, err = run("sweep", "--n=3", "--seeds=1", "--format=echarts")
+	ast.NoError(err)
+	ast.Contains(out, `"series"`)
+	//
+	for _, args := range [][]string{
+		{"--n=0"},
+		{"--loss=2"},
+		{"--latency=gauss:1ms"},
+		{"--format=xml"},
+		{"--algo=bakery"},
+		{"--format=gnuplot", "--column=p99"},
+	} {
+		_, err := run(append([]string{"sweep"}, args...)...)
+		ast.Error(err, "%v", args)
+	}
+}
@@ -0,0 +1,160 @@
+// bank 是一个分布式的银行
+// 账户分布在 KV-Store 的多个 shard 上，每个 shard 由 Raft 复制，
+// 转账是跨 shard 的事务，由 TxnClient 通过 2PC 提交。
+// 所有转账结束以后，全部账户的余额之和应该与开始时相同。
+//
+// 用法：
+//
+//	go run ./examples/bank --shards=3 --accounts=5 --clients=4 --transfers=5 --mode=2pl
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strconv"
+	"sync"
+
+	core "github.com/aQuaYi/Distributed-Algorithms/Core/code"
+	kvstore "github.com/aQuaYi/Distributed-Algorithms/KV-Store/code"
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+)
+
+// initial 是每个账户的初始余额
+const initial = 100
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+}
+
+func account(i int) string {
+	return fmt.Sprintf("account-%d", i)
+}
+
+// transfer 在 tx 中从 from 转 amount 到 to
+func transfer(tx *kvstore.Txn, from, to string, amount int) error {
+	a, _, err := tx.GetForUpdate(from)
+	if err != nil {
+		return err
+	}
+	b, _, err := tx.GetForUpdate(to)
+	if err != nil {
+		return err
+	}
+	x, _ := strconv.Atoi(a)
+	y, _ := strconv.Atoi(b)
+	if err := tx.Put(from, strconv.Itoa(x-amount)); err != nil {
+		return err
+	}
+	return tx.Put(to, strconv.Itoa(y+amount))
+}
+
+func parseMode(s string) (kvstore.Mode, error) {
+	switch s {
+	case "2pl":
+		return kvstore.TwoPhaseLocking, nil
+	case "occ":
+		return kvstore.Optimistic, nil
+	}
+	return 0, fmt.Errorf("bank: 未知的 --mode %q，只能是 2pl 或 occ", s)
+}
+
+func run(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("bank", flag.ContinueOnError)
+	fs.SetOutput(out)
+	shards := fs.Int("shards", 3, "shard 的数量")
+	replicas := fs.Int("replicas", 3, "每个 shard 的副本数量")
+	accounts := fs.Int("accounts", 5, "账户的数量")
+	clients := fs.Int("clients", 4, "同时转账的 client 数量")
+	transfers := fs.Int("transfers", 5, "每个 client 转账的次数")
+	modeName := fs.String("mode", "2pl", "并发控制的方式：2pl 或 occ")
+	seed := fs.Int64("seed", 0, "决定转账双方的种子")
+	trace := fs.String("trace", "", "把每次转账的结果写入这个文件")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	mode, err := parseMode(*modeName)
+	if err != nil {
+		return err
+	}
+	if *shards < 1 || *replicas < 1 || *accounts < 2 || *clients < 1 || *transfers < 0 {
+		return fmt.Errorf("bank: 至少需要 1 个 shard 和 2 个账户")
+	}
+
+	s := kvstore.MakeShards(*shards, *replicas)
+	defer s.Cleanup()
+	err = s.MakeTxnClient(mode).Run(func(tx *kvstore.Txn) error {
+		for i := 0; i < *accounts; i++ {
+			if err := tx.Put(account(i), strconv.Itoa(initial)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("bank: 开户失败：%s", err)
+	}
+
+	log := core.NewTraceLog(sim.Env{})
+	metrics := core.NewMetrics()
+	errs := make(chan error, *clients)
+	var wg sync.WaitGroup
+	for c := 0; c < *clients; c++ {
+		wg.Add(1)
+		go func(c int) {
+			defer wg.Done()
+			tc := s.MakeTxnClient(mode)
+			rnd := rand.New(rand.NewSource(*seed + int64(c)))
+			for i := 0; i < *transfers; i++ {
+				from := rnd.Intn(*accounts)
+				to := (from + 1 + rnd.Intn(*accounts-1)) % *accounts
+				amount := 1 + rnd.Intn(20)
+				err := tc.Run(func(tx *kvstore.Txn) error {
+					metrics.Add("attempts", 1)
+					return transfer(tx, account(from), account(to), amount)
+				})
+				if err != nil {
+					errs <- err
+					return
+				}
+				metrics.Add("commits", 1)
+				log.Tracef(c, "%s → %s 转账 %d", account(from), account(to), amount)
+			}
+		}(c)
+	}
+	wg.Wait()
+	close(errs)
+	if err := <-errs; err != nil {
+		return fmt.Errorf("bank: 转账失败：%s", err)
+	}
+
+	total := 0
+	err = s.MakeTxnClient(mode).Run(func(tx *kvstore.Txn) error {
+		total = 0
+		for i := 0; i < *accounts; i++ {
+			value, _, err := tx.Get(account(i))
+			if err != nil {
+				return err
+			}
+			n, _ := strconv.Atoi(value)
+			total += n
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("bank: 对账失败：%s", err)
+	}
+	fmt.Fprintf(out, "%s 模式下完成 %d 次转账，总余额 %d：%s\n", mode, metrics.Get("commits"), total, metrics)
+	if err := log.WriteFile(*trace); err != nil {
+		return err
+	}
+	if total != *accounts*initial {
+		return fmt.Errorf("bank: 总余额应该是 %d，实际是 %d", *accounts*initial, total)
+	}
+	return nil
+}
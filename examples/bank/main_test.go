@@ -0,0 +1,21 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_run(t *testing.T) {
+	ast := assert.New(t)
+	//
+	for _, mode := range []string{"2pl", "occ"} {
+		var out bytes.Buffer
+		ast.Nil(run([]string{"--shards=2", "--accounts=4", "--clients=3", "--transfers=3", "--mode=" + mode}, &out), mode)
+		ast.Contains(out.String(), "完成 9 次转账，总余额 400", mode)
+	}
+	//
+	var out bytes.Buffer
+	ast.Equal(`bank: 未知的 --mode "mvcc"，只能是 2pl 或 occ`, run([]string{"--mode=mvcc"}, &out).Error())
+}
@@ -0,0 +1,47 @@
+package main
+
+// GCounter 是只增不减的计数器，是最简单的 state-based CRDT
+// 每个副本只增加自己的那一项，合并时逐项取最大值，
+// 所以无论以什么顺序、合并多少次，所有副本最终都会收敛到相同的值。
+type GCounter struct {
+	me     int
+	counts []int
+}
+
+// NewGCounter 返回 n 个副本中第 me 个副本的 GCounter
+func NewGCounter(me, n int) *GCounter {
+	return &GCounter{me: me, counts: make([]int, n)}
+}
+
+// Increment 增加本副本的计数
+func (g *GCounter) Increment(delta int) {
+	g.counts[g.me] += delta
+}
+
+// Value 返回计数器的值
+func (g *GCounter) Value() int {
+	sum := 0
+	for _, c := range g.counts {
+		sum += c
+	}
+	return sum
+}
+
+// Merge 把其他副本的状态合并进来
+func (g *GCounter) Merge(other *GCounter) {
+	for i, c := range other.counts {
+		if c > g.counts[i] {
+			g.counts[i] = c
+		}
+	}
+}
+
+// Equal 判断两个副本的状态是否相同
+func (g *GCounter) Equal(other *GCounter) bool {
+	for i, c := range other.counts {
+		if c != g.counts[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GCounter(t *testing.T) {
+	ast := assert.New(t)
+	//
+	a, b := NewGCounter(0, 2), NewGCounter(1, 2)
+	a.Increment(3)
+	b.Increment(2)
+	ast.Equal(3, a.Value())
+	ast.False(a.Equal(b))
+	// 合并是幂等的，也与顺序无关
+	a.Merge(b)
+	a.Merge(b)
+	b.Merge(a)
+	ast.Equal(5, a.Value())
+	ast.Equal(5, b.Value())
+	ast.True(a.Equal(b))
+	// 旧的状态不会覆盖新的状态
+	old := NewGCounter(0, 2)
+	old.Increment(1)
+	a.Merge(old)
+	ast.Equal(5, a.Value())
+}
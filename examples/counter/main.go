@@ -0,0 +1,156 @@
+// counter 用两种方式实现一个多副本的计数器
+//
+//   - raft：计数器保存在 KV-Store 中，由 Raft 复制，每个 client 用 CompareAndSwap 循环加一，
+//     冲突时重试，结果是线性一致的。
+//   - crdt：每个副本持有一个 GCounter，只在本地加一，副本之间随机地交换状态，
+//     不需要协调，最终所有副本收敛到相同的值。
+//
+// 用法：
+//
+//	go run ./examples/counter --mode=raft --replicas=3 --clients=4 --increments=10
+//	go run ./examples/counter --mode=crdt --replicas=5 --clients=5 --increments=10 --seed=1
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strconv"
+	"sync"
+
+	core "github.com/aQuaYi/Distributed-Algorithms/Core/code"
+	kvstore "github.com/aQuaYi/Distributed-Algorithms/KV-Store/code"
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+}
+
+type options struct {
+	replicas, clients, increments int
+	seed                          int64
+	log                           *core.TraceLog
+	metrics                       *core.Metrics
+}
+
+func run(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("counter", flag.ContinueOnError)
+	fs.SetOutput(out)
+	mode := fs.String("mode", "raft", "计数器的实现：raft 或 crdt")
+	o := options{log: core.NewTraceLog(sim.Env{}), metrics: core.NewMetrics()}
+	fs.IntVar(&o.replicas, "replicas", 3, "副本的数量")
+	fs.IntVar(&o.clients, "clients", 4, "client 的数量，crdt 模式下 client i 访问副本 i % replicas")
+	fs.IntVar(&o.increments, "increments", 10, "每个 client 加一的次数")
+	fs.Int64Var(&o.seed, "seed", 0, "crdt 模式下决定 gossip 对象的种子")
+	trace := fs.String("trace", "", "把计数的过程写入这个文件")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if o.replicas < 1 || o.clients < 1 || o.increments < 0 {
+		return fmt.Errorf("counter: 至少需要 1 个副本和 1 个 client")
+	}
+	var value int
+	var err error
+	switch *mode {
+	case "raft":
+		value, err = runRaft(o)
+	case "crdt":
+		value, err = runCRDT(o)
+	default:
+		return fmt.Errorf("counter: 未知的 --mode %q，只能是 raft 或 crdt", *mode)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "%s 计数器的值是 %d：%s\n", *mode, value, o.metrics)
+	if err := o.log.WriteFile(*trace); err != nil {
+		return err
+	}
+	if want := o.clients * o.increments; value != want {
+		return fmt.Errorf("counter: 计数器应该是 %d，实际是 %d", want, value)
+	}
+	return nil
+}
+
+// runRaft 让所有 client 通过 CompareAndSwap 给 KV-Store 中的计数器加一
+func runRaft(o options) (int, error) {
+	const key = "counter"
+	c := kvstore.MakeCluster(o.replicas)
+	defer c.Cleanup()
+	var wg sync.WaitGroup
+	for i := 0; i < o.clients; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ck := c.MakeClerk()
+			for j := 0; j < o.increments; j++ {
+				for {
+					old, _ := ck.Get(key)
+					n, _ := strconv.Atoi(old)
+					if ck.CompareAndSwap(key, old, strconv.Itoa(n+1)) {
+						o.log.Tracef(i, "%d → %d", n, n+1)
+						break
+					}
+					o.metrics.Add("cas.retries", 1)
+				}
+				o.metrics.Add("increments", 1)
+			}
+		}(i)
+	}
+	wg.Wait()
+	value, _ := c.MakeClerk().Get(key)
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("counter: 读到的计数器 %q 不是整数", value)
+	}
+	return n, nil
+}
+
+// runCRDT 先让各个 client 在自己的副本上加一，每次加一之后随机地与一个副本 gossip，
+// 最后不断 gossip 直到所有副本收敛
+func runCRDT(o options) (int, error) {
+	rnd := rand.New(rand.NewSource(o.seed))
+	replicas := make([]*GCounter, o.replicas)
+	for i := range replicas {
+		replicas[i] = NewGCounter(i, o.replicas)
+	}
+	gossip := func(from int) {
+		to := rnd.Intn(o.replicas)
+		if to == from {
+			return
+		}
+		replicas[to].Merge(replicas[from])
+		o.metrics.Add("gossips", 1)
+		o.log.Tracef(from, "gossip 到 P%d，P%d 读到 %d", to, to, replicas[to].Value())
+	}
+	for j := 0; j < o.increments; j++ {
+		for i := 0; i < o.clients; i++ {
+			r := i % o.replicas
+			replicas[r].Increment(1)
+			o.metrics.Add("increments", 1)
+			gossip(r)
+		}
+	}
+	for !converged(replicas) {
+		o.metrics.Add("rounds", 1)
+		for i := range replicas {
+			gossip(i)
+		}
+	}
+	return replicas[0].Value(), nil
+}
+
+func converged(replicas []*GCounter) bool {
+	for _, r := range replicas[1:] {
+		if !r.Equal(replicas[0]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_run(t *testing.T) {
+	ast := assert.New(t)
+	//
+	var out bytes.Buffer
+	ast.Nil(run([]string{"--mode=raft", "--clients=3", "--increments=5"}, &out))
+	ast.Contains(out.String(), "raft 计数器的值是 15")
+	//
+	out.Reset()
+	ast.Nil(run([]string{"--mode=crdt", "--replicas=4", "--clients=6", "--increments=5", "--seed=3"}, &out))
+	ast.Contains(out.String(), "crdt 计数器的值是 30")
+	//
+	ast.Error(run([]string{"--mode=paxos"}, &out))
+}
+
+func Test_runCRDT_deterministic(t *testing.T) {
+	ast := assert.New(t)
+	//
+	var a, b bytes.Buffer
+	ast.Nil(run([]string{"--mode=crdt", "--seed=7"}, &a))
+	ast.Nil(run([]string{"--mode=crdt", "--seed=7"}, &b))
+	ast.Equal(a.String(), b.String())
+}
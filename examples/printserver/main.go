@@ -0,0 +1,127 @@
+// printserver 是一个分布式的打印服务
+// 多个 client 共用一台打印机，用 Lamport 的 mutual exclusion 算法决定谁可以打印，
+// 每个 client 的文档都会完整地连续打印出来，不会与其他 client 的文档交错。
+//
+// 用法：
+//
+//	go run ./examples/printserver --clients=3 --jobs=2 --lines=3 --trace=print.trace
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	core "github.com/aQuaYi/Distributed-Algorithms/Core/code"
+	mutualexclusion "github.com/aQuaYi/Distributed-Algorithms/Mutual-Exclusion/code"
+	"github.com/aQuaYi/Distributed-Algorithms/Sim/code"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+}
+
+// printer 是共用的打印机，它只记录有没有两个 client 同时打印
+type printer struct {
+	mutex    sync.Mutex
+	out      io.Writer
+	printing int // 正在打印的 client 数量
+	overlaps int // 同时打印的次数，算法正确时为 0
+}
+
+func (p *printer) start() {
+	p.mutex.Lock()
+	p.printing++
+	if p.printing > 1 {
+		p.overlaps++
+	}
+	p.mutex.Unlock()
+}
+
+func (p *printer) finish() {
+	p.mutex.Lock()
+	p.printing--
+	p.mutex.Unlock()
+}
+
+func (p *printer) println(format string, args ...interface{}) {
+	p.mutex.Lock()
+	fmt.Fprintf(p.out, format+"\n", args...)
+	p.mutex.Unlock()
+}
+
+// client 是 Pi 占用的 Resource，每次占用打印机，就打印自己的下一份文档
+type client struct {
+	id      int
+	lines   int
+	jobs    int // 已经打印的文档数量
+	printer *printer
+	trace   *core.TraceLog
+	metrics *core.Metrics
+	done    *sync.WaitGroup
+}
+
+func (c *client) Occupy(ts mutualexclusion.Timestamp) {
+	c.printer.start()
+	c.jobs++
+	c.trace.Tracef(c.id, "开始打印 job %d %s", c.jobs, ts)
+	for l := 1; l <= c.lines; l++ {
+		c.printer.println("P%d job %d 第 %d 行", c.id, c.jobs, l)
+	}
+	c.metrics.Add("jobs", 1)
+	c.metrics.Add("lines", c.lines)
+}
+
+func (c *client) Release(ts mutualexclusion.Timestamp) {
+	c.trace.Tracef(c.id, "打印完毕 %s", ts)
+	c.printer.finish()
+	c.done.Done()
+}
+
+func run(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("printserver", flag.ContinueOnError)
+	fs.SetOutput(out)
+	clients := fs.Int("clients", 3, "client 的数量")
+	jobs := fs.Int("jobs", 2, "每个 client 打印的文档数量")
+	lines := fs.Int("lines", 3, "每份文档的行数")
+	trace := fs.String("trace", "", "把申请和打印的过程写入这个文件")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *clients < 2 || *jobs < 1 || *lines < 1 {
+		return fmt.Errorf("printserver: 至少需要 2 个 client，每个至少打印 1 份文档")
+	}
+
+	p := &printer{out: out}
+	log := core.NewTraceLog(sim.Env{})
+	metrics := core.NewMetrics()
+	var done sync.WaitGroup
+	done.Add(*clients * *jobs)
+	rs := make([]mutualexclusion.Resource, *clients)
+	for i := range rs {
+		rs[i] = &client{id: i, lines: *lines, printer: p, trace: log, metrics: metrics, done: &done}
+	}
+	for i, proc := range mutualexclusion.NewProcesses(rs) {
+		go func(i int, proc mutualexclusion.Process) {
+			for j := 0; j < *jobs; j++ {
+				log.Tracef(i, "申请打印机")
+				proc.Request()
+			}
+		}(i, proc)
+	}
+	done.Wait()
+
+	fmt.Fprintf(out, "%d 个 client 打印了 %d 份文档：%s\n", *clients, metrics.Get("jobs"), metrics)
+	if err := log.WriteFile(*trace); err != nil {
+		return err
+	}
+	if p.overlaps > 0 {
+		return fmt.Errorf("printserver: 有 %d 次两个 client 同时打印", p.overlaps)
+	}
+	return nil
+}
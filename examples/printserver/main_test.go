@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_run(t *testing.T) {
+	ast := assert.New(t)
+	//
+	var out bytes.Buffer
+	ast.Nil(run([]string{"--clients=4", "--jobs=3", "--lines=5"}, &out))
+	ast.Contains(out.String(), "4 个 client 打印了 12 份文档：jobs=12 lines=60")
+	// 每份文档的 5 行都是连续打印的
+	lines := strings.Split(out.String(), "\n")
+	for i := 0; i < 12; i++ {
+		first := lines[i*5]
+		var id, job int
+		_, err := fmt.Sscanf(first, "P%d job %d", &id, &job)
+		ast.Nil(err, first)
+		for l := 1; l <= 5; l++ {
+			ast.Equal(fmt.Sprintf("P%d job %d 第 %d 行", id, job, l), lines[i*5+l-1])
+		}
+	}
+	//
+	ast.Error(run([]string{"--clients=1"}, &out))
+}
@@ -1,6 +1,22 @@
 #!/usr/bin/env bash
 
 set -e
+
+# bash ./test.sh race 在 race detector 下运行并发最密集的测试
+# 带有 //go:build race 的测试文件只在这时编译，完整的测试在 -race 下太慢，所以只运行 RACE_TESTS 中的测试
+if [ "$1" == "race" ]; then
+    RACE_TESTS=(
+        "./Mutual-Exclusion/... -run race"
+        "./KV-Store/..."
+        "./Percolator/..."
+    )
+    for t in "${RACE_TESTS[@]}"; do
+        echo $t
+        go test -race -count=1 $t
+    done
+    exit 0
+fi
+
 echo "" > coverage.txt
 
 for d in $(go list ./... | grep -v vendor); do
@@ -10,4 +26,4 @@ for d in $(go list ./... | grep -v vendor); do
         cat profile.out >> coverage.txt
         rm profile.out
     fi
-done
\ No newline at end of file
+done